@@ -26,7 +26,10 @@ import (
 
 func main() {
 	rootCmd := cmd.NewRootCommand()
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := cmd.NewSignalContext(rootCmd.Context())
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -23,8 +23,19 @@ import (
 const (
 	GroupName = "mlp.mia-platform.eu"
 
-	DataFromFile    = "file"
-	DataFromLiteral = "literal"
+	DataFromFile          = "file"
+	DataFromLiteral       = "literal"
+	DataFromLiteralBase64 = "literal-base64"
+	DataFromMerge         = "merge"
+
+	MergeOrderDeclared = "declared"
+	MergeOrderSorted   = "sorted"
+
+	SecretEncodingData       = "data"
+	SecretEncodingStringData = "stringData"
+
+	KeystoreFormatPKCS12 = "pkcs12"
+	KeystoreFormatJKS    = "jks"
 )
 
 var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1"}
@@ -44,17 +55,37 @@ type SecretSpec struct {
 	When   string        `json:"when" yaml:"when"`
 	TLS    *TLS          `json:"tls" yaml:"tls"`
 	Docker *DockerConfig `json:"docker" yaml:"docker"`
-	Data   []Data        `json:"data" yaml:"data"`
+	// Keystore add to the secret a Java keystore containing a certificate and its private key
+	Keystore *Keystore `json:"keystore,omitempty" yaml:"keystore,omitempty"`
+	Data     []Data    `json:"data" yaml:"data"`
+	// Encoding select the field where the content of data is saved, data for base64 values or stringData for
+	// keeping the UTF-8 values in clear text, the binary values are always saved in data
+	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+
+	FilenameTemplate string          `json:"filenameTemplate,omitempty" yaml:"filenameTemplate,omitempty"`
+	Rotation         *SecretRotation `json:"rotation,omitempty" yaml:"rotation,omitempty"`
+}
+
+// SecretRotation describes how often the content of a generated secret is expected to be rotated
+type SecretRotation struct {
+	// Interval is the rotation period expressed as a duration, with the additional support for days (d)
+	// and weeks (w) units, e.g. 90d
+	Interval string `json:"interval" yaml:"interval"`
 }
 
 type ConfigMapSpec struct {
 	Name string `json:"name" yaml:"name"`
 	Data []Data `json:"data" yaml:"data"`
+
+	FilenameTemplate string `json:"filenameTemplate,omitempty" yaml:"filenameTemplate,omitempty"`
 }
 
 type TLS struct {
-	Cert *TLSData `json:"cert" yaml:"cert"`
-	Key  *TLSData `json:"key" yaml:"key"`
+	Cert   *TLSData `json:"cert" yaml:"cert"`
+	Key    *TLSData `json:"key" yaml:"key"`
+	PKCS12 *PKCS12  `json:"pkcs12,omitempty" yaml:"pkcs12,omitempty"`
+
+	CAConfigMap string `json:"caConfigMap,omitempty" yaml:"caConfigMap,omitempty"`
 }
 
 type TLSData struct {
@@ -63,7 +94,38 @@ type TLSData struct {
 	Value string `json:"value" yaml:"value"`
 }
 
+// PKCS12 contains the source of a PKCS#12 archive containing the certificate, its chain and the private key,
+// a literal value must be base64 encoded
+type PKCS12 struct {
+	From       string `json:"from" yaml:"from"`
+	File       string `json:"file" yaml:"file"`
+	Value      string `json:"value" yaml:"value"`
+	Passphrase string `json:"passphrase" yaml:"passphrase"`
+}
+
+// Keystore describes a Java keystore built from a certificate and its private key in PEM format, when Cert and Key
+// are not set they are read from the tls block of the same secret
+type Keystore struct {
+	// Format is the format of the keystore, pkcs12 or jks
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// DataKey is the key of the secret where the keystore is saved
+	DataKey  string   `json:"dataKey,omitempty" yaml:"dataKey,omitempty"`
+	Alias    string   `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Password string   `json:"password" yaml:"password"`
+	Cert     *TLSData `json:"cert,omitempty" yaml:"cert,omitempty"`
+	Key      *TLSData `json:"key,omitempty" yaml:"key,omitempty"`
+}
+
+// DockerConfig contains the credentials of the docker registries saved in the same secret, the registry set
+// with the inline fields is saved together with the ones listed in Registries
 type DockerConfig struct {
+	DockerRegistry `json:",inline" yaml:",inline"`
+
+	Registries []DockerRegistry `json:"registries,omitempty" yaml:"registries,omitempty"`
+}
+
+// DockerRegistry contains the credentials of a single docker registry
+type DockerRegistry struct {
 	Username string `json:"username" yaml:"username"`
 	Password string `json:"password" yaml:"password"`
 	Email    string `json:"email" yaml:"email"`
@@ -75,4 +137,18 @@ type Data struct {
 	File  string `json:"file" yaml:"file"`
 	Key   string `json:"key" yaml:"key"`
 	Value string `json:"value" yaml:"value"`
+	Merge *Merge `json:"merge,omitempty" yaml:"merge,omitempty"`
+	// Optional omit the key instead of failing when the file or one of the environment variables used are missing
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
+	// Default is the value used for the key when the file or one of the environment variables used are missing
+	Default *string `json:"default,omitempty" yaml:"default,omitempty"`
+	// Binary force the content to be saved base64 encoded, even if it is valid UTF-8
+	Binary bool `json:"binary,omitempty" yaml:"binary,omitempty"`
+}
+
+// Merge contains the fragments that will be joined in a single key, file sources can use glob patterns
+type Merge struct {
+	Sources   []Data  `json:"sources" yaml:"sources"`
+	Separator *string `json:"separator,omitempty" yaml:"separator,omitempty"`
+	Order     string  `json:"order,omitempty" yaml:"order,omitempty"`
 }
@@ -26,7 +26,9 @@ func (in *ConfigMapSpec) DeepCopyInto(out *ConfigMapSpec) {
 	if in.Data != nil {
 		in, out := &in.Data, &out.Data
 		*out = make([]Data, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	return
 }
@@ -44,6 +46,16 @@ func (in *ConfigMapSpec) DeepCopy() *ConfigMapSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Data) DeepCopyInto(out *Data) {
 	*out = *in
+	if in.Merge != nil {
+		in, out := &in.Merge, &out.Merge
+		*out = new(Merge)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = new(string)
+		**out = **in
+	}
 	return
 }
 
@@ -60,6 +72,12 @@ func (in *Data) DeepCopy() *Data {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DockerConfig) DeepCopyInto(out *DockerConfig) {
 	*out = *in
+	out.DockerRegistry = in.DockerRegistry
+	if in.Registries != nil {
+		in, out := &in.Registries, &out.Registries
+		*out = make([]DockerRegistry, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -73,6 +91,22 @@ func (in *DockerConfig) DeepCopy() *DockerConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DockerRegistry) DeepCopyInto(out *DockerRegistry) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerRegistry.
+func (in *DockerRegistry) DeepCopy() *DockerRegistry {
+	if in == nil {
+		return nil
+	}
+	out := new(DockerRegistry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GenerateConfiguration) DeepCopyInto(out *GenerateConfiguration) {
 	*out = *in
@@ -104,6 +138,92 @@ func (in *GenerateConfiguration) DeepCopy() *GenerateConfiguration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Keystore) DeepCopyInto(out *Keystore) {
+	*out = *in
+	if in.Cert != nil {
+		in, out := &in.Cert, &out.Cert
+		*out = new(TLSData)
+		**out = **in
+	}
+	if in.Key != nil {
+		in, out := &in.Key, &out.Key
+		*out = new(TLSData)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Keystore.
+func (in *Keystore) DeepCopy() *Keystore {
+	if in == nil {
+		return nil
+	}
+	out := new(Keystore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Merge) DeepCopyInto(out *Merge) {
+	*out = *in
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]Data, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Separator != nil {
+		in, out := &in.Separator, &out.Separator
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Merge.
+func (in *Merge) DeepCopy() *Merge {
+	if in == nil {
+		return nil
+	}
+	out := new(Merge)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PKCS12) DeepCopyInto(out *PKCS12) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PKCS12.
+func (in *PKCS12) DeepCopy() *PKCS12 {
+	if in == nil {
+		return nil
+	}
+	out := new(PKCS12)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretRotation) DeepCopyInto(out *SecretRotation) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretRotation.
+func (in *SecretRotation) DeepCopy() *SecretRotation {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretRotation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretSpec) DeepCopyInto(out *SecretSpec) {
 	*out = *in
@@ -115,12 +235,24 @@ func (in *SecretSpec) DeepCopyInto(out *SecretSpec) {
 	if in.Docker != nil {
 		in, out := &in.Docker, &out.Docker
 		*out = new(DockerConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Keystore != nil {
+		in, out := &in.Keystore, &out.Keystore
+		*out = new(Keystore)
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Data != nil {
 		in, out := &in.Data, &out.Data
 		*out = make([]Data, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rotation != nil {
+		in, out := &in.Rotation, &out.Rotation
+		*out = new(SecretRotation)
+		**out = **in
 	}
 	return
 }
@@ -148,6 +280,11 @@ func (in *TLS) DeepCopyInto(out *TLS) {
 		*out = new(TLSData)
 		**out = **in
 	}
+	if in.PKCS12 != nil {
+		in, out := &in.PKCS12, &out.PKCS12
+		*out = new(PKCS12)
+		**out = **in
+	}
 	return
 }
 
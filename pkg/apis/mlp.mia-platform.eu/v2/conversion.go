@@ -0,0 +1,45 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+)
+
+// ConvertFromV1 return the v2 configuration equivalent to in, the fields added in v2 are left empty so the
+// resources are generated like before
+func ConvertFromV1(in *v1.GenerateConfiguration) *GenerateConfiguration {
+	in = in.DeepCopy()
+	out := &GenerateConfiguration{}
+	out.APIVersion = SchemeGroupVersion.String()
+	out.Kind = GenerateConfigurationKind
+
+	if in.Secrets != nil {
+		out.Secrets = make([]SecretSpec, 0, len(in.Secrets))
+		for _, secret := range in.Secrets {
+			out.Secrets = append(out.Secrets, SecretSpec{SecretSpec: secret})
+		}
+	}
+
+	if in.ConfigMaps != nil {
+		out.ConfigMaps = make([]ConfigMapSpec, 0, len(in.ConfigMaps))
+		for _, configMap := range in.ConfigMaps {
+			out.ConfigMaps = append(out.ConfigMaps, ConfigMapSpec{ConfigMapSpec: configMap})
+		}
+	}
+
+	return out
+}
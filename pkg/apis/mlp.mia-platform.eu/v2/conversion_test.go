@@ -0,0 +1,73 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"testing"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertFromV1(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in       *v1.GenerateConfiguration
+		expected *GenerateConfiguration
+	}{
+		"empty configuration": {
+			in: &v1.GenerateConfiguration{},
+			expected: &GenerateConfiguration{
+				TypeMeta: metav1.TypeMeta{APIVersion: "mlp.mia-platform.eu/v2", Kind: "GenerateConfiguration"},
+			},
+		},
+		"secrets and configmaps": {
+			in: &v1.GenerateConfiguration{
+				TypeMeta: metav1.TypeMeta{APIVersion: "mlp.mia-platform.eu/v1"},
+				Secrets: []v1.SecretSpec{
+					{Name: "secret", When: "once", Data: []v1.Data{{From: v1.DataFromLiteral, Key: "key", Value: "value"}}},
+				},
+				ConfigMaps: []v1.ConfigMapSpec{
+					{Name: "configmap", Data: []v1.Data{{From: v1.DataFromFile, File: "file.txt"}}, FilenameTemplate: "{{.Name}}.yaml"},
+				},
+			},
+			expected: &GenerateConfiguration{
+				TypeMeta: metav1.TypeMeta{APIVersion: "mlp.mia-platform.eu/v2", Kind: "GenerateConfiguration"},
+				Secrets: []SecretSpec{
+					{SecretSpec: v1.SecretSpec{Name: "secret", When: "once", Data: []v1.Data{{From: v1.DataFromLiteral, Key: "key", Value: "value"}}}},
+				},
+				ConfigMaps: []ConfigMapSpec{
+					{ConfigMapSpec: v1.ConfigMapSpec{Name: "configmap", Data: []v1.Data{{From: v1.DataFromFile, File: "file.txt"}}, FilenameTemplate: "{{.Name}}.yaml"}},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			out := ConvertFromV1(test.in)
+			assert.Equal(t, test.expected, out)
+			if len(test.in.Secrets) > 0 {
+				out.Secrets[0].Data[0].Value = "changed"
+				assert.Equal(t, "value", test.in.Secrets[0].Data[0].Value, "the input must not be modified")
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// examplesPath is the folder where the schemas and the CRD of the configuration are published
+var examplesPath = filepath.Join("..", "..", "..", "..", "examples")
+
+func TestJSONSchemas(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]reflect.Type{
+		"generateconfiguration-v1.schema.json": reflect.TypeOf(v1.GenerateConfiguration{}),
+		"generateconfiguration-v2.schema.json": reflect.TypeOf(GenerateConfiguration{}),
+	}
+
+	for file, configType := range tests {
+		t.Run(file, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := os.ReadFile(filepath.Join(examplesPath, file))
+			require.NoError(t, err)
+			schema := make(map[string]interface{})
+			require.NoError(t, json.Unmarshal(data, &schema))
+
+			defs, _ := schema["$defs"].(map[string]interface{})
+			assertSchemaMatchType(t, configType, schema, defs, nil, "")
+		})
+	}
+}
+
+func TestCRD(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile(filepath.Join(examplesPath, "generateconfiguration-crd.yaml"))
+	require.NoError(t, err)
+	crd := new(apiextensionsv1.CustomResourceDefinition)
+	require.NoError(t, yaml.UnmarshalStrict(data, crd))
+
+	assert.Equal(t, GroupName, crd.Spec.Group)
+	assert.Equal(t, GenerateConfigurationKind, crd.Spec.Names.Kind)
+
+	versionTypes := map[string]reflect.Type{
+		"v1": reflect.TypeOf(v1.GenerateConfiguration{}),
+		"v2": reflect.TypeOf(GenerateConfiguration{}),
+	}
+	require.Len(t, crd.Spec.Versions, len(versionTypes))
+	for _, version := range crd.Spec.Versions {
+		configType, found := versionTypes[version.Name]
+		require.True(t, found, "unknown version %s", version.Name)
+
+		data, err := json.Marshal(version.Schema.OpenAPIV3Schema)
+		require.NoError(t, err)
+		schema := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(data, &schema))
+		assertSchemaMatchType(t, configType, schema, nil, nil, version.Name)
+	}
+}
+
+// assertSchemaMatchType check that the properties of schema are the same json fields of typ, recursively; the
+// types already visited are not checked again for supporting recursive definitions
+func assertSchemaMatchType(t *testing.T, typ reflect.Type, schema, defs map[string]interface{}, visiting []reflect.Type, path string) {
+	t.Helper()
+
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, found := defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{})
+		require.True(t, found, "missing definition %s at %s", ref, path)
+		schema = resolved
+	}
+
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Slice:
+		assert.Equal(t, "array", schema["type"], path)
+		items, _ := schema["items"].(map[string]interface{})
+		assertSchemaMatchType(t, typ.Elem(), items, defs, visiting, path+"[]")
+	case reflect.Map:
+		assert.Equal(t, "object", schema["type"], path)
+		values, _ := schema["additionalProperties"].(map[string]interface{})
+		assertSchemaMatchType(t, typ.Elem(), values, defs, visiting, path+"{}")
+	case reflect.Struct:
+		if slices.Contains(visiting, typ) {
+			return
+		}
+		visiting = append(visiting, typ)
+
+		assert.Equal(t, "object", schema["type"], path)
+		properties, _ := schema["properties"].(map[string]interface{})
+		fields := jsonFields(typ)
+		assert.ElementsMatch(t, slices.Collect(maps.Keys(fields)), slices.Collect(maps.Keys(properties)), path)
+		for name, field := range fields {
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				assertSchemaMatchType(t, field, property, defs, visiting, path+"."+name)
+			}
+		}
+	case reflect.String:
+		assert.Equal(t, "string", schema["type"], path)
+	case reflect.Bool:
+		assert.Equal(t, "boolean", schema["type"], path)
+	default:
+		t.Errorf("unsupported type %s at %s", typ, path)
+	}
+}
+
+// jsonFields return the types of the fields of typ keyed by their json name, including the inline ones
+func jsonFields(typ reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for field := range slices.Values(reflect.VisibleFields(typ)) {
+		if !field.IsExported() || len(field.Index) > 1 && field.Anonymous {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && len(name) == 0 {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
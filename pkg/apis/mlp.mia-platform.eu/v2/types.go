@@ -0,0 +1,84 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	GroupName = v1.GroupName
+
+	// GenerateConfigurationKind is the kind of the generate configuration files
+	GenerateConfigurationKind = "GenerateConfiguration"
+
+	SealedScopeStrict        = "strict"
+	SealedScopeNamespaceWide = "namespace-wide"
+	SealedScopeClusterWide   = "cluster-wide"
+
+	// OutputKubernetes save the resource as a Kubernetes manifest, it is the default output
+	OutputKubernetes = "k8s"
+	// OutputEnv save the data of the resource as a dotenv file
+	OutputEnv = "env"
+	// OutputTfvars save the data of the resource as a Terraform variables file in the JSON syntax
+	OutputTfvars = "tfvars"
+)
+
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v2"}
+
+type GenerateConfiguration struct {
+	metav1.TypeMeta `json:",inline" yaml:",inline"`
+
+	// Namespace is set on all the generated resources
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Labels are added to all the generated resources
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Sealed encrypt the generated secrets as SealedSecret resources, that can be safely committed
+	Sealed *SealedOutput `json:"sealed,omitempty" yaml:"sealed,omitempty"`
+
+	Secrets    []SecretSpec    `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	ConfigMaps []ConfigMapSpec `json:"configMaps,omitempty" yaml:"configMaps,omitempty"`
+}
+
+// SealedOutput describes how the secrets are encrypted for the Sealed Secrets controller
+type SealedOutput struct {
+	// Certificate is the path of the PEM encoded public certificate of the controller
+	Certificate string `json:"certificate" yaml:"certificate"`
+	// Scope is the sealing scope of the secrets: strict, namespace-wide or cluster-wide
+	Scope string `json:"scope,omitempty" yaml:"scope,omitempty"`
+}
+
+// SecretSpec contains secret configurations
+type SecretSpec struct {
+	v1.SecretSpec `json:",inline" yaml:",inline"`
+
+	// Directories contains the paths of folders whose files are added to the secret, using their names as keys
+	Directories []string `json:"directories,omitempty" yaml:"directories,omitempty"`
+	// Output is the format of the generated file: k8s, env or tfvars
+	Output string `json:"output,omitempty" yaml:"output,omitempty"`
+}
+
+// ConfigMapSpec contains configmap configurations
+type ConfigMapSpec struct {
+	v1.ConfigMapSpec `json:",inline" yaml:",inline"`
+
+	// Directories contains the paths of folders whose files are added to the configmap, using their names as keys
+	Directories []string `json:"directories,omitempty" yaml:"directories,omitempty"`
+	// Output is the format of the generated file: k8s, env or tfvars
+	Output string `json:"output,omitempty" yaml:"output,omitempty"`
+}
@@ -0,0 +1,280 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capabilities
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	// MinimumKubernetesVersion is the oldest Kubernetes minor version with bundled capabilities
+	MinimumKubernetesVersion = "1.19"
+	// LatestKubernetesVersion is the most recent Kubernetes minor version with bundled capabilities, newer versions
+	// will use its data
+	LatestKubernetesVersion = "1.32"
+
+	minimumMinor = 19
+	latestMinor  = 32
+)
+
+// builtinResource contains a resource served by Kubernetes from the minor version added until the minor version
+// removed, a zero value means that the resource is served since the oldest or until the latest supported version
+type builtinResource struct {
+	name       string
+	kind       string
+	namespaced bool
+	added      int
+	removed    int
+}
+
+// builtinVersion contains the resources served for a version of a built-in API group
+type builtinVersion struct {
+	version   string
+	resources []builtinResource
+}
+
+// builtinGroup contains the versions of a built-in API group, sorted from the most preferred to the least one
+type builtinGroup struct {
+	name     string
+	versions []builtinVersion
+}
+
+// builtinAPIs contains the APIs served by Kubernetes for the workloads and configurations resources, with the
+// minor versions where they have been added or removed
+var builtinAPIs = []builtinGroup{
+	{name: "", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "configmaps", kind: "ConfigMap", namespaced: true},
+			{name: "endpoints", kind: "Endpoints", namespaced: true},
+			{name: "events", kind: "Event", namespaced: true},
+			{name: "limitranges", kind: "LimitRange", namespaced: true},
+			{name: "namespaces", kind: "Namespace"},
+			{name: "nodes", kind: "Node"},
+			{name: "persistentvolumeclaims", kind: "PersistentVolumeClaim", namespaced: true},
+			{name: "persistentvolumes", kind: "PersistentVolume"},
+			{name: "pods", kind: "Pod", namespaced: true},
+			{name: "podtemplates", kind: "PodTemplate", namespaced: true},
+			{name: "replicationcontrollers", kind: "ReplicationController", namespaced: true},
+			{name: "resourcequotas", kind: "ResourceQuota", namespaced: true},
+			{name: "secrets", kind: "Secret", namespaced: true},
+			{name: "serviceaccounts", kind: "ServiceAccount", namespaced: true},
+			{name: "services", kind: "Service", namespaced: true},
+		}},
+	}},
+	{name: "admissionregistration.k8s.io", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "mutatingwebhookconfigurations", kind: "MutatingWebhookConfiguration"},
+			{name: "validatingadmissionpolicies", kind: "ValidatingAdmissionPolicy", added: 30},
+			{name: "validatingadmissionpolicybindings", kind: "ValidatingAdmissionPolicyBinding", added: 30},
+			{name: "validatingwebhookconfigurations", kind: "ValidatingWebhookConfiguration"},
+		}},
+	}},
+	{name: "apiextensions.k8s.io", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "customresourcedefinitions", kind: "CustomResourceDefinition"},
+		}},
+		{version: "v1beta1", resources: []builtinResource{
+			{name: "customresourcedefinitions", kind: "CustomResourceDefinition", removed: 22},
+		}},
+	}},
+	{name: "apps", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "controllerrevisions", kind: "ControllerRevision", namespaced: true},
+			{name: "daemonsets", kind: "DaemonSet", namespaced: true},
+			{name: "deployments", kind: "Deployment", namespaced: true},
+			{name: "replicasets", kind: "ReplicaSet", namespaced: true},
+			{name: "statefulsets", kind: "StatefulSet", namespaced: true},
+		}},
+	}},
+	{name: "autoscaling", versions: []builtinVersion{
+		{version: "v2", resources: []builtinResource{
+			{name: "horizontalpodautoscalers", kind: "HorizontalPodAutoscaler", namespaced: true, added: 23},
+		}},
+		{version: "v1", resources: []builtinResource{
+			{name: "horizontalpodautoscalers", kind: "HorizontalPodAutoscaler", namespaced: true},
+		}},
+		{version: "v2beta2", resources: []builtinResource{
+			{name: "horizontalpodautoscalers", kind: "HorizontalPodAutoscaler", namespaced: true, removed: 26},
+		}},
+		{version: "v2beta1", resources: []builtinResource{
+			{name: "horizontalpodautoscalers", kind: "HorizontalPodAutoscaler", namespaced: true, removed: 25},
+		}},
+	}},
+	{name: "batch", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "cronjobs", kind: "CronJob", namespaced: true, added: 21},
+			{name: "jobs", kind: "Job", namespaced: true},
+		}},
+		{version: "v1beta1", resources: []builtinResource{
+			{name: "cronjobs", kind: "CronJob", namespaced: true, removed: 25},
+		}},
+	}},
+	{name: "certificates.k8s.io", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "certificatesigningrequests", kind: "CertificateSigningRequest"},
+		}},
+	}},
+	{name: "coordination.k8s.io", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "leases", kind: "Lease", namespaced: true},
+		}},
+	}},
+	{name: "discovery.k8s.io", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "endpointslices", kind: "EndpointSlice", namespaced: true, added: 21},
+		}},
+		{version: "v1beta1", resources: []builtinResource{
+			{name: "endpointslices", kind: "EndpointSlice", namespaced: true, removed: 25},
+		}},
+	}},
+	{name: "extensions", versions: []builtinVersion{
+		{version: "v1beta1", resources: []builtinResource{
+			{name: "ingresses", kind: "Ingress", namespaced: true, removed: 22},
+		}},
+	}},
+	{name: "flowcontrol.apiserver.k8s.io", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "flowschemas", kind: "FlowSchema", added: 29},
+			{name: "prioritylevelconfigurations", kind: "PriorityLevelConfiguration", added: 29},
+		}},
+		{version: "v1beta3", resources: []builtinResource{
+			{name: "flowschemas", kind: "FlowSchema", added: 26, removed: 32},
+			{name: "prioritylevelconfigurations", kind: "PriorityLevelConfiguration", added: 26, removed: 32},
+		}},
+		{version: "v1beta2", resources: []builtinResource{
+			{name: "flowschemas", kind: "FlowSchema", added: 23, removed: 29},
+			{name: "prioritylevelconfigurations", kind: "PriorityLevelConfiguration", added: 23, removed: 29},
+		}},
+		{version: "v1beta1", resources: []builtinResource{
+			{name: "flowschemas", kind: "FlowSchema", added: 20, removed: 26},
+			{name: "prioritylevelconfigurations", kind: "PriorityLevelConfiguration", added: 20, removed: 26},
+		}},
+	}},
+	{name: "networking.k8s.io", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "ingressclasses", kind: "IngressClass"},
+			{name: "ingresses", kind: "Ingress", namespaced: true},
+			{name: "networkpolicies", kind: "NetworkPolicy", namespaced: true},
+		}},
+		{version: "v1beta1", resources: []builtinResource{
+			{name: "ingressclasses", kind: "IngressClass", removed: 22},
+			{name: "ingresses", kind: "Ingress", namespaced: true, removed: 22},
+		}},
+	}},
+	{name: "node.k8s.io", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "runtimeclasses", kind: "RuntimeClass", added: 20},
+		}},
+		{version: "v1beta1", resources: []builtinResource{
+			{name: "runtimeclasses", kind: "RuntimeClass", removed: 25},
+		}},
+	}},
+	{name: "policy", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "poddisruptionbudgets", kind: "PodDisruptionBudget", namespaced: true, added: 21},
+		}},
+		{version: "v1beta1", resources: []builtinResource{
+			{name: "poddisruptionbudgets", kind: "PodDisruptionBudget", namespaced: true, removed: 25},
+			{name: "podsecuritypolicies", kind: "PodSecurityPolicy", removed: 25},
+		}},
+	}},
+	{name: "rbac.authorization.k8s.io", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "clusterrolebindings", kind: "ClusterRoleBinding"},
+			{name: "clusterroles", kind: "ClusterRole"},
+			{name: "rolebindings", kind: "RoleBinding", namespaced: true},
+			{name: "roles", kind: "Role", namespaced: true},
+		}},
+	}},
+	{name: "scheduling.k8s.io", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "priorityclasses", kind: "PriorityClass"},
+		}},
+	}},
+	{name: "storage.k8s.io", versions: []builtinVersion{
+		{version: "v1", resources: []builtinResource{
+			{name: "csidrivers", kind: "CSIDriver"},
+			{name: "csinodes", kind: "CSINode"},
+			{name: "csistoragecapacities", kind: "CSIStorageCapacity", namespaced: true, added: 24},
+			{name: "storageclasses", kind: "StorageClass"},
+			{name: "volumeattachments", kind: "VolumeAttachment"},
+		}},
+	}},
+}
+
+// ForKubernetesVersion return a Snapshot containing the built-in APIs served by the Kubernetes kubeVersion, in the
+// form 1.30, v1.30 or v1.30.5. Versions newer than LatestKubernetesVersion use its data, while versions older than
+// MinimumKubernetesVersion are not supported.
+func ForKubernetesVersion(kubeVersion string) (*Snapshot, error) {
+	parsedVersion, err := version.ParseGeneric(kubeVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubernetes version %q: %w", kubeVersion, err)
+	}
+
+	minor := int(parsedVersion.Minor())
+	if parsedVersion.Major() != 1 || minor < minimumMinor {
+		return nil, fmt.Errorf("unsupported kubernetes version %q: the minimum supported version is %s", kubeVersion, MinimumKubernetesVersion)
+	}
+
+	snapshot := &Snapshot{
+		TypeMeta:      metav1.TypeMeta{APIVersion: snapshotAPIVersion, Kind: snapshotKind},
+		ServerVersion: fmt.Sprintf("v%d.%d.%d", parsedVersion.Major(), minor, parsedVersion.Patch()),
+		Groups:        make([]Group, 0, len(builtinAPIs)),
+	}
+
+	minor = min(minor, latestMinor)
+	for _, builtinGroup := range builtinAPIs {
+		group := Group{Name: builtinGroup.name, Versions: []Version{}}
+		for _, builtinVersion := range builtinGroup.versions {
+			version := Version{Version: builtinVersion.version, Resources: []Resource{}}
+			for _, res := range builtinVersion.resources {
+				if !res.servedIn(minor) {
+					continue
+				}
+				version.Resources = append(version.Resources, Resource{
+					Name:         res.name,
+					SingularName: strings.ToLower(res.kind),
+					Kind:         res.kind,
+					Namespaced:   res.namespaced,
+				})
+			}
+
+			if len(version.Resources) == 0 {
+				continue
+			}
+
+			if len(group.PreferredVersion) == 0 {
+				group.PreferredVersion = version.Version
+			}
+			group.Versions = append(group.Versions, version)
+		}
+
+		if len(group.Versions) > 0 {
+			snapshot.Groups = append(snapshot.Groups, group)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// servedIn return true if the resource is served by the Kubernetes minor version
+func (r builtinResource) servedIn(minor int) bool {
+	return minor >= r.added && (r.removed == 0 || minor < r.removed)
+}
@@ -0,0 +1,143 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capabilities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestForKubernetesVersion(t *testing.T) {
+	t.Parallel()
+
+	cronJob := schema.GroupKind{Group: "batch", Kind: "CronJob"}
+	hpa := schema.GroupKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}
+	ingress := schema.GroupKind{Group: "networking.k8s.io", Kind: "Ingress"}
+
+	tests := map[string]struct {
+		kubeVersion           string
+		expectedServerVersion string
+		servedVersions        map[schema.GroupKind][]string
+		notServedVersions     map[schema.GroupKind][]string
+		expectedError         string
+	}{
+		"oldest supported version": {
+			kubeVersion:           "1.19",
+			expectedServerVersion: "v1.19.0",
+			servedVersions: map[schema.GroupKind][]string{
+				cronJob: {"v1beta1"},
+				hpa:     {"v1", "v2beta1", "v2beta2"},
+				ingress: {"v1", "v1beta1"},
+			},
+			notServedVersions: map[schema.GroupKind][]string{
+				cronJob: {"v1"},
+				hpa:     {"v2"},
+			},
+		},
+		"version with both cronjob versions": {
+			kubeVersion:           "v1.23.4",
+			expectedServerVersion: "v1.23.4",
+			servedVersions: map[schema.GroupKind][]string{
+				cronJob: {"v1", "v1beta1"},
+				hpa:     {"v2", "v2beta2"},
+			},
+			notServedVersions: map[schema.GroupKind][]string{
+				ingress: {"v1beta1"},
+			},
+		},
+		"recent version": {
+			kubeVersion:           "v1.30",
+			expectedServerVersion: "v1.30.0",
+			servedVersions: map[schema.GroupKind][]string{
+				cronJob: {"v1"},
+				hpa:     {"v1", "v2"},
+			},
+			notServedVersions: map[schema.GroupKind][]string{
+				cronJob: {"v1beta1"},
+				hpa:     {"v2beta1", "v2beta2"},
+			},
+		},
+		"version newer than the bundled data": {
+			kubeVersion:           "1.40.1",
+			expectedServerVersion: "v1.40.1",
+			servedVersions: map[schema.GroupKind][]string{
+				cronJob: {"v1"},
+			},
+		},
+		"too old version": {
+			kubeVersion:   "1.18",
+			expectedError: `unsupported kubernetes version "1.18"`,
+		},
+		"invalid version": {
+			kubeVersion:   "latest",
+			expectedError: `invalid kubernetes version "latest"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			snapshot, err := ForKubernetesVersion(test.kubeVersion)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, snapshotAPIVersion, snapshot.APIVersion)
+			assert.Equal(t, test.expectedServerVersion, snapshot.ServerVersion)
+
+			mapper := snapshot.RESTMapper()
+			for gk, versions := range test.servedVersions {
+				for _, version := range versions {
+					_, err := mapper.RESTMapping(gk, version)
+					assert.NoError(t, err, "%s must be served", gk.WithVersion(version))
+				}
+			}
+			for gk, versions := range test.notServedVersions {
+				for _, version := range versions {
+					_, err := mapper.RESTMapping(gk, version)
+					assert.True(t, meta.IsNoMatchError(err), "%s must not be served", gk.WithVersion(version))
+				}
+			}
+		})
+	}
+}
+
+func TestBuiltinAPIsAreConsistent(t *testing.T) {
+	t.Parallel()
+
+	for _, group := range builtinAPIs {
+		for _, version := range group.versions {
+			for _, res := range version.resources {
+				if res.removed > 0 {
+					assert.Greater(t, res.removed, res.added, "%s %s/%s", res.kind, group.name, version.version)
+				}
+			}
+		}
+	}
+
+	snapshot, err := ForKubernetesVersion(LatestKubernetesVersion)
+	require.NoError(t, err)
+	mapping, err := snapshot.RESTMapper().RESTMapping(schema.GroupKind{Kind: "Namespace"})
+	require.NoError(t, err)
+	assert.Equal(t, meta.RESTScopeNameRoot, mapping.Scope.Name())
+}
@@ -0,0 +1,182 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package capabilities contains the data describing the APIs served by a cluster, that can be saved in a snapshot
+// file and used in place of the discovery APIs when the cluster cannot be contacted
+package capabilities
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	snapshotAPIVersion = "mlp.mia-platform.eu/v1"
+	snapshotKind       = "ClusterSnapshot"
+)
+
+// Snapshot contains the APIs served by a cluster
+type Snapshot struct {
+	metav1.TypeMeta `json:",inline"`
+
+	ServerVersion string  `json:"serverVersion,omitempty"`
+	Groups        []Group `json:"groups"`
+}
+
+// Group contains the versions served for an API group
+type Group struct {
+	Name             string    `json:"name"`
+	PreferredVersion string    `json:"preferredVersion"`
+	Versions         []Version `json:"versions"`
+}
+
+// Version contains the resources served for a version of an API group
+type Version struct {
+	Version   string     `json:"version"`
+	Resources []Resource `json:"resources"`
+}
+
+// Resource contains the data of a single resource
+type Resource struct {
+	Name         string `json:"name"`
+	SingularName string `json:"singularName,omitempty"`
+	Kind         string `json:"kind"`
+	Namespaced   bool   `json:"namespaced"`
+}
+
+// NewSnapshot return a new Snapshot containing the APIs served by the cluster reachable with client
+func NewSnapshot(client discovery.DiscoveryInterface) (*Snapshot, error) {
+	version, err := client.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server version: %w", err)
+	}
+
+	groupResources, err := restmapper.GetAPIGroupResources(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read served APIs: %w", err)
+	}
+
+	snapshot := &Snapshot{
+		TypeMeta:      metav1.TypeMeta{APIVersion: snapshotAPIVersion, Kind: snapshotKind},
+		ServerVersion: version.GitVersion,
+		Groups:        make([]Group, 0, len(groupResources)),
+	}
+
+	for _, groupResource := range groupResources {
+		group := Group{
+			Name:             groupResource.Group.Name,
+			PreferredVersion: groupResource.Group.PreferredVersion.Version,
+			Versions:         make([]Version, 0, len(groupResource.Group.Versions)),
+		}
+
+		for _, groupVersion := range groupResource.Group.Versions {
+			version := Version{Version: groupVersion.Version, Resources: []Resource{}}
+			for _, apiResource := range groupResource.VersionedResources[groupVersion.Version] {
+				// subresources are not needed for mapping kinds to resources
+				if strings.Contains(apiResource.Name, "/") {
+					continue
+				}
+				version.Resources = append(version.Resources, Resource{
+					Name:         apiResource.Name,
+					SingularName: apiResource.SingularName,
+					Kind:         apiResource.Kind,
+					Namespaced:   apiResource.Namespaced,
+				})
+			}
+			group.Versions = append(group.Versions, version)
+		}
+		snapshot.Groups = append(snapshot.Groups, group)
+	}
+
+	// the discovery order is not stable, sort the groups for saving always the same file for the same cluster
+	slices.SortFunc(snapshot.Groups, func(a, b Group) int { return strings.Compare(a.Name, b.Name) })
+	return snapshot, nil
+}
+
+// Load read a Snapshot from the file at path
+func Load(fSys filesys.FileSystem, path string) (*Snapshot, error) {
+	data, err := fSys.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster snapshot: %w", err)
+	}
+
+	snapshot := new(Snapshot)
+	if err := yaml.UnmarshalStrict(data, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster snapshot %q: %w", path, err)
+	}
+
+	if snapshot.APIVersion != snapshotAPIVersion || snapshot.Kind != snapshotKind {
+		return nil, fmt.Errorf("file %q is not a valid cluster snapshot", path)
+	}
+
+	return snapshot, nil
+}
+
+// Save write the Snapshot in the file at path
+func (s *Snapshot) Save(fSys filesys.FileSystem, path string) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return fSys.WriteFile(path, data)
+}
+
+// RESTMapper return a RESTMapper for the resources contained in the Snapshot
+func (s *Snapshot) RESTMapper() meta.RESTMapper {
+	groupResources := make([]*restmapper.APIGroupResources, 0, len(s.Groups))
+	for _, group := range s.Groups {
+		groupResource := &restmapper.APIGroupResources{
+			Group: metav1.APIGroup{
+				Name:     group.Name,
+				Versions: make([]metav1.GroupVersionForDiscovery, 0, len(group.Versions)),
+			},
+			VersionedResources: make(map[string][]metav1.APIResource, len(group.Versions)),
+		}
+
+		for _, version := range group.Versions {
+			groupVersion := metav1.GroupVersionForDiscovery{
+				GroupVersion: metav1.GroupVersion{Group: group.Name, Version: version.Version}.String(),
+				Version:      version.Version,
+			}
+			groupResource.Group.Versions = append(groupResource.Group.Versions, groupVersion)
+			if version.Version == group.PreferredVersion {
+				groupResource.Group.PreferredVersion = groupVersion
+			}
+
+			apiResources := make([]metav1.APIResource, 0, len(version.Resources))
+			for _, res := range version.Resources {
+				apiResources = append(apiResources, metav1.APIResource{
+					Name:         res.Name,
+					SingularName: res.SingularName,
+					Kind:         res.Kind,
+					Namespaced:   res.Namespaced,
+				})
+			}
+			groupResource.VersionedResources[version.Version] = apiResources
+		}
+		groupResources = append(groupResources, groupResource)
+	}
+
+	return restmapper.NewDiscoveryRESTMapper(groupResources)
+}
@@ -0,0 +1,196 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capabilities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestNewSnapshot(t *testing.T) {
+	t.Parallel()
+
+	client := &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "configmaps", SingularName: "configmap", Kind: "ConfigMap", Namespaced: true},
+						{Name: "namespaces", SingularName: "namespace", Kind: "Namespace"},
+						{Name: "namespaces/status", Kind: "Namespace"},
+					},
+				},
+				{
+					GroupVersion: "batch/v1",
+					APIResources: []metav1.APIResource{
+						{Name: "cronjobs", SingularName: "cronjob", Kind: "CronJob", Namespaced: true},
+					},
+				},
+			},
+		},
+		FakedServerVersion: &version.Info{GitVersion: "v1.30.5"},
+	}
+
+	snapshot, err := NewSnapshot(client)
+	require.NoError(t, err)
+
+	expectedSnapshot := &Snapshot{
+		TypeMeta:      metav1.TypeMeta{APIVersion: snapshotAPIVersion, Kind: snapshotKind},
+		ServerVersion: "v1.30.5",
+		Groups: []Group{
+			{
+				Name:             "",
+				PreferredVersion: "v1",
+				Versions: []Version{
+					{
+						Version: "v1",
+						Resources: []Resource{
+							{Name: "configmaps", SingularName: "configmap", Kind: "ConfigMap", Namespaced: true},
+							{Name: "namespaces", SingularName: "namespace", Kind: "Namespace"},
+						},
+					},
+				},
+			},
+			{
+				Name:             "batch",
+				PreferredVersion: "v1",
+				Versions: []Version{
+					{
+						Version: "v1",
+						Resources: []Resource{
+							{Name: "cronjobs", SingularName: "cronjob", Kind: "CronJob", Namespaced: true},
+						},
+					},
+				},
+			},
+		},
+	}
+	assert.Equal(t, expectedSnapshot, snapshot)
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, snapshot.Save(fSys, "snapshot.yaml"))
+	loadedSnapshot, err := Load(fSys, "snapshot.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, expectedSnapshot, loadedSnapshot)
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("wrong-kind.yaml", []byte(`apiVersion: v1
+kind: ConfigMap
+groups: []
+`)))
+	require.NoError(t, fSys.WriteFile("unknown-keys.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v1
+kind: ClusterSnapshot
+unknown: value
+`)))
+
+	tests := map[string]struct {
+		path          string
+		expectedError string
+	}{
+		"missing file": {
+			path:          "missing.yaml",
+			expectedError: "failed to read cluster snapshot",
+		},
+		"wrong kind": {
+			path:          "wrong-kind.yaml",
+			expectedError: `file "wrong-kind.yaml" is not a valid cluster snapshot`,
+		},
+		"unknown keys": {
+			path:          "unknown-keys.yaml",
+			expectedError: `failed to parse cluster snapshot "unknown-keys.yaml"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Load(fSys, test.path)
+			assert.ErrorContains(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestRESTMapper(t *testing.T) {
+	t.Parallel()
+
+	snapshot := &Snapshot{
+		Groups: []Group{
+			{
+				Name:             "",
+				PreferredVersion: "v1",
+				Versions: []Version{
+					{
+						Version: "v1",
+						Resources: []Resource{
+							{Name: "namespaces", SingularName: "namespace", Kind: "Namespace"},
+						},
+					},
+				},
+			},
+			{
+				Name:             "batch",
+				PreferredVersion: "v1",
+				Versions: []Version{
+					{
+						Version: "v1",
+						Resources: []Resource{
+							{Name: "cronjobs", SingularName: "cronjob", Kind: "CronJob", Namespaced: true},
+						},
+					},
+					{
+						Version: "v1beta1",
+						Resources: []Resource{
+							{Name: "cronjobs", SingularName: "cronjob", Kind: "CronJob", Namespaced: true},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	mapper := snapshot.RESTMapper()
+
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: "batch", Kind: "CronJob"})
+	require.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}, mapping.Resource)
+	assert.Equal(t, meta.RESTScopeNameNamespace, mapping.Scope.Name())
+
+	mapping, err = mapper.RESTMapping(schema.GroupKind{Group: "batch", Kind: "CronJob"}, "v1beta1")
+	require.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionResource{Group: "batch", Version: "v1beta1", Resource: "cronjobs"}, mapping.Resource)
+
+	mapping, err = mapper.RESTMapping(schema.GroupKind{Kind: "Namespace"})
+	require.NoError(t, err)
+	assert.Equal(t, meta.RESTScopeNameRoot, mapping.Scope.Name())
+
+	_, err = mapper.RESTMapping(schema.GroupKind{Group: "apps", Kind: "Deployment"})
+	assert.True(t, meta.IsNoMatchError(err))
+}
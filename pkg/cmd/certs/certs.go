@@ -0,0 +1,44 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+const (
+	cmdUsage = "certs"
+	cmdShort = "Inspect the certificates contained in resources"
+	cmdLong  = `Inspect the certificates contained in Secrets and ConfigMaps, reading them
+	from manifest files or from a namespace of the target cluster.
+	`
+)
+
+// NewCommand return the command grouping the operations on certificates
+func NewCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   cmdUsage,
+		Short: heredoc.Doc(cmdShort),
+		Long:  heredoc.Doc(cmdLong),
+
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newCheckCommand(configFlags))
+	return cmd
+}
@@ -0,0 +1,34 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestNewCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewCommand(genericclioptions.NewConfigFlags(false))
+	assert.NotNil(t, cmd)
+
+	checkCmd, _, err := cmd.Find([]string{checkCmdUsage})
+	assert.NoError(t, err)
+	assert.Equal(t, checkCmdUsage, checkCmd.Name())
+}
@@ -0,0 +1,364 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/util"
+	"github.com/mia-platform/mlp/v2/pkg/resourceutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	checkCmdUsage = "check"
+	checkCmdShort = "Report the expiration of certificates and fail if they are expiring"
+	checkCmdLong  = `Report subject, issuer, SANs and days to expiration of every certificate
+	found in Secrets and ConfigMaps, and fail if any of them expires within the
+	configured window.
+
+	The resources are read from the files passed with the --filename flag, or from
+	the namespace of the target cluster if no file is passed.
+	`
+	checkCmdExamples = `# check the certificates in the generated manifests
+	mlp certs check --filename interpolated-files
+
+	# check the certificates in the current namespace, failing if any expires in the next 60 days
+	mlp certs check --namespace production --expiry-days 60
+	`
+
+	inputPathsFlagName  = "filename"
+	inputPathsShortName = "f"
+	inputPathsFlagUsage = "file or folder paths containing the resources to check, if not set the resources are read from the target namespace"
+
+	expiryDaysFlagName     = "expiry-days"
+	expiryDaysDefaultValue = 30
+	expiryDaysFlagUsage    = "fail if a certificate expires in less than this number of days"
+
+	stdinToken = "-"
+)
+
+var yamlExtensions = []string{".yaml", ".yml"}
+
+// CheckFlags contains all the flags for the `certs check` command. They will be converted to CheckOptions
+// that contains all runtime options for the command.
+type CheckFlags struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+	inputPaths  []string
+	expiryDays  int
+}
+
+// CheckOptions have the data required to perform the certs check operation
+type CheckOptions struct {
+	inputPaths []string
+	expiryDays int
+
+	clientFactory util.ClientFactory
+	clock         clock.PassiveClock
+	fSys          filesys.FileSystem
+	reader        io.Reader
+	writer        io.Writer
+}
+
+// certificateReport contains the information about a single certificate found in a resource
+type certificateReport struct {
+	resource string
+	key      string
+	subject  string
+	issuer   string
+	sans     []string
+	notAfter time.Time
+	daysLeft int
+}
+
+// newCheckCommand return the command for checking the expiration of certificates
+func newCheckCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	flags := &CheckFlags{
+		ConfigFlags: configFlags,
+	}
+
+	cmd := &cobra.Command{
+		Use:     checkCmdUsage,
+		Short:   heredoc.Doc(checkCmdShort),
+		Long:    heredoc.Doc(checkCmdLong),
+		Example: heredoc.Doc(checkCmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(cmd.InOrStdin(), cmd.OutOrStdout(), filesys.MakeFsOnDisk())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// AddFlags set the connection between CheckFlags property to command line flags
+func (f *CheckFlags) AddFlags(flags *pflag.FlagSet) {
+	if f.ConfigFlags != nil {
+		f.ConfigFlags.AddFlags(flags)
+	}
+
+	flags.StringSliceVarP(&f.inputPaths, inputPathsFlagName, inputPathsShortName, nil, inputPathsFlagUsage)
+	flags.IntVar(&f.expiryDays, expiryDaysFlagName, expiryDaysDefaultValue, expiryDaysFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *CheckFlags) ToOptions(reader io.Reader, writer io.Writer, fSys filesys.FileSystem) (*CheckOptions, error) {
+	if f.ConfigFlags == nil {
+		return nil, fmt.Errorf("config flags are required")
+	}
+
+	return &CheckOptions{
+		inputPaths:    f.inputPaths,
+		expiryDays:    f.expiryDays,
+		clientFactory: util.NewFactory(f.ConfigFlags),
+		clock:         clock.RealClock{},
+		fSys:          fSys,
+		reader:        reader,
+		writer:        writer,
+	}, nil
+}
+
+// Validate check the options for errors
+func (o *CheckOptions) Validate() error {
+	if len(o.inputPaths) > 1 && slices.Contains(o.inputPaths, stdinToken) {
+		return fmt.Errorf("cannot read from stdin and other paths together")
+	}
+
+	if o.expiryDays < 0 {
+		return fmt.Errorf("the %q flag cannot be negative", expiryDaysFlagName)
+	}
+
+	return nil
+}
+
+// Run execute the certs check command
+func (o *CheckOptions) Run(ctx context.Context) error {
+	secrets, configMaps, err := o.readResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := o.clock.Now()
+	reports := make([]certificateReport, 0)
+	for _, secret := range secrets {
+		data := maps.Clone(secret.Data)
+		if data == nil {
+			data = make(map[string][]byte, len(secret.StringData))
+		}
+		for key, value := range secret.StringData {
+			data[key] = []byte(value)
+		}
+
+		secretReports, err := certificatesReports("Secret/"+secret.Name, data, now)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, secretReports...)
+	}
+
+	for _, configMap := range configMaps {
+		data := make(map[string][]byte, len(configMap.Data))
+		for key, value := range configMap.Data {
+			data[key] = []byte(value)
+		}
+
+		configMapReports, err := certificatesReports("ConfigMap/"+configMap.Name, data, now)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, configMapReports...)
+	}
+
+	if len(reports) == 0 {
+		fmt.Fprintln(o.writer, "no certificates found")
+		return nil
+	}
+
+	o.printReports(reports)
+
+	expiring := 0
+	for _, report := range reports {
+		if report.daysLeft < o.expiryDays {
+			expiring++
+		}
+	}
+	if expiring > 0 {
+		return fmt.Errorf("%d certificates expire in less than %d days", expiring, o.expiryDays)
+	}
+
+	return nil
+}
+
+// readResources return the Secrets and ConfigMaps found in the input paths, or in the target namespace if no
+// path is set
+func (o *CheckOptions) readResources(ctx context.Context) ([]corev1.Secret, []corev1.ConfigMap, error) {
+	if len(o.inputPaths) == 0 {
+		return o.readRemoteResources(ctx)
+	}
+
+	var secrets []corev1.Secret
+	var configMaps []corev1.ConfigMap
+	objects, err := o.readObjects(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, obj := range objects {
+		if obj.GroupVersionKind().Group != corev1.GroupName {
+			continue
+		}
+
+		switch obj.GetKind() {
+		case "Secret":
+			secret := corev1.Secret{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &secret); err != nil {
+				return nil, nil, fmt.Errorf("failed to read secret %q: %w", obj.GetName(), err)
+			}
+			secrets = append(secrets, secret)
+		case "ConfigMap":
+			configMap := corev1.ConfigMap{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &configMap); err != nil {
+				return nil, nil, fmt.Errorf("failed to read configmap %q: %w", obj.GetName(), err)
+			}
+			configMaps = append(configMaps, configMap)
+		}
+	}
+
+	return secrets, configMaps, nil
+}
+
+// readRemoteResources return the Secrets and ConfigMaps found in the target namespace
+func (o *CheckOptions) readRemoteResources(ctx context.Context) ([]corev1.Secret, []corev1.ConfigMap, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	namespace, _, err := o.clientFactory.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientSet, err := o.clientFactory.KubernetesClientSet()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.V(5).Info("reading resources from cluster", "namespace", namespace)
+	secrets, err := clientSet.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	configMaps, err := clientSet.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list configmaps: %w", err)
+	}
+
+	return secrets.Items, configMaps.Items, nil
+}
+
+// readObjects return the resources whose certificates are checked, read from stdin or from the input paths
+func (o *CheckOptions) readObjects(ctx context.Context) ([]*unstructured.Unstructured, error) {
+	if o.inputPaths[0] == stdinToken {
+		return resourceutil.Decode(o.reader)
+	}
+
+	return resourceutil.DecodePaths(ctx, o.fSys, o.inputPaths, yamlExtensions)
+}
+
+// certificatesReports return a report for every certificate found in the values of data
+func certificatesReports(resource string, data map[string][]byte, now time.Time) ([]certificateReport, error) {
+	reports := make([]certificateReport, 0)
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		rest := data[key]
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate in %s key %q: %w", resource, key, err)
+			}
+
+			reports = append(reports, certificateReport{
+				resource: resource,
+				key:      key,
+				subject:  certificate.Subject.String(),
+				issuer:   certificate.Issuer.String(),
+				sans:     subjectAlternativeNames(certificate),
+				notAfter: certificate.NotAfter,
+				daysLeft: int(math.Floor(certificate.NotAfter.Sub(now).Hours() / 24)),
+			})
+		}
+	}
+
+	return reports, nil
+}
+
+// subjectAlternativeNames return all the alternative names contained in certificate
+func subjectAlternativeNames(certificate *x509.Certificate) []string {
+	sans := make([]string, 0, len(certificate.DNSNames)+len(certificate.IPAddresses)+len(certificate.EmailAddresses)+len(certificate.URIs))
+	sans = append(sans, certificate.DNSNames...)
+	for _, ip := range certificate.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, certificate.EmailAddresses...)
+	for _, uri := range certificate.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+// printReports write a table with a row for every report
+func (o *CheckOptions) printReports(reports []certificateReport) {
+	tabWriter := tabwriter.NewWriter(o.writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, "RESOURCE\tKEY\tSUBJECT\tISSUER\tSANS\tNOT AFTER\tDAYS LEFT")
+	for _, report := range reports {
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", report.resource, report.key, report.subject,
+			report.issuer, strings.Join(report.sans, ","), report.notAfter.UTC().Format(time.RFC3339), report.daysLeft)
+	}
+	_ = tabWriter.Flush()
+}
@@ -0,0 +1,239 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/mia-platform/jpl/pkg/util"
+	"github.com/mia-platform/mlp/v2/pkg/resourceutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	restfake "k8s.io/client-go/rest/fake"
+	"k8s.io/utils/clock"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	expectedReport = `RESOURCE              KEY      SUBJECT                  ISSUER                   SANS                                   NOT AFTER             DAYS LEFT
+Secret/example-tls    tls.crt  CN=example.com           CN=Example CA,O=Example  example.com,www.example.com,127.0.0.1  2025-01-15T00:00:00Z  14
+ConfigMap/example-ca  ca.crt   CN=Example CA,O=Example  CN=Example CA,O=Example                                         2025-01-20T00:00:00Z  19
+`
+	expectedSecretReport = `RESOURCE            KEY      SUBJECT         ISSUER                   SANS                                   NOT AFTER             DAYS LEFT
+Secret/example-tls  tls.crt  CN=example.com  CN=Example CA,O=Example  example.com,www.example.com,127.0.0.1  2025-01-15T00:00:00Z  14
+`
+)
+
+func TestCheckOptions(t *testing.T) {
+	t.Parallel()
+
+	reader := new(bytes.Buffer)
+	writer := new(bytes.Buffer)
+	fSys := filesys.MakeFsInMemory()
+	configFlags := genericclioptions.NewConfigFlags(false)
+
+	flags := &CheckFlags{
+		inputPaths: []string{"input"},
+		expiryDays: 10,
+	}
+	_, err := flags.ToOptions(reader, writer, fSys)
+	assert.ErrorContains(t, err, "config flags are required")
+
+	flags.ConfigFlags = configFlags
+	opts, err := flags.ToOptions(reader, writer, fSys)
+	require.NoError(t, err)
+	assert.Equal(t, &CheckOptions{
+		inputPaths:    []string{"input"},
+		expiryDays:    10,
+		clientFactory: util.NewFactory(configFlags),
+		clock:         clock.RealClock{},
+		fSys:          fSys,
+		reader:        reader,
+		writer:        writer,
+	}, opts)
+	assert.NoError(t, opts.Validate())
+
+	opts.inputPaths = []string{"input", stdinToken}
+	assert.ErrorContains(t, opts.Validate(), "cannot read from stdin and other paths together")
+	opts.inputPaths = nil
+	assert.NoError(t, opts.Validate())
+
+	opts.expiryDays = -1
+	assert.ErrorContains(t, opts.Validate(), `the "expiry-days" flag cannot be negative`)
+}
+
+func TestCheckRunFromFiles(t *testing.T) {
+	t.Parallel()
+
+	testdata := "testdata"
+	fakeClock := clocktesting.NewFakePassiveClock(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC))
+	stdinData, err := os.ReadFile(filepath.Join(testdata, "resources", "tls.secret.yaml"))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		inputPaths     []string
+		expiryDays     int
+		expectedOutput string
+		expectedError  string
+	}{
+		"all certificates are valid": {
+			inputPaths:     []string{filepath.Join(testdata, "resources")},
+			expiryDays:     10,
+			expectedOutput: expectedReport,
+		},
+		"certificates expiring in the window": {
+			inputPaths:     []string{filepath.Join(testdata, "resources")},
+			expiryDays:     15,
+			expectedOutput: expectedReport,
+			expectedError:  "1 certificates expire in less than 15 days",
+		},
+		"read from stdin": {
+			inputPaths:     []string{stdinToken},
+			expiryDays:     30,
+			expectedOutput: expectedSecretReport,
+			expectedError:  "1 certificates expire in less than 30 days",
+		},
+		"no certificates found": {
+			inputPaths:     []string{filepath.Join(testdata, "no-certificates")},
+			expiryDays:     30,
+			expectedOutput: "no certificates found\n",
+		},
+		"missing path": {
+			inputPaths:    []string{filepath.Join(testdata, "missing")},
+			expectedError: "no such file or directory",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			writer := new(strings.Builder)
+			opts := &CheckOptions{
+				inputPaths: test.inputPaths,
+				expiryDays: test.expiryDays,
+				clock:      fakeClock,
+				fSys:       filesys.MakeFsOnDisk(),
+				reader:     bytes.NewReader(stdinData),
+				writer:     writer,
+			}
+
+			err := opts.Run(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+			if len(test.expectedOutput) > 0 {
+				assert.Equal(t, test.expectedOutput, writer.String())
+			}
+		})
+	}
+}
+
+func TestCheckRunFromCluster(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-certs-test"
+	codec := jpltesting.Codecs.LegacyCodec(jpltesting.Scheme.PrioritizedVersionsAllGroups()...)
+	secretData, err := os.ReadFile(filepath.Join("testdata", "resources", "tls.secret.yaml"))
+	require.NoError(t, err)
+	objects, err := resourceutil.Decode(bytes.NewReader(secretData))
+	require.NoError(t, err)
+	secret := &corev1.Secret{}
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(objects[0].Object, secret))
+
+	tests := map[string]struct {
+		secretsStatus  int
+		expectedOutput string
+		expectedError  string
+	}{
+		"read certificates from namespace": {
+			secretsStatus:  http.StatusOK,
+			expectedOutput: expectedSecretReport,
+		},
+		"error listing secrets": {
+			secretsStatus: http.StatusForbidden,
+			expectedError: "failed to list secrets",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tf := jpltesting.NewTestClientFactory().
+				WithNamespace(namespace)
+			tf.Client = &restfake.RESTClient{
+				NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+				Client: restfake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+					var list runtime.Object
+					status := http.StatusOK
+					switch r.URL.Path {
+					case fmt.Sprintf("/api/v1/namespaces/%s/secrets", namespace):
+						list = &corev1.SecretList{Items: []corev1.Secret{*secret}}
+						status = test.secretsStatus
+					case fmt.Sprintf("/api/v1/namespaces/%s/configmaps", namespace):
+						list = &corev1.ConfigMapList{ListMeta: metav1.ListMeta{}}
+					default:
+						return nil, fmt.Errorf("unexpected call: %q, method %s", r.URL.Path, r.Method)
+					}
+
+					body := []byte(runtime.EncodeOrDie(codec, list))
+					return &http.Response{
+						StatusCode: status,
+						Header:     jpltesting.DefaultHeaders(),
+						Body:       io.NopCloser(bytes.NewReader(body)),
+					}, nil
+				}),
+			}
+
+			writer := new(strings.Builder)
+			opts := &CheckOptions{
+				expiryDays:    10,
+				clientFactory: tf,
+				clock:         clocktesting.NewFakePassiveClock(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)),
+				writer:        writer,
+			}
+
+			err := opts.Run(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedOutput, writer.String())
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
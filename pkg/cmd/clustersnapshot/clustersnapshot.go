@@ -0,0 +1,133 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clustersnapshot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/capabilities"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	cmdUsage = "cluster-snapshot"
+	cmdShort = "Save the APIs served by a cluster for using them offline"
+	cmdLong  = `Save the APIs served by a cluster for using them offline.
+
+	The command will read the API groups, versions and resources served by the
+	target cluster and will save them in a file that can be used by the deploy
+	command with the --offline flag, for validating and rendering the resources
+	without contacting the cluster.
+	`
+	cmdExamples = `# save the snapshot of the current cluster in the default file
+	mlp cluster-snapshot
+
+	# save the snapshot of the current cluster in a custom file
+	mlp cluster-snapshot --out snapshots/production.yaml
+	`
+
+	outputFlagName     = "out"
+	outputFlagShort    = "o"
+	outputDefaultValue = "cluster-snapshot.yaml"
+	outputFlagUsage    = "file path where the snapshot will be saved"
+)
+
+// Flags contains all the flags for the `cluster-snapshot` command. They will be converted to Options
+// that contains all runtime options for the command.
+type Flags struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+	outputPath  string
+}
+
+// Options have the data required to perform the cluster-snapshot operation
+type Options struct {
+	outputPath      string
+	discoveryClient discovery.DiscoveryInterface
+	fSys            filesys.FileSystem
+}
+
+// NewCommand return the command for saving the APIs served by the target cluster
+func NewCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	flags := &Flags{
+		ConfigFlags: configFlags,
+	}
+
+	cmd := &cobra.Command{
+		Use:     cmdUsage,
+		Short:   heredoc.Doc(cmdShort),
+		Long:    heredoc.Doc(cmdLong),
+		Example: heredoc.Doc(cmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(filesys.MakeFsOnDisk())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// AddFlags set the connection between Flags property to command line flags
+func (f *Flags) AddFlags(flags *pflag.FlagSet) {
+	if f.ConfigFlags != nil {
+		f.ConfigFlags.AddFlags(flags)
+	}
+
+	flags.StringVarP(&f.outputPath, outputFlagName, outputFlagShort, outputDefaultValue, outputFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *Flags) ToOptions(fSys filesys.FileSystem) (*Options, error) {
+	if f.ConfigFlags == nil {
+		return nil, fmt.Errorf("config flags are required")
+	}
+
+	discoveryClient, err := f.ConfigFlags.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Options{
+		outputPath:      f.outputPath,
+		discoveryClient: discoveryClient,
+		fSys:            fSys,
+	}, nil
+}
+
+// Run execute the cluster-snapshot command
+func (o *Options) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	logger.V(5).Info("reading served APIs")
+	snapshot, err := capabilities.NewSnapshot(o.discoveryClient)
+	if err != nil {
+		return err
+	}
+
+	logger.V(5).Info("saving cluster snapshot", "path", o.outputPath, "groups", len(snapshot.Groups))
+	return snapshot.Save(o.fSys, o.outputPath)
+}
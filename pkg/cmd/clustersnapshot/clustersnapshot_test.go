@@ -0,0 +1,96 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clustersnapshot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestNewCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewCommand(genericclioptions.NewConfigFlags(false))
+	assert.NotNil(t, cmd)
+	assert.NotNil(t, cmd.Flags().Lookup(outputFlagName))
+}
+
+func TestToOptions(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	flags := &Flags{outputPath: "snapshot.yaml"}
+	_, err := flags.ToOptions(fSys)
+	assert.ErrorContains(t, err, "config flags are required")
+
+	flags.ConfigFlags = genericclioptions.NewConfigFlags(false)
+	opts, err := flags.ToOptions(fSys)
+	require.NoError(t, err)
+	assert.Equal(t, "snapshot.yaml", opts.outputPath)
+	assert.NotNil(t, opts.discoveryClient)
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	opts := &Options{
+		outputPath: "snapshot.yaml",
+		fSys:       fSys,
+		discoveryClient: &fakediscovery.FakeDiscovery{
+			Fake: &clienttesting.Fake{
+				Resources: []*metav1.APIResourceList{
+					{
+						GroupVersion: "v1",
+						APIResources: []metav1.APIResource{
+							{Name: "configmaps", SingularName: "configmap", Kind: "ConfigMap", Namespaced: true},
+						},
+					},
+				},
+			},
+			FakedServerVersion: &version.Info{GitVersion: "v1.30.5"},
+		},
+	}
+
+	require.NoError(t, opts.Run(context.TODO()))
+
+	expectedSnapshot := `apiVersion: mlp.mia-platform.eu/v1
+groups:
+- name: ""
+  preferredVersion: v1
+  versions:
+  - resources:
+    - kind: ConfigMap
+      name: configmaps
+      namespaced: true
+      singularName: configmap
+    version: v1
+kind: ClusterSnapshot
+serverVersion: v1.30.5
+`
+	data, err := fSys.ReadFile("snapshot.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, expectedSnapshot, string(data))
+}
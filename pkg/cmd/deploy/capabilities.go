@@ -0,0 +1,103 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// compatibleVersions contains for every GroupKind the list of versions that share the same schema, sorted from the
+// most preferred to the least one
+var compatibleVersions = map[schema.GroupKind][]string{
+	{Group: "batch", Kind: "CronJob"}:                       {"v1", "v1beta1"},
+	{Group: "policy", Kind: "PodDisruptionBudget"}:          {"v1", "v1beta1"},
+	{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}: {"v2", "v2beta2"},
+}
+
+// versionConversion contains the data of a change of apiVersion done on a resource
+type versionConversion struct {
+	kind      string
+	name      string
+	namespace string
+	from      string
+	to        string
+}
+
+// String implement fmt.Stringer interface
+func (c versionConversion) String() string {
+	name := c.name
+	if len(c.namespace) > 0 {
+		name = c.namespace + "/" + c.name
+	}
+	return fmt.Sprintf("%s %s converted from %s to %s", c.kind, name, c.from, c.to)
+}
+
+// convertToSupportedVersions change the apiVersion of the resources with a compatible alternative to the most
+// preferred version that is available in the cluster, resources that have no supported version are left untouched
+func convertToSupportedVersions(mapper meta.RESTMapper, resources []*unstructured.Unstructured) ([]versionConversion, error) {
+	conversions := make([]versionConversion, 0)
+	for _, res := range resources {
+		gvk := res.GroupVersionKind()
+		versions, found := compatibleVersions[gvk.GroupKind()]
+		if !found || !slices.Contains(versions, gvk.Version) {
+			continue
+		}
+
+		version, err := preferredSupportedVersion(mapper, gvk.GroupKind(), versions)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(version) == 0 || version == gvk.Version {
+			continue
+		}
+
+		targetGVK := gvk.GroupKind().WithVersion(version)
+		res.SetAPIVersion(targetGVK.GroupVersion().String())
+		conversions = append(conversions, versionConversion{
+			kind:      gvk.Kind,
+			name:      res.GetName(),
+			namespace: res.GetNamespace(),
+			from:      gvk.GroupVersion().String(),
+			to:        targetGVK.GroupVersion().String(),
+		})
+	}
+
+	return conversions, nil
+}
+
+// preferredSupportedVersion return the first version in versions that is available in the cluster for gk, or an
+// empty string if none is found
+func preferredSupportedVersion(mapper meta.RESTMapper, gk schema.GroupKind, versions []string) (string, error) {
+	for _, version := range versions {
+		_, err := mapper.RESTMapping(gk, version)
+		switch {
+		case meta.IsNoMatchError(err):
+			continue
+		case err != nil:
+			return "", fmt.Errorf("failed to check support for %s: %w", gk.WithVersion(version), err)
+		}
+
+		return version, nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,116 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestConvertToSupportedVersions(t *testing.T) {
+	t.Parallel()
+
+	newResource := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		return obj
+	}
+
+	newMapper := func(gvks ...schema.GroupVersionKind) meta.RESTMapper {
+		mapper := meta.NewDefaultRESTMapper(nil)
+		for _, gvk := range gvks {
+			mapper.Add(gvk, meta.RESTScopeNamespace)
+		}
+		return mapper
+	}
+
+	cronJobV1 := schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"}
+	cronJobV1beta1 := schema.GroupVersionKind{Group: "batch", Version: "v1beta1", Kind: "CronJob"}
+	pdbV1beta1 := schema.GroupVersionKind{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"}
+	hpaV2 := schema.GroupVersionKind{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"}
+	configMap := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	tests := map[string]struct {
+		mapper              meta.RESTMapper
+		resources           []*unstructured.Unstructured
+		expectedAPIVersions []string
+		expectedConversions []string
+	}{
+		"upgrade to the preferred version": {
+			mapper: newMapper(cronJobV1, cronJobV1beta1, hpaV2),
+			resources: []*unstructured.Unstructured{
+				newResource("batch/v1beta1", "CronJob", "cronjob"),
+				newResource("autoscaling/v2beta2", "HorizontalPodAutoscaler", "hpa"),
+				newResource("v1", "ConfigMap", "configmap"),
+			},
+			expectedAPIVersions: []string{"batch/v1", "autoscaling/v2", "v1"},
+			expectedConversions: []string{
+				"CronJob default/cronjob converted from batch/v1beta1 to batch/v1",
+				"HorizontalPodAutoscaler default/hpa converted from autoscaling/v2beta2 to autoscaling/v2",
+			},
+		},
+		"downgrade on old clusters": {
+			mapper: newMapper(cronJobV1beta1, pdbV1beta1),
+			resources: []*unstructured.Unstructured{
+				newResource("batch/v1", "CronJob", "cronjob"),
+				newResource("policy/v1", "PodDisruptionBudget", "pdb"),
+			},
+			expectedAPIVersions: []string{"batch/v1beta1", "policy/v1beta1"},
+			expectedConversions: []string{
+				"CronJob default/cronjob converted from batch/v1 to batch/v1beta1",
+				"PodDisruptionBudget default/pdb converted from policy/v1 to policy/v1beta1",
+			},
+		},
+		"unsupported resources are left untouched": {
+			mapper: newMapper(configMap),
+			resources: []*unstructured.Unstructured{
+				newResource("batch/v1", "CronJob", "cronjob"),
+				newResource("autoscaling/v2beta1", "HorizontalPodAutoscaler", "hpa"),
+			},
+			expectedAPIVersions: []string{"batch/v1", "autoscaling/v2beta1"},
+			expectedConversions: []string{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			conversions, err := convertToSupportedVersions(test.mapper, test.resources)
+			require.NoError(t, err)
+
+			apiVersions := make([]string, 0, len(test.resources))
+			for _, res := range test.resources {
+				apiVersions = append(apiVersions, res.GetAPIVersion())
+			}
+			assert.Equal(t, test.expectedAPIVersions, apiVersions)
+
+			messages := make([]string, 0, len(conversions))
+			for _, conversion := range conversions {
+				messages = append(messages, conversion.String())
+			}
+			assert.Equal(t, test.expectedConversions, messages)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	expectedServer         = "server"
+	expectedUID            = "uid"
+	expectedNamespaceLabel = "namespace-label"
+
+	// clusterIdentityNamespace is the namespace whose uid identify a cluster, it exists in every cluster and it
+	// is never recreated
+	clusterIdentityNamespace = "kube-system"
+)
+
+var validClusterExpectations = []string{expectedServer, expectedUID, expectedNamespaceLabel}
+
+// clusterExpectation is a property that the target cluster must have for allowing the deploy
+type clusterExpectation struct {
+	kind  string
+	value string
+	// label is the key of the label that the target namespace must have, set only for the namespace-label kind
+	label string
+}
+
+// String implement fmt.Stringer interface
+func (e clusterExpectation) String() string {
+	if e.kind == expectedNamespaceLabel {
+		return fmt.Sprintf("%s %s=%s", e.kind, e.label, e.value)
+	}
+	return fmt.Sprintf("%s %s", e.kind, e.value)
+}
+
+// parseClusterExpectations parse the values of the expected-cluster flag, in the kind=value format
+func parseClusterExpectations(values []string) ([]clusterExpectation, error) {
+	expectations := make([]clusterExpectation, 0, len(values))
+	for _, value := range values {
+		kind, expected, found := strings.Cut(value, "=")
+		if !found || len(expected) == 0 {
+			return nil, fmt.Errorf("invalid expected cluster %q: must be in the kind=value format, where kind is one of: %s", value, strings.Join(validClusterExpectations, ", "))
+		}
+
+		expectation := clusterExpectation{kind: kind, value: expected}
+		switch kind {
+		case expectedServer:
+			if _, err := path.Match(expected, ""); err != nil {
+				return nil, fmt.Errorf("invalid expected cluster %q: %w", value, err)
+			}
+		case expectedUID:
+		case expectedNamespaceLabel:
+			label, labelValue, found := strings.Cut(expected, "=")
+			if errs := validation.IsQualifiedName(label); !found || len(errs) > 0 {
+				return nil, fmt.Errorf("invalid expected cluster %q: the namespace label must be in the key=value format", value)
+			}
+			expectation.label = label
+			expectation.value = labelValue
+		default:
+			return nil, fmt.Errorf("invalid expected cluster %q: kind must be one of: %s", value, strings.Join(validClusterExpectations, ", "))
+		}
+		expectations = append(expectations, expectation)
+	}
+
+	return expectations, nil
+}
+
+// verifyCluster check that the cluster pointed by the kubeconfig has all the expected properties before
+// changing anything in it, so a wrong context cannot receive the resources of another environment
+func (o *Options) verifyCluster(ctx context.Context, namespace string) error {
+	if len(o.expectedCluster) == 0 {
+		return nil
+	}
+
+	expectations, err := parseClusterExpectations(o.expectedCluster)
+	if err != nil {
+		return err
+	}
+
+	config, err := o.clientFactory.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	clientSet, err := o.clientFactory.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+
+	logr.FromContextOrDiscard(ctx).V(3).Info("verifying cluster identity", "server", config.Host)
+	return verifyClusterIdentity(ctx, clientSet, config.Host, namespace, expectations)
+}
+
+// verifyClusterIdentity return an error for the first expectation not satisfied by the cluster served at host,
+// namespace is the target namespace of the deploy
+func verifyClusterIdentity(ctx context.Context, clientSet kubernetes.Interface, host, namespace string, expectations []clusterExpectation) error {
+	for _, expectation := range expectations {
+		var found string
+		switch expectation.kind {
+		case expectedServer:
+			found = host
+			if match, _ := path.Match(expectation.value, host); match {
+				continue
+			}
+		case expectedUID:
+			identity, err := clientSet.CoreV1().Namespaces().Get(ctx, clusterIdentityNamespace, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to read the cluster uid: %w", err)
+			}
+			found = string(identity.UID)
+			if found == expectation.value {
+				continue
+			}
+		case expectedNamespaceLabel:
+			target, err := clientSet.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+			switch {
+			case apierrors.IsNotFound(err):
+				return fmt.Errorf("refusing to deploy: cannot verify the expected %s, namespace %q not found", expectation, namespace)
+			case err != nil:
+				return fmt.Errorf("failed to read namespace %q: %w", namespace, err)
+			}
+			labelValue, hasLabel := target.Labels[expectation.label]
+			found = fmt.Sprintf("%s=%s", expectation.label, labelValue)
+			if !hasLabel {
+				found = "no label " + expectation.label
+			}
+			if hasLabel && labelValue == expectation.value {
+				continue
+			}
+		}
+
+		return fmt.Errorf("refusing to deploy: the cluster does not match the expected %s, found %q", expectation, found)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseClusterExpectations(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		values               []string
+		expectedExpectations []clusterExpectation
+		expectedError        string
+	}{
+		"no values": {
+			expectedExpectations: []clusterExpectation{},
+		},
+		"all kinds": {
+			values: []string{"server=https://*.prod.example.com:6443", "uid=1234", "namespace-label=environment=production"},
+			expectedExpectations: []clusterExpectation{
+				{kind: expectedServer, value: "https://*.prod.example.com:6443"},
+				{kind: expectedUID, value: "1234"},
+				{kind: expectedNamespaceLabel, label: "environment", value: "production"},
+			},
+		},
+		"missing value": {
+			values:        []string{"uid="},
+			expectedError: `invalid expected cluster "uid=": must be in the kind=value format`,
+		},
+		"unknown kind": {
+			values:        []string{"context=prod"},
+			expectedError: `invalid expected cluster "context=prod": kind must be one of: server, uid, namespace-label`,
+		},
+		"invalid server pattern": {
+			values:        []string{"server=https://[prod"},
+			expectedError: `invalid expected cluster "server=https://[prod": syntax error in pattern`,
+		},
+		"invalid namespace label": {
+			values:        []string{"namespace-label=environment"},
+			expectedError: `invalid expected cluster "namespace-label=environment": the namespace label must be in the key=value format`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			expectations, err := parseClusterExpectations(test.values)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedExpectations, expectations)
+		})
+	}
+}
+
+func TestVerifyClusterIdentity(t *testing.T) {
+	t.Parallel()
+
+	host := "https://api.prod.example.com:6443"
+	tests := map[string]struct {
+		namespace     string
+		values        []string
+		expectedError string
+	}{
+		"all expectations satisfied": {
+			namespace: "production",
+			values:    []string{"server=https://*.prod.example.com:6443", "uid=prod-uid", "namespace-label=environment=production"},
+		},
+		"wrong server": {
+			namespace:     "production",
+			values:        []string{"server=https://*.staging.example.com:6443"},
+			expectedError: `refusing to deploy: the cluster does not match the expected server https://*.staging.example.com:6443, found "https://api.prod.example.com:6443"`,
+		},
+		"wrong uid": {
+			namespace:     "production",
+			values:        []string{"uid=staging-uid"},
+			expectedError: `refusing to deploy: the cluster does not match the expected uid staging-uid, found "prod-uid"`,
+		},
+		"wrong namespace label": {
+			namespace:     "production",
+			values:        []string{"namespace-label=environment=staging"},
+			expectedError: `refusing to deploy: the cluster does not match the expected namespace-label environment=staging, found "environment=production"`,
+		},
+		"namespace without label": {
+			namespace:     "unlabeled",
+			values:        []string{"namespace-label=environment=production"},
+			expectedError: `found "no label environment"`,
+		},
+		"missing namespace": {
+			namespace:     "missing",
+			values:        []string{"namespace-label=environment=production"},
+			expectedError: `refusing to deploy: cannot verify the expected namespace-label environment=production, namespace "missing" not found`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			clientSet := fake.NewSimpleClientset(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "prod-uid"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "production", Labels: map[string]string{"environment": "production"}}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unlabeled"}},
+			)
+
+			expectations, err := parseClusterExpectations(test.values)
+			require.NoError(t, err)
+
+			err = verifyClusterIdentity(context.TODO(), clientSet, host, test.namespace, expectations)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -35,64 +35,146 @@ import (
 const (
 	oldInventoryName = "resources-deployed"
 	oldInventoryKey  = "resources"
+
+	// InventoryBackendConfigMap save the inventory in a ConfigMap
+	InventoryBackendConfigMap = "configmap"
+	// InventoryBackendCRD save the inventory in a DeployInventory custom resource
+	InventoryBackendCRD = "crd"
 )
 
+// ValidInventoryBackends are the backends that can be used for saving the inventory
+var ValidInventoryBackends = []string{InventoryBackendConfigMap, InventoryBackendCRD}
+
 // Inventory wrap
 type Inventory struct {
 	delegate  inventory.Store
 	namespace string
 
+	// migrateFrom is the store of the other backend, its objects are loaded when the delegate is empty and it is
+	// deleted after they have been saved in the delegate
+	migrateFrom inventory.Store
+	migrating   bool
+
 	compatibilityMode bool
+	trackedObjects    sets.Set[resource.ObjectMetadata]
+	loadedObjects     sets.Set[resource.ObjectMetadata]
+	selectedObjects   sets.Set[resource.ObjectMetadata]
+	sharedObjects     sets.Set[resource.ObjectMetadata]
+	retainedObjects   sets.Set[resource.ObjectMetadata]
 
 	clientset kubernetes.Interface
 	mapper    meta.RESTMapper
 }
 
-func NewInventory(factory util.ClientFactory, name, namespace, filedManager string) (inventory.Store, error) {
-	cmInventory, err := inventory.NewConfigMapStore(factory, name, namespace, filedManager)
+// NewInventory return the inventory name in namespace saved with backend, the inventory saved by the other
+// backend is migrated to it on the first save
+func NewInventory(factory util.ClientFactory, name, namespace, filedManager, backend string) (*Inventory, error) {
+	clientset, err := factory.KubernetesClientSet()
 	if err != nil {
 		return nil, err
 	}
 
-	clientset, err := factory.KubernetesClientSet()
+	mapper, err := factory.ToRESTMapper()
 	if err != nil {
 		return nil, err
 	}
 
-	mapper, err := factory.ToRESTMapper()
+	client, err := factory.DynamicClient()
 	if err != nil {
 		return nil, err
 	}
 
+	configMapStore := newConfigMapStore(clientset, name, namespace, filedManager)
+	crdStore := newCustomResourceStore(client, mapper, name, namespace, filedManager)
+	delegate, migrateFrom := configMapStore, crdStore
+	if backend == InventoryBackendCRD {
+		delegate, migrateFrom = crdStore, configMapStore
+	}
+
 	return &Inventory{
-		delegate:  cmInventory,
-		namespace: namespace,
+		delegate:    delegate,
+		namespace:   namespace,
+		migrateFrom: migrateFrom,
 
 		compatibilityMode: true,
+		trackedObjects:    make(sets.Set[resource.ObjectMetadata]),
 
 		clientset: clientset,
 		mapper:    mapper,
 	}, nil
 }
 
+// TrackObjects add objects to the ones loaded from the inventory, so they will be pruned if they are not
+// present in the resources to apply
+func (s *Inventory) TrackObjects(objects ...resource.ObjectMetadata) {
+	s.trackedObjects.Insert(objects...)
+}
+
+// SelectObjects limit the inventory to objects, all the other objects loaded from the remote storage will not be
+// pruned and will be kept in the inventory when saved
+func (s *Inventory) SelectObjects(objects ...resource.ObjectMetadata) {
+	s.selectedObjects = sets.New(objects...)
+}
+
+// ShareObjects mark objects as shared with other tools, they are removed from the inventory so they will never be
+// pruned, even after their manifests have been deleted
+func (s *Inventory) ShareObjects(objects ...resource.ObjectMetadata) {
+	s.sharedObjects = sets.New(objects...)
+}
+
+// RetainedObjects return the objects loaded from the remote storage that have been excluded by SelectObjects
+func (s *Inventory) RetainedObjects() sets.Set[resource.ObjectMetadata] {
+	return s.retainedObjects
+}
+
 func (s *Inventory) Load(ctx context.Context) (sets.Set[resource.ObjectMetadata], error) {
 	objs, err := s.delegate.Load(ctx)
+	if err == nil && len(objs) == 0 {
+		objs, err = s.migratedObjects(ctx)
+	}
+
 	if err != nil || len(objs) > 0 {
 		s.compatibilityMode = false
 	}
 
 	if s.compatibilityMode {
-		return s.oldInventoryObjects(ctx)
+		objs, err = s.oldInventoryObjects(ctx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	objs = objs.Union(s.trackedObjects)
+	if s.sharedObjects != nil {
+		objs = objs.Difference(s.sharedObjects)
+	}
+
+	if s.selectedObjects != nil {
+		s.retainedObjects = objs.Difference(s.selectedObjects)
+		objs = objs.Intersection(s.selectedObjects)
 	}
 
-	return objs, err
+	s.loadedObjects = objs
+	return objs, nil
 }
 
 func (s *Inventory) Save(ctx context.Context, dryRun bool) error {
-	if err := s.delegate.Save(ctx, dryRun); err != nil || !s.compatibilityMode {
+	if err := s.delegate.Save(ctx, dryRun); err != nil {
 		return err
 	}
 
+	if s.migrating && !dryRun {
+		if err := s.migrateFrom.Delete(ctx, false); err != nil {
+			return err
+		}
+		s.migrating = false
+	}
+
+	if !s.compatibilityMode {
+		return nil
+	}
+
 	return s.deleteOldInventory(ctx, dryRun)
 }
 
@@ -101,9 +183,70 @@ func (s *Inventory) Delete(ctx context.Context, dryRun bool) error {
 }
 
 func (s *Inventory) SetObjects(objects sets.Set[*unstructured.Unstructured]) {
+	if len(s.sharedObjects) > 0 {
+		notShared := make(sets.Set[*unstructured.Unstructured], len(objects))
+		for obj := range objects {
+			if !s.sharedObjects.Has(resource.ObjectMetadataFromUnstructured(obj)) {
+				notShared.Insert(obj)
+			}
+		}
+		objects = notShared
+	}
+
+	if len(s.retainedObjects) == 0 {
+		s.delegate.SetObjects(objects)
+		return
+	}
+
+	objects = objects.Clone()
+	for objMeta := range s.retainedObjects {
+		objects.Insert(unstructuredFromMetadata(objMeta))
+	}
 	s.delegate.SetObjects(objects)
 }
 
+// SaveInterrupted save the inventory of a deploy stopped before the applier could save it, keeping the objects
+// loaded at the start that have not been pruned and adding the ones that have been applied
+func (s *Inventory) SaveInterrupted(ctx context.Context, applied, pruned sets.Set[resource.ObjectMetadata], dryRun bool) error {
+	if s.loadedObjects == nil {
+		// the inventory has never been loaded, so nothing has been applied or pruned
+		return nil
+	}
+
+	objects := make(sets.Set[*unstructured.Unstructured])
+	for objMeta := range s.loadedObjects.Difference(pruned).Union(applied) {
+		objects.Insert(unstructuredFromMetadata(objMeta))
+	}
+
+	s.SetObjects(objects)
+	return s.Save(ctx, dryRun)
+}
+
+// unstructuredFromMetadata return an object identified by objMeta, the inventory only saves group, kind, name and
+// namespace so a placeholder version is used
+func unstructuredFromMetadata(objMeta resource.ObjectMetadata) *unstructured.Unstructured {
+	obj := new(unstructured.Unstructured)
+	obj.SetGroupVersionKind(schema.GroupVersionKind{Group: objMeta.Group, Version: "v1", Kind: objMeta.Kind})
+	obj.SetName(objMeta.Name)
+	obj.SetNamespace(objMeta.Namespace)
+	return obj
+}
+
+// migratedObjects return the objects saved by the other inventory backend, an inventory that cannot be read
+// because the DeployInventory CustomResourceDefinition is missing or not accessible is considered empty
+func (s *Inventory) migratedObjects(ctx context.Context) (sets.Set[resource.ObjectMetadata], error) {
+	objs, err := s.migrateFrom.Load(ctx)
+	switch {
+	case apierrors.IsNotFound(err), apierrors.IsForbidden(err), meta.IsNoMatchError(err):
+		return make(sets.Set[resource.ObjectMetadata]), nil
+	case err != nil:
+		return nil, err
+	}
+
+	s.migrating = len(objs) > 0
+	return objs, nil
+}
+
 func (s *Inventory) oldInventoryObjects(ctx context.Context) (sets.Set[resource.ObjectMetadata], error) {
 	metadataSet := make(sets.Set[resource.ObjectMetadata], 0)
 	sec, err := s.clientset.CoreV1().Secrets(s.namespace).Get(ctx, oldInventoryName, metav1.GetOptions{})
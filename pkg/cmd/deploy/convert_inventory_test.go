@@ -22,7 +22,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"path"
 	"reflect"
+	"slices"
 	"testing"
 
 	jplresource "github.com/mia-platform/jpl/pkg/resource"
@@ -31,10 +33,15 @@ import (
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/sets"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	restfake "k8s.io/client-go/rest/fake"
+	clienttesting "k8s.io/client-go/testing"
 )
 
 func TestLoadInventory(t *testing.T) { //nolint: gocyclo
@@ -253,7 +260,7 @@ func TestLoadInventory(t *testing.T) { //nolint: gocyclo
 				Client: test.client,
 			}
 
-			inv, err := NewInventory(factory, inventoryName, namespace, "mlp")
+			inv, err := NewInventory(factory, inventoryName, namespace, "mlp", InventoryBackendConfigMap)
 			require.NoError(t, err)
 
 			set, err := inv.Load(context.TODO())
@@ -339,11 +346,9 @@ func TestSavingInventory(t *testing.T) {
 				Client: test.client,
 			}
 
-			inv, err := NewInventory(factory, inventoryName, namespace, "mlp")
-			castedInventory, ok := inv.(*Inventory)
-			require.True(t, ok)
-			castedInventory.compatibilityMode = test.compatibilityMode
+			inv, err := NewInventory(factory, inventoryName, namespace, "mlp", InventoryBackendConfigMap)
 			require.NoError(t, err)
+			inv.compatibilityMode = test.compatibilityMode
 
 			err = inv.Save(context.TODO(), test.dryRun)
 			if len(test.expectedError) > 0 {
@@ -355,3 +360,106 @@ func TestSavingInventory(t *testing.T) {
 		})
 	}
 }
+
+func TestInventoryMigration(t *testing.T) {
+	t.Parallel()
+
+	namespace := "test-inventory"
+	configMapPath := fmt.Sprintf("/api/v1/namespaces/%s/configmaps/%s", namespace, inventoryName)
+	codec := jpltesting.Codecs.LegacyCodec(jpltesting.Scheme.PrioritizedVersionsAllGroups()...)
+	deployResource := jplresource.ObjectMetadata{Kind: "Deployment", Group: "apps", Name: "example", Namespace: namespace}
+
+	inventoryConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: inventoryName, Namespace: namespace, ResourceVersion: "1"},
+		Data:       map[string]string{deployResource.ToString(): ""},
+	}
+
+	tests := map[string]struct {
+		backend                      string
+		remoteConfigMap              *corev1.ConfigMap
+		remoteCustomResource         *unstructured.Unstructured
+		dryRun                       bool
+		expectedConfigMapDelete      bool
+		expectedCustomResourceDelete bool
+	}{
+		"migrate from configmap to custom resource": {
+			backend:                 InventoryBackendCRD,
+			remoteConfigMap:         inventoryConfigMap,
+			expectedConfigMapDelete: true,
+		},
+		"migrate from custom resource to configmap": {
+			backend:                      InventoryBackendConfigMap,
+			remoteCustomResource:         deployInventoryObject(namespace, "1", "mlp", deployResource),
+			expectedCustomResourceDelete: true,
+		},
+		"don't delete the migrated inventory in dry run": {
+			backend:         InventoryBackendCRD,
+			remoteConfigMap: inventoryConfigMap,
+			dryRun:          true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			configMapDeleted := false
+			factory := jpltesting.NewTestClientFactory()
+			factory.Client = &restfake.RESTClient{
+				Client: restfake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+					if r.URL.Path == fmt.Sprintf("/api/v1/namespaces/%s/secrets/%s", namespace, oldInventoryName) {
+						return &http.Response{StatusCode: http.StatusNotFound, Header: jpltesting.DefaultHeaders()}, nil
+					}
+					if r.URL.Path != configMapPath && r.URL.Path != path.Dir(configMapPath) {
+						return nil, fmt.Errorf("unexpected call: %q, method %s", r.URL.Path, r.Method)
+					}
+
+					switch {
+					case r.Method == http.MethodGet && test.remoteConfigMap != nil:
+						body := io.NopCloser(bytes.NewReader([]byte(runtime.EncodeOrDie(codec, test.remoteConfigMap))))
+						return &http.Response{StatusCode: http.StatusOK, Body: body, Header: jpltesting.DefaultHeaders()}, nil
+					case r.Method == http.MethodGet:
+						return &http.Response{StatusCode: http.StatusNotFound, Header: jpltesting.DefaultHeaders()}, nil
+					case r.Method == http.MethodPost:
+						body := io.NopCloser(bytes.NewReader([]byte(runtime.EncodeOrDie(codec, inventoryConfigMap))))
+						return &http.Response{StatusCode: http.StatusCreated, Body: body, Header: jpltesting.DefaultHeaders()}, nil
+					case r.Method == http.MethodPatch:
+						return &http.Response{StatusCode: http.StatusOK, Body: r.Body, Header: jpltesting.DefaultHeaders()}, nil
+					case r.Method == http.MethodDelete:
+						configMapDeleted = true
+						return &http.Response{StatusCode: http.StatusOK, Header: jpltesting.DefaultHeaders()}, nil
+					}
+					return nil, fmt.Errorf("unexpected call: %q, method %s", r.URL.Path, r.Method)
+				}),
+			}
+
+			objects := make([]runtime.Object, 0)
+			if test.remoteCustomResource != nil {
+				objects = append(objects, test.remoteCustomResource)
+			}
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+				deployInventoriesGVR: deployInventoryKind + "List",
+			}, objects...)
+			factory.FakeDynamicClient = client
+			mapper, err := factory.ToRESTMapper()
+			require.NoError(t, err)
+			factory.RESTMapper = meta.MultiRESTMapper{mapper, deployInventoryRESTMapper()}
+
+			inv, err := NewInventory(factory, inventoryName, namespace, "mlp", test.backend)
+			require.NoError(t, err)
+
+			loaded, err := inv.Load(context.TODO())
+			require.NoError(t, err)
+			assert.Equal(t, sets.New(deployResource), loaded)
+
+			inv.SetObjects(sets.New(unstructuredFromMetadata(deployResource)))
+			require.NoError(t, inv.Save(context.TODO(), test.dryRun))
+
+			assert.Equal(t, test.expectedConfigMapDelete, configMapDeleted)
+			customResourceDeleted := slices.ContainsFunc(client.Actions(), func(action clienttesting.Action) bool {
+				return action.GetVerb() == "delete"
+			})
+			assert.Equal(t, test.expectedCustomResourceDelete, customResourceDeleted)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/util"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	cliresource "k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	deleteBeforeApplyAnnotation = "mia-platform.eu/delete-before-apply"
+	deleteBeforeApplyValue      = "true"
+)
+
+// deleteBeforeApplyTransport delete the objects that have opted in via annotation and wait for their removal before
+// sending their apply request through next, so that they are always recreated from scratch
+type deleteBeforeApplyTransport struct {
+	next     http.RoundTripper
+	timeout  time.Duration
+	interval time.Duration
+}
+
+func (t *deleteBeforeApplyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPatch || req.Header.Get("Content-Type") != string(types.ApplyPatchType) || req.GetBody == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	body, annotations, err := requestAnnotations(req)
+	if err != nil || annotations[deleteBeforeApplyAnnotation] != deleteBeforeApplyValue {
+		return t.next.RoundTrip(req)
+	}
+
+	logr.FromContextOrDiscard(req.Context()).V(3).Info("deleting resource before apply", "path", req.URL.Path)
+	deleteQuery := url.Values{}
+	if dryRun, found := req.URL.Query()["dryRun"]; found {
+		deleteQuery["dryRun"] = dryRun
+	}
+
+	deleteReq, err := newRequestFrom(req, http.MethodDelete, req.URL.Path, deleteQuery.Encode(), "application/json", []byte(`{"propagationPolicy":"Foreground"}`))
+	if err != nil {
+		return nil, err
+	}
+	deleteResponse, err := t.next.RoundTrip(deleteReq)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, deleteResponse.Body)
+	deleteResponse.Body.Close()
+
+	switch {
+	case deleteResponse.StatusCode == http.StatusNotFound:
+		// the object doesn't exist yet, there is nothing to delete
+		return t.next.RoundTrip(req)
+	case deleteResponse.StatusCode >= http.StatusBadRequest:
+		return nil, fmt.Errorf("deleting resource %s before apply: unexpected response %s", req.URL.Path, deleteResponse.Status)
+	}
+
+	if len(deleteQuery) > 0 {
+		return dryRunRecreateResponse(req, body), nil
+	}
+
+	if err := waitDeletion(req, t.next, t.timeout, t.interval); err != nil {
+		return nil, fmt.Errorf("waiting deletion of resource %s before apply: %w", req.URL.Path, err)
+	}
+
+	applyReq, err := newRequestFrom(req, req.Method, req.URL.Path, req.URL.RawQuery, req.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(applyReq)
+}
+
+// deleteBeforeApplyFactory wrap a ClientFactory for deleting the annotated objects before applying them with the
+// clients it returns
+type deleteBeforeApplyFactory struct {
+	util.ClientFactory
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// newDeleteBeforeApplyFactory return a ClientFactory that delete and recreate the objects with the delete before
+// apply annotation
+func newDeleteBeforeApplyFactory(factory util.ClientFactory) util.ClientFactory {
+	return &deleteBeforeApplyFactory{
+		ClientFactory: factory,
+		timeout:       defaultRecreateTimeout,
+		interval:      defaultRecreateInterval,
+	}
+}
+
+// UnstructuredClientForMapping override the ClientFactory method wrapping the transport of the returned client
+func (f *deleteBeforeApplyFactory) UnstructuredClientForMapping(mapping *meta.RESTMapping) (cliresource.RESTClient, error) {
+	client, err := f.ClientFactory.UnstructuredClientForMapping(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	restClient, ok := client.(*rest.RESTClient)
+	if !ok || restClient.Client == nil {
+		return client, nil
+	}
+
+	httpClient := *restClient.Client
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = &deleteBeforeApplyTransport{next: next, timeout: f.timeout, interval: f.interval}
+	restClient.Client = &httpClient
+	return restClient, nil
+}
@@ -0,0 +1,163 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDeleteBeforeApplyFactory(t *testing.T) {
+	t.Parallel()
+
+	objectPath := "/apis/batch/v1/namespaces/test/jobs/example"
+	deleteBody := `{"propagationPolicy":"Foreground"}`
+
+	tests := map[string]struct {
+		deleteBeforeApply bool
+		exists            bool
+		dryRun            bool
+		deleteStatus      int
+		expectedRequests  []recordedRequest
+		expectedError     string
+	}{
+		"delete and recreate existing object": {
+			deleteBeforeApply: true,
+			exists:            true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodDelete, path: objectPath, contentType: "application/json", body: deleteBody},
+				{method: http.MethodGet, path: objectPath, contentType: "application/json"},
+				{method: http.MethodPatch, path: objectPath, query: "fieldManager=mlp&force=true", contentType: string(types.ApplyPatchType)},
+			},
+		},
+		"apply missing object": {
+			deleteBeforeApply: true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodDelete, path: objectPath, contentType: "application/json", body: deleteBody},
+				{method: http.MethodPatch, path: objectPath, query: "fieldManager=mlp&force=true", contentType: string(types.ApplyPatchType)},
+			},
+		},
+		"simulate recreation during dry run": {
+			deleteBeforeApply: true,
+			exists:            true,
+			dryRun:            true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodDelete, path: objectPath, query: "dryRun=All", contentType: "application/json", body: deleteBody},
+			},
+		},
+		"without annotation apply directly": {
+			exists: true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: objectPath, query: "fieldManager=mlp&force=true", contentType: string(types.ApplyPatchType)},
+			},
+		},
+		"failed deletion stop the apply": {
+			deleteBeforeApply: true,
+			exists:            true,
+			deleteStatus:      http.StatusForbidden,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodDelete, path: objectPath, contentType: "application/json", body: deleteBody},
+			},
+			expectedError: "deleting resource /apis/batch/v1/namespaces/test/jobs/example before apply: unexpected response 403 Forbidden",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			body := `{"apiVersion":"batch/v1","kind":"Job","metadata":{"name":"example","namespace":"test"}}`
+			if test.deleteBeforeApply {
+				body = `{"apiVersion":"batch/v1","kind":"Job","metadata":{"name":"example","namespace":"test","annotations":{"mia-platform.eu/delete-before-apply":"true"}}}`
+			}
+
+			lock := sync.Mutex{}
+			exists := test.exists
+			requests := make([]recordedRequest, 0)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				lock.Lock()
+				defer lock.Unlock()
+				request := recordedRequest{
+					method:      r.Method,
+					path:        r.URL.Path,
+					query:       r.URL.RawQuery,
+					contentType: r.Header.Get("Content-Type"),
+				}
+				if r.Method == http.MethodDelete {
+					request.body = string(data)
+				}
+				requests = append(requests, request)
+
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.Method == http.MethodDelete && test.deleteStatus > 0:
+					w.WriteHeader(test.deleteStatus)
+					_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Forbidden","code":403}`))
+				case r.Method != http.MethodPatch && !exists:
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+				case r.Method == http.MethodDelete:
+					exists = r.URL.Query().Get("dryRun") != ""
+					w.WriteHeader(http.StatusOK)
+					_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Success"}`))
+				default:
+					w.WriteHeader(http.StatusOK)
+					_, _ = w.Write([]byte(body))
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			factory := newDeleteBeforeApplyFactory(&restClientFactory{host: server.URL})
+			factory.(*deleteBeforeApplyFactory).interval = time.Millisecond
+			client, err := factory.UnstructuredClientForMapping(&meta.RESTMapping{
+				GroupVersionKind: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+			})
+			require.NoError(t, err)
+
+			request := client.Patch(types.ApplyPatchType).
+				Namespace("test").
+				Resource("jobs").
+				Name("example").
+				Param("fieldManager", "mlp").
+				Param("force", "true")
+			if test.dryRun {
+				request = request.Param("dryRun", "All")
+			}
+			err = request.Body([]byte(body)).Do(context.TODO()).Error()
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+
+			assert.Equal(t, test.expectedRequests, requests)
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/poller"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// checkDependsOn verify that the resources referenced in the depends-on annotations that are not part of resources
+// exist and, unless in dry run, wait for them to be ready using checkers, returning an error naming the unavailable
+// ones and the resources that depend on them
+func (o *Options) checkDependsOn(ctx context.Context, resources []*unstructured.Unstructured, checkers poller.CustomStatusCheckers) error {
+	logger := logr.FromContextOrDiscard(ctx)
+	references, err := externalDependsOnReferences(resources)
+	if err != nil || len(references) == 0 {
+		return err
+	}
+
+	mapper, err := o.clientFactory.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+
+	client, err := o.clientFactory.DynamicClient()
+	if err != nil {
+		return err
+	}
+
+	logger.V(3).Info("checking depends-on references", "count", len(references))
+	problems := make(map[extensions.DependsOnReference]string)
+	objects := make(map[resource.ObjectMetadata]extensions.DependsOnReference)
+	toWatch := make([]*unstructured.Unstructured, 0, len(references))
+	for _, reference := range slices.SortedFunc(maps.Keys(references), compareDependsOnReferences) {
+		obj, problem, err := dependsOnObject(ctx, client, mapper, reference)
+		if err != nil {
+			return err
+		}
+
+		if len(problem) > 0 {
+			problems[reference] = problem
+			continue
+		}
+
+		objects[resource.ObjectMetadataFromUnstructured(obj)] = reference
+		toWatch = append(toWatch, obj)
+	}
+
+	if len(problems) == 0 && len(toWatch) > 0 && !o.dryRun {
+		logger.V(3).Info("waiting depends-on references", "count", len(toWatch), "timeout", o.dependsOnTimeout)
+		notReady, err := waitDependsOn(ctx, poller.NewDefaultStatusPoller(client, mapper, checkers), toWatch, o.dependsOnTimeout)
+		if err != nil {
+			return err
+		}
+
+		for objMeta, message := range notReady {
+			problems[objects[objMeta]] = message
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	builder := new(strings.Builder)
+	builder.WriteString(fmt.Sprintf("%d resource(s) referenced in the %s annotations are not available:\n", len(problems), extensions.DependsOnAnnotation))
+	for _, reference := range slices.SortedFunc(maps.Keys(problems), compareDependsOnReferences) {
+		builder.WriteString(fmt.Sprintf("\t- %s %s, required by %s\n", reference, problems[reference], strings.Join(references[reference], ", ")))
+	}
+	return errors.New(builder.String())
+}
+
+// externalDependsOnReferences return the references found in the depends-on annotations of resources that don't
+// match any of them, with the identifiers of the resources that depend on them
+func externalDependsOnReferences(resources []*unstructured.Unstructured) (map[extensions.DependsOnReference][]string, error) {
+	references := make(map[extensions.DependsOnReference][]string)
+	for _, obj := range resources {
+		objReferences, err := extensions.DependsOnReferences(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, reference := range objReferences {
+			if slices.ContainsFunc(resources, reference.Matches) {
+				continue
+			}
+
+			identifier := summaryIdentifier(resource.ObjectMetadataFromUnstructured(obj))
+			if !slices.Contains(references[reference], identifier) {
+				references[reference] = append(references[reference], identifier)
+			}
+		}
+	}
+
+	return references, nil
+}
+
+// dependsOnObject return the object identified by reference, or a description of why it cannot be found
+func dependsOnObject(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper, reference extensions.DependsOnReference) (*unstructured.Unstructured, string, error) {
+	mapping, problem, err := dependsOnMapping(mapper, reference)
+	if err != nil || len(problem) > 0 {
+		return nil, problem, err
+	}
+
+	var resourceClient dynamic.ResourceInterface = client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		resourceClient = client.Resource(mapping.Resource).Namespace(reference.Namespace)
+	}
+
+	obj, err := resourceClient.Get(ctx, reference.Name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil, "does not exist", nil
+	case err != nil:
+		return nil, "", fmt.Errorf("failed to read %s: %w", reference, err)
+	}
+
+	return obj, "", nil
+}
+
+// dependsOnMapping return the mapping for the kind of reference, looking for it in all the groups if the reference
+// doesn't specify one
+func dependsOnMapping(mapper meta.RESTMapper, reference extensions.DependsOnReference) (*meta.RESTMapping, string, error) {
+	groupKind := schema.GroupKind{Group: reference.Group, Kind: reference.Kind}
+	if len(reference.Group) == 0 {
+		gvks, err := mapper.KindsFor(schema.GroupVersionResource{Resource: strings.ToLower(reference.Kind)})
+		if err != nil && !meta.IsNoMatchError(err) {
+			return nil, "", fmt.Errorf("failed to resolve the kind of %s: %w", reference, err)
+		}
+
+		groups := make([]string, 0)
+		for _, gvk := range gvks {
+			if gvk.Kind == reference.Kind && !slices.Contains(groups, gvk.Group) {
+				groups = append(groups, gvk.Group)
+			}
+		}
+
+		switch len(groups) {
+		case 0:
+			return nil, "is not a known resource type", nil
+		case 1:
+			groupKind.Group = groups[0]
+		default:
+			slices.Sort(groups)
+			return nil, fmt.Sprintf("is ambiguous, use one of the groups %s", strings.Join(groups, ", ")), nil
+		}
+	}
+
+	mapping, err := mapper.RESTMapping(groupKind)
+	switch {
+	case meta.IsNoMatchError(err):
+		return nil, "is not a known resource type", nil
+	case err != nil:
+		return nil, "", fmt.Errorf("failed to resolve the kind of %s: %w", reference, err)
+	}
+
+	return mapping, "", nil
+}
+
+// waitDependsOn wait with statusPoller until objects are ready or timeout is elapsed, returning the message of the
+// objects that are not ready or have failed
+func waitDependsOn(ctx context.Context, statusPoller poller.StatusPoller, objects []*unstructured.Unstructured, timeout time.Duration) (map[resource.ObjectMetadata]string, error) {
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	notReady := make(map[resource.ObjectMetadata]string, len(objects))
+	for _, obj := range objects {
+		notReady[resource.ObjectMetadataFromUnstructured(obj)] = "is not ready: timed out waiting for its status"
+	}
+
+	failed := make(map[resource.ObjectMetadata]string)
+	eventCh := statusPoller.Start(pollCtx, objects)
+	for len(notReady) > 0 {
+		select {
+		case <-pollCtx.Done():
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			maps.Copy(notReady, failed)
+			return notReady, nil
+		case e, open := <-eventCh:
+			if !open {
+				maps.Copy(notReady, failed)
+				return notReady, nil
+			}
+
+			switch e.Type {
+			case event.TypeError:
+				return nil, e.ErrorInfo.Error
+			case event.TypeStatusUpdate:
+				info := e.StatusUpdateInfo
+				if _, waiting := notReady[info.ObjectMetadata]; !waiting {
+					continue
+				}
+
+				switch info.Status {
+				case event.StatusSuccessful:
+					delete(notReady, info.ObjectMetadata)
+				case event.StatusFailed:
+					delete(notReady, info.ObjectMetadata)
+					failed[info.ObjectMetadata] = fmt.Sprintf("has failed: %s", info.Message)
+				default:
+					notReady[info.ObjectMetadata] = fmt.Sprintf("is not ready: %s", cmp.Or(info.Message, "waiting for its status"))
+				}
+			}
+		}
+	}
+
+	return failed, nil
+}
+
+// compareDependsOnReferences sort the references by namespace, kind, group and name
+func compareDependsOnReferences(a, b extensions.DependsOnReference) int {
+	return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Group, b.Group), cmp.Compare(a.Name, b.Name))
+}
@@ -0,0 +1,156 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestCheckDependsOn(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-deploy-test"
+	readyDatabase := testDependsOnResource("example.com/v1", "Database", "ready", namespace, map[string]interface{}{"type": "Ready", "status": "True"})
+	pendingDatabase := testDependsOnResource("example.com/v1", "Database", "pending", namespace, map[string]interface{}{
+		"type":    "Ready",
+		"status":  "False",
+		"message": "waiting for the primary instance",
+	})
+	stalledDatabase := testDependsOnResource("example.com/v1", "Database", "stalled", namespace, map[string]interface{}{
+		"type":    "Stalled",
+		"status":  "True",
+		"message": "storage class not found",
+	})
+	cache := testDependsOnResource("example.com/v1", "Cache", "cache", namespace)
+
+	tests := map[string]struct {
+		resources     []*unstructured.Unstructured
+		dryRun        bool
+		expectedError string
+	}{
+		"no annotations": {
+			resources: []*unstructured.Unstructured{testDependsOnResource("apps/v1", "Deployment", "app", namespace)},
+		},
+		"dependency deployed together": {
+			resources: []*unstructured.Unstructured{
+				testDependsOnWorkload(namespace, "Database/missing"),
+				testDependsOnResource("example.com/v1", "Database", "missing", namespace),
+			},
+		},
+		"ready dependencies": {
+			resources: []*unstructured.Unstructured{
+				testDependsOnWorkload(namespace, "Database/ready, Cache.example.com/cache"),
+			},
+		},
+		"unavailable dependencies": {
+			resources: []*unstructured.Unstructured{
+				testDependsOnWorkload(namespace, "Database/missing, Cache/cache, Queue/jobs, Database/ready"),
+			},
+			expectedError: `3 resource(s) referenced in the mia-platform.eu/depends-on annotations are not available:` + "\n" +
+				"\t" + `- Cache/cache is ambiguous, use one of the groups example.com, other.io, required by Deployment.apps/app` + "\n" +
+				"\t" + `- Database/missing does not exist, required by Deployment.apps/app` + "\n" +
+				"\t" + `- Queue/jobs is not a known resource type, required by Deployment.apps/app` + "\n",
+		},
+		"not ready dependencies": {
+			resources: []*unstructured.Unstructured{
+				testDependsOnWorkload(namespace, "Database/pending, Database/stalled, Database/ready"),
+			},
+			expectedError: `2 resource(s) referenced in the mia-platform.eu/depends-on annotations are not available:` + "\n" +
+				"\t" + `- Database/pending is not ready: waiting for the primary instance, required by Deployment.apps/app` + "\n" +
+				"\t" + `- Database/stalled has failed: storage class not found, required by Deployment.apps/app` + "\n",
+		},
+		"dry run only check the existence": {
+			dryRun: true,
+			resources: []*unstructured.Unstructured{
+				testDependsOnWorkload(namespace, "Database/pending"),
+			},
+		},
+		"malformed reference": {
+			resources: []*unstructured.Unstructured{
+				testDependsOnWorkload(namespace, "database"),
+			},
+			expectedError: `invalid reference "database" in mia-platform.eu/depends-on annotation of Deployment "app"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{
+				{Group: "apps", Version: "v1"},
+				{Group: "example.com", Version: "v1"},
+				{Group: "other.io", Version: "v1"},
+			})
+			mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+			mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Database"}, meta.RESTScopeNamespace)
+			mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Cache"}, meta.RESTScopeNamespace)
+			mapper.Add(schema.GroupVersionKind{Group: "other.io", Version: "v1", Kind: "Cache"}, meta.RESTScopeNamespace)
+
+			tf := jpltesting.NewTestClientFactory().WithNamespace(namespace)
+			tf.RESTMapper = mapper
+			tf.FakeDynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+				runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{
+					{Group: "example.com", Version: "v1", Resource: "databases"}: "DatabaseList",
+					{Group: "example.com", Version: "v1", Resource: "caches"}:    "CacheList",
+				},
+				readyDatabase, pendingDatabase, stalledDatabase, cache,
+			)
+			options := &Options{
+				clientFactory:    tf,
+				dryRun:           test.dryRun,
+				dependsOnTimeout: 200 * time.Millisecond,
+			}
+
+			err := options.checkDependsOn(context.TODO(), test.resources, nil)
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func testDependsOnResource(apiVersion, kind, name, namespace string, conditions ...interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+	}}
+	if len(conditions) > 0 {
+		obj.Object["status"] = map[string]interface{}{"conditions": conditions}
+	}
+	return obj
+}
+
+func testDependsOnWorkload(namespace, dependsOn string) *unstructured.Unstructured {
+	obj := testDependsOnResource("apps/v1", "Deployment", "app", namespace)
+	obj.SetAnnotations(map[string]string{"mia-platform.eu/depends-on": dependsOn})
+	return obj
+}
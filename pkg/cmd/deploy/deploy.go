@@ -16,10 +16,18 @@
 package deploy
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"time"
@@ -27,19 +35,35 @@ import (
 	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/go-logr/logr"
 	"github.com/mia-platform/jpl/pkg/client"
+	"github.com/mia-platform/jpl/pkg/filter"
 	"github.com/mia-platform/jpl/pkg/flowcontrol"
-	"github.com/mia-platform/jpl/pkg/generator"
+	"github.com/mia-platform/jpl/pkg/mutator"
+	"github.com/mia-platform/jpl/pkg/poller"
+	"github.com/mia-platform/jpl/pkg/resource"
 	"github.com/mia-platform/jpl/pkg/resourcereader"
 	"github.com/mia-platform/jpl/pkg/util"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/generate"
+	"github.com/mia-platform/mlp/v2/pkg/config"
 	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/mia-platform/mlp/v2/pkg/history"
+	"github.com/mia-platform/mlp/v2/pkg/ignore"
+	"github.com/mia-platform/mlp/v2/pkg/resourceutil"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	apicorev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	corev1 "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/utils/clock"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/kio"
 )
 
 const (
@@ -51,12 +75,23 @@ const (
 	capabilities, like keeping track of deployed resources for removing them
 	when not present anymore, forcing deployment rollout when no changes
 	to the manifest are present and generating annotations for mounted files.
+
+	By default the resources are applied with server-side apply; single resources
+	can select a different patch strategy, a full replacement or their recreation
+	with the mia-platform.eu/patch-strategy, mia-platform.eu/recreate-immutable
+	and mia-platform.eu/delete-before-apply annotations.
 	`
 
 	inputPathsFlagName  = "filename"
 	inputPathsShortName = "f"
 	inputPathsFlagUsage = "the files and/or folders that contain the configurations to apply. Use '-' for reading from stdin"
 
+	generateConfigFlagName  = "generate-config"
+	generateConfigFlagUsage = "config file or folder of the generate command whose ConfigMaps and Secrets are generated in memory and deployed with the other resources, without saving them on disk; can be repeated"
+
+	generatePrefixesFlagName  = "generate-env-prefix"
+	generatePrefixesFlagUsage = "prefixes to add when looking for ENV variables interpolated in the generate config files"
+
 	deployTypeFlagName     = "deploy-type"
 	deployTypeDefaultValue = extensions.DeployAll
 	deployTypeFlagUsage    = "set the deployment mode (accepted values: deploy_all, smart_deploy)"
@@ -73,16 +108,214 @@ const (
 	dryRunDefaultValue = false
 	dryRunFlagUsage    = "if true the resources will be sent to the cluster but not persisted"
 
+	dryRunOutputFlagName  = "dry-run-output"
+	dryRunOutputFlagUsage = "path to a directory where the objects returned by the cluster during the dry run are saved, one file for every resource"
+
+	waitNamespaceTerminationFlagName     = "wait-namespace-termination"
+	waitNamespaceTerminationDefaultValue = false
+	waitNamespaceTerminationFlagUsage    = "if true and the target namespace is terminating, wait for its deletion before recreating it instead of failing"
+
+	restartDependentsFlagName     = "restart-dependents"
+	restartDependentsDefaultValue = false
+	restartDependentsFlagUsage    = "if true restart the workloads already in the cluster that use the ConfigMaps and Secrets changed by the deploy, when they are not part of it"
+
+	injectStandardEnvFlagName     = "inject-standard-env"
+	injectStandardEnvDefaultValue = false
+	injectStandardEnvFlagUsage    = "if true add to every container the NAMESPACE, POD_NAME, POD_IP and NODE_NAME env variables and the ones set in the project configuration, when not already defined"
+
+	serveEventsFlagName  = "serve-events"
+	serveEventsFlagUsage = "local address, like :8099, where the deploy events are streamed as Server-Sent Events while the deploy runs"
+
+	pruneFlagName     = "prune"
+	pruneDefaultValue = true
+	pruneFlagUsage    = "if false the resources removed from the manifests are not deleted and are kept in the inventory, the ones that would have been pruned are listed at the end of the deploy"
+
+	pruneWaitTimeoutFlagName     = "prune-wait-timeout"
+	pruneWaitTimeoutDefaultValue = 2 * time.Minute
+	pruneWaitTimeoutFlagUsage    = "the maximum time to wait for the removal of pruned resources before deleting the ones they can depend on, set to 0 to disable the wait"
+
+	waitForPruneFlagName     = "wait-for-prune"
+	waitForPruneDefaultValue = false
+	waitForPruneFlagUsage    = "if true wait at the end of the deploy until all the pruned resources are deleted, failing if some of them are still terminating after the prune wait timeout"
+
+	healthCheckTimeoutFlagName     = "health-check-timeout"
+	healthCheckTimeoutDefaultValue = 5 * time.Minute
+	healthCheckTimeoutFlagUsage    = "the maximum time to wait for the health path of the resources with the await completion annotation to respond 200"
+
+	dependsOnTimeoutFlagName     = "depends-on-timeout"
+	dependsOnTimeoutDefaultValue = 5 * time.Minute
+	dependsOnTimeoutFlagUsage    = "the maximum time to wait for the resources referenced in the depends-on annotations that are not part of the deploy to become ready"
+
+	waitTimeoutFlagName     = "wait-timeout"
+	waitTimeoutDefaultValue = 0
+	waitTimeoutFlagUsage    = "the maximum time to wait for every applied resource to become ready, 0 for waiting without limits; the " + waitTimeoutAnnotation + " annotation overrides it for a single resource"
+
+	namespaceFromManifestFlagName     = "namespace-from-manifest"
+	namespaceFromManifestDefaultValue = false
+	namespaceFromManifestFlagUsage    = "if true the resources will keep the namespace declared in their manifests, the namespace set via flag or kubeconfig will be used only for the ones without it and for the inventory"
+
+	namespaceMismatchFlagName     = "namespace-mismatch"
+	namespaceMismatchDefaultValue = namespaceMismatchFail
+	namespaceMismatchFlagUsage    = "how to handle resources declaring a namespace different from the one set via flag, one of: override, fail, skip"
+
+	fieldManagerFlagName  = "field-manager"
+	fieldManagerEnvName   = "MLP_FIELD_MANAGER"
+	fieldManagerFlagUsage = "the name of the manager used for applying resources, different managers keep separate inventories and don't prune each other resources, default to the " + fieldManagerEnvName + " env or 'mlp'"
+
+	// checksumKeyEnvName contains the key used for calculating the checksums of the Secrets with HMAC
+	checksumKeyEnvName = "MLP_CHECKSUM_KEY"
+
+	inventoryBackendFlagName     = "inventory-backend"
+	inventoryBackendDefaultValue = InventoryBackendConfigMap
+	inventoryBackendFlagUsage    = "where the inventory of the deployed resources is saved, one of: configmap, crd; the inventory saved by the other backend is migrated on the next deploy"
+
+	releaseNameFlagName  = "release-name"
+	releaseNameFlagUsage = "the name of the logical application deployed, different releases in the same namespace keep separate inventories and cannot apply or prune each other resources"
+
+	labelFlagName  = "label"
+	labelFlagUsage = "a label in the key=value form added to all the resources, can be repeated"
+
+	annotationFlagName  = "annotation"
+	annotationFlagUsage = "an annotation in the key=value form added to all the resources, can be repeated"
+
+	kindPatchStrategyFlagName  = "kind-patch-strategy"
+	kindPatchStrategyFlagUsage = "the patch strategy used for the resources of a kind that don't set the patch strategy annotation, in the Kind.group=strategy form, can be repeated"
+
+	stampPodTemplatesFlagName     = "stamp-pod-templates"
+	stampPodTemplatesDefaultValue = false
+	stampPodTemplatesFlagUsage    = "if true the labels and annotations set via flags are also added to the pod templates of the workloads, changing their values will trigger a new rollout"
+
+	kubernetesEventsFlagName     = "kubernetes-events"
+	kubernetesEventsDefaultValue = false
+	kubernetesEventsFlagUsage    = "if true a kubernetes event is created for every resource applied or pruned, attached to the resource or to the target namespace"
+
+	notifyURLsFlagName  = "notify-url"
+	notifyURLsFlagUsage = "webhook url that will receive a summary of the deploy when it finishes, can be repeated for multiple endpoints"
+
+	notifyTemplateFlagName  = "notify-template"
+	notifyTemplateFlagUsage = "path to a go template used for rendering the notification payload instead of the default json"
+
+	notifyPipelineURLFlagName  = "notify-pipeline-url"
+	notifyPipelineURLFlagUsage = "link to the pipeline running the deploy to add to the notification payload"
+
+	notifyDryRunFlagName     = "notify-dry-run"
+	notifyDryRunDefaultValue = false
+	notifyDryRunFlagUsage    = "if true the notification payload is printed instead of being sent"
+
+	offlineFlagName     = "offline"
+	offlineDefaultValue = false
+	offlineFlagUsage    = "if true the resources are validated and rendered using the cluster snapshot without contacting the cluster, and printed instead of being applied"
+
+	clusterSnapshotFlagName  = "cluster-snapshot"
+	clusterSnapshotFlagUsage = "path to a snapshot of the cluster APIs created with the cluster-snapshot command, required when running offline"
+
+	kubeVersionFlagName  = "kube-version"
+	kubeVersionFlagUsage = "target kubernetes version, like 1.30, whose built-in APIs are used in place of a cluster snapshot when running offline"
+
+	selectFlagName  = "select"
+	selectFlagUsage = "label selector for applying only the matching resources, the other resources are not pruned"
+
+	selectAnnotationFlagName  = "select-annotation"
+	selectAnnotationFlagUsage = "annotation selector for applying only the matching resources, the other resources are not pruned"
+
+	quotaCheckFlagName     = "quota-check"
+	quotaCheckDefaultValue = quotaCheckOff
+	quotaCheckFlagUsage    = "check the compute resources of the workloads against the namespace resource quotas before applying, one of: strict, warn, off"
+
+	secretStoreCheckFlagName     = "secret-store-check"
+	secretStoreCheckDefaultValue = false
+	secretStoreCheckFlagUsage    = "if true verify that the SecretStores and ClusterSecretStores referenced by the ExternalSecrets exist and are ready before applying the resources"
+
+	normalizeFlagName     = "normalize"
+	normalizeDefaultValue = false
+	normalizeFlagUsage    = "if true rewrite the resources in a canonical form before applying them, for avoiding patches and rollouts caused only by how they are rendered"
+
+	historyLimitFlagName  = "history-limit"
+	historyLimitFlagUsage = "number of deploys kept in the history saved inside the namespace, set to 0 for disabling the history"
+
+	actorFlagName  = "actor"
+	actorFlagUsage = "name of who is running the deploy saved in the history, default to the GITLAB_USER_LOGIN or GITHUB_ACTOR env"
+
+	gitSHAFlagName  = "git-sha"
+	gitSHAFlagUsage = "commit of the deployed configuration saved in the history, default to the CI_COMMIT_SHA or GITHUB_SHA env"
+
+	applyReportFlagName     = "apply-report"
+	applyReportDefaultValue = false
+	applyReportFlagUsage    = "if true print at the end of the deploy the operation, patch size, API latency and retries of every applied resource"
+
+	fanOutNamespacesFlagName  = "fan-out-namespaces"
+	fanOutNamespacesFlagUsage = "repeat the deploy in every namespace of the list, ignoring the namespace set via flag or kubeconfig"
+
+	fanOutSelectorFlagName  = "fan-out-selector"
+	fanOutSelectorFlagUsage = "repeat the deploy in every namespace matching the label selector, in addition to the ones passed with --fan-out-namespaces"
+
+	fanOutConcurrencyFlagName     = "fan-out-concurrency"
+	fanOutConcurrencyDefaultValue = 1
+	fanOutConcurrencyFlagUsage    = "number of namespaces deployed in parallel when deploying in multiple namespaces"
+
+	fanOutQPSFlagName  = "fan-out-qps"
+	fanOutQPSFlagUsage = "maximum number of requests per second sent to the API server by all the parallel deploys, 0 for using the default limits"
+
+	fanOutBurstFlagName     = "fan-out-burst"
+	fanOutBurstDefaultValue = 10
+	fanOutBurstFlagUsage    = "maximum burst of requests sent to the API server by all the parallel deploys when --fan-out-qps is set"
+
+	pruneOrphansFlagName     = "prune-orphans"
+	pruneOrphansDefaultValue = pruneOrphansOff
+	pruneOrphansFlagUsage    = "search the resources managed by mlp that are not in the manifests or in the inventory, one of: off, report, prune"
+
+	orphanKindsFlagName  = "orphan-kinds"
+	orphanKindsFlagUsage = "kinds of the namespaced resources searched for orphans, in the Kind.group format"
+
+	orphansManagedByFlagName     = "orphans-managed-by"
+	orphansManagedByDefaultValue = "mlp"
+	orphansManagedByFlagUsage    = "value of the app.kubernetes.io/managed-by label of the resources searched for orphans"
+
+	profileFlagName  = "profile"
+	profileFlagUsage = "name of the profile defined in the project configuration whose flag values are used for the deploy"
+
+	diagnoseFlagName     = "diagnose"
+	diagnoseDefaultValue = true
+	diagnoseFlagUsage    = "if true print the conditions, the recent events and the pods status of the resources that fail their health check or are not ready before the deploy ends"
+
+	noProgressFlagName     = "no-progress"
+	noProgressDefaultValue = false
+	noProgressFlagUsage    = "if true disable the live progress table and print a line for every event, also when running in a terminal"
+
+	quietFlagName     = "quiet"
+	quietDefaultValue = false
+	quietFlagUsage    = "if true print only the failures and the summary of the deploy, instead of a line for every event"
+
+	showFlagName  = "show"
+	showFlagUsage = "categories of the events printed during the deploy, any of: failed, changed, unchanged, skipped, pruned, progress; all by default"
+
+	expectedClusterFlagName  = "expected-cluster"
+	expectedClusterFlagUsage = "property that the target cluster must have before applying anything, in the kind=value format where kind is one of: server, uid, namespace-label; can be repeated"
+
+	resumeFlagName  = "resume"
+	resumeFlagUsage = "run id of a failed deploy to resume, applying only the resources not already applied by it or changed since then"
+
 	stdinToken    = "-"
 	fieldManager  = "mlp"
 	inventoryName = "eu.mia-platform.mlp"
 
+	generatedInventorySuffix = ".generated"
+
 	jobGeneratorLabel = "mia-platform.eu/autocreate"
 	jobGeneratorValue = "true"
 )
 
 var (
 	validDeployTypeValues = []string{extensions.DeployAll, extensions.DeploySmart}
+
+	// defaultNamespaceTerminationBackoff is used to poll a terminating namespace, it will wait for at most ~5 minutes
+	defaultNamespaceTerminationBackoff = wait.Backoff{
+		Duration: 1 * time.Second,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    35,
+		Cap:      10 * time.Second,
+	}
 )
 
 // Flags contains all the flags for the `deploy` command. They will be converted to Options
@@ -94,6 +327,61 @@ type Flags struct {
 	forceDeploy     bool
 	ensureNamespace bool
 	dryRun          bool
+	noProgress      bool
+	quiet           bool
+	show            []string
+
+	dryRunOutputDir          string
+	waitNamespaceTermination bool
+	injectStandardEnv        bool
+	restartDependents        bool
+	serveEvents              string
+	prune                    bool
+	pruneWaitTimeout         time.Duration
+	waitForPrune             bool
+	healthCheckTimeout       time.Duration
+	dependsOnTimeout         time.Duration
+	waitTimeout              time.Duration
+	namespaceFromManifest    bool
+	namespaceMismatch        string
+	fieldManager             string
+	inventoryBackend         string
+	releaseName              string
+	labels                   []string
+	annotations              []string
+	kindPatchStrategies      []string
+	stampPodTemplates        bool
+	kubernetesEvents         bool
+	notifyURLs               []string
+	notifyTemplatePath       string
+	notifyPipelineURL        string
+	notifyDryRun             bool
+	offline                  bool
+	clusterSnapshotPath      string
+	kubeVersion              string
+	quotaCheck               string
+	secretStoreCheck         bool
+	selectLabels             string
+	selectAnnotations        string
+	normalize                bool
+	historyLimit             int
+	actor                    string
+	gitSHA                   string
+	applyReport              bool
+	diagnose                 bool
+	profile                  string
+	pruneOrphans             string
+	orphanKinds              []string
+	orphansManagedBy         string
+	resumeRunID              string
+	fanOutNamespaces         []string
+	fanOutSelector           string
+	fanOutConcurrency        int
+	fanOutQPS                float32
+	fanOutBurst              int
+	expectedCluster          []string
+	generateConfigs          []string
+	generatePrefixes         []string
 }
 
 // Options have the data required to perform the deploy operation
@@ -103,6 +391,67 @@ type Options struct {
 	forceDeploy     bool
 	ensureNamespace bool
 	dryRun          bool
+	noProgress      bool
+	quiet           bool
+	show            []string
+
+	dryRunOutputDir          string
+	waitNamespaceTermination bool
+	namespaceBackoff         wait.Backoff
+	injectStandardEnv        bool
+	restartDependents        bool
+	serveEvents              string
+	skipPrune                bool
+	pruneWaitTimeout         time.Duration
+	waitForPrune             bool
+	healthCheckTimeout       time.Duration
+	dependsOnTimeout         time.Duration
+	waitTimeout              time.Duration
+	namespaceFromManifest    bool
+	namespaceMismatch        string
+	fieldManager             string
+	inventoryBackend         string
+	releaseName              string
+	labels                   map[string]string
+	annotations              map[string]string
+	kindPatchStrategies      map[string]string
+	stampPodTemplates        bool
+	kubernetesEvents         bool
+	notifyURLs               []string
+	notifyTemplatePath       string
+	notifyPipelineURL        string
+	notifyDryRun             bool
+	offline                  bool
+	clusterSnapshotPath      string
+	kubeVersion              string
+	quotaCheck               string
+	secretStoreCheck         bool
+	selectLabels             string
+	selectAnnotations        string
+	normalize                bool
+	historyLimit             int
+	actor                    string
+	gitSHA                   string
+	applyReport              bool
+	diagnose                 bool
+	pruneOrphans             string
+	orphanKinds              []string
+	orphansManagedBy         string
+	resumeRunID              string
+	fanOutNamespaces         []string
+	fanOutSelector           string
+	fanOutConcurrency        int
+	expectedCluster          []string
+	generateConfigs          []string
+	generatePrefixes         []string
+	projectConfigPath        string
+	checksumKey              string
+
+	objects  []*unstructured.Unstructured
+	progress *deployProgress
+
+	healthCheckInterval  time.Duration
+	healthCheckTransport http.RoundTripper
 
 	clientFactory util.ClientFactory
 	clock         clock.PassiveClock
@@ -124,28 +473,12 @@ func NewCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 		Args: cobra.NoArgs,
 
 		PreRun: func(cmd *cobra.Command, _ []string) {
-			logger := logr.FromContextOrDiscard(cmd.Context())
-			restClient, err := flags.ConfigFlags.ToRESTConfig()
-			cobra.CheckErr(err)
-			logger.V(10).Info("checking flow control APIs")
-			enabled, err := flowcontrol.IsEnabled(cmd.Context(), restClient)
-			cobra.CheckErr(err)
-			qps := float32(100.0)
-			burst := 500
-			if enabled {
-				qps = -1
-				burst = -1
-			}
-			flags.ConfigFlags.WrapConfigFn = func(c *rest.Config) *rest.Config {
-				c.QPS = qps
-				c.Burst = burst
-				return c
-			}
-			logger.V(5).Info("flow control APIs", "enabled", enabled)
+			cobra.CheckErr(flags.configure(cmd.Context(), cmd.Flags()))
 		},
 		Run: func(cmd *cobra.Command, _ []string) {
 			o, err := flags.ToOptions(cmd.InOrStdin(), cmd.OutOrStderr())
 			cobra.CheckErr(err)
+			o.projectConfigPath = config.PathFromContext(cmd.Context())
 			cobra.CheckErr(o.Validate())
 			cobra.CheckErr(o.Run(cmd.Context()))
 		},
@@ -155,6 +488,18 @@ func NewCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
 	if err := cmd.RegisterFlagCompletionFunc(deployTypeFlagName, deployTypeFlagCompletionfunc); err != nil {
 		panic(err)
 	}
+	if err := cmd.RegisterFlagCompletionFunc(quotaCheckFlagName, quotaCheckFlagCompletionfunc); err != nil {
+		panic(err)
+	}
+	if err := cmd.RegisterFlagCompletionFunc(pruneOrphansFlagName, pruneOrphansFlagCompletionfunc); err != nil {
+		panic(err)
+	}
+	if err := cmd.RegisterFlagCompletionFunc(namespaceMismatchFlagName, namespaceMismatchFlagCompletionfunc); err != nil {
+		panic(err)
+	}
+	if err := cmd.RegisterFlagCompletionFunc(inventoryBackendFlagName, InventoryBackendFlagCompletionfunc); err != nil {
+		panic(err)
+	}
 
 	return cmd
 }
@@ -166,10 +511,64 @@ func (f *Flags) AddFlags(flags *pflag.FlagSet) {
 	}
 
 	flags.StringSliceVarP(&f.inputPaths, inputPathsFlagName, inputPathsShortName, nil, inputPathsFlagUsage)
+	flags.StringSliceVar(&f.generateConfigs, generateConfigFlagName, nil, generateConfigFlagUsage)
+	flags.StringSliceVar(&f.generatePrefixes, generatePrefixesFlagName, nil, generatePrefixesFlagUsage)
 	flags.StringVar(&f.deployType, deployTypeFlagName, deployTypeDefaultValue, deployTypeFlagUsage)
 	flags.BoolVar(&f.forceDeploy, forceDeployFlagName, forceDeployDefaultValue, forceDeployFlagUsage)
 	flags.BoolVar(&f.ensureNamespace, ensureNamespaceFlagName, ensureNamespaceDefaultValue, ensureNamespaceFlagUsage)
 	flags.BoolVar(&f.dryRun, dryRunFlagName, dryRunDefaultValue, dryRunFlagUsage)
+	flags.StringVar(&f.dryRunOutputDir, dryRunOutputFlagName, "", dryRunOutputFlagUsage)
+	flags.BoolVar(&f.waitNamespaceTermination, waitNamespaceTerminationFlagName, waitNamespaceTerminationDefaultValue, waitNamespaceTerminationFlagUsage)
+	flags.BoolVar(&f.injectStandardEnv, injectStandardEnvFlagName, injectStandardEnvDefaultValue, injectStandardEnvFlagUsage)
+	flags.BoolVar(&f.restartDependents, restartDependentsFlagName, restartDependentsDefaultValue, restartDependentsFlagUsage)
+	flags.StringVar(&f.serveEvents, serveEventsFlagName, "", serveEventsFlagUsage)
+	flags.BoolVar(&f.prune, pruneFlagName, pruneDefaultValue, pruneFlagUsage)
+	flags.DurationVar(&f.pruneWaitTimeout, pruneWaitTimeoutFlagName, pruneWaitTimeoutDefaultValue, pruneWaitTimeoutFlagUsage)
+	flags.BoolVar(&f.waitForPrune, waitForPruneFlagName, waitForPruneDefaultValue, waitForPruneFlagUsage)
+	flags.DurationVar(&f.healthCheckTimeout, healthCheckTimeoutFlagName, healthCheckTimeoutDefaultValue, healthCheckTimeoutFlagUsage)
+	flags.DurationVar(&f.dependsOnTimeout, dependsOnTimeoutFlagName, dependsOnTimeoutDefaultValue, dependsOnTimeoutFlagUsage)
+	flags.DurationVar(&f.waitTimeout, waitTimeoutFlagName, waitTimeoutDefaultValue, waitTimeoutFlagUsage)
+	flags.BoolVar(&f.namespaceFromManifest, namespaceFromManifestFlagName, namespaceFromManifestDefaultValue, namespaceFromManifestFlagUsage)
+	flags.StringVar(&f.namespaceMismatch, namespaceMismatchFlagName, namespaceMismatchDefaultValue, namespaceMismatchFlagUsage)
+	flags.StringVar(&f.fieldManager, fieldManagerFlagName, cmp.Or(os.Getenv(fieldManagerEnvName), fieldManager), fieldManagerFlagUsage)
+	flags.StringVar(&f.inventoryBackend, inventoryBackendFlagName, inventoryBackendDefaultValue, inventoryBackendFlagUsage)
+	flags.StringVar(&f.releaseName, releaseNameFlagName, "", releaseNameFlagUsage)
+	flags.StringArrayVar(&f.labels, labelFlagName, nil, labelFlagUsage)
+	flags.StringArrayVar(&f.annotations, annotationFlagName, nil, annotationFlagUsage)
+	flags.StringArrayVar(&f.kindPatchStrategies, kindPatchStrategyFlagName, nil, kindPatchStrategyFlagUsage)
+	flags.BoolVar(&f.stampPodTemplates, stampPodTemplatesFlagName, stampPodTemplatesDefaultValue, stampPodTemplatesFlagUsage)
+	flags.BoolVar(&f.kubernetesEvents, kubernetesEventsFlagName, kubernetesEventsDefaultValue, kubernetesEventsFlagUsage)
+	flags.StringSliceVar(&f.notifyURLs, notifyURLsFlagName, nil, notifyURLsFlagUsage)
+	flags.StringVar(&f.notifyTemplatePath, notifyTemplateFlagName, "", notifyTemplateFlagUsage)
+	flags.StringVar(&f.notifyPipelineURL, notifyPipelineURLFlagName, "", notifyPipelineURLFlagUsage)
+	flags.BoolVar(&f.notifyDryRun, notifyDryRunFlagName, notifyDryRunDefaultValue, notifyDryRunFlagUsage)
+	flags.BoolVar(&f.offline, offlineFlagName, offlineDefaultValue, offlineFlagUsage)
+	flags.StringVar(&f.clusterSnapshotPath, clusterSnapshotFlagName, "", clusterSnapshotFlagUsage)
+	flags.StringVar(&f.kubeVersion, kubeVersionFlagName, "", kubeVersionFlagUsage)
+	flags.StringVar(&f.selectLabels, selectFlagName, "", selectFlagUsage)
+	flags.StringVar(&f.selectAnnotations, selectAnnotationFlagName, "", selectAnnotationFlagUsage)
+	flags.StringVar(&f.quotaCheck, quotaCheckFlagName, quotaCheckDefaultValue, quotaCheckFlagUsage)
+	flags.BoolVar(&f.secretStoreCheck, secretStoreCheckFlagName, secretStoreCheckDefaultValue, secretStoreCheckFlagUsage)
+	flags.BoolVar(&f.normalize, normalizeFlagName, normalizeDefaultValue, normalizeFlagUsage)
+	flags.IntVar(&f.historyLimit, historyLimitFlagName, history.DefaultLimit, historyLimitFlagUsage)
+	flags.StringVar(&f.actor, actorFlagName, cmp.Or(os.Getenv("GITLAB_USER_LOGIN"), os.Getenv("GITHUB_ACTOR")), actorFlagUsage)
+	flags.StringVar(&f.gitSHA, gitSHAFlagName, cmp.Or(os.Getenv("CI_COMMIT_SHA"), os.Getenv("GITHUB_SHA")), gitSHAFlagUsage)
+	flags.BoolVar(&f.applyReport, applyReportFlagName, applyReportDefaultValue, applyReportFlagUsage)
+	flags.BoolVar(&f.diagnose, diagnoseFlagName, diagnoseDefaultValue, diagnoseFlagUsage)
+	flags.StringVar(&f.profile, profileFlagName, "", profileFlagUsage)
+	flags.StringVar(&f.pruneOrphans, pruneOrphansFlagName, pruneOrphansDefaultValue, pruneOrphansFlagUsage)
+	flags.StringSliceVar(&f.orphanKinds, orphanKindsFlagName, defaultOrphanKinds, orphanKindsFlagUsage)
+	flags.StringVar(&f.orphansManagedBy, orphansManagedByFlagName, orphansManagedByDefaultValue, orphansManagedByFlagUsage)
+	flags.BoolVar(&f.noProgress, noProgressFlagName, noProgressDefaultValue, noProgressFlagUsage)
+	flags.BoolVar(&f.quiet, quietFlagName, quietDefaultValue, quietFlagUsage)
+	flags.StringSliceVar(&f.show, showFlagName, nil, showFlagUsage)
+	flags.StringVar(&f.resumeRunID, resumeFlagName, "", resumeFlagUsage)
+	flags.StringSliceVar(&f.fanOutNamespaces, fanOutNamespacesFlagName, nil, fanOutNamespacesFlagUsage)
+	flags.StringVar(&f.fanOutSelector, fanOutSelectorFlagName, "", fanOutSelectorFlagUsage)
+	flags.IntVar(&f.fanOutConcurrency, fanOutConcurrencyFlagName, fanOutConcurrencyDefaultValue, fanOutConcurrencyFlagUsage)
+	flags.Float32Var(&f.fanOutQPS, fanOutQPSFlagName, 0, fanOutQPSFlagUsage)
+	flags.IntVar(&f.fanOutBurst, fanOutBurstFlagName, fanOutBurstDefaultValue, fanOutBurstFlagUsage)
+	flags.StringArrayVar(&f.expectedCluster, expectedClusterFlagName, nil, expectedClusterFlagUsage)
 }
 
 // ToOptions transform the command flags in command runtime arguments
@@ -178,11 +577,83 @@ func (f *Flags) ToOptions(reader io.Reader, writer io.Writer) (*Options, error)
 		return nil, fmt.Errorf("config flags are required")
 	}
 
+	labels, err := parseKeyValues(f.labels, labelFlagName)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations, err := parseKeyValues(f.annotations, annotationFlagName)
+	if err != nil {
+		return nil, err
+	}
+
+	kindPatchStrategies, err := parseKeyValues(f.kindPatchStrategies, kindPatchStrategyFlagName)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Options{
 		inputPaths:      f.inputPaths,
 		deployType:      f.deployType,
 		forceDeploy:     f.forceDeploy,
 		ensureNamespace: f.ensureNamespace,
+		dryRun:          f.dryRun,
+		noProgress:      f.noProgress,
+		quiet:           f.quiet,
+		show:            f.show,
+
+		dryRunOutputDir:          f.dryRunOutputDir,
+		waitNamespaceTermination: f.waitNamespaceTermination,
+		namespaceBackoff:         defaultNamespaceTerminationBackoff,
+		injectStandardEnv:        f.injectStandardEnv,
+		restartDependents:        f.restartDependents,
+		serveEvents:              f.serveEvents,
+		skipPrune:                !f.prune,
+		pruneWaitTimeout:         f.pruneWaitTimeout,
+		waitForPrune:             f.waitForPrune,
+		healthCheckTimeout:       f.healthCheckTimeout,
+		dependsOnTimeout:         f.dependsOnTimeout,
+		waitTimeout:              f.waitTimeout,
+		healthCheckInterval:      healthCheckInterval,
+		namespaceFromManifest:    f.namespaceFromManifest,
+		namespaceMismatch:        f.namespaceMismatch,
+		fieldManager:             f.fieldManager,
+		inventoryBackend:         f.inventoryBackend,
+		releaseName:              f.releaseName,
+		labels:                   labels,
+		annotations:              annotations,
+		kindPatchStrategies:      kindPatchStrategies,
+		stampPodTemplates:        f.stampPodTemplates,
+		kubernetesEvents:         f.kubernetesEvents,
+		notifyURLs:               f.notifyURLs,
+		notifyTemplatePath:       f.notifyTemplatePath,
+		notifyPipelineURL:        f.notifyPipelineURL,
+		notifyDryRun:             f.notifyDryRun,
+		offline:                  f.offline,
+		clusterSnapshotPath:      f.clusterSnapshotPath,
+		kubeVersion:              f.kubeVersion,
+		quotaCheck:               f.quotaCheck,
+		secretStoreCheck:         f.secretStoreCheck,
+		selectLabels:             f.selectLabels,
+		selectAnnotations:        f.selectAnnotations,
+		normalize:                f.normalize,
+		historyLimit:             f.historyLimit,
+		actor:                    f.actor,
+		gitSHA:                   f.gitSHA,
+		applyReport:              f.applyReport,
+		diagnose:                 f.diagnose,
+		pruneOrphans:             f.pruneOrphans,
+		orphanKinds:              f.orphanKinds,
+		orphansManagedBy:         f.orphansManagedBy,
+		resumeRunID:              f.resumeRunID,
+		fanOutNamespaces:         f.fanOutNamespaces,
+		fanOutSelector:           f.fanOutSelector,
+		fanOutConcurrency:        f.fanOutConcurrency,
+		expectedCluster:          f.expectedCluster,
+		generateConfigs:          f.generateConfigs,
+		generatePrefixes:         f.generatePrefixes,
+		projectConfigPath:        config.DefaultFileName,
+		checksumKey:              os.Getenv(checksumKeyEnvName),
 
 		clientFactory: util.NewFactory(f.ConfigFlags),
 		reader:        reader,
@@ -191,8 +662,84 @@ func (f *Flags) ToOptions(reader io.Reader, writer io.Writer) (*Options, error)
 	}, nil
 }
 
+// NewOptions return the Options for deploying the resources in inputPaths like the deploy command, with the flags
+// set to flagValues and the default values for the others; the connection to the cluster is configured by
+// configFlags, and the profile and the client rate limits are applied as done before running the command
+func NewOptions(ctx context.Context, configFlags *genericclioptions.ConfigFlags, inputPaths []string, flagValues map[string]string, writer io.Writer) (*Options, error) {
+	flags := &Flags{ConfigFlags: configFlags}
+	flagSet := pflag.NewFlagSet(cmdUsage, pflag.ContinueOnError)
+	flags.AddFlags(flagSet)
+	for _, name := range slices.Sorted(maps.Keys(flagValues)) {
+		if err := flagSet.Set(name, flagValues[name]); err != nil {
+			return nil, fmt.Errorf("setting %q flag: %w", name, err)
+		}
+	}
+	flags.inputPaths = inputPaths
+
+	if err := flags.configure(ctx, flagSet); err != nil {
+		return nil, err
+	}
+
+	o, err := flags.ToOptions(nil, writer)
+	if err != nil {
+		return nil, err
+	}
+	o.projectConfigPath = config.PathFromContext(ctx)
+	return o, nil
+}
+
+// configure apply to flagSet the selected profile and set the client rate limits of the connection to the cluster,
+// disabling the client side ones if the cluster support the flow control APIs
+func (f *Flags) configure(ctx context.Context, flagSet *pflag.FlagSet) error {
+	logger := logr.FromContextOrDiscard(ctx)
+	if len(f.profile) > 0 {
+		logger.V(3).Info("applying deploy profile", "profile", f.profile)
+		if err := applyProfile(ctx, flagSet, f.profile); err != nil {
+			return err
+		}
+	}
+	if f.offline || f.ConfigFlags == nil {
+		logger.V(10).Info("skipping flow control check in offline mode")
+		return nil
+	}
+
+	restClient, err := f.ConfigFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	logger.V(10).Info("checking flow control APIs")
+	enabled, err := flowcontrol.IsEnabled(ctx, restClient)
+	if err != nil {
+		return err
+	}
+	qps := float32(100.0)
+	burst := 500
+	if enabled {
+		qps = -1
+		burst = -1
+	}
+	rateLimiter := fanOutRateLimiter(f.fanOutQPS, f.fanOutBurst)
+	f.ConfigFlags.WrapConfigFn = func(c *rest.Config) *rest.Config {
+		c.QPS = qps
+		c.Burst = burst
+		if rateLimiter != nil {
+			c.RateLimiter = rateLimiter
+		}
+		return c
+	}
+	logger.V(5).Info("flow control APIs", "enabled", enabled)
+	return nil
+}
+
+// WithObjects add already loaded objects to the ones that will be read from the input paths, this allow to pass
+// resources generated in memory without writing them on disk
+func (o *Options) WithObjects(objects ...*unstructured.Unstructured) *Options {
+	o.objects = append(o.objects, objects...)
+	return o
+}
+
 func (o *Options) Validate() error {
-	if len(o.inputPaths) == 0 {
+	if len(o.inputPaths) == 0 && len(o.generateConfigs) == 0 && len(o.objects) == 0 {
 		return fmt.Errorf("at least one path must be specified with %q flag", inputPathsFlagName)
 	}
 
@@ -204,6 +751,115 @@ func (o *Options) Validate() error {
 		return fmt.Errorf("invalid deploy type value: %q", o.deployType)
 	}
 
+	if len(o.fieldManager) == 0 {
+		return fmt.Errorf("the %q flag cannot be empty", fieldManagerFlagName)
+	}
+
+	if len(o.inventoryBackend) > 0 && !slices.Contains(ValidInventoryBackends, o.inventoryBackend) {
+		return fmt.Errorf("invalid inventory backend value: %q", o.inventoryBackend)
+	}
+
+	if len(o.releaseName) > 0 {
+		if errs := validation.IsDNS1123Label(o.releaseName); len(errs) > 0 {
+			return fmt.Errorf("invalid release name %q: %s", o.releaseName, strings.Join(errs, ", "))
+		}
+	}
+
+	if errs := validation.IsDNS1123Subdomain(inventoryNameFor(o.fieldManager, o.releaseName)); len(errs) > 0 {
+		return fmt.Errorf("invalid field manager %q: %s", o.fieldManager, strings.Join(errs, ", "))
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(o.labels)) {
+		errs := slices.Concat(validation.IsQualifiedName(key), validation.IsValidLabelValue(o.labels[key]))
+		if len(errs) > 0 {
+			return fmt.Errorf("invalid label %q: %s", key, strings.Join(errs, ", "))
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(o.annotations)) {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid annotation %q: %s", key, strings.Join(errs, ", "))
+		}
+	}
+
+	if err := o.validateKindPatchStrategies(); err != nil {
+		return err
+	}
+
+	if _, err := newResourceSelector(o.selectLabels, o.selectAnnotations); err != nil {
+		return err
+	}
+
+	if len(o.namespaceMismatch) > 0 && !slices.Contains(validNamespaceMismatchValues, o.namespaceMismatch) {
+		return fmt.Errorf("invalid namespace mismatch value: %q", o.namespaceMismatch)
+	}
+
+	if len(o.quotaCheck) > 0 && !slices.Contains(validQuotaCheckValues, o.quotaCheck) {
+		return fmt.Errorf("invalid quota check value: %q", o.quotaCheck)
+	}
+
+	if err := o.validatePruneOrphans(); err != nil {
+		return err
+	}
+
+	if o.skipPrune && o.pruneOrphans == pruneOrphansPrune {
+		return fmt.Errorf("the %q flag cannot be set to %q when %q is false", pruneOrphansFlagName, pruneOrphansPrune, pruneFlagName)
+	}
+
+	if o.waitForPrune && o.pruneWaitTimeout <= 0 {
+		return fmt.Errorf("the %q flag requires a %q greater than zero", waitForPruneFlagName, pruneWaitTimeoutFlagName)
+	}
+
+	if len(o.dryRunOutputDir) > 0 && !o.dryRun {
+		return fmt.Errorf("the %q flag can be used only with %q", dryRunOutputFlagName, dryRunFlagName)
+	}
+
+	if err := o.validateShow(); err != nil {
+		return err
+	}
+
+	if err := o.validateFanOut(); err != nil {
+		return err
+	}
+
+	if o.historyLimit < 0 {
+		return fmt.Errorf("the %q flag cannot be negative", historyLimitFlagName)
+	}
+
+	if o.offline && len(o.clusterSnapshotPath) == 0 && len(o.kubeVersion) == 0 {
+		return fmt.Errorf("the %q flag is required when running offline, unless %q is set", clusterSnapshotFlagName, kubeVersionFlagName)
+	}
+
+	if len(o.clusterSnapshotPath) > 0 && len(o.kubeVersion) > 0 {
+		return fmt.Errorf("the %q and %q flags cannot be used together", clusterSnapshotFlagName, kubeVersionFlagName)
+	}
+
+	if len(o.kubeVersion) > 0 && !o.offline {
+		return fmt.Errorf("the %q flag can be used only with %q", kubeVersionFlagName, offlineFlagName)
+	}
+
+	if _, err := parseClusterExpectations(o.expectedCluster); err != nil {
+		return err
+	}
+
+	if len(o.expectedCluster) > 0 && o.offline {
+		return fmt.Errorf("the %q and %q flags cannot be used together", expectedClusterFlagName, offlineFlagName)
+	}
+
+	if len(o.resumeRunID) > 0 && o.offline {
+		return fmt.Errorf("the %q and %q flags cannot be used together", resumeFlagName, offlineFlagName)
+	}
+
+	if err := o.validateServeEvents(); err != nil {
+		return err
+	}
+
+	for _, notifyURL := range o.notifyURLs {
+		if parsedURL, err := url.Parse(notifyURL); err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+			return fmt.Errorf("invalid notification url %q: only http and https urls are supported", notifyURL)
+		}
+	}
+
 	return nil
 }
 
@@ -211,53 +867,177 @@ func (o *Options) Validate() error {
 func (o *Options) Run(ctx context.Context) error {
 	logger := logr.FromContextOrDiscard(ctx)
 
+	if err := o.generateObjects(ctx); err != nil {
+		return err
+	}
+
+	if o.offline {
+		return o.runOffline(ctx)
+	}
+
+	if o.fanOutEnabled() {
+		return o.runFanOut(ctx)
+	}
+
+	o.clientFactory = newTolerantDiscoveryFactory(o.clientFactory)
 	namespace, _, err := o.clientFactory.ToRawKubeConfigLoader().Namespace()
 	if err != nil {
 		return err
 	}
 
-	inventory, err := NewInventory(o.clientFactory, inventoryName, namespace, fieldManager)
+	if err := o.verifyCluster(ctx, namespace); err != nil {
+		return err
+	}
+
+	inventory, err := NewInventory(o.clientFactory, inventoryNameFor(o.fieldManager, o.releaseName), namespace, o.fieldManager, o.inventoryBackend)
 	if err != nil {
 		return err
 	}
 
+	if o.progress, err = o.deployProgress(ctx, namespace); err != nil {
+		return err
+	}
+
 	resources, err := o.readResources(ctx)
 	if err != nil {
 		return err
 	}
 
-	if err := o.ensuringNamespace(ctx, namespace); err != nil {
-		return nil
+	if err := validatePatchStrategies(resources); err != nil {
+		return err
 	}
 
-	deployIdentifier := map[string]string{
-		"time": o.clock.Now().Format(time.RFC3339),
+	if err := validateOwnerships(resources); err != nil {
+		return err
+	}
+
+	if err := validateWaitTimeouts(resources); err != nil {
+		return err
+	}
+
+	if err := o.convertAPIVersions(ctx, resources); err != nil {
+		return err
+	}
+
+	mutators, err := o.mutators(resources)
+	if err != nil {
+		return err
+	}
+
+	if err := o.trackRemovedGeneratedResources(ctx, inventory, resources); err != nil {
+		return err
+	}
+
+	if err := o.handleOrphans(ctx, inventory, namespace, resources); err != nil {
+		return err
+	}
+
+	shareObjects(inventory, resources)
+
+	// the mutators are created before selecting the resources for using all the dependencies in the checksums
+	resources, err = o.selectResources(ctx, inventory, resources)
+	if err != nil {
+		return err
+	}
+
+	if o.skipPrune {
+		logger.V(3).Info("prune disabled")
+		disablePrune(inventory, resources)
+	}
+
+	namespaces := []string{namespace}
+	if o.namespaceFromManifest {
+		namespaces = namespacesFromResources(namespace, resources)
+	}
+
+	for _, namespace := range namespaces {
+		if err := o.ensuringNamespace(ctx, namespace); err != nil {
+			return err
+		}
+	}
+
+	if err := o.checkQuota(ctx, resources, mutators); err != nil {
+		return err
 	}
 
+	if err := o.checkSecretStores(ctx, resources); err != nil {
+		return err
+	}
+
+	statusCheckers, err := o.statusCheckers()
+	if err != nil {
+		return err
+	}
+
+	if err := o.checkDependsOn(ctx, resources, statusCheckers); err != nil {
+		return err
+	}
+	// the references are resolved on the selected resources, the other ones must already be in the cluster
+	mutators = append(mutators, extensions.NewDependsOnMutator(resources))
+
+	filters := []filter.Interface{extensions.NewDeployOnceFilter()}
+	if len(o.resumeRunID) > 0 {
+		logger.V(3).Info("resuming deploy", "runID", o.resumeRunID, "applied", len(o.progress.applied))
+		filters = append(filters, newResumeFilter(maps.Clone(o.progress.applied)))
+	}
+	if len(o.releaseName) > 0 {
+		filters = append(filters, extensions.NewReleaseOwnershipFilter(o.releaseName))
+	}
+
+	statusPoller, err := o.statusPoller(statusCheckers)
+	if err != nil {
+		return err
+	}
+
+	metrics := newMetricsRecorder()
+	dryRunObjects := newDryRunRecorder()
+	pruningFactory := newPruneFactory(o.clientFactory, o.pruneWaitTimeout)
 	applyClient, err := client.NewBuilder().
-		WithFactory(o.clientFactory).
+		WithFactory(newDryRunOutputFactory(newMetricsFactory(newImmutableRecreateFactory(newDeleteBeforeApplyFactory(newPatchStrategyFactory(pruningFactory, o.groupKindPatchStrategies()))), metrics), dryRunObjects)).
 		WithInventory(inventory).
-		WithGenerators(generator.NewJobGenerator(jobGeneratorLabel, jobGeneratorValue)).
-		WithMutator(
-			extensions.NewDependenciesMutator(resources),
-			extensions.NewDeployMutator(o.deployType, o.forceDeploy, extensions.ChecksumFromData(deployIdentifier)),
-			extensions.NewExternalSecretsMutator(resources),
-		).
-		WithFilters(extensions.NewDeployOnceFilter()).
-		WithCustomStatusChecker(extensions.ExternalSecretStatusCheckers()).
+		WithGenerators(extensions.NewCronJobGenerator(jobGeneratorLabel, jobGeneratorValue)).
+		WithMutator(mutators...).
+		WithFilters(filters...).
+		WithStatusPoller(statusPoller).
 		Build()
 	if err != nil {
 		return err
 	}
 	opts := client.ApplierOptions{
-		FieldManager: fieldManager,
+		FieldManager: o.fieldManager,
 		DryRun:       o.dryRun,
 	}
 
+	recorder, err := o.kubeEventRecorder(ctx, namespace, o.progress.runID)
+	if err != nil {
+		return err
+	}
+
+	deployNotifier, err := o.notifier()
+	if err != nil {
+		return err
+	}
+	collector := &summaryCollector{start: o.clock.Now()}
+	tracker := newProgressTracker()
+	health := newHealthTracker()
+
+	events, err := o.startEventServer(ctx)
+	if err != nil {
+		return err
+	}
+
 	logger.V(3).Info("start applying resources")
 	eventCh := applyClient.Run(ctx, resources, opts)
 
+	// the progress table always show all the resources, so the lines are used when the events are filtered
+	shown := o.shownEvents()
+	printer := newEventPrinter(o.writer, o.clock, !o.noProgress && len(shown) == 0, o.dryRun)
+	if len(shown) > 0 {
+		printer = newFilteredPrinter(printer, shown, metrics)
+	}
+
 	errorsDuringApplying := make([]error, 0)
+	done := ctx.Done()
 loop:
 	for {
 		select {
@@ -267,13 +1047,115 @@ loop:
 			}
 
 			if event.IsErrorEvent() {
-				errorsDuringApplying = append(errorsDuringApplying, errors.New(event.String()))
+				errorsDuringApplying = append(errorsDuringApplying, eventError(event))
 			}
 
-			fmt.Fprintln(o.writer, event.String())
-		case <-ctx.Done():
-			return ctx.Err()
-		}
+			printer.PrintEvent(event)
+			if recorder != nil {
+				recorder.Record(ctx, event)
+			}
+			if events != nil {
+				events.Publish(event)
+			}
+			collector.Collect(event)
+			metrics.Collect(event)
+			tracker.Track(event)
+			health.Track(event)
+			o.progress.Collect(event)
+		case <-done:
+			// keep reading the events until the applier has stopped, so it will not remain blocked on the channel
+			done = nil
+		}
+	}
+
+	printer.Flush()
+	interruptErr := ctx.Err()
+	interrupted := interruptErr != nil
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	if interrupted {
+		fmt.Fprintf(o.writer, "deploy interrupted after applying %d and pruning %d resource(s)\n", len(tracker.applied), len(tracker.pruned))
+		if err := o.saveInterruptedInventory(ctx, inventory, tracker); err != nil {
+			fmt.Fprintln(o.writer, err)
+		}
+	}
+
+	if o.skipPrune && !interrupted {
+		printSkippedPrune(o.writer, inventory, tracker.applied)
+	}
+
+	if o.restartDependents && !interrupted && !o.dryRun && len(errorsDuringApplying) == 0 {
+		if err := o.restartDependentWorkloads(ctx, resources, metrics, namespace); err != nil {
+			errorsDuringApplying = append(errorsDuringApplying, err)
+			collector.failures = append(collector.failures, err.Error())
+		}
+	}
+
+	if !interrupted && !o.dryRun && len(errorsDuringApplying) == 0 {
+		for _, err := range o.checkHealthPaths(ctx, resources) {
+			errorsDuringApplying = append(errorsDuringApplying, err)
+			collector.failures = append(collector.failures, err.Error())
+		}
+	}
+
+	if o.waitForPrune && !interrupted && !o.dryRun {
+		for _, err := range o.waitPrunedResources(ctx, trackerForFactory(pruningFactory)) {
+			errorsDuringApplying = append(errorsDuringApplying, err)
+			collector.failures = append(collector.failures, err.Error())
+		}
+	}
+
+	if len(o.dryRunOutputDir) > 0 {
+		if err := o.saveDryRunObjects(dryRunObjects); err != nil {
+			fmt.Fprintln(o.writer, err)
+		}
+	}
+
+	if o.diagnose && !o.dryRun {
+		if err := o.printDiagnoses(ctx, health.Unhealthy(interrupted)); err != nil {
+			fmt.Fprintln(o.writer, err)
+		}
+	}
+
+	resourceMetrics := metrics.Metrics()
+	if o.applyReport {
+		if err := printApplyReport(o.writer, resourceMetrics); err != nil {
+			fmt.Fprintln(o.writer, err)
+		}
+	}
+
+	kinds := collector.KindSummaries(resourceMetrics)
+	if err := printDeploySummary(o.writer, kinds, o.clock.Now().Sub(collector.start)); err != nil {
+		fmt.Fprintln(o.writer, err)
+	}
+
+	if err := o.saveProgress(ctx, namespace, o.progress, interrupted || len(errorsDuringApplying) > 0); err != nil {
+		fmt.Fprintln(o.writer, err)
+	}
+
+	if err := o.saveHistory(ctx, namespace, collector); err != nil {
+		fmt.Fprintln(o.writer, err)
+	}
+
+	if events != nil {
+		summary := collector.Summary(namespace, o.notifyPipelineURL, o.clock.Now())
+		summary.Kinds = kinds
+		if err := events.Close(ctx, summary); err != nil {
+			fmt.Fprintln(o.writer, err)
+		}
+	}
+
+	if deployNotifier != nil {
+		summary := collector.Summary(namespace, o.notifyPipelineURL, o.clock.Now())
+		summary.Resources = resourceMetrics
+		summary.Kinds = kinds
+		for _, err := range deployNotifier.Notify(ctx, summary) {
+			fmt.Fprintln(o.writer, err)
+		}
+	}
+
+	if interrupted {
+		return fmt.Errorf("deploy interrupted: %w", interruptErr)
 	}
 
 	if len(errorsDuringApplying) == 0 {
@@ -293,28 +1175,475 @@ func deployTypeFlagCompletionfunc(*cobra.Command, []string, string) ([]string, c
 	return validDeployTypeValues, cobra.ShellCompDirectiveDefault
 }
 
+func pruneOrphansFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return validPruneOrphansValues, cobra.ShellCompDirectiveDefault
+}
+
+func quotaCheckFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return validQuotaCheckValues, cobra.ShellCompDirectiveDefault
+}
+
+func namespaceMismatchFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return validNamespaceMismatchValues, cobra.ShellCompDirectiveDefault
+}
+
+// InventoryBackendFlagCompletionfunc complete the values of the flags selecting the inventory backend
+func InventoryBackendFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return ValidInventoryBackends, cobra.ShellCompDirectiveDefault
+}
+
+// parseKeyValues return the map of the key=value pairs passed with flagName, or nil if values is empty
+func parseKeyValues(values []string, flagName string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	pairs := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, found := strings.Cut(value, "=")
+		if !found || len(key) == 0 {
+			return nil, fmt.Errorf("invalid value %q for the %q flag: must be in the key=value form", value, flagName)
+		}
+		pairs[key] = val
+	}
+
+	return pairs, nil
+}
+
+// InventoryName return the name of the inventory used by the deploy command with manager for release, an empty
+// manager is the default one
+func InventoryName(manager, release string) string {
+	return inventoryNameFor(cmp.Or(manager, fieldManager), release)
+}
+
+// inventoryNameFor return the name of the inventory used by manager for release, the default manager without
+// a release keep using the original name to remain compatible with inventories saved by previous versions
+func inventoryNameFor(manager, release string) string {
+	name := inventoryName
+	if manager != fieldManager {
+		name += "." + manager
+	}
+
+	if len(release) > 0 {
+		name += "." + release
+	}
+
+	return name
+}
+
+// generatedInventoryNameFor return the name used for deploying the generated inventory with manager for release,
+// derived from the one of their inventory so that the releases in the same namespace don't share it
+func generatedInventoryNameFor(manager, release string) string {
+	return InventoryName(manager, release) + generatedInventorySuffix
+}
+
 func (o *Options) readResources(ctx context.Context) ([]*unstructured.Unstructured, error) {
 	logger := logr.FromContextOrDiscard(ctx)
 
-	readerBuilder := resourcereader.NewResourceReaderBuilder(o.clientFactory)
-	var accumulatedResources []*unstructured.Unstructured
+	namespace, enforced, err := o.clientFactory.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return nil, err
+	}
 
-	for _, path := range o.inputPaths {
-		reader, err := readerBuilder.ResourceReader(o.reader, path)
+	// the namespace set via flag is never enforced by the reader, the mismatches are handled by guardNamespace
+	factory := &manifestNamespaceFactory{ClientFactory: o.clientFactory}
+	readerBuilder := resourcereader.NewResourceReaderBuilder(factory)
+	accumulatedResources, err := o.preloadedObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	fSys := filesys.MakeFsOnDisk()
+	for _, inputPath := range o.inputPaths {
+		paths, err := filesToRead(fSys, inputPath)
 		if err != nil {
 			return nil, err
 		}
 
-		logger.V(5).Info("reading resources", "path", "path")
-		resources, err := reader.Read()
+		for _, path := range paths {
+			inputReader := o.reader
+			if path == stdinToken {
+				if inputReader, err = checkStdinDocuments(o.reader); err != nil {
+					return nil, err
+				}
+			}
+
+			reader, err := readerBuilder.ResourceReader(inputReader, path)
+			if err != nil {
+				return nil, err
+			}
+
+			logger.V(5).Info("reading resources", "path", path)
+			resources, err := reader.Read()
+			if err != nil {
+				return nil, err
+			}
+
+			accumulatedResources = append(accumulatedResources, resources...)
+		}
+	}
+
+	if !enforced || o.namespaceFromManifest {
+		return accumulatedResources, nil
+	}
+
+	return o.guardNamespace(namespace, accumulatedResources)
+}
+
+// checkStdinDocuments read the whole stdin stream and decode its documents one by one, so a malformed document is
+// reported with its position and its kind and name, instead of failing the whole stream without any hint; a reader
+// on the same content is returned for parsing the resources
+func checkStdinDocuments(reader io.Reader) (io.Reader, error) {
+	if reader == nil {
+		return nil, fmt.Errorf("cannot read resources from stdin")
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("reading resources from stdin: %w", err)
+	}
+
+	if err := resourceutil.DecodeEach(bytes.NewReader(data), func(*unstructured.Unstructured) error { return nil }); err != nil {
+		return nil, fmt.Errorf("reading resources from stdin: %w", err)
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+// filesToRead return the yaml files found inside path if it is a folder, skipping the ones ignored by its
+// .mlpignore file, or path itself otherwise
+func filesToRead(fSys filesys.FileSystem, path string) ([]string, error) {
+	if path == stdinToken || !fSys.IsDir(path) {
+		return []string{path}, nil
+	}
+
+	var paths []string
+	err := ignore.Walk(fSys, path, func(path string, info fs.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		for _, pattern := range kio.DefaultMatch {
+			if match, _ := filepath.Match(pattern, filepath.Base(path)); match {
+				paths = append(paths, path)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fail to read from path %q: %w", path, err)
+	}
+
+	return paths, nil
+}
+
+// generateObjects generate in memory the resources described in the generate config files and add them to the
+// objects to deploy, the config files are then cleared for generating them only once when deploying in multiple
+// namespaces
+func (o *Options) generateObjects(ctx context.Context) error {
+	if len(o.generateConfigs) == 0 {
+		return nil
+	}
+
+	objects, err := generate.NewOptions(o.generateConfigs, o.generatePrefixes, filesys.MakeFsOnDisk()).RunToObjects(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to generate the resources: %w", err)
+	}
+
+	o.WithObjects(objects...)
+	o.generateConfigs = nil
+	return nil
+}
+
+// preloadedObjects return a copy of the objects passed in memory with the default namespace set on namespaced
+// resources that don't have one
+func (o *Options) preloadedObjects() ([]*unstructured.Unstructured, error) {
+	if len(o.objects) == 0 {
+		return nil, nil
+	}
+
+	namespace, _, err := o.clientFactory.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	mapper, err := o.clientFactory.ToRESTMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*unstructured.Unstructured, 0, len(o.objects))
+	crds := resource.FindCRDs(o.objects)
+	for _, obj := range o.objects {
+		obj = obj.DeepCopy()
+		scope, err := resource.Scope(obj, mapper, crds)
 		if err != nil {
 			return nil, err
 		}
 
-		accumulatedResources = append(accumulatedResources, resources...)
+		if scope == meta.RESTScopeNamespace && len(obj.GetNamespace()) == 0 {
+			obj.SetNamespace(namespace)
+		}
+		objects = append(objects, obj)
+	}
+
+	return objects, nil
+}
+
+// mutators return the list of mutators to use during the apply process
+func (o *Options) mutators(resources []*unstructured.Unstructured) ([]mutator.Interface, error) {
+	deployIdentifier := map[string]string{
+		"time": o.clock.Now().Format(time.RFC3339),
+	}
+	if o.progress != nil {
+		// a resumed deploy keeps the identifier of the original one, so the resources it has applied are unchanged
+		deployIdentifier["time"] = o.progress.started
+	}
+
+	project, err := o.projectConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	workloads, err := extensions.NewWorkloads(project.Deploy.Workloads)
+	if err != nil {
+		return nil, err
+	}
+
+	mutators := []mutator.Interface{}
+	if o.normalize {
+		// the normalization must happen before the other mutators for calculating the checksums on the final form
+		mutators = append(mutators, extensions.NewNormalizeMutator())
+	}
+
+	// the live values are copied before the other mutators, so they can still add their annotations to the objects
+	mutators = append(mutators,
+		extensions.NewPreserveFieldsMutator(),
+		extensions.NewDependenciesMutator(resources, workloads, []byte(o.checksumKey)),
+		extensions.NewDeployMutator(o.deployType, o.forceDeploy, extensions.ChecksumFromData(deployIdentifier), workloads),
+		extensions.NewExternalSecretsMutator(resources, workloads),
+	)
+
+	if len(o.releaseName) > 0 {
+		mutators = append(mutators, extensions.NewReleaseMutator(o.releaseName))
+	}
+
+	if len(o.labels) > 0 || len(o.annotations) > 0 {
+		mutators = append(mutators, extensions.NewMetadataMutator(o.labels, o.annotations, o.stampPodTemplates, workloads))
+	}
+
+	if o.injectStandardEnv {
+		mutators = append(mutators, extensions.NewStandardEnvMutator(project.Deploy.Env, workloads))
+	}
+
+	if project.Deploy.WorkloadDefaults != nil {
+		mutators = append(mutators, extensions.NewWorkloadDefaultsMutator(*project.Deploy.WorkloadDefaults, workloads))
+	}
+
+	return mutators, nil
+}
+
+// kubeEventRecorder return the recorder for creating kubernetes events for the current run, or nil if the
+// events are disabled or the run is a dry run
+func (o *Options) kubeEventRecorder(ctx context.Context, namespace, runID string) (*kubeEventRecorder, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	if !o.kubernetesEvents || o.dryRun {
+		return nil, nil
+	}
+
+	clientSet, err := o.clientFactory.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+
+	logger.V(3).Info("recording kubernetes events", "runID", runID)
+	return &kubeEventRecorder{
+		client:    clientSet,
+		namespace: namespace,
+		runID:     runID,
+		clock:     o.clock,
+	}, nil
+}
+
+// saveHistory append a record of the current deploy to the history saved in namespace
+func (o *Options) saveHistory(ctx context.Context, namespace string, collector *summaryCollector) error {
+	if o.historyLimit == 0 || o.dryRun {
+		return nil
+	}
+
+	clientSet, err := o.clientFactory.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+
+	return history.NewStore(clientSet, namespace).Append(ctx, o.historyRecord(collector), o.historyLimit)
+}
+
+// historyRecord return the history record for the deploy with the data accumulated by collector
+func (o *Options) historyRecord(collector *summaryCollector) history.Record {
+	now := o.clock.Now()
+	result := history.ResultSucceeded
+	if len(collector.failures) > 0 {
+		result = history.ResultFailed
+	}
+
+	return history.Record{
+		Timestamp:    now.UTC(),
+		Actor:        o.actor,
+		Commit:       o.gitSHA,
+		FieldManager: o.fieldManager,
+		Applied:      len(collector.applied),
+		Pruned:       len(collector.pruned),
+		Failed:       len(collector.failures),
+		Duration:     now.Sub(collector.start).Truncate(time.Second).String(),
+		Result:       result,
+	}
+}
+
+// notifier return the notifier to call at the end of the deploy, or nil if no notification is configured
+func (o *Options) notifier() (*notifier, error) {
+	if len(o.notifyURLs) == 0 && !o.notifyDryRun {
+		return nil, nil
+	}
+
+	return newNotifier(o.notifyURLs, o.notifyTemplatePath, o.writer, o.notifyDryRun)
+}
+
+// statusCheckers return the custom status checkers to use for the resources, including the readiness
+// definitions found in the project configuration
+func (o *Options) statusCheckers() (poller.CustomStatusCheckers, error) {
+	checkers := extensions.ExternalSecretStatusCheckers()
+	maps.Copy(checkers, extensions.AddressStatusCheckers())
+	maps.Copy(checkers, extensions.JobStatusCheckers())
+
+	project, err := o.projectConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	readinessCheckers, err := extensions.ReadinessStatusCheckers(project.Deploy.Readiness)
+	if err != nil {
+		return nil, err
 	}
 
-	return accumulatedResources, nil
+	maps.Copy(checkers, readinessCheckers)
+	return checkers, nil
+}
+
+// statusPoller return the poller used for waiting the applied resources to become ready using checkers, failing the
+// ones not ready within their wait timeout
+func (o *Options) statusPoller(checkers poller.CustomStatusCheckers) (poller.StatusPoller, error) {
+	mapper, err := o.clientFactory.ToRESTMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := o.clientFactory.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return newTimeoutPoller(poller.NewDefaultStatusPoller(client, mapper, checkers), o.waitTimeout), nil
+}
+
+// waitPrunedResources wait for the removal of the resources pruned by tracker, returning an error for every
+// resource still terminating when the prune wait timeout is reached
+func (o *Options) waitPrunedResources(ctx context.Context, tracker *pruneTracker) []error {
+	if tracker == nil {
+		return nil
+	}
+
+	terminating, err := tracker.terminatingResources(ctx)
+	if err != nil {
+		return []error{fmt.Errorf("waiting for the removal of the pruned resources: %w", err)}
+	}
+
+	errs := make([]error, 0, len(terminating))
+	for _, obj := range terminating {
+		errs = append(errs, terminatingError(obj))
+	}
+	return errs
+}
+
+// applyProfile set on the flags in flagSet that are not set on the command line the values of the profile name
+// found in the project configuration
+func applyProfile(ctx context.Context, flagSet *pflag.FlagSet, name string) error {
+	project, err := config.Load(filesys.MakeFsOnDisk(), config.PathFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	return project.Deploy.ApplyProfile(name, flagSet, profileFlagName)
+}
+
+// projectConfig return the project configuration, or an empty one if no path is set
+func (o *Options) projectConfig() (*config.Project, error) {
+	if len(o.projectConfigPath) == 0 {
+		return new(config.Project), nil
+	}
+
+	return config.Load(filesys.MakeFsOnDisk(), o.projectConfigPath)
+}
+
+// convertAPIVersions change the apiVersion of resources that have a compatible version better supported by the
+// cluster, reporting every conversion done to the user
+func (o *Options) convertAPIVersions(ctx context.Context, resources []*unstructured.Unstructured) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	mapper, err := o.clientFactory.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+
+	conversions, err := convertToSupportedVersions(mapper, resources)
+	if err != nil {
+		return err
+	}
+
+	logger.V(5).Info("api versions converted", "count", len(conversions))
+	for _, conversion := range conversions {
+		fmt.Fprintln(o.writer, conversion)
+	}
+	return nil
+}
+
+// trackRemovedGeneratedResources add to the inventory the resources that are present in the remote generated inventory
+// but not in the one found in resources, so they will be pruned; the one found in resources is renamed for the
+// release being deployed
+func (o *Options) trackRemovedGeneratedResources(ctx context.Context, inventory *Inventory, resources []*unstructured.Unstructured) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	index := slices.IndexFunc(resources, extensions.IsGeneratedInventory)
+	if index < 0 {
+		return nil
+	}
+
+	current := resources[index]
+	current.SetName(generatedInventoryNameFor(o.fieldManager, o.releaseName))
+	currentData, _, err := unstructured.NestedStringMap(current.Object, "data")
+	if err != nil {
+		return err
+	}
+
+	clientSet, err := o.clientFactory.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+
+	previous, err := clientSet.CoreV1().ConfigMaps(current.GetNamespace()).Get(ctx, current.GetName(), metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to read generated inventory: %w", err)
+	}
+
+	removed := extensions.RemovedGeneratedResources(currentData, previous.Data, current.GetNamespace())
+	logger.V(5).Info("generated resources removed from configuration", "count", len(removed))
+	inventory.TrackObjects(removed...)
+	return nil
 }
 
 func (o *Options) ensuringNamespace(ctx context.Context, namespace string) error {
@@ -326,7 +1655,7 @@ func (o *Options) ensuringNamespace(ctx context.Context, namespace string) error
 
 	opts := metav1.ApplyOptions{
 		Force:        true,
-		FieldManager: fieldManager,
+		FieldManager: o.fieldManager,
 	}
 
 	if o.dryRun {
@@ -337,8 +1666,56 @@ func (o *Options) ensuringNamespace(ctx context.Context, namespace string) error
 		return err
 	}
 
+	if err := o.checkNamespaceTermination(ctx, clientSet, namespace); err != nil {
+		return err
+	}
+
 	logger.V(10).Info("ensuring existence of namespace", "namespace", namespace)
 	namespaceApply := corev1.Namespace(namespace)
 	_, err = clientSet.CoreV1().Namespaces().Apply(ctx, namespaceApply, opts)
 	return err
 }
+
+// checkNamespaceTermination return an error if namespace is terminating, or wait for its deletion if the user
+// has requested it
+func (o *Options) checkNamespaceTermination(ctx context.Context, clientSet kubernetes.Interface, namespace string) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	terminating, err := isNamespaceTerminating(ctx, clientSet, namespace)
+	if err != nil {
+		return err
+	}
+
+	if !terminating {
+		return nil
+	}
+
+	if !o.waitNamespaceTermination {
+		return fmt.Errorf("namespace %q is terminating: wait for its deletion or use the %q flag", namespace, waitNamespaceTerminationFlagName)
+	}
+
+	logger.V(3).Info("waiting for namespace termination", "namespace", namespace)
+	err = wait.ExponentialBackoffWithContext(ctx, o.namespaceBackoff, func(ctx context.Context) (bool, error) {
+		terminating, err := isNamespaceTerminating(ctx, clientSet, namespace)
+		return !terminating, err
+	})
+	if wait.Interrupted(err) && ctx.Err() == nil {
+		return fmt.Errorf("timed out waiting for namespace %q termination", namespace)
+	}
+
+	return err
+}
+
+// isNamespaceTerminating return true if namespace exists and is in the Terminating phase, missing permission for
+// reading the namespace is not considered an error for keeping compatibility with restricted service accounts
+func isNamespaceTerminating(ctx context.Context, clientSet kubernetes.Interface, namespace string) (bool, error) {
+	ns, err := clientSet.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err), apierrors.IsForbidden(err):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return ns.Status.Phase == apicorev1.NamespaceTerminating, nil
+}
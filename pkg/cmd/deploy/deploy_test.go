@@ -23,6 +23,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -33,6 +34,10 @@ import (
 	jplresource "github.com/mia-platform/jpl/pkg/resource"
 	jpltesting "github.com/mia-platform/jpl/pkg/testing"
 	"github.com/mia-platform/jpl/pkg/util"
+	"github.com/mia-platform/mlp/v2/pkg/config"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/mia-platform/mlp/v2/pkg/history"
+	"github.com/mia-platform/mlp/v2/pkg/ignore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
@@ -41,12 +46,14 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
 	dynamicfake "k8s.io/client-go/dynamic/fake"
 	restfake "k8s.io/client-go/rest/fake"
 	"k8s.io/utils/clock"
 	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
 func TestCommand(t *testing.T) {
@@ -58,7 +65,8 @@ func TestCommand(t *testing.T) {
 		case "/livez/ping":
 			w.WriteHeader(http.StatusOK)
 			w.Header().Add(fcv1beta3.ResponseHeaderMatchedFlowSchemaUID, "unused")
-		case "/api/v1/namespaces/mlp-test-deploy/secrets/resources-deployed":
+		case "/api/v1/namespaces/mlp-test-deploy/secrets/resources-deployed",
+			"/apis/mlp.mia-platform.eu/v1/namespaces/mlp-test-deploy/deployinventories/eu.mia-platform.mlp":
 			w.WriteHeader(http.StatusNotFound)
 		default:
 			for key, values := range jpltesting.DefaultHeaders() {
@@ -87,6 +95,36 @@ func TestCommand(t *testing.T) {
 	cmd.Execute()
 }
 
+func TestApplyProfile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "mlp.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`deploy:
+  profiles:
+    production:
+      deploy-type: smart_deploy
+      ensure-namespace: true
+      health-check-timeout: 10m
+`), 0600))
+
+	cmd := NewCommand(genericclioptions.NewConfigFlags(false))
+	cmd.SetContext(config.NewContext(context.TODO(), path))
+	require.NoError(t, cmd.ParseFlags([]string{"--profile=production", "--health-check-timeout=1m"}))
+
+	require.NoError(t, applyProfile(cmd.Context(), cmd.Flags(), "production"))
+	deployType, err := cmd.Flags().GetString(deployTypeFlagName)
+	require.NoError(t, err)
+	assert.Equal(t, "smart_deploy", deployType)
+	ensureNamespace, err := cmd.Flags().GetBool(ensureNamespaceFlagName)
+	require.NoError(t, err)
+	assert.True(t, ensureNamespace)
+	healthCheckTimeout, err := cmd.Flags().GetDuration(healthCheckTimeoutFlagName)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, healthCheckTimeout)
+
+	assert.ErrorContains(t, applyProfile(cmd.Context(), cmd.Flags(), "staging"), `unknown profile "staging", available profiles are: production`)
+}
+
 func TestOptions(t *testing.T) {
 	t.Parallel()
 
@@ -95,17 +133,25 @@ func TestOptions(t *testing.T) {
 	configFlags := genericclioptions.NewConfigFlags(false)
 
 	expectedOpts := &Options{
-		inputPaths:    []string{"input"},
-		deployType:    "smart_deploy",
-		reader:        reader,
-		writer:        buffer,
-		clientFactory: util.NewFactory(configFlags),
-		clock:         clock.RealClock{},
+		inputPaths:          []string{"input"},
+		deployType:          "smart_deploy",
+		noProgress:          true,
+		fieldManager:        "pipeline",
+		projectConfigPath:   "mlp.yaml",
+		reader:              reader,
+		namespaceBackoff:    defaultNamespaceTerminationBackoff,
+		healthCheckInterval: healthCheckInterval,
+		writer:              buffer,
+		clientFactory:       util.NewFactory(configFlags),
+		clock:               clock.RealClock{},
 	}
 
 	flag := &Flags{
-		inputPaths: []string{"input"},
-		deployType: "smart_deploy",
+		inputPaths:   []string{"input"},
+		deployType:   "smart_deploy",
+		noProgress:   true,
+		fieldManager: "pipeline",
+		prune:        true,
 	}
 	_, err := flag.ToOptions(reader, buffer)
 	assert.ErrorContains(t, err, "config flags are required")
@@ -117,15 +163,306 @@ func TestOptions(t *testing.T) {
 	assert.Equal(t, expectedOpts, opts)
 	assert.NoError(t, opts.Validate())
 
+	flag.labels = []string{"team=payments", "empty="}
+	flag.annotations = []string{"ci.example.com/pipeline=1234,5678"}
+	labeledOpts, err := flag.ToOptions(reader, buffer)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "payments", "empty": ""}, labeledOpts.labels)
+	assert.Equal(t, map[string]string{"ci.example.com/pipeline": "1234,5678"}, labeledOpts.annotations)
+	assert.NoError(t, labeledOpts.Validate())
+
+	labeledOpts.labels = map[string]string{"team": "payments team"}
+	assert.ErrorContains(t, labeledOpts.Validate(), `invalid label "team"`)
+	labeledOpts.labels = nil
+	labeledOpts.annotations = map[string]string{"invalid key": ""}
+	assert.ErrorContains(t, labeledOpts.Validate(), `invalid annotation "invalid key"`)
+
+	flag.labels = []string{"team"}
+	_, err = flag.ToOptions(reader, buffer)
+	assert.ErrorContains(t, err, `invalid value "team" for the "label" flag: must be in the key=value form`)
+	flag.labels = nil
+	flag.annotations = []string{"=value"}
+	_, err = flag.ToOptions(reader, buffer)
+	assert.ErrorContains(t, err, `invalid value "=value" for the "annotation" flag`)
+	flag.annotations = nil
+
 	opts.deployType = "wrong"
 	assert.ErrorContains(t, opts.Validate(), `invalid deploy type value: "wrong"`)
 	opts.deployType = "deploy_all"
 
+	opts.fieldManager = ""
+	assert.ErrorContains(t, opts.Validate(), `the "field-manager" flag cannot be empty`)
+	opts.fieldManager = "Invalid_Manager"
+	assert.ErrorContains(t, opts.Validate(), `invalid field manager "Invalid_Manager"`)
+	opts.fieldManager = fieldManager
+
+	opts.inventoryBackend = "secret"
+	assert.ErrorContains(t, opts.Validate(), `invalid inventory backend value: "secret"`)
+	opts.inventoryBackend = InventoryBackendCRD
+	assert.NoError(t, opts.Validate())
+
+	opts.releaseName = "Front.End"
+	assert.ErrorContains(t, opts.Validate(), `invalid release name "Front.End"`)
+	opts.releaseName = "frontend"
+	assert.NoError(t, opts.Validate())
+	opts.releaseName = ""
+
+	opts.notifyURLs = []string{"ftp://example.com"}
+	assert.ErrorContains(t, opts.Validate(), `invalid notification url "ftp://example.com"`)
+	opts.notifyURLs = nil
+
+	opts.selectLabels = "=api"
+	assert.ErrorContains(t, opts.Validate(), `invalid label selector "=api"`)
+	opts.selectLabels = "app=api"
+	assert.NoError(t, opts.Validate())
+
+	opts.quotaCheck = "wrong"
+	assert.ErrorContains(t, opts.Validate(), `invalid quota check value: "wrong"`)
+	opts.quotaCheck = quotaCheckStrict
+
+	opts.namespaceMismatch = "ignore"
+	assert.ErrorContains(t, opts.Validate(), `invalid namespace mismatch value: "ignore"`)
+	opts.namespaceMismatch = namespaceMismatchSkip
+	assert.NoError(t, opts.Validate())
+
+	opts.skipPrune = true
+	opts.pruneOrphans = pruneOrphansPrune
+	opts.orphansManagedBy = "mlp"
+	assert.ErrorContains(t, opts.Validate(), `the "prune-orphans" flag cannot be set to "prune" when "prune" is false`)
+	opts.pruneOrphans = pruneOrphansOff
+	opts.orphansManagedBy = ""
+	assert.NoError(t, opts.Validate())
+	opts.skipPrune = false
+
+	opts.waitForPrune = true
+	opts.pruneWaitTimeout = 0
+	assert.ErrorContains(t, opts.Validate(), `the "wait-for-prune" flag requires a "prune-wait-timeout" greater than zero`)
+	opts.pruneWaitTimeout = time.Minute
+	assert.NoError(t, opts.Validate())
+	opts.waitForPrune = false
+
+	opts.dryRunOutputDir = "output"
+	assert.ErrorContains(t, opts.Validate(), `the "dry-run-output" flag can be used only with "dry-run"`)
+	opts.dryRun = true
+	assert.NoError(t, opts.Validate())
+	opts.dryRunOutputDir = ""
+	opts.dryRun = false
+
+	opts.historyLimit = -1
+	assert.ErrorContains(t, opts.Validate(), `the "history-limit" flag cannot be negative`)
+	opts.historyLimit = 0
+
+	opts.offline = true
+	assert.ErrorContains(t, opts.Validate(), `the "cluster-snapshot" flag is required when running offline`)
+	opts.kubeVersion = "1.30"
+	assert.NoError(t, opts.Validate())
+	opts.clusterSnapshotPath = "cluster-snapshot.yaml"
+	assert.ErrorContains(t, opts.Validate(), `the "cluster-snapshot" and "kube-version" flags cannot be used together`)
+	opts.clusterSnapshotPath = ""
+	opts.offline = false
+	assert.ErrorContains(t, opts.Validate(), `the "kube-version" flag can be used only with "offline"`)
+	opts.kubeVersion = ""
+
+	opts.resumeRunID = "run-id"
+	assert.NoError(t, opts.Validate())
+	opts.offline = true
+	opts.kubeVersion = "1.30"
+	assert.ErrorContains(t, opts.Validate(), `the "resume" and "offline" flags cannot be used together`)
+	opts.offline = false
+	opts.kubeVersion = ""
+	opts.resumeRunID = ""
+
+	opts.expectedCluster = []string{"context=prod"}
+	assert.ErrorContains(t, opts.Validate(), `invalid expected cluster "context=prod"`)
+	opts.expectedCluster = []string{"uid=1234"}
+	assert.NoError(t, opts.Validate())
+	opts.offline = true
+	opts.kubeVersion = "1.30"
+	assert.ErrorContains(t, opts.Validate(), `the "expected-cluster" and "offline" flags cannot be used together`)
+	opts.offline = false
+	opts.kubeVersion = ""
+	opts.expectedCluster = nil
+
+	opts.serveEvents = "8099"
+	assert.ErrorContains(t, opts.Validate(), `invalid address "8099" for the "serve-events" flag`)
+	opts.serveEvents = ":8099"
+	assert.NoError(t, opts.Validate())
+	opts.serveEvents = ""
+
 	opts.inputPaths = []string{}
 	assert.ErrorContains(t, opts.Validate(), "at least one path must be specified")
 
 	opts.inputPaths = []string{"input", stdinToken}
 	assert.ErrorContains(t, opts.Validate(), "cannot read from stdin and other paths together")
+
+	opts.inputPaths = []string{}
+	opts.generateConfigs = []string{"generate.yaml"}
+	assert.NoError(t, opts.Validate())
+
+	opts.generateConfigs = nil
+	opts.WithObjects(&unstructured.Unstructured{})
+	assert.NoError(t, opts.Validate())
+}
+
+func TestGenerateObjects(t *testing.T) {
+	t.Setenv("MLP_GENERATED_VALUE", "value")
+
+	configPath := filepath.Join(t.TempDir(), "generate.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`apiVersion: mlp.mia-platform.eu/v2
+kind: GenerateConfiguration
+configMaps:
+- name: generated
+  data:
+  - from: literal
+    key: key
+    value: "{{GENERATED_VALUE}}"
+`), 0600))
+
+	options := &Options{
+		generateConfigs:  []string{configPath},
+		generatePrefixes: []string{"MLP_"},
+	}
+	require.NoError(t, options.generateObjects(context.TODO()))
+	require.Len(t, options.objects, 1)
+	assert.Equal(t, "generated", options.objects[0].GetName())
+	data, _, err := unstructured.NestedStringMap(options.objects[0].Object, "data")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"key": "value"}, data)
+	assert.Empty(t, options.generateConfigs, "the resources must be generated only once")
+
+	require.NoError(t, options.generateObjects(context.TODO()))
+	assert.Len(t, options.objects, 1)
+
+	options.generateConfigs = []string{filepath.Join(t.TempDir(), "missing.yaml")}
+	assert.ErrorContains(t, options.generateObjects(context.TODO()), "failed to generate the resources")
+}
+
+func TestNewOptions(t *testing.T) {
+	t.Parallel()
+
+	offline := map[string]string{offlineFlagName: "true", kubeVersionFlagName: "1.30.0"}
+	opts, err := NewOptions(context.TODO(), genericclioptions.NewConfigFlags(false), []string{"manifests"}, offline, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"manifests"}, opts.inputPaths)
+	assert.Equal(t, deployTypeDefaultValue, opts.deployType)
+	assert.Equal(t, ensureNamespaceDefaultValue, opts.ensureNamespace)
+	assert.Equal(t, healthCheckTimeoutDefaultValue, opts.healthCheckTimeout)
+	assert.Equal(t, history.DefaultLimit, opts.historyLimit)
+	assert.Equal(t, config.DefaultFileName, opts.projectConfigPath)
+	assert.Empty(t, opts.releaseName)
+	assert.NoError(t, opts.Validate())
+
+	path := filepath.Join(t.TempDir(), "mlp.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`deploy:
+  profiles:
+    production:
+      deploy-type: smart_deploy
+      health-check-timeout: 10m
+`), 0600))
+	ctx := config.NewContext(context.TODO(), path)
+	flagValues := map[string]string{
+		offlineFlagName:            "true",
+		kubeVersionFlagName:        "1.30.0",
+		profileFlagName:            "production",
+		healthCheckTimeoutFlagName: "1m",
+		releaseNameFlagName:        "smoke-tests",
+	}
+	opts, err = NewOptions(ctx, genericclioptions.NewConfigFlags(false), []string{"manifests"}, flagValues, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "smart_deploy", opts.deployType)
+	assert.Equal(t, time.Minute, opts.healthCheckTimeout)
+	assert.Equal(t, path, opts.projectConfigPath)
+	assert.Equal(t, "smoke-tests", opts.releaseName)
+
+	_, err = NewOptions(context.TODO(), genericclioptions.NewConfigFlags(false), nil, map[string]string{"unknown": "value"}, io.Discard)
+	assert.ErrorContains(t, err, `setting "unknown" flag`)
+
+	_, err = NewOptions(context.TODO(), nil, []string{"manifests"}, offline, io.Discard)
+	assert.ErrorContains(t, err, "config flags are required")
+}
+
+func TestHistoryRecord(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, time.January, 1, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	options := &Options{
+		actor:        "user",
+		gitSHA:       "0123456789abcdef",
+		fieldManager: fieldManager,
+		clock:        clocktesting.NewFakePassiveClock(start.Add(42 * time.Second)),
+	}
+
+	collector := &summaryCollector{start: start, applied: []string{"ConfigMap/example", "Deployment.apps/example"}}
+	assert.Equal(t, history.Record{
+		Timestamp:    start.Add(42 * time.Second).UTC(),
+		Actor:        "user",
+		Commit:       "0123456789abcdef",
+		FieldManager: fieldManager,
+		Applied:      2,
+		Duration:     "42s",
+		Result:       history.ResultSucceeded,
+	}, options.historyRecord(collector))
+
+	collector.pruned = []string{"Secret/example"}
+	collector.failures = []string{"error"}
+	record := options.historyRecord(collector)
+	assert.Equal(t, 1, record.Pruned)
+	assert.Equal(t, 1, record.Failed)
+	assert.Equal(t, history.ResultFailed, record.Result)
+
+	// disabled history or dry run must not contact the cluster
+	assert.NoError(t, options.saveHistory(context.TODO(), "namespace", collector))
+	options.historyLimit = history.DefaultLimit
+	options.dryRun = true
+	assert.NoError(t, options.saveHistory(context.TODO(), "namespace", collector))
+}
+
+func TestInventoryNameFor(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, inventoryName, inventoryNameFor(fieldManager, ""))
+	assert.Equal(t, "eu.mia-platform.mlp.pipeline", inventoryNameFor("pipeline", ""))
+	assert.Equal(t, "eu.mia-platform.mlp.frontend", inventoryNameFor(fieldManager, "frontend"))
+	assert.Equal(t, "eu.mia-platform.mlp.pipeline.frontend", inventoryNameFor("pipeline", "frontend"))
+}
+
+func TestGeneratedInventoryNameFor(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, extensions.GeneratedInventoryName, generatedInventoryNameFor("", ""))
+	assert.Equal(t, extensions.GeneratedInventoryName, generatedInventoryNameFor(fieldManager, ""))
+	assert.Equal(t, "eu.mia-platform.mlp.frontend.generated", generatedInventoryNameFor(fieldManager, "frontend"))
+	assert.Equal(t, "eu.mia-platform.mlp.pipeline.frontend.generated", generatedInventoryNameFor("pipeline", "frontend"))
+}
+
+func TestPreloadedObjects(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-preloaded-test"
+	configMap := jpltesting.UnstructuredFromFile(t, filepath.Join("testdata", "resources", "configmap.yaml"))
+	namespacedConfigMap := configMap.DeepCopy()
+	namespacedConfigMap.SetName("other")
+	namespacedConfigMap.SetNamespace("other-namespace")
+	clusterRole := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "ClusterRole",
+		"metadata": map[string]interface{}{
+			"name": "example",
+		},
+	}}
+
+	options := &Options{
+		clientFactory: jpltesting.NewTestClientFactory().WithNamespace(namespace),
+	}
+	options.WithObjects(configMap, namespacedConfigMap, clusterRole)
+
+	objects, err := options.preloadedObjects()
+	require.NoError(t, err)
+	require.Len(t, objects, 3)
+	assert.Equal(t, namespace, objects[0].GetNamespace())
+	assert.Equal(t, "other-namespace", objects[1].GetNamespace())
+	assert.Empty(t, objects[2].GetNamespace())
+	assert.Empty(t, configMap.GetNamespace(), "original object must not be modified")
 }
 
 func TestRun(t *testing.T) {
@@ -146,10 +483,11 @@ func TestRun(t *testing.T) {
 	}{
 		"apply objects": {
 			options: &Options{
-				inputPaths: []string{filepath.Join(testdata, "resources")},
-				deployType: "deploy_all",
-				dryRun:     true,
-				clock:      fakeClock,
+				inputPaths:   []string{filepath.Join(testdata, "resources")},
+				deployType:   "deploy_all",
+				dryRun:       true,
+				fieldManager: fieldManager,
+				clock:        fakeClock,
 			},
 			timeout: 1 * time.Second,
 			expectedResources: []*resourceValidation{
@@ -188,10 +526,11 @@ func TestRun(t *testing.T) {
 		},
 		"error reading files": {
 			options: &Options{
-				inputPaths: []string{filepath.Join(testdata, "missing.yaml")},
-				deployType: "deploy_all",
-				dryRun:     true,
-				clock:      fakeClock,
+				inputPaths:   []string{filepath.Join(testdata, "missing.yaml")},
+				deployType:   "deploy_all",
+				dryRun:       true,
+				fieldManager: fieldManager,
+				clock:        fakeClock,
 			},
 			timeout:             1 * time.Second,
 			expectedResources:   []*resourceValidation{},
@@ -200,10 +539,11 @@ func TestRun(t *testing.T) {
 		},
 		"error with timeout context": {
 			options: &Options{
-				inputPaths: []string{filepath.Join(testdata, "resources")},
-				deployType: "deploy_all",
-				dryRun:     true,
-				clock:      fakeClock,
+				inputPaths:   []string{filepath.Join(testdata, "resources")},
+				deployType:   "deploy_all",
+				dryRun:       true,
+				fieldManager: fieldManager,
+				clock:        fakeClock,
 			},
 			timeout:             0 * time.Millisecond,
 			expectedResources:   []*resourceValidation{},
@@ -277,10 +617,11 @@ func TestApplyingEncounteringErrors(t *testing.T) {
 	configMapPath := fmt.Sprintf("/api/v1/namespaces/%s/configmaps/%s", namespace, inventoryName)
 	fakeClock := clocktesting.NewFakePassiveClock(time.Date(1970, time.January, 0, 0, 0, 0, 0, time.UTC))
 	options := &Options{
-		inputPaths: []string{filepath.Join(testdata, "error-resources")},
-		deployType: "deploy_all",
-		dryRun:     true,
-		clock:      fakeClock,
+		inputPaths:   []string{filepath.Join(testdata, "error-resources")},
+		deployType:   "deploy_all",
+		dryRun:       true,
+		fieldManager: fieldManager,
+		clock:        fakeClock,
 	}
 	timeout := 1 * time.Second
 	secret := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "resources", "secret.yaml"))
@@ -330,6 +671,412 @@ func TestApplyingEncounteringErrors(t *testing.T) {
 	t.Log(stringBuilder.String())
 }
 
+func TestEnsuringNamespace(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-ensure-namespace-test"
+	namespacePath := fmt.Sprintf("/api/v1/namespaces/%s", namespace)
+	codec := jpltesting.Codecs.LegacyCodec(jpltesting.Scheme.PrioritizedVersionsAllGroups()...)
+	fastBackoff := wait.Backoff{Duration: time.Millisecond, Steps: 3}
+
+	namespaceResponse := func(phase corev1.NamespacePhase) *http.Response {
+		ns := &corev1.Namespace{Status: corev1.NamespaceStatus{Phase: phase}}
+		ns.SetName(namespace)
+		body := io.NopCloser(bytes.NewReader([]byte(runtime.EncodeOrDie(codec, ns))))
+		return &http.Response{StatusCode: http.StatusOK, Body: body, Header: jpltesting.DefaultHeaders()}
+	}
+
+	tests := map[string]struct {
+		options       *Options
+		getResponses  []int
+		expectedApply bool
+		expectedError string
+	}{
+		"missing namespace is created": {
+			options:       &Options{ensureNamespace: true},
+			getResponses:  []int{http.StatusNotFound},
+			expectedApply: true,
+		},
+		"active namespace is applied": {
+			options:       &Options{ensureNamespace: true},
+			getResponses:  []int{http.StatusOK},
+			expectedApply: true,
+		},
+		"forbidden read of namespace is ignored": {
+			options:       &Options{ensureNamespace: true},
+			getResponses:  []int{http.StatusForbidden},
+			expectedApply: true,
+		},
+		"terminating namespace fail fast": {
+			options:       &Options{ensureNamespace: true},
+			getResponses:  []int{http.StatusConflict},
+			expectedError: `namespace "mlp-ensure-namespace-test" is terminating`,
+		},
+		"wait for terminating namespace": {
+			options: &Options{
+				ensureNamespace:          true,
+				waitNamespaceTermination: true,
+				namespaceBackoff:         fastBackoff,
+			},
+			getResponses:  []int{http.StatusConflict, http.StatusConflict, http.StatusNotFound},
+			expectedApply: true,
+		},
+		"timeout waiting for terminating namespace": {
+			options: &Options{
+				ensureNamespace:          true,
+				waitNamespaceTermination: true,
+				namespaceBackoff:         fastBackoff,
+			},
+			getResponses:  []int{http.StatusConflict},
+			expectedError: `timed out waiting for namespace "mlp-ensure-namespace-test" termination`,
+		},
+		"ensure namespace disabled": {
+			options: &Options{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			getCalls := 0
+			applied := false
+			tf := jpltesting.NewTestClientFactory().
+				WithNamespace(namespace)
+			tf.Client = &restfake.RESTClient{
+				NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+				Client: restfake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+					switch {
+					case r.URL.Path == namespacePath && r.Method == http.MethodGet:
+						// StatusConflict is used as a placeholder for a namespace in Terminating phase
+						status := test.getResponses[min(getCalls, len(test.getResponses)-1)]
+						getCalls++
+						switch status {
+						case http.StatusOK:
+							return namespaceResponse(corev1.NamespaceActive), nil
+						case http.StatusConflict:
+							return namespaceResponse(corev1.NamespaceTerminating), nil
+						default:
+							return &http.Response{StatusCode: status, Header: jpltesting.DefaultHeaders()}, nil
+						}
+					case r.URL.Path == namespacePath && r.Method == http.MethodPatch:
+						applied = true
+						return namespaceResponse(corev1.NamespaceActive), nil
+					}
+
+					return nil, fmt.Errorf("unexpected call: %q, method %s", r.URL.Path, r.Method)
+				}),
+			}
+			test.options.clientFactory = tf
+
+			err := test.options.ensuringNamespace(context.TODO(), namespace)
+			assert.Equal(t, test.expectedApply, applied)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestMutators(t *testing.T) {
+	t.Parallel()
+
+	testdata := "testdata"
+	fakeClock := clocktesting.NewFakePassiveClock(time.Date(1970, time.January, 0, 0, 0, 0, 0, time.UTC))
+
+	tests := map[string]struct {
+		normalize         bool
+		releaseName       string
+		labels            map[string]string
+		projectConfigPath string
+		expectedMutators  int
+		expectedError     string
+	}{
+		"default mutators": {
+			expectedMutators: 4,
+		},
+		"workload defaults mutator": {
+			projectConfigPath: filepath.Join(testdata, "project-config", "workload-defaults-mlp.yaml"),
+			expectedMutators:  5,
+		},
+		"normalize mutator": {
+			normalize:        true,
+			expectedMutators: 5,
+		},
+		"release mutator": {
+			releaseName:      "frontend",
+			expectedMutators: 5,
+		},
+		"metadata mutator": {
+			labels:           map[string]string{"team": "payments"},
+			expectedMutators: 5,
+		},
+		"invalid workload defaults in project configuration": {
+			projectConfigPath: filepath.Join(testdata, "project-config", "invalid-workload-defaults-mlp.yaml"),
+			expectedError:     `error unmarshaling JSON: while decoding JSON: json: unknown field "unknownField"`,
+		},
+		"workloads from project configuration": {
+			projectConfigPath: filepath.Join(testdata, "project-config", "mlp.yaml"),
+			expectedMutators:  4,
+		},
+		"invalid workloads in project configuration": {
+			projectConfigPath: filepath.Join(testdata, "project-config", "invalid-workloads-mlp.yaml"),
+			expectedError:     `invalid pod template path "spec..template" for workload Workload.example.com`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options := &Options{
+				deployType:        "deploy_all",
+				normalize:         test.normalize,
+				releaseName:       test.releaseName,
+				labels:            test.labels,
+				projectConfigPath: test.projectConfigPath,
+				clock:             fakeClock,
+			}
+
+			mutators, err := options.mutators(nil)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+			assert.Len(t, mutators, test.expectedMutators)
+		})
+	}
+}
+
+func TestStatusCheckers(t *testing.T) {
+	t.Parallel()
+
+	certificateGK := schema.GroupKind{Group: "cert-manager.io", Kind: "Certificate"}
+	customGK := schema.GroupKind{Group: "example.com", Kind: "Custom"}
+	jobGK := schema.GroupKind{Group: "batch", Kind: "Job"}
+	tests := map[string]struct {
+		projectConfigPath string
+		expectedCheckers  []schema.GroupKind
+		expectedError     string
+	}{
+		"without project configuration": {
+			expectedCheckers: []schema.GroupKind{certificateGK, extensions.IngressGK, extensions.HTTPRouteGK, jobGK},
+		},
+		"missing project configuration": {
+			projectConfigPath: filepath.Join("testdata", "missing.yaml"),
+			expectedCheckers:  []schema.GroupKind{certificateGK, jobGK},
+		},
+		"readiness from project configuration": {
+			projectConfigPath: filepath.Join("testdata", "project-config", "mlp.yaml"),
+			expectedCheckers:  []schema.GroupKind{certificateGK, customGK},
+		},
+		"invalid readiness in project configuration": {
+			projectConfigPath: filepath.Join("testdata", "project-config", "invalid-mlp.yaml"),
+			expectedError:     "invalid ready expression for Custom.example.com",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options := &Options{projectConfigPath: test.projectConfigPath}
+			checkers, err := options.statusCheckers()
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				for _, gk := range test.expectedCheckers {
+					assert.Contains(t, checkers, gk)
+				}
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestTrackRemovedGeneratedResources(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-generated-inventory-test"
+	codec := jpltesting.Codecs.LegacyCodec(jpltesting.Scheme.PrioritizedVersionsAllGroups()...)
+	currentInventory := func() *unstructured.Unstructured {
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(extensions.NewGeneratedInventory([]jplresource.ObjectMetadata{
+			{Name: "kept", Kind: "Secret"},
+		}))
+		require.NoError(t, err)
+		obj := &unstructured.Unstructured{Object: data}
+		obj.SetNamespace(namespace)
+		return obj
+	}
+
+	tests := map[string]struct {
+		resources       []*unstructured.Unstructured
+		releaseName     string
+		remoteInventory *corev1.ConfigMap
+		remoteStatus    int
+		expectedName    string
+		expectedTracked []jplresource.ObjectMetadata
+		expectedError   string
+	}{
+		"no generated inventory in resources": {
+			expectedTracked: []jplresource.ObjectMetadata{},
+		},
+		"missing remote generated inventory": {
+			resources:       []*unstructured.Unstructured{currentInventory()},
+			remoteStatus:    http.StatusNotFound,
+			expectedTracked: []jplresource.ObjectMetadata{},
+		},
+		"removed resources are tracked": {
+			resources: []*unstructured.Unstructured{currentInventory()},
+			remoteInventory: extensions.NewGeneratedInventory([]jplresource.ObjectMetadata{
+				{Name: "kept", Kind: "Secret"},
+				{Name: "removed", Kind: "ConfigMap"},
+			}),
+			remoteStatus: http.StatusOK,
+			expectedName: extensions.GeneratedInventoryName,
+			expectedTracked: []jplresource.ObjectMetadata{
+				{Name: "removed", Namespace: namespace, Kind: "ConfigMap"},
+			},
+		},
+		"generated inventory of a release": {
+			resources:   []*unstructured.Unstructured{currentInventory()},
+			releaseName: "frontend",
+			remoteInventory: extensions.NewGeneratedInventory([]jplresource.ObjectMetadata{
+				{Name: "removed", Kind: "Secret"},
+			}),
+			remoteStatus: http.StatusOK,
+			expectedName: "eu.mia-platform.mlp.frontend.generated",
+			expectedTracked: []jplresource.ObjectMetadata{
+				{Name: "removed", Namespace: namespace, Kind: "Secret"},
+			},
+		},
+		"error reading remote generated inventory": {
+			resources:     []*unstructured.Unstructured{currentInventory()},
+			remoteStatus:  http.StatusInternalServerError,
+			expectedError: "failed to read generated inventory",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tf := jpltesting.NewTestClientFactory().
+				WithNamespace(namespace)
+			tf.Client = &restfake.RESTClient{
+				NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+				Client: restfake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+					generatedInventoryPath := fmt.Sprintf("/api/v1/namespaces/%s/configmaps/%s", namespace, generatedInventoryNameFor(fieldManager, test.releaseName))
+					if r.URL.Path != generatedInventoryPath || r.Method != http.MethodGet {
+						return nil, fmt.Errorf("unexpected call: %q, method %s", r.URL.Path, r.Method)
+					}
+
+					response := &http.Response{StatusCode: test.remoteStatus, Header: jpltesting.DefaultHeaders()}
+					if test.remoteInventory != nil {
+						body := []byte(runtime.EncodeOrDie(codec, test.remoteInventory))
+						response.Body = io.NopCloser(bytes.NewReader(body))
+					}
+					return response, nil
+				}),
+			}
+
+			inventory, err := NewInventory(tf, inventoryName, namespace, fieldManager, InventoryBackendConfigMap)
+			require.NoError(t, err)
+
+			options := &Options{clientFactory: tf, fieldManager: fieldManager, releaseName: test.releaseName}
+			err = options.trackRemovedGeneratedResources(context.TODO(), inventory, test.resources)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.ElementsMatch(t, test.expectedTracked, inventory.trackedObjects.UnsortedList())
+				if len(test.expectedName) > 0 {
+					assert.Equal(t, test.expectedName, test.resources[0].GetName())
+				}
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestFilesToRead(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile(filepath.Join("/resources", "deployment.yaml"), []byte("kind: Deployment")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("/resources", "service.yml"), []byte("kind: Service")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("/resources", "README.md"), []byte("# resources")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("/resources", "secret.example.yaml"), []byte("kind: Secret")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("/resources", "partials", "container.yaml"), []byte("name: app")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("/resources", ignore.FileName), []byte("*.example.yaml\npartials/\n")))
+
+	tests := map[string]struct {
+		path          string
+		expectedPaths []string
+	}{
+		"stdin": {
+			path:          stdinToken,
+			expectedPaths: []string{stdinToken},
+		},
+		"file": {
+			path:          filepath.Join("/resources", "secret.example.yaml"),
+			expectedPaths: []string{filepath.Join("/resources", "secret.example.yaml")},
+		},
+		"folder with ignore file": {
+			path: "/resources",
+			expectedPaths: []string{
+				filepath.Join("/resources", "deployment.yaml"),
+				filepath.Join("/resources", "service.yml"),
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			paths, err := filesToRead(fSys, test.path)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPaths, paths)
+		})
+	}
+}
+
+func TestCheckStdinDocuments(t *testing.T) {
+	t.Parallel()
+
+	data := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: [1
+`
+	_, err := checkStdinDocuments(strings.NewReader(data))
+	assert.ErrorContains(t, err, "reading resources from stdin: decoding document 1 (Deployment/api)")
+
+	valid := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: first\n"
+	reader, err := checkStdinDocuments(strings.NewReader(valid))
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, valid, string(content))
+
+	_, err = checkStdinDocuments(nil)
+	assert.ErrorContains(t, err, "cannot read resources from stdin")
+}
+
 func validationRoundTripper(t *testing.T, resources []*resourceValidation, r *http.Request) (*http.Response, error) {
 	t.Helper()
 	path := r.URL.Path
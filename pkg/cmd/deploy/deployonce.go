@@ -0,0 +1,38 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+)
+
+const (
+	// deployOnceAction is the action reported for the resources that are deployed only once and are not applied
+	// because they already exist
+	deployOnceAction = "skipped (deploy-once)"
+	// deployOnceMessage explain why a deploy once resource has not been applied
+	deployOnceMessage = "already exists and is not updated"
+)
+
+// isDeployOnceSkip return true if e reports a resource skipped because it is deployed only once and already
+// exists in the cluster
+func isDeployOnceSkip(e event.Event) bool {
+	return e.Type == event.TypeApply &&
+		e.ApplyInfo.Status == event.StatusSkipped &&
+		e.ApplyInfo.Object != nil &&
+		extensions.IsDeployOnce(e.ApplyInfo.Object)
+}
@@ -0,0 +1,67 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestIsDeployOnceSkip(t *testing.T) {
+	t.Parallel()
+
+	deployOnce := &unstructured.Unstructured{}
+	deployOnce.SetAPIVersion("v1")
+	deployOnce.SetKind("Secret")
+	deployOnce.SetName("example")
+	deployOnce.SetAnnotations(map[string]string{"mia-platform.eu/deploy": "once"})
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName("example")
+
+	tests := map[string]struct {
+		event    event.Event
+		expected bool
+	}{
+		"skipped deploy once resource": {
+			event:    event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: deployOnce, Status: event.StatusSkipped}},
+			expected: true,
+		},
+		"applied deploy once resource": {
+			event:    event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: deployOnce, Status: event.StatusSuccessful}},
+			expected: false,
+		},
+		"skipped resource without annotation": {
+			event:    event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: configMap, Status: event.StatusSkipped}},
+			expected: false,
+		},
+		"prune event": {
+			event:    event.Event{Type: event.TypePrune, PruneInfo: event.PruneInfo{Object: deployOnce, Status: event.StatusSkipped}},
+			expected: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, isDeployOnceSkip(test.event))
+		})
+	}
+}
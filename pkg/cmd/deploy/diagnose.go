@@ -0,0 +1,320 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// diagnosisEventsLimit is the number of most recent events printed for every resource
+	diagnosisEventsLimit = 5
+	// diagnosisPodsLimit is the number of not ready pods printed for every workload
+	diagnosisPodsLimit = 3
+	// diagnosisMessageLimit is the maximum length of the messages printed in the diagnosis
+	diagnosisMessageLimit = 200
+)
+
+// healthTracker keep track of the resources that have been applied and are not healthy, for diagnosing them
+// at the end of the deploy
+type healthTracker struct {
+	waiting map[resource.ObjectMetadata]string
+	failed  map[resource.ObjectMetadata]string
+	order   []resource.ObjectMetadata
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		waiting: make(map[resource.ObjectMetadata]string),
+		failed:  make(map[resource.ObjectMetadata]string),
+	}
+}
+
+// Track update the health state with the event e
+func (t *healthTracker) Track(e event.Event) {
+	switch e.Type {
+	case event.TypeApply:
+		if e.ApplyInfo.Status != event.StatusSuccessful {
+			return
+		}
+		t.setWaiting(resource.ObjectMetadataFromUnstructured(e.ApplyInfo.Object), "")
+	case event.TypeStatusUpdate:
+		objMeta := e.StatusUpdateInfo.ObjectMetadata
+		switch e.StatusUpdateInfo.Status {
+		case event.StatusSuccessful:
+			delete(t.waiting, objMeta)
+			delete(t.failed, objMeta)
+		case event.StatusFailed:
+			delete(t.waiting, objMeta)
+			t.failed[objMeta] = e.StatusUpdateInfo.Message
+		default:
+			t.setWaiting(objMeta, e.StatusUpdateInfo.Message)
+		}
+	}
+}
+
+func (t *healthTracker) setWaiting(objMeta resource.ObjectMetadata, message string) {
+	if !slices.Contains(t.order, objMeta) {
+		t.order = append(t.order, objMeta)
+	}
+	delete(t.failed, objMeta)
+	t.waiting[objMeta] = message
+}
+
+// Unhealthy return the resources that have failed their status check, and if includeWaiting is true also the ones
+// that have never become healthy, in the order they have been applied
+func (t *healthTracker) Unhealthy(includeWaiting bool) []resource.ObjectMetadata {
+	unhealthy := make([]resource.ObjectMetadata, 0)
+	for _, objMeta := range t.order {
+		_, failed := t.failed[objMeta]
+		_, waiting := t.waiting[objMeta]
+		if failed || (includeWaiting && waiting) {
+			unhealthy = append(unhealthy, objMeta)
+		}
+	}
+	return unhealthy
+}
+
+// printDiagnoses write a diagnostic block for every resource in objMetas, with its conditions, its recent events
+// and for workloads the status of their pods that are not ready
+func (o *Options) printDiagnoses(ctx context.Context, objMetas []resource.ObjectMetadata) error {
+	if len(objMetas) == 0 {
+		return nil
+	}
+
+	client, err := o.clientFactory.DynamicClient()
+	if err != nil {
+		return err
+	}
+	mapper, err := o.clientFactory.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+	clientSet, err := o.clientFactory.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+
+	for _, objMeta := range objMetas {
+		fmt.Fprintf(o.writer, "diagnosis for %s:\n", summaryIdentifier(objMeta))
+
+		mapping, err := mapper.RESTMapping(schema.GroupKind{Group: objMeta.Group, Kind: objMeta.Kind})
+		if err != nil {
+			fmt.Fprintf(o.writer, "\tcannot find resource type: %s\n", err)
+			continue
+		}
+
+		resourceClient := client.Resource(mapping.Resource).Namespace(objMeta.Namespace)
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			resourceClient = client.Resource(mapping.Resource)
+		}
+		obj, err := resourceClient.Get(ctx, objMeta.Name, metav1.GetOptions{})
+		if err != nil {
+			fmt.Fprintf(o.writer, "\tcannot read resource: %s\n", err)
+			continue
+		}
+
+		writeConditions(o.writer, obj)
+
+		eventsNamespace := eventNamespace(objMeta.Namespace)
+		selector := fields.AndSelectors(
+			fields.OneTermEqualSelector("involvedObject.name", objMeta.Name),
+			fields.OneTermEqualSelector("involvedObject.kind", objMeta.Kind),
+		).String()
+		events, err := clientSet.CoreV1().Events(eventsNamespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+		if err != nil {
+			fmt.Fprintf(o.writer, "\tcannot list events: %s\n", err)
+		} else {
+			writeEvents(o.writer, events.Items)
+		}
+
+		podSelector, found := workloadPodSelector(obj)
+		if !found {
+			continue
+		}
+		pods, err := clientSet.CoreV1().Pods(objMeta.Namespace).List(ctx, metav1.ListOptions{LabelSelector: podSelector})
+		if err != nil {
+			fmt.Fprintf(o.writer, "\tcannot list pods: %s\n", err)
+			continue
+		}
+		writePods(o.writer, pods.Items)
+	}
+
+	return nil
+}
+
+// eventNamespace return the namespace where the events of a resource are saved, the cluster scoped resources
+// have their events in the default namespace
+func eventNamespace(namespace string) string {
+	if len(namespace) == 0 {
+		return metav1.NamespaceDefault
+	}
+	return namespace
+}
+
+// writeConditions print the conditions found in the status of obj
+func writeConditions(writer io.Writer, obj *unstructured.Unstructured) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if len(conditions) == 0 {
+		return
+	}
+
+	fmt.Fprintln(writer, "\tconditions:")
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		conditionType, _, _ := unstructured.NestedString(conditionMap, "type")
+		status, _, _ := unstructured.NestedString(conditionMap, "status")
+		reason, _, _ := unstructured.NestedString(conditionMap, "reason")
+		message, _, _ := unstructured.NestedString(conditionMap, "message")
+		fmt.Fprintf(writer, "\t\t%s=%s%s\n", conditionType, status, reasonAndMessage(reason, message))
+	}
+}
+
+// writeEvents print the most recent events in events
+func writeEvents(writer io.Writer, events []corev1.Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	slices.SortStableFunc(events, func(a, b corev1.Event) int {
+		return eventTime(a).Compare(eventTime(b).Time)
+	})
+	if len(events) > diagnosisEventsLimit {
+		events = events[len(events)-diagnosisEventsLimit:]
+	}
+
+	fmt.Fprintln(writer, "\tevents:")
+	for _, event := range events {
+		count := ""
+		if event.Count > 1 {
+			count = fmt.Sprintf(" (x%d)", event.Count)
+		}
+		fmt.Fprintf(writer, "\t\t%s%s%s\n", event.Type, count, reasonAndMessage(event.Reason, event.Message))
+	}
+}
+
+// eventTime return the most accurate time of the last occurrence of event
+func eventTime(event corev1.Event) metav1.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp
+	case !event.EventTime.IsZero():
+		return metav1.Time{Time: event.EventTime.Time}
+	default:
+		return event.CreationTimestamp
+	}
+}
+
+// workloadPodSelector return the label selector of the pods managed by obj, if it is a workload with a pod template
+func workloadPodSelector(obj *unstructured.Unstructured) (string, bool) {
+	if _, found, _ := unstructured.NestedMap(obj.Object, "spec", "template"); !found {
+		return "", false
+	}
+
+	unstructuredSelector, found, _ := unstructured.NestedMap(obj.Object, "spec", "selector")
+	if !found {
+		return "", false
+	}
+
+	labelSelector := new(metav1.LabelSelector)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredSelector, labelSelector); err != nil {
+		return "", false
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil || selector.Empty() {
+		return "", false
+	}
+	return selector.String(), true
+}
+
+// writePods print the status of the containers of the pods that are not ready
+func writePods(writer io.Writer, pods []corev1.Pod) {
+	notReady := make([]corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if !isPodReady(pod) {
+			notReady = append(notReady, pod)
+		}
+	}
+	if len(notReady) == 0 {
+		return
+	}
+
+	fmt.Fprintf(writer, "\tpods not ready: %d of %d\n", len(notReady), len(pods))
+	for _, pod := range notReady[:min(len(notReady), diagnosisPodsLimit)] {
+		fmt.Fprintf(writer, "\t\t%s %s%s\n", pod.Name, pod.Status.Phase, reasonAndMessage(pod.Status.Reason, pod.Status.Message))
+		for _, status := range slices.Concat(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses) {
+			if status.Ready {
+				continue
+			}
+
+			if waiting := status.State.Waiting; waiting != nil {
+				fmt.Fprintf(writer, "\t\t\tcontainer %s waiting%s\n", status.Name, reasonAndMessage(waiting.Reason, waiting.Message))
+			}
+			if terminated := status.LastTerminationState.Terminated; terminated != nil {
+				fmt.Fprintf(writer, "\t\t\tcontainer %s last terminated with exit code %d after %d restarts%s\n", status.Name,
+					terminated.ExitCode, status.RestartCount, reasonAndMessage(terminated.Reason, terminated.Message))
+			}
+		}
+	}
+}
+
+// isPodReady return true if pod has the Ready condition set to true
+func isPodReady(pod corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// reasonAndMessage format a reason and a message in a single line, truncating the message if too long
+func reasonAndMessage(reason, message string) string {
+	message = strings.Join(strings.Fields(message), " ")
+	if len(message) > diagnosisMessageLimit {
+		message = message[:diagnosisMessageLimit] + "..."
+	}
+
+	switch {
+	case len(reason) > 0 && len(message) > 0:
+		return fmt.Sprintf(" %s: %s", reason, message)
+	case len(reason) > 0:
+		return " " + reason
+	case len(message) > 0:
+		return ": " + message
+	default:
+		return ""
+	}
+}
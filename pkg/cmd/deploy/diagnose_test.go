@@ -0,0 +1,287 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	jplresource "github.com/mia-platform/jpl/pkg/resource"
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	restfake "k8s.io/client-go/rest/fake"
+)
+
+func TestHealthTracker(t *testing.T) {
+	t.Parallel()
+
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "example", "namespace": "default"},
+	}}
+	configMap := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "example", "namespace": "default"},
+	}}
+	job := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]interface{}{"name": "example", "namespace": "default"},
+	}}
+	deploymentMeta := jplresource.ObjectMetadataFromUnstructured(deployment)
+	configMapMeta := jplresource.ObjectMetadataFromUnstructured(configMap)
+	jobMeta := jplresource.ObjectMetadataFromUnstructured(job)
+
+	tracker := newHealthTracker()
+	for _, obj := range []*unstructured.Unstructured{job, deployment, configMap} {
+		tracker.Track(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: obj, Status: event.StatusSuccessful}})
+	}
+	tracker.Track(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: configMap, Status: event.StatusFailed}})
+	tracker.Track(event.Event{Type: event.TypeStatusUpdate, StatusUpdateInfo: event.StatusUpdateInfo{ObjectMetadata: configMapMeta, Status: event.StatusSuccessful}})
+	tracker.Track(event.Event{Type: event.TypeStatusUpdate, StatusUpdateInfo: event.StatusUpdateInfo{ObjectMetadata: deploymentMeta, Status: event.StatusPending, Message: "0 of 1 replicas ready"}})
+	tracker.Track(event.Event{Type: event.TypeStatusUpdate, StatusUpdateInfo: event.StatusUpdateInfo{ObjectMetadata: jobMeta, Status: event.StatusFailed, Message: "job failed"}})
+
+	assert.Equal(t, []jplresource.ObjectMetadata{jobMeta}, tracker.Unhealthy(false))
+	assert.Equal(t, []jplresource.ObjectMetadata{jobMeta, deploymentMeta}, tracker.Unhealthy(true))
+
+	tracker.Track(event.Event{Type: event.TypeStatusUpdate, StatusUpdateInfo: event.StatusUpdateInfo{ObjectMetadata: deploymentMeta, Status: event.StatusSuccessful}})
+	assert.Equal(t, []jplresource.ObjectMetadata{jobMeta}, tracker.Unhealthy(true))
+}
+
+func TestReasonAndMessage(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		reason   string
+		message  string
+		expected string
+	}{
+		"reason and message": {
+			reason:   "CrashLoopBackOff",
+			message:  "back-off restarting\n failed container",
+			expected: " CrashLoopBackOff: back-off restarting failed container",
+		},
+		"only reason": {
+			reason:   "Completed",
+			expected: " Completed",
+		},
+		"only message": {
+			message:  "something happened",
+			expected: ": something happened",
+		},
+		"empty": {},
+		"long message": {
+			message:  strings.Repeat("a", diagnosisMessageLimit+10),
+			expected: ": " + strings.Repeat("a", diagnosisMessageLimit) + "...",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, reasonAndMessage(test.reason, test.message))
+		})
+	}
+}
+
+func TestWorkloadPodSelector(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		obj              map[string]interface{}
+		expectedSelector string
+		expectedFound    bool
+	}{
+		"deployment": {
+			obj: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "example"}},
+					"template": map[string]interface{}{},
+				},
+			},
+			expectedSelector: "app=example",
+			expectedFound:    true,
+		},
+		"without template": {
+			obj: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"selector": map[string]interface{}{"app": "example"},
+				},
+			},
+		},
+		"without selector": {
+			obj: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{},
+				},
+			},
+		},
+		"empty selector": {
+			obj: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"selector": map[string]interface{}{},
+					"template": map[string]interface{}{},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			selector, found := workloadPodSelector(&unstructured.Unstructured{Object: test.obj})
+			assert.Equal(t, test.expectedSelector, selector)
+			assert.Equal(t, test.expectedFound, found)
+		})
+	}
+}
+
+func TestPrintDiagnoses(t *testing.T) {
+	t.Parallel()
+
+	namespace := "diagnose"
+	codec := jpltesting.Codecs.LegacyCodec(jpltesting.Scheme.PrioritizedVersionsAllGroups()...)
+	now := time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "example", "namespace": namespace},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "example"}},
+			"template": map[string]interface{}{},
+		},
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Available", "status": "False", "reason": "MinimumReplicasUnavailable", "message": "Deployment does not have minimum availability."},
+			},
+		},
+	}}
+
+	events := &corev1.EventList{Items: []corev1.Event{
+		{
+			ObjectMeta:    metav1.ObjectMeta{Name: "older", Namespace: namespace},
+			Type:          corev1.EventTypeNormal,
+			Reason:        "ScalingReplicaSet",
+			Message:       "Scaled up replica set example-1 to 1",
+			LastTimestamp: metav1.NewTime(now.Add(-time.Minute)),
+		},
+		{
+			ObjectMeta:    metav1.ObjectMeta{Name: "newer", Namespace: namespace},
+			Type:          corev1.EventTypeWarning,
+			Reason:        "ProgressDeadlineExceeded",
+			Message:       "ReplicaSet example-1 has timed out progressing.",
+			Count:         2,
+			LastTimestamp: metav1.NewTime(now),
+		},
+	}}
+
+	pods := &corev1.PodList{Items: []corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "example-1-ready", Namespace: namespace},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "example-1-crashing", Namespace: namespace},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}},
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name:         "app",
+						RestartCount: 3,
+						State: corev1.ContainerState{
+							Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off 40s restarting failed container"},
+						},
+						LastTerminationState: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error", Message: "missing configuration"},
+						},
+					},
+					{Name: "sidecar", Ready: true},
+				},
+			},
+		},
+	}}
+
+	tf := jpltesting.NewTestClientFactory().WithNamespace(namespace)
+	tf.FakeDynamicClient = dynamicfake.NewSimpleDynamicClient(jpltesting.Scheme, deployment)
+	tf.Client = &restfake.RESTClient{
+		NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+		Client: restfake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+			header := jpltesting.DefaultHeaders()
+			var obj runtime.Object
+			switch r.URL.Path {
+			case "/api/v1/namespaces/diagnose/events":
+				assert.Equal(t, "involvedObject.name=example,involvedObject.kind=Deployment", r.URL.Query().Get("fieldSelector"))
+				obj = events
+			case "/api/v1/namespaces/diagnose/pods":
+				assert.Equal(t, "app=example", r.URL.Query().Get("labelSelector"))
+				obj = pods
+			default:
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				return &http.Response{StatusCode: http.StatusNotFound, Header: header, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+			}
+			body := []byte(runtime.EncodeOrDie(codec, obj))
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(body))}, nil
+		}),
+	}
+
+	output := new(strings.Builder)
+	options := &Options{
+		clientFactory: tf,
+		writer:        output,
+	}
+
+	missing := jplresource.ObjectMetadata{Name: "missing", Namespace: namespace, Kind: "Deployment", Group: "apps"}
+	err := options.printDiagnoses(context.TODO(), []jplresource.ObjectMetadata{
+		jplresource.ObjectMetadataFromUnstructured(deployment),
+		missing,
+	})
+	require.NoError(t, err)
+
+	expectedOutput := `diagnosis for Deployment.apps/example:
+	conditions:
+		Available=False MinimumReplicasUnavailable: Deployment does not have minimum availability.
+	events:
+		Normal ScalingReplicaSet: Scaled up replica set example-1 to 1
+		Warning (x2) ProgressDeadlineExceeded: ReplicaSet example-1 has timed out progressing.
+	pods not ready: 1 of 2
+		example-1-crashing Running
+			container app waiting CrashLoopBackOff: back-off 40s restarting failed container
+			container app last terminated with exit code 1 after 3 restarts Error: missing configuration
+diagnosis for Deployment.apps/missing:
+	cannot read resource: deployments.apps "missing" not found
+`
+	assert.Equal(t, expectedOutput, output.String())
+}
@@ -0,0 +1,61 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"sync"
+
+	"github.com/mia-platform/jpl/pkg/util"
+	"github.com/mia-platform/mlp/v2/pkg/restmapping"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// tolerantDiscoveryFactory wrap a ClientFactory for returning always the same RESTMapper, that cache the
+// mappings for the whole deploy and ignore the discovery failures of the API groups not used by the resources
+type tolerantDiscoveryFactory struct {
+	util.ClientFactory
+
+	once   sync.Once
+	mapper meta.RESTMapper
+	err    error
+}
+
+// newTolerantDiscoveryFactory return a ClientFactory whose RESTMapper tolerate the unreachable aggregated APIs
+func newTolerantDiscoveryFactory(factory util.ClientFactory) util.ClientFactory {
+	return &tolerantDiscoveryFactory{ClientFactory: factory}
+}
+
+// ToRESTMapper override the ClientFactory method wrapping the returned mapper
+func (f *tolerantDiscoveryFactory) ToRESTMapper() (meta.RESTMapper, error) {
+	f.once.Do(func() {
+		mapper, err := f.ClientFactory.ToRESTMapper()
+		if err != nil {
+			f.err = err
+			return
+		}
+
+		// without a discovery client there is no fallback, so the mapper is used as is
+		client, err := f.ClientFactory.ToDiscoveryClient()
+		if err != nil {
+			f.mapper = mapper
+			return
+		}
+
+		f.mapper = restmapping.NewTolerantRESTMapper(mapper, client, restmapping.NewMemoryCache())
+	})
+
+	return f.mapper, f.err
+}
@@ -0,0 +1,178 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mia-platform/jpl/pkg/util"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	cliresource "k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+const dryRunOutputFileExtension = ".yaml"
+
+// dryRunRecorder keep the objects returned by the server for the dry run apply requests, they contain the
+// default values and the mutations of the admission webhooks that the cluster would store
+type dryRunRecorder struct {
+	lock    sync.Mutex
+	objects map[string]*unstructured.Unstructured
+}
+
+func newDryRunRecorder() *dryRunRecorder {
+	return &dryRunRecorder{objects: make(map[string]*unstructured.Unstructured)}
+}
+
+// record save obj, removing its managed fields that only add noise when compared with the manifests
+func (r *dryRunRecorder) record(obj *unstructured.Unstructured) {
+	obj.SetManagedFields(nil)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.objects[dryRunOutputFileName(obj.GetNamespace(), obj.GetKind(), obj.GetName())] = obj
+}
+
+// write save every recorded object in its own file inside dir and return how many files have been written
+func (r *dryRunRecorder) write(fSys filesys.FileSystem, dir string) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := fSys.MkdirAll(dir); err != nil {
+		return 0, fmt.Errorf("failed to create dry run output directory: %w", err)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(r.objects)) {
+		data, err := yaml.Marshal(r.objects[name].Object)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := fSys.WriteFile(filepath.Join(dir, name), data); err != nil {
+			return 0, fmt.Errorf("failed to write dry run output: %w", err)
+		}
+	}
+
+	return len(r.objects), nil
+}
+
+// dryRunOutputFileName return the name of the file containing an object returned by the dry run
+func dryRunOutputFileName(namespace, kind, name string) string {
+	parts := []string{strings.ToLower(kind), name}
+	if len(namespace) > 0 {
+		parts = append([]string{namespace}, parts...)
+	}
+
+	return strings.Join(parts, "_") + dryRunOutputFileExtension
+}
+
+// dryRunOutputTransport capture the response bodies of the dry run server side apply requests made through next
+type dryRunOutputTransport struct {
+	next     http.RoundTripper
+	recorder *dryRunRecorder
+}
+
+func (t *dryRunOutputTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	response, err := t.next.RoundTrip(req)
+	if err != nil || !isDryRunApply(req) || response.Body == nil {
+		return response, err
+	}
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated {
+		return response, nil
+	}
+
+	data, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return response, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err == nil {
+		t.recorder.record(obj)
+	}
+	return response, nil
+}
+
+// isDryRunApply return true if req is a server side apply request that will not be persisted
+func isDryRunApply(req *http.Request) bool {
+	return req.Method == http.MethodPatch &&
+		req.Header.Get("Content-Type") == string(types.ApplyPatchType) &&
+		slices.Contains(req.URL.Query()["dryRun"], metav1.DryRunAll)
+}
+
+// dryRunOutputFactory wrap a ClientFactory for capturing the objects returned by the dry run apply requests made
+// with the clients it returns
+type dryRunOutputFactory struct {
+	util.ClientFactory
+	recorder *dryRunRecorder
+}
+
+// newDryRunOutputFactory return a ClientFactory that record the dry run objects in recorder
+func newDryRunOutputFactory(factory util.ClientFactory, recorder *dryRunRecorder) util.ClientFactory {
+	return &dryRunOutputFactory{
+		ClientFactory: factory,
+		recorder:      recorder,
+	}
+}
+
+// UnstructuredClientForMapping override the ClientFactory method wrapping the transport of the returned client
+func (f *dryRunOutputFactory) UnstructuredClientForMapping(mapping *meta.RESTMapping) (cliresource.RESTClient, error) {
+	client, err := f.ClientFactory.UnstructuredClientForMapping(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	restClient, ok := client.(*rest.RESTClient)
+	if !ok || restClient.Client == nil {
+		return client, nil
+	}
+
+	httpClient := *restClient.Client
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = &dryRunOutputTransport{next: next, recorder: f.recorder}
+	restClient.Client = &httpClient
+	return restClient, nil
+}
+
+// saveDryRunObjects write the objects returned by the cluster during the dry run in the output directory
+func (o *Options) saveDryRunObjects(recorder *dryRunRecorder) error {
+	count, err := recorder.write(filesys.MakeFsOnDisk(), o.dryRunOutputDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(o.writer, "dry run objects of %d resource(s) saved in %q\n", count, o.dryRunOutputDir)
+	return nil
+}
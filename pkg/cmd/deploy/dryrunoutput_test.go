@@ -0,0 +1,125 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestDryRunOutputFactory(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("dryRun") == metav1.DryRunAll {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"example","namespace":"default","managedFields":[{"manager":"mlp"}]},"spec":{"replicas":1,"revisionHistoryLimit":10}}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"persisted","namespace":"default"}}`))
+	}))
+	defer server.Close()
+
+	recorder := newDryRunRecorder()
+	factory := newDryRunOutputFactory(&restClientFactory{host: server.URL}, recorder)
+	client, err := factory.UnstructuredClientForMapping(&meta.RESTMapping{
+		GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+	})
+	require.NoError(t, err)
+
+	body := []byte(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"example","namespace":"default"}}`)
+	err = client.Patch(types.ApplyPatchType).Namespace("default").Resource("deployments").Name("example").
+		Param("dryRun", metav1.DryRunAll).Body(body).Do(context.TODO()).Error()
+	require.NoError(t, err)
+	err = client.Patch(types.ApplyPatchType).Namespace("default").Resource("deployments").Name("persisted").
+		Body(body).Do(context.TODO()).Error()
+	require.NoError(t, err)
+
+	fSys := filesys.MakeFsInMemory()
+	dir := filepath.Join("output", "dry-run")
+	count, err := recorder.write(fSys, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	data, err := fSys.ReadFile(filepath.Join(dir, "default_deployment_example.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: example
+  namespace: default
+spec:
+  replicas: 1
+  revisionHistoryLimit: 10
+`, string(data))
+	assert.False(t, fSys.Exists(filepath.Join(dir, "default_deployment_persisted.yaml")))
+}
+
+func TestDryRunOutputFileName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace    string
+		kind         string
+		name         string
+		expectedName string
+	}{
+		"namespaced resource": {
+			namespace:    "default",
+			kind:         "ConfigMap",
+			name:         "example",
+			expectedName: "default_configmap_example.yaml",
+		},
+		"cluster resource": {
+			kind:         "ClusterRole",
+			name:         "example",
+			expectedName: "clusterrole_example.yaml",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expectedName, dryRunOutputFileName(test.namespace, test.kind, test.name))
+		})
+	}
+}
+
+func TestSaveDryRunObjects(t *testing.T) {
+	t.Parallel()
+
+	output := new(strings.Builder)
+	dir := filepath.Join(t.TempDir(), "dry-run")
+	options := &Options{dryRunOutputDir: dir, writer: output}
+	require.NoError(t, options.saveDryRunObjects(newDryRunRecorder()))
+	assert.DirExists(t, dir)
+	assert.Equal(t, "dry run objects of 0 resource(s) saved in \""+dir+"\"\n", output.String())
+}
@@ -0,0 +1,121 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	showFailed    = "failed"
+	showChanged   = "changed"
+	showUnchanged = "unchanged"
+	showSkipped   = "skipped"
+	showPruned    = "pruned"
+	showProgress  = "progress"
+)
+
+var validShowValues = []string{showFailed, showChanged, showUnchanged, showSkipped, showPruned, showProgress}
+
+// validateShow return an error if the events filters set via flags are not valid
+func (o *Options) validateShow() error {
+	if o.quiet && len(o.show) > 0 {
+		return fmt.Errorf("the %q and %q flags cannot be used together", quietFlagName, showFlagName)
+	}
+
+	for _, show := range o.show {
+		if !slices.Contains(validShowValues, show) {
+			return fmt.Errorf("invalid %q value %q, valid values are %s", showFlagName, show, strings.Join(validShowValues, ", "))
+		}
+	}
+
+	return nil
+}
+
+// shownEvents return the categories of the events to print during the deploy, or nil if all of them are printed
+func (o *Options) shownEvents() []string {
+	if o.quiet {
+		return []string{showFailed}
+	}
+	return o.show
+}
+
+// filteredPrinter forward to the next eventPrinter only the events of the categories to show
+type filteredPrinter struct {
+	next    eventPrinter
+	show    sets.Set[string]
+	metrics *metricsRecorder
+}
+
+// newFilteredPrinter return an eventPrinter that print with next only the events of the show categories, metrics
+// is used for telling apart the resources changed by the apply from the unchanged ones
+func newFilteredPrinter(next eventPrinter, show []string, metrics *metricsRecorder) eventPrinter {
+	return &filteredPrinter{next: next, show: sets.New(show...), metrics: metrics}
+}
+
+// PrintEvent implement eventPrinter interface
+func (p *filteredPrinter) PrintEvent(e event.Event) {
+	if p.show.Has(p.category(e)) {
+		p.next.PrintEvent(e)
+	}
+}
+
+// Flush implement eventPrinter interface
+func (p *filteredPrinter) Flush() {
+	p.next.Flush()
+}
+
+// category return the category of e used for filtering it
+func (p *filteredPrinter) category(e event.Event) string {
+	if e.IsErrorEvent() {
+		return showFailed
+	}
+
+	switch e.Type {
+	case event.TypeApply:
+		switch e.ApplyInfo.Status {
+		case event.StatusFailed:
+			return showFailed
+		case event.StatusSkipped:
+			return showSkipped
+		case event.StatusSuccessful:
+			identifier := summaryIdentifier(resource.ObjectMetadataFromUnstructured(e.ApplyInfo.Object))
+			if p.metrics != nil && p.metrics.operation(identifier) == applyOperationUnchanged {
+				return showUnchanged
+			}
+			return showChanged
+		}
+	case event.TypePrune:
+		switch e.PruneInfo.Status {
+		case event.StatusFailed:
+			return showFailed
+		case event.StatusSuccessful:
+			return showPruned
+		}
+	case event.TypeStatusUpdate:
+		if e.StatusUpdateInfo.Status == event.StatusFailed {
+			return showFailed
+		}
+	}
+
+	return showProgress
+}
@@ -0,0 +1,159 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateShow(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		quiet         bool
+		show          []string
+		expectedError string
+	}{
+		"no filters": {},
+		"quiet": {
+			quiet: true,
+		},
+		"valid categories": {
+			show: []string{showFailed, showChanged},
+		},
+		"invalid category": {
+			show:          []string{showFailed, "created"},
+			expectedError: `invalid "show" value "created", valid values are failed, changed, unchanged, skipped, pruned, progress`,
+		},
+		"quiet and show together": {
+			quiet:         true,
+			show:          []string{showFailed},
+			expectedError: `the "quiet" and "show" flags cannot be used together`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options := &Options{quiet: test.quiet, show: test.show}
+			err := options.validateShow()
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.EqualError(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestShownEvents(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, (&Options{}).shownEvents())
+	assert.Equal(t, []string{showFailed}, (&Options{quiet: true}).shownEvents())
+	assert.Equal(t, []string{showChanged, showPruned}, (&Options{show: []string{showChanged, showPruned}}).shownEvents())
+}
+
+// recordingPrinter is an eventPrinter that keep in memory the events received
+type recordingPrinter struct {
+	events  []event.Event
+	flushed bool
+}
+
+func (p *recordingPrinter) PrintEvent(e event.Event) {
+	p.events = append(p.events, e)
+}
+
+func (p *recordingPrinter) Flush() {
+	p.flushed = true
+}
+
+func TestFilteredPrinter(t *testing.T) {
+	t.Parallel()
+
+	changed := progressTestObject("v1", "ConfigMap", "changed")
+	unchanged := progressTestObject("v1", "ConfigMap", "unchanged")
+	deployment := progressTestObject("apps/v1", "Deployment", "app")
+	pruned := progressTestObject("v1", "Secret", "old")
+
+	metrics := newMetricsRecorder()
+	metrics.recordRequest(summaryIdentifier(resource.ObjectMetadataFromUnstructured(changed)), 10, 0, 200)
+	metrics.recordRequest(summaryIdentifier(resource.ObjectMetadataFromUnstructured(unchanged)), 10, 0, 200)
+	metrics.recordUnchanged(summaryIdentifier(resource.ObjectMetadataFromUnstructured(unchanged)))
+
+	changedEvent := event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: changed, Status: event.StatusSuccessful}}
+	unchangedEvent := event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: unchanged, Status: event.StatusSuccessful}}
+	pendingEvent := event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: deployment, Status: event.StatusPending}}
+	skippedEvent := event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: deployment, Status: event.StatusSkipped}}
+	failedEvent := event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: deployment, Status: event.StatusFailed, Error: errors.New("boom")}}
+	prunedEvent := event.Event{Type: event.TypePrune, PruneInfo: event.PruneInfo{Object: pruned, Status: event.StatusSuccessful}}
+	statusEvent := event.Event{Type: event.TypeStatusUpdate, StatusUpdateInfo: event.StatusUpdateInfo{
+		Status:         event.StatusSuccessful,
+		ObjectMetadata: resource.ObjectMetadataFromUnstructured(deployment),
+	}}
+	unhealthyEvent := event.Event{Type: event.TypeStatusUpdate, StatusUpdateInfo: event.StatusUpdateInfo{
+		Status:         event.StatusFailed,
+		ObjectMetadata: resource.ObjectMetadataFromUnstructured(deployment),
+	}}
+	errorEvent := event.Event{Type: event.TypeError, ErrorInfo: event.ErrorInfo{Error: errors.New("generic error")}}
+
+	events := []event.Event{pendingEvent, changedEvent, unchangedEvent, skippedEvent, failedEvent, prunedEvent, statusEvent, unhealthyEvent, errorEvent}
+
+	tests := map[string]struct {
+		show           []string
+		expectedEvents []event.Event
+	}{
+		"only failures": {
+			show:           []string{showFailed},
+			expectedEvents: []event.Event{failedEvent, unhealthyEvent, errorEvent},
+		},
+		"failures and changes": {
+			show:           []string{showFailed, showChanged},
+			expectedEvents: []event.Event{changedEvent, failedEvent, unhealthyEvent, errorEvent},
+		},
+		"unchanged, skipped and pruned": {
+			show:           []string{showUnchanged, showSkipped, showPruned},
+			expectedEvents: []event.Event{unchangedEvent, skippedEvent, prunedEvent},
+		},
+		"progress": {
+			show:           []string{showProgress},
+			expectedEvents: []event.Event{pendingEvent, statusEvent},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := &recordingPrinter{}
+			printer := newFilteredPrinter(recorder, test.show, metrics)
+			for _, e := range events {
+				printer.PrintEvent(e)
+			}
+			printer.Flush()
+
+			assert.Equal(t, test.expectedEvents, recorder.events)
+			assert.True(t, recorder.flushed)
+		})
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"k8s.io/utils/clock"
+)
+
+const (
+	eventsStreamPath   = "/events"
+	eventsSnapshotPath = "/events.json"
+
+	// sseEventName and sseDoneName are the names of the Server-Sent Events sent for every deploy event and at the
+	// end of the deploy with its summary
+	sseEventName = "event"
+	sseDoneName  = "done"
+
+	// eventServerShutdownTimeout is the time given to the connected clients for receiving the last events
+	eventServerShutdownTimeout = 5 * time.Second
+)
+
+// streamedEvent is the JSON representation of a deploy event sent to the clients of the event server
+type streamedEvent struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Resource  string    `json:"resource,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Message   string    `json:"message"`
+	Error     bool      `json:"error,omitempty"`
+}
+
+// sseMessage is a message saved by the event server, its position in the history is used as its id
+type sseMessage struct {
+	name string
+	data []byte
+}
+
+// eventServer expose the events of a deploy on a local address as a stream of Server-Sent Events, the clients
+// connecting after the start of the deploy receive all the events from the beginning
+type eventServer struct {
+	server *http.Server
+	clock  clock.PassiveClock
+
+	lock     sync.Mutex
+	messages []sseMessage
+	done     bool
+	// updated is closed and replaced every time a message is added, for waking up the open streams
+	updated chan struct{}
+}
+
+// validateServeEvents check that the address of the event server is valid and can be used with the other options
+func (o *Options) validateServeEvents() error {
+	if len(o.serveEvents) == 0 {
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(o.serveEvents); err != nil {
+		return fmt.Errorf("invalid address %q for the %q flag: %w", o.serveEvents, serveEventsFlagName, err)
+	}
+
+	if o.offline {
+		return fmt.Errorf("the %q and %q flags cannot be used together", serveEventsFlagName, offlineFlagName)
+	}
+
+	return nil
+}
+
+// startEventServer start listening on the address set in the options, a nil server is returned if it is not set
+func (o *Options) startEventServer(ctx context.Context) (*eventServer, error) {
+	if len(o.serveEvents) == 0 {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", o.serveEvents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve the deploy events: %w", err)
+	}
+
+	server := newEventServer(o.clock)
+	go func() {
+		if err := server.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logr.FromContextOrDiscard(ctx).V(3).Info("event server stopped", "error", err.Error())
+		}
+	}()
+
+	fmt.Fprintf(o.writer, "streaming deploy events on http://%s%s\n", listener.Addr(), eventsStreamPath)
+	return server, nil
+}
+
+// newEventServer return a new eventServer, its handlers are ready to be served on any listener
+func newEventServer(clock clock.PassiveClock) *eventServer {
+	s := &eventServer{
+		clock:   clock,
+		updated: make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+eventsStreamPath, s.serveStream)
+	mux.HandleFunc("GET "+eventsSnapshotPath, s.serveSnapshot)
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return s
+}
+
+// Publish send e to all the connected clients
+func (s *eventServer) Publish(e event.Event) {
+	data, err := json.Marshal(s.streamedEvent(e))
+	if err != nil {
+		return
+	}
+	s.add(sseMessage{name: sseEventName, data: data})
+}
+
+// Close send summary to the connected clients, closing their streams, and stop the server once they have
+// received it, the connections still open after eventServerShutdownTimeout are dropped
+func (s *eventServer) Close(ctx context.Context, summary deploySummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	s.add(sseMessage{name: sseDoneName, data: data})
+
+	ctx, cancel := context.WithTimeout(ctx, eventServerShutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		_ = s.server.Close()
+		return fmt.Errorf("failed to stop the event server: %w", err)
+	}
+	return nil
+}
+
+// add save message in the history and wake up the open streams, after the done message nothing is added
+func (s *eventServer) add(message sseMessage) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.done {
+		return
+	}
+	s.messages = append(s.messages, message)
+	s.done = message.name == sseDoneName
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// next return the messages saved after the first from ones, if the deploy is finished and the channel closed when
+// new messages are added
+func (s *eventServer) next(from int) ([]sseMessage, bool, <-chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.messages[min(from, len(s.messages)):], s.done, s.updated
+}
+
+// serveStream write the messages as Server-Sent Events until the end of the deploy, starting after the one set in
+// the Last-Event-ID header if the client is reconnecting
+func (s *eventServer) serveStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sent := 0
+	if lastID, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil && lastID >= 0 {
+		sent = lastID + 1
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		messages, done, updated := s.next(sent)
+		for _, message := range messages {
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", sent, message.name, message.data); err != nil {
+				return
+			}
+			sent++
+		}
+		flusher.Flush()
+
+		if done {
+			return
+		}
+
+		select {
+		case <-updated:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// serveSnapshot write the JSON array of the deploy events received until now
+func (s *eventServer) serveSnapshot(w http.ResponseWriter, _ *http.Request) {
+	messages, _, _ := s.next(0)
+	events := make([]json.RawMessage, 0, len(messages))
+	for _, message := range messages {
+		if message.name == sseEventName {
+			events = append(events, message.data)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(events)
+}
+
+// streamedEvent return the JSON representation of e
+func (s *eventServer) streamedEvent(e event.Event) streamedEvent {
+	streamed := streamedEvent{
+		Time:    s.clock.Now(),
+		Type:    e.Type.String(),
+		Message: e.String(),
+		Error:   e.IsErrorEvent(),
+	}
+
+	var objMeta resource.ObjectMetadata
+	switch {
+	case e.Type == event.TypeApply && e.ApplyInfo.Object != nil:
+		objMeta = resource.ObjectMetadataFromUnstructured(e.ApplyInfo.Object)
+		streamed.Status = e.ApplyInfo.Status.String()
+		if isDeployOnceSkip(e) {
+			streamed.Message = fmt.Sprintf("%s, %s", deployOnceAction, deployOnceMessage)
+		}
+	case e.Type == event.TypePrune && e.PruneInfo.Object != nil:
+		objMeta = resource.ObjectMetadataFromUnstructured(e.PruneInfo.Object)
+		streamed.Status = e.PruneInfo.Status.String()
+	case e.Type == event.TypeStatusUpdate:
+		objMeta = e.StatusUpdateInfo.ObjectMetadata
+		streamed.Status = e.StatusUpdateInfo.Status.String()
+	default:
+		return streamed
+	}
+
+	streamed.Resource = summaryIdentifier(objMeta)
+	streamed.Namespace = objMeta.Namespace
+	return streamed
+}
@@ -0,0 +1,119 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestEventServer(t *testing.T) {
+	t.Parallel()
+
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "example", "namespace": "default"},
+	}}
+	configMap := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "removed", "namespace": "default"},
+	}}
+
+	clock := clocktesting.NewFakePassiveClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	server := newEventServer(clock)
+	httpServer := httptest.NewServer(server.server.Handler)
+	defer httpServer.Close()
+
+	// the stream is opened before any event for checking that the client is woken up by the new ones
+	liveRequest, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, httpServer.URL+eventsStreamPath, nil)
+	require.NoError(t, err)
+	liveResponse, err := httpServer.Client().Do(liveRequest)
+	require.NoError(t, err)
+	defer liveResponse.Body.Close()
+	assert.Equal(t, "text/event-stream", liveResponse.Header.Get("Content-Type"))
+
+	server.Publish(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: deployment, Status: event.StatusSuccessful}})
+	server.Publish(event.Event{Type: event.TypeStatusUpdate, StatusUpdateInfo: event.StatusUpdateInfo{
+		Status:         event.StatusSuccessful,
+		Message:        "resource is current",
+		ObjectMetadata: resource.ObjectMetadataFromUnstructured(deployment),
+	}})
+	server.Publish(event.Event{Type: event.TypePrune, PruneInfo: event.PruneInfo{Object: configMap, Status: event.StatusFailed, Error: errors.New("forbidden")}})
+
+	snapshot, err := httpServer.Client().Get(httpServer.URL + eventsSnapshotPath)
+	require.NoError(t, err)
+	defer snapshot.Body.Close()
+	data, err := io.ReadAll(snapshot.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", snapshot.Header.Get("Content-Type"))
+	assert.JSONEq(t, `[
+		{"time":"2024-01-01T00:00:00Z","type":"Apply","resource":"Deployment.apps/example","namespace":"default","status":"Successful","message":"Deployment.apps example: applied successfully"},
+		{"time":"2024-01-01T00:00:00Z","type":"StatusUpdate","resource":"Deployment.apps/example","namespace":"default","status":"Successful","message":"Deployment.apps example: resource is current"},
+		{"time":"2024-01-01T00:00:00Z","type":"Prune","resource":"ConfigMap/removed","namespace":"default","status":"Failed","message":"ConfigMap removed: failed to prune: forbidden","error":true}
+	]`, string(data))
+
+	require.NoError(t, server.Close(context.TODO(), deploySummary{Namespace: "default", Status: summaryStatusFailed}))
+	server.Publish(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: configMap, Status: event.StatusSuccessful}})
+
+	data, err = io.ReadAll(liveResponse.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `id: 0
+event: event
+data: {"time":"2024-01-01T00:00:00Z","type":"Apply","resource":"Deployment.apps/example","namespace":"default","status":"Successful","message":"Deployment.apps example: applied successfully"}
+
+id: 1
+event: event
+data: {"time":"2024-01-01T00:00:00Z","type":"StatusUpdate","resource":"Deployment.apps/example","namespace":"default","status":"Successful","message":"Deployment.apps example: resource is current"}
+
+id: 2
+event: event
+data: {"time":"2024-01-01T00:00:00Z","type":"Prune","resource":"ConfigMap/removed","namespace":"default","status":"Failed","message":"ConfigMap removed: failed to prune: forbidden","error":true}
+
+id: 3
+event: done
+data: {"namespace":"default","status":"failed","applied":null,"pruned":null,"failures":null,"duration":""}
+
+`, string(data))
+
+	// a reconnecting client receive only the events after the last one it has seen
+	request, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, httpServer.URL+eventsStreamPath, nil)
+	require.NoError(t, err)
+	request.Header.Set("Last-Event-ID", "2")
+	response, err := httpServer.Client().Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	data, err = io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `id: 3
+event: done
+data: {"namespace":"default","status":"failed","applied":null,"pruned":null,"failures":null,"duration":""}
+
+`, string(data))
+}
@@ -0,0 +1,250 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	clientflowcontrol "k8s.io/client-go/util/flowcontrol"
+)
+
+// fanOutResult contains the outcome of the deploy in a single namespace of a fan-out
+type fanOutResult struct {
+	namespace string
+	duration  time.Duration
+	err       error
+}
+
+// fanOutEnabled return true if the deploy must be repeated in multiple namespaces
+func (o *Options) fanOutEnabled() bool {
+	return len(o.fanOutNamespaces) > 0 || len(o.fanOutSelector) > 0
+}
+
+// validateFanOut check the options that cannot be used together with the fan-out
+func (o *Options) validateFanOut() error {
+	if !o.fanOutEnabled() {
+		return nil
+	}
+
+	if len(o.fanOutSelector) > 0 {
+		if _, err := labels.Parse(o.fanOutSelector); err != nil {
+			return fmt.Errorf("invalid namespace selector %q: %w", o.fanOutSelector, err)
+		}
+	}
+
+	switch {
+	case o.fanOutConcurrency < 1:
+		return fmt.Errorf("the %q flag must be at least 1", fanOutConcurrencyFlagName)
+	case slices.Contains(o.inputPaths, stdinToken):
+		return fmt.Errorf("cannot read from stdin when deploying in multiple namespaces")
+	case o.namespaceFromManifest:
+		return fmt.Errorf("the %q flag cannot be used when deploying in multiple namespaces", namespaceFromManifestFlagName)
+	case o.offline:
+		return fmt.Errorf("the %q flag cannot be used when deploying in multiple namespaces", offlineFlagName)
+	case len(o.resumeRunID) > 0:
+		return fmt.Errorf("the %q flag cannot be used when deploying in multiple namespaces", resumeFlagName)
+	case len(o.serveEvents) > 0:
+		return fmt.Errorf("the %q flag cannot be used when deploying in multiple namespaces", serveEventsFlagName)
+	}
+
+	return nil
+}
+
+// runFanOut repeat the deploy in every target namespace using a pool of workers, the output of every namespace is
+// printed when its deploy is completed followed by a table with the outcome of all of them
+func (o *Options) runFanOut(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	namespaces, err := o.fanOutTargets(ctx)
+	if err != nil {
+		return err
+	}
+	if len(namespaces) == 0 {
+		return fmt.Errorf("no namespace found for the deploy")
+	}
+
+	workers := min(o.fanOutConcurrency, len(namespaces))
+	logger.V(3).Info("deploying in multiple namespaces", "namespaces", len(namespaces), "workers", workers)
+
+	jobs := make(chan string)
+	results := make([]fanOutResult, 0, len(namespaces))
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for namespace := range jobs {
+				output := new(bytes.Buffer)
+				start := o.clock.Now()
+				err := o.forNamespace(namespace, output).Run(ctx)
+				result := fanOutResult{namespace: namespace, duration: o.clock.Since(start), err: err}
+
+				lock.Lock()
+				fmt.Fprintf(o.writer, "namespace %q:\n", namespace)
+				_, _ = output.WriteTo(o.writer)
+				if err != nil {
+					fmt.Fprintln(o.writer, strings.TrimRight(err.Error(), "\n"))
+				}
+				results = append(results, result)
+				lock.Unlock()
+			}
+		}()
+	}
+
+	for _, namespace := range namespaces {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- namespace
+	}
+	close(jobs)
+	wg.Wait()
+
+	slices.SortFunc(results, func(a, b fanOutResult) int { return strings.Compare(a.namespace, b.namespace) })
+	printFanOutResults(o.writer, results)
+
+	failed := make([]string, 0)
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result.namespace)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("deploy interrupted after %d of %d namespaces: %w", len(results), len(namespaces), err)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("deploy failed in %d of %d namespaces: %s", len(failed), len(namespaces), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// fanOutTargets return the sorted list of namespaces passed via flag or matching the namespace selector
+func (o *Options) fanOutTargets(ctx context.Context) ([]string, error) {
+	namespaces := sets.New(o.fanOutNamespaces...)
+	if len(o.fanOutSelector) == 0 {
+		return sets.List(namespaces), nil
+	}
+
+	clientSet, err := o.clientFactory.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := clientSet.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: o.fanOutSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces matching %q: %w", o.fanOutSelector, err)
+	}
+
+	for _, namespace := range list.Items {
+		if namespace.DeletionTimestamp != nil {
+			continue
+		}
+		namespaces.Insert(namespace.Name)
+	}
+	return sets.List(namespaces), nil
+}
+
+// forNamespace return a copy of the options for deploying in namespace and writing the output in writer
+func (o *Options) forNamespace(namespace string, writer *bytes.Buffer) *Options {
+	options := *o
+	options.fanOutNamespaces = nil
+	options.fanOutSelector = ""
+	options.noProgress = true
+	options.progress = nil
+	options.writer = writer
+	options.clientFactory = &namespaceOverrideFactory{ClientFactory: o.clientFactory, namespace: namespace}
+	return &options
+}
+
+// printFanOutResults write a table with the outcome of the deploy in every namespace
+func printFanOutResults(writer io.Writer, results []fanOutResult) {
+	tabWriter := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, "NAMESPACE\tRESULT\tDURATION")
+	for _, result := range results {
+		outcome := "succeeded"
+		if result.err != nil {
+			outcome = "failed"
+		}
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\n", result.namespace, outcome, result.duration.Round(time.Millisecond))
+	}
+	_ = tabWriter.Flush()
+}
+
+// fanOutRateLimiter return a rate limiter shared by all the clients created during a fan-out, so the total number
+// of requests sent to the API server is limited regardless of the number of workers
+func fanOutRateLimiter(qps float32, burst int) clientflowcontrol.RateLimiter {
+	if qps <= 0 {
+		return nil
+	}
+	return clientflowcontrol.NewTokenBucketRateLimiter(qps, max(burst, 1))
+}
+
+// namespaceOverrideFactory wrap a ClientFactory for enforcing namespace as the target of the deploy
+type namespaceOverrideFactory struct {
+	util.ClientFactory
+	namespace string
+}
+
+// ToRawKubeConfigLoader override the ClientFactory method wrapping the returned ClientConfig
+func (f *namespaceOverrideFactory) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return &namespaceOverrideClientConfig{delegate: f.ClientFactory.ToRawKubeConfigLoader(), namespace: f.namespace}
+}
+
+// namespaceOverrideClientConfig wrap a ClientConfig for always returning the same enforced namespace
+type namespaceOverrideClientConfig struct {
+	delegate  clientcmd.ClientConfig
+	namespace string
+}
+
+// RawConfig implement clientcmd.ClientConfig interface
+func (c *namespaceOverrideClientConfig) RawConfig() (clientcmdapi.Config, error) {
+	return c.delegate.RawConfig()
+}
+
+// ClientConfig implement clientcmd.ClientConfig interface
+func (c *namespaceOverrideClientConfig) ClientConfig() (*rest.Config, error) {
+	return c.delegate.ClientConfig()
+}
+
+// Namespace implement clientcmd.ClientConfig interface
+func (c *namespaceOverrideClientConfig) Namespace() (string, bool, error) {
+	return c.namespace, true, nil
+}
+
+// ConfigAccess implement clientcmd.ClientConfig interface
+func (c *namespaceOverrideClientConfig) ConfigAccess() clientcmd.ConfigAccess {
+	return c.delegate.ConfigAccess()
+}
+
+var _ clientcmd.ClientConfig = &namespaceOverrideClientConfig{}
@@ -0,0 +1,228 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	restfake "k8s.io/client-go/rest/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestValidateFanOut(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		options       *Options
+		expectedError string
+	}{
+		"fan-out disabled": {
+			options: &Options{inputPaths: []string{stdinToken}, offline: true},
+		},
+		"valid fan-out": {
+			options: &Options{
+				inputPaths:        []string{"input"},
+				fanOutNamespaces:  []string{"first", "second"},
+				fanOutSelector:    "tenant=true",
+				fanOutConcurrency: 4,
+			},
+		},
+		"invalid selector": {
+			options:       &Options{fanOutSelector: "=tenant", fanOutConcurrency: 1},
+			expectedError: `invalid namespace selector "=tenant"`,
+		},
+		"invalid concurrency": {
+			options:       &Options{fanOutNamespaces: []string{"first"}},
+			expectedError: `the "fan-out-concurrency" flag must be at least 1`,
+		},
+		"reading from stdin": {
+			options:       &Options{inputPaths: []string{stdinToken}, fanOutNamespaces: []string{"first"}, fanOutConcurrency: 1},
+			expectedError: "cannot read from stdin when deploying in multiple namespaces",
+		},
+		"namespace from manifest": {
+			options:       &Options{namespaceFromManifest: true, fanOutNamespaces: []string{"first"}, fanOutConcurrency: 1},
+			expectedError: `the "namespace-from-manifest" flag cannot be used when deploying in multiple namespaces`,
+		},
+		"offline": {
+			options:       &Options{offline: true, fanOutNamespaces: []string{"first"}, fanOutConcurrency: 1},
+			expectedError: `the "offline" flag cannot be used when deploying in multiple namespaces`,
+		},
+		"resume": {
+			options:       &Options{resumeRunID: "run", fanOutNamespaces: []string{"first"}, fanOutConcurrency: 1},
+			expectedError: `the "resume" flag cannot be used when deploying in multiple namespaces`,
+		},
+		"serve events": {
+			options:       &Options{serveEvents: ":8099", fanOutNamespaces: []string{"first"}, fanOutConcurrency: 1},
+			expectedError: `the "serve-events" flag cannot be used when deploying in multiple namespaces`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := test.options.validateFanOut()
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestNamespaceOverrideFactory(t *testing.T) {
+	t.Parallel()
+
+	tf := jpltesting.NewTestClientFactory().WithNamespace("original")
+	factory := &namespaceOverrideFactory{ClientFactory: tf, namespace: "override"}
+
+	namespace, enforced, err := factory.ToRawKubeConfigLoader().Namespace()
+	require.NoError(t, err)
+	assert.Equal(t, "override", namespace)
+	assert.True(t, enforced)
+}
+
+func TestFanOutRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, fanOutRateLimiter(0, 10))
+	limiter := fanOutRateLimiter(5, 0)
+	require.NotNil(t, limiter)
+	assert.Equal(t, float32(5), limiter.QPS())
+	assert.True(t, limiter.TryAccept())
+}
+
+func TestRunFanOut(t *testing.T) {
+	t.Parallel()
+
+	codec := jpltesting.Codecs.LegacyCodec(jpltesting.Scheme.PrioritizedVersionsAllGroups()...)
+	namespaces := &corev1.NamespaceList{
+		Items: []corev1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "tenant-b"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "tenant-deleting", DeletionTimestamp: &metav1.Time{Time: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)}}},
+		},
+	}
+
+	tests := map[string]struct {
+		fanOutNamespaces []string
+		fanOutSelector   string
+		failing          string
+		expectedApplied  []string
+		expectedOutput   []string
+		expectedError    string
+	}{
+		"deploy in listed and selected namespaces": {
+			fanOutNamespaces: []string{"tenant-c", "tenant-a"},
+			fanOutSelector:   "tenant=true",
+			expectedApplied:  []string{"tenant-a", "tenant-b", "tenant-c"},
+			expectedOutput:   []string{`namespace "tenant-a":`, `namespace "tenant-b":`, `namespace "tenant-c":`, "tenant-c   succeeded"},
+		},
+		"failure in a namespace": {
+			fanOutNamespaces: []string{"tenant-a", "tenant-b"},
+			failing:          "tenant-b",
+			expectedApplied:  []string{"tenant-a", "tenant-b"},
+			expectedOutput:   []string{"tenant-a   succeeded", "tenant-b   failed"},
+			expectedError:    "deploy failed in 1 of 2 namespaces: tenant-b",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var lock sync.Mutex
+			applied := make([]string, 0)
+			tf := jpltesting.NewTestClientFactory().WithNamespace("default")
+			tf.Client = &restfake.RESTClient{
+				NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+				Client: restfake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+					header := jpltesting.DefaultHeaders()
+					switch {
+					case r.URL.Path == "/api/v1/namespaces" && r.Method == http.MethodGet:
+						assert.Equal(t, "tenant=true", r.URL.Query().Get("labelSelector"))
+						body := []byte(runtime.EncodeOrDie(codec, namespaces))
+						return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(body))}, nil
+					case strings.HasSuffix(r.URL.Path, "/configmaps/example") && r.Method == http.MethodPatch:
+						namespace := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1"), "/")[2]
+						lock.Lock()
+						applied = append(applied, namespace)
+						lock.Unlock()
+						if namespace == test.failing {
+							return &http.Response{StatusCode: http.StatusInternalServerError, Header: header, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+						}
+						body, err := io.ReadAll(r.Body)
+						require.NoError(t, err)
+						return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(body))}, nil
+					case r.Method == http.MethodGet:
+						return &http.Response{StatusCode: http.StatusNotFound, Header: header, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+					default:
+						body, err := io.ReadAll(r.Body)
+						require.NoError(t, err)
+						return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(body))}, nil
+					}
+				}),
+			}
+
+			writer := new(strings.Builder)
+			options := &Options{
+				inputPaths:        []string{filepath.Join("testdata", "fan-out")},
+				deployType:        "deploy_all",
+				dryRun:            true,
+				fieldManager:      fieldManager,
+				fanOutNamespaces:  test.fanOutNamespaces,
+				fanOutSelector:    test.fanOutSelector,
+				fanOutConcurrency: 2,
+				clientFactory:     tf,
+				clock:             clocktesting.NewFakePassiveClock(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)),
+				writer:            writer,
+			}
+
+			ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+			defer cancel()
+			err := options.Run(ctx)
+			t.Log(writer.String())
+
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+			assert.ElementsMatch(t, test.expectedApplied, applied)
+			for _, expected := range test.expectedOutput {
+				assert.Contains(t, writer.String(), expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// healthCheckInterval is the time between two requests to the health path of the same resource
+	healthCheckInterval = 5 * time.Second
+	// healthCheckRequestTimeout is the maximum duration of a single request to the health path
+	healthCheckRequestTimeout = 10 * time.Second
+)
+
+// healthCheckTarget contains the data for calling the health path of a resource via its assigned address
+type healthCheckTarget struct {
+	identifier string
+	url        *url.URL
+	host       string
+}
+
+// String return the address called and, if different, the host used for the request
+func (t healthCheckTarget) String() string {
+	if len(t.host) == 0 {
+		return t.url.String()
+	}
+
+	return fmt.Sprintf("%s (host %s)", t.url, t.host)
+}
+
+// checkHealthPaths call the health path of the resources that have the await completion and health path
+// annotations until they respond 200, returning an error for every resource that doesn't within the timeout
+func (o *Options) checkHealthPaths(ctx context.Context, resources []*unstructured.Unstructured) []error {
+	logger := logr.FromContextOrDiscard(ctx)
+	objects := make([]*unstructured.Unstructured, 0)
+	for _, obj := range resources {
+		switch obj.GroupVersionKind().GroupKind() {
+		case extensions.IngressGK, extensions.GatewayGK, extensions.HTTPRouteGK:
+			if extensions.AwaitCompletion(obj) && len(obj.GetAnnotations()[extensions.AwaitHealthPathAnnotation]) > 0 {
+				objects = append(objects, obj)
+			}
+		}
+	}
+
+	if len(objects) == 0 {
+		return nil
+	}
+
+	client, err := o.clientFactory.DynamicClient()
+	if err != nil {
+		return []error{err}
+	}
+	mapper, err := o.clientFactory.ToRESTMapper()
+	if err != nil {
+		return []error{err}
+	}
+
+	getter := func(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		mapping, err := mapper.RESTMapping(obj.GroupVersionKind().GroupKind(), obj.GroupVersionKind().Version)
+		if err != nil {
+			return nil, err
+		}
+
+		var resourceClient dynamic.ResourceInterface = client.Resource(mapping.Resource)
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			resourceClient = client.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+		}
+		return resourceClient.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, o.healthCheckTimeout)
+	defer cancel()
+
+	errs := make([]error, 0)
+	for _, obj := range objects {
+		identifier := summaryIdentifier(resource.ObjectMetadataFromUnstructured(obj))
+		remoteObj, err := getter(ctx, obj)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read %s for its health check: %w", identifier, err))
+			continue
+		}
+
+		target, err := newHealthCheckTarget(ctx, remoteObj, getter)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot check health of %s: %w", identifier, err))
+			continue
+		}
+		target.identifier = identifier
+
+		logger.V(3).Info("checking health path", "resource", identifier, "url", target.url.String(), "host", target.host)
+		if err := o.pollHealthPath(ctx, target); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Fprintf(o.writer, "%s responded 200 on %s\n", identifier, target)
+	}
+
+	return errs
+}
+
+// pollHealthPath call target until it responds 200 or ctx expires
+func (o *Options) pollHealthPath(ctx context.Context, target *healthCheckTarget) error {
+	transport := o.healthCheckTransport
+	if transport == nil {
+		defaultTransport := http.DefaultTransport.(*http.Transport).Clone()
+		defaultTransport.TLSClientConfig = &tls.Config{ServerName: target.host, MinVersion: tls.VersionTLS12}
+		transport = defaultTransport
+	}
+	client := &http.Client{Transport: transport, Timeout: healthCheckRequestTimeout}
+
+	lastProblem := "no request completed"
+	err := wait.PollUntilContextCancel(ctx, o.healthCheckInterval, true, func(ctx context.Context) (bool, error) {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, target.url.String(), nil)
+		if err != nil {
+			return false, err
+		}
+		request.Host = target.host
+
+		response, err := client.Do(request)
+		if err != nil {
+			lastProblem = err.Error()
+			return false, nil
+		}
+		response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			lastProblem = fmt.Sprintf("last response was %s", response.Status)
+			return false, nil
+		}
+		return true, nil
+	})
+
+	switch {
+	case wait.Interrupted(err):
+		return fmt.Errorf("%s did not respond 200 on %s within %s: %s", target.identifier, target, o.healthCheckTimeout, lastProblem)
+	case err != nil:
+		return fmt.Errorf("health check of %s failed: %w", target.identifier, err)
+	}
+
+	return nil
+}
+
+// objectGetter return the current version of obj from the cluster
+type objectGetter func(context.Context, *unstructured.Unstructured) (*unstructured.Unstructured, error)
+
+// newHealthCheckTarget return the target to call for checking the health of obj via its assigned address
+func newHealthCheckTarget(ctx context.Context, obj *unstructured.Unstructured, getter objectGetter) (*healthCheckTarget, error) {
+	path := obj.GetAnnotations()[extensions.AwaitHealthPathAnnotation]
+	switch obj.GroupVersionKind().GroupKind() {
+	case extensions.IngressGK:
+		return ingressHealthCheckTarget(obj, path)
+	case extensions.GatewayGK:
+		return gatewayHealthCheckTarget(obj, path, "", "", 0)
+	case extensions.HTTPRouteGK:
+		return httpRouteHealthCheckTarget(ctx, obj, path, getter)
+	}
+
+	return nil, fmt.Errorf("unsupported resource %s", obj.GroupVersionKind().GroupKind())
+}
+
+// ingressHealthCheckTarget return the target for an Ingress, using the host of its first rule and https if the
+// host is covered by its tls configuration
+func ingressHealthCheckTarget(obj *unstructured.Unstructured, path string) (*healthCheckTarget, error) {
+	ingress := new(networkingv1.Ingress)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ingress); err != nil {
+		return nil, err
+	}
+
+	address := extensions.IngressAddress(ingress)
+	if len(address) == 0 {
+		return nil, fmt.Errorf("no address has been assigned")
+	}
+
+	host := ""
+	for _, rule := range ingress.Spec.Rules {
+		if len(rule.Host) > 0 && !strings.HasPrefix(rule.Host, "*") {
+			host = rule.Host
+			break
+		}
+	}
+
+	scheme := "http"
+	for _, ingressTLS := range ingress.Spec.TLS {
+		if len(host) == 0 || len(ingressTLS.Hosts) == 0 || tlsCoversHost(ingressTLS.Hosts, host) {
+			scheme = "https"
+			break
+		}
+	}
+
+	return newTarget(scheme, address, 0, path, host), nil
+}
+
+// gatewayHealthCheckTarget return the target for a Gateway, using the listener with sectionName or the first
+// HTTP or HTTPS one if empty; host and port override the ones found on the listener if set
+func gatewayHealthCheckTarget(obj *unstructured.Unstructured, path, sectionName, host string, port int64) (*healthCheckTarget, error) {
+	address, err := extensions.GatewayAddress(obj)
+	if err != nil {
+		return nil, err
+	}
+	if len(address) == 0 {
+		return nil, fmt.Errorf("no address has been assigned to Gateway %q", obj.GetName())
+	}
+
+	listeners, _, err := unstructured.NestedSlice(obj.Object, "spec", "listeners")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, listener := range listeners {
+		listenerMap, ok := listener.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _, _ := unstructured.NestedString(listenerMap, "name")
+		protocol, _, _ := unstructured.NestedString(listenerMap, "protocol")
+		if (len(sectionName) > 0 && name != sectionName) || (protocol != "HTTP" && protocol != "HTTPS") {
+			continue
+		}
+
+		listenerHost, _, _ := unstructured.NestedString(listenerMap, "hostname")
+		if strings.HasPrefix(listenerHost, "*") {
+			listenerHost = ""
+		}
+		listenerPort, _, _ := unstructured.NestedInt64(listenerMap, "port")
+		return newTarget(strings.ToLower(protocol), address, cmp.Or(port, listenerPort), path, cmp.Or(host, listenerHost)), nil
+	}
+
+	return nil, fmt.Errorf("no HTTP or HTTPS listener found on Gateway %q", obj.GetName())
+}
+
+// httpRouteHealthCheckTarget return the target for an HTTPRoute, using the address of its first parent Gateway and
+// the first hostname of the route
+func httpRouteHealthCheckTarget(ctx context.Context, obj *unstructured.Unstructured, path string, getter objectGetter) (*healthCheckTarget, error) {
+	hostnames, _, err := unstructured.NestedStringSlice(obj.Object, "spec", "hostnames")
+	if err != nil {
+		return nil, err
+	}
+
+	host := ""
+	for _, hostname := range hostnames {
+		if !strings.HasPrefix(hostname, "*") {
+			host = hostname
+			break
+		}
+	}
+
+	parentRefs, _, err := unstructured.NestedSlice(obj.Object, "spec", "parentRefs")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, parentRef := range parentRefs {
+		parentMap, ok := parentRef.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		group, _, _ := unstructured.NestedString(parentMap, "group")
+		kind, _, _ := unstructured.NestedString(parentMap, "kind")
+		if cmp.Or(group, extensions.GatewayGK.Group) != extensions.GatewayGK.Group || cmp.Or(kind, extensions.GatewayGK.Kind) != extensions.GatewayGK.Kind {
+			continue
+		}
+
+		name, _, _ := unstructured.NestedString(parentMap, "name")
+		namespace, _, _ := unstructured.NestedString(parentMap, "namespace")
+		sectionName, _, _ := unstructured.NestedString(parentMap, "sectionName")
+		port, _, _ := unstructured.NestedInt64(parentMap, "port")
+
+		gateway := new(unstructured.Unstructured)
+		gateway.SetAPIVersion(obj.GetAPIVersion())
+		gateway.SetKind(extensions.GatewayGK.Kind)
+		gateway.SetName(name)
+		gateway.SetNamespace(cmp.Or(namespace, obj.GetNamespace()))
+		if gateway, err = getter(ctx, gateway); err != nil {
+			return nil, fmt.Errorf("failed to read parent Gateway %q: %w", name, err)
+		}
+
+		return gatewayHealthCheckTarget(gateway, path, sectionName, host, port)
+	}
+
+	return nil, fmt.Errorf("no parent Gateway found")
+}
+
+// newTarget return a healthCheckTarget for the parameters, omitting the port when it is the default for scheme
+func newTarget(scheme, address string, port int64, path, host string) *healthCheckTarget {
+	hostPort := address
+	if port > 0 && !(scheme == "http" && port == 80) && !(scheme == "https" && port == 443) {
+		hostPort = net.JoinHostPort(address, strconv.FormatInt(port, 10))
+	} else if strings.Contains(address, ":") {
+		hostPort = "[" + address + "]"
+	}
+
+	path, query, _ := strings.Cut(path, "?")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return &healthCheckTarget{
+		url:  &url.URL{Scheme: scheme, Host: hostPort, Path: path, RawQuery: query},
+		host: host,
+	}
+}
+
+// tlsCoversHost return true if hosts contains host, also matching wildcard entries
+func tlsCoversHost(hosts []string, host string) bool {
+	for _, candidate := range hosts {
+		if candidate == host {
+			return true
+		}
+		if suffix, found := strings.CutPrefix(candidate, "*"); found && strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
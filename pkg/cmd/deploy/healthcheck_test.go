@@ -0,0 +1,329 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/yaml"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestNewHealthCheckTarget(t *testing.T) {
+	t.Parallel()
+
+	gateway := testUnstructured(t, `
+apiVersion: gateway.networking.k8s.io/v1
+kind: Gateway
+metadata:
+  name: gateway
+  namespace: infra
+spec:
+  listeners:
+  - name: tcp
+    protocol: TCP
+    port: 9000
+  - name: http
+    protocol: HTTP
+    port: 80
+    hostname: "*.example.com"
+  - name: https
+    protocol: HTTPS
+    port: 8443
+    hostname: gateway.example.com
+status:
+  addresses:
+  - value: 10.0.0.1
+`)
+	getter := func(_ context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		if obj.GetName() != gateway.GetName() || obj.GetNamespace() != gateway.GetNamespace() {
+			return nil, fmt.Errorf("not found")
+		}
+		return gateway, nil
+	}
+
+	tests := map[string]struct {
+		object         string
+		expectedTarget string
+		expectedError  string
+	}{
+		"ingress with tls": {
+			object: `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: example
+  annotations:
+    mia-platform.eu/await-health-path: /healthz?full=true
+spec:
+  tls:
+  - hosts:
+    - "*.example.com"
+  rules:
+  - host: "*.example.com"
+  - host: app.example.com
+status:
+  loadBalancer:
+    ingress:
+    - ip: 10.0.0.2
+`,
+			expectedTarget: "https://10.0.0.2/healthz?full=true (host app.example.com)",
+		},
+		"ingress without host": {
+			object: `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: example
+  annotations:
+    mia-platform.eu/await-health-path: healthz
+status:
+  loadBalancer:
+    ingress:
+    - hostname: lb.example.com
+`,
+			expectedTarget: "http://lb.example.com/healthz",
+		},
+		"ingress without address": {
+			object: `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: example
+  annotations:
+    mia-platform.eu/await-health-path: /healthz
+`,
+			expectedError: "no address has been assigned",
+		},
+		"gateway use the first http listener": {
+			object: `
+apiVersion: gateway.networking.k8s.io/v1
+kind: Gateway
+metadata:
+  name: gateway
+  namespace: infra
+  annotations:
+    mia-platform.eu/await-health-path: /healthz
+spec:
+  listeners:
+  - name: http
+    protocol: HTTP
+    port: 8080
+status:
+  addresses:
+  - value: "fd00::1"
+`,
+			expectedTarget: "http://[fd00::1]:8080/healthz",
+		},
+		"httproute use the listener of its parent": {
+			object: `
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: example
+  namespace: default
+  annotations:
+    mia-platform.eu/await-health-path: /healthz
+spec:
+  hostnames:
+  - app.example.com
+  parentRefs:
+  - name: gateway
+    namespace: infra
+    sectionName: https
+`,
+			expectedTarget: "https://10.0.0.1:8443/healthz (host app.example.com)",
+		},
+		"httproute use the first http listener and its hostname": {
+			object: `
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: example
+  namespace: default
+  annotations:
+    mia-platform.eu/await-health-path: /healthz
+spec:
+  parentRefs:
+  - name: gateway
+    namespace: infra
+`,
+			expectedTarget: "http://10.0.0.1/healthz",
+		},
+		"httproute without gateway parent": {
+			object: `
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: example
+  namespace: default
+  annotations:
+    mia-platform.eu/await-health-path: /healthz
+spec:
+  parentRefs:
+  - name: service
+    kind: Service
+    group: ""
+`,
+			expectedError: "no parent Gateway found",
+		},
+		"httproute with missing gateway": {
+			object: `
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: example
+  namespace: default
+  annotations:
+    mia-platform.eu/await-health-path: /healthz
+spec:
+  parentRefs:
+  - name: gateway
+`,
+			expectedError: `failed to read parent Gateway "gateway": not found`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			target, err := newHealthCheckTarget(context.TODO(), testUnstructured(t, test.object), getter)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedTarget, target.String())
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestCheckHealthPaths(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-deploy-test"
+	ingress := testUnstructured(t, `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: example
+  namespace: mlp-deploy-test
+  annotations:
+    mia-platform.eu/await-completion: "true"
+    mia-platform.eu/await-health-path: /healthz
+spec:
+  rules:
+  - host: app.example.com
+status:
+  loadBalancer:
+    ingress:
+    - ip: 10.0.0.2
+`)
+	withoutPath := ingress.DeepCopy()
+	withoutPath.SetName("without-path")
+	withoutPath.SetAnnotations(map[string]string{"mia-platform.eu/await-completion": "true"})
+
+	tests := map[string]struct {
+		healthyAfter   int32
+		resources      []*unstructured.Unstructured
+		expectedOutput string
+		expectedErrors []string
+	}{
+		"no resources to check": {
+			resources: []*unstructured.Unstructured{withoutPath},
+		},
+		"health path respond 200": {
+			healthyAfter:   2,
+			resources:      []*unstructured.Unstructured{ingress, withoutPath},
+			expectedOutput: "Ingress.networking.k8s.io/example responded 200 on http://10.0.0.2/healthz (host app.example.com)\n",
+		},
+		"health path never respond 200": {
+			healthyAfter: -1,
+			resources:    []*unstructured.Unstructured{ingress},
+			expectedErrors: []string{
+				"Ingress.networking.k8s.io/example did not respond 200 on http://10.0.0.2/healthz (host app.example.com) within 50ms: last response was 503 Service Unavailable",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+			transport := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+				assert.Equal(t, "app.example.com", request.Host)
+				statusCode := http.StatusServiceUnavailable
+				if test.healthyAfter >= 0 && calls.Add(1) >= test.healthyAfter {
+					statusCode = http.StatusOK
+				}
+				return &http.Response{
+					StatusCode: statusCode,
+					Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+					Body:       io.NopCloser(strings.NewReader("")),
+				}, nil
+			})
+
+			output := new(strings.Builder)
+			tf := jpltesting.NewTestClientFactory().WithNamespace(namespace)
+			tf.FakeDynamicClient = dynamicfake.NewSimpleDynamicClient(jpltesting.Scheme, ingress, withoutPath)
+			options := &Options{
+				healthCheckTimeout:   50 * time.Millisecond,
+				healthCheckInterval:  time.Millisecond,
+				healthCheckTransport: transport,
+				clientFactory:        tf,
+				writer:               output,
+			}
+
+			errs := options.checkHealthPaths(context.TODO(), test.resources)
+			errMessages := make([]string, 0, len(errs))
+			for _, err := range errs {
+				errMessages = append(errMessages, err.Error())
+			}
+			assert.ElementsMatch(t, test.expectedErrors, errMessages)
+			assert.Equal(t, test.expectedOutput, output.String())
+		})
+	}
+}
+
+func testUnstructured(t *testing.T, data string) *unstructured.Unstructured {
+	t.Helper()
+
+	jsonData, err := yaml.YAMLToJSON([]byte(data))
+	require.NoError(t, err)
+	obj := new(unstructured.Unstructured)
+	require.NoError(t, obj.UnmarshalJSON(jsonData))
+	return obj
+}
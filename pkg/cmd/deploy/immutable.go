@@ -0,0 +1,190 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/util"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	cliresource "k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	recreateImmutableAnnotation = "mia-platform.eu/recreate-immutable"
+	recreateImmutableValue      = "true"
+
+	// immutableFieldMessage is contained in the error returned by the api-server when the data of an immutable
+	// ConfigMap or Secret is changed
+	immutableFieldMessage = "field is immutable"
+
+	defaultRecreateTimeout  = 1 * time.Minute
+	defaultRecreateInterval = 1 * time.Second
+)
+
+var immutableResources = map[schema.GroupKind]struct{}{
+	{Group: "", Kind: "ConfigMap"}: {},
+	{Group: "", Kind: "Secret"}:    {},
+}
+
+// immutableRecreateTransport recreate the immutable ConfigMaps and Secrets that have opted in via annotation, when
+// the request made through next is rejected because their content has changed
+type immutableRecreateTransport struct {
+	next     http.RoundTripper
+	timeout  time.Duration
+	interval time.Duration
+}
+
+func (t *immutableRecreateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if (req.Method != http.MethodPatch && req.Method != http.MethodPut) || req.GetBody == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	body, annotations, err := requestAnnotations(req)
+	if err != nil || annotations[recreateImmutableAnnotation] != recreateImmutableValue {
+		return t.next.RoundTrip(req)
+	}
+
+	response, err := t.next.RoundTrip(req)
+	if err != nil || response.StatusCode != http.StatusUnprocessableEntity {
+		return response, err
+	}
+
+	responseBody, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+	if !strings.Contains(string(responseBody), immutableFieldMessage) {
+		return response, nil
+	}
+
+	logr.FromContextOrDiscard(req.Context()).V(3).Info("recreating immutable resource", "path", req.URL.Path)
+	query := req.URL.Query()
+	deleteQuery := url.Values{}
+	if dryRun, found := query["dryRun"]; found {
+		deleteQuery["dryRun"] = dryRun
+	}
+
+	deleteReq, err := newRequestFrom(req, http.MethodDelete, req.URL.Path, deleteQuery.Encode(), "application/json", []byte(`{"propagationPolicy":"Background"}`))
+	if err != nil {
+		return nil, err
+	}
+	deleteResponse, err := t.next.RoundTrip(deleteReq)
+	if err != nil || deleteResponse.StatusCode >= http.StatusBadRequest {
+		return deleteResponse, err
+	}
+	_, _ = io.Copy(io.Discard, deleteResponse.Body)
+	deleteResponse.Body.Close()
+
+	if len(deleteQuery) > 0 {
+		return dryRunRecreateResponse(req, body), nil
+	}
+
+	if err := waitDeletion(req, t.next, t.timeout, t.interval); err != nil {
+		return nil, fmt.Errorf("waiting deletion of immutable resource %s: %w", req.URL.Path, err)
+	}
+
+	retryReq, err := newRequestFrom(req, req.Method, req.URL.Path, req.URL.RawQuery, req.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(retryReq)
+}
+
+// waitDeletion poll through next the object targeted by req until it is not found anymore or timeout expires
+func waitDeletion(req *http.Request, next http.RoundTripper, timeout, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	return wait.PollUntilContextCancel(ctx, interval, true, func(ctx context.Context) (bool, error) {
+		getReq, err := newRequestFrom(req.WithContext(ctx), http.MethodGet, req.URL.Path, "", "application/json", nil)
+		if err != nil {
+			return false, err
+		}
+
+		response, err := next.RoundTrip(getReq)
+		if err != nil {
+			return false, err
+		}
+		_, _ = io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+
+		switch {
+		case response.StatusCode == http.StatusNotFound:
+			return true, nil
+		case response.StatusCode >= http.StatusBadRequest:
+			return false, fmt.Errorf("unexpected response %s", response.Status)
+		}
+		return false, nil
+	})
+}
+
+// immutableRecreateFactory wrap a ClientFactory for recreating the immutable ConfigMaps and Secrets with the clients
+// it returns
+type immutableRecreateFactory struct {
+	util.ClientFactory
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// newImmutableRecreateFactory return a ClientFactory that recreate the immutable ConfigMaps and Secrets whose content
+// has changed
+func newImmutableRecreateFactory(factory util.ClientFactory) util.ClientFactory {
+	return &immutableRecreateFactory{
+		ClientFactory: factory,
+		timeout:       defaultRecreateTimeout,
+		interval:      defaultRecreateInterval,
+	}
+}
+
+// UnstructuredClientForMapping override the ClientFactory method wrapping the transport of the returned client
+func (f *immutableRecreateFactory) UnstructuredClientForMapping(mapping *meta.RESTMapping) (cliresource.RESTClient, error) {
+	client, err := f.ClientFactory.UnstructuredClientForMapping(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, found := immutableResources[mapping.GroupVersionKind.GroupKind()]; !found {
+		return client, nil
+	}
+
+	restClient, ok := client.(*rest.RESTClient)
+	if !ok || restClient.Client == nil {
+		return client, nil
+	}
+
+	httpClient := *restClient.Client
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = &immutableRecreateTransport{next: next, timeout: f.timeout, interval: f.interval}
+	restClient.Client = &httpClient
+	return restClient, nil
+}
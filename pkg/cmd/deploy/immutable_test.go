@@ -0,0 +1,164 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestImmutableRecreateFactory(t *testing.T) {
+	t.Parallel()
+
+	objectPath := "/apis/v1/namespaces/test/configmaps/example"
+	immutableError := `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"ConfigMap \"example\" is invalid: data: Forbidden: field is immutable when ` + "`immutable`" + ` is set","reason":"Invalid","code":422}`
+	otherError := `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"ConfigMap \"example\" is invalid: metadata.name: Invalid value","reason":"Invalid","code":422}`
+
+	tests := map[string]struct {
+		recreate         bool
+		dryRun           bool
+		patchError       string
+		expectedRequests []recordedRequest
+		expectedError    string
+	}{
+		"recreate changed immutable object": {
+			recreate:   true,
+			patchError: immutableError,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: objectPath, query: "fieldManager=mlp&force=true", contentType: string(types.ApplyPatchType)},
+				{method: http.MethodDelete, path: objectPath, contentType: "application/json", body: `{"propagationPolicy":"Background"}`},
+				{method: http.MethodGet, path: objectPath, contentType: "application/json"},
+				{method: http.MethodPatch, path: objectPath, query: "fieldManager=mlp&force=true", contentType: string(types.ApplyPatchType)},
+			},
+		},
+		"simulate recreation during dry run": {
+			recreate:   true,
+			dryRun:     true,
+			patchError: immutableError,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: objectPath, query: "dryRun=All&fieldManager=mlp&force=true", contentType: string(types.ApplyPatchType)},
+				{method: http.MethodDelete, path: objectPath, query: "dryRun=All", contentType: "application/json", body: `{"propagationPolicy":"Background"}`},
+			},
+		},
+		"without annotation return the error": {
+			patchError: immutableError,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: objectPath, query: "fieldManager=mlp&force=true", contentType: string(types.ApplyPatchType)},
+			},
+			expectedError: "field is immutable",
+		},
+		"other validation errors are returned": {
+			recreate:   true,
+			patchError: otherError,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: objectPath, query: "fieldManager=mlp&force=true", contentType: string(types.ApplyPatchType)},
+			},
+			expectedError: "metadata.name: Invalid value",
+		},
+		"unchanged object": {
+			recreate: true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: objectPath, query: "fieldManager=mlp&force=true", contentType: string(types.ApplyPatchType)},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			body := `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"example","namespace":"test"},"immutable":true}`
+			if test.recreate {
+				body = `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"example","namespace":"test","annotations":{"mia-platform.eu/recreate-immutable":"true"}},"immutable":true}`
+			}
+
+			lock := sync.Mutex{}
+			deleted := false
+			requests := make([]recordedRequest, 0)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				lock.Lock()
+				defer lock.Unlock()
+				request := recordedRequest{
+					method:      r.Method,
+					path:        r.URL.Path,
+					query:       r.URL.RawQuery,
+					contentType: r.Header.Get("Content-Type"),
+				}
+				if r.Method == http.MethodDelete {
+					request.body = string(data)
+				}
+				requests = append(requests, request)
+
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.Method == http.MethodDelete:
+					deleted = r.URL.Query().Get("dryRun") == ""
+					w.WriteHeader(http.StatusOK)
+					_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Success"}`))
+				case r.Method == http.MethodGet && deleted:
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+				case r.Method == http.MethodPatch && !deleted && len(test.patchError) > 0:
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					_, _ = w.Write([]byte(test.patchError))
+				default:
+					w.WriteHeader(http.StatusOK)
+					_, _ = w.Write([]byte(body))
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			factory := newImmutableRecreateFactory(&restClientFactory{host: server.URL})
+			factory.(*immutableRecreateFactory).interval = time.Millisecond
+			client, err := factory.UnstructuredClientForMapping(&meta.RESTMapping{
+				GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			})
+			require.NoError(t, err)
+
+			request := client.Patch(types.ApplyPatchType).
+				Namespace("test").
+				Resource("configmaps").
+				Name("example").
+				Param("fieldManager", "mlp").
+				Param("force", "true")
+			if test.dryRun {
+				request = request.Param("dryRun", "All")
+			}
+			err = request.Body([]byte(body)).Do(context.TODO()).Error()
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+
+			assert.Equal(t, test.expectedRequests, requests)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// interruptCleanupTimeout is the maximum time allowed for saving the state of an interrupted deploy
+const interruptCleanupTimeout = 30 * time.Second
+
+// progressTracker keep track of the resources applied and pruned during the deploy, for saving a consistent
+// inventory if the deploy is interrupted before the applier can save it
+type progressTracker struct {
+	applied        sets.Set[resource.ObjectMetadata]
+	pruned         sets.Set[resource.ObjectMetadata]
+	inventorySaved bool
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{
+		applied: make(sets.Set[resource.ObjectMetadata]),
+		pruned:  make(sets.Set[resource.ObjectMetadata]),
+	}
+}
+
+// Track update the tracked state with the event e
+func (t *progressTracker) Track(e event.Event) {
+	switch {
+	case e.Type == event.TypeApply && e.ApplyInfo.Status == event.StatusSuccessful:
+		t.applied.Insert(resource.ObjectMetadataFromUnstructured(e.ApplyInfo.Object))
+	case e.Type == event.TypePrune && e.PruneInfo.Status == event.StatusSuccessful:
+		t.pruned.Insert(resource.ObjectMetadataFromUnstructured(e.PruneInfo.Object))
+	case e.Type == event.TypeInventory && e.InventoryInfo.Status == event.StatusSuccessful:
+		t.inventorySaved = true
+	}
+}
+
+// cleanupContext return a context detached from the cancellation of ctx, for completing the deploy reporting
+// after it has been interrupted
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx.Err() == nil {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(context.WithoutCancel(ctx), interruptCleanupTimeout)
+}
+
+// saveInterruptedInventory save in inventory the resources actually applied and pruned before the interruption
+func (o *Options) saveInterruptedInventory(ctx context.Context, inventory *Inventory, tracker *progressTracker) error {
+	if tracker.inventorySaved || (len(tracker.applied) == 0 && len(tracker.pruned) == 0) {
+		return nil
+	}
+
+	if err := inventory.SaveInterrupted(ctx, tracker.applied, tracker.pruned, o.dryRun); err != nil {
+		return fmt.Errorf("failed to save inventory of the interrupted deploy: %w", err)
+	}
+
+	return nil
+}
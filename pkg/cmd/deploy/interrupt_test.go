@@ -0,0 +1,125 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestProgressTracker(t *testing.T) {
+	t.Parallel()
+
+	api := testSelectObject("api", nil, nil)
+	worker := testSelectObject("worker", nil, nil)
+	removed := testSelectObject("removed", nil, nil)
+
+	tracker := newProgressTracker()
+	tracker.Track(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: api, Status: event.StatusSuccessful}})
+	tracker.Track(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: worker, Status: event.StatusFailed, Error: errors.New("error")}})
+	tracker.Track(event.Event{Type: event.TypePrune, PruneInfo: event.PruneInfo{Object: removed, Status: event.StatusSuccessful}})
+
+	assert.Equal(t, sets.New(resource.ObjectMetadataFromUnstructured(api)), tracker.applied)
+	assert.Equal(t, sets.New(resource.ObjectMetadataFromUnstructured(removed)), tracker.pruned)
+	assert.False(t, tracker.inventorySaved)
+
+	tracker.Track(event.Event{Type: event.TypeInventory, InventoryInfo: event.InventoryInfo{Status: event.StatusSuccessful}})
+	assert.True(t, tracker.inventorySaved)
+}
+
+func TestSaveInterruptedInventory(t *testing.T) {
+	t.Parallel()
+
+	api := resource.ObjectMetadata{Group: "apps", Kind: "Deployment", Name: "api", Namespace: "test"}
+	worker := resource.ObjectMetadata{Group: "apps", Kind: "Deployment", Name: "worker", Namespace: "test"}
+	config := resource.ObjectMetadata{Kind: "ConfigMap", Name: "config", Namespace: "test"}
+	removed := resource.ObjectMetadata{Kind: "Secret", Name: "removed", Namespace: "test"}
+
+	tests := map[string]struct {
+		tracker       *progressTracker
+		load          bool
+		expectedSaved sets.Set[resource.ObjectMetadata]
+	}{
+		"applied and pruned objects are saved": {
+			tracker: &progressTracker{applied: sets.New(api), pruned: sets.New(removed)},
+			load:    true,
+			// the worker has not been applied yet, but it must remain in the inventory for pruning it later
+			expectedSaved: sets.New(api, worker, config),
+		},
+		"nothing changed": {
+			tracker: newProgressTracker(),
+			load:    true,
+		},
+		"inventory already saved by the applier": {
+			tracker: &progressTracker{applied: sets.New(api), pruned: sets.New[resource.ObjectMetadata](), inventorySaved: true},
+			load:    true,
+		},
+		"inventory never loaded": {
+			tracker: &progressTracker{applied: sets.New(api), pruned: sets.New[resource.ObjectMetadata]()},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &recordingStore{objects: sets.New(worker, config, removed)}
+			inventory := &Inventory{delegate: store, trackedObjects: sets.New[resource.ObjectMetadata]()}
+			if test.load {
+				_, err := inventory.Load(context.TODO())
+				require.NoError(t, err)
+			}
+
+			options := &Options{}
+			require.NoError(t, options.saveInterruptedInventory(context.TODO(), inventory, test.tracker))
+			if test.expectedSaved == nil {
+				assert.Nil(t, store.saved)
+				return
+			}
+
+			saved := sets.New[resource.ObjectMetadata]()
+			for obj := range store.saved {
+				saved.Insert(resource.ObjectMetadataFromUnstructured(obj))
+			}
+			assert.Equal(t, test.expectedSaved, saved)
+		})
+	}
+}
+
+func TestCleanupContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := cleanupContext(context.TODO())
+	defer cancel()
+	assert.Equal(t, context.TODO(), ctx)
+
+	cancelledCtx, cancelParent := context.WithCancel(context.TODO())
+	cancelParent()
+	ctx, cancel = cleanupContext(cancelledCtx)
+	defer cancel()
+	require.NoError(t, ctx.Err())
+	deadline, found := ctx.Deadline()
+	assert.True(t, found)
+	assert.WithinDuration(t, time.Now().Add(interruptCleanupTimeout), deadline, time.Second)
+}
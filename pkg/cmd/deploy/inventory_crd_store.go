@@ -0,0 +1,260 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mia-platform/jpl/pkg/inventory"
+	"github.com/mia-platform/jpl/pkg/resource"
+	mlpv1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	deployInventoryKind = "DeployInventory"
+)
+
+var (
+	deployInventoriesGVR = mlpv1.SchemeGroupVersion.WithResource("deployinventories")
+)
+
+// customResourceStore is an inventory store backed by a DeployInventory custom resource, the objects are saved
+// as a structured list in its spec so the inventory is not limited by the ConfigMap size and its access can be
+// granted separately. Like configMapStore it is saved with optimistic concurrency, merging the entries added by
+// other deploys after it has been loaded.
+type customResourceStore struct {
+	name         string
+	namespace    string
+	fieldManager string
+
+	client  dynamic.Interface
+	mapper  meta.RESTMapper
+	backoff wait.Backoff
+
+	savedObjects sets.Set[*unstructured.Unstructured]
+
+	// loaded is true after the remote inventory has been read, remote is its latest version or nil if missing
+	loaded bool
+	remote *unstructured.Unstructured
+	// loadedObjects are the objects of the inventory when loaded, remoteObjects the ones of its latest version read
+	loadedObjects sets.Set[resource.ObjectMetadata]
+	remoteObjects sets.Set[resource.ObjectMetadata]
+}
+
+// newCustomResourceStore return a new Store that will persist data in the DeployInventory name in namespace
+func newCustomResourceStore(client dynamic.Interface, mapper meta.RESTMapper, name, namespace, fieldManager string) inventory.Store {
+	return &customResourceStore{
+		name:         name,
+		namespace:    namespace,
+		fieldManager: fieldManager,
+		client:       client,
+		mapper:       mapper,
+		backoff:      retry.DefaultRetry,
+	}
+}
+
+// Load implement Store interface
+func (s *customResourceStore) Load(ctx context.Context) (sets.Set[resource.ObjectMetadata], error) {
+	// the inventory is loaded before applying the resources, a missing definition must stop the deploy here
+	// instead of failing only when the inventory is saved at the end
+	if _, err := s.mapper.RESTMapping(mlpv1.SchemeGroupVersion.WithKind(deployInventoryKind).GroupKind(), mlpv1.SchemeGroupVersion.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, fmt.Errorf("failed to find inventory, check that the %s CustomResourceDefinition is installed: %w", deployInventoryKind, err)
+		}
+		return nil, fmt.Errorf("failed to find inventory: %w", err)
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	s.loadedObjects = s.remoteObjects
+	return s.loadedObjects.Clone(), nil
+}
+
+// Save implement Store interface
+func (s *customResourceStore) Save(ctx context.Context, dryRun bool) error {
+	stale := !s.loaded
+	return retry.RetryOnConflict(s.backoff, func() error {
+		if stale {
+			if err := s.refresh(ctx); err != nil {
+				return err
+			}
+		}
+		stale = true
+
+		return s.write(ctx, dryRun)
+	})
+}
+
+// Delete implement Store interface
+func (s *customResourceStore) Delete(ctx context.Context, dryRun bool) error {
+	propagation := metav1.DeletePropagationBackground
+	opts := metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	}
+
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	if err := s.client.Resource(deployInventoriesGVR).Namespace(s.namespace).Delete(ctx, s.name, opts); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete inventory: %w", err)
+	}
+
+	return nil
+}
+
+// SetObjects implement Store interface
+func (s *customResourceStore) SetObjects(objs sets.Set[*unstructured.Unstructured]) {
+	s.savedObjects = objs.Clone()
+}
+
+// refresh read the remote inventory, saving its latest version and the objects listed in it
+func (s *customResourceStore) refresh(ctx context.Context) error {
+	obj, err := s.client.Resource(deployInventoriesGVR).Namespace(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		s.loaded, s.remote = true, nil
+		s.remoteObjects = make(sets.Set[resource.ObjectMetadata])
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to find inventory: %w", err)
+	}
+
+	manager, _, err := unstructured.NestedString(obj.Object, "spec", "fieldManager")
+	if err != nil {
+		return fmt.Errorf("failed to read inventory: %w", err)
+	}
+	if len(manager) > 0 && manager != s.fieldManager {
+		return fmt.Errorf("inventory %q in namespace %q is also written by the field manager %q instead of %q: it is shared with another tool or with deploys using a different field manager", s.name, s.namespace, manager, s.fieldManager)
+	}
+
+	objects, err := deployInventoryObjects(obj)
+	if err != nil {
+		return err
+	}
+
+	s.loaded, s.remote, s.remoteObjects = true, obj, objects
+	return nil
+}
+
+// write create or update the remote inventory with the objects to save, the update will fail with a conflict
+// if the remote inventory has changed after its last read
+func (s *customResourceStore) write(ctx context.Context, dryRun bool) error {
+	var dryRunOpts []string
+	if dryRun {
+		dryRunOpts = []string{metav1.DryRunAll}
+	}
+
+	client := s.client.Resource(deployInventoriesGVR).Namespace(s.namespace)
+	obj := s.objectForStore()
+	var err error
+	if s.remote == nil {
+		_, err = client.Create(ctx, obj, metav1.CreateOptions{FieldManager: s.fieldManager, DryRun: dryRunOpts})
+		if apierrors.IsAlreadyExists(err) {
+			return apierrors.NewConflict(deployInventoriesGVR.GroupResource(), s.name, err)
+		}
+	} else {
+		obj.SetResourceVersion(s.remote.GetResourceVersion())
+		obj.SetLabels(s.remote.GetLabels())
+		obj.SetAnnotations(s.remote.GetAnnotations())
+		_, err = client.Update(ctx, obj, metav1.UpdateOptions{FieldManager: s.fieldManager, DryRun: dryRunOpts})
+	}
+
+	switch {
+	case apierrors.IsConflict(err):
+		return err
+	case apierrors.IsNotFound(err):
+		return fmt.Errorf("failed to save inventory, check that the %s CustomResourceDefinition is installed: %w", deployInventoryKind, err)
+	case err != nil:
+		return fmt.Errorf("failed to save inventory: %w", err)
+	}
+
+	return nil
+}
+
+// objectForStore return the inventory for the objects to save, adding the entries saved by other deploys
+// after the inventory has been loaded
+func (s *customResourceStore) objectForStore() *unstructured.Unstructured {
+	objects := make(sets.Set[resource.ObjectMetadata])
+	for obj := range s.savedObjects {
+		objects.Insert(resource.ObjectMetadataFromUnstructured(obj))
+	}
+	objects = objects.Union(s.remoteObjects.Difference(s.loadedObjects))
+
+	resources := make([]interface{}, 0, len(objects))
+	for _, objMeta := range slices.SortedFunc(maps.Keys(objects), compareObjectMetadata) {
+		resources = append(resources, map[string]interface{}{
+			"group":     objMeta.Group,
+			"kind":      objMeta.Kind,
+			"namespace": objMeta.Namespace,
+			"name":      objMeta.Name,
+		})
+	}
+
+	obj := new(unstructured.Unstructured)
+	obj.SetGroupVersionKind(mlpv1.SchemeGroupVersion.WithKind(deployInventoryKind))
+	obj.SetName(s.name)
+	obj.SetNamespace(s.namespace)
+	obj.Object["spec"] = map[string]interface{}{
+		"fieldManager": s.fieldManager,
+		"resources":    resources,
+	}
+	return obj
+}
+
+// deployInventoryObjects return the objects listed in the spec of a DeployInventory
+func deployInventoryObjects(obj *unstructured.Unstructured) (sets.Set[resource.ObjectMetadata], error) {
+	entries, _, err := unstructured.NestedSlice(obj.Object, "spec", "resources")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+
+	objects := make(sets.Set[resource.ObjectMetadata], len(entries))
+	for _, entry := range entries {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("failed to read inventory: invalid resource entry %v", entry)
+		}
+
+		objMeta := resource.ObjectMetadata{}
+		objMeta.Group, _, _ = unstructured.NestedString(fields, "group")
+		objMeta.Kind, _, _ = unstructured.NestedString(fields, "kind")
+		objMeta.Namespace, _, _ = unstructured.NestedString(fields, "namespace")
+		objMeta.Name, _, _ = unstructured.NestedString(fields, "name")
+		objects.Insert(objMeta)
+	}
+
+	return objects, nil
+}
+
+// compareObjectMetadata sort objects by their inventory key
+func compareObjectMetadata(a, b resource.ObjectMetadata) int {
+	return strings.Compare(a.ToString(), b.ToString())
+}
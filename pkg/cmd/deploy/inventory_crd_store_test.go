@@ -0,0 +1,237 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	jplresource "github.com/mia-platform/jpl/pkg/resource"
+	mlpv1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// deployInventoryObject return a DeployInventory for the tests listing objects
+func deployInventoryObject(namespace, resourceVersion, manager string, objects ...jplresource.ObjectMetadata) *unstructured.Unstructured {
+	store := &customResourceStore{name: inventoryName, namespace: namespace, fieldManager: manager}
+	store.savedObjects = make(sets.Set[*unstructured.Unstructured])
+	for _, objMeta := range objects {
+		store.savedObjects.Insert(unstructuredFromMetadata(objMeta))
+	}
+
+	obj := store.objectForStore()
+	obj.SetResourceVersion(resourceVersion)
+	return obj
+}
+
+// deployInventoryRESTMapper return a RESTMapper that resolve the DeployInventory kind, like a cluster with its
+// CustomResourceDefinition installed
+func deployInventoryRESTMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{mlpv1.SchemeGroupVersion})
+	mapper.Add(mlpv1.SchemeGroupVersion.WithKind(deployInventoryKind), meta.RESTScopeNamespace)
+	return mapper
+}
+
+func TestCustomResourceStore(t *testing.T) {
+	t.Parallel()
+
+	namespace := "test-inventory"
+	first := jplresource.ObjectMetadata{Kind: "ConfigMap", Name: "first", Namespace: namespace}
+	second := jplresource.ObjectMetadata{Kind: "ConfigMap", Name: "second", Namespace: namespace}
+	third := jplresource.ObjectMetadata{Group: "apps", Kind: "Deployment", Name: "third", Namespace: namespace}
+	concurrent := jplresource.ObjectMetadata{Kind: "ConfigMap", Name: "concurrent", Namespace: namespace}
+
+	tests := map[string]struct {
+		remote            *unstructured.Unstructured
+		concurrentRemote  *unstructured.Unstructured
+		conflicts         int
+		createError       error
+		missingDefinition bool
+		dryRun            bool
+		objects           []jplresource.ObjectMetadata
+		expectedLoaded    sets.Set[jplresource.ObjectMetadata]
+		expectedWrites    []sets.Set[jplresource.ObjectMetadata]
+		expectedLoadError string
+		expectedError     string
+	}{
+		"save existing inventory": {
+			remote:         deployInventoryObject(namespace, "1", fieldManager, first, second),
+			objects:        []jplresource.ObjectMetadata{first, third},
+			expectedLoaded: sets.New(first, second),
+			expectedWrites: []sets.Set[jplresource.ObjectMetadata]{sets.New(first, third)},
+		},
+		"merge entries saved concurrently": {
+			remote:           deployInventoryObject(namespace, "1", fieldManager, first, second),
+			concurrentRemote: deployInventoryObject(namespace, "2", fieldManager, first, second, concurrent),
+			conflicts:        1,
+			objects:          []jplresource.ObjectMetadata{first, third},
+			expectedLoaded:   sets.New(first, second),
+			expectedWrites: []sets.Set[jplresource.ObjectMetadata]{
+				sets.New(first, third),
+				sets.New(first, third, concurrent),
+			},
+		},
+		"create missing inventory": {
+			objects:        []jplresource.ObjectMetadata{first},
+			expectedLoaded: sets.New[jplresource.ObjectMetadata](),
+			expectedWrites: []sets.Set[jplresource.ObjectMetadata]{sets.New(first)},
+		},
+		"inventory created concurrently": {
+			concurrentRemote: deployInventoryObject(namespace, "1", fieldManager, concurrent),
+			conflicts:        1,
+			objects:          []jplresource.ObjectMetadata{first},
+			expectedLoaded:   sets.New[jplresource.ObjectMetadata](),
+			expectedWrites: []sets.Set[jplresource.ObjectMetadata]{
+				sets.New(first),
+				sets.New(first, concurrent),
+			},
+		},
+		"dry run": {
+			remote:         deployInventoryObject(namespace, "1", fieldManager, first),
+			dryRun:         true,
+			objects:        []jplresource.ObjectMetadata{second},
+			expectedLoaded: sets.New(first),
+			expectedWrites: []sets.Set[jplresource.ObjectMetadata]{sets.New(second)},
+		},
+		"too many conflicts": {
+			remote:         deployInventoryObject(namespace, "1", fieldManager, first),
+			conflicts:      2,
+			objects:        []jplresource.ObjectMetadata{first},
+			expectedLoaded: sets.New(first),
+			expectedWrites: []sets.Set[jplresource.ObjectMetadata]{sets.New(first), sets.New(first)},
+			expectedError:  "the object has been modified",
+		},
+		"missing custom resource definition": {
+			createError:    apierrors.NewNotFound(deployInventoriesGVR.GroupResource(), ""),
+			objects:        []jplresource.ObjectMetadata{first},
+			expectedLoaded: sets.New[jplresource.ObjectMetadata](),
+			expectedWrites: []sets.Set[jplresource.ObjectMetadata]{sets.New(first)},
+			expectedError:  "check that the DeployInventory CustomResourceDefinition is installed",
+		},
+		"missing custom resource definition fail the load": {
+			missingDefinition: true,
+			expectedLoadError: "failed to find inventory, check that the DeployInventory CustomResourceDefinition is installed",
+		},
+		"inventory written by another field manager": {
+			remote:            deployInventoryObject(namespace, "1", "kubectl", first),
+			expectedLoadError: `inventory "eu.mia-platform.mlp" in namespace "test-inventory" is also written by the field manager "kubectl" instead of "mlp"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			objects := make([]runtime.Object, 0)
+			if test.remote != nil {
+				objects = append(objects, test.remote)
+			}
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+				deployInventoriesGVR: deployInventoryKind + "List",
+			}, objects...)
+
+			conflicts := test.conflicts
+			conflictReactor := func(clienttesting.Action) (bool, runtime.Object, error) {
+				if conflicts == 0 {
+					return false, nil, nil
+				}
+				conflicts--
+				if test.concurrentRemote != nil {
+					if err := client.Tracker().Delete(deployInventoriesGVR, namespace, inventoryName); err != nil {
+						require.True(t, apierrors.IsNotFound(err))
+					}
+					require.NoError(t, client.Tracker().Create(deployInventoriesGVR, test.concurrentRemote, namespace))
+				}
+				return true, nil, apierrors.NewConflict(deployInventoriesGVR.GroupResource(), inventoryName, fmt.Errorf("the object has been modified"))
+			}
+			client.PrependReactor("update", deployInventoriesGVR.Resource, conflictReactor)
+			client.PrependReactor("create", deployInventoriesGVR.Resource, func(action clienttesting.Action) (bool, runtime.Object, error) {
+				if test.createError != nil {
+					return true, nil, test.createError
+				}
+				if handled, obj, err := conflictReactor(action); handled {
+					return handled, obj, apierrors.NewAlreadyExists(deployInventoriesGVR.GroupResource(), inventoryName)
+				} else if err != nil {
+					return handled, obj, err
+				}
+				return false, nil, nil
+			})
+
+			mapper := deployInventoryRESTMapper()
+			if test.missingDefinition {
+				mapper = meta.NewDefaultRESTMapper(nil)
+			}
+			store := newCustomResourceStore(client, mapper, inventoryName, namespace, fieldManager).(*customResourceStore)
+			store.backoff = wait.Backoff{Steps: 2}
+
+			loaded, err := store.Load(context.TODO())
+			if len(test.expectedLoadError) > 0 {
+				assert.ErrorContains(t, err, test.expectedLoadError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedLoaded, loaded)
+
+			toSave := make(sets.Set[*unstructured.Unstructured])
+			for _, objMeta := range test.objects {
+				toSave.Insert(unstructuredFromMetadata(objMeta))
+			}
+			store.SetObjects(toSave)
+
+			err = store.Save(context.TODO(), test.dryRun)
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+
+			writes := make([]sets.Set[jplresource.ObjectMetadata], 0)
+			for _, action := range client.Actions() {
+				var obj runtime.Object
+				switch action := action.(type) {
+				case clienttesting.CreateAction:
+					obj = action.GetObject()
+				case clienttesting.UpdateAction:
+					obj = action.GetObject()
+				default:
+					continue
+				}
+
+				written, ok := obj.(*unstructured.Unstructured)
+				require.True(t, ok)
+				assert.Equal(t, mlpv1.SchemeGroupVersion.WithKind(deployInventoryKind), written.GroupVersionKind())
+				manager, _, _ := unstructured.NestedString(written.Object, "spec", "fieldManager")
+				assert.Equal(t, fieldManager, manager)
+				savedObjects, err := deployInventoryObjects(written)
+				require.NoError(t, err)
+				writes = append(writes, savedObjects)
+			}
+			assert.Equal(t, test.expectedWrites, writes)
+		})
+	}
+}
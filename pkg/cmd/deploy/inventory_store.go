@@ -0,0 +1,228 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mia-platform/jpl/pkg/inventory"
+	"github.com/mia-platform/jpl/pkg/resource"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientv1 "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// configMapStore is an inventory store backed by a ConfigMap, compatible with the one of jpl. When the ConfigMap
+// has been loaded it is saved with optimistic concurrency: if another deploy has changed it in the meantime the
+// entries added by it are merged with the ones to save and the save is retried.
+type configMapStore struct {
+	name         string
+	namespace    string
+	fieldManager string
+
+	clientset kubernetes.Interface
+	backoff   wait.Backoff
+
+	savedObjects sets.Set[*unstructured.Unstructured]
+
+	// loaded is true after the remote ConfigMap has been read, exists and resourceVersion describe it
+	loaded          bool
+	exists          bool
+	resourceVersion string
+	// loadedKeys are the keys of the ConfigMap when loaded, remoteKeys are the keys of its latest version read
+	loadedKeys sets.Set[string]
+	remoteKeys sets.Set[string]
+}
+
+// newConfigMapStore return a new Store that will persist data in the ConfigMap name in namespace
+func newConfigMapStore(clientset kubernetes.Interface, name, namespace, fieldManager string) inventory.Store {
+	return &configMapStore{
+		name:         name,
+		namespace:    namespace,
+		fieldManager: fieldManager,
+		clientset:    clientset,
+		backoff:      retry.DefaultRetry,
+	}
+}
+
+// Load implement Store interface
+func (s *configMapStore) Load(ctx context.Context) (sets.Set[resource.ObjectMetadata], error) {
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	s.loadedKeys = s.remoteKeys
+	metadataSet := make(sets.Set[resource.ObjectMetadata], 0)
+	for dataKey := range s.loadedKeys {
+		if ok, objMeta := resource.ObjectMetadataFromString(dataKey); ok {
+			metadataSet.Insert(objMeta)
+		}
+	}
+
+	return metadataSet, nil
+}
+
+// Save implement Store interface
+func (s *configMapStore) Save(ctx context.Context, dryRun bool) error {
+	if dryRun || !s.loaded {
+		return s.apply(ctx, s.dataForStore(), "", dryRun)
+	}
+
+	stale := false
+	return retry.RetryOnConflict(s.backoff, func() error {
+		if stale {
+			if err := s.refresh(ctx); err != nil {
+				return err
+			}
+		}
+		stale = true
+
+		if !s.exists {
+			if err := s.create(ctx); err != nil {
+				return err
+			}
+		}
+
+		return s.apply(ctx, s.dataForStore(), s.resourceVersion, false)
+	})
+}
+
+// Delete implement Store interface
+func (s *configMapStore) Delete(ctx context.Context, dryRun bool) error {
+	propagation := metav1.DeletePropagationBackground
+	opts := metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	}
+
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	if err := s.clientset.CoreV1().ConfigMaps(s.namespace).Delete(ctx, s.name, opts); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete inventory: %w", err)
+	}
+
+	return nil
+}
+
+// SetObjects implement Store interface
+func (s *configMapStore) SetObjects(objs sets.Set[*unstructured.Unstructured]) {
+	s.savedObjects = objs.Clone()
+}
+
+// refresh read the remote ConfigMap, saving its keys and resourceVersion
+func (s *configMapStore) refresh(ctx context.Context) error {
+	cm, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		s.loaded, s.exists, s.resourceVersion = true, false, ""
+		s.remoteKeys = make(sets.Set[string])
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to find inventory: %w", err)
+	}
+
+	if err := s.checkFieldManagers(cm); err != nil {
+		return err
+	}
+
+	s.loaded, s.exists, s.resourceVersion = true, true, cm.ResourceVersion
+	s.remoteKeys = make(sets.Set[string], len(cm.Data))
+	for key := range cm.Data {
+		s.remoteKeys.Insert(key)
+	}
+	return nil
+}
+
+// checkFieldManagers return an error if the inventory has been applied by a field manager different from the
+// configured one, because its deploys will overwrite and prune the resources tracked by this one
+func (s *configMapStore) checkFieldManagers(cm *corev1.ConfigMap) error {
+	for _, managedFields := range cm.ManagedFields {
+		if managedFields.Operation == metav1.ManagedFieldsOperationApply && managedFields.Manager != s.fieldManager {
+			return fmt.Errorf("inventory %q in namespace %q is also written by the field manager %q instead of %q: it is shared with another tool or with deploys using a different field manager", s.name, s.namespace, managedFields.Manager, s.fieldManager)
+		}
+	}
+
+	return nil
+}
+
+// create save an empty inventory, so the following apply can use its resourceVersion for failing if another
+// deploy has saved it concurrently. The data is not set here because the fields owned by an update operation
+// would not be removed by the following server side apply requests.
+func (s *configMapStore) create(ctx context.Context) error {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace}}
+	created, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Create(ctx, cm, metav1.CreateOptions{FieldManager: s.fieldManager})
+	switch {
+	case apierrors.IsAlreadyExists(err):
+		return apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, s.name, err)
+	case err != nil:
+		return fmt.Errorf("failed to save inventory: %w", err)
+	}
+
+	s.exists, s.resourceVersion = true, created.ResourceVersion
+	return nil
+}
+
+// apply save data in the inventory, if resourceVersion is not empty the request will fail with a conflict if the
+// remote inventory has a different version
+func (s *configMapStore) apply(ctx context.Context, data map[string]string, resourceVersion string, dryRun bool) error {
+	opts := metav1.ApplyOptions{
+		Force:        true,
+		FieldManager: s.fieldManager,
+	}
+
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	cm := clientv1.ConfigMap(s.name, s.namespace).WithData(data)
+	if len(resourceVersion) > 0 {
+		cm = cm.WithResourceVersion(resourceVersion)
+	}
+
+	_, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Apply(ctx, cm, opts)
+	switch {
+	case apierrors.IsConflict(err):
+		return err
+	case err != nil:
+		return fmt.Errorf("failed to save inventory: %w", err)
+	}
+
+	return nil
+}
+
+// dataForStore return the ConfigMap data for the objects to save, adding the entries saved by other deploys
+// after the inventory has been loaded
+func (s *configMapStore) dataForStore() map[string]string {
+	data := make(map[string]string)
+	for obj := range s.savedObjects {
+		data[resource.ObjectMetadataFromUnstructured(obj).ToString()] = ""
+	}
+
+	for key := range s.remoteKeys.Difference(s.loadedKeys) {
+		data[key] = ""
+	}
+
+	return data
+}
@@ -0,0 +1,260 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	jplresource "github.com/mia-platform/jpl/pkg/resource"
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	restfake "k8s.io/client-go/rest/fake"
+)
+
+// inventoryResponse is a fake response for a request to the inventory ConfigMap
+type inventoryResponse struct {
+	method     string
+	statusCode int
+	configMap  *corev1.ConfigMap
+}
+
+func TestConfigMapStore(t *testing.T) {
+	t.Parallel()
+
+	namespace := "test-inventory"
+	configMapPath := fmt.Sprintf("/api/v1/namespaces/%s/configmaps/%s", namespace, inventoryName)
+	configMapsPath := fmt.Sprintf("/api/v1/namespaces/%s/configmaps", namespace)
+	codec := jpltesting.Codecs.LegacyCodec(jpltesting.Scheme.PrioritizedVersionsAllGroups()...)
+
+	first := jplresource.ObjectMetadata{Kind: "ConfigMap", Name: "first", Namespace: namespace}
+	second := jplresource.ObjectMetadata{Kind: "ConfigMap", Name: "second", Namespace: namespace}
+	third := jplresource.ObjectMetadata{Kind: "ConfigMap", Name: "third", Namespace: namespace}
+	concurrent := jplresource.ObjectMetadata{Kind: "ConfigMap", Name: "concurrent", Namespace: namespace}
+
+	inventoryConfigMap := func(resourceVersion string, objects ...jplresource.ObjectMetadata) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: inventoryName, Namespace: namespace, ResourceVersion: resourceVersion}}
+		cm.Data = make(map[string]string)
+		for _, objMeta := range objects {
+			cm.Data[objMeta.ToString()] = ""
+		}
+		return cm
+	}
+
+	otherManager := inventoryConfigMap("1", first)
+	otherManager.ManagedFields = []metav1.ManagedFieldsEntry{
+		{Manager: "kubectl-edit", Operation: metav1.ManagedFieldsOperationUpdate},
+		{Manager: "pipeline", Operation: metav1.ManagedFieldsOperationApply},
+	}
+
+	tests := map[string]struct {
+		dryRun            bool
+		responses         []inventoryResponse
+		objects           []jplresource.ObjectMetadata
+		expectedLoaded    sets.Set[jplresource.ObjectMetadata]
+		expectedApplied   []*corev1.ConfigMap
+		expectedLoadError string
+		expectedError     string
+	}{
+		"save existing inventory": {
+			responses: []inventoryResponse{
+				{method: http.MethodGet, statusCode: http.StatusOK, configMap: inventoryConfigMap("1", first, second)},
+				{method: http.MethodPatch, statusCode: http.StatusOK},
+			},
+			objects:         []jplresource.ObjectMetadata{first, third},
+			expectedLoaded:  sets.New(first, second),
+			expectedApplied: []*corev1.ConfigMap{inventoryConfigMap("1", first, third)},
+		},
+		"merge entries saved concurrently": {
+			responses: []inventoryResponse{
+				{method: http.MethodGet, statusCode: http.StatusOK, configMap: inventoryConfigMap("1", first, second)},
+				{method: http.MethodPatch, statusCode: http.StatusConflict},
+				{method: http.MethodGet, statusCode: http.StatusOK, configMap: inventoryConfigMap("2", first, second, concurrent)},
+				{method: http.MethodPatch, statusCode: http.StatusOK},
+			},
+			objects:        []jplresource.ObjectMetadata{first, third},
+			expectedLoaded: sets.New(first, second),
+			expectedApplied: []*corev1.ConfigMap{
+				inventoryConfigMap("1", first, third),
+				inventoryConfigMap("2", first, third, concurrent),
+			},
+		},
+		"create missing inventory": {
+			responses: []inventoryResponse{
+				{method: http.MethodGet, statusCode: http.StatusNotFound},
+				{method: http.MethodPost, statusCode: http.StatusCreated, configMap: inventoryConfigMap("1")},
+				{method: http.MethodPatch, statusCode: http.StatusOK},
+			},
+			objects:         []jplresource.ObjectMetadata{first},
+			expectedLoaded:  sets.New[jplresource.ObjectMetadata](),
+			expectedApplied: []*corev1.ConfigMap{inventoryConfigMap("1", first)},
+		},
+		"inventory created concurrently": {
+			responses: []inventoryResponse{
+				{method: http.MethodGet, statusCode: http.StatusNotFound},
+				{method: http.MethodPost, statusCode: http.StatusConflict},
+				{method: http.MethodGet, statusCode: http.StatusOK, configMap: inventoryConfigMap("3", concurrent)},
+				{method: http.MethodPatch, statusCode: http.StatusOK},
+			},
+			objects:         []jplresource.ObjectMetadata{first},
+			expectedLoaded:  sets.New[jplresource.ObjectMetadata](),
+			expectedApplied: []*corev1.ConfigMap{inventoryConfigMap("3", first, concurrent)},
+		},
+		"dry run don't check the version": {
+			dryRun: true,
+			responses: []inventoryResponse{
+				{method: http.MethodGet, statusCode: http.StatusOK, configMap: inventoryConfigMap("1", first, second)},
+				{method: http.MethodPatch, statusCode: http.StatusOK},
+			},
+			objects:         []jplresource.ObjectMetadata{first},
+			expectedLoaded:  sets.New(first, second),
+			expectedApplied: []*corev1.ConfigMap{inventoryConfigMap("", first)},
+		},
+		"too many conflicts": {
+			responses: []inventoryResponse{
+				{method: http.MethodGet, statusCode: http.StatusOK, configMap: inventoryConfigMap("1", first)},
+				{method: http.MethodPatch, statusCode: http.StatusConflict},
+				{method: http.MethodGet, statusCode: http.StatusOK, configMap: inventoryConfigMap("2", first)},
+				{method: http.MethodPatch, statusCode: http.StatusConflict},
+			},
+			objects:        []jplresource.ObjectMetadata{first},
+			expectedLoaded: sets.New(first),
+			expectedApplied: []*corev1.ConfigMap{
+				inventoryConfigMap("1", first),
+				inventoryConfigMap("2", first),
+			},
+			expectedError: "Operation cannot be fulfilled",
+		},
+		"error saving inventory": {
+			responses: []inventoryResponse{
+				{method: http.MethodGet, statusCode: http.StatusOK, configMap: inventoryConfigMap("1", first)},
+				{method: http.MethodPatch, statusCode: http.StatusForbidden},
+			},
+			objects:         []jplresource.ObjectMetadata{first},
+			expectedLoaded:  sets.New(first),
+			expectedApplied: []*corev1.ConfigMap{inventoryConfigMap("1", first)},
+			expectedError:   "failed to save inventory",
+		},
+		"inventory applied by another field manager": {
+			responses: []inventoryResponse{
+				{method: http.MethodGet, statusCode: http.StatusOK, configMap: otherManager},
+			},
+			expectedLoadError: `inventory "eu.mia-platform.mlp" in namespace "test-inventory" is also written by the field manager "pipeline" instead of "mlp"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			applied := make([]*corev1.ConfigMap, 0)
+			requests := 0
+			client := restfake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+				require.Less(t, requests, len(test.responses), "unexpected call: %q, method %s", r.URL.Path, r.Method)
+				response := test.responses[requests]
+				requests++
+				require.Equal(t, response.method, r.Method)
+
+				path := configMapPath
+				if r.Method == http.MethodPost {
+					path = configMapsPath
+				}
+				require.Equal(t, path, r.URL.Path)
+
+				if r.Method == http.MethodPatch {
+					data, err := io.ReadAll(r.Body)
+					require.NoError(t, err)
+					cm := new(corev1.ConfigMap)
+					require.NoError(t, runtime.DecodeInto(codec, data, cm))
+					applied = append(applied, cm)
+					assert.Equal(t, test.dryRun, r.URL.Query().Has("dryRun"))
+				}
+
+				var obj runtime.Object = inventoryConfigMap("")
+				if response.configMap != nil {
+					obj = response.configMap
+				}
+				switch response.statusCode {
+				case http.StatusConflict:
+					status := apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, inventoryName, fmt.Errorf("conflict")).Status()
+					if r.Method == http.MethodPost {
+						status = apierrors.NewAlreadyExists(schema.GroupResource{Resource: "configmaps"}, inventoryName).Status()
+					}
+					obj = &status
+				case http.StatusNotFound:
+					status := apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, inventoryName).Status()
+					obj = &status
+				case http.StatusForbidden:
+					status := apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, inventoryName, fmt.Errorf("forbidden")).Status()
+					obj = &status
+				}
+
+				body := io.NopCloser(bytes.NewReader([]byte(runtime.EncodeOrDie(codec, obj))))
+				return &http.Response{StatusCode: response.statusCode, Body: body, Header: jpltesting.DefaultHeaders()}, nil
+			})
+
+			factory := jpltesting.NewTestClientFactory()
+			factory.Client = &restfake.RESTClient{Client: client}
+			clientset, err := factory.KubernetesClientSet()
+			require.NoError(t, err)
+
+			store := newConfigMapStore(clientset, inventoryName, namespace, fieldManager).(*configMapStore)
+			store.backoff = wait.Backoff{Steps: 2}
+
+			loaded, err := store.Load(context.TODO())
+			if len(test.expectedLoadError) > 0 {
+				assert.ErrorContains(t, err, test.expectedLoadError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedLoaded, loaded)
+
+			objects := make(sets.Set[*unstructured.Unstructured])
+			for _, objMeta := range test.objects {
+				objects.Insert(unstructuredFromMetadata(objMeta))
+			}
+			store.SetObjects(objects)
+
+			err = store.Save(context.TODO(), test.dryRun)
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+
+			require.Len(t, applied, len(test.expectedApplied))
+			for idx, expected := range test.expectedApplied {
+				assert.Equal(t, expected.ResourceVersion, applied[idx].ResourceVersion)
+				assert.Equal(t, expected.Data, applied[idx].Data)
+			}
+			assert.Equal(t, len(test.responses), requests)
+		})
+	}
+}
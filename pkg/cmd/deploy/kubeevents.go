@@ -0,0 +1,123 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/event"
+	apicorev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
+)
+
+const (
+	eventReasonApplied    = "Applied"
+	eventReasonDeployOnce = "SkippedDeployOnce"
+	eventReasonPruned     = "Pruned"
+	eventReasonFailed     = "Failed"
+
+	eventComponent  = "mlp"
+	eventController = "mia-platform.eu/mlp"
+
+	runIDAnnotation = "mia-platform.eu/deploy-run-id"
+)
+
+// kubeEventRecorder create a Kubernetes Event for every resource applied, pruned or skipped because deployed once, the events are attached to
+// the resource if it is namespaced or to the target namespace otherwise
+type kubeEventRecorder struct {
+	client    kubernetes.Interface
+	namespace string
+	runID     string
+	clock     clock.PassiveClock
+}
+
+// Record create the Kubernetes Event corresponding to e if any, failures are only logged to avoid blocking
+// the deploy for a missing permission
+func (r *kubeEventRecorder) Record(ctx context.Context, e event.Event) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	var obj *unstructured.Unstructured
+	var reason, message, eventType string
+	switch {
+	case e.Type == event.TypeApply && e.ApplyInfo.Status == event.StatusSuccessful:
+		obj, reason, eventType = e.ApplyInfo.Object, eventReasonApplied, apicorev1.EventTypeNormal
+		message = fmt.Sprintf("resource applied by mlp run %s", r.runID)
+	case isDeployOnceSkip(e):
+		obj, reason, eventType = e.ApplyInfo.Object, eventReasonDeployOnce, apicorev1.EventTypeNormal
+		message = fmt.Sprintf("resource deployed only once %s in mlp run %s", deployOnceMessage, r.runID)
+	case e.Type == event.TypeApply && e.ApplyInfo.Status == event.StatusFailed:
+		obj, reason, eventType = e.ApplyInfo.Object, eventReasonFailed, apicorev1.EventTypeWarning
+		message = fmt.Sprintf("apply failed in mlp run %s: %s", r.runID, e.ApplyInfo.Error)
+	case e.Type == event.TypePrune && e.PruneInfo.Status == event.StatusSuccessful:
+		obj, reason, eventType = e.PruneInfo.Object, eventReasonPruned, apicorev1.EventTypeNormal
+		message = fmt.Sprintf("resource pruned by mlp run %s", r.runID)
+	case e.Type == event.TypePrune && e.PruneInfo.Status == event.StatusFailed:
+		obj, reason, eventType = e.PruneInfo.Object, eventReasonFailed, apicorev1.EventTypeWarning
+		message = fmt.Sprintf("prune failed in mlp run %s: %s", r.runID, e.PruneInfo.Error)
+	default:
+		return
+	}
+
+	kubeEvent := r.newEvent(obj, reason, message, eventType)
+	if _, err := r.client.CoreV1().Events(kubeEvent.Namespace).Create(ctx, kubeEvent, metav1.CreateOptions{}); err != nil {
+		logger.V(3).Info("failed to create kubernetes event", "reason", reason, "object", obj.GetName(), "error", err.Error())
+	}
+}
+
+// newEvent return a new Event for obj
+func (r *kubeEventRecorder) newEvent(obj *unstructured.Unstructured, reason, message, eventType string) *apicorev1.Event {
+	involvedObject := apicorev1.ObjectReference{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Name:       obj.GetName(),
+		Namespace:  obj.GetNamespace(),
+		UID:        obj.GetUID(),
+	}
+
+	if len(obj.GetNamespace()) == 0 {
+		involvedObject = apicorev1.ObjectReference{
+			APIVersion: apicorev1.SchemeGroupVersion.String(),
+			Kind:       "Namespace",
+			Name:       r.namespace,
+		}
+		message = fmt.Sprintf("%s %s: %s", obj.GetKind(), obj.GetName(), message)
+	}
+
+	now := metav1.NewTime(r.clock.Now())
+	return &apicorev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: involvedObject.Name + ".",
+			Namespace:    cmp.Or(involvedObject.Namespace, r.namespace),
+			Annotations:  map[string]string{runIDAnnotation: r.runID},
+		},
+		InvolvedObject:      involvedObject,
+		Reason:              reason,
+		Message:             message,
+		Type:                eventType,
+		Source:              apicorev1.EventSource{Component: eventComponent},
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		ReportingController: eventController,
+		ReportingInstance:   r.runID,
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apicorev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestKubeEventRecorder(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-events-test"
+	fakeClock := clocktesting.NewFakePassiveClock(time.Date(1970, time.January, 0, 0, 0, 0, 0, time.UTC))
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName("example")
+	configMap.SetNamespace("other-namespace")
+	clusterRole := &unstructured.Unstructured{}
+	clusterRole.SetAPIVersion("rbac.authorization.k8s.io/v1")
+	clusterRole.SetKind("ClusterRole")
+	clusterRole.SetName("example")
+
+	tests := map[string]struct {
+		event           event.Event
+		expectedEvent   *apicorev1.Event
+		expectedNoEvent bool
+	}{
+		"applied namespaced resource": {
+			event: event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: configMap, Status: event.StatusSuccessful}},
+			expectedEvent: &apicorev1.Event{
+				InvolvedObject: apicorev1.ObjectReference{APIVersion: "v1", Kind: "ConfigMap", Name: "example", Namespace: "other-namespace"},
+				Reason:         eventReasonApplied,
+				Message:        "resource applied by mlp run run-id",
+				Type:           apicorev1.EventTypeNormal,
+			},
+		},
+		"failed apply": {
+			event: event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: configMap, Status: event.StatusFailed, Error: errors.New("error")}},
+			expectedEvent: &apicorev1.Event{
+				InvolvedObject: apicorev1.ObjectReference{APIVersion: "v1", Kind: "ConfigMap", Name: "example", Namespace: "other-namespace"},
+				Reason:         eventReasonFailed,
+				Message:        "apply failed in mlp run run-id: error",
+				Type:           apicorev1.EventTypeWarning,
+			},
+		},
+		"pruned cluster resource": {
+			event: event.Event{Type: event.TypePrune, PruneInfo: event.PruneInfo{Object: clusterRole, Status: event.StatusSuccessful}},
+			expectedEvent: &apicorev1.Event{
+				InvolvedObject: apicorev1.ObjectReference{APIVersion: "v1", Kind: "Namespace", Name: namespace},
+				Reason:         eventReasonPruned,
+				Message:        "ClusterRole example: resource pruned by mlp run run-id",
+				Type:           apicorev1.EventTypeNormal,
+			},
+		},
+		"pending apply is ignored": {
+			event:           event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: configMap, Status: event.StatusPending}},
+			expectedNoEvent: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset()
+			recorder := &kubeEventRecorder{client: client, namespace: namespace, runID: "run-id", clock: fakeClock}
+			recorder.Record(context.TODO(), test.event)
+
+			events, err := client.CoreV1().Events(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+			require.NoError(t, err)
+			if test.expectedNoEvent {
+				assert.Empty(t, events.Items)
+				return
+			}
+
+			require.Len(t, events.Items, 1)
+			kubeEvent := events.Items[0]
+			assert.Equal(t, test.expectedEvent.InvolvedObject, kubeEvent.InvolvedObject)
+			assert.Equal(t, cmp.Or(test.expectedEvent.InvolvedObject.Namespace, namespace), kubeEvent.Namespace)
+			assert.Equal(t, test.expectedEvent.Reason, kubeEvent.Reason)
+			assert.Equal(t, test.expectedEvent.Message, kubeEvent.Message)
+			assert.Equal(t, test.expectedEvent.Type, kubeEvent.Type)
+			assert.Equal(t, "run-id", kubeEvent.Annotations[runIDAnnotation])
+			assert.Equal(t, "run-id", kubeEvent.ReportingInstance)
+			assert.Equal(t, fakeClock.Now(), kubeEvent.LastTimestamp.Time)
+		})
+	}
+}
+
+func TestKubeEventRecorderDisabled(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{}
+	recorder, err := options.kubeEventRecorder(context.TODO(), "namespace", "run-id")
+	require.NoError(t, err)
+	assert.Nil(t, recorder)
+
+	options = &Options{kubernetesEvents: true, dryRun: true}
+	recorder, err = options.kubeEventRecorder(context.TODO(), "namespace", "run-id")
+	require.NoError(t, err)
+	assert.Nil(t, recorder)
+}
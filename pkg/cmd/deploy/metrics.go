@@ -0,0 +1,306 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/jpl/pkg/util"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	cliresource "k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	applyOperationCreate    = "create"
+	applyOperationPatch     = "patch"
+	applyOperationUnchanged = "unchanged"
+	applyOperationSkip      = "skip"
+	// applyOperationDeployOnce is used for the resources deployed only once that already exist
+	applyOperationDeployOnce = "skip-deploy-once"
+	applyOperationFailed     = "failed"
+)
+
+// applyMetrics contains the data measured during the apply of a single resource
+type applyMetrics struct {
+	Resource  string `json:"resource"`
+	Operation string `json:"operation"`
+	PatchSize int    `json:"patchSize"`
+	LatencyMs int64  `json:"latencyMs"`
+	Retries   int    `json:"retries"`
+
+	latency  time.Duration
+	attempts int
+}
+
+// metricsRecorder accumulate the applyMetrics of the resources, the apply requests are measured by the
+// metricsTransport and the skipped resources are received from the apply events
+type metricsRecorder struct {
+	lock    sync.Mutex
+	metrics map[string]*applyMetrics
+}
+
+func newMetricsRecorder() *metricsRecorder {
+	return &metricsRecorder{metrics: make(map[string]*applyMetrics)}
+}
+
+// Collect record the resources skipped by the apply filters
+func (r *metricsRecorder) Collect(e event.Event) {
+	if e.Type != event.TypeApply || e.ApplyInfo.Status != event.StatusSkipped {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	metrics := r.metricsFor(summaryIdentifier(resource.ObjectMetadataFromUnstructured(e.ApplyInfo.Object)))
+	metrics.Operation = applyOperationSkip
+	if isDeployOnceSkip(e) {
+		metrics.Operation = applyOperationDeployOnce
+	}
+}
+
+// recordRequest add a single apply request for identifier to its metrics, a resource requested multiple times
+// is counted as retried
+func (r *metricsRecorder) recordRequest(identifier string, size int, latency time.Duration, statusCode int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	metrics := r.metricsFor(identifier)
+	metrics.attempts++
+	metrics.latency += latency
+	metrics.PatchSize = size
+
+	switch statusCode {
+	case http.StatusCreated:
+		metrics.Operation = applyOperationCreate
+	case http.StatusOK:
+		metrics.Operation = applyOperationPatch
+	default:
+		metrics.Operation = applyOperationFailed
+	}
+}
+
+// recordUnchanged mark the last apply request for identifier as a patch that has not modified the resource
+func (r *metricsRecorder) recordUnchanged(identifier string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.metricsFor(identifier).Operation = applyOperationUnchanged
+}
+
+// operation return the last operation recorded for identifier, or an empty string if it has not been applied
+func (r *metricsRecorder) operation(identifier string) string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if metrics, found := r.metrics[identifier]; found {
+		return metrics.Operation
+	}
+	return ""
+}
+
+func (r *metricsRecorder) metricsFor(identifier string) *applyMetrics {
+	metrics, found := r.metrics[identifier]
+	if !found {
+		metrics = &applyMetrics{Resource: identifier}
+		r.metrics[identifier] = metrics
+	}
+
+	return metrics
+}
+
+// Metrics return the metrics recorded until now ordered from the slowest resource
+func (r *metricsRecorder) Metrics() []applyMetrics {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	metrics := make([]applyMetrics, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metric := *m
+		metric.LatencyMs = m.latency.Milliseconds()
+		metric.Retries = max(m.attempts-1, 0)
+		metrics = append(metrics, metric)
+	}
+
+	slices.SortFunc(metrics, func(a, b applyMetrics) int {
+		return cmp.Or(cmp.Compare(b.latency, a.latency), cmp.Compare(a.Resource, b.Resource))
+	})
+	return metrics
+}
+
+// printApplyReport write a table with metrics in writer
+func printApplyReport(writer io.Writer, metrics []applyMetrics) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	tabWriter := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, "RESOURCE\tOPERATION\tPATCH SIZE\tLATENCY\tRETRIES")
+	for _, m := range metrics {
+		fmt.Fprintf(tabWriter, "%s\t%s\t%d\t%s\t%d\n", m.Resource, m.Operation, m.PatchSize, m.latency.Round(time.Millisecond), m.Retries)
+	}
+
+	return tabWriter.Flush()
+}
+
+// metricsTransport measure the server side apply requests made through next
+type metricsTransport struct {
+	next     http.RoundTripper
+	recorder *metricsRecorder
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPatch || req.Header.Get("Content-Type") != string(types.ApplyPatchType) || req.GetBody == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	identifier, size, err := t.requestObject(req)
+	if err != nil {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	response, err := t.next.RoundTrip(req)
+	statusCode := 0
+	if err == nil {
+		statusCode = response.StatusCode
+	}
+	t.recorder.recordRequest(identifier, size, time.Since(start), statusCode)
+	if statusCode == http.StatusOK && unchangedByApply(req, response) {
+		t.recorder.recordUnchanged(identifier)
+	}
+	return response, err
+}
+
+// unchangedByApply return true if the apply request has not modified the object returned in response. The api-server
+// update the time of the managed fields entry of the manager only when the apply modify the object, so an entry
+// older than the response means that the object was already in the applied state.
+func unchangedByApply(req *http.Request, response *http.Response) bool {
+	manager := req.URL.Query().Get("fieldManager")
+	responseTime, err := http.ParseTime(response.Header.Get("Date"))
+	if len(manager) == 0 || err != nil || response.Body == nil {
+		return false
+	}
+
+	data, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+
+	obj := struct {
+		Metadata struct {
+			ManagedFields []metav1.ManagedFieldsEntry `json:"managedFields"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return false
+	}
+
+	for _, entry := range obj.Metadata.ManagedFields {
+		if entry.Manager != manager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.Time == nil {
+			continue
+		}
+
+		// the times have a precision of one second, so the entry must be older than the previous second
+		return entry.Time.Add(time.Second).Before(responseTime)
+	}
+
+	return false
+}
+
+// requestObject return the identifier and the size of the object sent in the body of req
+func (t *metricsTransport) requestObject(req *http.Request) (string, int, error) {
+	body, err := req.GetBody()
+	if err != nil {
+		return "", 0, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	obj := struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return "", 0, err
+	}
+
+	gv, err := schema.ParseGroupVersion(obj.APIVersion)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return summaryIdentifier(resource.ObjectMetadata{Group: gv.Group, Kind: obj.Kind, Name: obj.Metadata.Name}), len(data), nil
+}
+
+// metricsFactory wrap a ClientFactory for measuring the apply requests made with the clients it returns
+type metricsFactory struct {
+	util.ClientFactory
+	recorder *metricsRecorder
+}
+
+// newMetricsFactory return a ClientFactory that record the apply requests in recorder
+func newMetricsFactory(factory util.ClientFactory, recorder *metricsRecorder) util.ClientFactory {
+	return &metricsFactory{
+		ClientFactory: factory,
+		recorder:      recorder,
+	}
+}
+
+// UnstructuredClientForMapping override the ClientFactory method wrapping the transport of the returned client
+func (f *metricsFactory) UnstructuredClientForMapping(mapping *meta.RESTMapping) (cliresource.RESTClient, error) {
+	client, err := f.ClientFactory.UnstructuredClientForMapping(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	restClient, ok := client.(*rest.RESTClient)
+	if !ok || restClient.Client == nil {
+		return client, nil
+	}
+
+	httpClient := *restClient.Client
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = &metricsTransport{next: next, recorder: f.recorder}
+	restClient.Client = &httpClient
+	return restClient, nil
+}
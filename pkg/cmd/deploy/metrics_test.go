@@ -0,0 +1,213 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	cliresource "k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest"
+)
+
+func TestMetricsRecorder(t *testing.T) {
+	t.Parallel()
+
+	skipped := &unstructured.Unstructured{}
+	skipped.SetAPIVersion("v1")
+	skipped.SetKind("Secret")
+	skipped.SetName("once")
+
+	recorder := newMetricsRecorder()
+	recorder.recordRequest("ConfigMap/example", 120, 10*time.Millisecond, http.StatusCreated)
+	recorder.recordRequest("Deployment.apps/example", 2048, 300*time.Millisecond, http.StatusTooManyRequests)
+	recorder.recordRequest("Deployment.apps/example", 2048, 200*time.Millisecond, http.StatusOK)
+	recorder.recordRequest("CustomResourceDefinition.apiextensions.k8s.io/example", 4096, 50*time.Millisecond, http.StatusUnprocessableEntity)
+	recorder.Collect(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: skipped, Status: event.StatusSkipped}})
+	recorder.Collect(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: skipped, Status: event.StatusSuccessful}})
+
+	metrics := recorder.Metrics()
+	assert.Equal(t, []applyMetrics{
+		{Resource: "Deployment.apps/example", Operation: applyOperationPatch, PatchSize: 2048, LatencyMs: 500, Retries: 1, latency: 500 * time.Millisecond, attempts: 2},
+		{Resource: "CustomResourceDefinition.apiextensions.k8s.io/example", Operation: applyOperationFailed, PatchSize: 4096, LatencyMs: 50, latency: 50 * time.Millisecond, attempts: 1},
+		{Resource: "ConfigMap/example", Operation: applyOperationCreate, PatchSize: 120, LatencyMs: 10, latency: 10 * time.Millisecond, attempts: 1},
+		{Resource: "Secret/once", Operation: applyOperationSkip},
+	}, metrics)
+
+	output := new(strings.Builder)
+	require.NoError(t, printApplyReport(output, metrics))
+	assert.Equal(t, `RESOURCE                                               OPERATION  PATCH SIZE  LATENCY  RETRIES
+Deployment.apps/example                                patch      2048        500ms    1
+CustomResourceDefinition.apiextensions.k8s.io/example  failed     4096        50ms     0
+ConfigMap/example                                      create     120         10ms     0
+Secret/once                                            skip       0           0s       0
+`, output.String())
+
+	output.Reset()
+	require.NoError(t, printApplyReport(output, nil))
+	assert.Empty(t, output.String())
+}
+
+// restClientFactory return real RESTClients pointing to host
+type restClientFactory struct {
+	util.ClientFactory
+	host string
+}
+
+func (f *restClientFactory) UnstructuredClientForMapping(mapping *meta.RESTMapping) (cliresource.RESTClient, error) {
+	gv := mapping.GroupVersionKind.GroupVersion()
+	return rest.RESTClientFor(&rest.Config{
+		Host:    f.host,
+		APIPath: "/apis",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &gv,
+			NegotiatedSerializer: cliresource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+		},
+	})
+}
+
+func TestMetricsFactory(t *testing.T) {
+	t.Parallel()
+
+	requests := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method != http.MethodPatch:
+			w.WriteHeader(http.StatusOK)
+		case requests.Add(1) == 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+		_, _ = w.Write([]byte(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"example"}}`))
+	}))
+	defer server.Close()
+
+	recorder := newMetricsRecorder()
+	factory := newMetricsFactory(&restClientFactory{host: server.URL}, recorder)
+	client, err := factory.UnstructuredClientForMapping(&meta.RESTMapping{
+		GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+	})
+	require.NoError(t, err)
+
+	body := []byte(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"example"}}`)
+	err = client.Patch(types.ApplyPatchType).Resource("deployments").Name("example").Body(body).Do(context.TODO()).Error()
+	require.NoError(t, err)
+	err = client.Get().Resource("deployments").Name("example").Do(context.TODO()).Error()
+	require.NoError(t, err)
+
+	metrics := recorder.Metrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "Deployment.apps/example", metrics[0].Resource)
+	assert.Equal(t, applyOperationCreate, metrics[0].Operation)
+	assert.Equal(t, len(body), metrics[0].PatchSize)
+	assert.Equal(t, 1, metrics[0].Retries)
+}
+
+func TestUnchangedByApply(t *testing.T) {
+	t.Parallel()
+
+	responseTime := time.Date(2024, time.March, 1, 10, 0, 30, 0, time.UTC)
+	body := func(manager, operation string, entryTime time.Time) string {
+		return `{"metadata":{"name":"example","managedFields":[{"manager":"` + manager + `","operation":"` + operation +
+			`","time":"` + entryTime.Format(time.RFC3339) + `"}]}}`
+	}
+
+	tests := map[string]struct {
+		query    string
+		date     string
+		body     string
+		expected bool
+	}{
+		"entry older than the response": {
+			query:    "fieldManager=mlp",
+			date:     responseTime.Format(http.TimeFormat),
+			body:     body("mlp", "Apply", responseTime.Add(-time.Hour)),
+			expected: true,
+		},
+		"entry updated by the request": {
+			query: "fieldManager=mlp",
+			date:  responseTime.Format(http.TimeFormat),
+			body:  body("mlp", "Apply", responseTime),
+		},
+		"entry updated in the previous second": {
+			query: "fieldManager=mlp",
+			date:  responseTime.Format(http.TimeFormat),
+			body:  body("mlp", "Apply", responseTime.Add(-time.Second)),
+		},
+		"entry of another manager": {
+			query: "fieldManager=mlp",
+			date:  responseTime.Format(http.TimeFormat),
+			body:  body("kubectl", "Apply", responseTime.Add(-time.Hour)),
+		},
+		"entry of an update operation": {
+			query: "fieldManager=mlp",
+			date:  responseTime.Format(http.TimeFormat),
+			body:  body("mlp", "Update", responseTime.Add(-time.Hour)),
+		},
+		"missing field manager": {
+			date: responseTime.Format(http.TimeFormat),
+			body: body("mlp", "Apply", responseTime.Add(-time.Hour)),
+		},
+		"missing date": {
+			query: "fieldManager=mlp",
+			body:  body("mlp", "Apply", responseTime.Add(-time.Hour)),
+		},
+		"invalid body": {
+			query: "fieldManager=mlp",
+			date:  responseTime.Format(http.TimeFormat),
+			body:  "{",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPatch, "/apis/apps/v1/deployments/example?"+test.query, nil)
+			response := &http.Response{
+				Header: http.Header{},
+				Body:   io.NopCloser(strings.NewReader(test.body)),
+			}
+			if len(test.date) > 0 {
+				response.Header.Set("Date", test.date)
+			}
+
+			assert.Equal(t, test.expected, unchangedByApply(req, response))
+			if len(test.date) > 0 && strings.Contains(test.query, "fieldManager") {
+				data, err := io.ReadAll(response.Body)
+				require.NoError(t, err)
+				assert.Equal(t, test.body, string(data), "the response body must remain readable")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/jpl/pkg/util"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	namespaceMismatchOverride = "override"
+	namespaceMismatchFail     = "fail"
+	namespaceMismatchSkip     = "skip"
+)
+
+var validNamespaceMismatchValues = []string{namespaceMismatchOverride, namespaceMismatchFail, namespaceMismatchSkip}
+
+// manifestNamespaceFactory wrap a ClientFactory for disabling the enforcement of the namespace set via flag,
+// allowing the resources to keep the namespace declared in their manifests
+type manifestNamespaceFactory struct {
+	util.ClientFactory
+}
+
+// ToRawKubeConfigLoader override the ClientFactory method wrapping the returned ClientConfig
+func (f *manifestNamespaceFactory) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return &manifestNamespaceClientConfig{delegate: f.ClientFactory.ToRawKubeConfigLoader()}
+}
+
+// manifestNamespaceClientConfig wrap a ClientConfig for never enforcing the namespace
+type manifestNamespaceClientConfig struct {
+	delegate clientcmd.ClientConfig
+}
+
+// RawConfig implement clientcmd.ClientConfig interface
+func (c *manifestNamespaceClientConfig) RawConfig() (clientcmdapi.Config, error) {
+	return c.delegate.RawConfig()
+}
+
+// ClientConfig implement clientcmd.ClientConfig interface
+func (c *manifestNamespaceClientConfig) ClientConfig() (*rest.Config, error) {
+	return c.delegate.ClientConfig()
+}
+
+// Namespace implement clientcmd.ClientConfig interface
+func (c *manifestNamespaceClientConfig) Namespace() (string, bool, error) {
+	namespace, _, err := c.delegate.Namespace()
+	return namespace, false, err
+}
+
+// ConfigAccess implement clientcmd.ClientConfig interface
+func (c *manifestNamespaceClientConfig) ConfigAccess() clientcmd.ConfigAccess {
+	return c.delegate.ConfigAccess()
+}
+
+// namespacesFromResources return the sorted list of namespaces used by resources plus the defaultNamespace
+func namespacesFromResources(defaultNamespace string, resources []*unstructured.Unstructured) []string {
+	namespaces := sets.New(defaultNamespace)
+	for _, res := range resources {
+		if namespace := res.GetNamespace(); len(namespace) > 0 {
+			namespaces.Insert(namespace)
+		}
+	}
+
+	return sets.List(namespaces)
+}
+
+// guardNamespace handle the resources that declare a namespace different from the one set via flag following the
+// namespace mismatch mode: the deploy fails listing all of them, or their namespace is overridden, or they are
+// skipped; every override and skip is reported to the user
+func (o *Options) guardNamespace(namespace string, resources []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	guardedResources := make([]*unstructured.Unstructured, 0, len(resources))
+	mismatches := make([]string, 0)
+	for _, res := range resources {
+		resNamespace := res.GetNamespace()
+		if len(resNamespace) == 0 || resNamespace == namespace {
+			guardedResources = append(guardedResources, res)
+			continue
+		}
+
+		identifier := summaryIdentifier(resource.ObjectMetadataFromUnstructured(res))
+		switch o.namespaceMismatch {
+		case namespaceMismatchOverride:
+			fmt.Fprintf(o.writer, "%s: namespace overridden from %q to %q\n", identifier, resNamespace, namespace)
+			res.SetNamespace(namespace)
+			guardedResources = append(guardedResources, res)
+		case namespaceMismatchSkip:
+			fmt.Fprintf(o.writer, "%s: skipped because its namespace %q is different from %q\n", identifier, resNamespace, namespace)
+		default:
+			mismatches = append(mismatches, fmt.Sprintf("%s in namespace %q", identifier, resNamespace))
+		}
+	}
+
+	if len(mismatches) == 0 {
+		return guardedResources, nil
+	}
+
+	builder := new(strings.Builder)
+	builder.WriteString(fmt.Sprintf("%d resource(s) declare a namespace different from %q, use --%s for overriding or skipping them:\n", len(mismatches), namespace, namespaceMismatchFlagName))
+	for _, mismatch := range mismatches {
+		builder.WriteString(fmt.Sprintf("\t- %s\n", mismatch))
+	}
+	return nil, fmt.Errorf("%s", builder.String())
+}
+
+var _ clientcmd.ClientConfig = &manifestNamespaceClientConfig{}
@@ -0,0 +1,185 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestManifestNamespaceClientConfig(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-manifest-namespace"
+	factory := &manifestNamespaceFactory{ClientFactory: jpltesting.NewTestClientFactory().WithNamespace(namespace)}
+
+	foundNamespace, enforce, err := factory.ToRawKubeConfigLoader().Namespace()
+	require.NoError(t, err)
+	assert.Equal(t, namespace, foundNamespace)
+	assert.False(t, enforce)
+}
+
+func TestNamespacesFromResources(t *testing.T) {
+	t.Parallel()
+
+	resourceInNamespace := func(namespace string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetNamespace(namespace)
+		return obj
+	}
+
+	tests := map[string]struct {
+		resources          []*unstructured.Unstructured
+		expectedNamespaces []string
+	}{
+		"no resources": {
+			expectedNamespaces: []string{"default"},
+		},
+		"resources in multiple namespaces": {
+			resources: []*unstructured.Unstructured{
+				resourceInNamespace("default"),
+				resourceInNamespace("zeta"),
+				resourceInNamespace(""),
+				resourceInNamespace("alpha"),
+				resourceInNamespace("zeta"),
+			},
+			expectedNamespaces: []string{"alpha", "default", "zeta"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expectedNamespaces, namespacesFromResources("default", test.resources))
+		})
+	}
+}
+
+func TestReadResourcesWithNamespaceFromManifest(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-manifest-namespace"
+	options := &Options{
+		inputPaths:            []string{filepath.Join("testdata", "multi-namespace")},
+		namespaceFromManifest: true,
+		clientFactory:         jpltesting.NewTestClientFactory().WithNamespace(namespace),
+	}
+
+	resources, err := options.readResources(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, resources, 3)
+
+	namespaces := make(map[string]string, len(resources))
+	for _, res := range resources {
+		namespaces[res.GetName()] = res.GetNamespace()
+	}
+	assert.Equal(t, map[string]string{
+		"default-namespace": namespace,
+		"other-namespace":   "mlp-other-namespace",
+		"cluster-role":      "",
+	}, namespaces)
+	assert.Equal(t, []string{namespace, "mlp-other-namespace"}, namespacesFromResources(namespace, resources))
+}
+
+func TestGuardNamespace(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-guard-namespace"
+	tests := map[string]struct {
+		mode               string
+		expectedNamespaces map[string]string
+		expectedOutput     string
+		expectedError      string
+	}{
+		"fail by default": {
+			expectedError: `1 resource(s) declare a namespace different from "mlp-guard-namespace", use --namespace-mismatch for overriding or skipping them:
+	- ConfigMap/other-namespace in namespace "mlp-other-namespace"
+`,
+		},
+		"fail": {
+			mode:          namespaceMismatchFail,
+			expectedError: `ConfigMap/other-namespace in namespace "mlp-other-namespace"`,
+		},
+		"override": {
+			mode: namespaceMismatchOverride,
+			expectedNamespaces: map[string]string{
+				"default-namespace": namespace,
+				"other-namespace":   namespace,
+				"cluster-role":      "",
+			},
+			expectedOutput: `ConfigMap/other-namespace: namespace overridden from "mlp-other-namespace" to "mlp-guard-namespace"` + "\n",
+		},
+		"skip": {
+			mode: namespaceMismatchSkip,
+			expectedNamespaces: map[string]string{
+				"default-namespace": namespace,
+				"cluster-role":      "",
+			},
+			expectedOutput: `ConfigMap/other-namespace: skipped because its namespace "mlp-other-namespace" is different from "mlp-guard-namespace"` + "\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			writer := new(strings.Builder)
+			options := &Options{
+				inputPaths:        []string{filepath.Join("testdata", "multi-namespace")},
+				namespaceMismatch: test.mode,
+				clientFactory:     jpltesting.NewTestClientFactory().WithNamespace(namespace),
+				writer:            writer,
+			}
+
+			resources, err := options.readResources(context.TODO())
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			namespaces := make(map[string]string, len(resources))
+			for _, res := range resources {
+				namespaces[res.GetName()] = res.GetNamespace()
+			}
+			assert.Equal(t, test.expectedNamespaces, namespaces)
+			assert.Equal(t, test.expectedOutput, writer.String())
+		})
+	}
+}
+
+func TestGuardNamespaceFromManifest(t *testing.T) {
+	t.Parallel()
+
+	options := &Options{
+		inputPaths:            []string{filepath.Join("testdata", "multi-namespace")},
+		namespaceFromManifest: true,
+		clientFactory:         jpltesting.NewTestClientFactory().WithNamespace("mlp-guard-namespace"),
+		writer:                new(strings.Builder),
+	}
+
+	resources, err := options.readResources(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, resources, 3)
+	assert.Equal(t, "mlp-other-namespace", resources[1].GetNamespace())
+}
@@ -0,0 +1,63 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// disablePrune limit inventory to resources, so the objects it tracks that are no longer in the manifests are not
+// pruned and are kept in it for the next deploy
+func disablePrune(inventory *Inventory, resources []*unstructured.Unstructured) {
+	identifiers := make([]resource.ObjectMetadata, 0, len(resources))
+	for _, res := range resources {
+		identifiers = append(identifiers, resource.ObjectMetadataFromUnstructured(res))
+	}
+	inventory.SelectObjects(identifiers...)
+}
+
+// printSkippedPrune write the objects tracked by inventory that would have been pruned, excluding the ones applied
+// during the deploy like the generated resources
+func printSkippedPrune(writer io.Writer, inventory *Inventory, applied sets.Set[resource.ObjectMetadata]) {
+	skipped := inventory.RetainedObjects().Difference(applied).UnsortedList()
+	if len(skipped) == 0 {
+		return
+	}
+
+	slices.SortFunc(skipped, func(a, b resource.ObjectMetadata) int {
+		return cmp.Or(
+			strings.Compare(a.Namespace, b.Namespace),
+			strings.Compare(summaryIdentifier(a), summaryIdentifier(b)),
+		)
+	})
+
+	fmt.Fprintf(writer, "prune disabled, %d resource(s) not pruned:\n", len(skipped))
+	for _, objMeta := range skipped {
+		if len(objMeta.Namespace) == 0 {
+			fmt.Fprintf(writer, "\t- %s\n", summaryIdentifier(objMeta))
+			continue
+		}
+		fmt.Fprintf(writer, "\t- %s in namespace %q\n", summaryIdentifier(objMeta), objMeta.Namespace)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestDisablePrune(t *testing.T) {
+	t.Parallel()
+
+	api := resource.ObjectMetadata{Group: "apps", Kind: "Deployment", Name: "api", Namespace: "test"}
+	worker := resource.ObjectMetadata{Group: "apps", Kind: "Deployment", Name: "worker", Namespace: "test"}
+	role := resource.ObjectMetadata{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "reader"}
+	job := resource.ObjectMetadata{Group: "batch", Kind: "Job", Name: "cronjob-generated", Namespace: "test"}
+
+	store := &recordingStore{objects: sets.New(api, worker, role, job)}
+	inventory := &Inventory{delegate: store, trackedObjects: make(sets.Set[resource.ObjectMetadata])}
+	disablePrune(inventory, []*unstructured.Unstructured{testSelectObject("api", nil, nil)})
+
+	loaded, err := inventory.Load(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, sets.New(api), loaded, "only the resources to apply can be pruned")
+
+	inventory.SetObjects(sets.New(testSelectObject("api", nil, nil)))
+	saved := sets.New[resource.ObjectMetadata]()
+	for obj := range store.saved {
+		saved.Insert(resource.ObjectMetadataFromUnstructured(obj))
+	}
+	assert.Equal(t, sets.New(api, worker, role, job), saved, "the resources not pruned must remain in the inventory")
+
+	buffer := new(bytes.Buffer)
+	printSkippedPrune(buffer, inventory, sets.New(api, job))
+	assert.Equal(t, `prune disabled, 2 resource(s) not pruned:
+	- ClusterRole.rbac.authorization.k8s.io/reader
+	- Deployment.apps/worker in namespace "test"
+`, buffer.String())
+
+	buffer.Reset()
+	printSkippedPrune(buffer, inventory, sets.New(api, worker, role))
+	assert.Equal(t, "prune disabled, 1 resource(s) not pruned:\n\t- Job.batch/cronjob-generated in namespace \"test\"\n", buffer.String())
+
+	buffer.Reset()
+	printSkippedPrune(buffer, inventory, sets.New(api, worker, role, job))
+	assert.Empty(t, buffer.String())
+}
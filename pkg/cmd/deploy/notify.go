@@ -0,0 +1,229 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+)
+
+const (
+	summaryStatusSucceeded = "succeeded"
+	summaryStatusFailed    = "failed"
+
+	notificationTimeout = 10 * time.Second
+)
+
+// deploySummary contains the data sent to the notification endpoints at the end of a deploy
+type deploySummary struct {
+	Namespace   string   `json:"namespace"`
+	Status      string   `json:"status"`
+	Applied     []string `json:"applied"`
+	Pruned      []string `json:"pruned"`
+	DeployOnce  []string `json:"deployOnce,omitempty"`
+	Failures    []string `json:"failures"`
+	Duration    string   `json:"duration"`
+	PipelineURL string   `json:"pipelineUrl,omitempty"`
+
+	Resources []applyMetrics `json:"resources,omitempty"`
+	Kinds     []kindSummary  `json:"kinds,omitempty"`
+}
+
+// summaryCollector accumulate the events received during the deploy for creating a deploySummary
+type summaryCollector struct {
+	start      time.Time
+	applied    []string
+	pruned     []string
+	deployOnce []string
+	failures   []string
+	// outcomes contains the final outcome of every resource, a failure is never overridden by later events
+	outcomes map[string]string
+}
+
+// Collect add the relevant information of e to the summary
+func (c *summaryCollector) Collect(e event.Event) {
+	if identifier, outcome := outcomeFromEvent(e); len(outcome) > 0 {
+		if c.outcomes == nil {
+			c.outcomes = make(map[string]string)
+		}
+		if c.outcomes[identifier] != outcomeFailed {
+			c.outcomes[identifier] = outcome
+		}
+	}
+
+	switch {
+	case e.Type == event.TypeApply && e.ApplyInfo.Status == event.StatusSuccessful:
+		c.applied = append(c.applied, summaryIdentifier(resource.ObjectMetadataFromUnstructured(e.ApplyInfo.Object)))
+	case e.Type == event.TypePrune && e.PruneInfo.Status == event.StatusSuccessful:
+		c.pruned = append(c.pruned, summaryIdentifier(resource.ObjectMetadataFromUnstructured(e.PruneInfo.Object)))
+	case isDeployOnceSkip(e):
+		c.deployOnce = append(c.deployOnce, summaryIdentifier(resource.ObjectMetadataFromUnstructured(e.ApplyInfo.Object)))
+	case e.IsErrorEvent():
+		c.failures = append(c.failures, e.String())
+	}
+}
+
+// Summary return the deploySummary with the data collected until now
+func (c *summaryCollector) Summary(namespace, pipelineURL string, end time.Time) deploySummary {
+	status := summaryStatusSucceeded
+	if len(c.failures) > 0 {
+		status = summaryStatusFailed
+	}
+
+	return deploySummary{
+		Namespace:   namespace,
+		Status:      status,
+		Applied:     nonNilSlice(c.applied),
+		Pruned:      nonNilSlice(c.pruned),
+		DeployOnce:  c.deployOnce,
+		Failures:    nonNilSlice(c.failures),
+		Duration:    end.Sub(c.start).Truncate(time.Second).String(),
+		PipelineURL: pipelineURL,
+	}
+}
+
+// KindSummaries return the outcomes collected until now grouped by kind, using metrics for distinguishing the
+// created, configured and unchanged resources
+func (c *summaryCollector) KindSummaries(metrics []applyMetrics) []kindSummary {
+	return kindSummaries(c.outcomes, metrics)
+}
+
+// summaryIdentifier return a readable identifier for objMeta
+func summaryIdentifier(objMeta resource.ObjectMetadata) string {
+	kind := objMeta.Kind
+	if len(objMeta.Group) > 0 {
+		kind = objMeta.Kind + "." + objMeta.Group
+	}
+	return kind + "/" + objMeta.Name
+}
+
+func nonNilSlice(slice []string) []string {
+	if slice == nil {
+		return []string{}
+	}
+	return slice
+}
+
+// notifier send a deploySummary to a list of webhooks, or print the payload if dryRun is true
+type notifier struct {
+	urls     []string
+	template *template.Template
+	client   *http.Client
+	writer   io.Writer
+	dryRun   bool
+}
+
+// newNotifier return a new notifier for urls, the payload will be rendered with the template at templatePath
+// or encoded as json if the path is empty
+func newNotifier(urls []string, templatePath string, writer io.Writer, dryRun bool) (*notifier, error) {
+	n := &notifier{
+		urls:   urls,
+		client: &http.Client{Timeout: notificationTimeout},
+		writer: writer,
+		dryRun: dryRun,
+	}
+
+	if len(templatePath) == 0 {
+		return n, nil
+	}
+
+	data, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification template: %w", err)
+	}
+
+	n.template, err = template.New("notification").Funcs(template.FuncMap{
+		"join":   strings.Join,
+		"toJson": toJSON,
+	}).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse notification template: %w", err)
+	}
+
+	return n, nil
+}
+
+// Notify send summary to all the configured urls, every failure is returned without stopping the other calls
+func (n *notifier) Notify(ctx context.Context, summary deploySummary) []error {
+	payload, err := n.payload(summary)
+	if err != nil {
+		return []error{err}
+	}
+
+	if n.dryRun {
+		fmt.Fprintf(n.writer, "notification payload:\n%s\n", payload)
+		return nil
+	}
+
+	var errs []error
+	for _, url := range n.urls {
+		if err := n.send(ctx, url, payload); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send notification to %q: %w", url, err))
+		}
+	}
+
+	return errs
+}
+
+// payload return the body of the notification for summary
+func (n *notifier) payload(summary deploySummary) ([]byte, error) {
+	if n.template == nil {
+		return json.Marshal(summary)
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := n.template.Execute(buffer, summary); err != nil {
+		return nil, fmt.Errorf("failed to render notification template: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (n *notifier) send(ctx context.Context, url string, payload []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
@@ -0,0 +1,157 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSummaryCollector(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(1970, time.January, 0, 0, 0, 0, 0, time.UTC)
+	deployment := &unstructured.Unstructured{}
+	deployment.SetAPIVersion("apps/v1")
+	deployment.SetKind("Deployment")
+	deployment.SetName("example")
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName("example")
+
+	collector := &summaryCollector{start: start}
+	assert.Equal(t, deploySummary{
+		Namespace: "namespace",
+		Status:    summaryStatusSucceeded,
+		Applied:   []string{},
+		Pruned:    []string{},
+		Failures:  []string{},
+		Duration:  "0s",
+	}, collector.Summary("namespace", "", start))
+
+	collector.Collect(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: deployment, Status: event.StatusPending}})
+	collector.Collect(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: deployment, Status: event.StatusSuccessful}})
+	collector.Collect(event.Event{Type: event.TypePrune, PruneInfo: event.PruneInfo{Object: configMap, Status: event.StatusSuccessful}})
+	collector.Collect(event.Event{Type: event.TypeError, ErrorInfo: event.ErrorInfo{Error: errors.New("error")}})
+
+	assert.Equal(t, deploySummary{
+		Namespace:   "namespace",
+		Status:      summaryStatusFailed,
+		Applied:     []string{"Deployment.apps/example"},
+		Pruned:      []string{"ConfigMap/example"},
+		Failures:    []string{"error"},
+		Duration:    "1m30s",
+		PipelineURL: "https://example.com/pipeline",
+	}, collector.Summary("namespace", "https://example.com/pipeline", start.Add(90*time.Second)))
+}
+
+func TestNotifier(t *testing.T) {
+	t.Parallel()
+
+	testdata := filepath.Join("testdata", "notifications")
+	summary := deploySummary{
+		Namespace: "namespace",
+		Status:    summaryStatusSucceeded,
+		Applied:   []string{"Deployment.apps/example", "ConfigMap/example"},
+		Pruned:    []string{},
+		Failures:  []string{},
+		Duration:  "10s",
+	}
+
+	tests := map[string]struct {
+		templatePath     string
+		dryRun           bool
+		responseStatus   int
+		expectedPayload  string
+		expectedOutput   string
+		expectedErrors   []string
+		expectedNewError string
+	}{
+		"default json payload": {
+			responseStatus:  http.StatusOK,
+			expectedPayload: `{"namespace":"namespace","status":"succeeded","applied":["Deployment.apps/example","ConfigMap/example"],"pruned":[],"failures":[],"duration":"10s"}`,
+		},
+		"custom template": {
+			templatePath:    filepath.Join(testdata, "slack.tmpl"),
+			responseStatus:  http.StatusOK,
+			expectedPayload: `{"text": "Deploy in namespace succeeded in 10s: Deployment.apps/example, ConfigMap/example"}` + "\n",
+		},
+		"endpoint error": {
+			responseStatus: http.StatusInternalServerError,
+			expectedErrors: []string{"unexpected status code 500"},
+		},
+		"dry run print the payload": {
+			dryRun:         true,
+			expectedOutput: "notification payload:\n" + `{"namespace":"namespace","status":"succeeded","applied":["Deployment.apps/example","ConfigMap/example"],"pruned":[],"failures":[],"duration":"10s"}` + "\n",
+		},
+		"missing template": {
+			templatePath:     filepath.Join(testdata, "missing.tmpl"),
+			expectedNewError: "failed to read notification template",
+		},
+		"invalid template": {
+			templatePath:     filepath.Join(testdata, "invalid.tmpl"),
+			expectedNewError: "failed to parse notification template",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var receivedPayload string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				receivedPayload = string(body)
+				w.WriteHeader(test.responseStatus)
+			}))
+			defer server.Close()
+
+			output := new(strings.Builder)
+			n, err := newNotifier([]string{server.URL}, test.templatePath, output, test.dryRun)
+			if len(test.expectedNewError) > 0 {
+				assert.ErrorContains(t, err, test.expectedNewError)
+				return
+			}
+			require.NoError(t, err)
+
+			errs := n.Notify(context.TODO(), summary)
+			require.Len(t, errs, len(test.expectedErrors))
+			for i, expectedError := range test.expectedErrors {
+				assert.ErrorContains(t, errs[i], expectedError)
+			}
+			assert.Equal(t, test.expectedOutput, output.String())
+			if len(test.expectedPayload) > 0 {
+				assert.Equal(t, test.expectedPayload, receivedPayload)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/client/cache"
+	"github.com/mia-platform/jpl/pkg/generator"
+	"github.com/mia-platform/jpl/pkg/mutator"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/jpl/pkg/util"
+	"github.com/mia-platform/mlp/v2/pkg/capabilities"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// offlineFactory wrap a ClientFactory for using a RESTMapper loaded from a cluster snapshot instead of the
+// discovery APIs of the cluster
+type offlineFactory struct {
+	util.ClientFactory
+	mapper meta.RESTMapper
+}
+
+// ToRESTMapper override the ClientFactory method returning the snapshot mapper
+func (f *offlineFactory) ToRESTMapper() (meta.RESTMapper, error) {
+	return f.mapper, nil
+}
+
+// offlineResourceGetter implement cache.RemoteResourceGetter without contacting the cluster, every resource
+// is considered as not existing
+type offlineResourceGetter struct{}
+
+// Get implement cache.RemoteResourceGetter interface
+func (offlineResourceGetter) Get(context.Context, resource.ObjectMetadata) (*unstructured.Unstructured, error) {
+	return nil, nil
+}
+
+// runOffline read, mutate and sort the resources using only the cluster snapshot, and print the resulting
+// manifests instead of applying them. Resources not served by the cluster in the snapshot will return an error
+// while reading them.
+func (o *Options) runOffline(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	snapshot, err := o.offlineSnapshot()
+	if err != nil {
+		return err
+	}
+
+	logger.V(3).Info("running offline", "snapshot", o.clusterSnapshotPath, "kubeVersion", o.kubeVersion, "serverVersion", snapshot.ServerVersion)
+	o.clientFactory = &offlineFactory{ClientFactory: o.clientFactory, mapper: snapshot.RESTMapper()}
+
+	resources, err := o.readResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := validatePatchStrategies(resources); err != nil {
+		return err
+	}
+
+	if err := validateOwnerships(resources); err != nil {
+		return err
+	}
+
+	if err := o.convertAPIVersions(ctx, resources); err != nil {
+		return err
+	}
+
+	mutators, err := o.mutators(resources)
+	if err != nil {
+		return err
+	}
+
+	resources, err = o.selectResources(ctx, nil, resources)
+	if err != nil {
+		return err
+	}
+
+	resources, err = renderOffline(resources, mutators)
+	if err != nil {
+		return err
+	}
+
+	return o.printResources(resources)
+}
+
+// offlineSnapshot return the snapshot to use in place of the cluster, loaded from the snapshot file or from the
+// built-in APIs of the target kubernetes version
+func (o *Options) offlineSnapshot() (*capabilities.Snapshot, error) {
+	if len(o.kubeVersion) > 0 {
+		return capabilities.ForKubernetesVersion(o.kubeVersion)
+	}
+
+	return capabilities.Load(filesys.MakeFsOnDisk(), o.clusterSnapshotPath)
+}
+
+// renderOffline run the generators and the mutators on resources and return them sorted in the order they
+// will be applied
+func renderOffline(resources []*unstructured.Unstructured, mutators []mutator.Interface) ([]*unstructured.Unstructured, error) {
+	getter := offlineResourceGetter{}
+	generators := []generator.Interface{extensions.NewCronJobGenerator(jobGeneratorLabel, jobGeneratorValue)}
+	for _, gen := range generators {
+		for _, obj := range resources {
+			if !gen.CanHandleResource(partialObjectMetadata(obj)) {
+				continue
+			}
+
+			generated, err := gen.Generate(obj, getter)
+			if err != nil {
+				return nil, fmt.Errorf("generate resource failed: %w", err)
+			}
+			resources = append(resources, generated...)
+		}
+	}
+
+	for _, mt := range mutators {
+		for _, obj := range resources {
+			if !mt.CanHandleResource(partialObjectMetadata(obj)) {
+				continue
+			}
+
+			if err := mt.Mutate(obj, getter); err != nil {
+				return nil, fmt.Errorf("mutate resource failed: %w", err)
+			}
+		}
+	}
+
+	graph, err := resource.NewDependencyGraph(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := graph.SortedResourceGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]*unstructured.Unstructured, 0, len(resources))
+	for _, group := range groups {
+		sorted = append(sorted, group...)
+	}
+	return sorted, nil
+}
+
+// printResources write resources as a multi document yaml in the command output
+func (o *Options) printResources(resources []*unstructured.Unstructured) error {
+	for _, res := range resources {
+		data, err := yaml.Marshal(res.Object)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(o.writer, "---\n%s", data)
+	}
+
+	return nil
+}
+
+// partialObjectMetadata return the metadata of obj used for checking if a mutator or generator can handle it
+func partialObjectMetadata(obj *unstructured.Unstructured) *metav1.PartialObjectMetadata {
+	objMetadata := meta.AsPartialObjectMetadata(obj)
+	objMetadata.TypeMeta = metav1.TypeMeta{
+		Kind:       obj.GetKind(),
+		APIVersion: obj.GetAPIVersion(),
+	}
+	return objMetadata
+}
+
+var _ cache.RemoteResourceGetter = offlineResourceGetter{}
@@ -0,0 +1,131 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestRunOffline(t *testing.T) {
+	t.Parallel()
+
+	testdata := "testdata"
+	namespace := "mlp-deploy-test"
+	// the jobs generated from cronjobs have a random suffix, replace it for comparing the output
+	generatedJobName := regexp.MustCompile(`name: example-[0-9a-f]{5}\n`)
+	fakeClock := clocktesting.NewFakePassiveClock(time.Date(1970, time.January, 0, 0, 0, 0, 0, time.UTC))
+
+	tests := map[string]struct {
+		options            *Options
+		expectedOutputPath string
+		expectedError      string
+	}{
+		"render resources": {
+			options: &Options{
+				inputPaths:          []string{filepath.Join(testdata, "resources")},
+				deployType:          "deploy_all",
+				offline:             true,
+				clusterSnapshotPath: filepath.Join(testdata, "offline", "cluster-snapshot.yaml"),
+			},
+			expectedOutputPath: filepath.Join(testdata, "offline", "expected.yaml"),
+		},
+		"resources not supported by the cluster": {
+			options: &Options{
+				inputPaths:          []string{filepath.Join(testdata, "resources")},
+				deployType:          "deploy_all",
+				offline:             true,
+				clusterSnapshotPath: filepath.Join(testdata, "offline", "core-snapshot.yaml"),
+			},
+			expectedError: `unknown resource type: "batch/v1, Kind=CronJob"`,
+		},
+		"render built-in resources for a kubernetes version": {
+			options: &Options{
+				inputPaths:  []string{filepath.Join(testdata, "offline", "builtin-resources")},
+				deployType:  "deploy_all",
+				offline:     true,
+				kubeVersion: "1.23",
+			},
+			expectedOutputPath: filepath.Join(testdata, "offline", "expected-builtin.yaml"),
+		},
+		"api versions removed in the kubernetes version": {
+			options: &Options{
+				inputPaths:  []string{filepath.Join(testdata, "offline", "builtin-resources")},
+				deployType:  "deploy_all",
+				offline:     true,
+				kubeVersion: "1.30",
+			},
+			expectedError: `unknown resource type: "batch/v1beta1, Kind=CronJob"`,
+		},
+		"custom resources are not available for a kubernetes version": {
+			options: &Options{
+				inputPaths:  []string{filepath.Join(testdata, "resources")},
+				deployType:  "deploy_all",
+				offline:     true,
+				kubeVersion: "1.30",
+			},
+			expectedError: `unknown resource type: "external-secrets.io/v1beta1, Kind=`,
+		},
+		"unsupported kubernetes version": {
+			options: &Options{
+				inputPaths:  []string{filepath.Join(testdata, "offline", "builtin-resources")},
+				offline:     true,
+				kubeVersion: "1.10",
+			},
+			expectedError: `unsupported kubernetes version "1.10"`,
+		},
+		"missing snapshot": {
+			options: &Options{
+				inputPaths:          []string{filepath.Join(testdata, "resources")},
+				offline:             true,
+				clusterSnapshotPath: filepath.Join(testdata, "offline", "missing.yaml"),
+			},
+			expectedError: "failed to read cluster snapshot",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			output := new(strings.Builder)
+			test.options.clientFactory = jpltesting.NewTestClientFactory().WithNamespace(namespace)
+			test.options.clock = fakeClock
+			test.options.writer = output
+
+			err := test.options.Run(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				expected, err := os.ReadFile(test.expectedOutputPath)
+				require.NoError(t, err)
+				assert.Equal(t, string(expected), generatedJobName.ReplaceAllString(output.String(), "name: example-job\n"))
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
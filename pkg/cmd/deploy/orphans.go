@@ -0,0 +1,216 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	pruneOrphansOff    = "off"
+	pruneOrphansReport = "report"
+	pruneOrphansPrune  = "prune"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+)
+
+var (
+	validPruneOrphansValues = []string{pruneOrphansOff, pruneOrphansReport, pruneOrphansPrune}
+
+	// defaultOrphanKinds are the namespaced kinds searched for orphans if not set via flag
+	defaultOrphanKinds = []string{
+		"ConfigMap",
+		"Secret",
+		"Service",
+		"ServiceAccount",
+		"PersistentVolumeClaim",
+		"Deployment.apps",
+		"StatefulSet.apps",
+		"DaemonSet.apps",
+		"Job.batch",
+		"CronJob.batch",
+		"HorizontalPodAutoscaler.autoscaling",
+		"PodDisruptionBudget.policy",
+		"Ingress.networking.k8s.io",
+		"Role.rbac.authorization.k8s.io",
+		"RoleBinding.rbac.authorization.k8s.io",
+	}
+)
+
+// validatePruneOrphans return an error if the flags for searching the orphan resources are not valid
+func (o *Options) validatePruneOrphans() error {
+	if len(o.pruneOrphans) == 0 || o.pruneOrphans == pruneOrphansOff {
+		return nil
+	}
+
+	if !slices.Contains(validPruneOrphansValues, o.pruneOrphans) {
+		return fmt.Errorf("invalid prune orphans value: %q", o.pruneOrphans)
+	}
+
+	if o.offline {
+		return fmt.Errorf("the %q and %q flags cannot be used together", pruneOrphansFlagName, offlineFlagName)
+	}
+
+	if len(o.orphansManagedBy) == 0 {
+		return fmt.Errorf("the %q flag cannot be empty", orphansManagedByFlagName)
+	}
+
+	for _, kind := range o.orphanKinds {
+		if len(schema.ParseGroupKind(kind).Kind) == 0 {
+			return fmt.Errorf("invalid kind %q in the %q flag, use the Kind.group format", kind, orphanKindsFlagName)
+		}
+	}
+	return nil
+}
+
+// handleOrphans search the resources labeled as managed by o.orphansManagedBy and applied by the deploy field
+// manager that are not present in resources or in the inventory, report them and if requested add them to inventory
+// so they will be pruned
+func (o *Options) handleOrphans(ctx context.Context, inventory *Inventory, namespace string, resources []*unstructured.Unstructured) error {
+	if len(o.pruneOrphans) == 0 || o.pruneOrphans == pruneOrphansOff {
+		return nil
+	}
+
+	namespaces := []string{namespace}
+	if o.namespaceFromManifest {
+		namespaces = namespacesFromResources(namespace, resources)
+	}
+
+	logger := logr.FromContextOrDiscard(ctx)
+	orphans, err := o.findOrphans(ctx, namespace, namespaces, resources)
+	if err != nil {
+		return fmt.Errorf("failed to search orphan resources: %w", err)
+	}
+
+	logger.V(3).Info("orphan resources found", "count", len(orphans))
+	for _, objMeta := range orphans {
+		switch o.pruneOrphans {
+		case pruneOrphansPrune:
+			fmt.Fprintf(o.writer, "orphan %s in namespace %q is not tracked by the inventory and will be pruned\n", summaryIdentifier(objMeta), objMeta.Namespace)
+		default:
+			fmt.Fprintf(o.writer, "orphan %s in namespace %q is not tracked by the inventory\n", summaryIdentifier(objMeta), objMeta.Namespace)
+		}
+	}
+
+	if o.pruneOrphans == pruneOrphansPrune {
+		inventory.TrackObjects(orphans...)
+	}
+	return nil
+}
+
+// findOrphans return the resources found in namespaces that are managed by mlp but are not present in resources
+// or in the inventory saved in namespace, sorted by namespace, kind and name
+func (o *Options) findOrphans(ctx context.Context, namespace string, namespaces []string, resources []*unstructured.Unstructured) ([]resource.ObjectMetadata, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	client, err := o.clientFactory.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := o.clientFactory.ToRESTMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	// a separate instance is used for not changing the state of the one used by the applier
+	inventory, err := NewInventory(o.clientFactory, inventoryNameFor(o.fieldManager, o.releaseName), namespace, o.fieldManager, o.inventoryBackend)
+	if err != nil {
+		return nil, err
+	}
+	known, err := inventory.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range resources {
+		known.Insert(resource.ObjectMetadataFromUnstructured(res))
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{managedByLabel: o.orphansManagedBy}).String()
+	orphans := make(sets.Set[resource.ObjectMetadata])
+	for _, kind := range o.orphanKinds {
+		mapping, err := mapper.RESTMapping(schema.ParseGroupKind(kind))
+		switch {
+		case meta.IsNoMatchError(err):
+			logger.V(5).Info("skipping kind not available in the cluster", "kind", kind)
+			continue
+		case err != nil:
+			return nil, err
+		case mapping.Scope.Name() != meta.RESTScopeNameNamespace:
+			logger.V(5).Info("skipping cluster scoped kind", "kind", kind)
+			continue
+		}
+
+		for _, ns := range namespaces {
+			list, err := client.Resource(mapping.Resource).Namespace(ns).List(ctx, metav1.ListOptions{LabelSelector: selector})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, obj := range list.Items {
+				objMeta := resource.ObjectMetadataFromUnstructured(&obj)
+				if known.Has(objMeta) || !o.isOrphanCandidate(&obj) {
+					continue
+				}
+				orphans.Insert(objMeta)
+			}
+		}
+	}
+
+	sorted := orphans.UnsortedList()
+	slices.SortFunc(sorted, func(a, b resource.ObjectMetadata) int {
+		return cmp.Or(
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Group, b.Group),
+			cmp.Compare(a.Kind, b.Kind),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+	return sorted, nil
+}
+
+// isOrphanCandidate return true if obj has been applied by the deploy field manager, is not owned by another
+// resource or release, is not shared with other tools, and has not been generated from a CronJob
+func (o *Options) isOrphanCandidate(obj *unstructured.Unstructured) bool {
+	if len(obj.GetOwnerReferences()) > 0 || isShared(obj) {
+		return false
+	}
+
+	annotations := obj.GetAnnotations()
+	if _, found := annotations[extensions.CreatedByCronJobAnnotation]; found {
+		return false
+	}
+
+	if release, found := annotations[extensions.ReleaseNameAnnotation]; found && release != o.releaseName {
+		return false
+	}
+
+	return slices.ContainsFunc(obj.GetManagedFields(), func(entry metav1.ManagedFieldsEntry) bool {
+		return entry.Manager == o.fieldManager
+	})
+}
@@ -0,0 +1,210 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	jplresource "github.com/mia-platform/jpl/pkg/resource"
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	restfake "k8s.io/client-go/rest/fake"
+)
+
+func TestValidatePruneOrphans(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		options       *Options
+		expectedError string
+	}{
+		"disabled": {
+			options: &Options{},
+		},
+		"off ignore the other flags": {
+			options: &Options{pruneOrphans: pruneOrphansOff, offline: true},
+		},
+		"valid": {
+			options: &Options{pruneOrphans: pruneOrphansPrune, orphansManagedBy: "mlp", orphanKinds: defaultOrphanKinds},
+		},
+		"invalid value": {
+			options:       &Options{pruneOrphans: "always"},
+			expectedError: `invalid prune orphans value: "always"`,
+		},
+		"offline": {
+			options:       &Options{pruneOrphans: pruneOrphansReport, orphansManagedBy: "mlp", offline: true},
+			expectedError: `the "prune-orphans" and "offline" flags cannot be used together`,
+		},
+		"empty managed by": {
+			options:       &Options{pruneOrphans: pruneOrphansReport},
+			expectedError: `the "orphans-managed-by" flag cannot be empty`,
+		},
+		"invalid kind": {
+			options:       &Options{pruneOrphans: pruneOrphansReport, orphansManagedBy: "mlp", orphanKinds: []string{".apps"}},
+			expectedError: `invalid kind ".apps" in the "orphan-kinds" flag, use the Kind.group format`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := test.options.validatePruneOrphans()
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.EqualError(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestHandleOrphans(t *testing.T) {
+	t.Parallel()
+
+	namespace := "orphans"
+	codec := jpltesting.Codecs.LegacyCodec(jpltesting.Scheme.PrioritizedVersionsAllGroups()...)
+
+	managedObject := func(apiVersion, kind, name, manager string, annotations map[string]interface{}, owned bool) *unstructured.Unstructured {
+		metadata := map[string]interface{}{
+			"name":          name,
+			"namespace":     namespace,
+			"labels":        map[string]interface{}{managedByLabel: "mlp"},
+			"managedFields": []interface{}{map[string]interface{}{"manager": manager, "operation": "Apply"}},
+		}
+		if annotations != nil {
+			metadata["annotations"] = annotations
+		}
+		if owned {
+			metadata["ownerReferences"] = []interface{}{map[string]interface{}{"apiVersion": "v1", "kind": "Owner", "name": "owner", "uid": "1234"}}
+		}
+		return &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": apiVersion, "kind": kind, "metadata": metadata}}
+	}
+
+	unlabeled := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":          "unlabeled",
+			"namespace":     namespace,
+			"managedFields": []interface{}{map[string]interface{}{"manager": fieldManager, "operation": "Apply"}},
+		},
+	}}
+	remoteObjects := []runtime.Object{
+		managedObject("v1", "ConfigMap", "orphan", fieldManager, nil, false),
+		managedObject("v1", "ConfigMap", "known", fieldManager, nil, false),
+		managedObject("v1", "ConfigMap", "manifest", fieldManager, nil, false),
+		managedObject("v1", "ConfigMap", "other-manager", "kubectl", nil, false),
+		managedObject("v1", "ConfigMap", "other-release", fieldManager, map[string]interface{}{extensions.ReleaseNameAnnotation: "other"}, false),
+		managedObject("v1", "Secret", "owned", fieldManager, nil, true),
+		managedObject("v1", "Secret", "shared", fieldManager, map[string]interface{}{ownershipAnnotation: ownershipShared}, false),
+		managedObject("apps/v1", "Deployment", "orphan", fieldManager, nil, false),
+		managedObject("batch/v1", "Job", "generated", fieldManager, map[string]interface{}{extensions.CreatedByCronJobAnnotation: "cronjob"}, false),
+		unlabeled,
+	}
+
+	inventory := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: inventoryName, Namespace: namespace},
+		Data: map[string]string{
+			jplresource.ObjectMetadata{Name: "known", Namespace: namespace, Kind: "ConfigMap"}.ToString(): "",
+		},
+	}
+	resources := []*unstructured.Unstructured{
+		managedObject("v1", "ConfigMap", "manifest", fieldManager, nil, false),
+	}
+
+	expectedOrphans := []jplresource.ObjectMetadata{
+		{Name: "orphan", Namespace: namespace, Kind: "ConfigMap"},
+		{Name: "orphan", Namespace: namespace, Kind: "Deployment", Group: "apps"},
+	}
+
+	tests := map[string]struct {
+		mode            string
+		expectedTracked sets.Set[jplresource.ObjectMetadata]
+		expectedOutput  string
+	}{
+		"report orphans": {
+			mode:            pruneOrphansReport,
+			expectedTracked: sets.New[jplresource.ObjectMetadata](),
+			expectedOutput: `orphan ConfigMap/orphan in namespace "orphans" is not tracked by the inventory
+orphan Deployment.apps/orphan in namespace "orphans" is not tracked by the inventory
+`,
+		},
+		"prune orphans": {
+			mode:            pruneOrphansPrune,
+			expectedTracked: sets.New(expectedOrphans...),
+			expectedOutput: `orphan ConfigMap/orphan in namespace "orphans" is not tracked by the inventory and will be pruned
+orphan Deployment.apps/orphan in namespace "orphans" is not tracked by the inventory and will be pruned
+`,
+		},
+		"disabled": {
+			mode:            pruneOrphansOff,
+			expectedTracked: sets.New[jplresource.ObjectMetadata](),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tf := jpltesting.NewTestClientFactory().WithNamespace(namespace)
+			tf.FakeDynamicClient = dynamicfake.NewSimpleDynamicClient(jpltesting.Scheme, remoteObjects...)
+			tf.Client = &restfake.RESTClient{
+				NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+				Client: restfake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+					header := jpltesting.DefaultHeaders()
+					if r.Method == http.MethodGet && r.URL.Path == "/api/v1/namespaces/orphans/configmaps/"+inventoryName {
+						body := []byte(runtime.EncodeOrDie(codec, inventory))
+						return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(body))}, nil
+					}
+					return &http.Response{StatusCode: http.StatusNotFound, Header: header, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+				}),
+			}
+
+			output := new(strings.Builder)
+			options := &Options{
+				fieldManager:     fieldManager,
+				inventoryBackend: InventoryBackendConfigMap,
+				pruneOrphans:     test.mode,
+				orphansManagedBy: "mlp",
+				orphanKinds:      append([]string{"Widget.example.com", "Namespace"}, defaultOrphanKinds...),
+				clientFactory:    tf,
+				writer:           output,
+			}
+
+			deployInventory, err := NewInventory(tf, inventoryName, namespace, fieldManager, InventoryBackendConfigMap)
+			require.NoError(t, err)
+
+			require.NoError(t, options.handleOrphans(context.TODO(), deployInventory, namespace, resources))
+			assert.Equal(t, test.expectedTracked, deployInventory.trackedObjects)
+			assert.Equal(t, test.expectedOutput, output.String())
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	ownershipAnnotation = "mia-platform.eu/ownership"
+
+	ownershipShared    = "shared"
+	ownershipExclusive = "exclusive"
+)
+
+var validOwnerships = []string{ownershipShared, ownershipExclusive}
+
+// validateOwnerships return an error listing the resources with an unknown value in the ownership annotation, or
+// shared with other tools but applied with a patch strategy that overwrites the fields owned by them
+func validateOwnerships(resources []*unstructured.Unstructured) error {
+	invalid := make([]string, 0)
+	for _, res := range resources {
+		annotations := res.GetAnnotations()
+		ownership, found := annotations[ownershipAnnotation]
+		if !found {
+			continue
+		}
+
+		identifier := summaryIdentifier(resource.ObjectMetadataFromUnstructured(res))
+		switch strategy, hasStrategy := annotations[patchStrategyAnnotation]; {
+		case !slices.Contains(validOwnerships, ownership):
+			invalid = append(invalid, fmt.Sprintf("\t- %s has ownership %q", identifier, ownership))
+		case ownership == ownershipShared && hasStrategy:
+			invalid = append(invalid, fmt.Sprintf("\t- %s is shared but has patch strategy %q", identifier, strategy))
+		}
+	}
+
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("invalid %s annotation, valid values are %s and shared resources must use server-side apply:\n%s", ownershipAnnotation, strings.Join(validOwnerships, ", "), strings.Join(invalid, "\n"))
+}
+
+// isShared return true if obj is shared with other tools that can own some of its fields
+func isShared(obj *unstructured.Unstructured) bool {
+	return obj.GetAnnotations()[ownershipAnnotation] == ownershipShared
+}
+
+// shareObjects remove from inventory the resources shared with other tools, so they are never pruned even after
+// their manifests have been deleted
+func shareObjects(inventory *Inventory, resources []*unstructured.Unstructured) {
+	identifiers := make([]resource.ObjectMetadata, 0)
+	for _, res := range resources {
+		if isShared(res) {
+			identifiers = append(identifiers, resource.ObjectMetadataFromUnstructured(res))
+		}
+	}
+
+	if len(identifiers) > 0 {
+		inventory.ShareObjects(identifiers...)
+	}
+}
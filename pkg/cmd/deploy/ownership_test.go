@@ -0,0 +1,97 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestValidateOwnerships(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		resources     []*unstructured.Unstructured
+		expectedError string
+	}{
+		"no annotations": {
+			resources: []*unstructured.Unstructured{testSelectObject("example", nil, nil)},
+		},
+		"valid ownerships": {
+			resources: []*unstructured.Unstructured{
+				testSelectObject("shared", nil, map[string]string{ownershipAnnotation: ownershipShared}),
+				testSelectObject("exclusive", nil, map[string]string{ownershipAnnotation: ownershipExclusive}),
+				testSelectObject("replace", nil, map[string]string{ownershipAnnotation: ownershipExclusive, patchStrategyAnnotation: patchStrategyReplace}),
+			},
+		},
+		"invalid ownerships": {
+			resources: []*unstructured.Unstructured{
+				testSelectObject("shared", nil, map[string]string{ownershipAnnotation: ownershipShared}),
+				testSelectObject("owned", nil, map[string]string{ownershipAnnotation: "owned"}),
+				testSelectObject("merge", nil, map[string]string{ownershipAnnotation: ownershipShared, patchStrategyAnnotation: patchStrategyMerge}),
+			},
+			expectedError: "invalid mia-platform.eu/ownership annotation, valid values are shared, exclusive and shared resources must use server-side apply:\n\t- Deployment.apps/owned has ownership \"owned\"\n\t- Deployment.apps/merge is shared but has patch strategy \"merge\"",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateOwnerships(test.resources)
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.EqualError(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestShareObjects(t *testing.T) {
+	t.Parallel()
+
+	api := resource.ObjectMetadata{Group: "apps", Kind: "Deployment", Name: "api", Namespace: "test"}
+	worker := resource.ObjectMetadata{Group: "apps", Kind: "Deployment", Name: "worker", Namespace: "test"}
+	removed := resource.ObjectMetadata{Group: "apps", Kind: "Deployment", Name: "removed", Namespace: "test"}
+
+	store := &recordingStore{objects: sets.New(api, worker, removed)}
+	inventory := &Inventory{delegate: store, trackedObjects: make(sets.Set[resource.ObjectMetadata])}
+	resources := []*unstructured.Unstructured{
+		testSelectObject("api", nil, map[string]string{ownershipAnnotation: ownershipExclusive}),
+		testSelectObject("worker", nil, map[string]string{ownershipAnnotation: ownershipShared}),
+		testSelectObject("new", nil, map[string]string{ownershipAnnotation: ownershipShared}),
+	}
+	shareObjects(inventory, resources)
+
+	loaded, err := inventory.Load(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, sets.New(api, removed), loaded, "shared resources cannot be pruned")
+
+	inventory.SetObjects(sets.New(resources...))
+	saved := sets.New[resource.ObjectMetadata]()
+	for obj := range store.saved {
+		saved.Insert(resource.ObjectMetadataFromUnstructured(obj))
+	}
+	assert.Equal(t, sets.New(api), saved, "shared resources must not be tracked in the inventory")
+}
@@ -0,0 +1,332 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/jpl/pkg/util"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	cliresource "k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	patchStrategyAnnotation = "mia-platform.eu/patch-strategy"
+
+	patchStrategyMerge     = "merge"
+	patchStrategyStrategic = "strategic"
+	patchStrategyReplace   = "replace"
+
+	// replaceConflictRetries is how many times a replace is retried when the live object changes between reading
+	// its resourceVersion and updating it
+	replaceConflictRetries = 5
+)
+
+var validPatchStrategies = []string{patchStrategyMerge, patchStrategyStrategic, patchStrategyReplace}
+
+// validatePatchStrategies return an error listing the resources with an unknown value in the patch strategy
+// annotation
+func validatePatchStrategies(resources []*unstructured.Unstructured) error {
+	invalid := make([]string, 0)
+	for _, res := range resources {
+		strategy, found := res.GetAnnotations()[patchStrategyAnnotation]
+		if !found || slices.Contains(validPatchStrategies, strategy) {
+			continue
+		}
+
+		identifier := summaryIdentifier(resource.ObjectMetadataFromUnstructured(res))
+		invalid = append(invalid, fmt.Sprintf("\t- %s has patch strategy %q", identifier, strategy))
+	}
+
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("invalid %s annotation, valid values are %s:\n%s", patchStrategyAnnotation, strings.Join(validPatchStrategies, ", "), strings.Join(invalid, "\n"))
+}
+
+// validateKindPatchStrategies return an error if a kind or a strategy set with the kind patch strategy flag is not
+// valid
+func (o *Options) validateKindPatchStrategies() error {
+	for _, kind := range slices.Sorted(maps.Keys(o.kindPatchStrategies)) {
+		if len(schema.ParseGroupKind(kind).Kind) == 0 {
+			return fmt.Errorf("invalid kind %q in the %q flag, use the Kind.group format", kind, kindPatchStrategyFlagName)
+		}
+
+		if strategy := o.kindPatchStrategies[kind]; !slices.Contains(validPatchStrategies, strategy) {
+			return fmt.Errorf("invalid patch strategy %q for kind %q, valid values are %s", strategy, kind, strings.Join(validPatchStrategies, ", "))
+		}
+	}
+
+	return nil
+}
+
+// groupKindPatchStrategies return the patch strategies set with the kind patch strategy flag indexed by their kind
+func (o *Options) groupKindPatchStrategies() map[schema.GroupKind]string {
+	strategies := make(map[schema.GroupKind]string, len(o.kindPatchStrategies))
+	for kind, strategy := range o.kindPatchStrategies {
+		strategies[schema.ParseGroupKind(kind)] = strategy
+	}
+	return strategies
+}
+
+// patchStrategyTransport rewrite the server side apply requests made through next for the objects that set a
+// different patch strategy via annotation, or for all the objects if defaultStrategy is set; the objects shared
+// with other tools are always applied with server side apply without forcing the conflicts
+type patchStrategyTransport struct {
+	next            http.RoundTripper
+	defaultStrategy string
+}
+
+func (t *patchStrategyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPatch || req.Header.Get("Content-Type") != string(types.ApplyPatchType) || req.GetBody == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	body, annotations, err := requestAnnotations(req)
+	if err != nil {
+		return t.next.RoundTrip(req)
+	}
+
+	query := req.URL.Query()
+	// force is valid only for server side apply requests, and it is never used for the objects shared with other
+	// tools to avoid taking the ownership of their fields
+	query.Del("force")
+
+	if annotations[ownershipAnnotation] == ownershipShared {
+		sharedReq, err := newRequestFrom(req, http.MethodPatch, req.URL.Path, query.Encode(), string(types.ApplyPatchType), body)
+		if err != nil {
+			return nil, err
+		}
+		return t.next.RoundTrip(sharedReq)
+	}
+
+	strategy := cmp.Or(annotations[patchStrategyAnnotation], t.defaultStrategy)
+	if len(strategy) == 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	var response *http.Response
+	switch strategy {
+	case patchStrategyMerge:
+		response, err = t.patch(req, query.Encode(), string(types.MergePatchType), body)
+	case patchStrategyStrategic:
+		response, err = t.patch(req, query.Encode(), string(types.StrategicMergePatchType), body)
+	case patchStrategyReplace:
+		response, err = t.replace(req, query.Encode(), body)
+	default:
+		return nil, fmt.Errorf("unknown patch strategy %q", strategy)
+	}
+	if err != nil || response.StatusCode != http.StatusNotFound {
+		return response, err
+	}
+
+	// patch and update requests fail if the object doesn't exist yet, so it has to be created on its collection
+	_, _ = io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+	createReq, err := newRequestFrom(req, http.MethodPost, path.Dir(req.URL.Path), query.Encode(), "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(createReq)
+}
+
+// patch send body to the object of req as a patch of contentType
+func (t *patchStrategyTransport) patch(req *http.Request, rawQuery, contentType string, body []byte) (*http.Response, error) {
+	patchReq, err := newRequestFrom(req, http.MethodPatch, req.URL.Path, rawQuery, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(patchReq)
+}
+
+// replace update the object of req with body, using the resourceVersion of the live object for avoiding to
+// overwrite the changes made concurrently and retrying on conflicts; the response of the read is returned if the
+// object doesn't exist
+func (t *patchStrategyTransport) replace(req *http.Request, rawQuery string, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		getReq, err := newRequestFrom(req, http.MethodGet, req.URL.Path, "", "", nil)
+		if err != nil {
+			return nil, err
+		}
+		getReq.Header.Del("Content-Type")
+
+		response, err := t.next.RoundTrip(getReq)
+		if err != nil || response.StatusCode != http.StatusOK {
+			return response, err
+		}
+
+		resourceVersion, err := responseResourceVersion(response)
+		if err != nil {
+			return nil, err
+		}
+
+		updateBody, err := withResourceVersion(body, resourceVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		updateReq, err := newRequestFrom(req, http.MethodPut, req.URL.Path, rawQuery, "application/json", updateBody)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err = t.next.RoundTrip(updateReq)
+		if err != nil || response.StatusCode != http.StatusConflict || attempt >= replaceConflictRetries {
+			return response, err
+		}
+		_, _ = io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+	}
+}
+
+// responseResourceVersion return the resourceVersion of the object in the body of response, closing it
+func responseResourceVersion(response *http.Response) (string, error) {
+	defer response.Body.Close()
+
+	obj := struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&obj); err != nil {
+		return "", fmt.Errorf("failed to read the live object: %w", err)
+	}
+	if len(obj.Metadata.ResourceVersion) == 0 {
+		return "", fmt.Errorf("the live object has no resourceVersion")
+	}
+
+	return obj.Metadata.ResourceVersion, nil
+}
+
+// withResourceVersion return body with resourceVersion set in the object metadata
+func withResourceVersion(body []byte, resourceVersion string) ([]byte, error) {
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil, err
+	}
+	if err := unstructured.SetNestedField(obj, resourceVersion, "metadata", "resourceVersion"); err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
+
+// requestAnnotations return the body of req and the annotations of the object in it
+func requestAnnotations(req *http.Request) ([]byte, map[string]string, error) {
+	reader, err := req.GetBody()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj := struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil, nil, err
+	}
+
+	return body, obj.Metadata.Annotations, nil
+}
+
+// newRequestFrom return a copy of req with a different method, path, query, content type and body
+func newRequestFrom(req *http.Request, method, urlPath, rawQuery, contentType string, body []byte) (*http.Request, error) {
+	requestURL := *req.URL
+	requestURL.Path = urlPath
+	requestURL.RawPath = ""
+	requestURL.RawQuery = rawQuery
+
+	newReq, err := http.NewRequestWithContext(req.Context(), method, requestURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	newReq.Header = req.Header.Clone()
+	newReq.Header.Set("Content-Type", contentType)
+	return newReq, nil
+}
+
+// dryRunRecreateResponse return the response to req for an object that has been deleted during a dry run: the object
+// is not really deleted, so its recreation cannot be simulated by the api-server and body is returned as is
+func dryRunRecreateResponse(req *http.Request, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     fmt.Sprintf("%d %s", http.StatusOK, http.StatusText(http.StatusOK)),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+}
+
+// patchStrategyFactory wrap a ClientFactory for honoring the patch strategy annotation in the apply requests made
+// with the clients it returns
+type patchStrategyFactory struct {
+	util.ClientFactory
+
+	kindStrategies map[schema.GroupKind]string
+}
+
+// newPatchStrategyFactory return a ClientFactory that apply the objects with the patch strategy set in their
+// annotation, or with the one set in kindStrategies for their kind
+func newPatchStrategyFactory(factory util.ClientFactory, kindStrategies map[schema.GroupKind]string) util.ClientFactory {
+	return &patchStrategyFactory{ClientFactory: factory, kindStrategies: kindStrategies}
+}
+
+// UnstructuredClientForMapping override the ClientFactory method wrapping the transport of the returned client
+func (f *patchStrategyFactory) UnstructuredClientForMapping(mapping *meta.RESTMapping) (cliresource.RESTClient, error) {
+	client, err := f.ClientFactory.UnstructuredClientForMapping(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	restClient, ok := client.(*rest.RESTClient)
+	if !ok || restClient.Client == nil {
+		return client, nil
+	}
+
+	httpClient := *restClient.Client
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = &patchStrategyTransport{
+		next:            next,
+		defaultStrategy: f.kindStrategies[mapping.GroupVersionKind.GroupKind()],
+	}
+	restClient.Client = &httpClient
+	return restClient, nil
+}
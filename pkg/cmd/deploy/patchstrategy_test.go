@@ -0,0 +1,326 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestValidatePatchStrategies(t *testing.T) {
+	t.Parallel()
+
+	newResource := func(name, strategy string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		if len(strategy) > 0 {
+			obj.SetAnnotations(map[string]string{patchStrategyAnnotation: strategy})
+		}
+		return obj
+	}
+
+	tests := map[string]struct {
+		resources     []*unstructured.Unstructured
+		expectedError string
+	}{
+		"no annotations": {
+			resources: []*unstructured.Unstructured{newResource("example", "")},
+		},
+		"valid strategies": {
+			resources: []*unstructured.Unstructured{
+				newResource("merge", patchStrategyMerge),
+				newResource("strategic", patchStrategyStrategic),
+				newResource("replace", patchStrategyReplace),
+			},
+		},
+		"invalid strategies": {
+			resources: []*unstructured.Unstructured{
+				newResource("merge", patchStrategyMerge),
+				newResource("json", "json"),
+				newResource("apply", "apply"),
+			},
+			expectedError: "invalid mia-platform.eu/patch-strategy annotation, valid values are merge, strategic, replace:\n\t- ConfigMap/json has patch strategy \"json\"\n\t- ConfigMap/apply has patch strategy \"apply\"",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validatePatchStrategies(test.resources)
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.EqualError(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+type recordedRequest struct {
+	method      string
+	path        string
+	query       string
+	contentType string
+	body        string
+}
+
+func TestValidateKindPatchStrategies(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		strategies    map[string]string
+		expected      map[schema.GroupKind]string
+		expectedError string
+	}{
+		"no strategies": {
+			expected: map[schema.GroupKind]string{},
+		},
+		"core and grouped kinds": {
+			strategies: map[string]string{"ConfigMap": patchStrategyMerge, "Deployment.apps": patchStrategyStrategic},
+			expected: map[schema.GroupKind]string{
+				{Kind: "ConfigMap"}:                 patchStrategyMerge,
+				{Group: "apps", Kind: "Deployment"}: patchStrategyStrategic,
+			},
+		},
+		"invalid kind": {
+			strategies:    map[string]string{".apps": patchStrategyMerge},
+			expectedError: `invalid kind ".apps" in the "kind-patch-strategy" flag, use the Kind.group format`,
+		},
+		"invalid strategy": {
+			strategies:    map[string]string{"Secret": "update"},
+			expectedError: `invalid patch strategy "update" for kind "Secret", valid values are merge, strategic, replace`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options := &Options{kindPatchStrategies: test.strategies}
+			err := options.validateKindPatchStrategies()
+			if len(test.expectedError) > 0 {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, options.groupKindPatchStrategies())
+		})
+	}
+}
+
+func TestPatchStrategyFactory(t *testing.T) {
+	t.Parallel()
+
+	replaceBody := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"annotations":{"mia-platform.eu/patch-strategy":"replace"},"name":"example","namespace":"test","resourceVersion":"42"}}`
+
+	tests := map[string]struct {
+		strategy         string
+		kindStrategy     string
+		shared           bool
+		existing         bool
+		conflicts        int
+		expectedRequests []recordedRequest
+	}{
+		"without annotation use server side apply": {
+			existing: true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: "/apis/apps/v1/namespaces/test/deployments/example", query: "fieldManager=mlp&force=true", contentType: string(types.ApplyPatchType)},
+			},
+		},
+		"merge strategy": {
+			strategy: patchStrategyMerge,
+			existing: true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: "/apis/apps/v1/namespaces/test/deployments/example", query: "fieldManager=mlp", contentType: string(types.MergePatchType)},
+			},
+		},
+		"strategic strategy": {
+			strategy: patchStrategyStrategic,
+			existing: true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: "/apis/apps/v1/namespaces/test/deployments/example", query: "fieldManager=mlp", contentType: string(types.StrategicMergePatchType)},
+			},
+		},
+		"replace strategy": {
+			strategy: patchStrategyReplace,
+			existing: true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodGet, path: "/apis/apps/v1/namespaces/test/deployments/example"},
+				{method: http.MethodPut, path: "/apis/apps/v1/namespaces/test/deployments/example", query: "fieldManager=mlp", contentType: "application/json", body: replaceBody},
+			},
+		},
+		"replace strategy retry on conflict": {
+			strategy:  patchStrategyReplace,
+			existing:  true,
+			conflicts: 1,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodGet, path: "/apis/apps/v1/namespaces/test/deployments/example"},
+				{method: http.MethodPut, path: "/apis/apps/v1/namespaces/test/deployments/example", query: "fieldManager=mlp", contentType: "application/json", body: replaceBody},
+				{method: http.MethodGet, path: "/apis/apps/v1/namespaces/test/deployments/example"},
+				{method: http.MethodPut, path: "/apis/apps/v1/namespaces/test/deployments/example", query: "fieldManager=mlp", contentType: "application/json", body: replaceBody},
+			},
+		},
+		"replace strategy create missing object": {
+			strategy: patchStrategyReplace,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodGet, path: "/apis/apps/v1/namespaces/test/deployments/example"},
+				{method: http.MethodPost, path: "/apis/apps/v1/namespaces/test/deployments", query: "fieldManager=mlp", contentType: "application/json"},
+			},
+		},
+		"kind strategy without annotation": {
+			kindStrategy: patchStrategyStrategic,
+			existing:     true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: "/apis/apps/v1/namespaces/test/deployments/example", query: "fieldManager=mlp", contentType: string(types.StrategicMergePatchType)},
+			},
+		},
+		"annotation override kind strategy": {
+			strategy:     patchStrategyReplace,
+			kindStrategy: patchStrategyMerge,
+			existing:     true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodGet, path: "/apis/apps/v1/namespaces/test/deployments/example"},
+				{method: http.MethodPut, path: "/apis/apps/v1/namespaces/test/deployments/example", query: "fieldManager=mlp", contentType: "application/json", body: replaceBody},
+			},
+		},
+		"shared object use server side apply without force": {
+			shared:   true,
+			existing: true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: "/apis/apps/v1/namespaces/test/deployments/example", query: "fieldManager=mlp", contentType: string(types.ApplyPatchType)},
+			},
+		},
+		"shared object ignore kind strategy": {
+			kindStrategy: patchStrategyReplace,
+			shared:       true,
+			existing:     true,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: "/apis/apps/v1/namespaces/test/deployments/example", query: "fieldManager=mlp", contentType: string(types.ApplyPatchType)},
+			},
+		},
+		"merge strategy create missing object": {
+			strategy: patchStrategyMerge,
+			expectedRequests: []recordedRequest{
+				{method: http.MethodPatch, path: "/apis/apps/v1/namespaces/test/deployments/example", query: "fieldManager=mlp", contentType: string(types.MergePatchType)},
+				{method: http.MethodPost, path: "/apis/apps/v1/namespaces/test/deployments", query: "fieldManager=mlp", contentType: "application/json"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			body := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"example","namespace":"test"}}`
+			if len(test.strategy) > 0 {
+				body = `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"example","namespace":"test","annotations":{"mia-platform.eu/patch-strategy":"` + test.strategy + `"}}}`
+			}
+			if test.shared {
+				body = `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"example","namespace":"test","annotations":{"mia-platform.eu/ownership":"shared"}}}`
+			}
+
+			lock := sync.Mutex{}
+			requests := make([]recordedRequest, 0)
+			conflicts := test.conflicts
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				lock.Lock()
+				requests = append(requests, recordedRequest{
+					method:      r.Method,
+					path:        r.URL.Path,
+					query:       r.URL.RawQuery,
+					contentType: r.Header.Get("Content-Type"),
+					body:        string(data),
+				})
+				lock.Unlock()
+
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.Method == http.MethodPost:
+					w.WriteHeader(http.StatusCreated)
+				case r.Method == http.MethodGet && test.existing:
+					w.WriteHeader(http.StatusOK)
+					_, _ = w.Write([]byte(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"example","namespace":"test","resourceVersion":"42"}}`))
+					return
+				case r.Method == http.MethodPut && !strings.Contains(string(data), `"resourceVersion":"42"`):
+					// the updates without the resourceVersion are rejected like the API server does for custom resources
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Invalid","code":422}`))
+					return
+				case r.Method == http.MethodPut && conflicts > 0:
+					conflicts--
+					w.WriteHeader(http.StatusConflict)
+					_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Conflict","code":409}`))
+					return
+				case !test.existing && r.Header.Get("Content-Type") != string(types.ApplyPatchType):
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+					return
+				default:
+					w.WriteHeader(http.StatusOK)
+				}
+				_, _ = w.Write([]byte(body))
+			}))
+			t.Cleanup(server.Close)
+
+			var kindStrategies map[schema.GroupKind]string
+			if len(test.kindStrategy) > 0 {
+				kindStrategies = map[schema.GroupKind]string{{Group: "apps", Kind: "Deployment"}: test.kindStrategy}
+			}
+			factory := newPatchStrategyFactory(&restClientFactory{host: server.URL}, kindStrategies)
+			client, err := factory.UnstructuredClientForMapping(&meta.RESTMapping{
+				GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			})
+			require.NoError(t, err)
+
+			err = client.Patch(types.ApplyPatchType).
+				Namespace("test").
+				Resource("deployments").
+				Name("example").
+				Param("fieldManager", "mlp").
+				Param("force", "true").
+				Body([]byte(body)).
+				Do(context.TODO()).
+				Error()
+			require.NoError(t, err)
+
+			for idx := range test.expectedRequests {
+				if test.expectedRequests[idx].method != http.MethodGet && len(test.expectedRequests[idx].body) == 0 {
+					test.expectedRequests[idx].body = body
+				}
+			}
+			assert.Equal(t, test.expectedRequests, requests)
+		})
+	}
+}
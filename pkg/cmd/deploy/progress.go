@@ -0,0 +1,379 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"golang.org/x/term"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/clock"
+)
+
+const (
+	phasePending  = "pending"
+	phaseApplying = "applying"
+	phaseWaiting  = "waiting"
+	phaseHealthy  = "healthy"
+	phaseSkipped  = "skipped"
+	phasePruning  = "pruning"
+	phasePruned   = "pruned"
+	phaseFailed   = "failed"
+
+	// ansi sequences for moving the cursor up n lines and clearing the screen from the cursor to the end
+	cursorUpFormat = "\x1b[%dA"
+	clearToEnd     = "\x1b[J"
+
+	// progressRefreshInterval is how often the table is redrawn for keeping the elapsed times live between events
+	progressRefreshInterval = time.Second
+	// hiddenRowsFormat is the last line of a table too tall for the terminal
+	hiddenRowsFormat = "+%d more"
+)
+
+// messageSpaces replace the characters that would break the table lines or columns
+var messageSpaces = strings.NewReplacer("\n", " ", "\r", " ", "\t", " ")
+
+// eventPrinter is used to show to the user the events received during the applying process
+type eventPrinter interface {
+	// PrintEvent elaborate a new event received from the applier
+	PrintEvent(event.Event)
+	// Flush will be called at the end of the process to print any remaining output
+	Flush()
+}
+
+// newEventPrinter return the eventPrinter to use on writer, the progress table is used only if it is enabled and
+// the writer is an interactive terminal
+func newEventPrinter(writer io.Writer, clock clock.PassiveClock, progress, dryRun bool) eventPrinter {
+	if progress && isTerminal(writer) {
+		printer := newProgressPrinter(writer, clock, dryRun)
+		printer.startRefresh(progressRefreshInterval)
+		return printer
+	}
+
+	return &linePrinter{writer: writer}
+}
+
+// isTerminal return true if writer is a file descriptor attached to a terminal
+func isTerminal(writer io.Writer) bool {
+	file, ok := writer.(*os.File)
+	return ok && term.IsTerminal(int(file.Fd()))
+}
+
+// terminalSize return a function reading the current width and height of the terminal attached to writer, the
+// function return zero values if writer is not a terminal or its size is unknown
+func terminalSize(writer io.Writer) func() (int, int) {
+	return func() (int, int) {
+		file, ok := writer.(*os.File)
+		if !ok {
+			return 0, 0
+		}
+
+		width, height, err := term.GetSize(int(file.Fd()))
+		if err != nil {
+			return 0, 0
+		}
+		return width, height
+	}
+}
+
+// linePrinter print a line for every event received
+type linePrinter struct {
+	writer io.Writer
+}
+
+// PrintEvent implement eventPrinter interface
+func (p *linePrinter) PrintEvent(e event.Event) {
+	if isDeployOnceSkip(e) {
+		gk := e.ApplyInfo.Object.GroupVersionKind().GroupKind()
+		fmt.Fprintf(p.writer, "%s %s: %s, %s\n", gk.String(), e.ApplyInfo.Object.GetName(), deployOnceAction, deployOnceMessage)
+		return
+	}
+
+	fmt.Fprintln(p.writer, e.String())
+}
+
+// Flush implement eventPrinter interface
+func (p *linePrinter) Flush() {}
+
+// progressRow contains the current state of a single resource
+type progressRow struct {
+	identifier string
+	phase      string
+	message    string
+	start      time.Time
+	end        time.Time
+}
+
+// progressPrinter keep a table with a row for every resource and rewrite it at every event received, and
+// periodically when the refresh is started, fitting it in the size of the terminal
+type progressPrinter struct {
+	writer io.Writer
+	clock  clock.PassiveClock
+	dryRun bool
+	// size return the width and height of the terminal, zero values disable the truncation of the table
+	size func() (int, int)
+
+	lock   sync.Mutex
+	rows   map[resource.ObjectMetadata]*progressRow
+	order  []resource.ObjectMetadata
+	errors []string
+	// printedLines contains the length of every line of the last table printed, for computing the terminal rows
+	// to clear also when they have been wrapped
+	printedLines []int
+	stopRefresh  chan struct{}
+	refreshDone  chan struct{}
+}
+
+func newProgressPrinter(writer io.Writer, clock clock.PassiveClock, dryRun bool) *progressPrinter {
+	return &progressPrinter{
+		writer: writer,
+		clock:  clock,
+		dryRun: dryRun,
+		size:   terminalSize(writer),
+		rows:   make(map[resource.ObjectMetadata]*progressRow),
+	}
+}
+
+// startRefresh redraw the table every interval until the printer is flushed, for updating the elapsed times
+// and following the resizes of the terminal also when no event is received
+func (p *progressPrinter) startRefresh(interval time.Duration) {
+	p.stopRefresh = make(chan struct{})
+	p.refreshDone = make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer close(p.refreshDone)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.lock.Lock()
+				p.render()
+				p.lock.Unlock()
+			case <-p.stopRefresh:
+				return
+			}
+		}
+	}()
+}
+
+// PrintEvent implement eventPrinter interface
+func (p *progressPrinter) PrintEvent(e event.Event) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	switch e.Type {
+	case event.TypeQueue:
+		for _, obj := range e.QueueInfo.Objects {
+			p.row(obj)
+		}
+	case event.TypeApply:
+		row := p.row(e.ApplyInfo.Object)
+		switch e.ApplyInfo.Status {
+		case event.StatusPending:
+			p.setPhase(row, phaseApplying, "")
+		case event.StatusSuccessful:
+			if p.dryRun {
+				p.setPhase(row, phaseHealthy, "")
+				break
+			}
+			p.setPhase(row, phaseWaiting, "")
+		case event.StatusSkipped:
+			if isDeployOnceSkip(e) {
+				p.setPhase(row, deployOnceAction, deployOnceMessage)
+				break
+			}
+			p.setPhase(row, phaseSkipped, "")
+		case event.StatusFailed:
+			p.setPhase(row, phaseFailed, fmt.Sprint(e.ApplyInfo.Error))
+		}
+	case event.TypePrune:
+		row := p.row(e.PruneInfo.Object)
+		switch e.PruneInfo.Status {
+		case event.StatusPending:
+			p.setPhase(row, phasePruning, "")
+		case event.StatusSuccessful:
+			p.setPhase(row, phasePruned, "")
+		case event.StatusFailed:
+			p.setPhase(row, phaseFailed, fmt.Sprint(e.PruneInfo.Error))
+		}
+	case event.TypeStatusUpdate:
+		row := p.rowForMetadata(e.StatusUpdateInfo.ObjectMetadata)
+		switch e.StatusUpdateInfo.Status {
+		case event.StatusSuccessful:
+			p.setPhase(row, phaseHealthy, e.StatusUpdateInfo.Message)
+		case event.StatusFailed:
+			p.setPhase(row, phaseFailed, e.StatusUpdateInfo.Message)
+		default:
+			p.setPhase(row, phaseWaiting, e.StatusUpdateInfo.Message)
+		}
+	default:
+		if e.IsErrorEvent() {
+			p.errors = append(p.errors, e.String())
+		}
+	}
+
+	p.render()
+}
+
+// Flush implement eventPrinter interface
+func (p *progressPrinter) Flush() {
+	if p.stopRefresh != nil {
+		close(p.stopRefresh)
+		<-p.refreshDone
+		p.stopRefresh = nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.render()
+	for _, err := range p.errors {
+		fmt.Fprintln(p.writer, err)
+	}
+}
+
+// row return the row for obj creating a new pending one if not already present
+func (p *progressPrinter) row(obj *unstructured.Unstructured) *progressRow {
+	return p.rowForMetadata(resource.ObjectMetadataFromUnstructured(obj))
+}
+
+// rowForMetadata return the row for objMeta creating a new pending one if not already present
+func (p *progressPrinter) rowForMetadata(objMeta resource.ObjectMetadata) *progressRow {
+	if row, found := p.rows[objMeta]; found {
+		return row
+	}
+
+	gk := schema.GroupKind{Group: objMeta.Group, Kind: objMeta.Kind}
+	row := &progressRow{
+		identifier: fmt.Sprintf("%s %s", gk.String(), objMeta.Name),
+		phase:      phasePending,
+		start:      p.clock.Now(),
+	}
+	p.rows[objMeta] = row
+	p.order = append(p.order, objMeta)
+	return row
+}
+
+// setPhase update row with a new phase and message, stopping its timer if the phase is a final one
+func (p *progressPrinter) setPhase(row *progressRow, phase, message string) {
+	row.phase = phase
+	row.message = message
+	switch phase {
+	case phaseHealthy, phaseSkipped, deployOnceAction, phasePruned, phaseFailed:
+		row.end = p.clock.Now()
+	default:
+		row.end = time.Time{}
+	}
+}
+
+// render clear the previous table and print the current status of the rows that fit in the terminal
+func (p *progressPrinter) render() {
+	width, height := p.size()
+	if rows := terminalRows(p.printedLines, width); rows > 0 {
+		fmt.Fprintf(p.writer, cursorUpFormat+clearToEnd, rows)
+	}
+
+	// the last line of the terminal is kept for the cursor, and one line can be used for the hidden rows
+	maxRows := len(p.order)
+	if height > 0 && len(p.order)+1 > height-1 {
+		maxRows = max(height-3, 0)
+	}
+	rows := p.visibleRows(maxRows)
+
+	builder := new(strings.Builder)
+	tabWriter := tabwriter.NewWriter(builder, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, "RESOURCE\tPHASE\tELAPSED\tMESSAGE")
+	for _, row := range rows {
+		end := row.end
+		if end.IsZero() {
+			end = p.clock.Now()
+		}
+		elapsed := end.Sub(row.start).Truncate(time.Second)
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\n", row.identifier, row.phase, elapsed, messageSpaces.Replace(row.message))
+	}
+	_ = tabWriter.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(builder.String(), "\n"), "\n")
+	if hidden := len(p.order) - len(rows); hidden > 0 {
+		lines = append(lines, fmt.Sprintf(hiddenRowsFormat, hidden))
+	}
+
+	p.printedLines = make([]int, 0, len(lines))
+	for _, line := range lines {
+		line = truncateLine(line, width)
+		p.printedLines = append(p.printedLines, utf8.RuneCountInString(line))
+		fmt.Fprintln(p.writer, line)
+	}
+}
+
+// visibleRows return at most maxRows rows in the order of the table, preferring the ones still in progress to
+// the completed ones
+func (p *progressPrinter) visibleRows(maxRows int) []*progressRow {
+	visible := make(map[resource.ObjectMetadata]bool, maxRows)
+	for _, completed := range []bool{false, true} {
+		for _, objMeta := range p.order {
+			if len(visible) == maxRows {
+				break
+			}
+			if !p.rows[objMeta].end.IsZero() == completed {
+				visible[objMeta] = true
+			}
+		}
+	}
+
+	rows := make([]*progressRow, 0, len(visible))
+	for _, objMeta := range p.order {
+		if visible[objMeta] {
+			rows = append(rows, p.rows[objMeta])
+		}
+	}
+	return rows
+}
+
+// truncateLine cut line to one character less than width, so it never reach the last column and is never
+// wrapped by the terminal; a zero width leave the line untouched
+func truncateLine(line string, width int) string {
+	if width <= 1 || utf8.RuneCountInString(line) < width {
+		return line
+	}
+
+	runes := []rune(line)
+	return string(runes[:width-1])
+}
+
+// terminalRows return the number of rows used in a terminal width columns wide by lines of the given lengths,
+// taking into account the lines wrapped after a resize of the terminal
+func terminalRows(lines []int, width int) int {
+	rows := 0
+	for _, length := range lines {
+		if width <= 0 || length <= width {
+			rows++
+			continue
+		}
+		rows += (length + width - 1) / width
+	}
+	return rows
+}
@@ -0,0 +1,210 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestNewEventPrinter(t *testing.T) {
+	t.Parallel()
+
+	fakeClock := clocktesting.NewFakePassiveClock(time.Now())
+	buffer := new(bytes.Buffer)
+
+	assert.IsType(t, &linePrinter{}, newEventPrinter(buffer, fakeClock, true, false))
+	assert.IsType(t, &linePrinter{}, newEventPrinter(buffer, fakeClock, false, false))
+}
+
+func TestLinePrinter(t *testing.T) {
+	t.Parallel()
+
+	buffer := new(bytes.Buffer)
+	printer := &linePrinter{writer: buffer}
+	printer.PrintEvent(event.Event{Type: event.TypeError, ErrorInfo: event.ErrorInfo{Error: errors.New("error")}})
+	printer.Flush()
+
+	assert.Equal(t, "error\n", buffer.String())
+}
+
+func TestProgressPrinter(t *testing.T) {
+	t.Parallel()
+
+	configMap := progressTestObject("v1", "ConfigMap", "config")
+	deployment := progressTestObject("apps/v1", "Deployment", "app")
+	secret := progressTestObject("v1", "Secret", "old")
+
+	tests := map[string]struct {
+		dryRun         bool
+		events         []event.Event
+		expectedOutput string
+	}{
+		"queue add pending rows": {
+			events: []event.Event{
+				{Type: event.TypeQueue, QueueInfo: event.QueueInfo{Objects: []*unstructured.Unstructured{configMap, deployment}}},
+			},
+			expectedOutput: `RESOURCE             PHASE    ELAPSED  MESSAGE
+ConfigMap config     pending  5s       
+Deployment.apps app  pending  5s       
+`,
+		},
+		"full lifecycle of resources": {
+			events: []event.Event{
+				{Type: event.TypeQueue, QueueInfo: event.QueueInfo{Objects: []*unstructured.Unstructured{configMap, deployment}}},
+				{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: configMap, Status: event.StatusSuccessful}},
+				{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: deployment, Status: event.StatusPending}},
+				{Type: event.TypeStatusUpdate, StatusUpdateInfo: event.StatusUpdateInfo{
+					Status:         event.StatusSuccessful,
+					Message:        "resource is current",
+					ObjectMetadata: resource.ObjectMetadataFromUnstructured(configMap),
+				}},
+				{Type: event.TypePrune, PruneInfo: event.PruneInfo{Object: secret, Status: event.StatusSuccessful}},
+			},
+			expectedOutput: `RESOURCE             PHASE     ELAPSED  MESSAGE
+ConfigMap config     healthy   0s       resource is current
+Deployment.apps app  applying  5s       
+Secret old           pruned    0s       
+`,
+		},
+		"failures are reported": {
+			events: []event.Event{
+				{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: configMap, Status: event.StatusFailed, Error: errors.New("boom")}},
+				{Type: event.TypeError, ErrorInfo: event.ErrorInfo{Error: errors.New("generic error")}},
+			},
+			expectedOutput: `RESOURCE          PHASE   ELAPSED  MESSAGE
+ConfigMap config  failed  0s       boom
+generic error
+`,
+		},
+		"dry run mark applied resources as healthy": {
+			dryRun: true,
+			events: []event.Event{
+				{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: configMap, Status: event.StatusSuccessful}},
+			},
+			expectedOutput: `RESOURCE          PHASE    ELAPSED  MESSAGE
+ConfigMap config  healthy  0s       
+`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			start := time.Now()
+			fakeClock := clocktesting.NewFakePassiveClock(start)
+			buffer := new(bytes.Buffer)
+			printer := newProgressPrinter(buffer, fakeClock, test.dryRun)
+			for _, e := range test.events {
+				printer.PrintEvent(e)
+			}
+
+			fakeClock.SetTime(start.Add(5 * time.Second))
+			buffer.Reset()
+			printer.printedLines = nil
+			printer.Flush()
+			assert.Equal(t, test.expectedOutput, buffer.String())
+		})
+	}
+}
+
+func TestProgressPrinterClearPreviousOutput(t *testing.T) {
+	t.Parallel()
+
+	fakeClock := clocktesting.NewFakePassiveClock(time.Now())
+	buffer := new(bytes.Buffer)
+	printer := newProgressPrinter(buffer, fakeClock, false)
+	printer.PrintEvent(event.Event{Type: event.TypeQueue, QueueInfo: event.QueueInfo{
+		Objects: []*unstructured.Unstructured{progressTestObject("v1", "ConfigMap", "config")},
+	}})
+	assert.False(t, strings.Contains(buffer.String(), clearToEnd))
+
+	buffer.Reset()
+	printer.Flush()
+	assert.True(t, strings.HasPrefix(buffer.String(), "\x1b[2A"+clearToEnd))
+}
+
+func TestProgressPrinterTerminalSize(t *testing.T) {
+	t.Parallel()
+
+	fakeClock := clocktesting.NewFakePassiveClock(time.Now())
+	buffer := new(bytes.Buffer)
+	printer := newProgressPrinter(buffer, fakeClock, false)
+	printer.size = func() (int, int) { return 30, 5 }
+
+	objects := []*unstructured.Unstructured{
+		progressTestObject("v1", "ConfigMap", "first"),
+		progressTestObject("v1", "ConfigMap", "second"),
+		progressTestObject("v1", "ConfigMap", "third"),
+		progressTestObject("v1", "ConfigMap", "fourth"),
+	}
+	printer.PrintEvent(event.Event{Type: event.TypeQueue, QueueInfo: event.QueueInfo{Objects: objects}})
+	printer.PrintEvent(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: objects[0], Status: event.StatusFailed, Error: errors.New("boom")}})
+
+	buffer.Reset()
+	printer.Flush()
+	assert.Equal(t, "\x1b[4A"+clearToEnd+`RESOURCE          PHASE    EL
+ConfigMap second  pending  0s
+ConfigMap third   pending  0s
++2 more
+`, buffer.String(), "the rows still in progress are preferred and the lines are cut before the last column")
+
+	// after the terminal is narrowed the previous lines are wrapped on more rows
+	printer.size = func() (int, int) { return 10, 0 }
+	buffer.Reset()
+	printer.Flush()
+	assert.True(t, strings.HasPrefix(buffer.String(), "\x1b[10A"+clearToEnd))
+}
+
+func TestProgressPrinterRefresh(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	fakeClock := clocktesting.NewFakePassiveClock(start)
+	buffer := new(bytes.Buffer)
+	printer := newProgressPrinter(buffer, fakeClock, false)
+	printer.PrintEvent(event.Event{Type: event.TypeQueue, QueueInfo: event.QueueInfo{
+		Objects: []*unstructured.Unstructured{progressTestObject("v1", "ConfigMap", "config")},
+	}})
+
+	printer.startRefresh(time.Millisecond)
+	fakeClock.SetTime(start.Add(3 * time.Second))
+	assert.Eventually(t, func() bool {
+		printer.lock.Lock()
+		defer printer.lock.Unlock()
+		return strings.Contains(buffer.String(), "pending  3s")
+	}, time.Second, time.Millisecond, "the table is redrawn without receiving new events")
+	printer.Flush()
+}
+
+func progressTestObject(apiVersion, kind, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetName(name)
+	obj.SetNamespace("mlp-progress-test")
+	return obj
+}
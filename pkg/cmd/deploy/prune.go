@@ -0,0 +1,286 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/util"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// pruneClass group resources that must be completely removed before starting the deletion of the next group, the
+// order follow the one used by the prune process that is the reverse of the apply order
+type pruneClass int
+
+const (
+	pruneClassCustomResources pruneClass = iota
+	pruneClassAPIExtensions
+	pruneClassWorkloads
+	pruneClassConfigurations
+	pruneClassDefinitions
+	pruneClassNamespaces
+
+	defaultPruneWaitInterval = 1 * time.Second
+)
+
+var configurationResources = map[schema.GroupResource]struct{}{
+	{Group: "", Resource: "configmaps"}:                                   {},
+	{Group: "", Resource: "secrets"}:                                      {},
+	{Group: "", Resource: "serviceaccounts"}:                              {},
+	{Group: "", Resource: "persistentvolumeclaims"}:                       {},
+	{Group: "", Resource: "persistentvolumes"}:                            {},
+	{Group: "", Resource: "resourcequotas"}:                               {},
+	{Group: "", Resource: "limitranges"}:                                  {},
+	{Group: "rbac.authorization.k8s.io", Resource: "roles"}:               {},
+	{Group: "rbac.authorization.k8s.io", Resource: "rolebindings"}:        {},
+	{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"}:        {},
+	{Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings"}: {},
+}
+
+// classForResource return the pruneClass of the resource described by gr
+func classForResource(gr schema.GroupResource) pruneClass {
+	switch gr {
+	case schema.GroupResource{Group: "", Resource: "namespaces"}:
+		return pruneClassNamespaces
+	case schema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}:
+		return pruneClassDefinitions
+	}
+
+	switch gr.Group {
+	case "admissionregistration.k8s.io", "apiregistration.k8s.io":
+		return pruneClassAPIExtensions
+	}
+
+	if _, found := configurationResources[gr]; found {
+		return pruneClassConfigurations
+	}
+
+	// groups without a domain or in the k8s.io domain are builtin kubernetes types, the others come from crds
+	if strings.Contains(gr.Group, ".") && !strings.HasSuffix(gr.Group, ".k8s.io") {
+		return pruneClassCustomResources
+	}
+
+	return pruneClassWorkloads
+}
+
+// pendingDeletion keep track of a resource deleted in the remote cluster that can still be present
+type pendingDeletion struct {
+	client dynamic.ResourceInterface
+	name   string
+}
+
+// pruneTracker keep track of the deletions requested and wait for their completion every time a resource of
+// a different pruneClass is deleted, to avoid removing controllers, configurations or definitions that are still
+// needed by resources with finalizers
+type pruneTracker struct {
+	timeout  time.Duration
+	interval time.Duration
+
+	lock      sync.Mutex
+	lastClass pruneClass
+	pending   []pendingDeletion
+	// timedOut contains the deletions not completed before the timeout, checked again at the end of the deploy
+	timedOut []pendingDeletion
+}
+
+// delete call deleteFn for the resource name of type gvr after waiting the completion of the deletions of
+// a different class, the deletion is tracked if it has been accepted by the remote server
+func (t *pruneTracker) delete(ctx context.Context, gvr schema.GroupVersionResource, client dynamic.ResourceInterface, name string, opts metav1.DeleteOptions, subresources ...string) error {
+	if len(opts.DryRun) > 0 || len(subresources) > 0 {
+		return client.Delete(ctx, name, opts, subresources...)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	class := classForResource(gvr.GroupResource())
+	if class != t.lastClass && len(t.pending) > 0 {
+		t.waitPendingDeletions(ctx)
+	}
+	t.lastClass = class
+
+	if err := client.Delete(ctx, name, opts); err != nil {
+		return err
+	}
+
+	t.pending = append(t.pending, pendingDeletion{client: client, name: name})
+	return nil
+}
+
+// waitPendingDeletions wait until all the pending deletions are completed or the timeout is reached, the ones not
+// completed in time are saved in timedOut
+func (t *pruneTracker) waitPendingDeletions(ctx context.Context) {
+	logger := logr.FromContextOrDiscard(ctx)
+	defer func() { t.pending = nil }()
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	logger.V(5).Info("waiting for pruned resources removal", "count", len(t.pending))
+	for idx, deletion := range t.pending {
+		err := wait.PollUntilContextCancel(ctx, t.interval, true, func(ctx context.Context) (bool, error) {
+			_, err := deletion.client.Get(ctx, deletion.name, metav1.GetOptions{})
+			switch {
+			case apierrors.IsNotFound(err):
+				return true, nil
+			case err != nil:
+				return false, err
+			}
+			return false, nil
+		})
+		if err != nil {
+			logger.V(3).Info("stop waiting for pruned resource removal", "name", deletion.name, "reason", err.Error())
+			t.timedOut = append(t.timedOut, t.pending[idx:]...)
+			return
+		}
+	}
+}
+
+// terminatingResources wait for the removal of all the pruned resources, including the ones not removed in time
+// during the deploy, and return the resources that are still present when the timeout is reached
+func (t *pruneTracker) terminatingResources(ctx context.Context) ([]*unstructured.Unstructured, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.pending = append(t.timedOut, t.pending...)
+	t.timedOut = nil
+	if len(t.pending) > 0 {
+		t.waitPendingDeletions(ctx)
+	}
+
+	terminating := make([]*unstructured.Unstructured, 0)
+	for _, deletion := range t.timedOut {
+		obj, err := deletion.client.Get(ctx, deletion.name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			continue
+		case err != nil:
+			return nil, err
+		}
+		terminating = append(terminating, obj)
+	}
+	t.timedOut = nil
+
+	return terminating, nil
+}
+
+// terminatingError return the error reported for a pruned resource that is still present after the wait timeout,
+// listing the finalizers that are blocking its removal
+func terminatingError(obj *unstructured.Unstructured) error {
+	name := obj.GetName()
+	if namespace := obj.GetNamespace(); len(namespace) > 0 {
+		name = namespace + "/" + name
+	}
+
+	id := obj.GroupVersionKind().GroupKind().String() + " " + name
+	finalizers := obj.GetFinalizers()
+	if len(finalizers) == 0 {
+		return fmt.Errorf("pruned resource %s is still terminating", id)
+	}
+	return fmt.Errorf("pruned resource %s is still terminating, blocked by finalizers: %s", id, strings.Join(finalizers, ", "))
+}
+
+// pruneFactory wrap a ClientFactory for returning a dynamic client that will order the deletions using a pruneTracker
+type pruneFactory struct {
+	util.ClientFactory
+	tracker *pruneTracker
+}
+
+// newPruneFactory return a ClientFactory that wait at most timeout between deletions of different classes, if
+// timeout is zero factory is returned unmodified
+func newPruneFactory(factory util.ClientFactory, timeout time.Duration) util.ClientFactory {
+	if timeout <= 0 {
+		return factory
+	}
+
+	return &pruneFactory{
+		ClientFactory: factory,
+		tracker: &pruneTracker{
+			timeout:  timeout,
+			interval: defaultPruneWaitInterval,
+		},
+	}
+}
+
+// trackerForFactory return the pruneTracker used by factory, or nil if the deletions are not tracked
+func trackerForFactory(factory util.ClientFactory) *pruneTracker {
+	if pruneFactory, ok := factory.(*pruneFactory); ok {
+		return pruneFactory.tracker
+	}
+	return nil
+}
+
+// DynamicClient override the ClientFactory method wrapping the returned client
+func (f *pruneFactory) DynamicClient() (dynamic.Interface, error) {
+	client, err := f.ClientFactory.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pruneClient{Interface: client, tracker: f.tracker}, nil
+}
+
+type pruneClient struct {
+	dynamic.Interface
+	tracker *pruneTracker
+}
+
+func (c *pruneClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &pruneResourceClient{
+		NamespaceableResourceInterface: c.Interface.Resource(gvr),
+		tracker:                        c.tracker,
+		gvr:                            gvr,
+	}
+}
+
+type pruneResourceClient struct {
+	dynamic.NamespaceableResourceInterface
+	tracker *pruneTracker
+	gvr     schema.GroupVersionResource
+}
+
+func (c *pruneResourceClient) Namespace(namespace string) dynamic.ResourceInterface {
+	return &pruneNamespacedClient{
+		ResourceInterface: c.NamespaceableResourceInterface.Namespace(namespace),
+		tracker:           c.tracker,
+		gvr:               c.gvr,
+	}
+}
+
+func (c *pruneResourceClient) Delete(ctx context.Context, name string, opts metav1.DeleteOptions, subresources ...string) error {
+	return c.tracker.delete(ctx, c.gvr, c.NamespaceableResourceInterface, name, opts, subresources...)
+}
+
+type pruneNamespacedClient struct {
+	dynamic.ResourceInterface
+	tracker *pruneTracker
+	gvr     schema.GroupVersionResource
+}
+
+func (c *pruneNamespacedClient) Delete(ctx context.Context, name string, opts metav1.DeleteOptions, subresources ...string) error {
+	return c.tracker.delete(ctx, c.gvr, c.ResourceInterface, name, opts, subresources...)
+}
@@ -0,0 +1,228 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestClassForResource(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		groupResource schema.GroupResource
+		expectedClass pruneClass
+	}{
+		"custom resource": {
+			groupResource: schema.GroupResource{Group: "example.com", Resource: "examples"},
+			expectedClass: pruneClassCustomResources,
+		},
+		"webhook": {
+			groupResource: schema.GroupResource{Group: "admissionregistration.k8s.io", Resource: "mutatingwebhookconfigurations"},
+			expectedClass: pruneClassAPIExtensions,
+		},
+		"deployment": {
+			groupResource: schema.GroupResource{Group: "apps", Resource: "deployments"},
+			expectedClass: pruneClassWorkloads,
+		},
+		"service": {
+			groupResource: schema.GroupResource{Group: "", Resource: "services"},
+			expectedClass: pruneClassWorkloads,
+		},
+		"ingress": {
+			groupResource: schema.GroupResource{Group: "networking.k8s.io", Resource: "ingresses"},
+			expectedClass: pruneClassWorkloads,
+		},
+		"configmap": {
+			groupResource: schema.GroupResource{Group: "", Resource: "configmaps"},
+			expectedClass: pruneClassConfigurations,
+		},
+		"role": {
+			groupResource: schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "roles"},
+			expectedClass: pruneClassConfigurations,
+		},
+		"crd": {
+			groupResource: schema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"},
+			expectedClass: pruneClassDefinitions,
+		},
+		"namespace": {
+			groupResource: schema.GroupResource{Group: "", Resource: "namespaces"},
+			expectedClass: pruneClassNamespaces,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expectedClass, classForResource(test.groupResource))
+		})
+	}
+}
+
+func TestNewPruneFactory(t *testing.T) {
+	t.Parallel()
+
+	factory := jpltesting.NewTestClientFactory()
+	assert.Equal(t, factory, newPruneFactory(factory, 0))
+	assert.IsType(t, &pruneFactory{}, newPruneFactory(factory, time.Second))
+}
+
+func TestPruneTracker(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-prune-test"
+	crGVR := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "examples"}
+	cmGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	tests := map[string]struct {
+		crGetsBeforeRemoval int
+		dryRun              bool
+		expectedCRGets      int
+	}{
+		"wait for removal of resources of the previous class": {
+			crGetsBeforeRemoval: 2,
+			expectedCRGets:      3,
+		},
+		"stop waiting after timeout": {
+			crGetsBeforeRemoval: -1,
+		},
+		"dry run deletions are not tracked": {
+			dryRun:         true,
+			expectedCRGets: 0,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cr := &unstructured.Unstructured{}
+			cr.SetAPIVersion("example.com/v1")
+			cr.SetKind("Example")
+			cr.SetName("example")
+			cr.SetNamespace(namespace)
+			cm := &unstructured.Unstructured{}
+			cm.SetAPIVersion("v1")
+			cm.SetKind("ConfigMap")
+			cm.SetName("example")
+			cm.SetNamespace(namespace)
+
+			fakeClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+				crGVR: "ExampleList",
+				cmGVR: "ConfigMapList",
+			}, cr, cm)
+
+			crGets := 0
+			fakeClient.PrependReactor("get", crGVR.Resource, func(k8stesting.Action) (bool, runtime.Object, error) {
+				crGets++
+				if test.crGetsBeforeRemoval < 0 || crGets <= test.crGetsBeforeRemoval {
+					return true, cr, nil
+				}
+				return true, nil, apierrors.NewNotFound(crGVR.GroupResource(), cr.GetName())
+			})
+
+			tracker := &pruneTracker{timeout: 50 * time.Millisecond, interval: time.Millisecond}
+			client := &pruneClient{Interface: fakeClient, tracker: tracker}
+			opts := metav1.DeleteOptions{}
+			if test.dryRun {
+				opts.DryRun = []string{metav1.DryRunAll}
+			}
+
+			ctx := context.TODO()
+			require.NoError(t, client.Resource(crGVR).Namespace(namespace).Delete(ctx, cr.GetName(), opts))
+			require.NoError(t, client.Resource(cmGVR).Namespace(namespace).Delete(ctx, cm.GetName(), opts))
+
+			if test.crGetsBeforeRemoval < 0 {
+				assert.Positive(t, crGets)
+			} else {
+				assert.Equal(t, test.expectedCRGets, crGets)
+			}
+
+			if test.dryRun {
+				assert.Empty(t, tracker.pending)
+				return
+			}
+			assert.Len(t, tracker.pending, 1)
+			assert.Equal(t, pruneClassConfigurations, tracker.lastClass)
+		})
+	}
+}
+
+func TestPruneTrackerTerminatingResources(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-prune-test"
+	crGVR := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "examples"}
+	cmGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	cr := &unstructured.Unstructured{}
+	cr.SetAPIVersion("example.com/v1")
+	cr.SetKind("Example")
+	cr.SetName("stuck")
+	cr.SetNamespace(namespace)
+	cr.SetFinalizers([]string{"example.com/cleanup", "example.com/backup"})
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetName("removed")
+	cm.SetNamespace(namespace)
+
+	fakeClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		crGVR: "ExampleList",
+		cmGVR: "ConfigMapList",
+	}, cr, cm)
+	// the custom resource is never removed because of its finalizers
+	fakeClient.PrependReactor("delete", crGVR.Resource, func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	tracker := &pruneTracker{timeout: 50 * time.Millisecond, interval: time.Millisecond}
+	client := &pruneClient{Interface: fakeClient, tracker: tracker}
+	ctx := context.TODO()
+
+	terminating, err := tracker.terminatingResources(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, terminating)
+
+	require.NoError(t, client.Resource(crGVR).Namespace(namespace).Delete(ctx, cr.GetName(), metav1.DeleteOptions{}))
+	require.NoError(t, client.Resource(cmGVR).Namespace(namespace).Delete(ctx, cm.GetName(), metav1.DeleteOptions{}))
+	assert.Len(t, tracker.timedOut, 1, "the custom resource is not removed before the configmap deletion")
+
+	terminating, err = tracker.terminatingResources(ctx)
+	require.NoError(t, err)
+	require.Len(t, terminating, 1)
+	assert.Equal(t, "stuck", terminating[0].GetName())
+	assert.Empty(t, tracker.pending)
+	assert.Empty(t, tracker.timedOut)
+
+	assert.EqualError(t, terminatingError(terminating[0]), "pruned resource Example.example.com mlp-prune-test/stuck is still terminating, blocked by finalizers: example.com/cleanup, example.com/backup")
+	assert.EqualError(t, terminatingError(cm), "pruned resource ConfigMap mlp-prune-test/removed is still terminating")
+	assert.Nil(t, trackerForFactory(jpltesting.NewTestClientFactory()))
+	assert.Equal(t, tracker, trackerForFactory(&pruneFactory{tracker: tracker}))
+}
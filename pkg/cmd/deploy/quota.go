@@ -0,0 +1,339 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/mutator"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	quotaCheckStrict = "strict"
+	quotaCheckWarn   = "warn"
+	quotaCheckOff    = "off"
+)
+
+var (
+	validQuotaCheckValues = []string{quotaCheckStrict, quotaCheckWarn, quotaCheckOff}
+
+	resourceQuotasGVR = corev1.SchemeGroupVersion.WithResource("resourcequotas")
+
+	// quotaResourceAliases map the quota resource names to the ones used for tracking the workloads demand
+	quotaResourceAliases = map[corev1.ResourceName]corev1.ResourceName{
+		corev1.ResourceCPU:            corev1.ResourceRequestsCPU,
+		corev1.ResourceMemory:         corev1.ResourceRequestsMemory,
+		corev1.ResourceRequestsCPU:    corev1.ResourceRequestsCPU,
+		corev1.ResourceRequestsMemory: corev1.ResourceRequestsMemory,
+		corev1.ResourceLimitsCPU:      corev1.ResourceLimitsCPU,
+		corev1.ResourceLimitsMemory:   corev1.ResourceLimitsMemory,
+	}
+
+	// quotaWorkloads contains the resource name and the paths of the replicas and pod spec fields for the workloads
+	// whose pods are counted against the namespace quotas
+	quotaWorkloads = map[schema.GroupKind]quotaWorkload{
+		{Group: "apps", Kind: "Deployment"}:  {resource: "deployments", replicasPath: []string{"spec", "replicas"}, podSpecPath: []string{"spec", "template", "spec"}},
+		{Group: "apps", Kind: "StatefulSet"}: {resource: "statefulsets", replicasPath: []string{"spec", "replicas"}, podSpecPath: []string{"spec", "template", "spec"}},
+		{Group: "apps", Kind: "ReplicaSet"}:  {resource: "replicasets", replicasPath: []string{"spec", "replicas"}, podSpecPath: []string{"spec", "template", "spec"}},
+		{Group: "batch", Kind: "Job"}:        {resource: "jobs", replicasPath: []string{"spec", "parallelism"}, podSpecPath: []string{"spec", "template", "spec"}},
+		{Kind: "Pod"}:                        {resource: "pods", podSpecPath: []string{"spec"}},
+	}
+)
+
+// quotaWorkload describe where to find the data needed for calculating the demand of a workload kind
+type quotaWorkload struct {
+	resource     string
+	replicasPath []string
+	podSpecPath  []string
+}
+
+// workloadDemand contains the compute resources that a workload will add to its namespace usage
+type workloadDemand struct {
+	name      string
+	namespace string
+	resources corev1.ResourceList
+}
+
+// quotaViolation describe a ResourceQuota that will be exceeded by the deploy
+type quotaViolation struct {
+	quota     string
+	namespace string
+	resource  corev1.ResourceName
+	required  resource.Quantity
+	available resource.Quantity
+	workloads []string
+}
+
+// String implement fmt.Stringer interface
+func (v quotaViolation) String() string {
+	return fmt.Sprintf("ResourceQuota %q in namespace %q exceeded for %s: %s required, %s available (%s)", v.quota,
+		v.namespace, v.resource, v.required.String(), v.available.String(), strings.Join(v.workloads, ", "))
+}
+
+// checkQuota compare the compute resources required by the workloads in resources with the ResourceQuotas of
+// their namespaces, returning an error or printing a warning based on the configured quota check
+func (o *Options) checkQuota(ctx context.Context, resources []*unstructured.Unstructured, mutators []mutator.Interface) error {
+	logger := logr.FromContextOrDiscard(ctx)
+	if o.quotaCheck == quotaCheckOff || len(o.quotaCheck) == 0 {
+		return nil
+	}
+
+	// run generators and mutators on a copy of the resources for taking into account the generated jobs
+	// and the resources injected by the workload defaults
+	copies := make([]*unstructured.Unstructured, 0, len(resources))
+	for _, obj := range resources {
+		copies = append(copies, obj.DeepCopy())
+	}
+	rendered, err := renderOffline(copies, mutators)
+	if err != nil {
+		return err
+	}
+
+	client, err := o.clientFactory.DynamicClient()
+	if err != nil {
+		return err
+	}
+
+	logger.V(3).Info("checking resource quotas")
+	violations, err := quotaViolations(ctx, client, rendered)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	if o.quotaCheck == quotaCheckWarn {
+		for _, violation := range violations {
+			fmt.Fprintf(o.writer, "warning: %s\n", violation)
+		}
+		return nil
+	}
+
+	builder := new(strings.Builder)
+	builder.WriteString(fmt.Sprintf("the deploy will exceed %d resource quota(s):\n", len(violations)))
+	for _, violation := range violations {
+		builder.WriteString(fmt.Sprintf("\t- %s\n", violation))
+	}
+	return errors.New(builder.String())
+}
+
+// quotaViolations return the ResourceQuotas that will be exceeded applying the workloads contained in resources,
+// the demand of every workload is the difference between its new pods and the ones of its current remote version
+func quotaViolations(ctx context.Context, client dynamic.Interface, resources []*unstructured.Unstructured) ([]quotaViolation, error) {
+	demands := make(map[string][]workloadDemand)
+	for _, obj := range resources {
+		gvk := obj.GroupVersionKind()
+		workload, found := quotaWorkloads[gvk.GroupKind()]
+		if !found {
+			continue
+		}
+
+		demand, err := workload.demand(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		gvr := gvk.GroupVersion().WithResource(workload.resource)
+		remoteObj, err := client.Resource(gvr).Namespace(obj.GetNamespace()).Get(ctx, obj.GetName(), metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s %q: %w", gvk.Kind, obj.GetName(), err)
+		default:
+			remoteDemand, err := workload.demand(remoteObj)
+			if err != nil {
+				return nil, err
+			}
+			for name, quantity := range remoteDemand {
+				current := demand[name]
+				current.Sub(quantity)
+				demand[name] = current
+			}
+		}
+
+		demands[obj.GetNamespace()] = append(demands[obj.GetNamespace()], workloadDemand{
+			name:      fmt.Sprintf("%s/%s", gvk.Kind, obj.GetName()),
+			namespace: obj.GetNamespace(),
+			resources: demand,
+		})
+	}
+
+	violations := make([]quotaViolation, 0)
+	for _, namespace := range slices.Sorted(maps.Keys(demands)) {
+		list, err := client.Resource(resourceQuotasGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list resource quotas in namespace %q: %w", namespace, err)
+		}
+
+		for _, item := range list.Items {
+			quota := new(corev1.ResourceQuota)
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, quota); err != nil {
+				return nil, err
+			}
+
+			violations = append(violations, quotaViolationsForQuota(quota, demands[namespace])...)
+		}
+	}
+
+	return violations, nil
+}
+
+// quotaViolationsForQuota return the compute resources of quota that will be exceeded by demands, quotas with
+// scopes are ignored because they apply only to a subset of pods
+func quotaViolationsForQuota(quota *corev1.ResourceQuota, demands []workloadDemand) []quotaViolation {
+	if len(quota.Spec.Scopes) > 0 || quota.Spec.ScopeSelector != nil {
+		return nil
+	}
+
+	hard := quota.Status.Hard
+	if len(hard) == 0 {
+		hard = quota.Spec.Hard
+	}
+
+	violations := make([]quotaViolation, 0)
+	for _, name := range slices.Sorted(maps.Keys(hard)) {
+		demandName, found := quotaResourceAliases[name]
+		if !found {
+			continue
+		}
+
+		required := resource.Quantity{}
+		workloads := make([]string, 0)
+		for _, demand := range demands {
+			quantity, found := demand.resources[demandName]
+			if !found {
+				continue
+			}
+
+			required.Add(quantity)
+			if quantity.Sign() > 0 {
+				workloads = append(workloads, fmt.Sprintf("%s: %s", demand.name, quantity.String()))
+			}
+		}
+
+		available := hard[name]
+		available.Sub(quota.Status.Used[name])
+		if required.Sign() <= 0 || required.Cmp(available) <= 0 {
+			continue
+		}
+
+		violations = append(violations, quotaViolation{
+			quota:     quota.Name,
+			namespace: quota.Namespace,
+			resource:  name,
+			required:  required,
+			available: available,
+			workloads: workloads,
+		})
+	}
+
+	return violations
+}
+
+// demand return the compute resources requested by all the replicas of the workload pods
+func (w quotaWorkload) demand(obj *unstructured.Unstructured) (corev1.ResourceList, error) {
+	replicas := int64(1)
+	if len(w.replicasPath) > 0 {
+		value, found, err := unstructured.NestedInt64(obj.Object, w.replicasPath...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read replicas of %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		if found {
+			replicas = value
+		}
+	}
+
+	podSpecData, _, err := unstructured.NestedMap(obj.Object, w.podSpecPath...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pod spec of %s %q: %w", obj.GetKind(), obj.GetName(), err)
+	}
+
+	podSpec := new(corev1.PodSpec)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(podSpecData, podSpec); err != nil {
+		return nil, fmt.Errorf("failed to read pod spec of %s %q: %w", obj.GetKind(), obj.GetName(), err)
+	}
+
+	demand := podDemand(podSpec)
+	for name, quantity := range demand {
+		quantity.Mul(replicas)
+		demand[name] = quantity
+	}
+	return demand, nil
+}
+
+// podDemand return the compute resources of a pod following the quota rules: the sum of the containers values or
+// the highest value of the init containers if greater
+func podDemand(podSpec *corev1.PodSpec) corev1.ResourceList {
+	demand := corev1.ResourceList{}
+	add := func(name corev1.ResourceName, quantity resource.Quantity) {
+		current := demand[name]
+		current.Add(quantity)
+		demand[name] = current
+	}
+
+	for _, container := range podSpec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			add(requestsName(name), quantity)
+		}
+		for name, quantity := range container.Resources.Limits {
+			add(limitsName(name), quantity)
+		}
+	}
+
+	for _, container := range podSpec.InitContainers {
+		values := corev1.ResourceList{}
+		for name, quantity := range container.Resources.Requests {
+			values[requestsName(name)] = quantity
+		}
+		for name, quantity := range container.Resources.Limits {
+			values[limitsName(name)] = quantity
+		}
+		for name, quantity := range values {
+			if current := demand[name]; quantity.Cmp(current) > 0 {
+				demand[name] = quantity
+			}
+		}
+	}
+
+	return demand
+}
+
+// requestsName return the quota name for the requests of a compute resource
+func requestsName(name corev1.ResourceName) corev1.ResourceName {
+	return cmp.Or(quotaResourceAliases[name], corev1.ResourceName("requests."+string(name)))
+}
+
+// limitsName return the quota name for the limits of a compute resource
+func limitsName(name corev1.ResourceName) corev1.ResourceName {
+	return corev1.ResourceName("limits." + string(name))
+}
@@ -0,0 +1,275 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/mutator"
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/utils/ptr"
+)
+
+func TestPodDemand(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		podSpec        *corev1.PodSpec
+		expectedDemand corev1.ResourceList
+	}{
+		"empty pod": {
+			podSpec:        &corev1.PodSpec{},
+			expectedDemand: corev1.ResourceList{},
+		},
+		"sum of containers": {
+			podSpec: &corev1.PodSpec{
+				Containers: []corev1.Container{
+					testContainer("100m", "128Mi", "200m", ""),
+					testContainer("250m", "", "", "1Gi"),
+				},
+			},
+			expectedDemand: corev1.ResourceList{
+				corev1.ResourceRequestsCPU:    resource.MustParse("350m"),
+				corev1.ResourceRequestsMemory: resource.MustParse("128Mi"),
+				corev1.ResourceLimitsCPU:      resource.MustParse("200m"),
+				corev1.ResourceLimitsMemory:   resource.MustParse("1Gi"),
+			},
+		},
+		"init containers higher than containers": {
+			podSpec: &corev1.PodSpec{
+				InitContainers: []corev1.Container{
+					testContainer("1", "64Mi", "", ""),
+				},
+				Containers: []corev1.Container{
+					testContainer("100m", "128Mi", "", ""),
+					testContainer("100m", "128Mi", "", ""),
+				},
+			},
+			expectedDemand: corev1.ResourceList{
+				corev1.ResourceRequestsCPU:    resource.MustParse("1"),
+				corev1.ResourceRequestsMemory: resource.MustParse("256Mi"),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			demand := podDemand(test.podSpec)
+			assert.Equal(t, len(test.expectedDemand), len(demand))
+			for name, quantity := range test.expectedDemand {
+				actual := demand[name]
+				assert.Zero(t, quantity.Cmp(actual), "unexpected value for %s: %s", name, actual.String())
+			}
+		})
+	}
+}
+
+func TestQuotaViolations(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-deploy-test"
+	quota := testResourceQuota(namespace, "compute", corev1.ResourceList{
+		corev1.ResourceRequestsCPU: resource.MustParse("2"),
+		corev1.ResourceMemory:      resource.MustParse("2Gi"),
+	}, corev1.ResourceList{
+		corev1.ResourceRequestsCPU: resource.MustParse("1"),
+		corev1.ResourceMemory:      resource.MustParse("1Gi"),
+	})
+	scopedQuota := testResourceQuota(namespace, "best-effort", corev1.ResourceList{
+		corev1.ResourceRequestsCPU: resource.MustParse("0"),
+	}, nil)
+	scopedQuota.Spec.Scopes = []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeNotBestEffort}
+	remoteDeployment := testDeployment(t, namespace, "api", 2, "500m", "256Mi")
+
+	tests := map[string]struct {
+		resources          []*unstructured.Unstructured
+		expectedViolations []string
+	}{
+		"workloads inside the quota": {
+			resources: []*unstructured.Unstructured{
+				testDeployment(t, namespace, "new", 1, "500m", "512Mi"),
+			},
+			expectedViolations: []string{},
+		},
+		"new workloads exceeding the quota": {
+			resources: []*unstructured.Unstructured{
+				testDeployment(t, namespace, "new", 3, "500m", "128Mi"),
+				testDeployment(t, namespace, "other", 1, "100m", "128Mi"),
+			},
+			expectedViolations: []string{
+				`ResourceQuota "compute" in namespace "mlp-deploy-test" exceeded for requests.cpu: 1600m required, 1 available (Deployment/new: 1500m, Deployment/other: 100m)`,
+			},
+		},
+		"updated workload counts only the difference": {
+			resources: []*unstructured.Unstructured{
+				testDeployment(t, namespace, "api", 3, "500m", "1Gi"),
+			},
+			expectedViolations: []string{
+				`ResourceQuota "compute" in namespace "mlp-deploy-test" exceeded for memory: 2560Mi required, 1Gi available (Deployment/api: 2560Mi)`,
+			},
+		},
+		"scaled down workload free resources": {
+			resources: []*unstructured.Unstructured{
+				testDeployment(t, namespace, "api", 0, "500m", "256Mi"),
+				testDeployment(t, namespace, "new", 1, "2", "128Mi"),
+			},
+			expectedViolations: []string{},
+		},
+		"resources that are not workloads are ignored": {
+			resources: []*unstructured.Unstructured{
+				{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata":   map[string]interface{}{"name": "config", "namespace": namespace},
+				}},
+			},
+			expectedViolations: []string{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			client := dynamicfake.NewSimpleDynamicClient(jpltesting.Scheme, quota, scopedQuota, remoteDeployment)
+			violations, err := quotaViolations(context.TODO(), client, test.resources)
+			require.NoError(t, err)
+
+			messages := make([]string, 0, len(violations))
+			for _, violation := range violations {
+				messages = append(messages, violation.String())
+			}
+			assert.Equal(t, test.expectedViolations, messages)
+		})
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-deploy-test"
+	quota := testResourceQuota(namespace, "compute", corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("1"),
+	}, corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("500m"),
+	})
+	resources := []*unstructured.Unstructured{testDeployment(t, namespace, "api", 2, "500m", "")}
+
+	tests := map[string]struct {
+		quotaCheck     string
+		expectedOutput string
+		expectedError  string
+	}{
+		"check disabled": {
+			quotaCheck: quotaCheckOff,
+		},
+		"warn mode": {
+			quotaCheck:     quotaCheckWarn,
+			expectedOutput: `warning: ResourceQuota "compute" in namespace "mlp-deploy-test" exceeded for cpu: 1 required, 500m available (Deployment/api: 1)` + "\n",
+		},
+		"strict mode": {
+			quotaCheck:    quotaCheckStrict,
+			expectedError: `the deploy will exceed 1 resource quota(s):` + "\n\t" + `- ResourceQuota "compute" in namespace "mlp-deploy-test" exceeded for cpu: 1 required, 500m available (Deployment/api: 1)`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			output := new(strings.Builder)
+			tf := jpltesting.NewTestClientFactory().WithNamespace(namespace)
+			tf.FakeDynamicClient = dynamicfake.NewSimpleDynamicClient(jpltesting.Scheme, quota)
+			options := &Options{
+				quotaCheck:    test.quotaCheck,
+				clientFactory: tf,
+				writer:        output,
+			}
+
+			err := options.checkQuota(context.TODO(), resources, []mutator.Interface{})
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+			assert.Equal(t, test.expectedOutput, output.String())
+		})
+	}
+}
+
+func testContainer(requestsCPU, requestsMemory, limitsCPU, limitsMemory string) corev1.Container {
+	list := func(cpu, memory string) corev1.ResourceList {
+		resources := corev1.ResourceList{}
+		if len(cpu) > 0 {
+			resources[corev1.ResourceCPU] = resource.MustParse(cpu)
+		}
+		if len(memory) > 0 {
+			resources[corev1.ResourceMemory] = resource.MustParse(memory)
+		}
+		return resources
+	}
+
+	return corev1.Container{
+		Name: "container",
+		Resources: corev1.ResourceRequirements{
+			Requests: list(requestsCPU, requestsMemory),
+			Limits:   list(limitsCPU, limitsMemory),
+		},
+	}
+}
+
+func testResourceQuota(namespace, name string, hard, used corev1.ResourceList) *corev1.ResourceQuota {
+	return &corev1.ResourceQuota{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ResourceQuota"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+		Status:     corev1.ResourceQuotaStatus{Hard: hard, Used: used},
+	}
+}
+
+func testDeployment(t *testing.T, namespace, name string, replicas int32, cpu, memory string) *unstructured.Unstructured {
+	t.Helper()
+
+	deployment := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(replicas),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{testContainer(cpu, memory, "", "")},
+				},
+			},
+		},
+	}
+
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(deployment)
+	require.NoError(t, err)
+	return &unstructured.Unstructured{Object: data}
+}
@@ -0,0 +1,150 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var (
+	configMapGK = schema.GroupKind{Kind: "ConfigMap"}
+	secretGK    = schema.GroupKind{Kind: "Secret"}
+)
+
+// changedDependencies return the ConfigMaps and Secrets in resources that have been created or patched by the
+// deploy, the ones without a namespace are moved in namespace
+func changedDependencies(resources []*unstructured.Unstructured, metrics *metricsRecorder, namespace string) []*unstructured.Unstructured {
+	changed := make([]*unstructured.Unstructured, 0)
+	for _, obj := range resources {
+		switch obj.GroupVersionKind().GroupKind() {
+		case configMapGK, secretGK:
+		default:
+			continue
+		}
+
+		operation := metrics.operation(summaryIdentifier(resource.ObjectMetadataFromUnstructured(obj)))
+		if operation != applyOperationCreate && operation != applyOperationPatch {
+			continue
+		}
+
+		if len(obj.GetNamespace()) == 0 {
+			obj = obj.DeepCopy()
+			obj.SetNamespace(namespace)
+		}
+		changed = append(changed, obj)
+	}
+
+	return changed
+}
+
+// restartDependentWorkloads trigger a new rollout of the live workloads that use the ConfigMaps and Secrets changed
+// by the deploy and that are not part of it, patching the dependencies checksum of their pod template
+func (o *Options) restartDependentWorkloads(ctx context.Context, resources []*unstructured.Unstructured, metrics *metricsRecorder, namespace string) error {
+	logger := logr.FromContextOrDiscard(ctx)
+	changed := changedDependencies(resources, metrics, namespace)
+	if len(changed) == 0 {
+		return nil
+	}
+
+	project, err := o.projectConfig()
+	if err != nil {
+		return err
+	}
+
+	workloads, err := extensions.NewWorkloads(project.Deploy.Workloads)
+	if err != nil {
+		return err
+	}
+
+	client, err := o.clientFactory.DynamicClient()
+	if err != nil {
+		return err
+	}
+
+	mapper, err := o.clientFactory.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+
+	// the workloads in the deploy have already received the new checksums from the dependencies mutator
+	deployed := sets.New[resource.ObjectMetadata]()
+	for _, obj := range resources {
+		objMeta := resource.ObjectMetadataFromUnstructured(obj)
+		if len(objMeta.Namespace) == 0 {
+			objMeta.Namespace = namespace
+		}
+		deployed.Insert(objMeta)
+	}
+
+	namespaces := sets.New[string]()
+	for _, obj := range changed {
+		namespaces.Insert(obj.GetNamespace())
+	}
+
+	logger.V(3).Info("searching workloads depending on changed resources", "count", len(changed))
+	for _, gk := range workloads.GroupKinds() {
+		mapping, err := mapper.RESTMapping(gk)
+		switch {
+		case meta.IsNoMatchError(err):
+			logger.V(5).Info("workload kind not served by the cluster", "kind", gk.String())
+			continue
+		case err != nil:
+			return err
+		}
+
+		for _, namespace := range sets.List(namespaces) {
+			resourceClient := client.Resource(mapping.Resource).Namespace(namespace)
+			list, err := resourceClient.List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to list %s in namespace %q: %w", mapping.Resource.Resource, namespace, err)
+			}
+
+			for _, obj := range list.Items {
+				objMeta := resource.ObjectMetadataFromUnstructured(&obj)
+				if deployed.Has(objMeta) || obj.GetDeletionTimestamp() != nil {
+					continue
+				}
+
+				obj.SetGroupVersionKind(mapping.GroupVersionKind)
+				patch, err := extensions.DependentRestartPatch(&obj, changed, workloads, []byte(o.checksumKey))
+				if err != nil {
+					return err
+				}
+				if patch == nil {
+					continue
+				}
+
+				if _, err := resourceClient.Patch(ctx, obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{FieldManager: o.fieldManager}); err != nil {
+					return fmt.Errorf("failed to restart %s: %w", summaryIdentifier(objMeta), err)
+				}
+				fmt.Fprintf(o.writer, "%s restarted for changed dependencies\n", summaryIdentifier(objMeta))
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func testRestartConfigMap(name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name},
+		"data":       map[string]interface{}{"key": "value"},
+	}}
+}
+
+func testRestartDeployment(name, configMapName string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{"mia-platform.eu/dependencies-checksum": "current"},
+				},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{"name": "app", "image": "busybox"}},
+					"volumes": []interface{}{
+						map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": configMapName}},
+					},
+				},
+			},
+		},
+	}}
+}
+
+func TestChangedDependencies(t *testing.T) {
+	t.Parallel()
+
+	metrics := newMetricsRecorder()
+	metrics.recordRequest("ConfigMap/created", 10, 0, http.StatusCreated)
+	metrics.recordRequest("ConfigMap/patched", 10, 0, http.StatusOK)
+	metrics.recordRequest("ConfigMap/unchanged", 10, 0, http.StatusOK)
+	metrics.recordUnchanged("ConfigMap/unchanged")
+	metrics.recordRequest("Deployment.apps/patched", 10, 0, http.StatusOK)
+
+	resources := []*unstructured.Unstructured{
+		testRestartConfigMap("created"),
+		testRestartConfigMap("patched"),
+		testRestartConfigMap("unchanged"),
+		testRestartConfigMap("skipped"),
+		testRestartDeployment("patched", "created"),
+	}
+
+	changed := changedDependencies(resources, metrics, "default")
+	names := make([]string, 0, len(changed))
+	for _, obj := range changed {
+		assert.Equal(t, "default", obj.GetNamespace())
+		names = append(names, obj.GetName())
+	}
+	assert.Equal(t, []string{"created", "patched"}, names)
+	assert.Empty(t, resources[0].GetNamespace(), "the resources must not be modified")
+}
+
+func TestRestartDependentWorkloads(t *testing.T) {
+	t.Parallel()
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "apps", Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+
+	deploymentsGVR := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	tf := jpltesting.NewTestClientFactory().WithNamespace("default")
+	tf.RESTMapper = mapper
+	tf.FakeDynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{deploymentsGVR: "DeploymentList"},
+		testRestartDeployment("consumer", "config"),
+		testRestartDeployment("unrelated", "other"),
+		testRestartDeployment("deployed", "config"),
+	)
+
+	metrics := newMetricsRecorder()
+	metrics.recordRequest("ConfigMap/config", 10, 0, http.StatusOK)
+	resources := []*unstructured.Unstructured{
+		testRestartConfigMap("config"),
+		testRestartDeployment("deployed", "config"),
+	}
+
+	output := new(strings.Builder)
+	options := &Options{clientFactory: tf, fieldManager: "mlp", writer: output}
+	require.NoError(t, options.restartDependentWorkloads(context.TODO(), resources, metrics, "default"))
+	assert.Equal(t, "Deployment.apps/consumer restarted for changed dependencies\n", output.String())
+
+	checksum := func(name string) string {
+		obj, err := tf.FakeDynamicClient.Resource(deploymentsGVR).Namespace("default").Get(context.TODO(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		value, _, err := unstructured.NestedString(obj.Object, "spec", "template", "metadata", "annotations", "mia-platform.eu/dependencies-checksum")
+		require.NoError(t, err)
+		return value
+	}
+	assert.NotEqual(t, "current", checksum("consumer"))
+	assert.Equal(t, "current", checksum("unrelated"))
+	assert.Equal(t, "current", checksum("deployed"))
+}
@@ -0,0 +1,237 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/client/cache"
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/filter"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// progressNameSuffix is appended to the inventory name for naming the ConfigMap containing the progress of
+	// the last failed deploy
+	progressNameSuffix = ".progress"
+
+	progressRunIDKey   = "runID"
+	progressStartedKey = "started"
+	progressAppliedKey = "applied"
+)
+
+// deployProgress contains the resources successfully applied by a deploy, saved when the deploy fails for
+// resuming it later without applying them again
+type deployProgress struct {
+	runID   string
+	started string
+	applied map[string]string
+}
+
+func newDeployProgress(runID, started string) *deployProgress {
+	return &deployProgress{
+		runID:   runID,
+		started: started,
+		applied: make(map[string]string),
+	}
+}
+
+// Collect record the checksum of the resource successfully applied in e
+func (p *deployProgress) Collect(e event.Event) {
+	if e.Type != event.TypeApply || e.ApplyInfo.Status != event.StatusSuccessful {
+		return
+	}
+
+	key, checksum := progressEntry(e.ApplyInfo.Object)
+	p.applied[key] = checksum
+}
+
+// progressEntry return the key and the checksum of the applied content of obj, the Jobs created from a CronJob
+// are identified by the CronJob name because a new random name is generated at every deploy
+func progressEntry(obj *unstructured.Unstructured) (string, string) {
+	objMeta := resource.ObjectMetadataFromUnstructured(obj)
+	if cronJobName, found := obj.GetAnnotations()[extensions.CreatedByCronJobAnnotation]; found {
+		obj = obj.DeepCopy()
+		obj.SetName(cronJobName)
+		objMeta.Name = cronJobName
+	}
+
+	return objMeta.ToString(), extensions.ChecksumFromData(obj.Object)
+}
+
+// resumeFilter skip the resources already applied with the same content by the deploy that is being resumed
+type resumeFilter struct {
+	applied map[string]string
+}
+
+func newResumeFilter(applied map[string]string) filter.Interface {
+	return &resumeFilter{applied: applied}
+}
+
+// Filter implement filter.Interface interface
+func (f *resumeFilter) Filter(obj *unstructured.Unstructured, _ cache.RemoteResourceGetter) (bool, error) {
+	key, checksum := progressEntry(obj)
+	appliedChecksum, found := f.applied[key]
+	return found && appliedChecksum == checksum, nil
+}
+
+// keep it to always check if resumeFilter implement correctly the filter.Interface interface
+var _ filter.Interface = &resumeFilter{}
+
+// progressStore read and write the progress of the last failed deploy of a field manager inside a namespace
+type progressStore struct {
+	clientset kubernetes.Interface
+	name      string
+	namespace string
+}
+
+func newProgressStore(clientset kubernetes.Interface, name, namespace string) *progressStore {
+	return &progressStore{
+		clientset: clientset,
+		name:      name,
+		namespace: namespace,
+	}
+}
+
+// Load return the progress saved for the deploy runID
+func (s *progressStore) Load(ctx context.Context, runID string) (*deployProgress, error) {
+	configMap, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil, fmt.Errorf("no failed deploy to resume found in namespace %q", s.namespace)
+	case err != nil:
+		return nil, fmt.Errorf("failed to read deploy progress: %w", err)
+	}
+
+	if savedRunID := configMap.Data[progressRunIDKey]; savedRunID != runID {
+		return nil, fmt.Errorf("cannot resume deploy %q: the last failed deploy in namespace %q is %q", runID, s.namespace, savedRunID)
+	}
+
+	progress := newDeployProgress(runID, configMap.Data[progressStartedKey])
+	if err := json.Unmarshal([]byte(configMap.Data[progressAppliedKey]), &progress.applied); err != nil {
+		return nil, fmt.Errorf("failed to decode deploy progress: %w", err)
+	}
+
+	return progress, nil
+}
+
+// Save write progress replacing the one of the previous failed deploy
+func (s *progressStore) Save(ctx context.Context, progress *deployProgress) error {
+	applied, err := json.Marshal(progress.applied)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]string{
+		progressRunIDKey:   progress.runID,
+		progressStartedKey: progress.started,
+		progressAppliedKey: string(applied),
+	}
+
+	client := s.clientset.CoreV1().ConfigMaps(s.namespace)
+	// retry also if another deploy has created the ConfigMap in the meantime
+	retriable := func(err error) bool { return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) }
+	err = retry.OnError(retry.DefaultRetry, retriable, func() error {
+		configMap, err := client.Get(ctx, s.name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+				Data:       data,
+			}
+			_, err = client.Create(ctx, configMap, metav1.CreateOptions{})
+			return err
+		case err != nil:
+			return err
+		}
+
+		configMap.Data = data
+		_, err = client.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save deploy progress: %w", err)
+	}
+
+	return nil
+}
+
+// Delete remove the saved progress, if any
+func (s *progressStore) Delete(ctx context.Context) error {
+	err := s.clientset.CoreV1().ConfigMaps(s.namespace).Delete(ctx, s.name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove deploy progress: %w", err)
+	}
+
+	return nil
+}
+
+// progressNameFor return the name of the ConfigMap containing the progress of the failed deploys of manager
+// for release
+func progressNameFor(manager, release string) string {
+	return inventoryNameFor(manager, release) + progressNameSuffix
+}
+
+// deployProgress return the progress of the current deploy, loading the one of the deploy to resume if requested
+func (o *Options) deployProgress(ctx context.Context, namespace string) (*deployProgress, error) {
+	if len(o.resumeRunID) == 0 {
+		return newDeployProgress(string(uuid.NewUUID()), o.clock.Now().Format(time.RFC3339)), nil
+	}
+
+	clientSet, err := o.clientFactory.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+
+	return newProgressStore(clientSet, progressNameFor(o.fieldManager, o.releaseName), namespace).Load(ctx, o.resumeRunID)
+}
+
+// saveProgress save progress if the deploy has failed, so it can be resumed later, or remove the saved one when
+// a resumed deploy has completed successfully
+func (o *Options) saveProgress(ctx context.Context, namespace string, progress *deployProgress, failed bool) error {
+	if o.dryRun || (!failed && len(o.resumeRunID) == 0) {
+		return nil
+	}
+
+	clientSet, err := o.clientFactory.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+
+	store := newProgressStore(clientSet, progressNameFor(o.fieldManager, o.releaseName), namespace)
+	if !failed {
+		return store.Delete(ctx)
+	}
+
+	if err := store.Save(ctx, progress); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(o.writer, "the deploy can be resumed with the flag --%s=%s\n", resumeFlagName, progress.runID)
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResumeFilter(t *testing.T) {
+	t.Parallel()
+
+	configMap := func(value string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "example", "namespace": "default"},
+			"data":       map[string]interface{}{"key": value},
+		}}
+		return obj
+	}
+	job := func(name, image string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "batch/v1",
+			"kind":       "Job",
+			"metadata": map[string]interface{}{
+				"name":        name,
+				"namespace":   "default",
+				"annotations": map[string]interface{}{extensions.CreatedByCronJobAnnotation: "example"},
+			},
+			"spec": map[string]interface{}{"image": image},
+		}}
+		return obj
+	}
+
+	progress := newDeployProgress("run-id", "1970-01-01T00:00:00Z")
+	for _, obj := range []*unstructured.Unstructured{configMap("value"), job("example-abcde", "image:1")} {
+		progress.Collect(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Status: event.StatusSuccessful, Object: obj}})
+	}
+	failed := configMap("value")
+	failed.SetName("failed")
+	progress.Collect(event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Status: event.StatusFailed, Object: failed}})
+	require.Len(t, progress.applied, 2)
+
+	tests := map[string]struct {
+		obj              *unstructured.Unstructured
+		expectedFiltered bool
+	}{
+		"unchanged resource is skipped": {
+			obj:              configMap("value"),
+			expectedFiltered: true,
+		},
+		"changed resource is applied": {
+			obj: configMap("changed"),
+		},
+		"job created from the same cronjob is skipped": {
+			obj:              job("example-fghij", "image:1"),
+			expectedFiltered: true,
+		},
+		"job created from a changed cronjob is applied": {
+			obj: job("example-fghij", "image:2"),
+		},
+		"resource not applied": {
+			obj: failed,
+		},
+	}
+
+	filter := newResumeFilter(progress.applied)
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			filtered, err := filter.Filter(test.obj, nil)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedFiltered, filtered)
+		})
+	}
+}
+
+func TestProgressStore(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-progress-test"
+	name := progressNameFor(fieldManager, "")
+	clientset := fake.NewSimpleClientset()
+	store := newProgressStore(clientset, name, namespace)
+
+	_, err := store.Load(context.TODO(), "first-run")
+	assert.ErrorContains(t, err, `no failed deploy to resume found in namespace "mlp-progress-test"`)
+	require.NoError(t, store.Delete(context.TODO()))
+
+	progress := newDeployProgress("first-run", "1970-01-01T00:00:00Z")
+	progress.applied["_example__ConfigMap"] = "checksum"
+	require.NoError(t, store.Save(context.TODO(), progress))
+
+	loaded, err := store.Load(context.TODO(), "first-run")
+	require.NoError(t, err)
+	assert.Equal(t, progress, loaded)
+
+	progress = newDeployProgress("second-run", "1970-01-02T00:00:00Z")
+	require.NoError(t, store.Save(context.TODO(), progress))
+	_, err = store.Load(context.TODO(), "first-run")
+	assert.ErrorContains(t, err, `cannot resume deploy "first-run": the last failed deploy in namespace "mlp-progress-test" is "second-run"`)
+
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "eu.mia-platform.mlp.progress", configMap.Name)
+	assert.Equal(t, map[string]string{
+		progressRunIDKey:   "second-run",
+		progressStartedKey: "1970-01-02T00:00:00Z",
+		progressAppliedKey: "{}",
+	}, configMap.Data)
+
+	require.NoError(t, store.Delete(context.TODO()))
+	_, err = clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestProgressStoreInvalidData(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-progress-test"
+	name := progressNameFor("custom", "frontend")
+	clientset := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Data: map[string]string{
+			progressRunIDKey:   "run-id",
+			progressAppliedKey: "invalid",
+		},
+	})
+
+	_, err := newProgressStore(clientset, name, namespace).Load(context.TODO(), "run-id")
+	assert.ErrorContains(t, err, "failed to decode deploy progress")
+}
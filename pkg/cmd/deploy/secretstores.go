@@ -0,0 +1,205 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	extsecv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	externalSecretGK     = extsecv1beta1.SchemeGroupVersion.WithKind(extsecv1beta1.ExtSecretKind).GroupKind()
+	secretStoreGK        = extsecv1beta1.SchemeGroupVersion.WithKind(extsecv1beta1.SecretStoreKind).GroupKind()
+	clusterSecretStoreGK = extsecv1beta1.SchemeGroupVersion.WithKind(extsecv1beta1.ClusterSecretStoreKind).GroupKind()
+
+	secretStoresGVR        = extsecv1beta1.SchemeGroupVersion.WithResource("secretstores")
+	clusterSecretStoresGVR = extsecv1beta1.SchemeGroupVersion.WithResource("clustersecretstores")
+)
+
+// storeReference identify a SecretStore or a ClusterSecretStore referenced by an ExternalSecret
+type storeReference struct {
+	kind      string
+	name      string
+	namespace string
+}
+
+// String implement fmt.Stringer interface
+func (r storeReference) String() string {
+	if r.kind == extsecv1beta1.ClusterSecretStoreKind {
+		return fmt.Sprintf("%s %q", r.kind, r.name)
+	}
+	return fmt.Sprintf("%s %q in namespace %q", r.kind, r.name, r.namespace)
+}
+
+// checkSecretStores verify that the stores referenced by the ExternalSecrets in resources exist and are ready,
+// returning an error naming the unavailable stores and the ExternalSecrets that use them
+func (o *Options) checkSecretStores(ctx context.Context, resources []*unstructured.Unstructured) error {
+	logger := logr.FromContextOrDiscard(ctx)
+	if !o.secretStoreCheck {
+		return nil
+	}
+
+	references, err := secretStoreReferences(resources)
+	if err != nil || len(references) == 0 {
+		return err
+	}
+
+	client, err := o.clientFactory.DynamicClient()
+	if err != nil {
+		return err
+	}
+
+	logger.V(3).Info("checking secret stores", "count", len(references))
+	problems := make([]string, 0)
+	for _, reference := range slices.SortedFunc(maps.Keys(references), compareStoreReferences) {
+		problem, err := secretStoreProblem(ctx, client, reference)
+		if err != nil {
+			return err
+		}
+
+		if len(problem) > 0 {
+			problems = append(problems, fmt.Sprintf("%s %s, referenced by ExternalSecret %s", reference, problem, strings.Join(references[reference], ", ")))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	builder := new(strings.Builder)
+	builder.WriteString(fmt.Sprintf("%d secret store(s) referenced by ExternalSecrets are not available:\n", len(problems)))
+	for _, problem := range problems {
+		builder.WriteString(fmt.Sprintf("\t- %s\n", problem))
+	}
+	return errors.New(builder.String())
+}
+
+// secretStoreReferences return the stores referenced by the ExternalSecrets in resources with the names of the
+// ExternalSecrets that use them, the stores that are part of resources are skipped because they will be created
+// by the deploy itself
+func secretStoreReferences(resources []*unstructured.Unstructured) (map[storeReference][]string, error) {
+	deployedStores := make(map[storeReference]bool)
+	for _, obj := range resources {
+		switch obj.GroupVersionKind().GroupKind() {
+		case secretStoreGK, clusterSecretStoreGK:
+			deployedStores[storeReference{kind: obj.GetKind(), name: obj.GetName(), namespace: obj.GetNamespace()}] = true
+		}
+	}
+
+	references := make(map[storeReference][]string)
+	for _, obj := range resources {
+		if obj.GroupVersionKind().GroupKind() != externalSecretGK {
+			continue
+		}
+
+		extsec := new(extsecv1beta1.ExternalSecret)
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, extsec); err != nil {
+			return nil, fmt.Errorf("failed to read ExternalSecret %q: %w", obj.GetName(), err)
+		}
+
+		storeRefs := []*extsecv1beta1.SecretStoreRef{&extsec.Spec.SecretStoreRef}
+		for _, data := range extsec.Spec.Data {
+			if data.SourceRef != nil {
+				storeRefs = append(storeRefs, &data.SourceRef.SecretStoreRef)
+			}
+		}
+		for _, dataFrom := range extsec.Spec.DataFrom {
+			if dataFrom.SourceRef != nil {
+				storeRefs = append(storeRefs, dataFrom.SourceRef.SecretStoreRef)
+			}
+		}
+
+		for _, storeRef := range storeRefs {
+			if storeRef == nil || len(storeRef.Name) == 0 {
+				continue
+			}
+
+			reference := storeReference{
+				kind:      cmp.Or(storeRef.Kind, extsecv1beta1.SecretStoreKind),
+				name:      storeRef.Name,
+				namespace: obj.GetNamespace(),
+			}
+			if reference.kind == extsecv1beta1.ClusterSecretStoreKind {
+				reference.namespace = ""
+			}
+
+			if deployedStores[reference] || slices.Contains(references[reference], obj.GetName()) {
+				continue
+			}
+			references[reference] = append(references[reference], obj.GetName())
+		}
+	}
+
+	return references, nil
+}
+
+// secretStoreProblem return a description of why the store identified by reference cannot be used, or an empty
+// string if it exists and is ready
+func secretStoreProblem(ctx context.Context, client dynamic.Interface, reference storeReference) (string, error) {
+	var resourceClient dynamic.ResourceInterface = client.Resource(clusterSecretStoresGVR)
+	if reference.kind != extsecv1beta1.ClusterSecretStoreKind {
+		resourceClient = client.Resource(secretStoresGVR).Namespace(reference.namespace)
+	}
+
+	store, err := resourceClient.Get(ctx, reference.name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return "does not exist", nil
+	case err != nil:
+		return "", fmt.Errorf("failed to read %s: %w", reference, err)
+	}
+
+	conditions, _, err := unstructured.NestedSlice(store.Object, "status", "conditions")
+	if err != nil {
+		return "", fmt.Errorf("failed to read status of %s: %w", reference, err)
+	}
+
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]interface{})
+		if !ok || conditionMap["type"] != string(extsecv1beta1.SecretStoreReady) {
+			continue
+		}
+
+		if conditionMap["status"] == string(corev1.ConditionTrue) {
+			return "", nil
+		}
+
+		reason, _ := conditionMap["reason"].(string)
+		message, _ := conditionMap["message"].(string)
+		return fmt.Sprintf("is not ready: %s", cmp.Or(message, reason, "unknown reason")), nil
+	}
+
+	return "is not ready: the store has not reported its status yet", nil
+}
+
+// compareStoreReferences sort the store references by kind, namespace and name
+func compareStoreReferences(a, b storeReference) int {
+	return cmp.Or(cmp.Compare(a.kind, b.kind), cmp.Compare(a.namespace, b.namespace), cmp.Compare(a.name, b.name))
+}
@@ -0,0 +1,162 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestCheckSecretStores(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-deploy-test"
+	readyStore := testSecretStore("SecretStore", "ready", namespace, map[string]interface{}{"type": "Ready", "status": "True"})
+	notReadyStore := testSecretStore("SecretStore", "not-ready", namespace, map[string]interface{}{
+		"type":    "Ready",
+		"status":  "False",
+		"reason":  "InvalidProviderConfig",
+		"message": "unable to validate store",
+	})
+	pendingStore := testSecretStore("SecretStore", "pending", namespace)
+	clusterStore := testSecretStore("ClusterSecretStore", "cluster", "", map[string]interface{}{"type": "Ready", "status": "True"})
+
+	tests := map[string]struct {
+		disabled      bool
+		resources     []*unstructured.Unstructured
+		expectedError string
+	}{
+		"check disabled": {
+			disabled:  true,
+			resources: []*unstructured.Unstructured{testExternalSecret(namespace, "secret", "SecretStore", "missing")},
+		},
+		"no external secrets": {
+			resources: []*unstructured.Unstructured{testSecretStore("SecretStore", "missing", namespace)},
+		},
+		"ready stores": {
+			resources: []*unstructured.Unstructured{
+				testExternalSecret(namespace, "secret", "SecretStore", "ready"),
+				testExternalSecret(namespace, "other-secret", "", "ready"),
+				testExternalSecret(namespace, "cluster-secret", "ClusterSecretStore", "cluster"),
+			},
+		},
+		"store deployed together": {
+			resources: []*unstructured.Unstructured{
+				testExternalSecret(namespace, "secret", "SecretStore", "missing"),
+				testSecretStore("SecretStore", "missing", namespace),
+			},
+		},
+		"unavailable stores": {
+			resources: []*unstructured.Unstructured{
+				testExternalSecret(namespace, "secret", "SecretStore", "missing"),
+				testExternalSecret(namespace, "other-secret", "SecretStore", "missing"),
+				testExternalSecret(namespace, "not-ready-secret", "SecretStore", "not-ready"),
+				testExternalSecret(namespace, "pending-secret", "SecretStore", "pending"),
+				testExternalSecret(namespace, "cluster-secret", "ClusterSecretStore", "missing"),
+				testExternalSecret(namespace, "ready-secret", "SecretStore", "ready"),
+			},
+			expectedError: `4 secret store(s) referenced by ExternalSecrets are not available:` + "\n" +
+				"\t" + `- ClusterSecretStore "missing" does not exist, referenced by ExternalSecret cluster-secret` + "\n" +
+				"\t" + `- SecretStore "missing" in namespace "mlp-deploy-test" does not exist, referenced by ExternalSecret secret, other-secret` + "\n" +
+				"\t" + `- SecretStore "not-ready" in namespace "mlp-deploy-test" is not ready: unable to validate store, referenced by ExternalSecret not-ready-secret` + "\n" +
+				"\t" + `- SecretStore "pending" in namespace "mlp-deploy-test" is not ready: the store has not reported its status yet, referenced by ExternalSecret pending-secret` + "\n",
+		},
+		"store referenced in data source": {
+			resources: []*unstructured.Unstructured{
+				func() *unstructured.Unstructured {
+					obj := testExternalSecret(namespace, "secret", "SecretStore", "ready")
+					data := []interface{}{
+						map[string]interface{}{
+							"secretKey": "key",
+							"sourceRef": map[string]interface{}{"storeRef": map[string]interface{}{"name": "missing"}},
+						},
+					}
+					assert.NoError(t, unstructured.SetNestedSlice(obj.Object, data, "spec", "data"))
+					return obj
+				}(),
+			},
+			expectedError: `- SecretStore "missing" in namespace "mlp-deploy-test" does not exist, referenced by ExternalSecret secret`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tf := jpltesting.NewTestClientFactory().WithNamespace(namespace)
+			tf.FakeDynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+				runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{
+					secretStoresGVR:        "SecretStoreList",
+					clusterSecretStoresGVR: "ClusterSecretStoreList",
+				},
+				readyStore, notReadyStore, pendingStore, clusterStore,
+			)
+			options := &Options{
+				secretStoreCheck: !test.disabled,
+				clientFactory:    tf,
+			}
+
+			err := options.checkSecretStores(context.TODO(), test.resources)
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func testSecretStore(kind, name, namespace string, conditions ...interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "external-secrets.io/v1beta1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{},
+	}}
+	if len(namespace) > 0 {
+		obj.SetNamespace(namespace)
+	}
+	if len(conditions) > 0 {
+		obj.Object["status"] = map[string]interface{}{"conditions": conditions}
+	}
+	return obj
+}
+
+func testExternalSecret(namespace, name, storeKind, storeName string) *unstructured.Unstructured {
+	storeRef := map[string]interface{}{"name": storeName}
+	if len(storeKind) > 0 {
+		storeRef["kind"] = storeKind
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "external-secrets.io/v1beta1",
+		"kind":       "ExternalSecret",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		"spec": map[string]interface{}{
+			"secretStoreRef": storeRef,
+			"target":         map[string]interface{}{"name": name},
+		},
+	}}
+}
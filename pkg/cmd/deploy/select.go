@@ -0,0 +1,104 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/mlp/v2/pkg/resourceutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// resourceSelector select a subset of the resources to deploy matching their labels and annotations
+type resourceSelector struct {
+	labels      labels.Selector
+	annotations labels.Selector
+}
+
+// newResourceSelector return a resourceSelector for the label and annotation selectors, if both are empty
+// nil is returned
+func newResourceSelector(labelSelector, annotationSelector string) (*resourceSelector, error) {
+	if len(labelSelector) == 0 && len(annotationSelector) == 0 {
+		return nil, nil
+	}
+
+	selector := &resourceSelector{
+		labels:      labels.Everything(),
+		annotations: labels.Everything(),
+	}
+
+	var err error
+	if len(labelSelector) > 0 {
+		if selector.labels, err = labels.Parse(labelSelector); err != nil {
+			return nil, fmt.Errorf("invalid label selector %q: %w", labelSelector, err)
+		}
+	}
+
+	if len(annotationSelector) > 0 {
+		if selector.annotations, err = labels.Parse(annotationSelector); err != nil {
+			return nil, fmt.Errorf("invalid annotation selector %q: %w", annotationSelector, err)
+		}
+	}
+
+	return selector, nil
+}
+
+// Matches return true if obj labels and annotations match the selector
+func (s *resourceSelector) Matches(obj *unstructured.Unstructured) bool {
+	return s.labels.Matches(labels.Set(obj.GetLabels())) && s.annotations.Matches(labels.Set(obj.GetAnnotations()))
+}
+
+// Filter return the resources matching the selector and their identifiers, returning an error if none of them
+// is selected
+func (s *resourceSelector) Filter(resources []*unstructured.Unstructured) ([]*unstructured.Unstructured, []resource.ObjectMetadata, error) {
+	selected := resourceutil.Filter(resources, s.Matches)
+	identifiers := make([]resource.ObjectMetadata, 0, len(selected))
+	for _, obj := range selected {
+		identifiers = append(identifiers, resourceutil.Identifier(obj))
+	}
+
+	if len(selected) == 0 {
+		return nil, nil, fmt.Errorf("no resources match the selection")
+	}
+
+	return selected, identifiers, nil
+}
+
+// selectResources return the resources matching the selection flags, limiting the inventory to them so the
+// other resources will not be pruned
+func (o *Options) selectResources(ctx context.Context, inventory *Inventory, resources []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	selector, err := newResourceSelector(o.selectLabels, o.selectAnnotations)
+	if err != nil || selector == nil {
+		return resources, err
+	}
+
+	selected, identifiers, err := selector.Filter(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.V(3).Info("resources selected", "count", len(selected), "total", len(resources))
+	if inventory != nil {
+		inventory.SelectObjects(identifiers...)
+	}
+	return selected, nil
+}
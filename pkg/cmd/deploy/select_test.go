@@ -0,0 +1,151 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestResourceSelector(t *testing.T) {
+	t.Parallel()
+
+	api := testSelectObject("api", map[string]string{"app": "api"}, map[string]string{"mia-platform.eu/team": "core"})
+	worker := testSelectObject("worker", map[string]string{"app": "worker"}, map[string]string{"mia-platform.eu/team": "core"})
+	frontend := testSelectObject("frontend", map[string]string{"app": "frontend"}, nil)
+	resources := []*unstructured.Unstructured{api, worker, frontend}
+
+	tests := map[string]struct {
+		labelSelector      string
+		annotationSelector string
+		expectedNames      []string
+		expectedError      string
+	}{
+		"no selectors": {
+			expectedNames: []string{"api", "worker", "frontend"},
+		},
+		"label selector": {
+			labelSelector: "app=api",
+			expectedNames: []string{"api"},
+		},
+		"set based label selector": {
+			labelSelector: "app in (api, frontend)",
+			expectedNames: []string{"api", "frontend"},
+		},
+		"annotation selector": {
+			annotationSelector: "mia-platform.eu/team=core",
+			expectedNames:      []string{"api", "worker"},
+		},
+		"label and annotation selectors": {
+			labelSelector:      "app!=api",
+			annotationSelector: "mia-platform.eu/team=core",
+			expectedNames:      []string{"worker"},
+		},
+		"nothing selected": {
+			labelSelector: "app=missing",
+			expectedError: "no resources match the selection",
+		},
+		"invalid label selector": {
+			labelSelector: "=api",
+			expectedError: `invalid label selector "=api"`,
+		},
+		"invalid annotation selector": {
+			annotationSelector: "team in (",
+			expectedError:      `invalid annotation selector "team in ("`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options := &Options{selectLabels: test.labelSelector, selectAnnotations: test.annotationSelector}
+			selected, err := options.selectResources(context.TODO(), nil, resources)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			names := make([]string, 0, len(selected))
+			for _, obj := range selected {
+				names = append(names, obj.GetName())
+			}
+			assert.Equal(t, test.expectedNames, names)
+		})
+	}
+}
+
+func TestInventorySelection(t *testing.T) {
+	t.Parallel()
+
+	api := resource.ObjectMetadata{Group: "apps", Kind: "Deployment", Name: "api", Namespace: "test"}
+	worker := resource.ObjectMetadata{Group: "apps", Kind: "Deployment", Name: "worker", Namespace: "test"}
+	config := resource.ObjectMetadata{Kind: "ConfigMap", Name: "config", Namespace: "test"}
+	removed := resource.ObjectMetadata{Kind: "Secret", Name: "removed", Namespace: "test"}
+
+	store := &recordingStore{objects: sets.New(api, worker, config)}
+	inventory := &Inventory{delegate: store, trackedObjects: sets.New(removed)}
+	inventory.SelectObjects(api, config)
+
+	loaded, err := inventory.Load(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, sets.New(api, config), loaded)
+
+	// only the selected objects are applied, the others must remain in the inventory
+	inventory.SetObjects(sets.New(testSelectObject("api", nil, nil)))
+	saved := sets.New[resource.ObjectMetadata]()
+	for obj := range store.saved {
+		saved.Insert(resource.ObjectMetadataFromUnstructured(obj))
+	}
+	assert.Equal(t, sets.New(api, worker, removed), saved)
+}
+
+func testSelectObject(name string, labels, annotations map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+	}}
+	obj.SetName(name)
+	obj.SetNamespace("test")
+	obj.SetLabels(labels)
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+// recordingStore is an inventory store that keep in memory the objects set for saving
+type recordingStore struct {
+	objects sets.Set[resource.ObjectMetadata]
+	saved   sets.Set[*unstructured.Unstructured]
+}
+
+func (s *recordingStore) Load(context.Context) (sets.Set[resource.ObjectMetadata], error) {
+	return s.objects.Clone(), nil
+}
+
+func (s *recordingStore) Save(context.Context, bool) error { return nil }
+
+func (s *recordingStore) Delete(context.Context, bool) error { return nil }
+
+func (s *recordingStore) SetObjects(objects sets.Set[*unstructured.Unstructured]) {
+	s.saved = objects
+}
@@ -0,0 +1,165 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+)
+
+const (
+	outcomeApplied = "applied"
+	outcomeSkipped = "skipped"
+	// outcomeDeployOnce is the outcome of the resources deployed only once that already exist
+	outcomeDeployOnce = deployOnceAction
+	outcomePruned     = "pruned"
+	outcomeFailed     = "failed"
+)
+
+// kindSummary contains how many resources of a kind have been created, configured, left unchanged, skipped,
+// skipped because deployed only once, pruned or have failed during the deploy
+type kindSummary struct {
+	Kind       string `json:"kind"`
+	Created    int    `json:"created"`
+	Configured int    `json:"configured"`
+	Unchanged  int    `json:"unchanged"`
+	Skipped    int    `json:"skipped"`
+	DeployOnce int    `json:"deployOnce"`
+	Pruned     int    `json:"pruned"`
+	Failed     int    `json:"failed"`
+}
+
+// add sum the counts of other to s
+func (s *kindSummary) add(other kindSummary) {
+	s.Created += other.Created
+	s.Configured += other.Configured
+	s.Unchanged += other.Unchanged
+	s.Skipped += other.Skipped
+	s.DeployOnce += other.DeployOnce
+	s.Pruned += other.Pruned
+	s.Failed += other.Failed
+}
+
+// outcomeFromEvent return the identifier of the resource of e and the outcome of the operation, or an empty
+// outcome if e doesn't conclude an operation on a resource
+func outcomeFromEvent(e event.Event) (string, string) {
+	switch {
+	case e.Type == event.TypeApply && e.ApplyInfo.Object != nil:
+		identifier := summaryIdentifier(resource.ObjectMetadataFromUnstructured(e.ApplyInfo.Object))
+		switch e.ApplyInfo.Status {
+		case event.StatusSuccessful:
+			return identifier, outcomeApplied
+		case event.StatusSkipped:
+			if isDeployOnceSkip(e) {
+				return identifier, outcomeDeployOnce
+			}
+			return identifier, outcomeSkipped
+		case event.StatusFailed:
+			return identifier, outcomeFailed
+		}
+	case e.Type == event.TypePrune && e.PruneInfo.Object != nil:
+		identifier := summaryIdentifier(resource.ObjectMetadataFromUnstructured(e.PruneInfo.Object))
+		switch e.PruneInfo.Status {
+		case event.StatusSuccessful:
+			return identifier, outcomePruned
+		case event.StatusFailed:
+			return identifier, outcomeFailed
+		}
+	case e.Type == event.TypeStatusUpdate && e.StatusUpdateInfo.Status == event.StatusFailed:
+		return summaryIdentifier(e.StatusUpdateInfo.ObjectMetadata), outcomeFailed
+	}
+
+	return "", ""
+}
+
+// kindSummaries return the summary of outcomes grouped by kind and ordered by name, the operation recorded
+// in metrics is used for distinguish the applied resources that have been created, configured or left unchanged
+func kindSummaries(outcomes map[string]string, metrics []applyMetrics) []kindSummary {
+	operations := make(map[string]string, len(metrics))
+	for _, m := range metrics {
+		operations[m.Resource] = m.Operation
+	}
+
+	summaries := make(map[string]*kindSummary)
+	for identifier, outcome := range outcomes {
+		kind, _, _ := strings.Cut(identifier, "/")
+		summary, found := summaries[kind]
+		if !found {
+			summary = &kindSummary{Kind: kind}
+			summaries[kind] = summary
+		}
+
+		switch outcome {
+		case outcomeSkipped:
+			summary.Skipped++
+		case outcomeDeployOnce:
+			summary.DeployOnce++
+		case outcomePruned:
+			summary.Pruned++
+		case outcomeFailed:
+			summary.Failed++
+		default:
+			switch operations[identifier] {
+			case applyOperationCreate:
+				summary.Created++
+			case applyOperationUnchanged:
+				summary.Unchanged++
+			default:
+				summary.Configured++
+			}
+		}
+	}
+
+	result := make([]kindSummary, 0, len(summaries))
+	for _, kind := range slices.Sorted(maps.Keys(summaries)) {
+		result = append(result, *summaries[kind])
+	}
+	return result
+}
+
+// printDeploySummary write a table with the kinds summaries and their total in writer, followed by the duration
+// of the deploy
+func printDeploySummary(writer io.Writer, summaries []kindSummary, duration time.Duration) error {
+	if len(summaries) > 0 {
+		tabWriter := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+		printRow := func(s kindSummary) {
+			fmt.Fprintf(tabWriter, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", s.Kind, s.Created, s.Configured, s.Unchanged, s.Skipped, s.DeployOnce, s.Pruned, s.Failed)
+		}
+
+		fmt.Fprintln(tabWriter, "KIND\tCREATED\tCONFIGURED\tUNCHANGED\tSKIPPED\tDEPLOY-ONCE\tPRUNED\tFAILED")
+		total := kindSummary{Kind: "TOTAL"}
+		for _, summary := range summaries {
+			total.add(summary)
+			printRow(summary)
+		}
+		printRow(total)
+
+		if err := tabWriter.Flush(); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(writer, "deploy finished in %s\n", duration.Truncate(time.Millisecond))
+	return err
+}
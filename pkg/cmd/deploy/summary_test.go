@@ -0,0 +1,92 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestKindSummaries(t *testing.T) {
+	t.Parallel()
+
+	object := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		return obj
+	}
+	applied := func(obj *unstructured.Unstructured, status event.Status) event.Event {
+		return event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: obj, Status: status, Error: errors.New("error")}}
+	}
+
+	collector := &summaryCollector{}
+	assert.Empty(t, collector.KindSummaries(nil))
+
+	collector.Collect(applied(object("v1", "ConfigMap", "created"), event.StatusSuccessful))
+	collector.Collect(applied(object("v1", "ConfigMap", "unchanged"), event.StatusSuccessful))
+	collector.Collect(applied(object("v1", "ConfigMap", "failed"), event.StatusFailed))
+	collector.Collect(applied(object("v1", "Secret", "once"), event.StatusSkipped))
+	deployOnce := object("v1", "Secret", "deploy-once")
+	deployOnce.SetAnnotations(map[string]string{"mia-platform.eu/deploy": "once"})
+	collector.Collect(applied(deployOnce, event.StatusSkipped))
+	collector.Collect(applied(object("apps/v1", "Deployment", "configured"), event.StatusPending))
+	collector.Collect(applied(object("apps/v1", "Deployment", "configured"), event.StatusSuccessful))
+	collector.Collect(applied(object("apps/v1", "Deployment", "unhealthy"), event.StatusSuccessful))
+	collector.Collect(event.Event{Type: event.TypeStatusUpdate, StatusUpdateInfo: event.StatusUpdateInfo{
+		Status:         event.StatusFailed,
+		ObjectMetadata: resource.ObjectMetadata{Group: "apps", Kind: "Deployment", Name: "unhealthy"},
+	}})
+	collector.Collect(applied(object("apps/v1", "Deployment", "unhealthy"), event.StatusSuccessful))
+	collector.Collect(event.Event{Type: event.TypePrune, PruneInfo: event.PruneInfo{Object: object("v1", "Secret", "old"), Status: event.StatusSuccessful}})
+	collector.Collect(event.Event{Type: event.TypeError, ErrorInfo: event.ErrorInfo{Error: errors.New("error")}})
+
+	summaries := collector.KindSummaries([]applyMetrics{
+		{Resource: "ConfigMap/created", Operation: applyOperationCreate},
+		{Resource: "ConfigMap/unchanged", Operation: applyOperationUnchanged},
+		{Resource: "ConfigMap/failed", Operation: applyOperationFailed},
+		{Resource: "Deployment.apps/configured", Operation: applyOperationPatch},
+		{Resource: "Secret/once", Operation: applyOperationSkip},
+	})
+	assert.Equal(t, []kindSummary{
+		{Kind: "ConfigMap", Created: 1, Unchanged: 1, Failed: 1},
+		{Kind: "Deployment.apps", Configured: 1, Failed: 1},
+		{Kind: "Secret", Skipped: 1, DeployOnce: 1, Pruned: 1},
+	}, summaries)
+
+	output := new(strings.Builder)
+	require.NoError(t, printDeploySummary(output, summaries, 12345*time.Millisecond+500*time.Microsecond))
+	assert.Equal(t, `KIND             CREATED  CONFIGURED  UNCHANGED  SKIPPED  DEPLOY-ONCE  PRUNED  FAILED
+ConfigMap        1        0           1          0        0            0       1
+Deployment.apps  0        1           0          0        0            0       1
+Secret           0        0           0          1        1            1       0
+TOTAL            1        1           1          1        1            1       2
+deploy finished in 12.345s
+`, output.String())
+
+	output.Reset()
+	require.NoError(t, printDeploySummary(output, nil, 0))
+	assert.Equal(t, "deploy finished in 0s\n", output.String())
+}
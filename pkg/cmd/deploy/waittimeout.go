@@ -0,0 +1,226 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/poller"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	waitTimeoutAnnotation = "mia-platform.eu/timeout"
+)
+
+// validateWaitTimeouts return an error listing the resources with a timeout annotation that is not a valid duration,
+// or with an await completion annotation that is neither a boolean nor a valid duration
+func validateWaitTimeouts(resources []*unstructured.Unstructured) error {
+	invalidTimeouts := make([]string, 0)
+	invalidAwaitCompletions := make([]string, 0)
+	for _, res := range resources {
+		identifier := summaryIdentifier(resource.ObjectMetadataFromUnstructured(res))
+		annotations := res.GetAnnotations()
+		if value, found := annotations[waitTimeoutAnnotation]; found {
+			if timeout, err := time.ParseDuration(value); err != nil || timeout < 0 {
+				invalidTimeouts = append(invalidTimeouts, fmt.Sprintf("\t- %s has timeout %q", identifier, value))
+			}
+		}
+
+		if value, found := annotations[extensions.AwaitCompletionAnnotation]; found {
+			_, boolErr := strconv.ParseBool(value)
+			if timeout, err := time.ParseDuration(value); boolErr != nil && (err != nil || timeout < 0) {
+				invalidAwaitCompletions = append(invalidAwaitCompletions, fmt.Sprintf("\t- %s has value %q", identifier, value))
+			}
+		}
+	}
+
+	errs := make([]error, 0, 2)
+	if len(invalidTimeouts) > 0 {
+		errs = append(errs, fmt.Errorf("invalid %s annotation, the value must be a positive duration like 30m:\n%s", waitTimeoutAnnotation, strings.Join(invalidTimeouts, "\n")))
+	}
+	if len(invalidAwaitCompletions) > 0 {
+		errs = append(errs, fmt.Errorf("invalid %s annotation, the value must be true, false or a positive duration like 30m:\n%s", extensions.AwaitCompletionAnnotation, strings.Join(invalidAwaitCompletions, "\n")))
+	}
+	return errors.Join(errs...)
+}
+
+// waitTimeout return the maximum time to wait for obj to become ready, read from its timeout annotation, from the
+// duration set in its await completion annotation, or defaultTimeout if none is set; zero means no limit
+func waitTimeout(obj *unstructured.Unstructured, defaultTimeout time.Duration) time.Duration {
+	value, found := obj.GetAnnotations()[waitTimeoutAnnotation]
+	if !found {
+		if timeout, found := extensions.AwaitCompletionTimeout(obj); found {
+			return timeout
+		}
+		return defaultTimeout
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return defaultTimeout
+	}
+	return timeout
+}
+
+// timeoutPoller wrap a poller for failing the resources that are not ready within their wait timeout, the wait ends
+// when all the resources are ready or have exceeded their timeout
+type timeoutPoller struct {
+	poller         poller.StatusPoller
+	defaultTimeout time.Duration
+}
+
+func newTimeoutPoller(statusPoller poller.StatusPoller, defaultTimeout time.Duration) poller.StatusPoller {
+	return &timeoutPoller{poller: statusPoller, defaultTimeout: defaultTimeout}
+}
+
+// Start implement the poller.StatusPoller interface
+func (p *timeoutPoller) Start(ctx context.Context, objects []*unstructured.Unstructured) <-chan event.Event {
+	timeouts := make(map[resource.ObjectMetadata]time.Duration)
+	pending := sets.New[resource.ObjectMetadata]()
+	for _, obj := range objects {
+		objMeta := resource.ObjectMetadataFromUnstructured(obj)
+		pending.Insert(objMeta)
+		if timeout := waitTimeout(obj, p.defaultTimeout); timeout > 0 {
+			timeouts[objMeta] = timeout
+		}
+	}
+
+	if len(timeouts) == 0 {
+		return p.poller.Start(ctx, objects)
+	}
+
+	pollerCtx, cancel := context.WithCancel(ctx)
+	pollerCh := p.poller.Start(pollerCtx, objects)
+	expiredCh := make(chan resource.ObjectMetadata)
+	timers := make([]*time.Timer, 0, len(timeouts))
+	for objMeta, timeout := range timeouts {
+		timers = append(timers, time.AfterFunc(timeout, func() {
+			select {
+			case expiredCh <- objMeta:
+			case <-pollerCtx.Done():
+			}
+		}))
+	}
+
+	eventCh := make(chan event.Event)
+	go func() {
+		defer close(eventCh)
+		defer cancel()
+		defer func() {
+			for _, timer := range timers {
+				timer.Stop()
+			}
+		}()
+
+		send := func(e event.Event) bool {
+			select {
+			case eventCh <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		lastMessages := make(map[resource.ObjectMetadata]string)
+		for {
+			select {
+			case e, open := <-pollerCh:
+				if !open {
+					return
+				}
+
+				if e.Type == event.TypeStatusUpdate {
+					objMeta := e.StatusUpdateInfo.ObjectMetadata
+					if !pending.Has(objMeta) {
+						continue
+					}
+					lastMessages[objMeta] = e.StatusUpdateInfo.Message
+					if e.StatusUpdateInfo.Status == event.StatusSuccessful {
+						pending.Delete(objMeta)
+					}
+				}
+
+				if !send(e) {
+					return
+				}
+			case objMeta := <-expiredCh:
+				if !pending.Has(objMeta) {
+					continue
+				}
+
+				pending.Delete(objMeta)
+				if !send(timeoutEvent(objMeta, timeouts[objMeta], lastMessages[objMeta])) || len(pending) == 0 {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return eventCh
+}
+
+// timeoutEvent return the status event failing the resource objMeta that is not ready after timeout
+func timeoutEvent(objMeta resource.ObjectMetadata, timeout time.Duration, lastMessage string) event.Event {
+	message := fmt.Sprintf("timed out in the wait phase after %s", timeout)
+	if len(lastMessage) > 0 {
+		message = fmt.Sprintf("%s, last status: %s", message, lastMessage)
+	}
+
+	return event.Event{
+		Type: event.TypeStatusUpdate,
+		StatusUpdateInfo: event.StatusUpdateInfo{
+			Status:         event.StatusFailed,
+			Message:        message,
+			ObjectMetadata: objMeta,
+		},
+	}
+}
+
+// eventError return the error reported by e, marking the apply failures caused by a timeout so they can be told
+// apart from the resources that are not ready within their wait timeout
+func eventError(e event.Event) error {
+	if e.Type == event.TypeApply && isTimeoutError(e.ApplyInfo.Error) {
+		return fmt.Errorf("%s (timed out in the apply phase)", e)
+	}
+	return errors.New(e.String())
+}
+
+// isTimeoutError return true if err is caused by a request that has not completed in time
+func isTimeoutError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsServerTimeout(err) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
@@ -0,0 +1,263 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/event"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newTimeoutResource(name, timeout string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("batch/v1")
+	obj.SetKind("Job")
+	obj.SetName(name)
+	obj.SetNamespace("default")
+	if len(timeout) > 0 {
+		obj.SetAnnotations(map[string]string{waitTimeoutAnnotation: timeout})
+	}
+	return obj
+}
+
+func newAwaitCompletionResource(name, value string) *unstructured.Unstructured {
+	obj := newTimeoutResource(name, "")
+	obj.SetAnnotations(map[string]string{extensions.AwaitCompletionAnnotation: value})
+	return obj
+}
+
+func TestValidateWaitTimeouts(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		resources     []*unstructured.Unstructured
+		expectedError string
+	}{
+		"no annotations": {
+			resources: []*unstructured.Unstructured{newTimeoutResource("example", "")},
+		},
+		"valid timeouts": {
+			resources: []*unstructured.Unstructured{
+				newTimeoutResource("migration", "30m"),
+				newTimeoutResource("unlimited", "0s"),
+			},
+		},
+		"invalid timeouts": {
+			resources: []*unstructured.Unstructured{
+				newTimeoutResource("migration", "30m"),
+				newTimeoutResource("words", "thirty minutes"),
+				newTimeoutResource("negative", "-1m"),
+			},
+			expectedError: `invalid mia-platform.eu/timeout annotation, the value must be a positive duration like 30m:
+	- Job.batch/words has timeout "thirty minutes"
+	- Job.batch/negative has timeout "-1m"`,
+		},
+		"await completion values": {
+			resources: []*unstructured.Unstructured{
+				newAwaitCompletionResource("enabled", "true"),
+				newAwaitCompletionResource("disabled", "false"),
+				newAwaitCompletionResource("legacy", "10m"),
+			},
+		},
+		"invalid await completion values": {
+			resources: []*unstructured.Unstructured{
+				newTimeoutResource("words", "thirty minutes"),
+				newAwaitCompletionResource("legacy", "10m"),
+				newAwaitCompletionResource("words", "yes please"),
+				newAwaitCompletionResource("negative", "-1m"),
+			},
+			expectedError: `invalid mia-platform.eu/timeout annotation, the value must be a positive duration like 30m:
+	- Job.batch/words has timeout "thirty minutes"
+invalid mia-platform.eu/await-completion annotation, the value must be true, false or a positive duration like 30m:
+	- Job.batch/words has value "yes please"
+	- Job.batch/negative has value "-1m"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := validateWaitTimeouts(test.resources)
+			if len(test.expectedError) > 0 {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 5*time.Minute, waitTimeout(newTimeoutResource("default", ""), 5*time.Minute))
+	assert.Equal(t, 30*time.Minute, waitTimeout(newTimeoutResource("override", "30m"), 5*time.Minute))
+	assert.Equal(t, time.Duration(0), waitTimeout(newTimeoutResource("unlimited", "0"), 5*time.Minute))
+	assert.Equal(t, 5*time.Minute, waitTimeout(newTimeoutResource("invalid", "invalid"), 5*time.Minute))
+	assert.Equal(t, 10*time.Minute, waitTimeout(newAwaitCompletionResource("legacy", "10m"), 5*time.Minute))
+	assert.Equal(t, 5*time.Minute, waitTimeout(newAwaitCompletionResource("enabled", "true"), 5*time.Minute))
+
+	both := newAwaitCompletionResource("both", "10m")
+	both.SetAnnotations(map[string]string{extensions.AwaitCompletionAnnotation: "10m", waitTimeoutAnnotation: "30m"})
+	assert.Equal(t, 30*time.Minute, waitTimeout(both, 5*time.Minute))
+}
+
+// scriptedPoller send the status events in its script for the objects to watch and then wait until the context is
+// cancelled, like a poller watching resources that never become ready
+type scriptedPoller struct {
+	script map[string][]event.Status
+}
+
+func (p *scriptedPoller) Start(ctx context.Context, objs []*unstructured.Unstructured) <-chan event.Event {
+	eventCh := make(chan event.Event)
+	go func() {
+		defer close(eventCh)
+		for _, obj := range objs {
+			for _, status := range p.script[obj.GetName()] {
+				e := event.Event{
+					Type: event.TypeStatusUpdate,
+					StatusUpdateInfo: event.StatusUpdateInfo{
+						Status:         status,
+						Message:        fmt.Sprintf("%s is %s", obj.GetName(), status),
+						ObjectMetadata: resource.ObjectMetadataFromUnstructured(obj),
+					},
+				}
+				select {
+				case eventCh <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		<-ctx.Done()
+	}()
+	return eventCh
+}
+
+func TestTimeoutPoller(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		objects         []*unstructured.Unstructured
+		defaultTimeout  time.Duration
+		script          map[string][]event.Status
+		expectedEvents  []string
+		expectedFailure bool
+		cancelAfter     int
+	}{
+		"resource exceeding its annotation timeout": {
+			objects: []*unstructured.Unstructured{
+				newTimeoutResource("ready", ""),
+				newTimeoutResource("migration", "20ms"),
+			},
+			script: map[string][]event.Status{
+				"ready":     {event.StatusSuccessful},
+				"migration": {event.StatusPending},
+			},
+			expectedEvents: []string{
+				"Job.batch ready: ready is Successful",
+				"Job.batch migration: migration is Pending",
+				"Job.batch migration: timed out in the wait phase after 20ms, last status: migration is Pending",
+			},
+		},
+		"annotation disabling the default timeout": {
+			objects: []*unstructured.Unstructured{
+				newTimeoutResource("unlimited", "0"),
+				newTimeoutResource("limited", ""),
+			},
+			defaultTimeout: 20 * time.Millisecond,
+			script:         map[string][]event.Status{},
+			expectedEvents: []string{
+				"Job.batch limited: timed out in the wait phase after 20ms",
+			},
+			cancelAfter: 1,
+		},
+		"ready resources are not failed": {
+			objects: []*unstructured.Unstructured{
+				newTimeoutResource("ready", "20ms"),
+				newTimeoutResource("migration", "40ms"),
+			},
+			script: map[string][]event.Status{
+				"ready":     {event.StatusPending, event.StatusSuccessful},
+				"migration": {event.StatusPending},
+			},
+			expectedEvents: []string{
+				"Job.batch ready: ready is Pending",
+				"Job.batch ready: ready is Successful",
+				"Job.batch migration: migration is Pending",
+				"Job.batch migration: timed out in the wait phase after 40ms, last status: migration is Pending",
+			},
+		},
+		"no timeouts": {
+			objects: []*unstructured.Unstructured{newTimeoutResource("ready", "")},
+			script: map[string][]event.Status{
+				"ready": {event.StatusSuccessful},
+			},
+			expectedEvents: []string{"Job.batch ready: ready is Successful"},
+			cancelAfter:    1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+			defer cancel()
+
+			statusPoller := newTimeoutPoller(&scriptedPoller{script: test.script}, test.defaultTimeout)
+			events := make([]string, 0)
+			for e := range statusPoller.Start(ctx, test.objects) {
+				events = append(events, e.String())
+				if test.cancelAfter > 0 && len(events) == test.cancelAfter {
+					cancel()
+				}
+			}
+
+			assert.NotErrorIs(t, ctx.Err(), context.DeadlineExceeded, "the poller has not stopped before the test timeout")
+			assert.Equal(t, test.expectedEvents, events)
+		})
+	}
+}
+
+func TestEventError(t *testing.T) {
+	t.Parallel()
+
+	obj := newTimeoutResource("migration", "")
+	applyEvent := func(err error) event.Event {
+		return event.Event{Type: event.TypeApply, ApplyInfo: event.ApplyInfo{Object: obj, Status: event.StatusFailed, Error: err}}
+	}
+
+	assert.EqualError(t, eventError(applyEvent(errors.New("forbidden"))), "Job.batch migration: failed to apply: forbidden")
+	assert.EqualError(t, eventError(applyEvent(context.DeadlineExceeded)), "Job.batch migration: failed to apply: context deadline exceeded (timed out in the apply phase)")
+
+	serverTimeout := apierrors.NewServerTimeout(schema.GroupResource{Group: "batch", Resource: "jobs"}, "patch", 1)
+	assert.ErrorContains(t, eventError(applyEvent(serverTimeout)), "(timed out in the apply phase)")
+
+	statusEvent := timeoutEvent(resource.ObjectMetadataFromUnstructured(obj), time.Minute, "")
+	assert.True(t, statusEvent.IsErrorEvent())
+	assert.EqualError(t, eventError(statusEvent), "Job.batch migration: timed out in the wait phase after 1m0s")
+}
@@ -0,0 +1,482 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/util"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/deploy"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/interpolate"
+	"github.com/mia-platform/mlp/v2/pkg/ignore"
+	"github.com/mia-platform/mlp/v2/pkg/resourceutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	doctorCmdUsage = "doctor"
+	doctorCmdShort = "Check that the environment is ready for the deploy"
+	doctorCmdLong  = `Check that the environment is complete before running the real deploy,
+	printing a checklist with the result of every check:
+
+	- variables: every env variable referenced in the files is set, looking for it
+	  also with the prefixes passed with --env-prefix
+	- cluster: the kubeconfig and its context can reach the cluster
+	- namespace: the target namespace exists, or it can be created
+	- inventory: the inventory of the deployed resources can be read
+	- apis: the kinds of the resources in the files, like the ones defined by custom
+	  resource definitions, are served by the cluster
+
+	The command fails if any check is not passed.
+	`
+	doctorCmdExamples = `# check the environment before deploying the files in the out folder
+	mlp env doctor --filename out --env-prefix DEV_
+
+	# check only the connection to the cluster of the current context
+	mlp env doctor
+	`
+
+	inputPathsFlagName  = "filename"
+	inputPathsShortName = "f"
+	inputPathsFlagUsage = "file or folder paths containing the files that will be interpolated and deployed"
+
+	prefixesFlagName  = "env-prefix"
+	prefixesFlagShort = "e"
+	prefixesFlagUsage = "prefixes to add when looking for ENV variables"
+
+	fieldManagerFlagName  = "field-manager"
+	fieldManagerEnvName   = "MLP_FIELD_MANAGER"
+	fieldManagerFlagUsage = "the name of the manager used by the deploy, for finding its inventory, default to the " + fieldManagerEnvName + " env or 'mlp'"
+
+	releaseNameFlagName  = "release-name"
+	releaseNameFlagUsage = "the name of the release used by the deploy, for finding its inventory"
+
+	inventoryBackendFlagName     = "inventory-backend"
+	inventoryBackendDefaultValue = deploy.InventoryBackendConfigMap
+	inventoryBackendFlagUsage    = "where the inventory of the deployed resources is saved, one of: configmap, crd"
+
+	checkVariables = "variables"
+	checkCluster   = "cluster"
+	checkNamespace = "namespace"
+	checkInventory = "inventory"
+	checkAPIs      = "apis"
+
+	resultPass = "pass"
+	resultFail = "fail"
+	resultSkip = "skip"
+)
+
+var (
+	yamlExtensions = []string{".yaml", ".yml"}
+
+	// localGroups contains the groups of the files consumed by mlp and kustomize that are never sent to the cluster
+	localGroups = []string{"kustomize.config.k8s.io", "config.kubernetes.io", "mlp.mia-platform.eu"}
+)
+
+// DoctorFlags contains all the flags for the `env doctor` command. They will be converted to DoctorOptions
+// that contains all runtime options for the command.
+type DoctorFlags struct {
+	ConfigFlags      *genericclioptions.ConfigFlags
+	inputPaths       []string
+	prefixes         []string
+	fieldManager     string
+	releaseName      string
+	inventoryBackend string
+}
+
+// DoctorOptions have the data required to perform the env doctor operation
+type DoctorOptions struct {
+	inputPaths       []string
+	prefixes         []string
+	fieldManager     string
+	releaseName      string
+	inventoryBackend string
+
+	clientFactory util.ClientFactory
+	fSys          filesys.FileSystem
+	writer        io.Writer
+}
+
+// checkResult contains the outcome of a single check
+type checkResult struct {
+	name    string
+	result  string
+	details string
+}
+
+// newDoctorCommand return the command for checking the completeness of the pipeline environment
+func newDoctorCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	flags := &DoctorFlags{
+		ConfigFlags: configFlags,
+	}
+
+	cmd := &cobra.Command{
+		Use:     doctorCmdUsage,
+		Short:   heredoc.Doc(doctorCmdShort),
+		Long:    heredoc.Doc(doctorCmdLong),
+		Example: heredoc.Doc(doctorCmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(cmd.OutOrStdout(), filesys.MakeFsOnDisk())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	if err := cmd.RegisterFlagCompletionFunc(inventoryBackendFlagName, deploy.InventoryBackendFlagCompletionfunc); err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+// AddFlags set the connection between DoctorFlags property to command line flags
+func (f *DoctorFlags) AddFlags(flags *pflag.FlagSet) {
+	if f.ConfigFlags != nil {
+		f.ConfigFlags.AddFlags(flags)
+	}
+
+	flags.StringSliceVarP(&f.inputPaths, inputPathsFlagName, inputPathsShortName, nil, inputPathsFlagUsage)
+	flags.StringSliceVarP(&f.prefixes, prefixesFlagName, prefixesFlagShort, nil, prefixesFlagUsage)
+	flags.StringVar(&f.fieldManager, fieldManagerFlagName, os.Getenv(fieldManagerEnvName), fieldManagerFlagUsage)
+	flags.StringVar(&f.releaseName, releaseNameFlagName, "", releaseNameFlagUsage)
+	flags.StringVar(&f.inventoryBackend, inventoryBackendFlagName, inventoryBackendDefaultValue, inventoryBackendFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *DoctorFlags) ToOptions(writer io.Writer, fSys filesys.FileSystem) (*DoctorOptions, error) {
+	if f.ConfigFlags == nil {
+		return nil, fmt.Errorf("config flags are required")
+	}
+
+	return &DoctorOptions{
+		inputPaths:       f.inputPaths,
+		prefixes:         f.prefixes,
+		fieldManager:     f.fieldManager,
+		releaseName:      f.releaseName,
+		inventoryBackend: f.inventoryBackend,
+
+		clientFactory: util.NewFactory(f.ConfigFlags),
+		fSys:          fSys,
+		writer:        writer,
+	}, nil
+}
+
+// Validate check the options for errors
+func (o *DoctorOptions) Validate() error {
+	if !slices.Contains(deploy.ValidInventoryBackends, o.inventoryBackend) {
+		return fmt.Errorf("invalid inventory backend value: %q", o.inventoryBackend)
+	}
+
+	for _, path := range o.inputPaths {
+		if !o.fSys.Exists(path) {
+			return fmt.Errorf("no such file or directory: %s", path)
+		}
+	}
+
+	return nil
+}
+
+// Run execute the env doctor command
+func (o *DoctorOptions) Run(ctx context.Context) error {
+	files, err := o.readFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	results := make([]checkResult, 0)
+	results = append(results, o.checkVariables(files))
+
+	clusterResult, clientSet := o.checkCluster(ctx)
+	results = append(results, clusterResult)
+	if clientSet == nil {
+		for _, name := range []string{checkNamespace, checkInventory, checkAPIs} {
+			results = append(results, checkResult{name: name, result: resultSkip, details: "the cluster is not reachable"})
+		}
+	} else {
+		namespaceResult, namespace := o.checkNamespace(ctx, clientSet)
+		results = append(results, namespaceResult, o.checkInventory(ctx, namespace), o.checkAPIs(files))
+	}
+
+	if err := printResults(o.writer, results); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.result == resultFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d environment check(s) failed", failed)
+	}
+	return nil
+}
+
+// readFiles return the content of the yaml files found in the input paths, keyed by their path
+func (o *DoctorOptions) readFiles(ctx context.Context) (map[string][]byte, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	files := make(map[string][]byte)
+	for _, inputPath := range o.inputPaths {
+		err := ignore.Walk(o.fSys, inputPath, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !slices.Contains(yamlExtensions, filepath.Ext(path)) {
+				return nil
+			}
+
+			logger.V(10).Info("reading file", "path", path)
+			data, err := o.fSys.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files[path] = data
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fail to read from path %q: %w", inputPath, err)
+		}
+	}
+
+	return files, nil
+}
+
+// checkVariables verify that every env variable referenced in files can be resolved
+func (o *DoctorOptions) checkVariables(files map[string][]byte) checkResult {
+	result := checkResult{name: checkVariables}
+	if len(files) == 0 {
+		result.result, result.details = resultSkip, "no files to check"
+		return result
+	}
+
+	missing := sets.New[string]()
+	missingFiles := 0
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		_, names, err := interpolate.InterpolateKeepingMissing(files[path], o.prefixes)
+		if err != nil {
+			result.result, result.details = resultFail, fmt.Sprintf("%s: %s", path, err)
+			return result
+		}
+
+		if len(names) > 0 {
+			missingFiles++
+			missing.Insert(names...)
+		}
+	}
+
+	if missing.Len() > 0 {
+		result.result = resultFail
+		result.details = fmt.Sprintf("%s not set, referenced in %d file(s)", strings.Join(sets.List(missing), ", "), missingFiles)
+		return result
+	}
+
+	result.result, result.details = resultPass, fmt.Sprintf("all the variables referenced in %d file(s) are set", len(files))
+	return result
+}
+
+// checkCluster verify that the cluster of the current context can be reached, returning the client to use for the
+// other checks if it can
+func (o *DoctorOptions) checkCluster(ctx context.Context) (checkResult, kubernetes.Interface) {
+	result := checkResult{name: checkCluster, result: resultFail}
+
+	config, err := o.clientFactory.ToRESTConfig()
+	if err != nil {
+		result.details = fmt.Sprintf("invalid kubeconfig: %s", err)
+		return result, nil
+	}
+
+	clientSet, err := o.clientFactory.KubernetesClientSet()
+	if err != nil {
+		result.details = err.Error()
+		return result, nil
+	}
+
+	data, err := clientSet.CoreV1().RESTClient().Get().AbsPath("/version").DoRaw(ctx)
+	if err != nil {
+		result.details = fmt.Sprintf("%s not reachable: %s", config.Host, err)
+		return result, nil
+	}
+
+	info := version.Info{}
+	if err := json.Unmarshal(data, &info); err != nil {
+		result.details = fmt.Sprintf("invalid version returned by %s: %s", config.Host, err)
+		return result, nil
+	}
+
+	result.result, result.details = resultPass, fmt.Sprintf("%s reachable, version %s", config.Host, info.GitVersion)
+	return result, clientSet
+}
+
+// checkNamespace verify that the target namespace exists, or that it can be created if it is missing
+func (o *DoctorOptions) checkNamespace(ctx context.Context, clientSet kubernetes.Interface) (checkResult, string) {
+	result := checkResult{name: checkNamespace, result: resultFail}
+
+	namespace, _, err := o.clientFactory.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		result.details = err.Error()
+		return result, ""
+	}
+
+	ns, err := clientSet.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		newNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		if _, err := clientSet.CoreV1().Namespaces().Create(ctx, newNamespace, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}); err != nil {
+			result.details = fmt.Sprintf("namespace %q not found and cannot be created: %s", namespace, err)
+			return result, namespace
+		}
+		result.result, result.details = resultPass, fmt.Sprintf("namespace %q not found, it can be created", namespace)
+	case err != nil:
+		result.details = fmt.Sprintf("failed to read namespace %q: %s", namespace, err)
+	case ns.Status.Phase == corev1.NamespaceTerminating:
+		result.details = fmt.Sprintf("namespace %q is terminating", namespace)
+	default:
+		result.result, result.details = resultPass, fmt.Sprintf("namespace %q exists", namespace)
+	}
+
+	return result, namespace
+}
+
+// checkInventory verify that the inventory used by the deploy in namespace can be read
+func (o *DoctorOptions) checkInventory(ctx context.Context, namespace string) checkResult {
+	result := checkResult{name: checkInventory, result: resultFail}
+	if len(namespace) == 0 {
+		result.result, result.details = resultSkip, "the target namespace is unknown"
+		return result
+	}
+
+	manager := cmp.Or(o.fieldManager, "mlp")
+	name := deploy.InventoryName(manager, o.releaseName)
+	inventory, err := deploy.NewInventory(o.clientFactory, name, namespace, manager, o.inventoryBackend)
+	if err != nil {
+		result.details = err.Error()
+		return result
+	}
+
+	objects, err := inventory.Load(ctx)
+	if err != nil {
+		result.details = fmt.Sprintf("failed to read inventory %q: %s", name, err)
+		return result
+	}
+
+	result.result, result.details = resultPass, fmt.Sprintf("inventory %q readable with %d resource(s)", name, objects.Len())
+	return result
+}
+
+// checkAPIs verify that the kinds of the resources found in files are served by the cluster
+func (o *DoctorOptions) checkAPIs(files map[string][]byte) checkResult {
+	result := checkResult{name: checkAPIs, result: resultFail}
+	if len(files) == 0 {
+		result.result, result.details = resultSkip, "no files to check"
+		return result
+	}
+
+	mapper, err := o.clientFactory.ToRESTMapper()
+	if err != nil {
+		result.details = err.Error()
+		return result
+	}
+
+	gvks := sets.New[schema.GroupVersionKind]()
+	for _, data := range files {
+		// the missing variables are kept, the objects that cannot be decoded are already reported by that check
+		interpolated, _, err := interpolate.InterpolateKeepingMissing(data, o.prefixes)
+		if err != nil {
+			continue
+		}
+
+		objects, err := resourceutil.Decode(bytes.NewReader(interpolated))
+		if err != nil {
+			continue
+		}
+		for _, obj := range objects {
+			if isClusterResource(obj) {
+				gvks.Insert(obj.GroupVersionKind())
+			}
+		}
+	}
+
+	missing := make([]string, 0)
+	for gvk := range gvks {
+		_, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		switch {
+		case meta.IsNoMatchError(err):
+			missing = append(missing, fmt.Sprintf("%s %s", gvk.Kind, gvk.GroupVersion()))
+		case err != nil:
+			result.details = err.Error()
+			return result
+		}
+	}
+
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		result.details = fmt.Sprintf("%s not served by the cluster", strings.Join(missing, ", "))
+		return result
+	}
+
+	result.result, result.details = resultPass, fmt.Sprintf("%d kind(s) served by the cluster", gvks.Len())
+	return result
+}
+
+// isClusterResource return true if obj is a resource that will be sent to the cluster
+func isClusterResource(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return len(gvk.Kind) > 0 && len(gvk.Version) > 0 && !slices.Contains(localGroups, gvk.Group)
+}
+
+// printResults write a table with the result of every check in writer
+func printResults(writer io.Writer, results []checkResult) error {
+	tabWriter := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, "CHECK\tRESULT\tDETAILS")
+	for _, result := range results {
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\n", result.name, result.result, result.details)
+	}
+
+	return tabWriter.Flush()
+}
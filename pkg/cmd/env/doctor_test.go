@@ -0,0 +1,256 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	restfake "k8s.io/client-go/rest/fake"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	doctorNamespace  = "mlp-doctor-test"
+	doctorDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: example
+spec:
+  template:
+    spec:
+      containers:
+      - name: example
+        image: "{{MLP_DOCTOR_IMAGE}}"
+`
+	doctorKustomization = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- deployment.yaml
+`
+	doctorExternalSecret = `apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: example
+`
+)
+
+func TestDoctorOptions(t *testing.T) {
+	t.Parallel()
+
+	writer := new(bytes.Buffer)
+	fSys := filesys.MakeFsInMemory()
+	flags := &DoctorFlags{}
+	_, err := flags.ToOptions(writer, fSys)
+	assert.ErrorContains(t, err, "config flags are required")
+
+	flags = &DoctorFlags{
+		ConfigFlags:      genericclioptions.NewConfigFlags(false),
+		inputPaths:       []string{"out"},
+		prefixes:         []string{"DEV_"},
+		releaseName:      "api",
+		inventoryBackend: inventoryBackendDefaultValue,
+	}
+	o, err := flags.ToOptions(writer, fSys)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"out"}, o.inputPaths)
+	assert.Equal(t, []string{"DEV_"}, o.prefixes)
+	assert.Equal(t, "api", o.releaseName)
+	assert.ErrorContains(t, o.Validate(), "no such file or directory: out")
+
+	require.NoError(t, fSys.MkdirAll("out"))
+	assert.NoError(t, o.Validate())
+
+	o.inventoryBackend = "invalid"
+	assert.ErrorContains(t, o.Validate(), `invalid inventory backend value: "invalid"`)
+}
+
+func TestCheckVariables(t *testing.T) {
+	t.Setenv("DEV_MLP_DOCTOR_IMAGE", "nginx")
+
+	tests := map[string]struct {
+		files          map[string][]byte
+		prefixes       []string
+		expectedResult checkResult
+	}{
+		"no files": {
+			expectedResult: checkResult{name: checkVariables, result: resultSkip, details: "no files to check"},
+		},
+		"variables resolved with prefixes": {
+			files:          map[string][]byte{"deployment.yaml": []byte(doctorDeployment)},
+			prefixes:       []string{"DEV_"},
+			expectedResult: checkResult{name: checkVariables, result: resultPass, details: "all the variables referenced in 1 file(s) are set"},
+		},
+		"missing variables": {
+			files: map[string][]byte{
+				"deployment.yaml": []byte(doctorDeployment),
+				"configmap.yaml":  []byte("data:\n  key: '{{MLP_DOCTOR_MISSING}}'\n  other: '{{MLP_DOCTOR_IMAGE}}'\n"),
+			},
+			expectedResult: checkResult{name: checkVariables, result: resultFail, details: "MLP_DOCTOR_IMAGE, MLP_DOCTOR_MISSING not set, referenced in 2 file(s)"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := &DoctorOptions{prefixes: test.prefixes}
+			assert.Equal(t, test.expectedResult, o.checkVariables(test.files))
+		})
+	}
+}
+
+func TestDoctorRun(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		files           map[string]string
+		versionStatus   int
+		namespaceStatus int
+		createStatus    int
+		configMapStatus int
+		expectedOutput  string
+		expectedError   string
+	}{
+		"missing variables with a ready cluster": {
+			files: map[string]string{
+				"deployment.yaml":    doctorDeployment,
+				"kustomization.yaml": doctorKustomization,
+			},
+			versionStatus:   http.StatusOK,
+			namespaceStatus: http.StatusOK,
+			configMapStatus: http.StatusOK,
+			expectedOutput: `CHECK      RESULT  DETAILS
+variables  fail    MLP_DOCTOR_IMAGE not set, referenced in 1 file(s)
+cluster    pass    http://localhost:8080 reachable, version v1.30.0
+namespace  pass    namespace "mlp-doctor-test" exists
+inventory  pass    inventory "eu.mia-platform.mlp" readable with 1 resource(s)
+apis       pass    1 kind(s) served by the cluster
+`,
+			expectedError: "1 environment check(s) failed",
+		},
+		"missing namespace that can be created and missing kinds": {
+			files: map[string]string{
+				"externalsecret.yaml": doctorExternalSecret,
+			},
+			versionStatus:   http.StatusOK,
+			namespaceStatus: http.StatusNotFound,
+			createStatus:    http.StatusCreated,
+			configMapStatus: http.StatusForbidden,
+			expectedOutput: `CHECK      RESULT  DETAILS
+variables  pass    all the variables referenced in 1 file(s) are set
+cluster    pass    http://localhost:8080 reachable, version v1.30.0
+namespace  pass    namespace "mlp-doctor-test" not found, it can be created
+inventory  fail    failed to read inventory "eu.mia-platform.mlp": failed to find inventory: unknown (get configmaps eu.mia-platform.mlp)
+apis       fail    ExternalSecret external-secrets.io/v1beta1 not served by the cluster
+`,
+			expectedError: "2 environment check(s) failed",
+		},
+		"namespace that cannot be created": {
+			versionStatus:   http.StatusOK,
+			namespaceStatus: http.StatusNotFound,
+			createStatus:    http.StatusForbidden,
+			configMapStatus: http.StatusNotFound,
+			expectedOutput: `CHECK      RESULT  DETAILS
+variables  skip    no files to check
+cluster    pass    http://localhost:8080 reachable, version v1.30.0
+namespace  fail    namespace "mlp-doctor-test" not found and cannot be created: unknown (post namespaces)
+inventory  pass    inventory "eu.mia-platform.mlp" readable with 0 resource(s)
+apis       skip    no files to check
+`,
+			expectedError: "1 environment check(s) failed",
+		},
+		"cluster not reachable": {
+			versionStatus: http.StatusServiceUnavailable,
+			expectedOutput: `CHECK      RESULT  DETAILS
+variables  skip    no files to check
+cluster    fail    http://localhost:8080 not reachable: the server is currently unable to handle the request
+namespace  skip    the cluster is not reachable
+inventory  skip    the cluster is not reachable
+apis       skip    the cluster is not reachable
+`,
+			expectedError: "1 environment check(s) failed",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tf := jpltesting.NewTestClientFactory().
+				WithNamespace(doctorNamespace)
+			tf.Client = &restfake.RESTClient{
+				NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+				Client: restfake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+					status, body := http.StatusNotFound, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound"}`
+					switch path := r.URL.Path; {
+					case path == "/version":
+						status, body = test.versionStatus, `{"gitVersion":"v1.30.0"}`
+					case path == "/api/v1/namespaces/"+doctorNamespace && r.Method == http.MethodGet:
+						status, body = test.namespaceStatus, `{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"`+doctorNamespace+`"}}`
+					case path == "/api/v1/namespaces" && r.Method == http.MethodPost:
+						status, body = test.createStatus, `{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"`+doctorNamespace+`"}}`
+					case path == "/api/v1/namespaces/"+doctorNamespace+"/configmaps/eu.mia-platform.mlp":
+						status, body = test.configMapStatus, `{"kind":"ConfigMap","apiVersion":"v1","metadata":{"name":"eu.mia-platform.mlp"},"data":{"`+doctorNamespace+`_example_apps_Deployment":""}}`
+					case path == "/api/v1/namespaces/"+doctorNamespace+"/secrets/resources-deployed":
+					default:
+						return nil, fmt.Errorf("unexpected call: %q, method %s", r.URL.Path, r.Method)
+					}
+
+					return &http.Response{
+						StatusCode: status,
+						Header:     jpltesting.DefaultHeaders(),
+						Body:       io.NopCloser(strings.NewReader(body)),
+					}, nil
+				}),
+			}
+
+			fSys := filesys.MakeFsInMemory()
+			inputPaths := []string{}
+			for name, data := range test.files {
+				require.NoError(t, fSys.WriteFile(filepath.Join("out", name), []byte(data)))
+				inputPaths = []string{"out"}
+			}
+
+			writer := new(strings.Builder)
+			o := &DoctorOptions{
+				inputPaths:       inputPaths,
+				inventoryBackend: inventoryBackendDefaultValue,
+				clientFactory:    tf,
+				fSys:             fSys,
+				writer:           writer,
+			}
+
+			err := o.Run(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+			assert.Equal(t, test.expectedOutput, writer.String())
+		})
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/mia-platform/jpl/pkg/util"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/deploy"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/sanitize"
+	"github.com/mia-platform/mlp/v2/pkg/outputdir"
+	"github.com/mia-platform/mlp/v2/pkg/resourceutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	cmdUsage = "export"
+	cmdShort = "Save the live resources tracked by the inventory of a namespace as manifests"
+	cmdLong  = `Save the live resources tracked by the inventory of a namespace as manifests.
+
+	The command will read the inventory saved by the deploy command in the target
+	namespace and will fetch from the cluster every resource listed in it. The
+	fields populated by the API server are removed like the sanitize command does,
+	and every resource is saved in its own file inside the output directory, so
+	the folder can be passed to the deploy command for restoring them or for
+	migrating them to another cluster.
+	`
+	cmdExamples = `# save the resources deployed in a namespace in the backup folder
+	mlp export -n my-namespace -o ./backup
+
+	# save the resources deployed by a release with a custom field manager
+	mlp export -n my-namespace --field-manager my-manager --release-name api -o ./backup
+
+	# save the resources in the folders layout used by Mia-Platform Console
+	mlp export -n my-namespace --layout console -o ./backup
+	`
+
+	outputFlagName     = "out"
+	outputFlagShort    = "o"
+	outputDefaultValue = "export"
+	outputFlagUsage    = "output directory where the exported resources are saved"
+
+	fieldManagerFlagName  = "field-manager"
+	fieldManagerEnvName   = "MLP_FIELD_MANAGER"
+	fieldManagerFlagUsage = "the name of the manager used by the deploy, for finding its inventory, default to the " + fieldManagerEnvName + " env or 'mlp'"
+
+	releaseNameFlagName  = "release-name"
+	releaseNameFlagUsage = "the name of the release used by the deploy, for finding its inventory"
+
+	inventoryBackendFlagName     = "inventory-backend"
+	inventoryBackendDefaultValue = deploy.InventoryBackendConfigMap
+	inventoryBackendFlagUsage    = "where the inventory of the deployed resources is saved, one of: configmap, crd"
+
+	layoutFlagName     = "layout"
+	layoutDefaultValue = outputdir.LayoutFlat
+	layoutFlagUsage    = "how the files are organized inside the output directory, one of: flat, console"
+
+	exportFileExtension = ".yaml"
+)
+
+// Flags contains all the flags for the `export` command. They will be converted to Options
+// that contains all runtime options for the command.
+type Flags struct {
+	ConfigFlags      *genericclioptions.ConfigFlags
+	outputPath       string
+	fieldManager     string
+	releaseName      string
+	inventoryBackend string
+	layout           string
+}
+
+// Options have the data required to perform the export operation
+type Options struct {
+	outputPath       string
+	fieldManager     string
+	releaseName      string
+	inventoryBackend string
+	layout           string
+
+	clientFactory util.ClientFactory
+	fSys          filesys.FileSystem
+	writer        io.Writer
+}
+
+// NewCommand return the command for saving the live resources tracked by an inventory as manifests
+func NewCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	flags := &Flags{
+		ConfigFlags: configFlags,
+	}
+
+	cmd := &cobra.Command{
+		Use:     cmdUsage,
+		Short:   heredoc.Doc(cmdShort),
+		Long:    heredoc.Doc(cmdLong),
+		Example: heredoc.Doc(cmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(cmd.OutOrStdout(), outputdir.MakeFsOnDisk())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	if err := cmd.RegisterFlagCompletionFunc(inventoryBackendFlagName, deploy.InventoryBackendFlagCompletionfunc); err != nil {
+		panic(err)
+	}
+	if err := cmd.RegisterFlagCompletionFunc(layoutFlagName, layoutFlagCompletionfunc); err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+// AddFlags set the connection between Flags property to command line flags
+func (f *Flags) AddFlags(flags *pflag.FlagSet) {
+	if f.ConfigFlags != nil {
+		f.ConfigFlags.AddFlags(flags)
+	}
+
+	flags.StringVarP(&f.outputPath, outputFlagName, outputFlagShort, outputDefaultValue, outputFlagUsage)
+	if err := cobra.MarkFlagDirname(flags, outputFlagName); err != nil {
+		panic(err)
+	}
+	flags.StringVar(&f.fieldManager, fieldManagerFlagName, os.Getenv(fieldManagerEnvName), fieldManagerFlagUsage)
+	flags.StringVar(&f.releaseName, releaseNameFlagName, "", releaseNameFlagUsage)
+	flags.StringVar(&f.inventoryBackend, inventoryBackendFlagName, inventoryBackendDefaultValue, inventoryBackendFlagUsage)
+	flags.StringVar(&f.layout, layoutFlagName, layoutDefaultValue, layoutFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *Flags) ToOptions(writer io.Writer, fSys filesys.FileSystem) (*Options, error) {
+	if f.ConfigFlags == nil {
+		return nil, fmt.Errorf("config flags are required")
+	}
+
+	return &Options{
+		outputPath:       f.outputPath,
+		fieldManager:     f.fieldManager,
+		releaseName:      f.releaseName,
+		inventoryBackend: f.inventoryBackend,
+		layout:           f.layout,
+
+		clientFactory: util.NewFactory(f.ConfigFlags),
+		fSys:          fSys,
+		writer:        writer,
+	}, nil
+}
+
+// Validate check the options for errors
+func (o *Options) Validate() error {
+	if len(o.outputPath) == 0 {
+		return fmt.Errorf("the output directory cannot be empty")
+	}
+
+	if !slices.Contains(deploy.ValidInventoryBackends, o.inventoryBackend) {
+		return fmt.Errorf("invalid inventory backend value: %q", o.inventoryBackend)
+	}
+
+	if !slices.Contains(outputdir.ValidLayouts, o.layout) {
+		return fmt.Errorf("invalid layout value: %q", o.layout)
+	}
+
+	return nil
+}
+
+// Run execute the export command
+func (o *Options) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	namespace, _, err := o.clientFactory.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return err
+	}
+
+	manager := cmp.Or(o.fieldManager, "mlp")
+	name := deploy.InventoryName(manager, o.releaseName)
+	inventory, err := deploy.NewInventory(o.clientFactory, name, namespace, manager, o.inventoryBackend)
+	if err != nil {
+		return err
+	}
+
+	logger.V(5).Info("reading inventory", "name", name, "namespace", namespace)
+	objMetas, err := inventory.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read inventory %q: %w", name, err)
+	}
+
+	if objMetas.Len() == 0 {
+		fmt.Fprintf(o.writer, "no resources tracked by inventory %q in namespace %q\n", name, namespace)
+		return nil
+	}
+
+	client, err := o.clientFactory.DynamicClient()
+	if err != nil {
+		return err
+	}
+	mapper, err := o.clientFactory.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+
+	if err := o.fSys.MkdirAll(o.outputPath); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	exported := make([]string, 0, objMetas.Len())
+	for _, objMeta := range slices.SortedFunc(maps.Keys(objMetas), resourceutil.CompareIdentifiers) {
+		mapping, err := mapper.RESTMapping(schema.GroupKind{Group: objMeta.Group, Kind: objMeta.Kind})
+		if err != nil {
+			return fmt.Errorf("cannot find resource type of %s: %w", identifier(objMeta), err)
+		}
+
+		resourceClient := client.Resource(mapping.Resource).Namespace(objMeta.Namespace)
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			resourceClient = client.Resource(mapping.Resource)
+		}
+
+		logger.V(10).Info("reading live resource", "resource", identifier(objMeta))
+		obj, err := resourceClient.Get(ctx, objMeta.Name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			fmt.Fprintf(o.writer, "%s not found in the cluster, skipped\n", identifier(objMeta))
+			continue
+		case err != nil:
+			return fmt.Errorf("failed to read %s: %w", identifier(objMeta), err)
+		}
+
+		sanitize.Sanitize(obj)
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(o.outputPath, o.exportPath(objMeta))
+		if err := o.fSys.MkdirAll(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := o.fSys.WriteFile(path, data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", identifier(objMeta), err)
+		}
+		exported = append(exported, path)
+	}
+
+	if o.layout == outputdir.LayoutConsole {
+		if err := outputdir.WriteConsoleIndex(o.fSys, o.outputPath, exported); err != nil {
+			return fmt.Errorf("failed to write %s: %w", outputdir.ConsoleIndexFileName, err)
+		}
+	}
+
+	fmt.Fprintf(o.writer, "exported %d resource(s) tracked by inventory %q in %q\n", len(exported), name, o.outputPath)
+	return nil
+}
+
+// exportPath return the path relative to the output directory of the file containing the resource identified by
+// objMeta, following the selected layout
+func (o *Options) exportPath(objMeta resource.ObjectMetadata) string {
+	if o.layout != outputdir.LayoutConsole {
+		return exportFileName(objMeta)
+	}
+
+	// the namespace is already part of the path, so it is removed from the file name
+	fileName := exportFileName(resource.ObjectMetadata{Group: objMeta.Group, Kind: objMeta.Kind, Name: objMeta.Name})
+	return outputdir.ConsolePath(objMeta.Namespace, schema.GroupKind{Group: objMeta.Group, Kind: objMeta.Kind}, fileName)
+}
+
+func layoutFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return outputdir.ValidLayouts, cobra.ShellCompDirectiveDefault
+}
+
+// exportFileName return the name of the file containing the resource identified by objMeta, the group is part of
+// the name for avoiding collisions between kinds with the same name
+func exportFileName(objMeta resource.ObjectMetadata) string {
+	kind := strings.ToLower(objMeta.Kind)
+	if len(objMeta.Group) > 0 {
+		kind += "." + objMeta.Group
+	}
+
+	parts := []string{kind, objMeta.Name}
+	if len(objMeta.Namespace) > 0 {
+		parts = append([]string{objMeta.Namespace}, parts...)
+	}
+
+	return strings.Join(parts, "_") + exportFileExtension
+}
+
+// identifier return a human readable identifier of the resource for the messages
+func identifier(objMeta resource.ObjectMetadata) string {
+	kind := objMeta.Kind
+	if len(objMeta.Group) > 0 {
+		kind += "." + objMeta.Group
+	}
+
+	if len(objMeta.Namespace) == 0 {
+		return fmt.Sprintf("%s %q", kind, objMeta.Name)
+	}
+	return fmt.Sprintf("%s %q in namespace %q", kind, objMeta.Name, objMeta.Namespace)
+}
@@ -0,0 +1,255 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/mia-platform/mlp/v2/pkg/outputdir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	restfake "k8s.io/client-go/rest/fake"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	exportNamespace = "mlp-export-test"
+
+	expectedDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    custom: annotation
+  name: example
+  namespace: mlp-export-test
+spec:
+  replicas: 2
+`
+	expectedConfigMap = `apiVersion: v1
+data:
+  key: value
+kind: ConfigMap
+metadata:
+  name: example
+  namespace: mlp-export-test
+`
+)
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewCommand(genericclioptions.NewConfigFlags(false))
+	assert.NotNil(t, cmd)
+	assert.NotNil(t, cmd.Flags().Lookup(outputFlagName))
+	assert.NotNil(t, cmd.Flags().Lookup(inventoryBackendFlagName))
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	writer := new(bytes.Buffer)
+	fSys := filesys.MakeFsInMemory()
+	flags := &Flags{}
+	_, err := flags.ToOptions(writer, fSys)
+	assert.ErrorContains(t, err, "config flags are required")
+
+	flags = &Flags{
+		ConfigFlags:      genericclioptions.NewConfigFlags(false),
+		outputPath:       "backup",
+		fieldManager:     "manager",
+		releaseName:      "api",
+		inventoryBackend: inventoryBackendDefaultValue,
+		layout:           layoutDefaultValue,
+	}
+	o, err := flags.ToOptions(writer, fSys)
+	require.NoError(t, err)
+	assert.Equal(t, "backup", o.outputPath)
+	assert.Equal(t, "manager", o.fieldManager)
+	assert.Equal(t, "api", o.releaseName)
+	assert.NotNil(t, o.clientFactory)
+	assert.NoError(t, o.Validate())
+
+	o.inventoryBackend = "invalid"
+	assert.ErrorContains(t, o.Validate(), `invalid inventory backend value: "invalid"`)
+	o.inventoryBackend = inventoryBackendDefaultValue
+
+	o.layout = "nested"
+	assert.ErrorContains(t, o.Validate(), `invalid layout value: "nested"`)
+	o.layout = layoutDefaultValue
+
+	o.outputPath = ""
+	assert.ErrorContains(t, o.Validate(), "the output directory cannot be empty")
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":              "example",
+			"namespace":         exportNamespace,
+			"uid":               "8b2a4f7e-6c1d-4a9e-9f3b-2d5e7c8a1b0f",
+			"resourceVersion":   "1234",
+			"generation":        int64(3),
+			"creationTimestamp": "2024-01-01T00:00:00Z",
+			"annotations": map[string]interface{}{
+				"custom":                            "annotation",
+				"deployment.kubernetes.io/revision": "3",
+			},
+			"managedFields": []interface{}{
+				map[string]interface{}{"manager": "mlp", "operation": "Apply"},
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+		},
+		"status": map[string]interface{}{
+			"readyReplicas": int64(2),
+		},
+	}}
+	configMap := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":            "example",
+			"namespace":       exportNamespace,
+			"resourceVersion": "42",
+		},
+		"data": map[string]interface{}{"key": "value"},
+	}}
+
+	tests := map[string]struct {
+		inventoryData  string
+		layout         string
+		expectedFiles  map[string]string
+		expectedOutput string
+		expectedError  string
+	}{
+		"export tracked resources": {
+			inventoryData: `{"` + exportNamespace + `_example_apps_Deployment":"","` + exportNamespace + `_example__ConfigMap":""}`,
+			expectedFiles: map[string]string{
+				"mlp-export-test_deployment.apps_example.yaml": expectedDeployment,
+				"mlp-export-test_configmap_example.yaml":       expectedConfigMap,
+			},
+			expectedOutput: "exported 2 resource(s) tracked by inventory \"eu.mia-platform.mlp\" in \"backup\"\n",
+		},
+		"export with console layout": {
+			inventoryData: `{"` + exportNamespace + `_example_apps_Deployment":"","` + exportNamespace + `_example__ConfigMap":""}`,
+			layout:        outputdir.LayoutConsole,
+			expectedFiles: map[string]string{
+				"mlp-export-test/workloads/deployment.apps_example.yaml": expectedDeployment,
+				"mlp-export-test/configuration/configmap_example.yaml":   expectedConfigMap,
+				outputdir.ConsoleIndexFileName: `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- mlp-export-test/configuration/configmap_example.yaml
+- mlp-export-test/workloads/deployment.apps_example.yaml
+`,
+			},
+			expectedOutput: "exported 2 resource(s) tracked by inventory \"eu.mia-platform.mlp\" in \"backup\"\n",
+		},
+		"skip missing resources": {
+			inventoryData: `{"` + exportNamespace + `_missing__ConfigMap":"","` + exportNamespace + `_example__ConfigMap":""}`,
+			expectedFiles: map[string]string{
+				"mlp-export-test_configmap_example.yaml": expectedConfigMap,
+			},
+			expectedOutput: "ConfigMap \"missing\" in namespace \"mlp-export-test\" not found in the cluster, skipped\n" +
+				"exported 1 resource(s) tracked by inventory \"eu.mia-platform.mlp\" in \"backup\"\n",
+		},
+		"empty inventory": {
+			inventoryData:  `{}`,
+			expectedFiles:  map[string]string{},
+			expectedOutput: "no resources tracked by inventory \"eu.mia-platform.mlp\" in namespace \"mlp-export-test\"\n",
+		},
+		"unknown resource type": {
+			inventoryData: `{"` + exportNamespace + `_example_example.com_Unknown":""}`,
+			expectedError: `cannot find resource type of Unknown.example.com "example" in namespace "mlp-export-test"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tf := jpltesting.NewTestClientFactory().WithNamespace(exportNamespace)
+			tf.FakeDynamicClient = dynamicfake.NewSimpleDynamicClient(jpltesting.Scheme, deployment.DeepCopy(), configMap.DeepCopy())
+			tf.Client = &restfake.RESTClient{
+				NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+				Client: restfake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+					status, body := http.StatusNotFound, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound"}`
+					switch r.URL.Path {
+					case "/api/v1/namespaces/" + exportNamespace + "/configmaps/eu.mia-platform.mlp":
+						status, body = http.StatusOK, `{"kind":"ConfigMap","apiVersion":"v1","metadata":{"name":"eu.mia-platform.mlp"},"data":`+test.inventoryData+`}`
+					case "/api/v1/namespaces/" + exportNamespace + "/secrets/resources-deployed":
+					default:
+						return nil, fmt.Errorf("unexpected call: %q, method %s", r.URL.Path, r.Method)
+					}
+
+					return &http.Response{
+						StatusCode: status,
+						Header:     jpltesting.DefaultHeaders(),
+						Body:       io.NopCloser(strings.NewReader(body)),
+					}, nil
+				}),
+			}
+
+			fSys := filesys.MakeFsInMemory()
+			writer := new(strings.Builder)
+			o := &Options{
+				outputPath:       "backup",
+				inventoryBackend: inventoryBackendDefaultValue,
+				layout:           cmp.Or(test.layout, layoutDefaultValue),
+				clientFactory:    tf,
+				fSys:             fSys,
+				writer:           writer,
+			}
+
+			err := o.Run(context.TODO())
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedOutput, writer.String())
+			for file, expectedData := range test.expectedFiles {
+				data, err := fSys.ReadFile(filepath.Join("backup", file))
+				require.NoError(t, err)
+				assert.Equal(t, expectedData, string(data))
+			}
+			if len(test.expectedFiles) > 0 && len(test.layout) == 0 {
+				entries, err := fSys.ReadDir("backup")
+				require.NoError(t, err)
+				assert.Len(t, entries, len(test.expectedFiles))
+			}
+		})
+	}
+}
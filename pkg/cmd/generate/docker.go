@@ -0,0 +1,111 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// parseDocker return the content of a dockerconfigjson containing the credentials of all the registries of spec
+func parseDocker(spec *v1.DockerConfig) ([]byte, error) {
+	registries := spec.Registries
+	if spec.DockerRegistry != (v1.DockerRegistry{}) {
+		registries = append([]v1.DockerRegistry{spec.DockerRegistry}, registries...)
+	}
+
+	if len(registries) == 0 {
+		return nil, errors.New("docker configuration must contain at least one registry")
+	}
+
+	auths := make(dockerConfig, len(registries))
+	for _, registry := range registries {
+		if err := validateDockerRegistry(registry); err != nil {
+			return nil, err
+		}
+
+		if _, found := auths[registry.Server]; found {
+			return nil, fmt.Errorf("docker registry %q is configured more than once", registry.Server)
+		}
+
+		auths[registry.Server] = dockerConfigEntry{
+			Username: registry.Username,
+			Password: registry.Password,
+			Email:    registry.Email,
+			Auth:     encodeDockerConfigFieldAuth(registry.Username, registry.Password),
+		}
+	}
+
+	return json.Marshal(dockerConfigJSON{Auths: auths})
+}
+
+// validateDockerRegistry return an error if the server of registry is not a valid hostname, optionally with
+// scheme, port and path, or if its email is not a valid address
+func validateDockerRegistry(registry v1.DockerRegistry) error {
+	if len(registry.Server) == 0 {
+		return errors.New("docker registry server cannot be empty")
+	}
+
+	if err := validateDockerServer(registry.Server); err != nil {
+		return fmt.Errorf("invalid docker registry server %q: %w", registry.Server, err)
+	}
+
+	if len(registry.Email) == 0 {
+		return nil
+	}
+
+	if _, err := mail.ParseAddress(registry.Email); err != nil {
+		return fmt.Errorf("invalid email %q for docker registry %q: %w", registry.Email, registry.Server, err)
+	}
+	return nil
+}
+
+// validateDockerServer check that server contains a valid hostname or ip address
+func validateDockerServer(server string) error {
+	if !strings.Contains(server, "://") {
+		server = "//" + server
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return err
+	}
+
+	host := serverURL.Hostname()
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+
+	if errs := validation.IsDNS1123Subdomain(strings.ToLower(host)); len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// encodeDockerConfigFieldAuth returns base64 encoding of the username and password string
+func encodeDockerConfigFieldAuth(username, password string) string {
+	fieldValue := username + ":" + password
+	return base64.StdEncoding.EncodeToString([]byte(fieldValue))
+}
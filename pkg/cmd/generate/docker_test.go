@@ -0,0 +1,130 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"encoding/json"
+	"testing"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestParseDocker(t *testing.T) {
+	t.Parallel()
+
+	registry := func(server, email string) v1.DockerRegistry {
+		return v1.DockerRegistry{Username: "username", Password: "password", Email: email, Server: server}
+	}
+	entry := func(email string) dockerConfigEntry {
+		return dockerConfigEntry{Username: "username", Password: "password", Email: email, Auth: "dXNlcm5hbWU6cGFzc3dvcmQ="}
+	}
+
+	tests := map[string]struct {
+		config        *v1.DockerConfig
+		expectedAuths dockerConfig
+		expectedError string
+	}{
+		"single registry": {
+			config:        &v1.DockerConfig{DockerRegistry: registry("example.com", "email@example.com")},
+			expectedAuths: dockerConfig{"example.com": entry("email@example.com")},
+		},
+		"multiple registries": {
+			config: &v1.DockerConfig{
+				DockerRegistry: registry("example.com", ""),
+				Registries: []v1.DockerRegistry{
+					registry("https://index.docker.io/v1/", ""),
+					registry("10.0.0.1:5000", "User <user@example.com>"),
+				},
+			},
+			expectedAuths: dockerConfig{
+				"example.com":                 entry(""),
+				"https://index.docker.io/v1/": entry(""),
+				"10.0.0.1:5000":               entry("User <user@example.com>"),
+			},
+		},
+		"only registries list": {
+			config:        &v1.DockerConfig{Registries: []v1.DockerRegistry{registry("registry.example.com:443", "")}},
+			expectedAuths: dockerConfig{"registry.example.com:443": entry("")},
+		},
+		"no registry": {
+			config:        &v1.DockerConfig{},
+			expectedError: "docker configuration must contain at least one registry",
+		},
+		"missing server": {
+			config:        &v1.DockerConfig{DockerRegistry: registry("", "")},
+			expectedError: "docker registry server cannot be empty",
+		},
+		"invalid server": {
+			config:        &v1.DockerConfig{DockerRegistry: registry("registry_example.com", "")},
+			expectedError: `invalid docker registry server "registry_example.com"`,
+		},
+		"invalid server port": {
+			config:        &v1.DockerConfig{DockerRegistry: registry("example.com:port", "")},
+			expectedError: `invalid docker registry server "example.com:port"`,
+		},
+		"invalid email": {
+			config:        &v1.DockerConfig{DockerRegistry: registry("example.com", "not-an-email")},
+			expectedError: `invalid email "not-an-email" for docker registry "example.com"`,
+		},
+		"duplicated server": {
+			config: &v1.DockerConfig{
+				DockerRegistry: registry("example.com", ""),
+				Registries:     []v1.DockerRegistry{registry("example.com", "")},
+			},
+			expectedError: `docker registry "example.com" is configured more than once`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := parseDocker(test.config)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			config := dockerConfigJSON{}
+			require.NoError(t, json.Unmarshal(data, &config))
+			assert.Equal(t, test.expectedAuths, config.Auths)
+		})
+	}
+}
+
+func TestDockerConfigUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`username: user
+password: pass
+server: example.com
+registries:
+- username: other
+  password: pass
+  server: other.example.com
+`)
+
+	config := v1.DockerConfig{}
+	require.NoError(t, yaml.Unmarshal(data, &config))
+	assert.Equal(t, v1.DockerConfig{
+		DockerRegistry: v1.DockerRegistry{Username: "user", Password: "pass", Server: "example.com"},
+		Registries:     []v1.DockerRegistry{{Username: "other", Password: "pass", Server: "other.example.com"}},
+	}, config)
+}
@@ -0,0 +1,74 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// isBinaryData return true if the content read for data must be saved base64 encoded: entries marked as binary
+// and content that is not valid UTF-8 always are, while literal values never are unless marked as binary
+func (o *Options) isBinaryData(data v1.Data, content []byte) bool {
+	switch {
+	case data.Binary:
+		return true
+	case data.From == v1.DataFromLiteral:
+		return false
+	case !utf8.Valid(content):
+		return true
+	default:
+		return o.forceBase64
+	}
+}
+
+// setSecretData add to secret the content of the data entries of spec, using the stringData field for the values
+// that are not binary if requested by the spec encoding
+func (o *Options) setSecretData(secret *corev1.Secret, spec v1.SecretSpec) error {
+	var useStringData bool
+	switch spec.Encoding {
+	case "", v1.SecretEncodingData:
+	case v1.SecretEncodingStringData:
+		useStringData = true
+	default:
+		return fmt.Errorf("invalid encoding %q for secret %q: must be %q or %q", spec.Encoding, spec.Name, v1.SecretEncodingData, v1.SecretEncodingStringData)
+	}
+
+	for _, data := range spec.Data {
+		key, content, found, err := o.dataContent(data)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+
+		if !useStringData || o.isBinaryData(data, content) {
+			secret.Data[key] = content
+			continue
+		}
+
+		if secret.StringData == nil {
+			secret.StringData = make(map[string]string)
+		}
+		secret.StringData[key] = string(content)
+	}
+
+	return nil
+}
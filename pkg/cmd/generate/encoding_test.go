@@ -0,0 +1,159 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestDataEncoding(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("text.txt", []byte("text content")))
+	require.NoError(t, fSys.WriteFile("binary.bin", []byte{0xff, 0xfe, 0x00}))
+	require.NoError(t, fSys.WriteFile("marked.txt", []byte("marked")))
+	require.NoError(t, fSys.WriteFile("string-data.yaml", []byte(`secrets:
+- name: encoded
+  when: always
+  encoding: stringData
+  data:
+  - from: literal
+    key: literal
+    value: literal value
+  - from: file
+    file: text.txt
+  - from: file
+    file: binary.bin
+  - from: literal
+    key: forced
+    value: forced value
+    binary: true
+config-maps:
+- name: encoded
+  data:
+  - from: literal
+    key: literal
+    value: literal value
+  - from: file
+    file: text.txt
+  - from: file
+    file: binary.bin
+  - from: file
+    file: marked.txt
+    binary: true
+`)))
+	require.NoError(t, fSys.WriteFile("default-encoding.yaml", []byte(`secrets:
+- name: encoded
+  when: always
+  data:
+  - from: literal
+    key: literal
+    value: literal value
+`)))
+	require.NoError(t, fSys.WriteFile("invalid-encoding.yaml", []byte(`secrets:
+- name: invalid
+  when: always
+  encoding: base64
+  data:
+  - from: literal
+    key: literal
+    value: literal value
+`)))
+
+	type fields map[string]map[string]string
+	tests := map[string]struct {
+		configFile     string
+		forceBase64    bool
+		expectedFields map[string]fields
+		expectedError  string
+	}{
+		"string data encoding": {
+			configFile: "string-data.yaml",
+			expectedFields: map[string]fields{
+				"Secret": {
+					"data":       {"binary.bin": "//4A", "forced": "Zm9yY2VkIHZhbHVl"},
+					"stringData": {"literal": "literal value", "text.txt": "text content"},
+				},
+				"ConfigMap": {
+					"data":       {"literal": "literal value", "text.txt": "text content"},
+					"binaryData": {"binary.bin": "//4A", "marked.txt": "bWFya2Vk"},
+				},
+			},
+		},
+		"force base64 for files": {
+			configFile:  "string-data.yaml",
+			forceBase64: true,
+			expectedFields: map[string]fields{
+				"Secret": {
+					"data":       {"binary.bin": "//4A", "forced": "Zm9yY2VkIHZhbHVl", "text.txt": "dGV4dCBjb250ZW50"},
+					"stringData": {"literal": "literal value"},
+				},
+				"ConfigMap": {
+					"data":       {"literal": "literal value"},
+					"binaryData": {"binary.bin": "//4A", "marked.txt": "bWFya2Vk", "text.txt": "dGV4dCBjb250ZW50"},
+				},
+			},
+		},
+		"default data encoding": {
+			configFile: "default-encoding.yaml",
+			expectedFields: map[string]fields{
+				"Secret": {
+					"data": {"literal": "bGl0ZXJhbCB2YWx1ZQ=="},
+				},
+			},
+		},
+		"invalid encoding": {
+			configFile:    "invalid-encoding.yaml",
+			expectedError: `invalid encoding "base64" for secret "invalid": must be "data" or "stringData"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options := NewOptions([]string{test.configFile}, nil, fSys)
+			options.forceBase64 = test.forceBase64
+			objects, err := options.RunToObjects(context.TODO())
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			result := make(map[string]fields, len(objects))
+			for _, obj := range objects {
+				objFields := make(fields)
+				for _, field := range []string{"data", "stringData", "binaryData"} {
+					values, found, err := unstructured.NestedStringMap(obj.Object, field)
+					require.NoError(t, err)
+					if found {
+						objFields[field] = values
+					}
+				}
+				result[obj.GetKind()] = objFields
+			}
+			assert.Equal(t, test.expectedFields, result)
+		})
+	}
+}
@@ -19,21 +19,32 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
+	"io/fs"
+	"maps"
 	"path/filepath"
 	"slices"
-	"unicode/utf8"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/resource"
 	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	v2 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v2"
 	"github.com/mia-platform/mlp/v2/pkg/cmd/interpolate"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/mia-platform/mlp/v2/pkg/ignore"
+	"github.com/mia-platform/mlp/v2/pkg/outputdir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/clock"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/yaml"
 )
@@ -42,10 +53,13 @@ const (
 	cmdUsage = "generate"
 	cmdShort = "Generate ConfigMap and Secret manifests"
 	cmdLong  = `Generate ConfigMap and Secret Kubernetes manifest files from one or more
-	configuration files.
+	configuration files. If a path is a folder all the configuration files found
+	inside it will be used, skipping the ones matching the patterns of the
+	.mlpignore file found at its root.
 
 	The configuration files will be interpolated with the same logic of the
-	interpolate command.
+	interpolate command, and read following their apiVersion: the files without
+	it are read as mlp.mia-platform.eu/v1.
 	`
 
 	configFilesFlagName  = "config-file"
@@ -59,26 +73,68 @@ const (
 	outputFlagName  = "out"
 	outputFlagShort = "o"
 	outputFlagUsage = "output directory where interpolated files are saved"
+
+	filenameTemplateFlagName  = "filename-template"
+	filenameTemplateFlagUsage = "go template used for naming the generated files, it can use the .Kind and .Name fields"
+	defaultFilenameTemplate   = "{{.Name}}.{{lower .Kind}}.yaml"
+
+	inventoryFlagName  = "inventory"
+	inventoryFlagUsage = "if true generate also a ConfigMap tracking the generated resources, the deploy command will use it to prune the ones removed from the configuration"
+
+	leftDelimFlagName  = "left-delim"
+	leftDelimFlagUsage = "the string opening the interpolation sequences in the config files"
+
+	rightDelimFlagName  = "right-delim"
+	rightDelimFlagUsage = "the string closing the interpolation sequences in the config files"
+
+	certExpiryWarningDaysFlagName     = "cert-expiry-warning-days"
+	certExpiryWarningDaysDefaultValue = 30
+	certExpiryWarningDaysFlagUsage    = "number of days before the expiration of a TLS certificate when a warning is printed"
+
+	runIDFlagName  = "run-id"
+	runIDFlagUsage = "if set the generated files are saved in a folder with this name inside the output directory, together with an " + outputdir.IndexFileName + " file listing them"
+
+	forceBase64FlagName  = "force-base64"
+	forceBase64FlagUsage = "if true the content read from files is always saved base64 encoded, in the data field of the Secrets and in the binaryData field of the ConfigMaps, even if it is valid UTF-8"
 )
 
 var (
 	validExtensions = []string{".yaml", ".yml"}
+
+	filenameTemplateFuncs = template.FuncMap{
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+	}
 )
 
 // Flags contains all the flags for the `generate` command. They will be converted to Options
 // that contains all runtime options for the command.
 type Flags struct {
-	configFiles []string
-	prefixes    []string
-	outputPath  string
+	configFiles           []string
+	prefixes              []string
+	outputPath            string
+	filenameTemplate      string
+	inventory             bool
+	certExpiryWarningDays int
+	forceBase64           bool
+	leftDelim             string
+	rightDelim            string
+	runID                 string
 }
 
 // Options have the data required to perform the generate operation
 type Options struct {
-	configFiles []string
-	prefixes    []string
-	outputPath  string
-	fSys        filesys.FileSystem
+	configFiles           []string
+	prefixes              []string
+	outputPath            string
+	filenameTemplate      string
+	inventory             bool
+	certExpiryWarningDays int
+	forceBase64           bool
+	delimiters            interpolate.Delimiters
+	runID                 string
+	fSys                  filesys.FileSystem
+	clock                 clock.PassiveClock
 }
 
 // NewCommand return the command for generating ConfigMap and Secret resources from a configuration file
@@ -92,7 +148,7 @@ func NewCommand() *cobra.Command {
 		Args: cobra.NoArgs,
 
 		Run: func(cmd *cobra.Command, _ []string) {
-			o, err := flags.ToOptions(filesys.MakeFsOnDisk())
+			o, err := flags.ToOptions(outputdir.MakeFsOnDisk())
 			cobra.CheckErr(err)
 			cobra.CheckErr(o.Validate())
 			cobra.CheckErr(o.Run(cmd.Context()))
@@ -116,15 +172,40 @@ func (f *Flags) AddFlags(flags *pflag.FlagSet) {
 	if err := cobra.MarkFlagDirname(flags, outputFlagName); err != nil {
 		panic(err)
 	}
+	flags.StringVar(&f.filenameTemplate, filenameTemplateFlagName, defaultFilenameTemplate, filenameTemplateFlagUsage)
+	flags.BoolVar(&f.inventory, inventoryFlagName, false, inventoryFlagUsage)
+	flags.IntVar(&f.certExpiryWarningDays, certExpiryWarningDaysFlagName, certExpiryWarningDaysDefaultValue, certExpiryWarningDaysFlagUsage)
+	flags.BoolVar(&f.forceBase64, forceBase64FlagName, false, forceBase64FlagUsage)
+	flags.StringVar(&f.leftDelim, leftDelimFlagName, interpolate.DefaultDelimiters.Left, leftDelimFlagUsage)
+	flags.StringVar(&f.rightDelim, rightDelimFlagName, interpolate.DefaultDelimiters.Right, rightDelimFlagUsage)
+	flags.StringVar(&f.runID, runIDFlagName, "", runIDFlagUsage)
+}
+
+// NewOptions return the Options for generating the resources found in configFiles looking for environment
+// variables with prefixes, without going through the command flags
+func NewOptions(configFiles, prefixes []string, fSys filesys.FileSystem) *Options {
+	return &Options{
+		configFiles:           configFiles,
+		prefixes:              prefixes,
+		filenameTemplate:      defaultFilenameTemplate,
+		certExpiryWarningDays: certExpiryWarningDaysDefaultValue,
+		fSys:                  fSys,
+	}
 }
 
 // ToOptions transform the command flags in command runtime arguments
 func (f *Flags) ToOptions(fSys filesys.FileSystem) (*Options, error) {
 	return &Options{
-		configFiles: f.configFiles,
-		prefixes:    f.prefixes,
-		outputPath:  f.outputPath,
-		fSys:        fSys,
+		configFiles:           f.configFiles,
+		prefixes:              f.prefixes,
+		outputPath:            f.outputPath,
+		filenameTemplate:      f.filenameTemplate,
+		inventory:             f.inventory,
+		certExpiryWarningDays: f.certExpiryWarningDays,
+		forceBase64:           f.forceBase64,
+		delimiters:            interpolate.Delimiters{Left: f.leftDelim, Right: f.rightDelim},
+		runID:                 f.runID,
+		fSys:                  fSys,
 	}, nil
 }
 
@@ -133,6 +214,22 @@ func (o *Options) Validate() error {
 		return fmt.Errorf("at least one config file must be specified")
 	}
 
+	if _, err := parseFilenameTemplate(o.filenameTemplate); err != nil {
+		return fmt.Errorf("invalid filename template: %w", err)
+	}
+
+	if o.certExpiryWarningDays < 0 {
+		return fmt.Errorf("the %q flag cannot be negative", certExpiryWarningDaysFlagName)
+	}
+
+	if err := o.delimiters.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := o.outputDir(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -140,40 +237,185 @@ func (o *Options) Validate() error {
 func (o *Options) Run(ctx context.Context) error {
 	logger := logr.FromContextOrDiscard(ctx)
 
-	if err := o.fSys.MkdirAll(o.outputPath); err != nil {
+	outputPath, err := o.outputDir()
+	if err != nil {
+		return err
+	}
+
+	resources, err := o.generate(ctx)
+	if err != nil {
 		return err
 	}
 
-	pathsToInterpolate := o.filterYAMLFiles()
+	if err := o.fSys.MkdirAll(outputPath); err != nil {
+		return err
+	}
+
+	if err := o.writeResources(ctx, outputPath, resources.objects); err != nil {
+		return err
+	}
+	if err := o.writeFiles(ctx, outputPath, resources.files); err != nil {
+		return err
+	}
+
+	if len(o.runID) == 0 {
+		return nil
+	}
+
+	written := slices.Collect(maps.Keys(resources.objects))
+	written = append(written, slices.Collect(maps.Keys(resources.files))...)
+	logger.V(5).Info("saving output index", "path", outputPath)
+	return outputdir.WriteIndex(o.fSys, outputPath, o.runID, cmdUsage, written)
+}
+
+// outputDir return the directory where the generated files are saved, scoped to the run if a run id is set
+func (o *Options) outputDir() (string, error) {
+	if len(o.runID) == 0 {
+		return o.outputPath, nil
+	}
+
+	return outputdir.RunPath(o.outputPath, o.runID)
+}
+
+// RunToObjects execute the generate command without writing anything on the filesystem and return the generated
+// resources sorted by their file name, they can be passed directly to the deploy command avoiding to save
+// sensitive data on disk; the resources configured with a non Kubernetes output are skipped
+func (o *Options) RunToObjects(ctx context.Context) ([]*unstructured.Unstructured, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	resources, err := o.generate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*unstructured.Unstructured, 0, len(resources.objects))
+	for _, name := range slices.Sorted(maps.Keys(resources.objects)) {
+		obj, err := toUnstructured(resources.objects[name])
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	for name := range resources.files {
+		logger.V(5).Info("skipping resource with a non Kubernetes output", "file", name)
+	}
+
+	return objects, nil
+}
+
+// generate return the resources described in all the configuration files, and the inventory tracking them if
+// requested, keyed by the file name where they will be saved
+func (o *Options) generate(ctx context.Context) (*generatedResources, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	pathsToInterpolate, err := o.filterYAMLFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	generated := &generatedResources{
+		objects: make(map[string]runtime.Object),
+		files:   make(map[string][]byte),
+	}
 	for _, path := range pathsToInterpolate {
 		logger.V(3).Info("generating resource from configuration", "path", path)
 		configuration, err := o.readConfiguration(ctx, path)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		err = o.generateResources(ctx, configuration)
+		resources, err := o.generateResources(ctx, configuration)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		if err := generated.merge(resources); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
+	if !o.inventory {
+		return generated, nil
+	}
+
+	inventory, err := generatedInventory(slices.Collect(maps.Values(generated.objects)))
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := o.filenameForResource(inventory.Kind, inventory.Name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	generated.objects[name] = inventory
+	return generated, nil
+}
+
+// toUnstructured convert a generated object in its unstructured form
+func toUnstructured(object runtime.Object) (*unstructured.Unstructured, error) {
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &unstructured.Unstructured{Object: data}
+	unstructured.RemoveNestedField(obj.Object, "metadata", "creationTimestamp")
+	return obj, nil
+}
+
+// generatedInventory return the ConfigMap that keep track of all the generated objects
+func generatedInventory(objects []runtime.Object) (*corev1.ConfigMap, error) {
+	objMetas := make([]resource.ObjectMetadata, 0, len(objects))
+	for _, obj := range objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		objMetas = append(objMetas, resource.ObjectMetadata{
+			Name:  accessor.GetName(),
+			Group: gvk.Group,
+			Kind:  gvk.Kind,
+		})
+	}
+
+	return extensions.NewGeneratedInventory(objMetas), nil
 }
 
-func (o *Options) filterYAMLFiles() []string {
+// filterYAMLFiles return the yaml files passed as configuration files and the ones found inside the folders,
+// skipping the paths ignored by their .mlpignore file
+func (o *Options) filterYAMLFiles() ([]string, error) {
 	filteredPaths := make([]string, 0)
 	for _, path := range o.configFiles {
-		if o.fSys.IsDir(path) || !slices.Contains(validExtensions, filepath.Ext(path)) {
+		if !o.fSys.IsDir(path) {
+			if slices.Contains(validExtensions, filepath.Ext(path)) {
+				filteredPaths = append(filteredPaths, path)
+			}
 			continue
 		}
-		filteredPaths = append(filteredPaths, path)
+
+		err := ignore.Walk(o.fSys, path, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && slices.Contains(validExtensions, filepath.Ext(path)) {
+				filteredPaths = append(filteredPaths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return filteredPaths
+	return filteredPaths, nil
 }
 
-func (o *Options) readConfiguration(ctx context.Context, path string) (*v1.GenerateConfiguration, error) {
+// readConfiguration return the interpolated configuration at path, the configurations without apiVersion or with
+// the v1 one are converted to v2
+func (o *Options) readConfiguration(ctx context.Context, path string) (*v2.GenerateConfiguration, error) {
 	logger := logr.FromContextOrDiscard(ctx)
 
 	data, err := o.fSys.ReadFile(path)
@@ -182,50 +424,251 @@ func (o *Options) readConfiguration(ctx context.Context, path string) (*v1.Gener
 	}
 
 	logger.V(8).Info("interpolating configuration file", "path", path)
-	interpolatedData, err := interpolate.Interpolate(data, o.prefixes)
+	interpolatedData, missingEnvs, err := o.delimiters.InterpolateKeepingMissing(data, o.prefixes)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("interpolating %s: %w", path, err)
 	}
 
 	logger.V(5).Info("parsing configuration file", "path", path)
-	configuration := new(v1.GenerateConfiguration)
-	err = yaml.Unmarshal(interpolatedData, configuration)
-	return configuration, err
+	typeMeta := new(metav1.TypeMeta)
+	if err := yaml.Unmarshal(interpolatedData, typeMeta); err != nil {
+		return nil, err
+	}
+
+	var configuration *v2.GenerateConfiguration
+	switch typeMeta.APIVersion {
+	case "", v1.SchemeGroupVersion.String():
+		v1Configuration := new(v1.GenerateConfiguration)
+		if err := yaml.Unmarshal(interpolatedData, v1Configuration); err != nil {
+			return nil, err
+		}
+		configuration = v2.ConvertFromV1(v1Configuration)
+	case v2.SchemeGroupVersion.String():
+		if len(typeMeta.Kind) > 0 && typeMeta.Kind != v2.GenerateConfigurationKind {
+			return nil, fmt.Errorf("unsupported kind %q in %s: must be %q", typeMeta.Kind, path, v2.GenerateConfigurationKind)
+		}
+		configuration = new(v2.GenerateConfiguration)
+		if err := yaml.UnmarshalStrict(interpolatedData, configuration); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported apiVersion %q in %s: must be %q or %q", typeMeta.APIVersion, path, v1.SchemeGroupVersion, v2.SchemeGroupVersion)
+	}
+
+	if err := resolveMissingEnvs(configuration, missingEnvs, o.delimiters); err != nil {
+		return nil, err
+	}
+	return configuration, nil
+}
+
+// generatedResources contains the resources generated from a configuration keyed by the file name where they
+// will be saved
+type generatedResources struct {
+	// objects are the resources saved as Kubernetes manifests
+	objects map[string]runtime.Object
+	// files are the resources already rendered by the formatter of their output
+	files map[string][]byte
+}
+
+// merge add the objects and the files of other to resources, returning an error if one of their file names is
+// already used
+func (resources *generatedResources) merge(other *generatedResources) error {
+	names := slices.Sorted(maps.Keys(other.objects))
+	names = append(names, slices.Sorted(maps.Keys(other.files))...)
+	for _, name := range names {
+		_, foundObject := resources.objects[name]
+		_, foundFile := resources.files[name]
+		if foundObject || foundFile {
+			return fmt.Errorf("multiple resources are generated with the same file name: %q", name)
+		}
+	}
+
+	maps.Copy(resources.objects, other.objects)
+	maps.Copy(resources.files, other.files)
+	return nil
+}
+
+// add save obj in the format selected by output, using filenameTemplate if set or the default one of the output
+// for naming its file
+func (o *Options) add(resources *generatedResources, obj runtime.Object, output, filenameTemplate string) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+
+	var formatter outputFormatter
+	if !isKubernetesOutput(output) {
+		if formatter, err = formatterForOutput(output); err != nil {
+			return fmt.Errorf("%s %q: %w", kind, accessor.GetName(), err)
+		}
+		if len(filenameTemplate) == 0 {
+			filenameTemplate = formatter.filenameTemplate()
+		}
+	}
+
+	name, err := o.filenameForResource(kind, accessor.GetName(), filenameTemplate)
+	if err != nil {
+		return err
+	}
+	_, foundObject := resources.objects[name]
+	_, foundFile := resources.files[name]
+	if foundObject || foundFile {
+		return fmt.Errorf("multiple resources are generated with the same file name: %q", name)
+	}
+
+	if formatter == nil {
+		resources.objects[name] = obj
+		return nil
+	}
+
+	data, err := resourceData(obj)
+	if err != nil {
+		return err
+	}
+	content, err := formatter.format(data)
+	if err != nil {
+		return fmt.Errorf("%s %q cannot be saved with the %s output: %w", kind, accessor.GetName(), output, err)
+	}
+	resources.files[name] = content
+	return nil
 }
 
-func (o *Options) generateResources(ctx context.Context, config *v1.GenerateConfiguration) error {
+// generateResources return the resources described in config
+func (o *Options) generateResources(ctx context.Context, config *v2.GenerateConfiguration) (*generatedResources, error) {
 	logger := logr.FromContextOrDiscard(ctx)
 
-	resources := make(map[string]runtime.Object, len(config.Secrets)+len(config.ConfigMaps))
+	sealer, err := o.newSealer(config)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := &generatedResources{
+		objects: make(map[string]runtime.Object, len(config.Secrets)+len(config.ConfigMaps)),
+		files:   make(map[string][]byte),
+	}
 	for _, obj := range config.ConfigMaps {
-		cm, err := o.configMapFromConfig(obj)
+		directoriesData, err := o.directoriesData(obj.Directories)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		spec := obj.ConfigMapSpec
+		spec.Data = append(slices.Clone(spec.Data), directoriesData...)
+		cm, err := o.configMapFromConfig(spec)
+		if err != nil {
+			return nil, err
 		}
+		setMetadata(cm, config)
 
 		logger.V(7).Info("generated configmap", "name", cm.Name)
-		name := fmt.Sprintf("%s.configmap.yaml", obj.Name)
-		resources[name] = cm
+		if err := o.add(resources, cm, obj.Output, obj.FilenameTemplate); err != nil {
+			return nil, err
+		}
 	}
 
 	for _, obj := range config.Secrets {
-		sec, err := o.secretsFromConfig(obj)
+		if len(obj.Directories) > 0 && (obj.TLS != nil || obj.Docker != nil) {
+			return nil, fmt.Errorf("directories cannot be used in the tls or docker secret %q", obj.Name)
+		}
+
+		directoriesData, err := o.directoriesData(obj.Directories)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		spec := obj.SecretSpec
+		if len(directoriesData) > 0 {
+			spec.Data = append(slices.Clone(spec.Data), directoriesData...)
+		}
+		sec, caConfigMap, err := o.secretsFromConfig(ctx, spec)
+		if err != nil {
+			return nil, err
+		}
+		setMetadata(sec, config)
+
+		// the secrets saved outside Kubernetes are not sealed, their consumers cannot decrypt them
+		var resource runtime.Object = sec
+		if sealer != nil && isKubernetesOutput(obj.Output) {
+			if resource, err = sealer.seal(sec); err != nil {
+				return nil, err
+			}
 		}
 
 		logger.V(7).Info("generated secret", "name", sec.Name)
-		name := fmt.Sprintf("%s.secret.yaml", obj.Name)
-		resources[name] = sec
+		if err := o.add(resources, resource, obj.Output, obj.FilenameTemplate); err != nil {
+			return nil, err
+		}
+
+		if caConfigMap == nil {
+			continue
+		}
+		setMetadata(caConfigMap, config)
+
+		logger.V(7).Info("generated CA configmap", "name", caConfigMap.Name)
+		if err := o.add(resources, caConfigMap, obj.Output, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return resources, nil
+}
+
+// directoriesData return the data entries for the files found in directories, sorted by name
+func (o *Options) directoriesData(directories []string) ([]v1.Data, error) {
+	var data []v1.Data
+	for _, directory := range directories {
+		if !o.fSys.IsDir(directory) {
+			return nil, fmt.Errorf("directory %q not found", directory)
+		}
+
+		files, err := o.fSys.ReadDir(directory)
+		if err != nil {
+			return nil, err
+		}
+
+		slices.Sort(files)
+		for _, file := range files {
+			path := filepath.Join(directory, file)
+			if o.fSys.IsDir(path) {
+				continue
+			}
+			data = append(data, v1.Data{From: v1.DataFromFile, File: path})
+		}
+	}
+
+	return data, nil
+}
+
+// setMetadata set on obj the namespace and the labels shared by all the resources generated from config
+func setMetadata(obj metav1.Object, config *v2.GenerateConfiguration) {
+	if len(config.Namespace) > 0 {
+		obj.SetNamespace(config.Namespace)
 	}
 
+	if len(config.Labels) == 0 {
+		return
+	}
+
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string, len(config.Labels))
+	}
+	maps.Copy(labels, config.Labels)
+	obj.SetLabels(labels)
+}
+
+// writeResources save resources in outputPath using their keys as file names
+func (o *Options) writeResources(ctx context.Context, outputPath string, resources map[string]runtime.Object) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
 	for name, obj := range resources {
 		data, err := yaml.Marshal(obj)
 		if err != nil {
 			return err
 		}
 
-		path := filepath.Join(o.outputPath, name)
+		path := filepath.Join(outputPath, name)
 		logger.V(5).Info("writing resource", "path", path)
 		if err := o.fSys.WriteFile(path, data); err != nil {
 			return err
@@ -235,6 +678,63 @@ func (o *Options) generateResources(ctx context.Context, config *v1.GenerateConf
 	return nil
 }
 
+// writeFiles save the content of files in outputPath using their keys as file names
+func (o *Options) writeFiles(ctx context.Context, outputPath string, files map[string][]byte) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	for name, content := range files {
+		path := filepath.Join(outputPath, name)
+		logger.V(5).Info("writing file", "path", path)
+		if err := o.fSys.WriteFile(path, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// filenameForResource return the file name to use for saving a resource of kind and name, using overrideTemplate
+// if set or the template configured in the options
+func (o *Options) filenameForResource(kind, name, overrideTemplate string) (string, error) {
+	templateString := o.filenameTemplate
+	if len(overrideTemplate) > 0 {
+		templateString = overrideTemplate
+	}
+
+	tmpl, err := parseFilenameTemplate(templateString)
+	if err != nil {
+		return "", fmt.Errorf("invalid filename template for %s %q: %w", kind, name, err)
+	}
+
+	builder := new(strings.Builder)
+	data := struct {
+		Kind string
+		Name string
+	}{
+		Kind: kind,
+		Name: name,
+	}
+	if err := tmpl.Execute(builder, data); err != nil {
+		return "", fmt.Errorf("failed to render filename for %s %q: %w", kind, name, err)
+	}
+
+	filename := builder.String()
+	if len(filename) == 0 || filename == "." || filename == ".." || filename != filepath.Base(filename) {
+		return "", fmt.Errorf("invalid filename %q for %s %q", filename, kind, name)
+	}
+
+	return filename, nil
+}
+
+// parseFilenameTemplate return a parsed template for templateString, falling back to the default one if empty
+func parseFilenameTemplate(templateString string) (*template.Template, error) {
+	if len(templateString) == 0 {
+		templateString = defaultFilenameTemplate
+	}
+
+	return template.New("filename").Funcs(filenameTemplateFuncs).Option("missingkey=error").Parse(templateString)
+}
+
 func (o *Options) configMapFromConfig(spec v1.ConfigMapSpec) (*corev1.ConfigMap, error) {
 	configMap := &corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
@@ -250,28 +750,75 @@ func (o *Options) configMapFromConfig(spec v1.ConfigMapSpec) (*corev1.ConfigMap,
 	configMap.BinaryData = map[string][]byte{}
 
 	for _, data := range spec.Data {
-		switch data.From {
-		case v1.DataFromLiteral:
-			configMap.Data[data.Key] = data.Value
-		case v1.DataFromFile:
-			content, err := o.fSys.ReadFile(data.File)
-			if err != nil {
-				return nil, err
-			}
-			key := filepath.Base(data.File)
-			switch utf8.Valid(content) {
-			case true:
-				configMap.Data[key] = string(content)
-			default:
-				configMap.BinaryData[key] = content
-			}
+		key, content, found, err := o.dataContent(data)
+		if err != nil {
+			return nil, err
 		}
+		if !found {
+			continue
+		}
+
+		if o.isBinaryData(data, content) {
+			configMap.BinaryData[key] = content
+			continue
+		}
+		configMap.Data[key] = string(content)
 	}
 
 	return configMap, nil
 }
 
-func (o *Options) secretsFromConfig(spec v1.SecretSpec) (*corev1.Secret, error) {
+// dataContent return the key and the content described by data; found is false if the data is optional and its
+// file is missing, while its default value is used if set
+func (o *Options) dataContent(data v1.Data) (string, []byte, bool, error) {
+	switch data.From {
+	case v1.DataFromLiteral:
+		return data.Key, []byte(data.Value), true, nil
+	case v1.DataFromLiteralBase64:
+		content, err := decodeLiteralBase64(data)
+		if err != nil {
+			return "", nil, false, err
+		}
+		return data.Key, content, true, nil
+	case v1.DataFromFile:
+		key := filepath.Base(data.File)
+		if !o.fSys.Exists(data.File) {
+			switch {
+			case data.Default != nil:
+				return key, []byte(*data.Default), true, nil
+			case data.Optional:
+				return "", nil, false, nil
+			}
+		}
+
+		content, err := o.fSys.ReadFile(data.File)
+		if err != nil {
+			return "", nil, false, err
+		}
+		return key, content, true, nil
+	case v1.DataFromMerge:
+		content, err := o.mergeData(data)
+		if err != nil {
+			return "", nil, false, err
+		}
+		return data.Key, content, true, nil
+	}
+
+	return "", nil, false, nil
+}
+
+// decodeLiteralBase64 return the decoded value of a literal-base64 data, ignoring spaces and newlines
+func decodeLiteralBase64(data v1.Data) ([]byte, error) {
+	content, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(data.Value), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value for key %q: %w", v1.DataFromLiteralBase64, data.Key, err)
+	}
+	return content, nil
+}
+
+// secretsFromConfig return the Secret described by spec, and the ConfigMap containing the CA certificates if
+// requested by its tls configuration
+func (o *Options) secretsFromConfig(ctx context.Context, spec v1.SecretSpec) (*corev1.Secret, *corev1.ConfigMap, error) {
 	secret := &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: corev1.SchemeGroupVersion.String(),
@@ -286,72 +833,122 @@ func (o *Options) secretsFromConfig(spec v1.SecretSpec) (*corev1.Secret, error)
 		Data: map[string][]byte{},
 	}
 
+	rotation, err := rotationAnnotations(spec.Name, spec.Rotation, o.now())
+	if err != nil {
+		return nil, nil, err
+	}
+	maps.Copy(secret.Annotations, rotation)
+
+	var bundle *tlsBundle
+	var caConfigMap *corev1.ConfigMap
 	switch {
 	case spec.Data != nil:
 		secret.Type = corev1.SecretTypeOpaque
-		for _, data := range spec.Data {
-			switch data.From {
-			case v1.DataFromLiteral:
-				secret.Data[data.Key] = []byte(data.Value)
-			case v1.DataFromFile:
-				content, err := o.fSys.ReadFile(data.File)
-				if err != nil {
-					return nil, err
-				}
-				secret.Data[filepath.Base(data.File)] = content
-			}
+		if err := o.setSecretData(secret, spec); err != nil {
+			return nil, nil, err
 		}
 	case spec.Docker != nil:
 		secret.Type = corev1.SecretTypeDockerConfigJson
 		data, err := parseDocker(spec.Docker)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		secret.Data[corev1.DockerConfigJsonKey] = data
 	case spec.TLS != nil:
 		secret.Type = corev1.SecretTypeTLS
-		certData, certKey, err := o.parseTLS(spec.TLS)
-		if err != nil {
-			return nil, err
+		if bundle, err = o.parseTLS(spec.TLS); err != nil {
+			return nil, nil, err
 		}
-		secret.Data[corev1.TLSCertKey] = certData
-		secret.Data[corev1.TLSPrivateKeyKey] = certKey
-	}
+		secret.Data[corev1.TLSCertKey] = bundle.cert
+		secret.Data[corev1.TLSPrivateKeyKey] = bundle.key
 
-	return secret, nil
-}
+		if warning := expiryWarning(bundle.leaf, o.certExpiryWarningDays, o.now()); len(warning) > 0 {
+			logr.FromContextOrDiscard(ctx).Info(warning, "secret", spec.Name, "notAfter", bundle.leaf.NotAfter)
+		}
+
+		if len(spec.TLS.CAConfigMap) == 0 {
+			break
+		}
 
-func parseDocker(dockerConfig *v1.DockerConfig) ([]byte, error) {
-	dockerConfigAuth := dockerConfigEntry{
-		Username: dockerConfig.Username,
-		Password: dockerConfig.Password,
-		Email:    dockerConfig.Email,
-		Auth:     encodeDockerConfigFieldAuth(dockerConfig.Username, dockerConfig.Password),
+		if len(bundle.ca) == 0 {
+			return nil, nil, fmt.Errorf("no chain certificates found for the CA ConfigMap of secret %q", spec.Name)
+		}
+		caConfigMap = &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: corev1.SchemeGroupVersion.String(),
+				Kind:       "ConfigMap",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: spec.TLS.CAConfigMap,
+			},
+			Data: map[string]string{caConfigMapKey: string(bundle.ca)},
+		}
+	case spec.Keystore != nil:
+		secret.Type = corev1.SecretTypeOpaque
 	}
-	dockerConfigJSON := dockerConfigJSON{
-		Auths: map[string]dockerConfigEntry{dockerConfig.Server: dockerConfigAuth},
+
+	if spec.Keystore != nil {
+		key, data, err := o.keystoreData(spec.Name, spec.Keystore, bundle)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, inData := secret.Data[key]
+		_, inStringData := secret.StringData[key]
+		if inData || inStringData {
+			return nil, nil, fmt.Errorf("the keystore key %q is already used in secret %q", key, spec.Name)
+		}
+		secret.Data[key] = data
 	}
 
-	return json.Marshal(dockerConfigJSON)
+	return secret, caConfigMap, nil
 }
 
-// encodeDockerConfigFieldAuth returns base64 encoding of the username and password string
-func encodeDockerConfigFieldAuth(username, password string) string {
-	fieldValue := username + ":" + password
-	return base64.StdEncoding.EncodeToString([]byte(fieldValue))
+// now return the current time from the clock of the options, or from the system if not set
+func (o *Options) now() time.Time {
+	if o.clock == nil {
+		return time.Now()
+	}
+	return o.clock.Now()
 }
 
-func (o *Options) parseTLS(tlsConfig *v1.TLS) ([]byte, []byte, error) {
-	tlsCert, tlsKey, err := o.readTLSData(tlsConfig)
-	if err != nil {
-		return nil, nil, err
+// parseTLS read the certificate and private key from a PKCS#12 archive or from PEM data, where the certificate can
+// be a bundle containing its chain and the private key, and validate them
+func (o *Options) parseTLS(tlsConfig *v1.TLS) (*tlsBundle, error) {
+	var bundle *tlsBundle
+	switch {
+	case tlsConfig.PKCS12 != nil:
+		certificates, keyPEM, err := o.readPKCS12(tlsConfig.PKCS12)
+		if err != nil {
+			return nil, err
+		}
+
+		if bundle, err = newTLSBundle(nil, certificates, keyPEM); err != nil {
+			return nil, err
+		}
+	default:
+		tlsCert, tlsKey, err := o.readTLSData(tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		certificates, bundledKey, err := splitPEMBundle(tlsCert)
+		if err != nil {
+			return nil, err
+		}
+		if tlsKey == nil {
+			tlsKey = bundledKey
+		}
+
+		if bundle, err = newTLSBundle(tlsCert, certificates, tlsKey); err != nil {
+			return nil, err
+		}
 	}
 
-	if _, err := tls.X509KeyPair(tlsCert, tlsKey); err != nil {
-		return nil, nil, err
+	if _, err := tls.X509KeyPair(bundle.cert, bundle.key); err != nil {
+		return nil, err
 	}
 
-	return tlsCert, tlsKey, nil
+	return bundle, nil
 }
 
 func (o *Options) readTLSData(data *v1.TLS) ([]byte, []byte, error) {
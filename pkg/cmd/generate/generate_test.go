@@ -22,17 +22,22 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io/fs"
 	"math/big"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/mia-platform/mlp/v2/pkg/ignore"
+	"github.com/mia-platform/mlp/v2/pkg/outputdir"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
@@ -56,16 +61,18 @@ func TestOptions(t *testing.T) {
 
 	fSys := filesys.MakeEmptyDirInMemory()
 	expectedOpts := &Options{
-		configFiles: []string{"file.yaml"},
-		prefixes:    []string{"prefix"},
-		outputPath:  "output",
-		fSys:        fSys,
+		configFiles:      []string{"file.yaml"},
+		prefixes:         []string{"prefix"},
+		outputPath:       "output",
+		filenameTemplate: defaultFilenameTemplate,
+		fSys:             fSys,
 	}
 
 	flag := &Flags{
-		prefixes:    []string{"prefix"},
-		configFiles: []string{"file.yaml"},
-		outputPath:  "output",
+		prefixes:         []string{"prefix"},
+		configFiles:      []string{"file.yaml"},
+		outputPath:       "output",
+		filenameTemplate: defaultFilenameTemplate,
 	}
 
 	opts, err := flag.ToOptions(fSys)
@@ -76,6 +83,18 @@ func TestOptions(t *testing.T) {
 	opts.configFiles = []string{}
 
 	assert.ErrorContains(t, opts.Validate(), "at least one config file must be specified")
+
+	opts.configFiles = []string{"file.yaml"}
+	opts.filenameTemplate = "{{.Name"
+	assert.ErrorContains(t, opts.Validate(), "invalid filename template")
+	opts.filenameTemplate = defaultFilenameTemplate
+
+	opts.certExpiryWarningDays = -1
+	assert.ErrorContains(t, opts.Validate(), `the "cert-expiry-warning-days" flag cannot be negative`)
+	opts.certExpiryWarningDays = 0
+
+	opts.runID = "../run"
+	assert.ErrorContains(t, opts.Validate(), `invalid run id "../run"`)
 }
 
 func TestRun(t *testing.T) {
@@ -133,6 +152,79 @@ func TestRun(t *testing.T) {
 			},
 			expectedError: `tls: failed to find any PEM data in key input`,
 		},
+		"custom filename templates": {
+			options: &Options{
+				configFiles:      []string{"filename-template.yaml"},
+				outputPath:       "filename-template-output",
+				filenameTemplate: "{{.Kind}}-{{.Name}}.yaml",
+				fSys:             fSys,
+			},
+			expectedResultsPath: "template-output",
+		},
+		"creating resources from folder": {
+			options: &Options{
+				configFiles:      []string{"config-folder"},
+				outputPath:       "folder-output",
+				filenameTemplate: "{{.Kind}}-{{.Name}}.yaml",
+				fSys:             fSys,
+			},
+			expectedResultsPath: "template-output",
+		},
+		"creating resources with inventory": {
+			options: &Options{
+				configFiles:      []string{"filename-template.yaml"},
+				outputPath:       "inventory-output",
+				filenameTemplate: defaultFilenameTemplate,
+				inventory:        true,
+				fSys:             fSys,
+			},
+			expectedResultsPath: "expected-inventory-output",
+		},
+		"error with duplicated filenames": {
+			options: &Options{
+				configFiles:      []string{"duplicated-filename.yaml"},
+				outputPath:       "duplicated-filename-output",
+				filenameTemplate: "resource.yaml",
+				fSys:             fSys,
+			},
+			expectedError: `multiple resources are generated with the same file name: "resource.yaml"`,
+		},
+		"error with duplicated filenames in different configuration files": {
+			options: &Options{
+				configFiles:      []string{"filename-template.yaml", "config-folder"},
+				outputPath:       "duplicated-configurations-output",
+				filenameTemplate: defaultFilenameTemplate,
+				fSys:             fSys,
+			},
+			expectedError: `multiple resources are generated with the same file name: "custom-name.yml"`,
+		},
+		"error with filename outside output directory": {
+			options: &Options{
+				configFiles:      []string{"filename-template.yaml"},
+				outputPath:       "wrong-filename-output",
+				filenameTemplate: "../{{.Name}}.yaml",
+				fSys:             fSys,
+			},
+			expectedError: `invalid filename "../literal.yaml" for ConfigMap "literal"`,
+		},
+		"error with filename of the output directory": {
+			options: &Options{
+				configFiles:      []string{"filename-template.yaml"},
+				outputPath:       "wrong-filename-output",
+				filenameTemplate: ".",
+				fSys:             fSys,
+			},
+			expectedError: `invalid filename "." for ConfigMap "literal"`,
+		},
+		"error with filename of the parent directory": {
+			options: &Options{
+				configFiles:      []string{"filename-template.yaml"},
+				outputPath:       "wrong-filename-output",
+				filenameTemplate: "..",
+				fSys:             fSys,
+			},
+			expectedError: `invalid filename ".." for ConfigMap "literal"`,
+		},
 		"error reading file": {
 			options: &Options{
 				prefixes:    []string{"MLP_"},
@@ -159,6 +251,297 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestRunWithRunID(t *testing.T) {
+	fSys := testFilesys(t)
+	options := &Options{
+		configFiles:      []string{"filename-template.yaml"},
+		outputPath:       "run-output",
+		filenameTemplate: defaultFilenameTemplate,
+		inventory:        true,
+		runID:            "run-1",
+		fSys:             fSys,
+	}
+	require.NoError(t, options.Run(context.TODO()))
+
+	runPath := filepath.Join("run-output", "run-1")
+	data, err := fSys.ReadFile(filepath.Join(runPath, outputdir.IndexFileName))
+	require.NoError(t, err)
+	index := new(outputdir.Index)
+	require.NoError(t, json.Unmarshal(data, index))
+	assert.Equal(t, &outputdir.Index{
+		RunID:   "run-1",
+		Command: "generate",
+		Files: []string{
+			"custom-name.yml",
+			"eu.mia-platform.mlp.generated.configmap.yaml",
+			"literal.configmap.yaml",
+		},
+	}, index)
+
+	require.NoError(t, fSys.RemoveAll(filepath.Join(runPath, outputdir.IndexFileName)))
+	testStructure(t, fSys, runPath, "expected-inventory-output")
+}
+
+func TestRunToObjects(t *testing.T) {
+	t.Setenv("MLP_DOCKER_PASSWORD", "password")
+
+	fSys := testFilesys(t)
+	options := NewOptions([]string{"filename-template.yaml", "duplicated-filename.yaml"}, []string{"MLP_"}, fSys)
+
+	objects, err := options.RunToObjects(context.TODO())
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		names = append(names, obj.GetKind()+"/"+obj.GetName())
+		_, found := obj.Object["metadata"].(map[string]interface{})["creationTimestamp"]
+		assert.False(t, found)
+	}
+	assert.Equal(t, []string{"Secret/opaque", "ConfigMap/first", "ConfigMap/literal", "ConfigMap/second"}, names)
+	assert.False(t, fSys.Exists("interpolated-files"), "no file must be written")
+
+	options.inventory = true
+	objects, err = options.RunToObjects(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, objects, 5)
+	inventoryIndex := slices.IndexFunc(objects, func(obj *unstructured.Unstructured) bool {
+		return obj.GetName() == "eu.mia-platform.mlp.generated"
+	})
+	require.NotEqual(t, -1, inventoryIndex)
+	data, _, err := unstructured.NestedStringMap(objects[inventoryIndex].Object, "data")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"_opaque__Secret":     "",
+		"_literal__ConfigMap": "",
+		"_first__ConfigMap":   "",
+		"_second__ConfigMap":  "",
+	}, data)
+
+	options = NewOptions([]string{"missing-file.yaml"}, nil, fSys)
+	_, err = options.RunToObjects(context.TODO())
+	assert.ErrorContains(t, err, `'missing' doesn't exist`)
+}
+
+func TestLiteralBase64Data(t *testing.T) {
+	t.Setenv("MLP_ENCODED_TEXT", base64.StdEncoding.EncodeToString([]byte("line one\nline two")))
+	t.Setenv("MLP_ENCODED_BINARY", base64.StdEncoding.EncodeToString([]byte{0xff, 0xfe, 0x00}))
+	t.Setenv("MLP_ENCODED_INVALID", "not base64!")
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("encoded.yaml", []byte(`config-maps:
+- name: encoded
+  data:
+  - from: literal-base64
+    key: text
+    value: "{{ENCODED_TEXT}}"
+  - from: literal-base64
+    key: binary
+    value: "{{ENCODED_BINARY}}"
+  - from: literal
+    key: decoded
+    value: "{{ENCODED_TEXT|b64decode}}"
+secrets:
+- name: encoded
+  when: always
+  data:
+  - from: literal-base64
+    key: text
+    value: "{{ENCODED_TEXT}}"
+`)))
+	require.NoError(t, fSys.WriteFile("invalid-literal.yaml", []byte(`config-maps:
+- name: invalid
+  data:
+  - from: literal-base64
+    key: invalid
+    value: "{{ENCODED_INVALID}}"
+`)))
+	require.NoError(t, fSys.WriteFile("invalid-sequence.yaml", []byte(`config-maps:
+- name: invalid
+  data:
+  - from: literal
+    key: invalid
+    value: "{{ENCODED_INVALID|b64decode}}"
+`)))
+
+	options := NewOptions([]string{"encoded.yaml"}, []string{"MLP_"}, fSys)
+	objects, err := options.RunToObjects(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+
+	data, _, err := unstructured.NestedStringMap(objects[0].Object, "data")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"text":    "line one\nline two",
+		"decoded": "line one\nline two",
+	}, data)
+	binaryData, _, err := unstructured.NestedStringMap(objects[0].Object, "binaryData")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"binary": "//4A"}, binaryData)
+
+	data, _, err = unstructured.NestedStringMap(objects[1].Object, "data")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"text": base64.StdEncoding.EncodeToString([]byte("line one\nline two")),
+	}, data)
+
+	options = NewOptions([]string{"invalid-literal.yaml"}, []string{"MLP_"}, fSys)
+	_, err = options.RunToObjects(context.TODO())
+	assert.ErrorContains(t, err, `invalid literal-base64 value for key "invalid"`)
+
+	options = NewOptions([]string{"invalid-sequence.yaml"}, []string{"MLP_"}, fSys)
+	_, err = options.RunToObjects(context.TODO())
+	assert.ErrorContains(t, err, `environment variable "ENCODED_INVALID" is not a valid base64 value`)
+}
+
+func TestConfigurationVersions(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.MkdirAll(filepath.Join("settings", "nested")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("settings", "b.properties"), []byte("b=2")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("settings", "a.properties"), []byte("a=1")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("settings", "nested", "c.properties"), []byte("c=3")))
+	require.NoError(t, fSys.WriteFile("v1.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v1
+config-maps:
+- name: legacy
+  data:
+  - from: literal
+    key: key
+    value: value
+`)))
+	require.NoError(t, fSys.WriteFile("v2.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v2
+kind: GenerateConfiguration
+namespace: production
+labels:
+  app: example
+configMaps:
+- name: settings
+  data:
+  - from: literal
+    key: key
+    value: value
+  directories:
+  - settings
+secrets:
+- name: settings
+  when: once
+  directories:
+  - settings
+`)))
+	require.NoError(t, fSys.WriteFile("unknown-version.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v3`)))
+	require.NoError(t, fSys.WriteFile("unknown-kind.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v2
+kind: Configuration
+`)))
+	require.NoError(t, fSys.WriteFile("unknown-field.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v2
+config-maps:
+- name: legacy
+`)))
+	require.NoError(t, fSys.WriteFile("tls-directories.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v2
+secrets:
+- name: tls
+  tls:
+    cert:
+      from: literal
+      value: value
+  directories:
+  - settings
+`)))
+	require.NoError(t, fSys.WriteFile("missing-directory.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v2
+configMaps:
+- name: missing
+  directories:
+  - missing
+`)))
+
+	tests := map[string]struct {
+		configFile      string
+		expectedObjects []map[string]interface{}
+		expectedError   string
+	}{
+		"v1 configuration": {
+			configFile: "v1.yaml",
+			expectedObjects: []map[string]interface{}{
+				{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata":   map[string]interface{}{"name": "legacy"},
+					"data":       map[string]interface{}{"key": "value"},
+				},
+			},
+		},
+		"v2 configuration": {
+			configFile: "v2.yaml",
+			expectedObjects: []map[string]interface{}{
+				{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata": map[string]interface{}{
+						"name":      "settings",
+						"namespace": "production",
+						"labels":    map[string]interface{}{"app": "example"},
+					},
+					"data": map[string]interface{}{"key": "value", "a.properties": "a=1", "b.properties": "b=2"},
+				},
+				{
+					"apiVersion": "v1",
+					"kind":       "Secret",
+					"metadata": map[string]interface{}{
+						"name":        "settings",
+						"namespace":   "production",
+						"labels":      map[string]interface{}{"app": "example"},
+						"annotations": map[string]interface{}{"mia-platform.eu/deploy": "once"},
+					},
+					"type": "Opaque",
+					"data": map[string]interface{}{
+						"a.properties": base64.StdEncoding.EncodeToString([]byte("a=1")),
+						"b.properties": base64.StdEncoding.EncodeToString([]byte("b=2")),
+					},
+				},
+			},
+		},
+		"unknown apiVersion": {
+			configFile:    "unknown-version.yaml",
+			expectedError: `unsupported apiVersion "mlp.mia-platform.eu/v3" in unknown-version.yaml`,
+		},
+		"unknown kind": {
+			configFile:    "unknown-kind.yaml",
+			expectedError: `unsupported kind "Configuration" in unknown-kind.yaml`,
+		},
+		"v1 field in v2 configuration": {
+			configFile:    "unknown-field.yaml",
+			expectedError: `unknown field "config-maps"`,
+		},
+		"directories in tls secret": {
+			configFile:    "tls-directories.yaml",
+			expectedError: `directories cannot be used in the tls or docker secret "tls"`,
+		},
+		"missing directory": {
+			configFile:    "missing-directory.yaml",
+			expectedError: `directory "missing" not found`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options := NewOptions([]string{test.configFile}, nil, fSys)
+			objects, err := options.RunToObjects(context.TODO())
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			actual := make([]map[string]interface{}, 0, len(objects))
+			for _, obj := range objects {
+				actual = append(actual, obj.Object)
+			}
+			assert.Equal(t, test.expectedObjects, actual)
+		})
+	}
+}
+
 func testStructure(t *testing.T, fSys filesys.FileSystem, pathToTest, expectationPath string) {
 	t.Helper()
 
@@ -233,6 +616,19 @@ func testFilesys(t *testing.T) filesys.FileSystem {
 	require.NoError(t, fSys.WriteFile(filepath.Join("output", "files.configmap.yaml"), []byte(fileConfigMap)))
 	require.NoError(t, fSys.WriteFile(filepath.Join("output", "literal.configmap.yaml"), []byte(literalConfigMap)))
 	require.NoError(t, fSys.WriteFile("binary", []byte{0xff, 0xfd}))
+	require.NoError(t, fSys.WriteFile("filename-template.yaml", []byte(filenameTemplateConfiguration)))
+	require.NoError(t, fSys.WriteFile("duplicated-filename.yaml", []byte(duplicatedFilenameConfiguration)))
+	require.NoError(t, fSys.MkdirAll("template-output"))
+	require.NoError(t, fSys.WriteFile(filepath.Join("template-output", "ConfigMap-literal.yaml"), []byte(literalConfigMap)))
+	require.NoError(t, fSys.WriteFile(filepath.Join("template-output", "custom-name.yml"), []byte(opaqueLiteralSecret)))
+	require.NoError(t, fSys.MkdirAll("expected-inventory-output"))
+	require.NoError(t, fSys.WriteFile(filepath.Join("expected-inventory-output", "literal.configmap.yaml"), []byte(literalConfigMap)))
+	require.NoError(t, fSys.WriteFile(filepath.Join("expected-inventory-output", "custom-name.yml"), []byte(opaqueLiteralSecret)))
+	require.NoError(t, fSys.WriteFile(filepath.Join("expected-inventory-output", "eu.mia-platform.mlp.generated.configmap.yaml"), []byte(generatedInventoryConfigMap)))
+	require.NoError(t, fSys.WriteFile(filepath.Join("config-folder", ignore.FileName), []byte("broken-*.yaml\n")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("config-folder", "filename-template.yaml"), []byte(filenameTemplateConfiguration)))
+	require.NoError(t, fSys.WriteFile(filepath.Join("config-folder", "broken-certificates.yaml"), []byte(brokenCertificates)))
+	require.NoError(t, fSys.WriteFile(filepath.Join("config-folder", "README.md"), []byte("# configurations")))
 
 	return fSys
 }
@@ -407,6 +803,47 @@ config-maps:
     cert:
       from: "literal"
       value: "{{CERTIFICATE}}"
+`
+	opaqueLiteralSecret = `apiVersion: v1
+data:
+  key: dmFsdWU=
+kind: Secret
+metadata:
+  annotations:
+    mia-platform.eu/deploy: always
+  creationTimestamp: null
+  name: opaque
+type: Opaque
+`
+	filenameTemplateConfiguration = `secrets:
+- name: "opaque"
+  when: "always"
+  filenameTemplate: "custom-name.yml"
+  data:
+  - from: "literal"
+    key: key
+    value: value
+config-maps:
+- name: "literal"
+  data:
+  - from: "literal"
+    key: key
+    value: value
+  - from: "literal"
+    key: otherKey
+    value: value
+`
+	duplicatedFilenameConfiguration = `config-maps:
+- name: "first"
+  data:
+  - from: "literal"
+    key: key
+    value: value
+- name: "second"
+  data:
+  - from: "literal"
+    key: key
+    value: value
 `
 	missingFile = `config-maps:
 - name: "files"
@@ -414,3 +851,15 @@ config-maps:
   - from: "file"
     file: "missing"`
 )
+
+const generatedInventoryConfigMap = `apiVersion: v1
+data:
+  _literal__ConfigMap: ""
+  _opaque__Secret: ""
+kind: ConfigMap
+metadata:
+  creationTimestamp: null
+  labels:
+    mia-platform.eu/generated-inventory: "true"
+  name: eu.mia-platform.mlp.generated
+`
@@ -0,0 +1,156 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1" //nolint:gosec // sha1 is mandated by the JKS format
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	jksMagic           = 0xFEEDFEED
+	jksVersion         = 2
+	jksPrivateKeyTag   = 1
+	jksCertificateType = "X.509"
+	// jksIntegritySalt is the fixed string mixed with the password in the integrity digest of the keystore
+	jksIntegritySalt = "Mighty Aphrodite"
+)
+
+var (
+	// jksKeyProtectorOID is the identifier of the proprietary algorithm used for protecting the private keys
+	jksKeyProtectorOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+)
+
+// encryptedPrivateKeyInfo is the PKCS#8 structure containing an encrypted private key
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// encodeJKS return a JKS keystore containing a single private key entry called alias, with key and its chain of
+// certificates starting from the leaf, protected with password; random is used for generating the salt of the key
+func encodeJKS(random io.Reader, alias string, key crypto.Signer, certificates []*x509.Certificate, password string) ([]byte, error) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	passwordBytes := jksPassword(password)
+	protectedKey, err := jksProtectKey(random, keyDER, passwordBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := new(bytes.Buffer)
+	write := func(value any) { _ = binary.Write(buffer, binary.BigEndian, value) }
+	write(uint32(jksMagic))
+	write(uint32(jksVersion))
+	write(uint32(1))
+
+	write(uint32(jksPrivateKeyTag))
+	// the aliases are case insensitive and saved in lower case
+	jksWriteUTF(buffer, strings.ToLower(alias))
+	// the creation date is the start of the certificate validity, for keeping the keystore stable between runs
+	write(certificates[0].NotBefore.UnixMilli())
+	write(uint32(len(protectedKey)))
+	buffer.Write(protectedKey)
+	write(uint32(len(certificates)))
+	for _, certificate := range certificates {
+		jksWriteUTF(buffer, jksCertificateType)
+		write(uint32(len(certificate.Raw)))
+		buffer.Write(certificate.Raw)
+	}
+
+	digest := sha1.New() //nolint:gosec // sha1 is mandated by the JKS format
+	digest.Write(passwordBytes)
+	digest.Write([]byte(jksIntegritySalt))
+	digest.Write(buffer.Bytes())
+	buffer.Write(digest.Sum(nil))
+	return buffer.Bytes(), nil
+}
+
+// jksProtectKey encrypt keyDER with the JKS key protector algorithm: the key is xored with a stream of sha1 digests
+// of the password chained starting from a random salt, and followed by the digest of the password and the clear key
+// for checking its integrity
+func jksProtectKey(random io.Reader, keyDER, passwordBytes []byte) ([]byte, error) {
+	salt := make([]byte, sha1.Size)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	encrypted := make([]byte, 0, sha1.Size+len(keyDER)+sha1.Size)
+	encrypted = append(encrypted, salt...)
+	digest := salt
+	for offset := 0; offset < len(keyDER); offset += sha1.Size {
+		hash := sha1.New() //nolint:gosec // sha1 is mandated by the JKS format
+		hash.Write(passwordBytes)
+		hash.Write(digest)
+		digest = hash.Sum(nil)
+		for idx := 0; idx < sha1.Size && offset+idx < len(keyDER); idx++ {
+			encrypted = append(encrypted, keyDER[offset+idx]^digest[idx])
+		}
+	}
+
+	check := sha1.New() //nolint:gosec // sha1 is mandated by the JKS format
+	check.Write(passwordBytes)
+	check.Write(keyDER)
+	encrypted = append(encrypted, check.Sum(nil)...)
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  jksKeyProtectorOID,
+			Parameters: asn1.NullRawValue,
+		},
+		EncryptedData: encrypted,
+	})
+}
+
+// jksPassword return the bytes of password as used by Java, two big endian bytes for every UTF-16 code unit
+func jksPassword(password string) []byte {
+	units := utf16.Encode([]rune(password))
+	data := make([]byte, 0, len(units)*2)
+	for _, unit := range units {
+		data = binary.BigEndian.AppendUint16(data, unit)
+	}
+	return data
+}
+
+// jksWriteUTF write value in the modified UTF-8 encoding of Java DataOutput, prefixed by its length
+func jksWriteUTF(buffer *bytes.Buffer, value string) {
+	var data []byte
+	for _, unit := range utf16.Encode([]rune(value)) {
+		switch {
+		case unit >= 0x01 && unit <= 0x7F:
+			data = append(data, byte(unit))
+		case unit <= 0x7FF:
+			data = append(data, byte(0xC0|(unit>>6)), byte(0x80|(unit&0x3F)))
+		default:
+			data = append(data, byte(0xE0|(unit>>12)), byte(0x80|((unit>>6)&0x3F)), byte(0x80|(unit&0x3F)))
+		}
+	}
+
+	_ = binary.Write(buffer, binary.BigEndian, uint16(len(data)))
+	buffer.Write(data)
+}
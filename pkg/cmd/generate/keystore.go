@@ -0,0 +1,150 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	defaultPKCS12DataKey = "keystore.p12"
+	defaultJKSDataKey    = "keystore.jks"
+)
+
+// keystoreData return the key and the content of the keystore described by config for the secret name; the
+// certificate and the private key are read from config or, when they are not set, taken from tlsBundle
+func (o *Options) keystoreData(name string, config *v1.Keystore, bundle *tlsBundle) (string, []byte, error) {
+	format := config.Format
+	if len(format) == 0 {
+		format = v1.KeystoreFormatPKCS12
+	}
+
+	dataKey := config.DataKey
+	switch format {
+	case v1.KeystoreFormatPKCS12:
+		if len(dataKey) == 0 {
+			dataKey = defaultPKCS12DataKey
+		}
+	case v1.KeystoreFormatJKS:
+		if len(dataKey) == 0 {
+			dataKey = defaultJKSDataKey
+		}
+	default:
+		return "", nil, fmt.Errorf("invalid keystore format %q for secret %q: must be %q or %q", format, name, v1.KeystoreFormatPKCS12, v1.KeystoreFormatJKS)
+	}
+
+	if len(config.Password) == 0 {
+		return "", nil, fmt.Errorf("the keystore of secret %q requires a password", name)
+	}
+
+	if config.Cert != nil || config.Key != nil {
+		var err error
+		if bundle, err = o.parseTLS(&v1.TLS{Cert: config.Cert, Key: config.Key}); err != nil {
+			return "", nil, fmt.Errorf("reading the keystore certificate of secret %q: %w", name, err)
+		}
+	}
+	if bundle == nil {
+		return "", nil, fmt.Errorf("the keystore of secret %q must set its cert and key or be used with the tls block", name)
+	}
+
+	certificates, err := keystoreCertificates(bundle.cert)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading the keystore certificate of secret %q: %w", name, err)
+	}
+	key, err := parsePrivateKeyPEM(bundle.key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	alias := config.Alias
+	if len(alias) == 0 {
+		alias = name
+	}
+
+	// the salts are derived from the content of the keystore, so the same inputs generate the same keystore
+	// and the secret doesn't change between different runs
+	random := newSeededReader([]byte(format), []byte(alias), []byte(config.Password), bundle.cert, bundle.key)
+	var data []byte
+	switch format {
+	case v1.KeystoreFormatJKS:
+		data, err = encodeJKS(random, alias, key, certificates, config.Password)
+	default:
+		data, err = pkcs12.Modern.WithRand(random).Encode(key, certificates[0], certificates[1:], config.Password)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("encoding the keystore of secret %q: %w", name, err)
+	}
+
+	return dataKey, data, nil
+}
+
+// seededReader is an endless stream of pseudo random bytes derived from a seed
+type seededReader struct {
+	seed    [sha256.Size]byte
+	counter uint64
+	buffer  []byte
+}
+
+var _ io.Reader = &seededReader{}
+
+// newSeededReader return a seededReader using the hash of parts as seed
+func newSeededReader(parts ...[]byte) *seededReader {
+	hash := sha256.New()
+	for _, part := range parts {
+		_ = binary.Write(hash, binary.BigEndian, uint64(len(part)))
+		hash.Write(part)
+	}
+
+	reader := new(seededReader)
+	copy(reader.seed[:], hash.Sum(nil))
+	return reader
+}
+
+func (r *seededReader) Read(p []byte) (int, error) {
+	for read := 0; read < len(p); {
+		if len(r.buffer) == 0 {
+			hash := sha256.New()
+			hash.Write(r.seed[:])
+			_ = binary.Write(hash, binary.BigEndian, r.counter)
+			r.buffer = hash.Sum(nil)
+			r.counter++
+		}
+
+		copied := copy(p[read:], r.buffer)
+		r.buffer = r.buffer[copied:]
+		read += copied
+	}
+	return len(p), nil
+}
+
+// keystoreCertificates return the certificates parsed from the PEM data of the keystore, with the leaf first
+func keystoreCertificates(data []byte) ([]*x509.Certificate, error) {
+	certificates, _, err := splitPEMBundle(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return certificates, nil
+}
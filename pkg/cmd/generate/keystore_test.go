@@ -0,0 +1,233 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" //nolint:gosec // sha1 is mandated by the JKS format
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestKeystoreFromTLS(t *testing.T) {
+	t.Parallel()
+
+	chain := generateChain(t, time.Now().AddDate(1, 0, 0))
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("chain.pem", encodeCertificates([]*x509.Certificate{chain.leaf, chain.intermediate, chain.root})))
+	require.NoError(t, fSys.WriteFile("key.pem", chain.keyPEM))
+
+	options := &Options{fSys: fSys, certExpiryWarningDays: certExpiryWarningDaysDefaultValue}
+	spec := v1.SecretSpec{
+		Name: "api-tls",
+		TLS: &v1.TLS{
+			Cert: &v1.TLSData{From: v1.DataFromFile, File: "chain.pem"},
+			Key:  &v1.TLSData{From: v1.DataFromFile, File: "key.pem"},
+		},
+		Keystore: &v1.Keystore{Password: "changeit"},
+	}
+
+	secret, _, err := options.secretsFromConfig(context.TODO(), spec)
+	require.NoError(t, err)
+	assert.Equal(t, corev1.SecretTypeTLS, secret.Type)
+	assert.Contains(t, secret.Data, corev1.TLSCertKey)
+	require.Contains(t, secret.Data, defaultPKCS12DataKey)
+
+	key, leaf, caCerts, err := pkcs12.DecodeChain(secret.Data[defaultPKCS12DataKey], "changeit")
+	require.NoError(t, err)
+	assert.Equal(t, chain.key, key)
+	assert.True(t, chain.leaf.Equal(leaf))
+	require.Len(t, caCerts, 2)
+	assert.True(t, chain.intermediate.Equal(caCerts[0]))
+
+	again, _, err := options.secretsFromConfig(context.TODO(), spec)
+	require.NoError(t, err)
+	assert.Equal(t, secret.Data[defaultPKCS12DataKey], again.Data[defaultPKCS12DataKey], "the keystore must be stable between runs")
+}
+
+func TestJKSKeystore(t *testing.T) {
+	t.Parallel()
+
+	chain := generateChain(t, time.Now().AddDate(1, 0, 0))
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("chain.pem", encodeCertificates([]*x509.Certificate{chain.leaf, chain.intermediate})))
+	require.NoError(t, fSys.WriteFile("key.pem", chain.keyPEM))
+
+	options := &Options{fSys: fSys}
+	secret, _, err := options.secretsFromConfig(context.TODO(), v1.SecretSpec{
+		Name: "api-keystore",
+		Keystore: &v1.Keystore{
+			Format:   v1.KeystoreFormatJKS,
+			DataKey:  "server.jks",
+			Alias:    "Server",
+			Password: "pässword",
+			Cert:     &v1.TLSData{From: v1.DataFromFile, File: "chain.pem"},
+			Key:      &v1.TLSData{From: v1.DataFromFile, File: "key.pem"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, corev1.SecretTypeOpaque, secret.Type)
+	require.Contains(t, secret.Data, "server.jks")
+
+	alias, keyDER, certificates := decodeJKS(t, secret.Data["server.jks"], "pässword")
+	assert.Equal(t, "server", alias)
+	expectedKey, err := x509.MarshalPKCS8PrivateKey(chain.key)
+	require.NoError(t, err)
+	assert.Equal(t, expectedKey, keyDER)
+	assert.Equal(t, [][]byte{chain.leaf.Raw, chain.intermediate.Raw}, certificates)
+}
+
+func TestKeystoreErrors(t *testing.T) {
+	t.Parallel()
+
+	chain := generateChain(t, time.Now().AddDate(1, 0, 0))
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("leaf.pem", encodeCertificates([]*x509.Certificate{chain.leaf})))
+	require.NoError(t, fSys.WriteFile("key.pem", chain.keyPEM))
+	cert := &v1.TLSData{From: v1.DataFromFile, File: "leaf.pem"}
+	key := &v1.TLSData{From: v1.DataFromFile, File: "key.pem"}
+
+	tests := map[string]struct {
+		spec          v1.SecretSpec
+		expectedError string
+	}{
+		"invalid format": {
+			spec:          v1.SecretSpec{Name: "keystore", Keystore: &v1.Keystore{Format: "bks", Password: "changeit", Cert: cert, Key: key}},
+			expectedError: `invalid keystore format "bks" for secret "keystore": must be "pkcs12" or "jks"`,
+		},
+		"missing password": {
+			spec:          v1.SecretSpec{Name: "keystore", Keystore: &v1.Keystore{Cert: cert, Key: key}},
+			expectedError: `the keystore of secret "keystore" requires a password`,
+		},
+		"missing certificate": {
+			spec:          v1.SecretSpec{Name: "keystore", Keystore: &v1.Keystore{Password: "changeit"}},
+			expectedError: `the keystore of secret "keystore" must set its cert and key or be used with the tls block`,
+		},
+		"invalid certificate": {
+			spec:          v1.SecretSpec{Name: "keystore", Keystore: &v1.Keystore{Password: "changeit", Cert: key, Key: key}},
+			expectedError: `reading the keystore certificate of secret "keystore"`,
+		},
+		"key already used": {
+			spec: v1.SecretSpec{
+				Name:     "keystore",
+				Data:     []v1.Data{{From: v1.DataFromLiteral, Key: defaultPKCS12DataKey, Value: "value"}},
+				Keystore: &v1.Keystore{Password: "changeit", Cert: cert, Key: key},
+			},
+			expectedError: `the keystore key "keystore.p12" is already used in secret "keystore"`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := &Options{fSys: fSys}
+			_, _, err := options.secretsFromConfig(context.TODO(), test.spec)
+			assert.ErrorContains(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestJKSWriteUTF(t *testing.T) {
+	t.Parallel()
+
+	buffer := new(bytes.Buffer)
+	jksWriteUTF(buffer, "aé€\x00")
+	assert.Equal(t, []byte{0x00, 0x08, 'a', 0xC3, 0xA9, 0xE2, 0x82, 0xAC, 0xC0, 0x80}, buffer.Bytes())
+}
+
+// decodeJKS return the alias, the private key and the certificates of the single entry of a JKS keystore, checking
+// its integrity digest and the one of the protected key
+func decodeJKS(t *testing.T, data []byte, password string) (string, []byte, [][]byte) {
+	t.Helper()
+
+	passwordBytes := jksPassword(password)
+	content, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	hash := sha1.New() //nolint:gosec // sha1 is mandated by the JKS format
+	hash.Write(passwordBytes)
+	hash.Write([]byte(jksIntegritySalt))
+	hash.Write(content)
+	require.Equal(t, hash.Sum(nil), digest, "invalid keystore integrity digest")
+
+	reader := bytes.NewReader(content)
+	readUint32 := func() uint32 {
+		var value uint32
+		require.NoError(t, binary.Read(reader, binary.BigEndian, &value))
+		return value
+	}
+	readBytes := func(length int) []byte {
+		value := make([]byte, length)
+		_, err := io.ReadFull(reader, value)
+		require.NoError(t, err)
+		return value
+	}
+	readUTF := func() string {
+		var length uint16
+		require.NoError(t, binary.Read(reader, binary.BigEndian, &length))
+		return string(readBytes(int(length)))
+	}
+
+	require.Equal(t, uint32(jksMagic), readUint32())
+	require.Equal(t, uint32(jksVersion), readUint32())
+	require.Equal(t, uint32(1), readUint32())
+	require.Equal(t, uint32(jksPrivateKeyTag), readUint32())
+	alias := readUTF()
+	readBytes(8)
+
+	var keyInfo encryptedPrivateKeyInfo
+	_, err := asn1.Unmarshal(readBytes(int(readUint32())), &keyInfo)
+	require.NoError(t, err)
+	require.True(t, keyInfo.Algorithm.Algorithm.Equal(jksKeyProtectorOID))
+
+	protected := keyInfo.EncryptedData
+	salt, encrypted, check := protected[:sha1.Size], protected[sha1.Size:len(protected)-sha1.Size], protected[len(protected)-sha1.Size:]
+	keyDER := make([]byte, 0, len(encrypted))
+	digest = salt
+	for offset := 0; offset < len(encrypted); offset += sha1.Size {
+		hash := sha1.New() //nolint:gosec // sha1 is mandated by the JKS format
+		hash.Write(passwordBytes)
+		hash.Write(digest)
+		digest = hash.Sum(nil)
+		for idx := 0; idx < sha1.Size && offset+idx < len(encrypted); idx++ {
+			keyDER = append(keyDER, encrypted[offset+idx]^digest[idx])
+		}
+	}
+	keyHash := sha1.New() //nolint:gosec // sha1 is mandated by the JKS format
+	keyHash.Write(passwordBytes)
+	keyHash.Write(keyDER)
+	require.Equal(t, keyHash.Sum(nil), check, "invalid private key digest")
+
+	var certificates [][]byte
+	for count := readUint32(); count > 0; count-- {
+		require.Equal(t, jksCertificateType, readUTF())
+		certificates = append(certificates, readBytes(int(readUint32())))
+	}
+	require.Zero(t, reader.Len())
+
+	return alias, keyDER, certificates
+}
@@ -0,0 +1,127 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+)
+
+const (
+	// defaultMergeSeparator is used between the fragments if the merge directive doesn't set one
+	defaultMergeSeparator = "\n"
+)
+
+// mergeFragment is a single piece of content that will be joined with the others
+type mergeFragment struct {
+	name    string
+	content []byte
+}
+
+// mergeData return the content obtained joining all the sources of the merge directive of data
+func (o *Options) mergeData(data v1.Data) ([]byte, error) {
+	if len(data.Key) == 0 {
+		return nil, fmt.Errorf("the key is required for merging data")
+	}
+
+	merge := data.Merge
+	if merge == nil || len(merge.Sources) == 0 {
+		return nil, fmt.Errorf("no sources to merge for key %q", data.Key)
+	}
+
+	fragments := make([]mergeFragment, 0, len(merge.Sources))
+	for _, source := range merge.Sources {
+		sourceFragments, err := o.mergeFragments(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge key %q: %w", data.Key, err)
+		}
+		fragments = append(fragments, sourceFragments...)
+	}
+
+	switch merge.Order {
+	case "", v1.MergeOrderDeclared:
+	case v1.MergeOrderSorted:
+		slices.SortStableFunc(fragments, func(a, b mergeFragment) int {
+			return strings.Compare(a.name, b.name)
+		})
+	default:
+		return nil, fmt.Errorf("unknown merge order for key %q: %s", data.Key, merge.Order)
+	}
+
+	separator := defaultMergeSeparator
+	if merge.Separator != nil {
+		separator = *merge.Separator
+	}
+
+	contents := make([][]byte, 0, len(fragments))
+	for _, fragment := range fragments {
+		contents = append(contents, fragment.content)
+	}
+	return bytes.Join(contents, []byte(separator)), nil
+}
+
+// mergeFragments return the fragments of a single merge source, a file source containing a glob pattern is
+// expanded and its matches are returned in lexical order
+func (o *Options) mergeFragments(source v1.Data) ([]mergeFragment, error) {
+	switch source.From {
+	case v1.DataFromLiteral:
+		return []mergeFragment{{name: source.Key, content: []byte(source.Value)}}, nil
+	case v1.DataFromLiteralBase64:
+		content, err := decodeLiteralBase64(source)
+		if err != nil {
+			return nil, err
+		}
+		return []mergeFragment{{name: source.Key, content: content}}, nil
+	case v1.DataFromFile:
+		if !hasGlobMeta(source.File) {
+			content, err := o.fSys.ReadFile(source.File)
+			if err != nil {
+				return nil, err
+			}
+			return []mergeFragment{{name: source.File, content: content}}, nil
+		}
+
+		paths, err := o.fSys.Glob(source.File)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no files found for %q", source.File)
+		}
+
+		slices.Sort(paths)
+		fragments := make([]mergeFragment, 0, len(paths))
+		for _, path := range paths {
+			content, err := o.fSys.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			fragments = append(fragments, mergeFragment{name: path, content: content})
+		}
+		return fragments, nil
+	default:
+		return nil, fmt.Errorf("unknown data source: %s", source.From)
+	}
+}
+
+// hasGlobMeta return true if path contains any of the special characters recognized by filepath.Match
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
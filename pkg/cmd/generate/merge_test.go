@@ -0,0 +1,172 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestMergeData(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile(filepath.Join("/conf.d", "20-server.conf"), []byte("server {}")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("/conf.d", "10-upstream.conf"), []byte("upstream {}")))
+	require.NoError(t, fSys.WriteFile("base.conf", []byte("worker_processes 1;")))
+
+	tests := map[string]struct {
+		data            v1.Data
+		expectedContent string
+		expectedError   string
+	}{
+		"literal and file sources in declared order": {
+			data: v1.Data{
+				From: v1.DataFromMerge,
+				Key:  "nginx.conf",
+				Merge: &v1.Merge{
+					Sources: []v1.Data{
+						{From: v1.DataFromLiteral, Value: "# generated"},
+						{From: v1.DataFromFile, File: "base.conf"},
+					},
+				},
+			},
+			expectedContent: "# generated\nworker_processes 1;",
+		},
+		"glob pattern with custom separator": {
+			data: v1.Data{
+				From: v1.DataFromMerge,
+				Key:  "nginx.conf",
+				Merge: &v1.Merge{
+					Sources: []v1.Data{
+						{From: v1.DataFromFile, File: "base.conf"},
+						{From: v1.DataFromFile, File: filepath.Join("/conf.d", "*.conf")},
+					},
+					Separator: ptr.To("\n---\n"),
+				},
+			},
+			expectedContent: "worker_processes 1;\n---\nupstream {}\n---\nserver {}",
+		},
+		"sorted order with empty separator": {
+			data: v1.Data{
+				From: v1.DataFromMerge,
+				Key:  "application.properties",
+				Merge: &v1.Merge{
+					Sources: []v1.Data{
+						{From: v1.DataFromLiteral, Key: "b", Value: "b=2\n"},
+						{From: v1.DataFromLiteral, Key: "a", Value: "a=1\n"},
+					},
+					Separator: ptr.To(""),
+					Order:     v1.MergeOrderSorted,
+				},
+			},
+			expectedContent: "a=1\nb=2\n",
+		},
+		"missing key": {
+			data: v1.Data{
+				From:  v1.DataFromMerge,
+				Merge: &v1.Merge{Sources: []v1.Data{{From: v1.DataFromLiteral, Value: "value"}}},
+			},
+			expectedError: "the key is required for merging data",
+		},
+		"missing sources": {
+			data: v1.Data{
+				From: v1.DataFromMerge,
+				Key:  "key",
+			},
+			expectedError: `no sources to merge for key "key"`,
+		},
+		"no files matching": {
+			data: v1.Data{
+				From:  v1.DataFromMerge,
+				Key:   "key",
+				Merge: &v1.Merge{Sources: []v1.Data{{From: v1.DataFromFile, File: "missing/*.conf"}}},
+			},
+			expectedError: `failed to merge key "key": no files found for "missing/*.conf"`,
+		},
+		"nested merge": {
+			data: v1.Data{
+				From:  v1.DataFromMerge,
+				Key:   "key",
+				Merge: &v1.Merge{Sources: []v1.Data{{From: v1.DataFromMerge}}},
+			},
+			expectedError: `failed to merge key "key": unknown data source: merge`,
+		},
+		"unknown order": {
+			data: v1.Data{
+				From: v1.DataFromMerge,
+				Key:  "key",
+				Merge: &v1.Merge{
+					Sources: []v1.Data{{From: v1.DataFromLiteral, Value: "value"}},
+					Order:   "random",
+				},
+			},
+			expectedError: `unknown merge order for key "key": random`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options := &Options{fSys: fSys}
+			content, err := options.mergeData(test.data)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedContent, string(content))
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestMergeDataInResources(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("first.properties", []byte("first=1")))
+	require.NoError(t, fSys.WriteFile("second.properties", []byte("second=2")))
+	data := []v1.Data{{
+		From: v1.DataFromMerge,
+		Key:  "application.properties",
+		Merge: &v1.Merge{
+			Sources: []v1.Data{
+				{From: v1.DataFromFile, File: "first.properties"},
+				{From: v1.DataFromFile, File: "second.properties"},
+			},
+		},
+	}}
+	options := &Options{fSys: fSys}
+
+	configMap, err := options.configMapFromConfig(v1.ConfigMapSpec{Name: "config", Data: data})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"application.properties": "first=1\nsecond=2"}, configMap.Data)
+
+	secret, _, err := options.secretsFromConfig(context.TODO(), v1.SecretSpec{Name: "secret", Data: data})
+	require.NoError(t, err)
+	assert.Equal(t, corev1.SecretTypeOpaque, secret.Type)
+	assert.Equal(t, map[string][]byte{"application.properties": []byte("first=1\nsecond=2")}, secret.Data)
+}
@@ -0,0 +1,129 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	v2 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v2"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/interpolate"
+	"sigs.k8s.io/yaml"
+)
+
+// resolveMissingEnvs handle the data entries of config that use one of the missing environment variables, using
+// their default value or removing them if optional; an error is returned if a missing variable is used elsewhere
+func resolveMissingEnvs(config *v2.GenerateConfiguration, missing []string, delims interpolate.Delimiters) error {
+	if len(missing) == 0 {
+		return nil
+	}
+
+	for idx := range config.ConfigMaps {
+		data, err := resolveDataEntries(config.ConfigMaps[idx].Data, missing, delims)
+		if err != nil {
+			return err
+		}
+		config.ConfigMaps[idx].Data = data
+	}
+
+	for idx := range config.Secrets {
+		if config.Secrets[idx].Data == nil {
+			continue
+		}
+
+		data, err := resolveDataEntries(config.Secrets[idx].Data, missing, delims)
+		if err != nil {
+			return err
+		}
+		config.Secrets[idx].Data = data
+	}
+
+	remainingData, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	if env := missingEnvIn(string(remainingData), missing, delims); len(env) > 0 {
+		return fmt.Errorf("environment variable %q not found", env)
+	}
+	return nil
+}
+
+// resolveDataEntries return entries with the ones that use a missing environment variable replaced by their
+// default value or removed if optional
+func resolveDataEntries(entries []v1.Data, missing []string, delims interpolate.Delimiters) ([]v1.Data, error) {
+	resolved := make([]v1.Data, 0, len(entries))
+	for _, data := range entries {
+		env := missingEnvInData(data, missing, delims)
+		switch {
+		case len(env) == 0:
+			resolved = append(resolved, data)
+		case data.Default != nil:
+			defaultData, err := defaultDataEntry(data, delims)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, defaultData)
+		case !data.Optional:
+			return nil, fmt.Errorf("environment variable %q not found", env)
+		}
+	}
+
+	return resolved, nil
+}
+
+// defaultDataEntry return a literal entry with the default value of data, using the file name as key for the
+// file entries
+func defaultDataEntry(data v1.Data, delims interpolate.Delimiters) (v1.Data, error) {
+	key := data.Key
+	if data.From == v1.DataFromFile {
+		key = filepath.Base(data.File)
+	}
+
+	if len(key) == 0 || strings.Contains(key, delims.OrDefault().Left) {
+		return v1.Data{}, fmt.Errorf("cannot use the default value for %q: the key cannot be computed", cmp.Or(data.File, data.Key))
+	}
+
+	return v1.Data{From: v1.DataFromLiteral, Key: key, Value: *data.Default}, nil
+}
+
+// missingEnvInData return the first missing environment variable used by data or its merge sources
+func missingEnvInData(data v1.Data, missing []string, delims interpolate.Delimiters) string {
+	fields := []string{data.Key, data.File, data.Value}
+	if data.Merge != nil {
+		for _, source := range data.Merge.Sources {
+			fields = append(fields, source.Key, source.File, source.Value)
+		}
+	}
+
+	return missingEnvIn(strings.Join(fields, "\n"), missing, delims)
+}
+
+// missingEnvIn return the first of the missing environment variables used in value
+func missingEnvIn(value string, missing []string, delims interpolate.Delimiters) string {
+	idx := slices.IndexFunc(missing, func(env string) bool {
+		return strings.Contains(value, delims.Sequence(env)) || strings.Contains(value, delims.Sequence(env+interpolate.Base64DecodeSuffix))
+	})
+	if idx < 0 {
+		return ""
+	}
+
+	return missing[idx]
+}
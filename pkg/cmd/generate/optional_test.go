@@ -0,0 +1,185 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mia-platform/mlp/v2/pkg/cmd/interpolate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestOptionalData(t *testing.T) {
+	t.Setenv("MLP_OPTIONAL_PRESENT", "present")
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("existing.txt", []byte("existing content")))
+	require.NoError(t, fSys.WriteFile("optional.yaml", []byte(`config-maps:
+- name: optional
+  data:
+  - from: literal
+    key: present
+    value: "{{OPTIONAL_PRESENT}}"
+  - from: literal
+    key: omitted
+    value: "{{OPTIONAL_MISSING}}"
+    optional: true
+  - from: literal-base64
+    key: encoded
+    value: "{{OPTIONAL_MISSING|b64decode}}"
+    optional: true
+  - from: literal
+    key: defaulted
+    value: "prefix-{{OPTIONAL_MISSING}}"
+    default: fallback
+  - from: file
+    file: existing.txt
+    optional: true
+  - from: file
+    file: missing.txt
+    optional: true
+  - from: file
+    file: "{{OPTIONAL_MISSING}}/missing.txt"
+    default: file fallback
+secrets:
+- name: optional
+  when: always
+  data:
+  - from: literal
+    key: omitted
+    value: "{{OPTIONAL_MISSING}}"
+    optional: true
+  - from: file
+    file: missing.txt
+    default: secret fallback
+`)))
+	require.NoError(t, fSys.WriteFile("required.yaml", []byte(`config-maps:
+- name: required
+  data:
+  - from: literal
+    key: required
+    value: "{{OPTIONAL_MISSING}}"
+`)))
+	require.NoError(t, fSys.WriteFile("outside-data.yaml", []byte(`config-maps:
+- name: "{{OPTIONAL_MISSING}}"
+  data:
+  - from: literal
+    key: omitted
+    value: "{{OPTIONAL_MISSING}}"
+    optional: true
+`)))
+	require.NoError(t, fSys.WriteFile("default-without-key.yaml", []byte(`config-maps:
+- name: default-without-key
+  data:
+  - from: file
+    file: "{{OPTIONAL_MISSING}}"
+    default: value
+`)))
+
+	require.NoError(t, fSys.WriteFile("custom-delimiters.yaml", []byte(`config-maps:
+- name: custom-delimiters
+  data:
+  - from: literal
+    key: present
+    value: "[[OPTIONAL_PRESENT]]"
+  - from: literal
+    key: template
+    value: "{{ .Values.name }} {{OPTIONAL_PRESENT}}"
+  - from: literal
+    key: omitted
+    value: "[[OPTIONAL_MISSING]]"
+    optional: true
+`)))
+	require.NoError(t, fSys.WriteFile("custom-default-without-key.yaml", []byte(`config-maps:
+- name: default-without-key
+  data:
+  - from: file
+    file: "[[OPTIONAL_MISSING]]"
+    default: value
+`)))
+
+	tests := map[string]struct {
+		configFile    string
+		delimiters    interpolate.Delimiters
+		expectedData  map[string]map[string]string
+		expectedError string
+	}{
+		"optional and default values": {
+			configFile: "optional.yaml",
+			expectedData: map[string]map[string]string{
+				"Secret": {"missing.txt": "c2VjcmV0IGZhbGxiYWNr"},
+				"ConfigMap": {
+					"present":      "present",
+					"defaulted":    "fallback",
+					"existing.txt": "existing content",
+					"missing.txt":  "file fallback",
+				},
+			},
+		},
+		"required value": {
+			configFile:    "required.yaml",
+			expectedError: `environment variable "OPTIONAL_MISSING" not found`,
+		},
+		"missing variable outside data": {
+			configFile:    "outside-data.yaml",
+			expectedError: `environment variable "OPTIONAL_MISSING" not found`,
+		},
+		"default value without key": {
+			configFile:    "default-without-key.yaml",
+			expectedError: `cannot use the default value for "{{OPTIONAL_MISSING}}": the key cannot be computed`,
+		},
+		"custom delimiters": {
+			configFile: "custom-delimiters.yaml",
+			delimiters: interpolate.Delimiters{Left: "[[", Right: "]]"},
+			expectedData: map[string]map[string]string{
+				"ConfigMap": {
+					"present":  "present",
+					"template": "{{ .Values.name }} {{OPTIONAL_PRESENT}}",
+				},
+			},
+		},
+		"custom delimiters default value without key": {
+			configFile:    "custom-default-without-key.yaml",
+			delimiters:    interpolate.Delimiters{Left: "[[", Right: "]]"},
+			expectedError: `cannot use the default value for "[[OPTIONAL_MISSING]]": the key cannot be computed`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			options := NewOptions([]string{test.configFile}, []string{"MLP_"}, fSys)
+			options.delimiters = test.delimiters
+			objects, err := options.RunToObjects(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				data := make(map[string]map[string]string, len(objects))
+				for _, obj := range objects {
+					objData, _, err := unstructured.NestedStringMap(obj.Object, "data")
+					require.NoError(t, err)
+					data[obj.GetKind()] = objData
+				}
+				assert.Equal(t, test.expectedData, data)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	v2 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// outputFormatter render the data of a generated ConfigMap or Secret for the consumers outside Kubernetes
+type outputFormatter interface {
+	// filenameTemplate return the template used for naming the files when the resource doesn't set one
+	filenameTemplate() string
+	// format return the content of the file holding data
+	format(data map[string]string) ([]byte, error)
+}
+
+var (
+	outputFormatters = map[string]outputFormatter{
+		v2.OutputEnv:    envFormatter{},
+		v2.OutputTfvars: tfvarsFormatter{},
+	}
+
+	envNameRegex    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	tfvarsNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	envValueEscapes = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`)
+)
+
+// isKubernetesOutput return true if output save the resource as a Kubernetes manifest
+func isKubernetesOutput(output string) bool {
+	return len(output) == 0 || output == v2.OutputKubernetes
+}
+
+// formatterForOutput return the formatter registered for output
+func formatterForOutput(output string) (outputFormatter, error) {
+	formatter, found := outputFormatters[output]
+	if !found {
+		outputs := append([]string{v2.OutputKubernetes}, slices.Sorted(maps.Keys(outputFormatters))...)
+		return nil, fmt.Errorf("unsupported output %q: must be one of %s", output, strings.Join(outputs, ", "))
+	}
+	return formatter, nil
+}
+
+// resourceData return the keys of the generated ConfigMap or Secret obj with their values as strings, the binary
+// values are rejected because they cannot be saved outside the Kubernetes manifests
+func resourceData(obj runtime.Object) (map[string]string, error) {
+	var kind, name string
+	var stringData map[string]string
+	var binaryData map[string][]byte
+	switch resource := obj.(type) {
+	case *corev1.ConfigMap:
+		kind, name, stringData, binaryData = resource.Kind, resource.Name, resource.Data, resource.BinaryData
+	case *corev1.Secret:
+		kind, name, stringData, binaryData = resource.Kind, resource.Name, resource.StringData, resource.Data
+	default:
+		return nil, fmt.Errorf("unsupported resource %s for a non Kubernetes output", obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+
+	data := make(map[string]string, len(stringData)+len(binaryData))
+	maps.Copy(data, stringData)
+	for key, value := range binaryData {
+		if !utf8.Valid(value) {
+			return nil, fmt.Errorf("the key %q of %s %q contains binary data that can only be saved in a Kubernetes manifest", key, kind, name)
+		}
+		data[key] = string(value)
+	}
+
+	return data, nil
+}
+
+// envFormatter save the data as a dotenv file, with a double quoted variable for every key
+type envFormatter struct{}
+
+func (envFormatter) filenameTemplate() string {
+	return "{{.Name}}.env"
+}
+
+func (envFormatter) format(data map[string]string) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		if !envNameRegex.MatchString(key) {
+			return nil, fmt.Errorf("the key %q is not a valid environment variable name", key)
+		}
+		fmt.Fprintf(buffer, "%s=\"%s\"\n", key, envValueEscapes.Replace(data[key]))
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// tfvarsFormatter save the data as a Terraform variables file in the JSON syntax, with a string variable for
+// every key
+type tfvarsFormatter struct{}
+
+func (tfvarsFormatter) filenameTemplate() string {
+	return "{{.Name}}.tfvars.json"
+}
+
+func (tfvarsFormatter) format(data map[string]string) ([]byte, error) {
+	for key := range data {
+		if !tfvarsNameRegex.MatchString(key) {
+			return nil, fmt.Errorf("the key %q is not a valid Terraform variable name", key)
+		}
+	}
+
+	buffer := new(bytes.Buffer)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,186 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestOutputFormatters(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		output          string
+		data            map[string]string
+		expectedContent string
+		expectedError   string
+	}{
+		"env output quote and escape the values": {
+			output: "env",
+			data: map[string]string{
+				"PASSWORD": `p@ss"word$`,
+				"CERT":     "line one\nline two",
+				"PATH_":    `C:\tools`,
+			},
+			expectedContent: "CERT=\"line one\\nline two\"\nPASSWORD=\"p@ss\\\"word\\$\"\nPATH_=\"C:\\\\tools\"\n",
+		},
+		"env output reject invalid names": {
+			output:        "env",
+			data:          map[string]string{"config.json": "{}"},
+			expectedError: `the key "config.json" is not a valid environment variable name`,
+		},
+		"tfvars output save string variables": {
+			output: "tfvars",
+			data: map[string]string{
+				"db_url":   "postgres://db:5432/app?sslmode=require&user=<app>",
+				"replicas": "3",
+			},
+			expectedContent: "{\n  \"db_url\": \"postgres://db:5432/app?sslmode=require&user=<app>\",\n  \"replicas\": \"3\"\n}\n",
+		},
+		"tfvars output reject invalid names": {
+			output:        "tfvars",
+			data:          map[string]string{"1st": "value"},
+			expectedError: `the key "1st" is not a valid Terraform variable name`,
+		},
+		"unsupported output": {
+			output:        "properties",
+			expectedError: `unsupported output "properties": must be one of k8s, env, tfvars`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			formatter, err := formatterForOutput(test.output)
+			if err == nil {
+				var content []byte
+				content, err = formatter.format(test.data)
+				if len(test.expectedError) == 0 {
+					require.NoError(t, err)
+					assert.Equal(t, test.expectedContent, string(content))
+					return
+				}
+			}
+
+			assert.EqualError(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestRunWithOutputs(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("binary.bin", []byte{0xff, 0xfe, 0x00}))
+	require.NoError(t, fSys.WriteFile("outputs.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v2
+kind: GenerateConfiguration
+configMaps:
+- name: settings
+  output: env
+  data:
+  - from: literal
+    key: LOG_LEVEL
+    value: info
+- name: manifest
+  output: k8s
+  data:
+  - from: literal
+    key: key
+    value: value
+secrets:
+- name: credentials
+  output: tfvars
+  data:
+  - from: literal
+    key: db_password
+    value: secret
+- name: custom-name
+  output: env
+  filenameTemplate: custom-name.secrets.env
+  encoding: stringData
+  data:
+  - from: literal
+    key: TOKEN
+    value: token
+`)))
+	require.NoError(t, fSys.WriteFile("unsupported.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v2
+kind: GenerateConfiguration
+configMaps:
+- name: settings
+  output: properties
+`)))
+	require.NoError(t, fSys.WriteFile("binary.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v2
+kind: GenerateConfiguration
+configMaps:
+- name: binary
+  output: env
+  data:
+  - from: file
+    file: binary.bin
+`)))
+
+	options := &Options{
+		configFiles:      []string{"outputs.yaml"},
+		outputPath:       "output",
+		filenameTemplate: defaultFilenameTemplate,
+		inventory:        true,
+		fSys:             fSys,
+	}
+	require.NoError(t, options.Run(context.TODO()))
+
+	files, err := fSys.ReadDir("output")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"credentials.tfvars.json",
+		"custom-name.secrets.env",
+		"eu.mia-platform.mlp.generated.configmap.yaml",
+		"manifest.configmap.yaml",
+		"settings.env",
+	}, files)
+
+	expectedFiles := map[string]string{
+		"settings.env":            "LOG_LEVEL=\"info\"\n",
+		"custom-name.secrets.env": "TOKEN=\"token\"\n",
+		"credentials.tfvars.json": "{\n  \"db_password\": \"secret\"\n}\n",
+	}
+	for name, expectedContent := range expectedFiles {
+		content, err := fSys.ReadFile(filepath.Join("output", name))
+		require.NoError(t, err)
+		assert.Equal(t, expectedContent, string(content), name)
+	}
+
+	objects, err := options.RunToObjects(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	assert.Equal(t, "manifest", objects[1].GetName())
+	data, _, err := unstructured.NestedStringMap(objects[0].Object, "data")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"_manifest__ConfigMap": ""}, data, "the inventory must track only the Kubernetes resources")
+
+	options.configFiles = []string{"unsupported.yaml"}
+	assert.EqualError(t, options.Run(context.TODO()), `ConfigMap "settings": unsupported output "properties": must be one of k8s, env, tfvars`)
+
+	options.configFiles = []string{"binary.yaml"}
+	assert.EqualError(t, options.Run(context.TODO()), `the key "binary.bin" of ConfigMap "binary" contains binary data that can only be saved in a Kubernetes manifest`)
+}
@@ -0,0 +1,86 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+)
+
+const (
+	// RotationIntervalAnnotation contains the rotation interval configured for a generated secret
+	RotationIntervalAnnotation = "mia-platform.eu/rotation-interval"
+	// RotatedAtAnnotation contains the RFC3339 timestamp of when the secret content has been generated
+	RotatedAtAnnotation = "mia-platform.eu/rotated-at"
+	// RotationDueAnnotation contains the RFC3339 timestamp after which the secret content must be rotated
+	RotationDueAnnotation = "mia-platform.eu/rotation-due"
+
+	day = 24 * time.Hour
+)
+
+// ParseRotationInterval parse a rotation interval, it accepts all the durations supported by time.ParseDuration
+// plus an integer number of days (d) or weeks (w)
+func ParseRotationInterval(interval string) (time.Duration, error) {
+	var duration time.Duration
+	var err error
+	switch {
+	case strings.HasSuffix(interval, "d"):
+		duration, err = parseDays(strings.TrimSuffix(interval, "d"), day)
+	case strings.HasSuffix(interval, "w"):
+		duration, err = parseDays(strings.TrimSuffix(interval, "w"), 7*day)
+	default:
+		duration, err = time.ParseDuration(interval)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid rotation interval %q: %w", interval, err)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("invalid rotation interval %q: must be greater than zero", interval)
+	}
+	return duration, nil
+}
+
+func parseDays(value string, unit time.Duration) (time.Duration, error) {
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(count) * unit, nil
+}
+
+// rotationAnnotations return the annotations to stamp on a secret generated at now with the rotation configuration
+func rotationAnnotations(name string, rotation *v1.SecretRotation, now time.Time) (map[string]string, error) {
+	if rotation == nil {
+		return nil, nil
+	}
+
+	interval, err := ParseRotationInterval(rotation.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("secret %q: %w", name, err)
+	}
+
+	now = now.UTC().Truncate(time.Second)
+	return map[string]string{
+		RotationIntervalAnnotation: rotation.Interval,
+		RotatedAtAnnotation:        now.Format(time.RFC3339),
+		RotationDueAnnotation:      now.Add(interval).Format(time.RFC3339),
+	}, nil
+}
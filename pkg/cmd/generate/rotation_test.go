@@ -0,0 +1,123 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestParseRotationInterval(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		interval         string
+		expectedDuration time.Duration
+		expectedError    string
+	}{
+		"days": {
+			interval:         "90d",
+			expectedDuration: 90 * 24 * time.Hour,
+		},
+		"weeks": {
+			interval:         "2w",
+			expectedDuration: 14 * 24 * time.Hour,
+		},
+		"go duration": {
+			interval:         "36h30m",
+			expectedDuration: 36*time.Hour + 30*time.Minute,
+		},
+		"invalid days": {
+			interval:      "1.5d",
+			expectedError: `invalid rotation interval "1.5d"`,
+		},
+		"invalid unit": {
+			interval:      "3y",
+			expectedError: `invalid rotation interval "3y"`,
+		},
+		"empty": {
+			interval:      "",
+			expectedError: `invalid rotation interval ""`,
+		},
+		"zero": {
+			interval:      "0d",
+			expectedError: `invalid rotation interval "0d": must be greater than zero`,
+		},
+		"negative": {
+			interval:      "-1h",
+			expectedError: `invalid rotation interval "-1h": must be greater than zero`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			duration, err := ParseRotationInterval(test.interval)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedDuration, duration)
+		})
+	}
+}
+
+func TestSecretsFromConfigWithRotation(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, time.January, 10, 8, 30, 15, 500, time.FixedZone("CET", 3600))
+	options := &Options{
+		fSys:  filesys.MakeFsInMemory(),
+		clock: clocktesting.NewFakePassiveClock(now),
+	}
+
+	secret, _, err := options.secretsFromConfig(context.TODO(), v1.SecretSpec{
+		Name:     "secret",
+		When:     "once",
+		Data:     []v1.Data{{From: v1.DataFromLiteral, Key: "key", Value: "value"}},
+		Rotation: &v1.SecretRotation{Interval: "90d"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"mia-platform.eu/deploy":   "once",
+		RotationIntervalAnnotation: "90d",
+		RotatedAtAnnotation:        "2024-01-10T07:30:15Z",
+		RotationDueAnnotation:      "2024-04-09T07:30:15Z",
+	}, secret.Annotations)
+
+	secret, _, err = options.secretsFromConfig(context.TODO(), v1.SecretSpec{
+		Name: "secret",
+		Data: []v1.Data{{From: v1.DataFromLiteral, Key: "key", Value: "value"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"mia-platform.eu/deploy": ""}, secret.Annotations)
+
+	_, _, err = options.secretsFromConfig(context.TODO(), v1.SecretSpec{
+		Name:     "secret",
+		Data:     []v1.Data{{From: v1.DataFromLiteral, Key: "key", Value: "value"}},
+		Rotation: &v1.SecretRotation{Interval: "soon"},
+	})
+	assert.ErrorContains(t, err, `secret "secret": invalid rotation interval "soon"`)
+}
@@ -0,0 +1,212 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"maps"
+
+	v2 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	sealedSecretAPIVersion = "bitnami.com/v1alpha1"
+	sealedSecretKind       = "SealedSecret"
+
+	sealedNamespaceWideAnnotation = "sealedsecrets.bitnami.com/namespace-wide"
+	sealedClusterWideAnnotation   = "sealedsecrets.bitnami.com/cluster-wide"
+
+	// sealedSessionKeySize is the size of the AES key generated for every sealed value
+	sealedSessionKeySize = 32
+)
+
+// sealer encrypt the generated secrets with the public key of the Sealed Secrets controller
+type sealer struct {
+	publicKey *rsa.PublicKey
+	scope     string
+	rand      io.Reader
+}
+
+// newSealer return the sealer for the sealed output of config, or nil if the secrets are not sealed
+func (o *Options) newSealer(config *v2.GenerateConfiguration) (*sealer, error) {
+	if config.Sealed == nil {
+		return nil, nil
+	}
+
+	scope := config.Sealed.Scope
+	switch scope {
+	case "":
+		scope = v2.SealedScopeStrict
+	case v2.SealedScopeStrict, v2.SealedScopeNamespaceWide, v2.SealedScopeClusterWide:
+	default:
+		return nil, fmt.Errorf("invalid sealed scope %q: must be one of %s, %s or %s", scope, v2.SealedScopeStrict, v2.SealedScopeNamespaceWide, v2.SealedScopeClusterWide)
+	}
+
+	if scope != v2.SealedScopeClusterWide && len(config.Namespace) == 0 {
+		return nil, fmt.Errorf("the namespace is required for sealing secrets with the %s scope", scope)
+	}
+
+	if len(config.Sealed.Certificate) == 0 {
+		return nil, fmt.Errorf("the certificate is required for sealing secrets")
+	}
+
+	data, err := o.fSys.ReadFile(config.Sealed.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("reading sealing certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate found in %q", config.Sealed.Certificate)
+	}
+
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing sealing certificate: %w", err)
+	}
+
+	publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("the sealing certificate %q must contain an RSA public key", config.Sealed.Certificate)
+	}
+
+	return &sealer{publicKey: publicKey, scope: scope, rand: rand.Reader}, nil
+}
+
+// seal return the SealedSecret resource containing the encrypted data of secret
+func (s *sealer) seal(secret *corev1.Secret) (*unstructured.Unstructured, error) {
+	data := make(map[string][]byte, len(secret.Data)+len(secret.StringData))
+	maps.Copy(data, secret.Data)
+	for key, value := range secret.StringData {
+		data[key] = []byte(value)
+	}
+
+	label := s.label(secret.Namespace, secret.Name)
+	encryptedData := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		encrypted, err := s.encrypt(value, label)
+		if err != nil {
+			return nil, fmt.Errorf("sealing key %q of secret %q: %w", key, secret.Name, err)
+		}
+		encryptedData[key] = base64.StdEncoding.EncodeToString(encrypted)
+	}
+
+	annotations := make(map[string]string)
+	switch s.scope {
+	case v2.SealedScopeNamespaceWide:
+		annotations[sealedNamespaceWideAnnotation] = "true"
+	case v2.SealedScopeClusterWide:
+		annotations[sealedClusterWideAnnotation] = "true"
+	}
+
+	templateMetadata := map[string]interface{}{"name": secret.Name}
+	metadata := map[string]interface{}{"name": secret.Name}
+	if len(secret.Namespace) > 0 {
+		templateMetadata["namespace"] = secret.Namespace
+		metadata["namespace"] = secret.Namespace
+	}
+	if len(secret.Labels) > 0 {
+		templateMetadata["labels"] = stringMap(secret.Labels)
+		metadata["labels"] = stringMap(secret.Labels)
+	}
+	templateAnnotations := make(map[string]string, len(secret.Annotations)+len(annotations))
+	maps.Copy(templateAnnotations, secret.Annotations)
+	maps.Copy(templateAnnotations, annotations)
+	if len(templateAnnotations) > 0 {
+		templateMetadata["annotations"] = stringMap(templateAnnotations)
+	}
+	if len(annotations) > 0 {
+		metadata["annotations"] = stringMap(annotations)
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": sealedSecretAPIVersion,
+		"kind":       sealedSecretKind,
+		"metadata":   metadata,
+		"spec": map[string]interface{}{
+			"encryptedData": encryptedData,
+			"template": map[string]interface{}{
+				"metadata": templateMetadata,
+				"type":     string(secret.Type),
+			},
+		},
+	}}, nil
+}
+
+// label return the label bound to the encrypted values, that restrict where they can be decrypted following the
+// sealing scope
+func (s *sealer) label(namespace, name string) []byte {
+	switch s.scope {
+	case v2.SealedScopeNamespaceWide:
+		return []byte(namespace)
+	case v2.SealedScopeClusterWide:
+		return []byte{}
+	default:
+		return []byte(namespace + "/" + name)
+	}
+}
+
+// encrypt seal plaintext with the same hybrid encryption of the Sealed Secrets controller: a random AES-GCM session
+// key encrypts the value and is itself encrypted with RSA-OAEP, and the two ciphertexts are joined prefixed by the
+// length of the RSA one
+func (s *sealer) encrypt(plaintext, label []byte) ([]byte, error) {
+	sessionKey := make([]byte, sealedSessionKeySize)
+	if _, err := io.ReadFull(s.rand, sessionKey); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaCiphertext, err := rsa.EncryptOAEP(sha256.New(), s.rand, s.publicKey, sessionKey, label)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := binary.BigEndian.AppendUint16(nil, uint16(len(rsaCiphertext)))
+	ciphertext = append(ciphertext, rsaCiphertext...)
+
+	// the session key is used only once, so a zero nonce is safe
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(ciphertext, nonce, plaintext, nil), nil
+}
+
+// stringMap convert values in the map type used by unstructured objects
+func stringMap(values map[string]string) map[string]interface{} {
+	converted := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		converted[key] = value
+	}
+	return converted
+}
@@ -0,0 +1,162 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestSealedOutput(t *testing.T) {
+	t.Parallel()
+
+	keyPEM, certPEM := generateCertificates(t)
+	block, _ := pem.Decode([]byte(keyPEM))
+	require.NotNil(t, block)
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	require.NoError(t, err)
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("cert.pem", []byte(certPEM)))
+	require.NoError(t, fSys.WriteFile("key.pem", []byte(keyPEM)))
+
+	tests := map[string]struct {
+		scope               string
+		namespace           string
+		expectedLabel       string
+		expectedAnnotations map[string]string
+		expectedError       string
+	}{
+		"strict scope by default": {
+			namespace:     "production",
+			expectedLabel: "production/credentials",
+		},
+		"namespace wide scope": {
+			scope:               "namespace-wide",
+			namespace:           "production",
+			expectedLabel:       "production",
+			expectedAnnotations: map[string]string{sealedNamespaceWideAnnotation: "true"},
+		},
+		"cluster wide scope": {
+			scope:               "cluster-wide",
+			expectedAnnotations: map[string]string{sealedClusterWideAnnotation: "true"},
+		},
+		"strict scope without namespace": {
+			expectedError: "the namespace is required for sealing secrets with the strict scope",
+		},
+		"invalid scope": {
+			scope:         "global",
+			expectedError: `invalid sealed scope "global"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			configuration := `apiVersion: mlp.mia-platform.eu/v2
+namespace: "` + test.namespace + `"
+sealed:
+  certificate: cert.pem
+  scope: "` + test.scope + `"
+secrets:
+- name: credentials
+  when: always
+  encoding: stringData
+  data:
+  - from: literal
+    key: password
+    value: secret
+  - from: file
+    file: key.pem
+`
+			memFSys := filesys.MakeFsInMemory()
+			require.NoError(t, memFSys.WriteFile("cert.pem", []byte(certPEM)))
+			require.NoError(t, memFSys.WriteFile("key.pem", []byte(keyPEM)))
+			require.NoError(t, memFSys.WriteFile("sealed.yaml", []byte(configuration)))
+
+			options := NewOptions([]string{"sealed.yaml"}, nil, memFSys)
+			objects, err := options.RunToObjects(context.TODO())
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, objects, 1)
+			sealed := objects[0]
+			assert.Equal(t, sealedSecretAPIVersion, sealed.GetAPIVersion())
+			assert.Equal(t, sealedSecretKind, sealed.GetKind())
+			assert.Equal(t, "credentials", sealed.GetName())
+			assert.Equal(t, test.namespace, sealed.GetNamespace())
+			assert.Equal(t, test.expectedAnnotations, sealed.GetAnnotations())
+
+			secretType, _, err := unstructured.NestedString(sealed.Object, "spec", "template", "type")
+			require.NoError(t, err)
+			assert.Equal(t, "Opaque", secretType)
+			templateAnnotations, _, err := unstructured.NestedStringMap(sealed.Object, "spec", "template", "metadata", "annotations")
+			require.NoError(t, err)
+			assert.Equal(t, "always", templateAnnotations["mia-platform.eu/deploy"])
+
+			encryptedData, _, err := unstructured.NestedStringMap(sealed.Object, "spec", "encryptedData")
+			require.NoError(t, err)
+			require.Len(t, encryptedData, 2)
+			assert.Equal(t, "secret", unseal(t, privateKey, encryptedData["password"], test.expectedLabel))
+			assert.Equal(t, keyPEM, unseal(t, privateKey, encryptedData["key.pem"], test.expectedLabel))
+		})
+	}
+
+	options := NewOptions([]string{"sealed.yaml"}, nil, fSys)
+	require.NoError(t, fSys.WriteFile("sealed.yaml", []byte(`apiVersion: mlp.mia-platform.eu/v2
+sealed:
+  certificate: key.pem
+  scope: cluster-wide
+`)))
+	_, err = options.RunToObjects(context.TODO())
+	assert.ErrorContains(t, err, `no PEM certificate found in "key.pem"`)
+}
+
+// unseal decrypt value with the same algorithm used by the Sealed Secrets controller
+func unseal(t *testing.T, key *rsa.PrivateKey, value, label string) string {
+	t.Helper()
+
+	ciphertext, err := base64.StdEncoding.DecodeString(value)
+	require.NoError(t, err)
+	rsaLength := int(binary.BigEndian.Uint16(ciphertext))
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), nil, key, ciphertext[2:2+rsaLength], []byte(label))
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(sessionKey)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext[2+rsaLength:], nil)
+	require.NoError(t, err)
+	return string(plaintext)
+}
@@ -0,0 +1,257 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	// caConfigMapKey is the key used for saving the chain certificates in the CA ConfigMap
+	caConfigMapKey = "ca.crt"
+
+	certificateBlockType = "CERTIFICATE"
+	privateKeyBlockType  = "PRIVATE KEY"
+)
+
+// tlsBundle contains the PEM encoded parts of a TLS certificate
+type tlsBundle struct {
+	// cert contains the leaf certificate followed by its chain
+	cert []byte
+	// key contains the private key of the leaf certificate
+	key []byte
+	// ca contains only the chain certificates
+	ca []byte
+	// leaf is the parsed leaf certificate, it can be nil if the certificate data cannot be parsed
+	leaf *x509.Certificate
+}
+
+// readPKCS12 return the certificates and the private key in PEM format contained in the PKCS#12 archive
+func (o *Options) readPKCS12(config *v1.PKCS12) ([]*x509.Certificate, []byte, error) {
+	var data []byte
+	switch config.From {
+	case v1.DataFromLiteral:
+		decoded, err := base64.StdEncoding.DecodeString(config.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode pkcs12 value: %w", err)
+		}
+		data = decoded
+	case v1.DataFromFile:
+		content, err := o.fSys.ReadFile(config.File)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = content
+	default:
+		return nil, nil, fmt.Errorf("unknown data source: %s", config.From)
+	}
+
+	key, leaf, caCerts, err := pkcs12.DecodeChain(data, config.Passphrase)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode pkcs12 archive: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode pkcs12 private key: %w", err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: privateKeyBlockType, Bytes: keyDER})
+	return append([]*x509.Certificate{leaf}, caCerts...), keyPEM, nil
+}
+
+// splitPEMBundle return all the certificates found in data and the first private key block encoded in PEM format,
+// the other blocks are ignored
+func splitPEMBundle(data []byte) ([]*x509.Certificate, []byte, error) {
+	var certificates []*x509.Certificate
+	var keyPEM []byte
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch {
+		case block.Type == certificateBlockType:
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse certificate: %w", err)
+			}
+			certificates = append(certificates, certificate)
+		case strings.HasSuffix(block.Type, privateKeyBlockType) && keyPEM == nil:
+			keyPEM = pem.EncodeToMemory(block)
+		}
+	}
+
+	return certificates, keyPEM, nil
+}
+
+// newTLSBundle return a tlsBundle with certificates sorted starting from the one matching keyPEM and following
+// the issuers chain; original is used as is for the certificate if no reordering is needed
+func newTLSBundle(original []byte, certificates []*x509.Certificate, keyPEM []byte) (*tlsBundle, error) {
+	if len(certificates) == 0 {
+		return &tlsBundle{cert: original, key: keyPEM}, nil
+	}
+
+	sorted, err := sortCertificatesChain(certificates, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &tlsBundle{key: keyPEM, leaf: sorted[0], ca: encodeCertificates(sorted[1:])}
+	switch {
+	case original != nil && sameOrder(certificates, sorted) && !bytes.Contains(original, []byte(privateKeyBlockType)):
+		// keep the data as is to avoid changing the generated secret when the input is already correct
+		bundle.cert = original
+	default:
+		bundle.cert = encodeCertificates(sorted)
+	}
+
+	return bundle, nil
+}
+
+// sortCertificatesChain return certificates starting from the leaf matching the private key in keyPEM, followed by
+// its issuers; if the leaf cannot be found the first certificate is used. Every certificate must be part of the chain.
+func sortCertificatesChain(certificates []*x509.Certificate, keyPEM []byte) ([]*x509.Certificate, error) {
+	leafIndex := 0
+	if publicKey := publicKeyFromPEM(keyPEM); publicKey != nil {
+		for idx, certificate := range certificates {
+			if publicKey.Equal(certificate.PublicKey) {
+				leafIndex = idx
+				break
+			}
+		}
+	}
+
+	remaining := make([]*x509.Certificate, 0, len(certificates)-1)
+	remaining = append(remaining, certificates[:leafIndex]...)
+	remaining = append(remaining, certificates[leafIndex+1:]...)
+	sorted := []*x509.Certificate{certificates[leafIndex]}
+	for len(remaining) > 0 {
+		current := sorted[len(sorted)-1]
+		if bytes.Equal(current.RawIssuer, current.RawSubject) {
+			break
+		}
+
+		issuerIndex := -1
+		for idx, certificate := range remaining {
+			if bytes.Equal(certificate.RawSubject, current.RawIssuer) && current.CheckSignatureFrom(certificate) == nil {
+				issuerIndex = idx
+				break
+			}
+		}
+		if issuerIndex == -1 {
+			break
+		}
+
+		sorted = append(sorted, remaining[issuerIndex])
+		remaining = append(remaining[:issuerIndex], remaining[issuerIndex+1:]...)
+	}
+
+	if len(remaining) > 0 {
+		return nil, fmt.Errorf("certificate %q is not part of the chain of %q", remaining[0].Subject, sorted[0].Subject)
+	}
+
+	return sorted, nil
+}
+
+// publicKeyFromPEM return the public key of the private key contained in keyPEM, or nil if it cannot be parsed
+func publicKeyFromPEM(keyPEM []byte) interface{ Equal(crypto.PublicKey) bool } {
+	signer, err := parsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil
+	}
+
+	publicKey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return nil
+	}
+	return publicKey
+}
+
+// parsePrivateKeyPEM return the private key contained in keyPEM, encoded in PKCS#1, SEC 1 or PKCS#8 format
+func parsePrivateKeyPEM(keyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no private key found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA " + privateKeyBlockType:
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC " + privateKeyBlockType:
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// sameOrder return true if the two slices contain the same certificates in the same order
+func sameOrder(first, second []*x509.Certificate) bool {
+	if len(first) != len(second) {
+		return false
+	}
+
+	for idx := range first {
+		if !first[idx].Equal(second[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeCertificates return the PEM encoding of certificates
+func encodeCertificates(certificates []*x509.Certificate) []byte {
+	buffer := new(bytes.Buffer)
+	for _, certificate := range certificates {
+		_ = pem.Encode(buffer, &pem.Block{Type: certificateBlockType, Bytes: certificate.Raw})
+	}
+	return buffer.Bytes()
+}
+
+// expiryWarning return a message if certificate is expired or will expire in less than warningDays from now,
+// or an empty string otherwise
+func expiryWarning(certificate *x509.Certificate, warningDays int, now time.Time) string {
+	if certificate == nil {
+		return ""
+	}
+
+	remaining := certificate.NotAfter.Sub(now)
+	switch {
+	case remaining <= 0:
+		return "certificate is expired"
+	case remaining < time.Duration(warningDays)*24*time.Hour:
+		return fmt.Sprintf("certificate will expire in less than %d days", warningDays)
+	default:
+		return ""
+	}
+}
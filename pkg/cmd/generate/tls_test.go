@@ -0,0 +1,295 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	v1 "github.com/mia-platform/mlp/v2/pkg/apis/mlp.mia-platform.eu/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestParseTLS(t *testing.T) {
+	t.Parallel()
+
+	chain := generateChain(t, time.Now().AddDate(1, 0, 0))
+	otherChain := generateChain(t, time.Now().AddDate(1, 0, 0))
+	leafPEM := encodeCertificates([]*x509.Certificate{chain.leaf})
+	orderedPEM := encodeCertificates([]*x509.Certificate{chain.leaf, chain.intermediate, chain.root})
+	caPEM := encodeCertificates([]*x509.Certificate{chain.intermediate, chain.root})
+
+	pfxData, err := pkcs12.Modern.Encode(chain.key, chain.leaf, []*x509.Certificate{chain.intermediate, chain.root}, "passphrase")
+	require.NoError(t, err)
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("leaf.pem", leafPEM))
+	require.NoError(t, fSys.WriteFile("key.pem", chain.keyPEM))
+	require.NoError(t, fSys.WriteFile("ordered.pem", orderedPEM))
+	require.NoError(t, fSys.WriteFile("unordered.pem", encodeCertificates([]*x509.Certificate{chain.root, chain.leaf, chain.intermediate})))
+	require.NoError(t, fSys.WriteFile("bundle.pem", append(encodeCertificates([]*x509.Certificate{chain.intermediate}), append(chain.keyPEM, leafPEM...)...)))
+	require.NoError(t, fSys.WriteFile("unrelated.pem", encodeCertificates([]*x509.Certificate{chain.leaf, chain.intermediate, otherChain.root})))
+	require.NoError(t, fSys.WriteFile("archive.p12", pfxData))
+
+	tests := map[string]struct {
+		config         *v1.TLS
+		expectedCert   []byte
+		expectedKey    []byte
+		expectedCA     []byte
+		expectedErrror string
+	}{
+		"single certificate": {
+			config: &v1.TLS{
+				Cert: &v1.TLSData{From: v1.DataFromFile, File: "leaf.pem"},
+				Key:  &v1.TLSData{From: v1.DataFromFile, File: "key.pem"},
+			},
+			expectedCert: leafPEM,
+			expectedKey:  chain.keyPEM,
+		},
+		"ordered chain is kept as is": {
+			config: &v1.TLS{
+				Cert: &v1.TLSData{From: v1.DataFromFile, File: "ordered.pem"},
+				Key:  &v1.TLSData{From: v1.DataFromFile, File: "key.pem"},
+			},
+			expectedCert: orderedPEM,
+			expectedKey:  chain.keyPEM,
+			expectedCA:   caPEM,
+		},
+		"unordered chain is sorted starting from the leaf": {
+			config: &v1.TLS{
+				Cert: &v1.TLSData{From: v1.DataFromFile, File: "unordered.pem"},
+				Key:  &v1.TLSData{From: v1.DataFromFile, File: "key.pem"},
+			},
+			expectedCert: orderedPEM,
+			expectedKey:  chain.keyPEM,
+			expectedCA:   caPEM,
+		},
+		"bundle containing the private key": {
+			config: &v1.TLS{
+				Cert: &v1.TLSData{From: v1.DataFromFile, File: "bundle.pem"},
+			},
+			expectedCert: encodeCertificates([]*x509.Certificate{chain.leaf, chain.intermediate}),
+			expectedKey:  chain.keyPEM,
+			expectedCA:   encodeCertificates([]*x509.Certificate{chain.intermediate}),
+		},
+		"pkcs12 archive from file": {
+			config: &v1.TLS{
+				PKCS12: &v1.PKCS12{From: v1.DataFromFile, File: "archive.p12", Passphrase: "passphrase"},
+			},
+			expectedCert: orderedPEM,
+			expectedKey:  chain.pkcs8PEM,
+			expectedCA:   caPEM,
+		},
+		"pkcs12 archive from literal": {
+			config: &v1.TLS{
+				PKCS12: &v1.PKCS12{
+					From:       v1.DataFromLiteral,
+					Value:      base64.StdEncoding.EncodeToString(pfxData),
+					Passphrase: "passphrase",
+				},
+			},
+			expectedCert: orderedPEM,
+			expectedKey:  chain.pkcs8PEM,
+			expectedCA:   caPEM,
+		},
+		"pkcs12 archive with wrong passphrase": {
+			config: &v1.TLS{
+				PKCS12: &v1.PKCS12{From: v1.DataFromFile, File: "archive.p12", Passphrase: "wrong"},
+			},
+			expectedErrror: "failed to decode pkcs12 archive",
+		},
+		"pkcs12 literal not base64 encoded": {
+			config: &v1.TLS{
+				PKCS12: &v1.PKCS12{From: v1.DataFromLiteral, Value: "not base64!"},
+			},
+			expectedErrror: "failed to decode pkcs12 value",
+		},
+		"certificate not part of the chain": {
+			config: &v1.TLS{
+				Cert: &v1.TLSData{From: v1.DataFromFile, File: "unrelated.pem"},
+				Key:  &v1.TLSData{From: v1.DataFromFile, File: "key.pem"},
+			},
+			expectedErrror: `certificate "CN=root" is not part of the chain of "CN=leaf"`,
+		},
+		"key not matching the certificate": {
+			config: &v1.TLS{
+				Cert: &v1.TLSData{From: v1.DataFromFile, File: "leaf.pem"},
+				Key:  &v1.TLSData{From: v1.DataFromLiteral, Value: string(otherChain.keyPEM)},
+			},
+			expectedErrror: "private key does not match public key",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options := &Options{fSys: fSys}
+			bundle, err := options.parseTLS(test.config)
+			switch len(test.expectedErrror) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, string(test.expectedCert), string(bundle.cert))
+				assert.Equal(t, string(test.expectedKey), string(bundle.key))
+				assert.Equal(t, string(test.expectedCA), string(bundle.ca))
+				assert.True(t, chain.leaf.Equal(bundle.leaf))
+			default:
+				assert.ErrorContains(t, err, test.expectedErrror)
+			}
+		})
+	}
+}
+
+func TestExpiryWarning(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		notAfter        time.Time
+		expectedWarning string
+	}{
+		"valid certificate": {
+			notAfter: now.AddDate(0, 2, 0),
+		},
+		"certificate expiring soon": {
+			notAfter:        now.AddDate(0, 0, 10),
+			expectedWarning: "certificate will expire in less than 30 days",
+		},
+		"expired certificate": {
+			notAfter:        now.AddDate(0, 0, -1),
+			expectedWarning: "certificate is expired",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			certificate := &x509.Certificate{NotAfter: test.notAfter}
+			assert.Equal(t, test.expectedWarning, expiryWarning(certificate, 30, now))
+		})
+	}
+
+	assert.Empty(t, expiryWarning(nil, 30, now))
+}
+
+func TestSecretsFromConfigWithCAConfigMap(t *testing.T) {
+	t.Parallel()
+
+	chain := generateChain(t, time.Now().AddDate(1, 0, 0))
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("chain.pem", encodeCertificates([]*x509.Certificate{chain.leaf, chain.intermediate})))
+	require.NoError(t, fSys.WriteFile("leaf.pem", encodeCertificates([]*x509.Certificate{chain.leaf})))
+	require.NoError(t, fSys.WriteFile("key.pem", chain.keyPEM))
+
+	options := &Options{fSys: fSys, certExpiryWarningDays: certExpiryWarningDaysDefaultValue}
+	secret, configMap, err := options.secretsFromConfig(context.TODO(), v1.SecretSpec{
+		Name: "tls",
+		TLS: &v1.TLS{
+			Cert:        &v1.TLSData{From: v1.DataFromFile, File: "chain.pem"},
+			Key:         &v1.TLSData{From: v1.DataFromFile, File: "key.pem"},
+			CAConfigMap: "tls-ca",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "tls", secret.Name)
+	require.NotNil(t, configMap)
+	assert.Equal(t, "tls-ca", configMap.Name)
+	assert.Equal(t, map[string]string{
+		caConfigMapKey: string(encodeCertificates([]*x509.Certificate{chain.intermediate})),
+	}, configMap.Data)
+
+	_, _, err = options.secretsFromConfig(context.TODO(), v1.SecretSpec{
+		Name: "tls",
+		TLS: &v1.TLS{
+			Cert:        &v1.TLSData{From: v1.DataFromFile, File: "leaf.pem"},
+			Key:         &v1.TLSData{From: v1.DataFromFile, File: "key.pem"},
+			CAConfigMap: "tls-ca",
+		},
+	})
+	assert.ErrorContains(t, err, `no chain certificates found for the CA ConfigMap of secret "tls"`)
+}
+
+// testChain contains a certificate chain with a root and an intermediate CA and a leaf certificate
+type testChain struct {
+	root         *x509.Certificate
+	intermediate *x509.Certificate
+	leaf         *x509.Certificate
+	key          *ecdsa.PrivateKey
+	keyPEM       []byte
+	pkcs8PEM     []byte
+}
+
+// generateChain generates a certificate chain for testing purposes with a leaf valid until notAfter
+func generateChain(t *testing.T, notAfter time.Time) testChain {
+	t.Helper()
+
+	newCertificate := func(commonName string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+		require.NoError(t, err)
+
+		template := &x509.Certificate{
+			SerialNumber:          serial,
+			Subject:               pkix.Name{CommonName: commonName},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              notAfter,
+			IsCA:                  isCA,
+			BasicConstraintsValid: true,
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		require.NoError(t, err)
+		certificate, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return certificate, key
+	}
+
+	root, rootKey := newCertificate("root", true, nil, nil)
+	intermediate, intermediateKey := newCertificate("intermediate", true, root, rootKey)
+	leaf, leafKey := newCertificate("leaf", false, intermediate, intermediateKey)
+
+	ecDER, err := x509.MarshalECPrivateKey(leafKey)
+	require.NoError(t, err)
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	require.NoError(t, err)
+
+	return testChain{
+		root:         root,
+		intermediate: intermediate,
+		leaf:         leaf,
+		key:          leafKey,
+		keyPEM:       pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}),
+		pkcs8PEM:     pem.EncodeToMemory(&pem.Block{Type: privateKeyBlockType, Bytes: pkcs8DER}),
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/config"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/mia-platform/mlp/v2/pkg/graph"
+	"github.com/mia-platform/mlp/v2/pkg/resourceutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	cmdUsage = "graph"
+	cmdShort = "Export the graph of the dependencies between the resources"
+	cmdLong  = `Export the graph of the dependencies between the resources.
+
+	The graph contains the ConfigMaps, Secrets and ServiceAccounts used by the
+	workloads, the stores used by the ExternalSecrets and the Secrets they generate,
+	and the CustomResourceDefinitions of the custom resources. The resources that
+	are referenced but not found in the files are added with a dashed border.
+
+	The graph can be printed in the DOT language, for rendering it with Graphviz,
+	or in json.
+	`
+	cmdExamples = `# render the graph of the interpolated resources as an svg image
+	mlp graph -f interpolated-files | dot -Tsvg -o graph.svg
+
+	# print the graph as json
+	mlp graph -f interpolated-files -o json
+	`
+
+	inputPathsFlagName  = "filename"
+	inputPathsShortName = "f"
+	inputPathsFlagUsage = "file or folder paths containing the resources, use - for reading from stdin"
+
+	outputFlagName     = "output"
+	outputFlagShort    = "o"
+	outputDefaultValue = outputDOT
+	outputFlagUsage    = "output format, one of: dot, json"
+
+	outputDOT  = "dot"
+	outputJSON = "json"
+
+	stdinToken = "-"
+)
+
+var (
+	validOutputValues = []string{outputDOT, outputJSON}
+	yamlExtensions    = []string{".yaml", ".yml"}
+)
+
+// Flags contains all the flags for the `graph` command. They will be converted to Options
+// that contains all runtime options for the command.
+type Flags struct {
+	inputPaths []string
+	output     string
+}
+
+// Options have the data required to perform the graph operation
+type Options struct {
+	inputPaths        []string
+	output            string
+	projectConfigPath string
+
+	fSys   filesys.FileSystem
+	reader io.Reader
+	writer io.Writer
+}
+
+// NewCommand return the command for exporting the dependency graph of a set of resources
+func NewCommand() *cobra.Command {
+	flags := &Flags{}
+
+	cmd := &cobra.Command{
+		Use:     cmdUsage,
+		Short:   heredoc.Doc(cmdShort),
+		Long:    heredoc.Doc(cmdLong),
+		Example: heredoc.Doc(cmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(cmd.InOrStdin(), cmd.OutOrStdout(), filesys.MakeFsOnDisk())
+			cobra.CheckErr(err)
+			o.projectConfigPath = config.PathFromContext(cmd.Context())
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	if err := cmd.RegisterFlagCompletionFunc(outputFlagName, outputFlagCompletionfunc); err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+// AddFlags set the connection between Flags property to command line flags
+func (f *Flags) AddFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVarP(&f.inputPaths, inputPathsFlagName, inputPathsShortName, nil, inputPathsFlagUsage)
+	flags.StringVarP(&f.output, outputFlagName, outputFlagShort, outputDefaultValue, outputFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *Flags) ToOptions(reader io.Reader, writer io.Writer, fSys filesys.FileSystem) (*Options, error) {
+	return &Options{
+		inputPaths: f.inputPaths,
+		output:     f.output,
+		fSys:       fSys,
+		reader:     reader,
+		writer:     writer,
+	}, nil
+}
+
+// Validate check the options for errors
+func (o *Options) Validate() error {
+	if len(o.inputPaths) == 0 {
+		return fmt.Errorf("at least one path must be specified with the %q flag", inputPathsFlagName)
+	}
+
+	if len(o.inputPaths) > 1 && slices.Contains(o.inputPaths, stdinToken) {
+		return fmt.Errorf("cannot read from stdin and other paths together")
+	}
+
+	if !slices.Contains(validOutputValues, o.output) {
+		return fmt.Errorf("invalid output value: %q", o.output)
+	}
+
+	return nil
+}
+
+// Run execute the graph command
+func (o *Options) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	objects, err := o.readObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	workloads, err := o.workloads()
+	if err != nil {
+		return err
+	}
+
+	logger.V(5).Info("building dependency graph", "resources", len(objects))
+	dependencyGraph, err := graph.Build(objects, workloads)
+	if err != nil {
+		return err
+	}
+
+	if o.output == outputJSON {
+		encoder := json.NewEncoder(o.writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(dependencyGraph)
+	}
+
+	return dependencyGraph.WriteDOT(o.writer)
+}
+
+// workloads return the workloads configured in the project configuration in addition to the built-in ones
+func (o *Options) workloads() (extensions.Workloads, error) {
+	if len(o.projectConfigPath) == 0 {
+		return nil, nil
+	}
+
+	project, err := config.Load(o.fSys, o.projectConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return extensions.NewWorkloads(project.Deploy.Workloads)
+}
+
+// readObjects return the resources to add to the graph, read from stdin or from the input paths
+func (o *Options) readObjects(ctx context.Context) ([]*unstructured.Unstructured, error) {
+	if o.inputPaths[0] == stdinToken {
+		return resourceutil.Decode(o.reader)
+	}
+
+	return resourceutil.DecodePaths(ctx, o.fSys, o.inputPaths, yamlExtensions)
+}
+
+func outputFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return validOutputValues, cobra.ShellCompDirectiveDefault
+}
@@ -0,0 +1,160 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	expectedDOT = `digraph mlp {
+	rankdir=LR;
+	node [shape=box];
+	"ConfigMap/test/example" [label="ConfigMap\nexample"];
+	"Deployment.apps/test/example" [label="Deployment\nexample"];
+	"Secret/test/missing" [label="Secret\nmissing", style=dashed];
+	"Deployment.apps/test/example" -> "ConfigMap/test/example" [label="volume"];
+	"Deployment.apps/test/example" -> "Secret/test/missing" [label="env"];
+}
+`
+	expectedStdinDOT = `digraph mlp {
+	rankdir=LR;
+	node [shape=box];
+	"ConfigMap/test/example" [label="ConfigMap\nexample"];
+}
+`
+)
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewCommand()
+	assert.NotNil(t, cmd)
+	assert.NotNil(t, cmd.Flags().Lookup(inputPathsFlagName))
+	assert.NotNil(t, cmd.Flags().Lookup(outputFlagName))
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	reader := new(bytes.Buffer)
+	writer := new(bytes.Buffer)
+	fSys := filesys.MakeFsInMemory()
+
+	flags := &Flags{
+		inputPaths: []string{"input"},
+		output:     outputDOT,
+	}
+	opts, err := flags.ToOptions(reader, writer, fSys)
+	require.NoError(t, err)
+	assert.Equal(t, &Options{
+		inputPaths: []string{"input"},
+		output:     outputDOT,
+		fSys:       fSys,
+		reader:     reader,
+		writer:     writer,
+	}, opts)
+	assert.NoError(t, opts.Validate())
+
+	opts.inputPaths = []string{"input", stdinToken}
+	assert.ErrorContains(t, opts.Validate(), "cannot read from stdin and other paths together")
+	opts.inputPaths = nil
+	assert.ErrorContains(t, opts.Validate(), `at least one path must be specified with the "filename" flag`)
+
+	opts.inputPaths = []string{"input"}
+	opts.output = "yaml"
+	assert.ErrorContains(t, opts.Validate(), `invalid output value: "yaml"`)
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	testdata := "testdata"
+	stdinData, err := os.ReadFile(filepath.Join(testdata, "resources", "configmap.yml"))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		inputPaths     []string
+		output         string
+		expectedOutput string
+		expectedError  string
+	}{
+		"dot output": {
+			inputPaths:     []string{filepath.Join(testdata, "resources")},
+			output:         outputDOT,
+			expectedOutput: expectedDOT,
+		},
+		"json output": {
+			inputPaths: []string{filepath.Join(testdata, "resources", "configmap.yml")},
+			output:     outputJSON,
+			expectedOutput: `{
+  "nodes": [
+    {
+      "id": "ConfigMap/test/example",
+      "kind": "ConfigMap",
+      "name": "example",
+      "namespace": "test"
+    }
+  ],
+  "edges": []
+}
+`,
+		},
+		"read from stdin": {
+			inputPaths:     []string{stdinToken},
+			output:         outputDOT,
+			expectedOutput: expectedStdinDOT,
+		},
+		"missing path": {
+			inputPaths:    []string{filepath.Join(testdata, "missing")},
+			output:        outputDOT,
+			expectedError: "no such file or directory",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			writer := new(strings.Builder)
+			opts := &Options{
+				inputPaths: test.inputPaths,
+				output:     test.output,
+				fSys:       filesys.MakeFsOnDisk(),
+				reader:     bytes.NewReader(stdinData),
+				writer:     writer,
+			}
+
+			err := opts.Run(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+				assert.Equal(t, test.expectedOutput, writer.String())
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	deployhistory "github.com/mia-platform/mlp/v2/pkg/history"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	cmdUsage = "history"
+	cmdShort = "List the recent deploys made in a namespace"
+	cmdLong  = `List the recent deploys made in a namespace.
+
+	Every deploy saves a compact record with its date, actor, commit, resource
+	counts and result in a ConfigMap next to the inventory, keeping only the most
+	recent ones. The command will read the records and print them from the oldest
+	to the most recent.
+	`
+	cmdExamples = `# list the recent deploys in the current namespace
+	mlp history
+
+	# list the recent deploys in a specific namespace as json
+	mlp history -n my-namespace -o json
+	`
+
+	outputFlagName     = "output"
+	outputFlagShort    = "o"
+	outputDefaultValue = outputTable
+	outputFlagUsage    = "output format, one of: table, json"
+
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+var validOutputValues = []string{outputTable, outputJSON}
+
+// Flags contains all the flags for the `history` command. They will be converted to Options
+// that contains all runtime options for the command.
+type Flags struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+	output      string
+}
+
+// Options have the data required to perform the history operation
+type Options struct {
+	output    string
+	namespace string
+	clientset kubernetes.Interface
+	writer    io.Writer
+}
+
+// NewCommand return the command for listing the deploy history of a namespace
+func NewCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	flags := &Flags{
+		ConfigFlags: configFlags,
+	}
+
+	cmd := &cobra.Command{
+		Use:     cmdUsage,
+		Short:   heredoc.Doc(cmdShort),
+		Long:    heredoc.Doc(cmdLong),
+		Example: heredoc.Doc(cmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(cmd.OutOrStdout())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	if err := cmd.RegisterFlagCompletionFunc(outputFlagName, outputFlagCompletionfunc); err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+// AddFlags set the connection between Flags property to command line flags
+func (f *Flags) AddFlags(flags *pflag.FlagSet) {
+	if f.ConfigFlags != nil {
+		f.ConfigFlags.AddFlags(flags)
+	}
+
+	flags.StringVarP(&f.output, outputFlagName, outputFlagShort, outputDefaultValue, outputFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *Flags) ToOptions(writer io.Writer) (*Options, error) {
+	if f.ConfigFlags == nil {
+		return nil, fmt.Errorf("config flags are required")
+	}
+
+	namespace, _, err := f.ConfigFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	restConfig, err := f.ConfigFlags.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Options{
+		output:    f.output,
+		namespace: namespace,
+		clientset: clientset,
+		writer:    writer,
+	}, nil
+}
+
+// Validate check the options for errors
+func (o *Options) Validate() error {
+	if !slices.Contains(validOutputValues, o.output) {
+		return fmt.Errorf("invalid output value: %q", o.output)
+	}
+
+	return nil
+}
+
+// Run execute the history command
+func (o *Options) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	logger.V(5).Info("reading deploy history", "namespace", o.namespace)
+	records, err := deployhistory.NewStore(o.clientset, o.namespace).List(ctx)
+	if err != nil {
+		return err
+	}
+
+	if o.output == outputJSON {
+		encoder := json.NewEncoder(o.writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	}
+
+	if len(records) == 0 {
+		fmt.Fprintf(o.writer, "no deploy history found in namespace %q\n", o.namespace)
+		return nil
+	}
+
+	tabWriter := tabwriter.NewWriter(o.writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, "REVISION\tDATE\tACTOR\tCOMMIT\tMANAGER\tAPPLIED\tPRUNED\tFAILED\tDURATION\tRESULT")
+	for _, record := range records {
+		fmt.Fprintf(tabWriter, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\n",
+			record.Revision,
+			record.Timestamp.Format(time.RFC3339),
+			valueOrPlaceholder(record.Actor),
+			valueOrPlaceholder(shortCommit(record.Commit)),
+			valueOrPlaceholder(record.FieldManager),
+			record.Applied,
+			record.Pruned,
+			record.Failed,
+			record.Duration,
+			record.Result,
+		)
+	}
+
+	return tabWriter.Flush()
+}
+
+func outputFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return validOutputValues, cobra.ShellCompDirectiveDefault
+}
+
+// shortCommit return the abbreviated form of a git commit sha
+func shortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+	return commit
+}
+
+// valueOrPlaceholder return value or a placeholder if it is empty for keeping the table columns aligned
+func valueOrPlaceholder(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+	return value
+}
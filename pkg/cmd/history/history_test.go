@@ -0,0 +1,145 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	deployhistory "github.com/mia-platform/mlp/v2/pkg/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewCommand(genericclioptions.NewConfigFlags(false))
+	assert.NotNil(t, cmd)
+	assert.NotNil(t, cmd.Flags().Lookup(outputFlagName))
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	buffer := new(bytes.Buffer)
+	flags := &Flags{output: outputTable}
+	_, err := flags.ToOptions(buffer)
+	assert.ErrorContains(t, err, "config flags are required")
+
+	namespace := "mlp-history-test"
+	flags.ConfigFlags = genericclioptions.NewConfigFlags(false)
+	flags.ConfigFlags.Namespace = &namespace
+	opts, err := flags.ToOptions(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, namespace, opts.namespace)
+	assert.Equal(t, outputTable, opts.output)
+	assert.NotNil(t, opts.clientset)
+	assert.NoError(t, opts.Validate())
+
+	opts.output = "yaml"
+	assert.ErrorContains(t, opts.Validate(), `invalid output value: "yaml"`)
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-history-test"
+	historyConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: deployhistory.ConfigMapName, Namespace: namespace},
+		Data: map[string]string{
+			"records": `[{"revision":1,"timestamp":"2025-01-01T10:00:00Z","actor":"user","commit":"0123456789abcdef","fieldManager":"mlp","applied":3,"pruned":1,"failed":0,"duration":"12s","result":"succeeded"},{"revision":2,"timestamp":"2025-01-02T10:00:00Z","fieldManager":"mlp","applied":1,"pruned":0,"failed":2,"duration":"1m5s","result":"failed"}]`,
+		},
+	}
+
+	tests := map[string]struct {
+		objects        []runtime.Object
+		output         string
+		expectedOutput string
+	}{
+		"table output": {
+			objects: []runtime.Object{historyConfigMap},
+			output:  outputTable,
+			expectedOutput: heredoc.Doc(`
+				REVISION  DATE                  ACTOR  COMMIT    MANAGER  APPLIED  PRUNED  FAILED  DURATION  RESULT
+				1         2025-01-01T10:00:00Z  user   01234567  mlp      3        1       0       12s       succeeded
+				2         2025-01-02T10:00:00Z  -      -         mlp      1        0       2       1m5s      failed
+			`),
+		},
+		"json output": {
+			objects: []runtime.Object{historyConfigMap},
+			output:  outputJSON,
+			expectedOutput: heredoc.Doc(`
+				[
+				  {
+				    "revision": 1,
+				    "timestamp": "2025-01-01T10:00:00Z",
+				    "actor": "user",
+				    "commit": "0123456789abcdef",
+				    "fieldManager": "mlp",
+				    "applied": 3,
+				    "pruned": 1,
+				    "failed": 0,
+				    "duration": "12s",
+				    "result": "succeeded"
+				  },
+				  {
+				    "revision": 2,
+				    "timestamp": "2025-01-02T10:00:00Z",
+				    "fieldManager": "mlp",
+				    "applied": 1,
+				    "pruned": 0,
+				    "failed": 2,
+				    "duration": "1m5s",
+				    "result": "failed"
+				  }
+				]
+			`),
+		},
+		"empty history": {
+			output:         outputTable,
+			expectedOutput: "no deploy history found in namespace \"mlp-history-test\"\n",
+		},
+		"empty history as json": {
+			output:         outputJSON,
+			expectedOutput: "[]\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			buffer := new(bytes.Buffer)
+			opts := &Options{
+				output:    test.output,
+				namespace: namespace,
+				clientset: fake.NewSimpleClientset(test.objects...),
+				writer:    buffer,
+			}
+
+			require.NoError(t, opts.Run(context.TODO()))
+			assert.Equal(t, test.expectedOutput, buffer.String())
+		})
+	}
+}
@@ -18,6 +18,7 @@ package hydrate
 import (
 	"cmp"
 	"context"
+	"fmt"
 	"path/filepath"
 	"regexp"
 	"slices"
@@ -25,7 +26,10 @@ import (
 
 	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/interpolate"
+	"github.com/mia-platform/mlp/v2/pkg/config"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
@@ -38,6 +42,13 @@ const (
 	The command will create a new 'kustomization.yaml' file if does not already exists
 	in the target folders and then will add all the file called '*.patch.yaml' or
 	'*.patch.yml' as patches and all the rest '*.yaml' or '*.yml' file as resources.
+
+	A 'mlp-overlay.yaml' file in the folder can set the replicas, the images and the
+	resources of the workloads by name, without writing patch files for them.
+
+	The kustomize components set with the --component flag, or mapped to the env prefixes
+	set with --env-prefix in the project configuration, are added to the components
+	of the kustomization files.
 	`
 	cmdExamples = `# hydrate only one folder
 	mlp hydrate configuration
@@ -47,17 +58,55 @@ const (
 
 	# hydrate current folder
 	mlp hydrate
+
+	# hydrate a folder without touching the managed-by label already set by another tool
+	mlp hydrate configuration --managed-by-policy if-absent
+
+	# hydrate a folder enabling the components of the DEV_ environment and the debug one
+	mlp hydrate overlays/development --env-prefix DEV_ --component ../../components/debug
 	`
+
+	managedByFlagName    = "managed-by"
+	managedByDefault     = "mlp"
+	managedByFlagUsage   = "value of the " + managedByLabel + " label added to the kustomization files"
+	managedByPolicyName  = "managed-by-policy"
+	managedByPolicyUsage = "when to set the managed-by label (accepted values: always, if-absent, never)"
+
+	componentFlagName  = "component"
+	componentFlagUsage = "path of a kustomize component to add to the kustomization files, relative to their folder"
+	prefixesFlagName   = "env-prefix"
+	prefixesFlagShort  = "e"
+	prefixesFlagUsage  = "env prefixes used for selecting the components mapped to them in the project configuration"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+
+	managedByPolicyAlways   = "always"
+	managedByPolicyIfAbsent = "if-absent"
+	managedByPolicyNever    = "never"
+)
+
+var (
+	validManagedByPolicyValues = []string{managedByPolicyAlways, managedByPolicyIfAbsent, managedByPolicyNever}
 )
 
 // Flags contains all the flags for the `hydrate` command. They will be converted to Options
 // that contains all runtime options for the command.
-type Flags struct{}
+type Flags struct {
+	managedBy       string
+	managedByPolicy string
+	components      []string
+	envPrefixes     []string
+}
 
 // Options have the data required to perform the hydrate operation
 type Options struct {
-	paths []string
-	fSys  filesys.FileSystem
+	paths             []string
+	managedBy         string
+	managedByPolicy   string
+	components        []string
+	envPrefixes       []string
+	projectConfigPath string
+	fSys              filesys.FileSystem
 }
 
 // NewCommand return the command for generating kustomization files in target folders and populating the resource
@@ -73,15 +122,30 @@ func NewCommand() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			o, err := flags.ToOptions(args, filesys.MakeFsOnDisk())
 			cobra.CheckErr(err)
+			o.projectConfigPath = config.PathFromContext(cmd.Context())
+			cobra.CheckErr(o.Validate())
 			cobra.CheckErr(o.Run(cmd.Context()))
 		},
 	}
 
+	flags.AddFlags(cmd.Flags())
+	if err := cmd.RegisterFlagCompletionFunc(managedByPolicyName, managedByPolicyCompletionFunc); err != nil {
+		panic(err)
+	}
+
 	return cmd
 }
 
+// AddFlags set the connection between Flags property to command line flags
+func (f *Flags) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&f.managedBy, managedByFlagName, managedByDefault, managedByFlagUsage)
+	flags.StringVar(&f.managedByPolicy, managedByPolicyName, managedByPolicyAlways, managedByPolicyUsage)
+	flags.StringSliceVar(&f.components, componentFlagName, nil, componentFlagUsage)
+	flags.StringSliceVarP(&f.envPrefixes, prefixesFlagName, prefixesFlagShort, nil, prefixesFlagUsage)
+}
+
 // ToOptions transform the command flags in command runtime arguments
-func (*Flags) ToOptions(args []string, fSys filesys.FileSystem) (*Options, error) {
+func (f *Flags) ToOptions(args []string, fSys filesys.FileSystem) (*Options, error) {
 	var paths []string
 	switch len(args) {
 	case 0:
@@ -91,15 +155,59 @@ func (*Flags) ToOptions(args []string, fSys filesys.FileSystem) (*Options, error
 	}
 
 	return &Options{
-		paths: paths,
-		fSys:  fSys,
+		paths:           paths,
+		managedBy:       f.managedBy,
+		managedByPolicy: f.managedByPolicy,
+		components:      f.components,
+		envPrefixes:     f.envPrefixes,
+		fSys:            fSys,
 	}, nil
 }
 
+// NewOptions return the Options for hydrating the kustomization files in paths with the default managed-by label
+func NewOptions(paths []string, fSys filesys.FileSystem) *Options {
+	return &Options{
+		paths:           paths,
+		managedBy:       managedByDefault,
+		managedByPolicy: managedByPolicyAlways,
+		fSys:            fSys,
+	}
+}
+
+// Validate will check that the options are consistent
+func (o *Options) Validate() error {
+	if !slices.Contains(validManagedByPolicyValues, o.managedByPolicy) {
+		return fmt.Errorf("invalid managed-by policy value: %q", o.managedByPolicy)
+	}
+
+	if o.managedByPolicy != managedByPolicyNever && len(o.managedBy) == 0 {
+		return fmt.Errorf("the %q flag cannot be empty, use %q to skip the label", managedByFlagName, managedByPolicyNever)
+	}
+
+	if slices.Contains(o.components, "") {
+		return fmt.Errorf("the %q flag cannot be empty", componentFlagName)
+	}
+
+	return nil
+}
+
 // Run execute the hydrate command
 func (o *Options) Run(ctx context.Context) error {
 	logger := logr.FromContextOrDiscard(ctx)
 
+	if len(o.envPrefixes) > 0 && len(o.projectConfigPath) > 0 {
+		project, err := config.Load(filesys.MakeFsOnDisk(), o.projectConfigPath)
+		if err != nil {
+			return err
+		}
+
+		for _, component := range project.Hydrate.ComponentsFor(interpolate.PrefixNames(o.envPrefixes)) {
+			if !slices.Contains(o.components, component) {
+				o.components = append(o.components, component)
+			}
+		}
+	}
+
 	logger.V(5).Info("hydrating files", "paths", strings.Join(o.paths, ", "))
 	for _, path := range o.paths {
 		if err := o.hydrateKustomize(ctx, path); err != nil {
@@ -123,6 +231,7 @@ func (o *Options) hydrateKustomize(ctx context.Context, path string) error {
 	logger.V(8).Info("files found", "paths", strings.Join(files, ", "))
 	var resources []string
 	var patches []string
+	var overlay string
 	regex := regexp.MustCompile(`(^|\.)patch\.ya?ml$`)
 	yamlExtensions := []string{".yaml", ".yml"}
 	for _, file := range files {
@@ -132,11 +241,14 @@ func (o *Options) hydrateKustomize(ctx context.Context, path string) error {
 			continue
 		}
 
-		switch regex.MatchString(normalizedName) {
-		case true:
+		switch {
+		case overlayFileRegex.MatchString(normalizedName):
+			logger.V(10).Info("overlay found", "path", file)
+			overlay = file
+		case regex.MatchString(normalizedName):
 			logger.V(10).Info("patch found", "path", file)
 			patches = append(patches, file)
-		case false:
+		default:
 			logger.V(10).Info("resource found", "path", file)
 			resources = append(resources, file)
 		}
@@ -144,15 +256,15 @@ func (o *Options) hydrateKustomize(ctx context.Context, path string) error {
 
 	slices.SortStableFunc(resources, cmp.Compare)
 	slices.SortStableFunc(patches, cmp.Compare)
-	return updateKustomize(ctx, o.fSys, path, resources, patches)
+	return o.updateKustomize(ctx, path, resources, patches, overlay)
 }
 
 // updateKustomize will read the kustomization file at path and will add resources and patches if not already
-// present in the file
-func updateKustomize(ctx context.Context, fSys filesys.FileSystem, path string, resources, patches []string) error {
+// present in the file, and the replicas and patches described in the overlay file if not empty
+func (o *Options) updateKustomize(ctx context.Context, path string, resources, patches []string, overlay string) error {
 	logger := logr.FromContextOrDiscard(ctx)
 
-	kf, err := newKustomizationFile(fSys, path)
+	kf, err := newKustomizationFile(o.fSys, path)
 	if err != nil {
 		return err
 	}
@@ -190,8 +302,53 @@ func updateKustomize(ctx context.Context, fSys filesys.FileSystem, path string,
 		}
 	}
 
-	// add managed by annotation to allow empty kustomization files
-	k.MetaData = &types.ObjectMeta{Labels: map[string]string{"app.kubernetes.io/managed-by": "mlp"}}
+	for _, component := range o.components {
+		if slices.Contains(k.Components, component) {
+			continue
+		}
+		if !o.fSys.Exists(filepath.Join(path, component)) {
+			return fmt.Errorf("component %q not found in %q", component, path)
+		}
+		logger.V(8).Info("adding component", "path", component)
+		k.Components = append(k.Components, component)
+	}
+
+	if len(overlay) > 0 {
+		logger.V(5).Info("applying overlay", "path", overlay)
+		environmentOverlay, err := readOverlay(o.fSys, filepath.Join(path, overlay))
+		if err != nil {
+			return err
+		}
+		if err := environmentOverlay.apply(k); err != nil {
+			return err
+		}
+	}
+
+	// add managed by label to allow empty kustomization files
+	o.setManagedByLabel(k)
 	logger.V(5).Info("saving kustomization file", "path", path)
 	return kf.write(k)
 }
+
+// setManagedByLabel add the managed-by label to the kustomization metadata following the configured policy
+func (o *Options) setManagedByLabel(k *types.Kustomization) {
+	if o.managedByPolicy == managedByPolicyNever {
+		return
+	}
+
+	if k.MetaData == nil {
+		k.MetaData = &types.ObjectMeta{}
+	}
+	if k.MetaData.Labels == nil {
+		k.MetaData.Labels = make(map[string]string)
+	}
+
+	if _, found := k.MetaData.Labels[managedByLabel]; found && o.managedByPolicy == managedByPolicyIfAbsent {
+		return
+	}
+	k.MetaData.Labels[managedByLabel] = o.managedBy
+}
+
+func managedByPolicyCompletionFunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return validManagedByPolicyValues, cobra.ShellCompDirectiveDefault
+}
@@ -16,6 +16,7 @@
 package hydrate
 
 import (
+	"cmp"
 	"context"
 	"os"
 	"path/filepath"
@@ -24,6 +25,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
@@ -44,16 +46,116 @@ func TestNewCommand(t *testing.T) {
 func TestToOptions(t *testing.T) {
 	t.Parallel()
 
-	flags := &Flags{}
+	flags := &Flags{managedBy: managedByDefault, managedByPolicy: managedByPolicyAlways}
 	fSys := filesys.MakeEmptyDirInMemory()
 	o, err := flags.ToOptions(nil, fSys)
 	require.NoError(t, err)
-	assert.Equal(t, &Options{paths: []string{filesys.SelfDir}, fSys: fSys}, o)
+	assert.Equal(t, &Options{
+		paths:           []string{filesys.SelfDir},
+		managedBy:       managedByDefault,
+		managedByPolicy: managedByPolicyAlways,
+		fSys:            fSys,
+	}, o)
 
 	paths := []string{"one", "two"}
 	o, err = flags.ToOptions(paths, fSys)
 	require.NoError(t, err)
-	assert.Equal(t, &Options{paths: paths, fSys: fSys}, o)
+	assert.Equal(t, &Options{
+		paths:           paths,
+		managedBy:       managedByDefault,
+		managedByPolicy: managedByPolicyAlways,
+		fSys:            fSys,
+	}, o)
+	assert.Equal(t, o, NewOptions(paths, fSys))
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		options       *Options
+		expectedError string
+	}{
+		"default values": {
+			options: &Options{managedBy: managedByDefault, managedByPolicy: managedByPolicyAlways},
+		},
+		"empty value with never policy": {
+			options: &Options{managedByPolicy: managedByPolicyNever},
+		},
+		"empty value with if-absent policy": {
+			options:       &Options{managedByPolicy: managedByPolicyIfAbsent},
+			expectedError: `the "managed-by" flag cannot be empty`,
+		},
+		"invalid policy": {
+			options:       &Options{managedBy: managedByDefault, managedByPolicy: "sometimes"},
+			expectedError: `invalid managed-by policy value: "sometimes"`,
+		},
+		"empty component": {
+			options:       &Options{managedBy: managedByDefault, managedByPolicy: managedByPolicyAlways, components: []string{""}},
+			expectedError: `the "component" flag cannot be empty`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := test.options.Validate()
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestSetManagedByLabel(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		metadata         *types.ObjectMeta
+		managedBy        string
+		policy           string
+		expectedMetadata *types.ObjectMeta
+	}{
+		"always set label on missing metadata": {
+			managedBy:        "mlp",
+			policy:           managedByPolicyAlways,
+			expectedMetadata: &types.ObjectMeta{Labels: map[string]string{managedByLabel: "mlp"}},
+		},
+		"always override existing label": {
+			metadata:         &types.ObjectMeta{Name: "name", Labels: map[string]string{managedByLabel: "argocd", "key": "value"}},
+			managedBy:        "mlp",
+			policy:           managedByPolicyAlways,
+			expectedMetadata: &types.ObjectMeta{Name: "name", Labels: map[string]string{managedByLabel: "mlp", "key": "value"}},
+		},
+		"if absent keep existing label": {
+			metadata:         &types.ObjectMeta{Labels: map[string]string{managedByLabel: "argocd"}},
+			managedBy:        "mlp",
+			policy:           managedByPolicyIfAbsent,
+			expectedMetadata: &types.ObjectMeta{Labels: map[string]string{managedByLabel: "argocd"}},
+		},
+		"if absent set missing label": {
+			metadata:         &types.ObjectMeta{Name: "name"},
+			managedBy:        "pipeline",
+			policy:           managedByPolicyIfAbsent,
+			expectedMetadata: &types.ObjectMeta{Name: "name", Labels: map[string]string{managedByLabel: "pipeline"}},
+		},
+		"never set the label": {
+			policy: managedByPolicyNever,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			options := &Options{managedBy: test.managedBy, managedByPolicy: test.policy}
+			kustomization := &types.Kustomization{MetaData: test.metadata}
+			options.setManagedByLabel(kustomization)
+			assert.Equal(t, test.expectedMetadata, kustomization.MetaData)
+		})
+	}
 }
 
 func TestRun(t *testing.T) {
@@ -112,8 +214,10 @@ resources:
 		t.Run(name, func(t *testing.T) {
 			fSys := testingInMemoryFSys(t)
 			options := &Options{
-				paths: test.paths,
-				fSys:  fSys,
+				paths:           test.paths,
+				managedBy:       managedByDefault,
+				managedByPolicy: managedByPolicyAlways,
+				fSys:            fSys,
 			}
 			err := options.Run(context.TODO())
 			switch len(test.expectedError) {
@@ -132,6 +236,82 @@ resources:
 	}
 }
 
+func TestRunComponents(t *testing.T) {
+	t.Parallel()
+
+	projectConfigPath := filepath.Join(t.TempDir(), "mlp.yaml")
+	require.NoError(t, os.WriteFile(projectConfigPath, []byte(`hydrate:
+  components:
+    DEV_:
+    - ../components/debug
+    - ../components/mesh
+`), 0600))
+
+	tests := map[string]struct {
+		components         []string
+		envPrefixes        []string
+		expectedComponents string
+		expectedError      string
+	}{
+		"no components": {},
+		"components from flag": {
+			components: []string{"../components/mesh"},
+			expectedComponents: `components:
+- ../components/existing
+- ../components/mesh
+`,
+		},
+		"components from env prefix": {
+			components:  []string{"../components/existing"},
+			envPrefixes: []string{"DEV_", "PROD_"},
+			expectedComponents: `components:
+- ../components/existing
+- ../components/debug
+- ../components/mesh
+`,
+		},
+		"missing component": {
+			components:    []string{"../components/missing"},
+			expectedError: `component "../components/missing" not found in "overlay"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fSys := filesys.MakeEmptyDirInMemory()
+			for _, component := range []string{"debug", "existing", "mesh"} {
+				require.NoError(t, fSys.MkdirAll(filepath.Join("components", component)))
+			}
+			require.NoError(t, fSys.MkdirAll("overlay"))
+			require.NoError(t, fSys.WriteFile(filepath.Join("overlay", "kustomization.yaml"), []byte(`components:
+- ../components/existing
+`)))
+
+			options := &Options{
+				paths:             []string{"overlay"},
+				managedByPolicy:   managedByPolicyNever,
+				components:        test.components,
+				envPrefixes:       test.envPrefixes,
+				projectConfigPath: projectConfigPath,
+				fSys:              fSys,
+			}
+			err := options.Run(context.TODO())
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			expectedComponents := cmp.Or(test.expectedComponents, "components:\n- ../components/existing\n")
+			data, err := fSys.ReadFile(filepath.Join("overlay", "kustomization.yaml"))
+			require.NoError(t, err)
+			assert.Equal(t, "kind: Kustomization\napiVersion: kustomize.config.k8s.io/v1beta1\n"+expectedComponents, string(data))
+		})
+	}
+}
+
 func testingInMemoryFSys(t *testing.T) filesys.FileSystem {
 	t.Helper()
 	overlaysFolder := filepath.Join("overlays", "environment")
@@ -0,0 +1,157 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hydrate
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// workloadKinds is the kind selector used for targeting the workloads patched by the overlay
+	workloadKinds = "(Deployment|StatefulSet|DaemonSet)"
+)
+
+var (
+	overlayFileRegex = regexp.MustCompile(`^mlp-overlay\.ya?ml$`)
+)
+
+// environmentOverlay is the content of the overlay file with the per environment values of the workloads
+type environmentOverlay struct {
+	Workloads map[string]workloadOverlay `json:"workloads,omitempty"`
+}
+
+// workloadOverlay contains the values to change for a single workload
+type workloadOverlay struct {
+	Replicas   *int64                      `json:"replicas,omitempty"`
+	Containers map[string]containerOverlay `json:"containers,omitempty"`
+}
+
+// containerOverlay contains the values to change for a single container of a workload
+type containerOverlay struct {
+	Image     string                       `json:"image,omitempty"`
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// readOverlay return the environmentOverlay saved in the file at path
+func readOverlay(fSys filesys.FileSystem, path string) (*environmentOverlay, error) {
+	data, err := fSys.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	overlay := new(environmentOverlay)
+	if err := yaml.UnmarshalStrict(data, overlay); err != nil {
+		return nil, fmt.Errorf("invalid overlay file %q: %w", path, err)
+	}
+
+	return overlay, nil
+}
+
+// apply add the replicas and the container patches of overlay to k, replacing the ones already generated for
+// the same workloads
+func (overlay *environmentOverlay) apply(k *types.Kustomization) error {
+	for _, name := range slices.Sorted(maps.Keys(overlay.Workloads)) {
+		workload := overlay.Workloads[name]
+		if workload.Replicas != nil {
+			setReplicas(k, name, *workload.Replicas)
+		}
+
+		if len(workload.Containers) == 0 {
+			continue
+		}
+
+		patch, err := workload.patch(name)
+		if err != nil {
+			return err
+		}
+		setPatch(k, types.Patch{
+			Patch: patch,
+			Target: &types.Selector{
+				ResId: resid.ResId{
+					Gvk:  resid.Gvk{Kind: workloadKinds},
+					Name: regexp.QuoteMeta(name),
+				},
+			},
+		})
+	}
+
+	return nil
+}
+
+// patch return the strategic merge patch for the containers of the workload with name
+func (workload workloadOverlay) patch(name string) (string, error) {
+	containers := make([]map[string]interface{}, 0, len(workload.Containers))
+	for _, containerName := range slices.Sorted(maps.Keys(workload.Containers)) {
+		container := workload.Containers[containerName]
+		patch := map[string]interface{}{"name": containerName}
+		if len(container.Image) > 0 {
+			patch["image"] = container.Image
+		}
+		if container.Resources != nil {
+			patch["resources"] = container.Resources
+		}
+		containers = append(containers, patch)
+	}
+
+	data, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{"containers": containers},
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating patch for workload %q: %w", name, err)
+	}
+
+	return string(data), nil
+}
+
+// setReplicas set the replicas count for the resource with name, updating the existing entry if present
+func setReplicas(k *types.Kustomization, name string, count int64) {
+	for idx, replica := range k.Replicas {
+		if replica.Name == name {
+			k.Replicas[idx].Count = count
+			return
+		}
+	}
+
+	k.Replicas = append(k.Replicas, types.Replica{Name: name, Count: count})
+}
+
+// setPatch add the inline patch to k, replacing the inline patch with the same target if present
+func setPatch(k *types.Kustomization, patch types.Patch) {
+	for idx, existing := range k.Patches {
+		if len(existing.Path) == 0 && existing.Target != nil && existing.Target.ResId == patch.Target.ResId {
+			k.Patches[idx] = patch
+			return
+		}
+	}
+
+	k.Patches = append(k.Patches, patch)
+}
@@ -0,0 +1,147 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hydrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+func TestOverlayApply(t *testing.T) {
+	t.Parallel()
+
+	replicas := int64(2)
+	overlay := &environmentOverlay{
+		Workloads: map[string]workloadOverlay{
+			"api": {
+				Replicas: &replicas,
+				Containers: map[string]containerOverlay{
+					"api": {Image: "nexus.example.com/api:1.2.0"},
+				},
+			},
+			"worker.v2": {Replicas: &replicas},
+		},
+	}
+
+	expectedPatch := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      containers:
+      - image: nexus.example.com/api:1.2.0
+        name: api
+`
+	apiTarget := &types.Selector{ResId: resid.ResId{Gvk: resid.Gvk{Kind: workloadKinds}, Name: "api"}}
+
+	tests := map[string]struct {
+		kustomization *types.Kustomization
+		expected      *types.Kustomization
+	}{
+		"empty kustomization": {
+			kustomization: &types.Kustomization{},
+			expected: &types.Kustomization{
+				Replicas: []types.Replica{{Name: "api", Count: 2}, {Name: "worker.v2", Count: 2}},
+				Patches:  []types.Patch{{Patch: expectedPatch, Target: apiTarget}},
+			},
+		},
+		"replace previous values": {
+			kustomization: &types.Kustomization{
+				Replicas: []types.Replica{{Name: "other", Count: 1}, {Name: "api", Count: 5}},
+				Patches: []types.Patch{
+					{Path: "api.patch.yaml", Target: apiTarget},
+					{Patch: "old patch", Target: apiTarget},
+				},
+			},
+			expected: &types.Kustomization{
+				Replicas: []types.Replica{{Name: "other", Count: 1}, {Name: "api", Count: 2}, {Name: "worker.v2", Count: 2}},
+				Patches: []types.Patch{
+					{Path: "api.patch.yaml", Target: apiTarget},
+					{Patch: expectedPatch, Target: apiTarget},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.NoError(t, overlay.apply(test.kustomization))
+			assert.Equal(t, test.expected, test.kustomization)
+		})
+	}
+}
+
+func TestReadOverlay(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("valid.yaml", []byte("workloads:\n  api:\n    replicas: 3\n")))
+	require.NoError(t, fSys.WriteFile("invalid.yaml", []byte("workloads:\n  api:\n    replica: 3\n")))
+
+	overlay, err := readOverlay(fSys, "valid.yaml")
+	require.NoError(t, err)
+	replicas := int64(3)
+	assert.Equal(t, &environmentOverlay{Workloads: map[string]workloadOverlay{"api": {Replicas: &replicas}}}, overlay)
+
+	_, err = readOverlay(fSys, "invalid.yaml")
+	assert.ErrorContains(t, err, `invalid overlay file "invalid.yaml"`)
+
+	_, err = readOverlay(fSys, "missing.yaml")
+	assert.Error(t, err)
+}
+
+func TestRunWithOverlay(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	testdata := filepath.Join("testdata", "overlay")
+	for _, file := range []string{"deployment.yaml", "statefulset.yaml", "mlp-overlay.yaml", "kustomization.yaml"} {
+		data, err := os.ReadFile(filepath.Join(testdata, file))
+		require.NoError(t, err)
+		require.NoError(t, fSys.WriteFile(filepath.Join("overlay", file), data))
+	}
+
+	options := &Options{
+		paths:           []string{"overlay"},
+		managedByPolicy: managedByPolicyNever,
+		fSys:            fSys,
+	}
+	require.NoError(t, options.Run(context.TODO()))
+	// running a second time must not duplicate the generated entries
+	require.NoError(t, options.Run(context.TODO()))
+
+	resMap, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, "overlay")
+	require.NoError(t, err)
+	data, err := resMap.AsYaml()
+	require.NoError(t, err)
+
+	expected, err := os.ReadFile(filepath.Join(testdata, "expected.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(data))
+}
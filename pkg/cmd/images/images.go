@@ -0,0 +1,320 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/config"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/mia-platform/mlp/v2/pkg/resourceutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	cmdUsage = "images"
+	cmdShort = "List the container images used by the resources"
+	cmdLong  = `List the container images used by the resources.
+
+	For every container and init container of the workloads found in the files
+	the command prints the image with its tag and digest, and if it is using a
+	semantic version; the images pinned to a digest are considered compliant, like
+	the smart_deploy type of the deploy command does.
+
+	The list can be printed as a table or in json, for passing it to the tools
+	used for scanning the images.
+	`
+	cmdExamples = `# print the images used by the interpolated resources
+	mlp images -f interpolated-files
+
+	# print the images as json
+	mlp images -f interpolated-files -o json
+	`
+
+	inputPathsFlagName  = "filename"
+	inputPathsShortName = "f"
+	inputPathsFlagUsage = "file or folder paths containing the resources, use - for reading from stdin"
+
+	outputFlagName     = "output"
+	outputFlagShort    = "o"
+	outputDefaultValue = outputTable
+	outputFlagUsage    = "output format, one of: table, json"
+
+	outputTable = "table"
+	outputJSON  = "json"
+
+	stdinToken = "-"
+)
+
+var (
+	validOutputValues = []string{outputTable, outputJSON}
+	yamlExtensions    = []string{".yaml", ".yml"}
+)
+
+// Image is a container image used by a workload
+type Image struct {
+	Kind          string `json:"kind"`
+	Name          string `json:"name"`
+	Namespace     string `json:"namespace,omitempty"`
+	Container     string `json:"container"`
+	InitContainer bool   `json:"initContainer,omitempty"`
+	Image         string `json:"image"`
+	Tag           string `json:"tag,omitempty"`
+	Digest        string `json:"digest,omitempty"`
+	Semver        bool   `json:"semver"`
+}
+
+// Flags contains all the flags for the `images` command. They will be converted to Options
+// that contains all runtime options for the command.
+type Flags struct {
+	inputPaths []string
+	output     string
+}
+
+// Options have the data required to perform the images operation
+type Options struct {
+	inputPaths        []string
+	output            string
+	projectConfigPath string
+
+	fSys   filesys.FileSystem
+	reader io.Reader
+	writer io.Writer
+}
+
+// NewCommand return the command for listing the container images used by a set of resources
+func NewCommand() *cobra.Command {
+	flags := &Flags{}
+
+	cmd := &cobra.Command{
+		Use:     cmdUsage,
+		Short:   heredoc.Doc(cmdShort),
+		Long:    heredoc.Doc(cmdLong),
+		Example: heredoc.Doc(cmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(cmd.InOrStdin(), cmd.OutOrStdout(), filesys.MakeFsOnDisk())
+			cobra.CheckErr(err)
+			o.projectConfigPath = config.PathFromContext(cmd.Context())
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	if err := cmd.RegisterFlagCompletionFunc(outputFlagName, outputFlagCompletionfunc); err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+// AddFlags set the connection between Flags property to command line flags
+func (f *Flags) AddFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVarP(&f.inputPaths, inputPathsFlagName, inputPathsShortName, nil, inputPathsFlagUsage)
+	flags.StringVarP(&f.output, outputFlagName, outputFlagShort, outputDefaultValue, outputFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *Flags) ToOptions(reader io.Reader, writer io.Writer, fSys filesys.FileSystem) (*Options, error) {
+	return &Options{
+		inputPaths: f.inputPaths,
+		output:     f.output,
+		fSys:       fSys,
+		reader:     reader,
+		writer:     writer,
+	}, nil
+}
+
+// Validate check the options for errors
+func (o *Options) Validate() error {
+	if len(o.inputPaths) == 0 {
+		return fmt.Errorf("at least one path must be specified with the %q flag", inputPathsFlagName)
+	}
+
+	if len(o.inputPaths) > 1 && slices.Contains(o.inputPaths, stdinToken) {
+		return fmt.Errorf("cannot read from stdin and other paths together")
+	}
+
+	if !slices.Contains(validOutputValues, o.output) {
+		return fmt.Errorf("invalid output value: %q", o.output)
+	}
+
+	return nil
+}
+
+// Run execute the images command
+func (o *Options) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	objects, err := o.readObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	workloads, err := o.workloads()
+	if err != nil {
+		return err
+	}
+
+	logger.V(5).Info("listing images", "resources", len(objects))
+	images, err := List(objects, workloads)
+	if err != nil {
+		return err
+	}
+
+	if o.output == outputJSON {
+		encoder := json.NewEncoder(o.writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(images)
+	}
+
+	return writeTable(o.writer, images)
+}
+
+// workloads return the workloads configured in the project configuration in addition to the built-in ones
+func (o *Options) workloads() (extensions.Workloads, error) {
+	if len(o.projectConfigPath) == 0 {
+		return nil, nil
+	}
+
+	project, err := config.Load(o.fSys, o.projectConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return extensions.NewWorkloads(project.Deploy.Workloads)
+}
+
+// List return the images used by the containers of the pods and workloads in objects, sorted by workload and
+// container
+func List(objects []*unstructured.Unstructured, workloads extensions.Workloads) ([]Image, error) {
+	images := make([]Image, 0)
+	for _, obj := range objects {
+		fields, found := workloads.PodSpecFields(obj.GroupVersionKind().GroupKind())
+		if !found {
+			continue
+		}
+
+		unstructuredPodSpec, found, err := unstructured.NestedMap(obj.Object, fields...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pod spec of %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		if !found {
+			continue
+		}
+
+		podSpec := corev1.PodSpec{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredPodSpec, &podSpec); err != nil {
+			return nil, fmt.Errorf("failed to read pod spec of %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+
+		for idx, container := range slices.Concat(podSpec.InitContainers, podSpec.Containers) {
+			image, err := imageForContainer(obj, container, idx < len(podSpec.InitContainers))
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, image)
+		}
+	}
+
+	slices.SortStableFunc(images, func(a, b Image) int {
+		return cmp.Or(
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Kind, b.Kind),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+	return images, nil
+}
+
+// imageForContainer return the Image used by container of the workload obj
+func imageForContainer(obj *unstructured.Unstructured, container corev1.Container, initContainer bool) (Image, error) {
+	tag, digest, err := extensions.ParseImageTag(container.Image)
+	if err != nil {
+		return Image{}, fmt.Errorf("invalid image for container %q of %s %q: %w", container.Name, obj.GetKind(), obj.GetName(), err)
+	}
+
+	notUsingSemver, err := extensions.IsNotUsingSemver(container.Image)
+	if err != nil {
+		return Image{}, fmt.Errorf("invalid image for container %q of %s %q: %w", container.Name, obj.GetKind(), obj.GetName(), err)
+	}
+
+	return Image{
+		Kind:          obj.GetKind(),
+		Name:          obj.GetName(),
+		Namespace:     obj.GetNamespace(),
+		Container:     container.Name,
+		InitContainer: initContainer,
+		Image:         container.Image,
+		Tag:           tag,
+		Digest:        digest,
+		Semver:        !notUsingSemver,
+	}, nil
+}
+
+// writeTable print images as a table with a row for every container
+func writeTable(writer io.Writer, images []Image) error {
+	tw := tabwriter.NewWriter(writer, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "WORKLOAD\tCONTAINER\tIMAGE\tTAG\tDIGEST\tSEMVER")
+	for _, image := range images {
+		workload := image.Kind + "/" + image.Name
+		if len(image.Namespace) > 0 {
+			workload = image.Namespace + "/" + workload
+		}
+
+		container := image.Container
+		if image.InitContainer {
+			container += " (init)"
+		}
+
+		semver := "no"
+		if image.Semver {
+			semver = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", workload, container, image.Image, cmp.Or(image.Tag, "-"), cmp.Or(image.Digest, "-"), semver)
+	}
+
+	return tw.Flush()
+}
+
+// readObjects return the resources whose images are listed, read from stdin or from the input paths
+func (o *Options) readObjects(ctx context.Context) ([]*unstructured.Unstructured, error) {
+	if o.inputPaths[0] == stdinToken {
+		return resourceutil.Decode(o.reader)
+	}
+
+	return resourceutil.DecodePaths(ctx, o.fSys, o.inputPaths, yamlExtensions)
+}
+
+func outputFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return validOutputValues, cobra.ShellCompDirectiveDefault
+}
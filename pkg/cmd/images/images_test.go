@@ -0,0 +1,192 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	expectedTable = `WORKLOAD               CONTAINER   IMAGE     TAG      DIGEST   SEMVER
+test/CronJob/cleanup   cleanup     busybox   latest   -        no
+`
+	expectedJSON = `[
+  {
+    "kind": "Deployment",
+    "name": "example",
+    "namespace": "test",
+    "container": "migrations",
+    "initContainer": true,
+    "image": "registry.example.com/example/migrations@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+    "digest": "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+    "semver": true
+  },
+  {
+    "kind": "Deployment",
+    "name": "example",
+    "namespace": "test",
+    "container": "example",
+    "image": "registry.example.com/example/api:1.2.3",
+    "tag": "1.2.3",
+    "semver": true
+  },
+  {
+    "kind": "Deployment",
+    "name": "example",
+    "namespace": "test",
+    "container": "proxy",
+    "image": "nginx:stable",
+    "tag": "stable",
+    "semver": false
+  }
+]
+`
+)
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewCommand()
+	assert.NotNil(t, cmd)
+	assert.NotNil(t, cmd.Flags().Lookup(inputPathsFlagName))
+	assert.NotNil(t, cmd.Flags().Lookup(outputFlagName))
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	reader := new(bytes.Buffer)
+	writer := new(bytes.Buffer)
+	fSys := filesys.MakeFsInMemory()
+
+	flags := &Flags{
+		inputPaths: []string{"input"},
+		output:     outputTable,
+	}
+	opts, err := flags.ToOptions(reader, writer, fSys)
+	require.NoError(t, err)
+	assert.Equal(t, &Options{
+		inputPaths: []string{"input"},
+		output:     outputTable,
+		fSys:       fSys,
+		reader:     reader,
+		writer:     writer,
+	}, opts)
+	assert.NoError(t, opts.Validate())
+
+	opts.inputPaths = []string{"input", stdinToken}
+	assert.ErrorContains(t, opts.Validate(), "cannot read from stdin and other paths together")
+	opts.inputPaths = nil
+	assert.ErrorContains(t, opts.Validate(), `at least one path must be specified with the "filename" flag`)
+
+	opts.inputPaths = []string{"input"}
+	opts.output = "yaml"
+	assert.ErrorContains(t, opts.Validate(), `invalid output value: "yaml"`)
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	testdata := "testdata"
+	stdinData, err := os.ReadFile(filepath.Join(testdata, "resources", "cronjob.yaml"))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		inputPaths        []string
+		output            string
+		projectConfigPath string
+		expectedOutput    string
+		expectedError     string
+	}{
+		"table output": {
+			inputPaths:     []string{filepath.Join(testdata, "resources", "cronjob.yaml")},
+			output:         outputTable,
+			expectedOutput: expectedTable,
+		},
+		"json output": {
+			inputPaths:     []string{filepath.Join(testdata, "resources", "deployment.yaml"), filepath.Join(testdata, "resources", "configmap.yml")},
+			output:         outputJSON,
+			expectedOutput: expectedJSON,
+		},
+		"empty list": {
+			inputPaths:     []string{filepath.Join(testdata, "resources", "configmap.yml")},
+			output:         outputJSON,
+			expectedOutput: "[]\n",
+		},
+		"custom workload from the project configuration": {
+			inputPaths:        []string{filepath.Join(testdata, "custom-workload.yaml")},
+			output:            outputTable,
+			projectConfigPath: filepath.Join(testdata, "mlp.yaml"),
+			expectedOutput: `WORKLOAD               CONTAINER   IMAGE                               TAG     DIGEST   SEMVER
+test/Workload/custom   custom      registry.example.com/custom:2.0.0   2.0.0   -        yes
+`,
+		},
+		"custom workload without project configuration": {
+			inputPaths:     []string{filepath.Join(testdata, "custom-workload.yaml")},
+			output:         outputJSON,
+			expectedOutput: "[]\n",
+		},
+		"read from stdin": {
+			inputPaths:     []string{stdinToken},
+			output:         outputTable,
+			expectedOutput: expectedTable,
+		},
+		"missing path": {
+			inputPaths:    []string{filepath.Join(testdata, "missing")},
+			output:        outputTable,
+			expectedError: "no such file or directory",
+		},
+		"invalid image": {
+			inputPaths:    []string{filepath.Join(testdata, "invalid-image.yaml")},
+			output:        outputTable,
+			expectedError: `invalid image for container "invalid" of Pod "invalid"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			writer := new(strings.Builder)
+			opts := &Options{
+				inputPaths:        test.inputPaths,
+				output:            test.output,
+				projectConfigPath: test.projectConfigPath,
+				fSys:              filesys.MakeFsOnDisk(),
+				reader:            bytes.NewReader(stdinData),
+				writer:            writer,
+			}
+
+			err := opts.Run(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+				assert.Equal(t, test.expectedOutput, writer.String())
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sync"
+)
+
+const (
+	// auditKeyEnvName contains the key used for hashing the substituted values with HMAC
+	auditKeyEnvName = "MLP_CHECKSUM_KEY"
+
+	hashAlgorithmSHA256     = "sha256"
+	hashAlgorithmHMACSHA256 = "hmac-sha256"
+)
+
+// substitutionFunc is called with the name of the variable every time a sequence is substituted with its value
+type substitutionFunc func(envName string)
+
+// auditLog is the list of the substitutions performed by the interpolation, used for proving which environment
+// values have been used for rendering the files without disclosing them
+type auditLog struct {
+	// HashAlgorithm is the algorithm used for hashing the values, sha256 or hmac-sha256 if a key is set
+	HashAlgorithm string `json:"hashAlgorithm"`
+	// Substitutions contains an entry for every variable substituted in every file, sorted by file and variable
+	Substitutions []auditEntry `json:"substitutions"`
+}
+
+// auditEntry describe the substitutions of a variable in a single file
+type auditEntry struct {
+	// File is the path of the interpolated file
+	File string `json:"file"`
+	// Variable is the name used inside the interpolation sequences
+	Variable string `json:"variable"`
+	// Source is the name of the env that provided the value, including its prefix
+	Source string `json:"source"`
+	// Replacements is the number of sequences substituted with the value
+	Replacements int `json:"replacements"`
+	// ValueHash is the hex encoded hash of the value
+	ValueHash string `json:"valueHash"`
+}
+
+// auditRecorder collect the substitutions of the interpolated files, it is safe for concurrent use
+type auditRecorder struct {
+	lock    sync.Mutex
+	key     []byte
+	entries []auditEntry
+}
+
+// newAuditRecorder return a recorder hashing the values with HMAC using key, or with a plain sha256 if it is empty
+func newAuditRecorder(key []byte) *auditRecorder {
+	return &auditRecorder{key: key}
+}
+
+// fileAudit count the substitutions done in a single file, it is not safe for concurrent use
+type fileAudit map[string]int
+
+// record implement substitutionFunc
+func (f fileAudit) record(envName string) {
+	f[envName]++
+}
+
+// add save the substitutions counted for the file at path, resolving the envs that provided their values
+func (r *auditRecorder) add(path string, prefixes []string, counts fileAudit) {
+	entries := make([]auditEntry, 0, len(counts))
+	for envName, count := range counts {
+		source, value, _ := resolveEnv(envName, prefixes)
+		entries = append(entries, auditEntry{
+			File:         path,
+			Variable:     envName,
+			Source:       source,
+			Replacements: count,
+			ValueHash:    r.hash(value),
+		})
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.entries = append(r.entries, entries...)
+}
+
+// hash return the hex encoded hash of value
+func (r *auditRecorder) hash(value string) string {
+	if len(r.key) == 0 {
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// log return the auditLog with the substitutions recorded
+func (r *auditRecorder) log() auditLog {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	algorithm := hashAlgorithmSHA256
+	if len(r.key) > 0 {
+		algorithm = hashAlgorithmHMACSHA256
+	}
+
+	entries := append(make([]auditEntry, 0, len(r.entries)), r.entries...)
+	slices.SortFunc(entries, func(a, b auditEntry) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Variable, b.Variable))
+	})
+	return auditLog{HashAlgorithm: algorithm, Substitutions: entries}
+}
+
+// marshal return the json encoding of the auditLog with the substitutions recorded
+func (r *auditRecorder) marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(r.log(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,113 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestRunWithAuditLog(t *testing.T) {
+	t.Setenv("MLP_AUDIT_NAME", "prefixed")
+	t.Setenv("AUDIT_NAME", "plain")
+	t.Setenv("AUDIT_REPLICAS", "3")
+	t.Setenv("AUDIT_SECRET", "s3cr3t")
+
+	sha256Hex := func(value string) string {
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+	hmacHex := func(key, value string) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := map[string]struct {
+		auditKey      string
+		preserveTypes bool
+		expectedLog   auditLog
+	}{
+		"values hashed with sha256": {
+			expectedLog: auditLog{
+				HashAlgorithm: hashAlgorithmSHA256,
+				Substitutions: []auditEntry{
+					{File: "/input/a.yaml", Variable: "AUDIT_NAME", Source: "MLP_AUDIT_NAME", Replacements: 2, ValueHash: sha256Hex("prefixed")},
+					{File: "/input/a.yaml", Variable: "AUDIT_REPLICAS", Source: "AUDIT_REPLICAS", Replacements: 1, ValueHash: sha256Hex("3")},
+					{File: "/input/b.yaml", Variable: "AUDIT_SECRET", Source: "AUDIT_SECRET", Replacements: 1, ValueHash: sha256Hex("s3cr3t")},
+				},
+			},
+		},
+		"values hashed with hmac and preserved types": {
+			auditKey:      "audit-key",
+			preserveTypes: true,
+			expectedLog: auditLog{
+				HashAlgorithm: hashAlgorithmHMACSHA256,
+				Substitutions: []auditEntry{
+					{File: "/input/a.yaml", Variable: "AUDIT_NAME", Source: "MLP_AUDIT_NAME", Replacements: 2, ValueHash: hmacHex("audit-key", "prefixed")},
+					{File: "/input/a.yaml", Variable: "AUDIT_REPLICAS", Source: "AUDIT_REPLICAS", Replacements: 1, ValueHash: hmacHex("audit-key", "3")},
+					{File: "/input/b.yaml", Variable: "AUDIT_SECRET", Source: "AUDIT_SECRET", Replacements: 1, ValueHash: hmacHex("audit-key", "s3cr3t")},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fSys := filesys.MakeFsInMemory()
+			require.NoError(t, fSys.WriteFile("/input/a.yaml", []byte("name: {{AUDIT_NAME}}\nlabel: \"{{AUDIT_NAME}}\"\nreplicas: \"{{AUDIT_REPLICAS}}\"\nescaped: \\{{AUDIT_SECRET}}\n")))
+			require.NoError(t, fSys.WriteFile("/input/b.yaml", []byte("secret: '{{AUDIT_SECRET}}'\n")))
+			require.NoError(t, fSys.WriteFile("/input/c.yaml", []byte("static: value\n")))
+
+			options := &Options{
+				prefixes:      []string{"MLP_"},
+				inputPaths:    []string{"/input"},
+				outputPath:    "/output",
+				preserveTypes: test.preserveTypes,
+				onMissing:     onMissingError,
+				concurrency:   2,
+				delimiters:    DefaultDelimiters,
+				auditLogPath:  "/audit.json",
+				auditKey:      test.auditKey,
+				fSys:          fSys,
+			}
+			require.NoError(t, options.Run(context.TODO()))
+
+			data, err := fSys.ReadFile("/audit.json")
+			require.NoError(t, err)
+			log := auditLog{}
+			require.NoError(t, json.Unmarshal(data, &log))
+			assert.Equal(t, test.expectedLog, log)
+			assert.NotContains(t, string(data), "s3cr3t")
+		})
+	}
+}
+
+func TestAuditLogWithoutSubstitutions(t *testing.T) {
+	t.Parallel()
+
+	data, err := newAuditRecorder(nil).marshal()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"hashAlgorithm": "sha256", "substitutions": []}`, string(data))
+}
@@ -0,0 +1,44 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Base64DecodeSuffix is added to the name of an environment variable inside an interpolation sequence, like in
+	// {{NAME|b64decode}}, for decoding its base64 value before the substitution
+	Base64DecodeSuffix = "|b64decode"
+)
+
+// parseSequenceName return the name of the environment variable used in an interpolation sequence and if its
+// value must be decoded from base64
+func parseSequenceName(sequenceName string) (string, bool) {
+	return strings.CutSuffix(sequenceName, Base64DecodeSuffix)
+}
+
+// DecodeBase64Value return the decoded value of the environment variable envName, ignoring the spaces and newlines
+// added by the tools that wrap the encoded lines
+func DecodeBase64Value(envName, value string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+	if err != nil {
+		return "", fmt.Errorf("environment variable %q is not a valid base64 value: %w", envName, err)
+	}
+	return string(decoded), nil
+}
@@ -0,0 +1,98 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	benchmarkFiles     = 2000
+	benchmarkVariables = 20
+)
+
+// benchmarkFilesys return an in memory file system with a folder containing benchmarkFiles files, every one of
+// them using the same benchmarkVariables variables
+func benchmarkFilesys(b *testing.B) filesys.FileSystem {
+	b.Helper()
+
+	builder := new(strings.Builder)
+	for idx := range benchmarkVariables {
+		b.Setenv(fmt.Sprintf("MLP_BENCHMARK_ENV_%d", idx), fmt.Sprintf("value-%d", idx))
+		fmt.Fprintf(builder, "key%d: \"{{BENCHMARK_ENV_%d}}\"\nother%d: prefix-{{BENCHMARK_ENV_%d}}-suffix\n", idx, idx, idx, idx)
+	}
+	content := []byte(builder.String())
+
+	fSys := filesys.MakeFsInMemory()
+	for idx := range benchmarkFiles {
+		require.NoError(b, fSys.WriteFile(filepath.Join("/input", fmt.Sprintf("file-%d.yaml", idx)), content))
+	}
+	return fSys
+}
+
+func BenchmarkRun(b *testing.B) {
+	for _, concurrency := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			fSys := benchmarkFilesys(b)
+			options := &Options{
+				prefixes:    []string{"MLP_TEST_", "MLP_"},
+				inputPaths:  []string{"/input"},
+				outputPath:  "/output",
+				onMissing:   onMissingError,
+				concurrency: concurrency,
+				fSys:        fSys,
+			}
+
+			b.ResetTimer()
+			for range b.N {
+				require.NoError(b, options.Run(context.TODO()))
+			}
+		})
+	}
+}
+
+func BenchmarkInterpolate(b *testing.B) {
+	builder := new(strings.Builder)
+	for idx := range benchmarkVariables {
+		b.Setenv(fmt.Sprintf("MLP_BENCHMARK_ENV_%d", idx), fmt.Sprintf("value-%d", idx))
+		fmt.Fprintf(builder, "key%d: \"{{BENCHMARK_ENV_%d}}\"\n", idx, idx)
+	}
+	data := []byte(builder.String())
+	prefixes := []string{"MLP_TEST_", "MLP_"}
+
+	b.Run("without cache", func(b *testing.B) {
+		for range b.N {
+			_, err := DefaultDelimiters.matcher().interpolateEnvs(data, prefixes, onMissingError, lookupEnv, nil, logr.Discard())
+			require.NoError(b, err)
+		}
+	})
+
+	b.Run("with cache", func(b *testing.B) {
+		cache := newEnvCache()
+		for range b.N {
+			_, err := DefaultDelimiters.matcher().interpolateEnvs(data, prefixes, onMissingError, cache.lookup, nil, logr.Discard())
+			require.NoError(b, err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"strings"
+	"sync"
+)
+
+// lookupFunc return the value of an environment variable searching it with the given prefixes
+type lookupFunc func(envName string, prefixes []string) (string, bool)
+
+// envCacheKey identify a resolved variable, the same name can resolve to different values with different prefixes
+type envCacheKey struct {
+	name     string
+	prefixes string
+}
+
+// envCacheValue contains the result of a lookup, including the variables that are not found
+type envCacheValue struct {
+	value string
+	found bool
+}
+
+// envCache keep the values of the environment variables already resolved, it is safe for concurrent use and avoid
+// to search the same names with all their prefixes for every interpolated file
+type envCache struct {
+	lock   sync.RWMutex
+	values map[envCacheKey]envCacheValue
+}
+
+// newEnvCache return a new empty cache
+func newEnvCache() *envCache {
+	return &envCache{
+		values: make(map[envCacheKey]envCacheValue),
+	}
+}
+
+// lookup implement lookupFunc using the cached value if present or resolving and saving it
+func (c *envCache) lookup(envName string, prefixes []string) (string, bool) {
+	key := envCacheKey{name: envName, prefixes: strings.Join(prefixes, "\x00")}
+
+	c.lock.RLock()
+	cached, found := c.values[key]
+	c.lock.RUnlock()
+	if found {
+		return cached.value, cached.found
+	}
+
+	value, exists := lookupEnv(envName, prefixes)
+	c.lock.Lock()
+	c.values[key] = envCacheValue{value: value, found: exists}
+	c.lock.Unlock()
+	return value, exists
+}
@@ -0,0 +1,54 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvCache(t *testing.T) {
+	t.Setenv("MLP_CACHED_ENV", "prefixed")
+	t.Setenv("CACHED_ENV", "plain")
+
+	cache := newEnvCache()
+	value, found := cache.lookup("CACHED_ENV", []string{"MLP_"})
+	assert.True(t, found)
+	assert.Equal(t, "prefixed", value)
+
+	value, found = cache.lookup("CACHED_ENV", nil)
+	assert.True(t, found)
+	assert.Equal(t, "plain", value)
+
+	value, found = cache.lookup("MISSING_CACHED_ENV", []string{"MLP_"})
+	assert.False(t, found)
+	assert.Empty(t, value)
+
+	// values are resolved only once for the same name and prefixes
+	t.Setenv("MLP_CACHED_ENV", "changed")
+	t.Setenv("MISSING_CACHED_ENV", "found")
+	value, found = cache.lookup("CACHED_ENV", []string{"MLP_"})
+	assert.True(t, found)
+	assert.Equal(t, "prefixed", value)
+
+	_, found = cache.lookup("MISSING_CACHED_ENV", []string{"MLP_"})
+	assert.False(t, found)
+
+	value, found = cache.lookup("CACHED_ENV", []string{"MLP_", "OTHER_"})
+	assert.True(t, found)
+	assert.Equal(t, "changed", value)
+}
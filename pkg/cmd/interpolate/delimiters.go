@@ -0,0 +1,150 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+)
+
+// Delimiters contains the strings enclosing the name of an environment variable in an interpolation sequence, the
+// zero value is equivalent to DefaultDelimiters
+type Delimiters struct {
+	Left  string
+	Right string
+}
+
+var (
+	// DefaultDelimiters are the delimiters used when no others are configured
+	DefaultDelimiters = Delimiters{Left: "{{", Right: "}}"}
+
+	// matchers cache the sequenceMatcher compiled for every Delimiters used
+	matchers sync.Map
+)
+
+// Validate return an error if the delimiters cannot be used for finding the interpolation sequences
+func (d Delimiters) Validate() error {
+	if d == (Delimiters{}) {
+		return nil
+	}
+
+	for _, delim := range []string{d.Left, d.Right} {
+		switch {
+		case len(delim) == 0:
+			return fmt.Errorf("the interpolation delimiters cannot be empty")
+		case strings.ContainsAny(delim, "\"'\\ \t\n"):
+			return fmt.Errorf("invalid interpolation delimiter %q: quotes, backslashes and spaces are not allowed", delim)
+		}
+	}
+
+	return nil
+}
+
+// Sequence return the interpolation sequence for envName
+func (d Delimiters) Sequence(envName string) string {
+	d = d.OrDefault()
+	return d.Left + envName + d.Right
+}
+
+// OrDefault return DefaultDelimiters if d is the zero value, or d otherwise
+func (d Delimiters) OrDefault() Delimiters {
+	if d == (Delimiters{}) {
+		return DefaultDelimiters
+	}
+	return d
+}
+
+// String implement the fmt.Stringer interface
+func (d Delimiters) String() string {
+	return d.Sequence("NAME")
+}
+
+// Interpolate will interpolate the data content with values from env values, returning an error if one of them
+// is not found
+func (d Delimiters) Interpolate(data []byte, envPrefixes []string) ([]byte, error) {
+	return d.matcher().interpolateEnvs(data, envPrefixes, onMissingError, lookupEnv, nil, logr.Discard())
+}
+
+// InterpolateKeepingMissing will interpolate the data content with values from env values, leaving untouched the
+// sequences of the ones not found and returning their names; an error is returned only if a value cannot be decoded
+func (d Delimiters) InterpolateKeepingMissing(data []byte, envPrefixes []string) ([]byte, []string, error) {
+	missing := make([]string, 0)
+	data, err := d.matcher().replaceSequences(data, func(envName string) (string, bool, error) {
+		value, found := lookupEnv(envName, envPrefixes)
+		if !found && !slices.Contains(missing, envName) {
+			missing = append(missing, envName)
+		}
+		return value, found, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, missing, nil
+}
+
+// matcher return the sequenceMatcher for d, compiling it on the first use
+func (d Delimiters) matcher() *sequenceMatcher {
+	d = d.OrDefault()
+	if m, found := matchers.Load(d); found {
+		return m.(*sequenceMatcher)
+	}
+
+	m, _ := matchers.LoadOrStore(d, newSequenceMatcher(d))
+	return m.(*sequenceMatcher)
+}
+
+// sequenceMatcher find the interpolation sequences enclosed in a pair of delimiters
+type sequenceMatcher struct {
+	escapedLeft string
+	left        string
+
+	// sequences match, in order of precedence, the escaped sequences and the interpolation sequences encased in
+	// double quotes, single quotes or without quotes
+	sequences *regexp.Regexp
+
+	// typedScalar match a double quoted interpolation sequence used as the whole value of a mapping key or of
+	// a sequence item, optionally followed by a comment
+	typedScalar *regexp.Regexp
+}
+
+// newSequenceMatcher return a sequenceMatcher for delims
+func newSequenceMatcher(delims Delimiters) *sequenceMatcher {
+	left, right := regexp.QuoteMeta(delims.Left), regexp.QuoteMeta(delims.Right)
+	escapedLeft := delims.Left + `"` + delims.Left + `"` + delims.Right
+	name := `([A-Z0-9_]+(?:` + regexp.QuoteMeta(Base64DecodeSuffix) + `)?)`
+
+	sequences := strings.Join([]string{
+		`\\` + left + `[A-Z0-9_]+(?:` + regexp.QuoteMeta(Base64DecodeSuffix) + `)?` + right,
+		regexp.QuoteMeta(escapedLeft),
+		`"` + left + name + right + `"`,
+		`'` + left + name + right + `'`,
+		left + name + right,
+	}, "|")
+	typedScalar := `(?m)^([ \t]*(?:-[ \t]+)*(?:-|[^\s#'"-][^#\n]*?:)[ \t]+)"` + left + name + right + `"([ \t]*(?:#.*)?)$`
+
+	return &sequenceMatcher{
+		escapedLeft: escapedLeft,
+		left:        delims.Left,
+		sequences:   regexp.MustCompile(sequences),
+		typedScalar: regexp.MustCompile(typedScalar),
+	}
+}
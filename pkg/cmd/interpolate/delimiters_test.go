@@ -0,0 +1,165 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelimitersValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		delimiters    Delimiters
+		expectedError string
+	}{
+		"zero value": {},
+		"default": {
+			delimiters: DefaultDelimiters,
+		},
+		"square brackets": {
+			delimiters: Delimiters{Left: "[[", Right: "]]"},
+		},
+		"shell style": {
+			delimiters: Delimiters{Left: "${", Right: "}"},
+		},
+		"empty right": {
+			delimiters:    Delimiters{Left: "[["},
+			expectedError: "the interpolation delimiters cannot be empty",
+		},
+		"quotes": {
+			delimiters:    Delimiters{Left: `"[`, Right: "]"},
+			expectedError: `invalid interpolation delimiter "\"[": quotes, backslashes and spaces are not allowed`,
+		},
+		"spaces": {
+			delimiters:    Delimiters{Left: "[[", Right: " ]]"},
+			expectedError: `invalid interpolation delimiter " ]]": quotes, backslashes and spaces are not allowed`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := test.delimiters.Validate()
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.EqualError(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestDelimitersInterpolate(t *testing.T) {
+	t.Setenv("MLP_FIRST", "first")
+	t.Setenv("MLP_QUOTED", `"quoted" 'value'`)
+
+	tests := map[string]struct {
+		delimiters    Delimiters
+		data          string
+		expected      string
+		expectedError string
+	}{
+		"square brackets": {
+			delimiters: Delimiters{Left: "[[", Right: "]]"},
+			data:       `key: [[FIRST]] {{FIRST}} {{ .Values.name }}`,
+			expected:   `key: first {{FIRST}} {{ .Values.name }}`,
+		},
+		"shell style": {
+			delimiters: Delimiters{Left: "${", Right: "}"},
+			data:       `key: ${FIRST}-${FIRST} $FIRST`,
+			expected:   `key: first-first $FIRST`,
+		},
+		"quote aware substitution": {
+			delimiters: Delimiters{Left: "${", Right: "}"},
+			data: `double: "${QUOTED}"
+single: '${QUOTED}'`,
+			expected: `double: "\"quoted\" 'value'"
+single: '"quoted" 'value''`,
+		},
+		"escaped sequence": {
+			delimiters: Delimiters{Left: "[[", Right: "]]"},
+			data:       `key: \[[FIRST]] [[FIRST]]`,
+			expected:   `key: [[FIRST]] first`,
+		},
+		"escaped delimiter": {
+			delimiters: Delimiters{Left: "[[", Right: "]]"},
+			data:       `key: [["[["]] FIRST ]]`,
+			expected:   `key: [[ FIRST ]]`,
+		},
+		"strict names": {
+			delimiters: Delimiters{Left: "${", Right: "}"},
+			data:       `key: ${first} ${ FIRST }`,
+			expected:   `key: ${first} ${ FIRST }`,
+		},
+		"missing env": {
+			delimiters:    Delimiters{Left: "[[", Right: "]]"},
+			data:          `key: [[MISSING_ENV]]`,
+			expectedError: `environment variable "MISSING_ENV" not found`,
+		},
+		"zero value use the default delimiters": {
+			data:     `key: {{FIRST}}`,
+			expected: `key: first`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := test.delimiters.Interpolate([]byte(test.data), []string{"MLP_"})
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(data))
+		})
+	}
+}
+
+func TestDelimitersKeepingMissingAndTypes(t *testing.T) {
+	t.Setenv("MLP_DELIMS_FOUND", "found")
+	t.Setenv("MLP_DELIMS_NUMBER", "42")
+
+	delimiters := Delimiters{Left: "[[", Right: "]]"}
+	data, missing, err := delimiters.InterpolateKeepingMissing([]byte(`found: [[DELIMS_FOUND]]
+missing: "[[DELIMS_MISSING]]"
+other: "{{DELIMS_OTHER}}"
+`), []string{"MLP_"})
+	require.NoError(t, err)
+	assert.Equal(t, `found: found
+missing: "[[DELIMS_MISSING]]"
+other: "{{DELIMS_OTHER}}"
+`, string(data))
+	assert.Equal(t, []string{"DELIMS_MISSING"}, missing)
+	assert.Equal(t, "[[DELIMS_MISSING]]", delimiters.Sequence("DELIMS_MISSING"))
+
+	data, err = delimiters.InterpolatePreservingTypes([]byte(`number: "[[DELIMS_NUMBER]]"
+string: "[[DELIMS_FOUND]]"
+`), []string{"MLP_"})
+	require.NoError(t, err)
+	assert.Equal(t, `number: 42
+string: "found"
+`, string(data))
+
+	data, err = delimiters.InterpolateGoTemplate([]byte(`value: [[ .Env.DELIMS_FOUND ]] {{ .Values }}`), []string{"MLP_"})
+	require.NoError(t, err)
+	assert.Equal(t, `value: found {{ .Values }}`, string(data))
+}
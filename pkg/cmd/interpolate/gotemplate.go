@@ -0,0 +1,163 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// goTemplateData is the data passed to the templates rendered by the gotemplate engine
+type goTemplateData struct {
+	Env map[string]string
+}
+
+// InterpolateGoTemplate will render data as a Go template exposing the environment variables in the .Env map,
+// the variables found with one of the envPrefixes will be also available without the prefix, following the same
+// precedence order of the default engine
+func InterpolateGoTemplate(data []byte, envPrefixes []string) ([]byte, error) {
+	return DefaultDelimiters.InterpolateGoTemplate(data, envPrefixes)
+}
+
+// InterpolateGoTemplate is like the package InterpolateGoTemplate function, using the delimiters d for the actions
+func (d Delimiters) InterpolateGoTemplate(data []byte, envPrefixes []string) ([]byte, error) {
+	tmpl, err := template.New("interpolate").
+		Delims(d.Left, d.Right).
+		Option("missingkey=error").
+		Funcs(goTemplateFuncs(envPrefixes)).
+		Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := tmpl.Execute(buffer, goTemplateData{Env: envMapWithPrefixes(envPrefixes)}); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// envMapWithPrefixes return all the environment variables, adding the variables found with one of the prefixes
+// also without it; if the same name is found with more prefixes, the first prefix win. When a prefix is required
+// the following prefixes are ignored and only the variables found with the prefixes are returned
+func envMapWithPrefixes(prefixes []string) map[string]string {
+	chain := prefixes
+	bareFallback := true
+	for i, prefix := range prefixes {
+		if strings.HasSuffix(prefix, RequiredPrefixMarker) {
+			chain = prefixes[:i+1]
+			bareFallback = false
+			break
+		}
+	}
+	chain = PrefixNames(chain)
+
+	environ := os.Environ()
+	env := make(map[string]string, len(environ))
+	if bareFallback {
+		for _, pair := range environ {
+			name, value, _ := strings.Cut(pair, "=")
+			env[name] = value
+		}
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, pair := range environ {
+			name, value, _ := strings.Cut(pair, "=")
+			if strippedName, found := strings.CutPrefix(name, chain[i]); found && len(strippedName) > 0 {
+				env[strippedName] = value
+			}
+		}
+	}
+
+	return env
+}
+
+// goTemplateFuncs return a subset of the functions commonly available in the sprig library
+func goTemplateFuncs(prefixes []string) template.FuncMap {
+	return template.FuncMap{
+		"env": func(name string) string {
+			value, _ := valueForEnv(name, prefixes, func(str string) string { return str })
+			return value
+		},
+		"default": func(defaultValue, value interface{}) interface{} {
+			if isEmptyValue(value) {
+				return defaultValue
+			}
+			return value
+		},
+		"required": func(message string, value interface{}) (interface{}, error) {
+			if isEmptyValue(value) {
+				return nil, errors.New(message)
+			}
+			return value, nil
+		},
+		"quote":      func(str string) string { return strconv.Quote(str) },
+		"squote":     func(str string) string { return "'" + strings.ReplaceAll(str, "'", "''") + "'" },
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, str string) string { return strings.TrimPrefix(str, prefix) },
+		"trimSuffix": func(suffix, str string) string { return strings.TrimSuffix(str, suffix) },
+		"replace":    func(oldStr, newStr, str string) string { return strings.ReplaceAll(str, oldStr, newStr) },
+		"contains":   func(substr, str string) bool { return strings.Contains(str, substr) },
+		"hasPrefix":  func(prefix, str string) bool { return strings.HasPrefix(str, prefix) },
+		"hasSuffix":  func(suffix, str string) bool { return strings.HasSuffix(str, suffix) },
+		"b64enc":     func(str string) string { return base64.StdEncoding.EncodeToString([]byte(str)) },
+		"b64dec": func(str string) (string, error) {
+			data, err := base64.StdEncoding.DecodeString(str)
+			return string(data), err
+		},
+		"toJson": func(value interface{}) (string, error) {
+			data, err := json.Marshal(value)
+			return string(data), err
+		},
+		"indent": indent,
+		"nindent": func(spaces int, str string) string {
+			return "\n" + indent(spaces, str)
+		},
+	}
+}
+
+// indent add spaces at the start of every line of str
+func indent(spaces int, str string) string {
+	padding := strings.Repeat(" ", spaces)
+	return padding + strings.ReplaceAll(str, "\n", "\n"+padding)
+}
+
+// isEmptyValue return true if value is nil or the zero value of its type
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	reflectValue := reflect.ValueOf(value)
+	switch reflectValue.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return reflectValue.Len() == 0
+	default:
+		return reflectValue.IsZero()
+	}
+}
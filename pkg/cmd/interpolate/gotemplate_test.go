@@ -0,0 +1,107 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolateGoTemplate(t *testing.T) {
+	t.Setenv("MLP_GOTEMPLATE_VALUE", "value")
+	t.Setenv("MLP_TEST_GOTEMPLATE_OVERRIDE", "test-override")
+	t.Setenv("MLP_GOTEMPLATE_OVERRIDE", "override")
+	t.Setenv("GOTEMPLATE_OVERRIDE", "base")
+	t.Setenv("GOTEMPLATE_MULTILINE", "first\nsecond")
+	t.Setenv("GOTEMPLATE_ENCODED", "dmFsdWU=")
+
+	prefixes := []string{"MLP_TEST_", "MLP_"}
+	tests := map[string]struct {
+		template       string
+		prefixes       []string
+		expectedResult string
+		expectedError  string
+	}{
+		"env values with prefixes": {
+			template:       "{{ .Env.GOTEMPLATE_VALUE }} {{ .Env.MLP_GOTEMPLATE_VALUE }} {{ .Env.GOTEMPLATE_OVERRIDE }}",
+			expectedResult: "value value test-override",
+		},
+		"env function": {
+			template:       `{{ env "GOTEMPLATE_OVERRIDE" }}-{{ env "GOTEMPLATE_MISSING" | default "default" }}`,
+			expectedResult: "test-override-default",
+		},
+		"string functions": {
+			template:       `{{ upper "a" }}{{ lower "B" }}{{ trim " c " }}{{ trimPrefix "x" "xd" }}{{ trimSuffix "x" "ex" }}{{ replace "a" "f" "a" }}`,
+			expectedResult: "Abcdef",
+		},
+		"quoting functions": {
+			template:       `{{ quote "a\"b" }} {{ squote "it's" }} {{ toJson .Env.GOTEMPLATE_MULTILINE }}`,
+			expectedResult: `"a\"b" 'it''s' "first\nsecond"`,
+		},
+		"conditional functions": {
+			template:       `{{ if contains "ue" .Env.GOTEMPLATE_VALUE }}ok{{ end }}{{ if hasPrefix "va" .Env.GOTEMPLATE_VALUE }}ok{{ end }}{{ if hasSuffix "ue" .Env.GOTEMPLATE_VALUE }}ok{{ end }}`,
+			expectedResult: "okokok",
+		},
+		"encoding functions": {
+			template:       `{{ b64enc "value" }} {{ b64dec .Env.GOTEMPLATE_ENCODED }}`,
+			expectedResult: "dmFsdWU= value",
+		},
+		"indent functions": {
+			template:       "key:{{ .Env.GOTEMPLATE_MULTILINE | nindent 2 }}\nother:\n{{ .Env.GOTEMPLATE_MULTILINE | indent 1 }}",
+			expectedResult: "key:\n  first\n  second\nother:\n first\n second",
+		},
+		"missing env": {
+			template:      "{{ .Env.GOTEMPLATE_MISSING }}",
+			expectedError: `map has no entry for key "GOTEMPLATE_MISSING"`,
+		},
+		"required value": {
+			template:      `{{ env "GOTEMPLATE_MISSING" | required "value is required" }}`,
+			expectedError: "value is required",
+		},
+		"required prefix": {
+			template:       `{{ .Env.GOTEMPLATE_OVERRIDE }}-{{ env "GOTEMPLATE_MULTILINE" | default "default" }}`,
+			prefixes:       []string{"MLP_!", "MLP_TEST_"},
+			expectedResult: "override-default",
+		},
+		"required prefix hide the bare names": {
+			template:      "{{ .Env.GOTEMPLATE_MULTILINE }}",
+			prefixes:      []string{"MLP_!"},
+			expectedError: `map has no entry for key "GOTEMPLATE_MULTILINE"`,
+		},
+		"invalid template": {
+			template:      "{{ .Env.GOTEMPLATE_VALUE",
+			expectedError: "failed to parse template",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			templatePrefixes := prefixes
+			if test.prefixes != nil {
+				templatePrefixes = test.prefixes
+			}
+			result, err := InterpolateGoTemplate([]byte(test.template), templatePrefixes)
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+				assert.Equal(t, test.expectedResult, string(result))
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
@@ -16,30 +16,37 @@
 package interpolate
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
-	"regexp"
+	"runtime"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/ignore"
+	"github.com/mia-platform/mlp/v2/pkg/outputdir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"golang.org/x/sync/errgroup"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
 const (
 	cmdUsage = "interpolate"
 	cmdShort = "Interpolate env variables in files"
-	cmdLong  = `Interpolate the environment variables values delimited by '{{' and '}}' inside one or
-	multiple files.
-	If a path is a folder only the files directly inside will be interpolated.
+	cmdLong  = `Interpolate the environment variables values delimited by '{{' and '}}', or by the
+	delimiters set with the --left-delim and --right-delim flags, inside one or multiple files.
+	If a path is a folder only the files directly inside will be interpolated,
+	skipping the ones matching the patterns of the .mlpignore file found at its root.
 
 	The results of the interpolation will be saved in the folder specified with
 	the --out flag. By default the folder is named "interpolated-files".
@@ -60,47 +67,110 @@ const (
 	# Interpolate a folder and save the resulting files in a custom folder
 
 	mlp interpolate --filename a/folder --out result-folder/
+
+	# Interpolate a folder saving the resulting files in a folder scoped to the pipeline run
+
+	mlp interpolate --filename a/folder --out result-folder/ --run-id "$CI_PIPELINE_ID"
 	`
 
 	prefixesFlagName  = "env-prefix"
 	prefixesFlagShort = "e"
-	prefixesFlagUsage = "prefixes to add when looking for ENV variables"
+	prefixesFlagUsage = "prefixes to add when looking for ENV variables, a prefix ending with " + RequiredPrefixMarker + " stop the search if the value is not found with it"
+
+	noBareFallbackFlagName     = "no-bare-fallback"
+	noBareFallbackDefaultValue = false
+	noBareFallbackFlagUsage    = "if true ENV variables are searched only with the prefixes and never with their bare names"
 
 	inputFlagName  = "filename"
 	inputFlagShort = "f"
 	inputFlagUsage = "file or folder paths containing data to interpolate"
 
+	engineFlagName  = "engine"
+	engineFlagUsage = "the interpolation engine to use (accepted values: default, gotemplate)"
+
+	preserveTypesFlagName     = "preserve-types"
+	preserveTypesDefaultValue = false
+	preserveTypesFlagUsage    = "if true double quoted sequences used as a whole YAML value are interpolated without quotes when the value is a number or a boolean"
+
+	onMissingFlagName  = "on-missing"
+	onMissingFlagUsage = "how to handle env variables not found (accepted values: error, warn, keep, empty)"
+
+	concurrencyFlagName  = "concurrency"
+	concurrencyFlagUsage = "number of files interpolated in parallel"
+
+	leftDelimFlagName  = "left-delim"
+	leftDelimFlagUsage = "the string opening the interpolation sequences"
+
+	rightDelimFlagName  = "right-delim"
+	rightDelimFlagUsage = "the string closing the interpolation sequences"
+
+	auditLogFlagName  = "audit-log"
+	auditLogFlagUsage = "path of a json file where to save the list of the substitutions performed, with the envs providing the values and their hashes"
+
 	outputFlagName  = "out"
 	outputFlagShort = "o"
 	outputFlagUsage = "output directory where interpolated files are saved"
 
+	runIDFlagName  = "run-id"
+	runIDFlagUsage = "if set the interpolated files are saved in a folder with this name inside the output directory, together with an " + outputdir.IndexFileName + " file listing them"
+
+	engineDefault    = "default"
+	engineGoTemplate = "gotemplate"
+
+	onMissingError = "error"
+	onMissingWarn  = "warn"
+	onMissingKeep  = "keep"
+	onMissingEmpty = "empty"
+
+	// RequiredPrefixMarker is the suffix that mark a prefix as required: the values must be found with it or with
+	// one of the prefixes before it, without falling back to the following prefixes or to the bare name
+	RequiredPrefixMarker = "!"
+
 	stdinToken             = "-"
 	outputFileNameForStdin = "output.yaml"
+)
 
-	envirnonmentRegex      = `[{]{2}([A-Z0-9_]+)[}]{2}`
-	unqutedLeftDelim       = `{{`
-	unqutedRightDelim      = `}}`
-	doubleQoutedLeftDelim  = `"` + unqutedLeftDelim
-	doubleQoutedRightDelim = unqutedRightDelim + `"`
-	singleQoutedLeftDelim  = `'` + unqutedLeftDelim
-	singleQoutedRightDelim = unqutedRightDelim + `'`
+var (
+	validEngineValues    = []string{engineDefault, engineGoTemplate}
+	validOnMissingValues = []string{onMissingError, onMissingWarn, onMissingKeep, onMissingEmpty}
 )
 
 // Flags contains all the flags for the `interpolate` command. They will be converted to Options
 // that contains all runtime options for the command.
 type Flags struct {
-	prefixes   []string
-	inputPaths []string
-	outputPath string
+	prefixes       []string
+	noBareFallback bool
+	inputPaths     []string
+	outputPath     string
+	engine         string
+	preserveTypes  bool
+	onMissing      string
+	concurrency    int
+	leftDelim      string
+	rightDelim     string
+	auditLogPath   string
+	runID          string
 }
 
 // Options have the data required to perform the interpolate operation
 type Options struct {
-	prefixes   []string
-	inputPaths []string
-	outputPath string
-	fSys       filesys.FileSystem
-	reader     io.Reader
+	prefixes       []string
+	noBareFallback bool
+	inputPaths     []string
+	outputPath     string
+	engine         string
+	preserveTypes  bool
+	onMissing      string
+	concurrency    int
+	delimiters     Delimiters
+	auditLogPath   string
+	auditKey       string
+	runID          string
+	fSys           filesys.FileSystem
+	reader         io.Reader
+
+	envs  *envCache
+	audit *auditRecorder
 }
 
 // NewCommand return the command for interpolating env variables on target files
@@ -115,7 +185,7 @@ func NewCommand() *cobra.Command {
 		Args: cobra.NoArgs,
 
 		Run: func(cmd *cobra.Command, _ []string) {
-			o, err := flags.ToOptions(cmd.InOrStdin(), filesys.MakeFsOnDisk())
+			o, err := flags.ToOptions(cmd.InOrStdin(), outputdir.MakeFsOnDisk())
 			cobra.CheckErr(err)
 			cobra.CheckErr(o.Validate())
 			cobra.CheckErr(o.Run(cmd.Context()))
@@ -123,6 +193,12 @@ func NewCommand() *cobra.Command {
 	}
 
 	flags.AddFlags(cmd.Flags())
+	if err := cmd.RegisterFlagCompletionFunc(engineFlagName, engineFlagCompletionfunc); err != nil {
+		panic(err)
+	}
+	if err := cmd.RegisterFlagCompletionFunc(onMissingFlagName, onMissingFlagCompletionfunc); err != nil {
+		panic(err)
+	}
 
 	return cmd
 }
@@ -130,8 +206,17 @@ func NewCommand() *cobra.Command {
 // AddFlags set the connection between Flags property to command line flags
 func (f *Flags) AddFlags(flags *pflag.FlagSet) {
 	flags.StringSliceVarP(&f.prefixes, prefixesFlagName, prefixesFlagShort, nil, prefixesFlagUsage)
+	flags.BoolVar(&f.noBareFallback, noBareFallbackFlagName, noBareFallbackDefaultValue, noBareFallbackFlagUsage)
 	flags.StringSliceVarP(&f.inputPaths, inputFlagName, inputFlagShort, nil, inputFlagUsage)
 	flags.StringVarP(&f.outputPath, outputFlagName, outputFlagShort, "interpolated-files", outputFlagUsage)
+	flags.StringVar(&f.engine, engineFlagName, engineDefault, engineFlagUsage)
+	flags.BoolVar(&f.preserveTypes, preserveTypesFlagName, preserveTypesDefaultValue, preserveTypesFlagUsage)
+	flags.StringVar(&f.onMissing, onMissingFlagName, onMissingError, onMissingFlagUsage)
+	flags.IntVar(&f.concurrency, concurrencyFlagName, runtime.NumCPU(), concurrencyFlagUsage)
+	flags.StringVar(&f.leftDelim, leftDelimFlagName, DefaultDelimiters.Left, leftDelimFlagUsage)
+	flags.StringVar(&f.rightDelim, rightDelimFlagName, DefaultDelimiters.Right, rightDelimFlagUsage)
+	flags.StringVar(&f.auditLogPath, auditLogFlagName, "", auditLogFlagUsage)
+	flags.StringVar(&f.runID, runIDFlagName, "", runIDFlagUsage)
 	if err := cobra.MarkFlagDirname(flags, outputFlagName); err != nil {
 		panic(err)
 	}
@@ -139,12 +224,26 @@ func (f *Flags) AddFlags(flags *pflag.FlagSet) {
 
 // ToOptions transform the command flags in command runtime arguments
 func (f *Flags) ToOptions(reader io.Reader, fSys filesys.FileSystem) (*Options, error) {
+	prefixes := f.prefixes
+	if f.noBareFallback {
+		prefixes = withoutBareFallback(prefixes)
+	}
+
 	return &Options{
-		inputPaths: f.inputPaths,
-		prefixes:   f.prefixes,
-		outputPath: f.outputPath,
-		fSys:       fSys,
-		reader:     reader,
+		inputPaths:     f.inputPaths,
+		prefixes:       prefixes,
+		noBareFallback: f.noBareFallback,
+		outputPath:     f.outputPath,
+		engine:         f.engine,
+		preserveTypes:  f.preserveTypes,
+		onMissing:      f.onMissing,
+		concurrency:    f.concurrency,
+		delimiters:     Delimiters{Left: f.leftDelim, Right: f.rightDelim},
+		auditLogPath:   f.auditLogPath,
+		auditKey:       os.Getenv(auditKeyEnvName),
+		runID:          f.runID,
+		fSys:           fSys,
+		reader:         reader,
 	}, nil
 }
 
@@ -157,13 +256,54 @@ func (o *Options) Validate() error {
 		return fmt.Errorf("cannot read from stdin and other paths together")
 	}
 
+	if o.noBareFallback && len(o.prefixes) == 0 {
+		return fmt.Errorf("the %q flag requires at least one %q flag", noBareFallbackFlagName, prefixesFlagName)
+	}
+
+	if !slices.Contains(validEngineValues, o.engine) {
+		return fmt.Errorf("invalid engine value: %q", o.engine)
+	}
+
+	if o.preserveTypes && o.engine == engineGoTemplate {
+		return fmt.Errorf("the %q flag cannot be used with the %q engine", preserveTypesFlagName, engineGoTemplate)
+	}
+
+	if !slices.Contains(validOnMissingValues, o.onMissing) {
+		return fmt.Errorf("invalid on-missing value: %q", o.onMissing)
+	}
+
+	if o.onMissing != onMissingError && o.engine == engineGoTemplate {
+		return fmt.Errorf("the %q flag cannot be used with the %q engine", onMissingFlagName, engineGoTemplate)
+	}
+
+	if len(o.auditLogPath) > 0 && o.engine == engineGoTemplate {
+		return fmt.Errorf("the %q flag cannot be used with the %q engine", auditLogFlagName, engineGoTemplate)
+	}
+
+	if o.concurrency < 1 {
+		return fmt.Errorf("the %q flag must be greater than 0", concurrencyFlagName)
+	}
+
+	if err := o.delimiters.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := o.outputDir(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // Run execute the interpolate command
 func (o *Options) Run(ctx context.Context) error {
 	logger := logr.FromContextOrDiscard(ctx)
-	if err := o.fSys.MkdirAll(o.outputPath); err != nil {
+	outputPath, err := o.outputDir()
+	if err != nil {
+		return err
+	}
+
+	if err := o.fSys.MkdirAll(outputPath); err != nil {
 		return err
 	}
 
@@ -172,25 +312,106 @@ func (o *Options) Run(ctx context.Context) error {
 		return err
 	}
 
+	// files with the same name will be saved in the same output path, keep only the last one like the sequential
+	// processing would do
+	lastPathForName := make(map[string]string, len(pathsToInterpolate))
 	for _, path := range pathsToInterpolate {
-		data, name, err := o.readFile(path)
-		if err != nil {
-			return err
-		}
+		lastPathForName[o.outputName(path)] = path
+	}
 
-		logger.V(5).Info("intepolating file", "path", path)
-		interpolatedData, err := Interpolate(data, o.prefixes)
-		if err != nil {
-			return err
-		}
+	o.envs = newEnvCache()
+	if len(o.auditLogPath) > 0 {
+		o.audit = newAuditRecorder([]byte(o.auditKey))
+	}
+	// the file system implementations are not guaranteed to be safe for concurrent use
+	fSysLock := new(sync.Mutex)
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(max(o.concurrency, 1))
+	for _, path := range pathsToInterpolate {
+		group.Go(func() error {
+			if err := groupCtx.Err(); err != nil {
+				return err
+			}
+
+			fSysLock.Lock()
+			data, name, err := o.readFile(path)
+			fSysLock.Unlock()
+			if err != nil {
+				return err
+			}
+
+			logger.V(5).Info("intepolating file", "path", path)
+			substitutions := make(fileAudit)
+			interpolatedData, err := o.interpolate(data, substitutions.record, logger.WithValues("path", path))
+			if err != nil {
+				return err
+			}
+
+			if lastPathForName[name] != path {
+				logger.V(10).Info("skip saving file overwritten by another path", "path", path)
+				return nil
+			}
+
+			if o.audit != nil {
+				o.audit.add(path, o.prefixes, substitutions)
+			}
+
+			logger.V(10).Info("saving interpolated file", "path", path)
+			fSysLock.Lock()
+			defer fSysLock.Unlock()
+			return o.fSys.WriteFile(filepath.Join(outputPath, name), interpolatedData)
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return err
+	}
 
-		logger.V(10).Info("saving interpolated file", "path", path)
-		if err := o.fSys.WriteFile(filepath.Join(o.outputPath, name), interpolatedData); err != nil {
+	if len(o.runID) > 0 {
+		logger.V(5).Info("saving output index", "path", outputPath)
+		files := slices.Collect(maps.Keys(lastPathForName))
+		if err := outputdir.WriteIndex(o.fSys, outputPath, o.runID, cmdUsage, files); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	if o.audit == nil {
+		return nil
+	}
+
+	logger.V(5).Info("saving audit log", "path", o.auditLogPath)
+	data, err := o.audit.marshal()
+	if err != nil {
+		return err
+	}
+	return o.fSys.WriteFile(o.auditLogPath, data)
+}
+
+// interpolate run the interpolation engine selected in the options on data, calling record for every substitution
+func (o *Options) interpolate(data []byte, record substitutionFunc, logger logr.Logger) ([]byte, error) {
+	if o.engine == engineGoTemplate {
+		return o.delimiters.InterpolateGoTemplate(data, o.prefixes)
+	}
+
+	lookup := lookupEnv
+	if o.envs != nil {
+		lookup = o.envs.lookup
+	}
+
+	matcher := o.delimiters.matcher()
+	if o.preserveTypes {
+		data = matcher.unquoteTypedScalars(data, o.prefixes, lookup, record)
+	}
+
+	return matcher.interpolateEnvs(data, o.prefixes, o.onMissing, lookup, record, logger)
+}
+
+func engineFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return validEngineValues, cobra.ShellCompDirectiveDefault
+}
+
+func onMissingFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return validOnMissingValues, cobra.ShellCompDirectiveDefault
 }
 
 func (o *Options) filesToInterpolate(ctx context.Context) ([]string, error) {
@@ -221,7 +442,7 @@ func (o *Options) filesToInterpolate(ctx context.Context) ([]string, error) {
 		}
 
 		logger.V(10).Info("considering folder", "path", path)
-		err := o.fSys.Walk(path, func(path string, info fs.FileInfo, err error) error {
+		err := ignore.Walk(o.fSys, path, func(path string, info fs.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
@@ -249,85 +470,199 @@ func (o *Options) readFile(path string) ([]byte, string, error) {
 	}
 
 	data, err := o.fSys.ReadFile(path)
-	return data, filepath.Base(path), err
+	return data, o.outputName(path), err
 }
 
-// Interpolate will interpolate the data content with values from env values
-func Interpolate(data []byte, envPrefixes []string) ([]byte, error) {
-	for _, env := range envNamesToInterpolate(data) {
-		parsedData, err := substituteEnv(string(data), env, envPrefixes)
-		if err != nil {
-			return nil, err
-		}
-		data = []byte(parsedData)
+// outputDir return the directory where the interpolated files are saved, scoped to the run if a run id is set
+func (o *Options) outputDir() (string, error) {
+	if len(o.runID) == 0 {
+		return o.outputPath, nil
 	}
 
-	return data, nil
+	return outputdir.RunPath(o.outputPath, o.runID)
 }
 
-func envNamesToInterpolate(data []byte) []string {
-	regex := regexp.MustCompile(envirnonmentRegex)
-	envNames := make([]string, 0)
-	for _, match := range regex.FindAllStringSubmatch(string(data), -1) {
-		if slices.Contains(envNames, match[1]) {
-			continue
-		}
-		envNames = append(envNames, match[1])
+// outputName return the name of the file that will contain the interpolated data read from path
+func (o *Options) outputName(path string) string {
+	if path == stdinToken {
+		return outputFileNameForStdin
 	}
 
-	return envNames
+	return filepath.Base(path)
+}
+
+// Interpolate will interpolate the data content with values from env values, returning an error if one of them
+// is not found
+func Interpolate(data []byte, envPrefixes []string) ([]byte, error) {
+	return DefaultDelimiters.Interpolate(data, envPrefixes)
+}
+
+// InterpolateKeepingMissing will interpolate the data content with values from env values, leaving untouched the
+// sequences of the ones not found and returning their names; an error is returned only if a value cannot be decoded
+func InterpolateKeepingMissing(data []byte, envPrefixes []string) ([]byte, []string, error) {
+	return DefaultDelimiters.InterpolateKeepingMissing(data, envPrefixes)
 }
 
-// substituteEnv substitute envName in data when encased in a set of delimiters appling transformations on the
-// value contained in it.
-func substituteEnv(data, envName string, prefixes []string) (string, error) {
-	doubleQouted := doubleQoutedLeftDelim + envName + doubleQoutedRightDelim
-	substitution, err := valueForEnv(envName, prefixes, func(str string) string {
-		str = strconv.Quote(str)
-		return strings.ReplaceAll(str, `\\`, `\`)
+// interpolateEnvs will interpolate the data content with values from env values, handling the ones not found
+// following the onMissing policy: returning an error, leaving the sequence untouched or substituting it with an
+// empty value, logging a warning in the warn case; record, if not nil, is called for every sequence substituted
+// with the value of an env
+func (m *sequenceMatcher) interpolateEnvs(data []byte, envPrefixes []string, onMissing string, lookup lookupFunc, record substitutionFunc, logger logr.Logger) ([]byte, error) {
+	warned := make([]string, 0)
+	return m.replaceSequences(data, func(envName string) (string, bool, error) {
+		value, found := lookup(envName, envPrefixes)
+		if found {
+			if record != nil {
+				record(envName)
+			}
+			return value, true, nil
+		}
+
+		switch onMissing {
+		case onMissingKeep:
+			return "", false, nil
+		case onMissingWarn:
+			if !slices.Contains(warned, envName) {
+				warned = append(warned, envName)
+				logger.Info("environment variable not found, using an empty value", "name", envName)
+			}
+		case onMissingEmpty:
+		default:
+			return "", false, fmt.Errorf("environment variable %q not found", envName)
+		}
+
+		return "", true, nil
 	})
-	if err != nil {
-		return "", err
+}
+
+// replaceSequences substitute every interpolation sequence in data with the value returned by valueFn, decoding it
+// from base64 if the sequence has the Base64DecodeSuffix and applying transformations based on the delimiters used,
+// or leave it untouched if valueFn does not return a value. The escaped sequences are written without their escape.
+// Data is scanned only once from the start, so the values substituted are never interpolated again and the result
+// does not depend on the order of the env names.
+func (m *sequenceMatcher) replaceSequences(data []byte, valueFn func(envName string) (string, bool, error)) ([]byte, error) {
+	matches := m.sequences.FindAllSubmatchIndex(data, -1)
+	if len(matches) == 0 {
+		return data, nil
 	}
 
-	data = strings.ReplaceAll(data, doubleQouted, substitution)
+	buffer := bytes.NewBuffer(make([]byte, 0, len(data)))
+	last := 0
+	for _, match := range matches {
+		buffer.Write(data[last:match[0]])
+		last = match[1]
+		sequence := data[match[0]:match[1]]
+
+		var envName, delim string
+		switch {
+		case string(sequence) == m.escapedLeft:
+			buffer.WriteString(m.left)
+			continue
+		case sequence[0] == '\\':
+			buffer.Write(sequence[1:])
+			continue
+		case match[2] >= 0:
+			envName, delim = string(data[match[2]:match[3]]), `"`
+		case match[4] >= 0:
+			envName, delim = string(data[match[4]:match[5]]), `'`
+		default:
+			envName = string(data[match[6]:match[7]])
+		}
 
-	singleQouted := singleQoutedLeftDelim + envName + singleQoutedRightDelim
-	substitution, err = valueForEnv(envName, prefixes, func(str string) string {
-		str = strconv.Quote(str)
-		str = strings.ReplaceAll(str, `\\`, `\`)
-		str = strings.ReplaceAll(str, `\"`, `"`)
-		return "'" + str[1:len(str)-1] + "'"
-	})
-	if err != nil {
-		return "", err
+		envName, decode := parseSequenceName(envName)
+		value, found, err := valueFn(envName)
+		if err != nil {
+			return nil, err
+		}
+
+		if !found {
+			buffer.Write(sequence)
+			continue
+		}
+
+		if decode {
+			if value, err = DecodeBase64Value(envName, value); err != nil {
+				return nil, err
+			}
+		}
+		buffer.WriteString(substituteValue(value, delim))
 	}
+	buffer.Write(data[last:])
 
-	data = strings.ReplaceAll(data, singleQouted, substitution)
+	return buffer.Bytes(), nil
+}
 
-	unquoted := unqutedLeftDelim + envName + unqutedRightDelim
-	substitution, err = valueForEnv(envName, prefixes, func(str string) string {
-		return strings.ReplaceAll(str, "\n", "\\n") // keep multiline string on one line
-	})
-	if err != nil {
-		return "", err
+// substituteValue return value transformed for replacing a sequence encased in delim quotes
+func substituteValue(value, delim string) string {
+	switch delim {
+	case `"`:
+		return strings.ReplaceAll(strconv.Quote(value), `\\`, `\`)
+	case `'`:
+		substitution := strings.ReplaceAll(strconv.Quote(value), `\\`, `\`)
+		substitution = strings.ReplaceAll(substitution, `\"`, `"`)
+		return "'" + substitution[1:len(substitution)-1] + "'"
+	default:
+		return strings.ReplaceAll(value, "\n", "\\n") // keep multiline string on one line
 	}
+}
 
-	return strings.ReplaceAll(data, unquoted, substitution), nil
+// lookupEnv return the value of envName searching first the names with one of the prefixes, in order, and
+// then the name without prefixes; the search stop at the first required prefix
+func lookupEnv(envName string, prefixes []string) (string, bool) {
+	_, value, found := resolveEnv(envName, prefixes)
+	return value, found
 }
 
-func valueForEnv(envName string, prefixes []string, fn func(string) string) (string, error) {
+// resolveEnv is like lookupEnv, but return also the name of the env that provided the value
+func resolveEnv(envName string, prefixes []string) (string, string, bool) {
 	envsToCheck := make([]string, 0, len(prefixes)+1)
+	bareFallback := true
 	for _, prefix := range prefixes {
+		prefix, required := strings.CutSuffix(prefix, RequiredPrefixMarker)
 		envsToCheck = append(envsToCheck, prefix+envName)
+		if required {
+			bareFallback = false
+			break
+		}
+	}
+	if bareFallback {
+		envsToCheck = append(envsToCheck, envName)
 	}
-	envsToCheck = append(envsToCheck, envName)
 
 	for _, envName := range envsToCheck {
 		if val, exists := os.LookupEnv(envName); exists {
-			return fn(val), nil
+			return envName, val, true
 		}
 	}
 
+	return "", "", false
+}
+
+// PrefixNames return prefixes without the markers of the required ones
+func PrefixNames(prefixes []string) []string {
+	names := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		names = append(names, strings.TrimSuffix(prefix, RequiredPrefixMarker))
+	}
+
+	return names
+}
+
+// withoutBareFallback return prefixes with the last one marked as required, so the bare names are never searched
+func withoutBareFallback(prefixes []string) []string {
+	if len(prefixes) == 0 || strings.HasSuffix(prefixes[len(prefixes)-1], RequiredPrefixMarker) {
+		return prefixes
+	}
+
+	required := slices.Clone(prefixes)
+	required[len(required)-1] += RequiredPrefixMarker
+	return required
+}
+
+func valueForEnv(envName string, prefixes []string, fn func(string) string) (string, error) {
+	if val, found := lookupEnv(envName, prefixes); found {
+		return fn(val), nil
+	}
+
 	return "", fmt.Errorf("environment variable %q not found", envName)
 }
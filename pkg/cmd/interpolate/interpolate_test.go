@@ -18,6 +18,8 @@ package interpolate
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
@@ -25,6 +27,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/mia-platform/mlp/v2/pkg/outputdir"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
@@ -43,17 +46,23 @@ func TestOptions(t *testing.T) {
 	buffer := new(bytes.Buffer)
 	fSys := filesys.MakeEmptyDirInMemory()
 	expectedOpts := &Options{
-		prefixes:   []string{"prefix"},
-		inputPaths: []string{"input"},
-		outputPath: "output",
-		fSys:       fSys,
-		reader:     buffer,
+		prefixes:    []string{"prefix"},
+		inputPaths:  []string{"input"},
+		outputPath:  "output",
+		engine:      "gotemplate",
+		onMissing:   "error",
+		concurrency: 4,
+		fSys:        fSys,
+		reader:      buffer,
 	}
 
 	flag := &Flags{
-		prefixes:   []string{"prefix"},
-		inputPaths: []string{"input"},
-		outputPath: "output",
+		prefixes:    []string{"prefix"},
+		inputPaths:  []string{"input"},
+		outputPath:  "output",
+		engine:      "gotemplate",
+		onMissing:   "error",
+		concurrency: 4,
 	}
 	opts, err := flag.ToOptions(buffer, fSys)
 	require.NoError(t, err)
@@ -66,6 +75,137 @@ func TestOptions(t *testing.T) {
 
 	opts.inputPaths = []string{"input", stdinToken}
 	assert.ErrorContains(t, opts.Validate(), "cannot read from stdin and other paths together")
+
+	opts.inputPaths = []string{"input"}
+	opts.engine = "wrong"
+	assert.ErrorContains(t, opts.Validate(), `invalid engine value: "wrong"`)
+
+	opts.engine = engineGoTemplate
+	opts.preserveTypes = true
+	assert.ErrorContains(t, opts.Validate(), `the "preserve-types" flag cannot be used with the "gotemplate" engine`)
+	opts.preserveTypes = false
+
+	opts.onMissing = "wrong"
+	assert.ErrorContains(t, opts.Validate(), `invalid on-missing value: "wrong"`)
+
+	opts.onMissing = onMissingKeep
+	assert.ErrorContains(t, opts.Validate(), `the "on-missing" flag cannot be used with the "gotemplate" engine`)
+	opts.onMissing = onMissingError
+
+	opts.auditLogPath = "audit.json"
+	assert.ErrorContains(t, opts.Validate(), `the "audit-log" flag cannot be used with the "gotemplate" engine`)
+	opts.auditLogPath = ""
+	opts.onMissing = onMissingKeep
+
+	opts.engine = engineDefault
+	assert.NoError(t, opts.Validate())
+
+	opts.concurrency = 0
+	assert.ErrorContains(t, opts.Validate(), `the "concurrency" flag must be greater than 0`)
+	opts.concurrency = 1
+
+	opts.runID = ".."
+	assert.ErrorContains(t, opts.Validate(), `invalid run id ".."`)
+	opts.runID = ""
+
+	opts.noBareFallback = true
+	opts.prefixes = nil
+	assert.ErrorContains(t, opts.Validate(), `the "no-bare-fallback" flag requires at least one "env-prefix" flag`)
+}
+
+func TestOptionsWithoutBareFallback(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		prefixes         []string
+		expectedPrefixes []string
+	}{
+		"last prefix is marked as required": {
+			prefixes:         []string{"TEST_", "PROD_"},
+			expectedPrefixes: []string{"TEST_", "PROD_!"},
+		},
+		"last prefix already required": {
+			prefixes:         []string{"TEST_", "PROD_!"},
+			expectedPrefixes: []string{"TEST_", "PROD_!"},
+		},
+		"no prefixes": {},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			flags := &Flags{prefixes: test.prefixes, noBareFallback: true}
+			opts, err := flags.ToOptions(nil, filesys.MakeEmptyDirInMemory())
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPrefixes, opts.prefixes)
+			assert.Equal(t, test.prefixes, flags.prefixes, "the flag values must not be modified")
+		})
+	}
+}
+
+func TestResolveEnvWithRequiredPrefixes(t *testing.T) {
+	t.Setenv("MLP_CHAIN_PROD_ONLY_PROD", "prod")
+	t.Setenv("MLP_CHAIN_DEV_ONLY_DEV", "dev")
+	t.Setenv("ONLY_DEV", "bare-dev")
+	t.Setenv("ONLY_BARE", "bare")
+
+	tests := map[string]struct {
+		prefixes       []string
+		envName        string
+		expectedSource string
+		expectedValue  string
+		expectedFound  bool
+	}{
+		"optional prefixes fall back to the bare name": {
+			prefixes:       []string{"MLP_CHAIN_PROD_", "MLP_CHAIN_DEV_"},
+			envName:        "ONLY_BARE",
+			expectedSource: "ONLY_BARE",
+			expectedValue:  "bare",
+			expectedFound:  true,
+		},
+		"required prefix provide the value": {
+			prefixes:       []string{"MLP_CHAIN_PROD_!", "MLP_CHAIN_DEV_"},
+			envName:        "ONLY_PROD",
+			expectedSource: "MLP_CHAIN_PROD_ONLY_PROD",
+			expectedValue:  "prod",
+			expectedFound:  true,
+		},
+		"required prefix stop the search": {
+			prefixes: []string{"MLP_CHAIN_PROD_!", "MLP_CHAIN_DEV_"},
+			envName:  "ONLY_DEV",
+		},
+		"required prefix disable the bare name": {
+			prefixes: []string{"MLP_CHAIN_PROD_!"},
+			envName:  "ONLY_BARE",
+		},
+		"prefixes before the required one are still used": {
+			prefixes:       []string{"MLP_CHAIN_DEV_", "MLP_CHAIN_PROD_!"},
+			envName:        "ONLY_DEV",
+			expectedSource: "MLP_CHAIN_DEV_ONLY_DEV",
+			expectedValue:  "dev",
+			expectedFound:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			source, value, found := resolveEnv(test.envName, test.prefixes)
+			assert.Equal(t, test.expectedSource, source)
+			assert.Equal(t, test.expectedValue, value)
+			assert.Equal(t, test.expectedFound, found)
+		})
+	}
+
+	_, err := Interpolate([]byte(`key: {{ONLY_BARE}}`), []string{"MLP_CHAIN_PROD_!"})
+	assert.ErrorContains(t, err, `environment variable "ONLY_BARE" not found`)
+}
+
+func TestPrefixNames(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"TEST_", "PROD_"}, PrefixNames([]string{"TEST_", "PROD_!"}))
+	assert.Empty(t, PrefixNames(nil))
 }
 
 func TestRun(t *testing.T) {
@@ -110,6 +250,17 @@ ZZZZZZZZZZZZZZZZZZZZZZZZZZ
 			},
 			expectedResultsPath: filepath.Join(testdata, "results"),
 		},
+		"interpolate multiple paths in parallel": {
+			option: &Options{
+				prefixes:    []string{"MLP_TEST_", "MLP_"},
+				inputPaths:  []string{filepath.Join(testdata, "folder"), filepath.Join(testdata, "file.yaml")},
+				outputPath:  filepath.Join(testTmpDir, "outputs-parallel"),
+				concurrency: 4,
+				fSys:        fSys,
+				reader:      new(bytes.Buffer),
+			},
+			expectedResultsPath: filepath.Join(testdata, "results"),
+		},
 		"interpolate from reader": {
 			option: &Options{
 				prefixes:   []string{"MLP_"},
@@ -124,6 +275,28 @@ ZZZZZZZZZZZZZZZZZZZZZZZZZZ
 			},
 			expectedResultsPath: filepath.Join(testdata, "stdin"),
 		},
+		"interpolate with gotemplate engine": {
+			option: &Options{
+				prefixes:   []string{"MLP_TEST_", "MLP_"},
+				inputPaths: []string{filepath.Join(testdata, "gotemplate")},
+				outputPath: filepath.Join(testTmpDir, "outputs-gotemplate"),
+				engine:     engineGoTemplate,
+				fSys:       fSys,
+				reader:     new(bytes.Buffer),
+			},
+			expectedResultsPath: filepath.Join(testdata, "gotemplate-results"),
+		},
+		"interpolate preserving types": {
+			option: &Options{
+				prefixes:      []string{"MLP_"},
+				inputPaths:    []string{filepath.Join(testdata, "preserve-types")},
+				outputPath:    filepath.Join(testTmpDir, "outputs-preserve-types"),
+				preserveTypes: true,
+				fSys:          fSys,
+				reader:        new(bytes.Buffer),
+			},
+			expectedResultsPath: filepath.Join(testdata, "preserve-types-results"),
+		},
 		"error with missing env": {
 			option: &Options{
 				prefixes:   []string{"MLP_MISSING"},
@@ -133,6 +306,36 @@ ZZZZZZZZZZZZZZZZZZZZZZZZZZ
 			},
 			expectedError: `environment variable "MISSING_ENV" not found`,
 		},
+		"keep missing env": {
+			option: &Options{
+				prefixes:   []string{"MLP_"},
+				inputPaths: []string{filepath.Join(testdata, "on-missing")},
+				outputPath: filepath.Join(testTmpDir, "outputs-on-missing-keep"),
+				onMissing:  onMissingKeep,
+				fSys:       fSys,
+			},
+			expectedResultsPath: filepath.Join(testdata, "on-missing-keep-results"),
+		},
+		"empty missing env": {
+			option: &Options{
+				prefixes:   []string{"MLP_"},
+				inputPaths: []string{filepath.Join(testdata, "on-missing")},
+				outputPath: filepath.Join(testTmpDir, "outputs-on-missing-empty"),
+				onMissing:  onMissingEmpty,
+				fSys:       fSys,
+			},
+			expectedResultsPath: filepath.Join(testdata, "on-missing-empty-results"),
+		},
+		"warn missing env": {
+			option: &Options{
+				prefixes:   []string{"MLP_"},
+				inputPaths: []string{filepath.Join(testdata, "on-missing")},
+				outputPath: filepath.Join(testTmpDir, "outputs-on-missing-warn"),
+				onMissing:  onMissingWarn,
+				fSys:       fSys,
+			},
+			expectedResultsPath: filepath.Join(testdata, "on-missing-empty-results"),
+		},
 		"error with ensuring output folder": {
 			option: &Options{
 				outputPath: func() string {
@@ -187,6 +390,67 @@ ZZZZZZZZZZZZZZZZZZZZZZZZZZ
 	}
 }
 
+func TestRunWithDuplicatedNames(t *testing.T) {
+	t.Setenv("MLP_SIMPLE_ENV", "test")
+
+	fSys := filesys.MakeFsInMemory()
+	for idx := range 20 {
+		data := fmt.Sprintf("key: value-%d-{{SIMPLE_ENV}}\n", idx)
+		require.NoError(t, fSys.WriteFile(filepath.Join(fmt.Sprintf("/input-%d", idx), "file.yaml"), []byte(data)))
+	}
+
+	inputPaths := make([]string, 0, 20)
+	for idx := range 20 {
+		inputPaths = append(inputPaths, filepath.Join(fmt.Sprintf("/input-%d", idx), "file.yaml"))
+	}
+
+	options := &Options{
+		prefixes:    []string{"MLP_"},
+		inputPaths:  inputPaths,
+		outputPath:  "/output",
+		concurrency: 8,
+		fSys:        fSys,
+	}
+	require.NoError(t, options.Run(context.TODO()))
+
+	// the last path wins like in a sequential interpolation
+	data, err := fSys.ReadFile(filepath.Join("/output", "file.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "key: value-19-test\n", string(data))
+}
+
+func TestRunWithRunID(t *testing.T) {
+	t.Setenv("MLP_SIMPLE_ENV", "test")
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile(filepath.Join("/input", "first.yaml"), []byte("key: {{SIMPLE_ENV}}\n")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("/input", "second.yaml"), []byte("key: value\n")))
+
+	options := &Options{
+		prefixes:    []string{"MLP_"},
+		inputPaths:  []string{"/input"},
+		outputPath:  "/output",
+		runID:       "run-1",
+		concurrency: 1,
+		fSys:        fSys,
+	}
+	require.NoError(t, options.Run(context.TODO()))
+
+	data, err := fSys.ReadFile(filepath.Join("/output", "run-1", "first.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "key: test\n", string(data))
+
+	data, err = fSys.ReadFile(filepath.Join("/output", "run-1", outputdir.IndexFileName))
+	require.NoError(t, err)
+	index := new(outputdir.Index)
+	require.NoError(t, json.Unmarshal(data, index))
+	assert.Equal(t, &outputdir.Index{
+		RunID:   "run-1",
+		Command: "interpolate",
+		Files:   []string{"first.yaml", "second.yaml"},
+	}, index)
+}
+
 func testStructure(t *testing.T, pathToTest, expectationPath string) {
 	t.Helper()
 
@@ -208,3 +472,128 @@ func testStructure(t *testing.T, pathToTest, expectationPath string) {
 		return nil
 	})
 }
+
+func TestInterpolateKeepingMissing(t *testing.T) {
+	t.Setenv("MLP_KEEP_FOUND", "found")
+	t.Setenv("MLP_KEEP_ENCODED", "ZW5jb2RlZA==")
+
+	data, missing, err := InterpolateKeepingMissing([]byte(`found: {{KEEP_FOUND}}
+decoded: {{KEEP_ENCODED|b64decode}}
+missing: "{{KEEP_MISSING}}"
+again: {{KEEP_MISSING}}
+escaped: \{{KEEP_ESCAPED}}
+`), []string{"MLP_"})
+	require.NoError(t, err)
+	assert.Equal(t, `found: found
+decoded: encoded
+missing: "{{KEEP_MISSING}}"
+again: {{KEEP_MISSING}}
+escaped: {{KEEP_ESCAPED}}
+`, string(data))
+	assert.Equal(t, []string{"KEEP_MISSING"}, missing)
+
+	_, _, err = InterpolateKeepingMissing([]byte(`invalid: {{KEEP_FOUND|b64decode}}`), []string{"MLP_"})
+	assert.ErrorContains(t, err, `environment variable "KEEP_FOUND" is not a valid base64 value`)
+}
+
+func TestInterpolateSequences(t *testing.T) {
+	t.Setenv("MLP_FIRST", "first")
+	t.Setenv("MLP_SECOND", "second")
+	t.Setenv("MLP_NESTED", "{{SECOND}}")
+	t.Setenv("MLP_ENCODED", "Zmlyc3Q=")
+	t.Setenv("MLP_ENCODED_MULTILINE", "Zmlyc3QKc2Vjb25k")
+	t.Setenv("MLP_ENCODED_QUOTE", "c2F5ICJoaSI=")
+	t.Setenv("MLP_ENCODED_WRAPPED", "Zmly\nc3Q=\n")
+
+	tests := map[string]struct {
+		data          string
+		expected      string
+		expectedError string
+	}{
+		"adjacent sequences": {
+			data:     `key: {{FIRST}}{{SECOND}}`,
+			expected: `key: firstsecond`,
+		},
+		"sequence inside braces": {
+			data:     `key: {{{{FIRST}}}}`,
+			expected: `key: {{first}}`,
+		},
+		"quoted adjacent sequences": {
+			data:     `key: "{{FIRST}}{{SECOND}}"`,
+			expected: `key: "firstsecond"`,
+		},
+		"value containing a sequence is not interpolated again": {
+			data:     `key: {{NESTED}} {{SECOND}}`,
+			expected: `key: {{SECOND}} second`,
+		},
+		"escaped sequence with backslash": {
+			data:     `key: \{{FIRST}} {{FIRST}}`,
+			expected: `key: {{FIRST}} first`,
+		},
+		"escaped sequence in double quotes": {
+			data:     `key: "\{{FIRST}}"`,
+			expected: `key: "{{FIRST}}"`,
+		},
+		"escaped delimiter": {
+			data:     `expr: '{{"{{"}} $labels.instance }}' # {{FIRST}}`,
+			expected: `expr: '{{ $labels.instance }}' # first`,
+		},
+		"escaped delimiter before a sequence": {
+			data:     `key: {{"{{"}}FIRST}}`,
+			expected: `key: {{FIRST}}`,
+		},
+		"escaped sequence of a missing env": {
+			data:     `key: \{{MISSING_ENV}}`,
+			expected: `key: {{MISSING_ENV}}`,
+		},
+		"backslash not followed by a sequence": {
+			data:     `key: \{{ not a sequence }}`,
+			expected: `key: \{{ not a sequence }}`,
+		},
+		"missing env": {
+			data:          `key: {{FIRST}} {{MISSING_ENV}}`,
+			expectedError: `environment variable "MISSING_ENV" not found`,
+		},
+		"base64 decoded value": {
+			data:     `key: {{ENCODED|b64decode}} {{ENCODED}}`,
+			expected: `key: first Zmlyc3Q=`,
+		},
+		"base64 decoded value in double quotes": {
+			data:     `key: "{{ENCODED_MULTILINE|b64decode}}"`,
+			expected: `key: "first\nsecond"`,
+		},
+		"base64 decoded value in single quotes": {
+			data:     `key: '{{ENCODED_QUOTE|b64decode}}'`,
+			expected: `key: 'say "hi"'`,
+		},
+		"base64 value wrapped on multiple lines": {
+			data:     `key: {{ENCODED_WRAPPED|b64decode}}`,
+			expected: `key: first`,
+		},
+		"escaped base64 sequence": {
+			data:     `key: \{{ENCODED|b64decode}}`,
+			expected: `key: {{ENCODED|b64decode}}`,
+		},
+		"invalid base64 value": {
+			data:          `key: {{FIRST|b64decode}}`,
+			expectedError: `environment variable "FIRST" is not a valid base64 value`,
+		},
+		"missing base64 env": {
+			data:          `key: {{MISSING_ENV|b64decode}}`,
+			expectedError: `environment variable "MISSING_ENV" not found`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := Interpolate([]byte(test.data), []string{"MLP_"})
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(data))
+		})
+	}
+}
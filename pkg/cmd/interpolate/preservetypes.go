@@ -0,0 +1,68 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"bytes"
+	"regexp"
+)
+
+const (
+	// typedValueRegex match the values that can be safely written as YAML numbers or booleans
+	typedValueRegex = `^(?:-?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][-+]?[0-9]+)?|true|false)$`
+)
+
+var (
+	typedValueRegexp = regexp.MustCompile(typedValueRegex)
+)
+
+// InterpolatePreservingTypes will interpolate the data content with values from env values like Interpolate, but
+// double quoted sequences used as a whole YAML value will lose their quotes if the env value is a number or a boolean.
+// Single quoted sequences are always interpolated as strings.
+func InterpolatePreservingTypes(data []byte, envPrefixes []string) ([]byte, error) {
+	return DefaultDelimiters.InterpolatePreservingTypes(data, envPrefixes)
+}
+
+// InterpolatePreservingTypes is like the package InterpolatePreservingTypes function, using the delimiters d
+func (d Delimiters) InterpolatePreservingTypes(data []byte, envPrefixes []string) ([]byte, error) {
+	return d.Interpolate(d.matcher().unquoteTypedScalars(data, envPrefixes, lookupEnv, nil), envPrefixes)
+}
+
+// unquoteTypedScalars substitute the double quoted sequences found in scalar positions with the raw env value
+// if it is a number or a boolean, all the other sequences are left untouched; record, if not nil, is called for
+// every substituted sequence
+func (m *sequenceMatcher) unquoteTypedScalars(data []byte, envPrefixes []string, lookup lookupFunc, record substitutionFunc) []byte {
+	return m.typedScalar.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := m.typedScalar.FindSubmatch(match)
+		envName, decode := parseSequenceName(string(groups[2]))
+		value, found := lookup(envName, envPrefixes)
+		if found && decode {
+			var err error
+			if value, err = DecodeBase64Value(envName, value); err != nil {
+				found = false
+			}
+		}
+		if !found || !typedValueRegexp.MatchString(value) {
+			// let the standard interpolation handle the sequence and any missing env or decoding error
+			return match
+		}
+
+		if record != nil {
+			record(envName)
+		}
+		return bytes.Join([][]byte{groups[1], []byte(value), groups[3]}, nil)
+	})
+}
@@ -0,0 +1,103 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interpolate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolatePreservingTypes(t *testing.T) {
+	t.Setenv("MLP_TYPES_REPLICAS", "2")
+	t.Setenv("TYPES_REPLICAS", "1")
+	t.Setenv("TYPES_FLOAT", "-1.5e3")
+	t.Setenv("TYPES_BOOLEAN", "true")
+	t.Setenv("TYPES_STRING", "value")
+	t.Setenv("TYPES_LEADING_ZERO", "007")
+	t.Setenv("TYPES_YAML_BOOLEAN", "yes")
+	t.Setenv("TYPES_ENCODED_REPLICAS", "Mw==")
+
+	prefixes := []string{"MLP_"}
+	tests := map[string]struct {
+		data           string
+		expectedResult string
+		expectedError  string
+	}{
+		"number as mapping value": {
+			data:           `replicas: "{{TYPES_REPLICAS}}"`,
+			expectedResult: `replicas: 2`,
+		},
+		"float and boolean with comments": {
+			data: `spec:
+  value: "{{TYPES_FLOAT}}" # a comment
+  enabled: "{{TYPES_BOOLEAN}}"`,
+			expectedResult: `spec:
+  value: -1.5e3 # a comment
+  enabled: true`,
+		},
+		"sequence items": {
+			data: `args:
+- "{{TYPES_REPLICAS}}"
+- - "{{TYPES_BOOLEAN}}"
+- name: "{{TYPES_BOOLEAN}}"`,
+			expectedResult: `args:
+- 2
+- - true
+- name: true`,
+		},
+		"single quotes force a string": {
+			data:           `replicas: '{{TYPES_REPLICAS}}'`,
+			expectedResult: `replicas: '2'`,
+		},
+		"strings keep their quotes": {
+			data: `name: "{{TYPES_STRING}}"
+code: "{{TYPES_LEADING_ZERO}}"
+flag: "{{TYPES_YAML_BOOLEAN}}"`,
+			expectedResult: `name: "value"
+code: "007"
+flag: "yes"`,
+		},
+		"sequences inside other strings are not changed": {
+			data: `image: "image:{{TYPES_REPLICAS}}"
+command: "echo {{TYPES_REPLICAS}}"`,
+			expectedResult: `image: "image:2"
+command: "echo 2"`,
+		},
+		"base64 decoded number": {
+			data:           `replicas: "{{TYPES_ENCODED_REPLICAS|b64decode}}"`,
+			expectedResult: `replicas: 3`,
+		},
+		"missing env": {
+			data:          `replicas: "{{TYPES_MISSING}}"`,
+			expectedError: `environment variable "TYPES_MISSING" not found`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := InterpolatePreservingTypes([]byte(test.data), prefixes)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedResult, string(result))
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
@@ -18,13 +18,17 @@ package kustomize
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"slices"
 
 	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/interpolate"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
@@ -44,26 +48,78 @@ const (
 
 	# Save output to a file
 	mlp kustomize --output /home/config/build-results.yaml
+
+	# Build a kustomization containing helm charts and interpolate the rendered output
+	mlp kustomize --enable-helm --interpolate --env-prefix DEV_
+
+	# Interpolate the kustomization and patch files before building them
+	mlp kustomize --interpolate-sources --env-prefix DEV_
 	`
 
 	outputFlagName          = "output"
 	outputFlagShort         = "o"
 	outputFlagUsage         = "If specified, write output to the file at this path"
 	outputIsADirectoryError = "output path is a directory instead of a file"
+
+	enableHelmFlagName     = "enable-helm"
+	enableHelmDefaultValue = false
+	enableHelmFlagUsage    = "enable use of the helm chart inflator generator"
+
+	helmCommandFlagName     = "helm-command"
+	helmCommandDefaultValue = "helm"
+	helmCommandFlagUsage    = "helm command (path to executable)"
+
+	loadRestrictorFlagName     = "load-restrictor"
+	loadRestrictorDefaultValue = "LoadRestrictionsRootOnly"
+	loadRestrictorFlagUsage    = "if set to 'LoadRestrictionsNone', local kustomizations may load files from outside their root"
+
+	enableAlphaPluginsFlagName     = "enable-alpha-plugins"
+	enableAlphaPluginsDefaultValue = false
+	enableAlphaPluginsFlagUsage    = "enable kustomize plugins"
+
+	interpolateFlagName     = "interpolate"
+	interpolateDefaultValue = false
+	interpolateFlagUsage    = "if true the env variables sequences in the rendered resources are interpolated"
+
+	interpolateSourcesFlagName     = "interpolate-sources"
+	interpolateSourcesDefaultValue = false
+	interpolateSourcesFlagUsage    = "if true the env variables sequences in the kustomization tree files are interpolated before the build"
+
+	prefixesFlagName  = "env-prefix"
+	prefixesFlagShort = "e"
+	prefixesFlagUsage = "prefixes to add when looking for ENV variables during the interpolation"
+)
+
+var (
+	validLoadRestrictorValues = []string{types.LoadRestrictionsRootOnly.String(), types.LoadRestrictionsNone.String()}
 )
 
 // Flags contains all the flags for the `kustomize` command. They will be converted to Options
 // that contains all runtime options for the command.
 type Flags struct {
-	outputPath string
+	outputPath         string
+	enableHelm         bool
+	helmCommand        string
+	loadRestrictor     string
+	enableAlphaPlugins bool
+	interpolate        bool
+	interpolateSources bool
+	prefixes           []string
 }
 
 // Options have the data required to perform the kustomize operation
 type Options struct {
-	inputPath  string
-	outputPath string
-	fSys       filesys.FileSystem
-	writer     io.Writer
+	inputPath          string
+	outputPath         string
+	enableHelm         bool
+	helmCommand        string
+	loadRestrictor     string
+	enableAlphaPlugins bool
+	interpolate        bool
+	interpolateSources bool
+	prefixes           []string
+	fSys               filesys.FileSystem
+	writer             io.Writer
 }
 
 // NewCommand return the command for build a kustomization target from a directory
@@ -85,6 +141,9 @@ func NewCommand() *cobra.Command {
 	}
 
 	flags.AddFlags(cmd.Flags())
+	if err := cmd.RegisterFlagCompletionFunc(loadRestrictorFlagName, loadRestrictorFlagCompletionfunc); err != nil {
+		panic(err)
+	}
 
 	return cmd
 }
@@ -92,6 +151,13 @@ func NewCommand() *cobra.Command {
 // AddFlags set the connection between Flags property to command line flags
 func (f *Flags) AddFlags(set *pflag.FlagSet) {
 	set.StringVarP(&f.outputPath, outputFlagName, outputFlagShort, "", outputFlagUsage)
+	set.BoolVar(&f.enableHelm, enableHelmFlagName, enableHelmDefaultValue, enableHelmFlagUsage)
+	set.StringVar(&f.helmCommand, helmCommandFlagName, helmCommandDefaultValue, helmCommandFlagUsage)
+	set.StringVar(&f.loadRestrictor, loadRestrictorFlagName, loadRestrictorDefaultValue, loadRestrictorFlagUsage)
+	set.BoolVar(&f.enableAlphaPlugins, enableAlphaPluginsFlagName, enableAlphaPluginsDefaultValue, enableAlphaPluginsFlagUsage)
+	set.BoolVar(&f.interpolate, interpolateFlagName, interpolateDefaultValue, interpolateFlagUsage)
+	set.BoolVar(&f.interpolateSources, interpolateSourcesFlagName, interpolateSourcesDefaultValue, interpolateSourcesFlagUsage)
+	set.StringSliceVarP(&f.prefixes, prefixesFlagName, prefixesFlagShort, nil, prefixesFlagUsage)
 }
 
 // ToOptions transform the command flags in command runtime arguments
@@ -108,11 +174,22 @@ func (f *Flags) ToOptions(args []string, fSys filesys.FileSystem, writer io.Writ
 		return nil, errors.New(outputIsADirectoryError)
 	}
 
+	if len(f.loadRestrictor) > 0 && !slices.Contains(validLoadRestrictorValues, f.loadRestrictor) {
+		return nil, fmt.Errorf("invalid load restrictor value: %q", f.loadRestrictor)
+	}
+
 	return &Options{
-		inputPath:  inputPath,
-		outputPath: f.outputPath,
-		fSys:       fSys,
-		writer:     writer,
+		inputPath:          inputPath,
+		outputPath:         f.outputPath,
+		enableHelm:         f.enableHelm,
+		helmCommand:        f.helmCommand,
+		loadRestrictor:     f.loadRestrictor,
+		enableAlphaPlugins: f.enableAlphaPlugins,
+		interpolate:        f.interpolate,
+		interpolateSources: f.interpolateSources,
+		prefixes:           f.prefixes,
+		fSys:               fSys,
+		writer:             writer,
 	}, nil
 }
 
@@ -121,8 +198,14 @@ func (o *Options) Run(ctx context.Context) error {
 	logger := logr.FromContextOrDiscard(ctx)
 
 	logger.V(5).Info("reading kustomize files", "path", o.inputPath)
-	kustomizer := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
-	resourceMap, err := kustomizer.Run(o.fSys, o.inputPath)
+	fSys := o.fSys
+	if o.interpolateSources {
+		logger.V(5).Info("interpolating kustomize files before the build", "prefixes", o.prefixes)
+		fSys = newInterpolatedFileSystem(o.fSys, o.prefixes)
+	}
+
+	kustomizer := krusty.MakeKustomizer(o.krustyOptions())
+	resourceMap, err := kustomizer.Run(fSys, o.inputPath)
 	if err != nil {
 		return err
 	}
@@ -132,6 +215,13 @@ func (o *Options) Run(ctx context.Context) error {
 		return err
 	}
 
+	if o.interpolate {
+		logger.V(5).Info("interpolating rendered resources", "prefixes", o.prefixes)
+		if yaml, err = interpolate.Interpolate(yaml, o.prefixes); err != nil {
+			return err
+		}
+	}
+
 	if len(o.outputPath) > 0 {
 		logger.V(5).Info("writing accumulated data", "path", o.outputPath)
 		return o.fSys.WriteFile(o.outputPath, yaml)
@@ -140,3 +230,22 @@ func (o *Options) Run(ctx context.Context) error {
 	_, err = o.writer.Write(yaml)
 	return err
 }
+
+func loadRestrictorFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return validLoadRestrictorValues, cobra.ShellCompDirectiveDefault
+}
+
+// krustyOptions return the options for the embedded kustomize engine
+func (o *Options) krustyOptions() *krusty.Options {
+	options := krusty.MakeDefaultOptions()
+	if o.loadRestrictor == types.LoadRestrictionsNone.String() {
+		options.LoadRestrictions = types.LoadRestrictionsNone
+	}
+	if o.enableAlphaPlugins {
+		options.PluginConfig = types.EnabledPluginConfig(types.BploUseStaticallyLinked)
+	}
+
+	options.PluginConfig.HelmConfig.Enabled = o.enableHelm
+	options.PluginConfig.HelmConfig.Command = o.helmCommand
+	return options
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
@@ -77,12 +78,41 @@ func TestToOptions(t *testing.T) {
 				writer:     buffer,
 			},
 		},
+		"create options with kustomize and interpolation flags": {
+			flags: &Flags{
+				enableHelm:         true,
+				helmCommand:        "helm",
+				loadRestrictor:     "LoadRestrictionsNone",
+				enableAlphaPlugins: true,
+				interpolate:        true,
+				interpolateSources: true,
+				prefixes:           []string{"DEV_"},
+			},
+			expectedOptions: &Options{
+				inputPath:          filesys.SelfDir,
+				enableHelm:         true,
+				helmCommand:        "helm",
+				loadRestrictor:     "LoadRestrictionsNone",
+				enableAlphaPlugins: true,
+				interpolate:        true,
+				interpolateSources: true,
+				prefixes:           []string{"DEV_"},
+				fSys:               fSys,
+				writer:             buffer,
+			},
+		},
 		"output path is a dir": {
 			flags: &Flags{
 				outputPath: testPath,
 			},
 			expectedError: outputIsADirectoryError,
 		},
+		"invalid load restrictor": {
+			flags: &Flags{
+				loadRestrictor: "invalid",
+			},
+			expectedError: `invalid load restrictor value: "invalid"`,
+		},
 	}
 
 	for name, test := range tests {
@@ -100,7 +130,29 @@ func TestToOptions(t *testing.T) {
 	}
 }
 
+func TestKrustyOptions(t *testing.T) {
+	t.Parallel()
+
+	options := (&Options{}).krustyOptions()
+	assert.Equal(t, types.LoadRestrictionsRootOnly, options.LoadRestrictions)
+	assert.Equal(t, types.PluginRestrictionsBuiltinsOnly, options.PluginConfig.PluginRestrictions)
+	assert.False(t, options.PluginConfig.HelmConfig.Enabled)
+
+	options = (&Options{
+		enableHelm:         true,
+		helmCommand:        "helm3",
+		loadRestrictor:     "LoadRestrictionsNone",
+		enableAlphaPlugins: true,
+	}).krustyOptions()
+	assert.Equal(t, types.LoadRestrictionsNone, options.LoadRestrictions)
+	assert.Equal(t, types.PluginRestrictionsNone, options.PluginConfig.PluginRestrictions)
+	assert.True(t, options.PluginConfig.HelmConfig.Enabled)
+	assert.Equal(t, "helm3", options.PluginConfig.HelmConfig.Command)
+}
+
 func TestRun(t *testing.T) {
+	t.Setenv("MLP_KUSTOMIZE_TEST_VALUE", "interpolated")
+	t.Setenv("MLP_KUSTOMIZE_TEST_PREFIX", "dev-")
 	tests := map[string]struct {
 		options        *Options
 		expectedOutput string
@@ -121,6 +173,56 @@ func TestRun(t *testing.T) {
 				fSys:       filesys.MakeFsOnDisk(),
 			},
 		},
+		"interpolate rendered resources": {
+			options: &Options{
+				inputPath:   filepath.Join("testdata", "interpolate"),
+				interpolate: true,
+				fSys:        filesys.MakeFsOnDisk(),
+				writer:      new(bytes.Buffer),
+			},
+			expectedOutput: `apiVersion: v1
+data:
+  key: 'interpolated'
+kind: ConfigMap
+metadata:
+  name: example
+`,
+		},
+		"interpolate kustomize files before the build": {
+			options: &Options{
+				inputPath:          filepath.Join("testdata", "interpolate-sources"),
+				interpolateSources: true,
+				fSys:               filesys.MakeFsOnDisk(),
+				writer:             new(bytes.Buffer),
+			},
+			expectedOutput: `apiVersion: v1
+data:
+  inline: interpolated
+  key: interpolated
+  patched: interpolated
+kind: ConfigMap
+metadata:
+  name: dev-example
+`,
+		},
+		"interpolate kustomize files with missing env": {
+			options: &Options{
+				inputPath:          filepath.Join("testdata", "missing-env"),
+				interpolateSources: true,
+				fSys:               filesys.MakeFsOnDisk(),
+				writer:             new(bytes.Buffer),
+			},
+			expectedError: `environment variable "MLP_KUSTOMIZE_MISSING_VALUE" not found`,
+		},
+		"interpolation with missing env": {
+			options: &Options{
+				inputPath:   filepath.Join("testdata", "missing-env"),
+				interpolate: true,
+				fSys:        filesys.MakeFsOnDisk(),
+				writer:      new(bytes.Buffer),
+			},
+			expectedError: `environment variable "MLP_KUSTOMIZE_MISSING_VALUE" not found`,
+		},
 		"error reading files": {
 			options: &Options{
 				inputPath:  "testdata",
@@ -150,6 +252,10 @@ func TestRun(t *testing.T) {
 			default:
 				assert.ErrorContains(t, err, test.expectedError)
 			}
+
+			if len(test.expectedOutput) > 0 {
+				assert.Equal(t, test.expectedOutput, test.options.writer.(*bytes.Buffer).String())
+			}
 		})
 	}
 }
@@ -0,0 +1,89 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kustomize
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/mia-platform/mlp/v2/pkg/cmd/interpolate"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// interpolatedFileSystem read the files of the kustomize tree from the underlying filesystem, interpolating the
+// env variables sequences found inside them and keeping the result in memory, so the build run against the
+// interpolated kustomization and patch files without modifying the original ones
+type interpolatedFileSystem struct {
+	filesys.FileSystem
+	memFs    filesys.FileSystem
+	prefixes []string
+}
+
+func newInterpolatedFileSystem(fSys filesys.FileSystem, prefixes []string) *interpolatedFileSystem {
+	return &interpolatedFileSystem{
+		FileSystem: fSys,
+		memFs:      filesys.MakeFsInMemory(),
+		prefixes:   prefixes,
+	}
+}
+
+// keep it to always check if interpolatedFileSystem implement correctly the filesys.FileSystem interface
+var _ filesys.FileSystem = &interpolatedFileSystem{}
+
+// Open implement filesys.FileSystem interface
+func (fs *interpolatedFileSystem) Open(path string) (filesys.File, error) {
+	key, err := fs.load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.memFs.Open(key)
+}
+
+// ReadFile implement filesys.FileSystem interface
+func (fs *interpolatedFileSystem) ReadFile(path string) ([]byte, error) {
+	key, err := fs.load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.memFs.ReadFile(key)
+}
+
+// load read the file at path from the underlying filesystem the first time is requested and save its
+// interpolated content in memory, returning the key to use for reading it
+func (fs *interpolatedFileSystem) load(path string) (string, error) {
+	key := path
+	if absPath, err := filepath.Abs(path); err == nil {
+		key = absPath
+	}
+
+	if fs.memFs.Exists(key) {
+		return key, nil
+	}
+
+	data, err := fs.FileSystem.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	interpolated, err := interpolate.Interpolate(data, fs.prefixes)
+	if err != nil {
+		return "", fmt.Errorf("interpolating %q: %w", path, err)
+	}
+
+	return key, fs.memFs.WriteFile(key, interpolated)
+}
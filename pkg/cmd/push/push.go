@@ -0,0 +1,340 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/oci"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	cmdUsage = "push REFERENCE"
+	cmdShort = "Push the rendered manifests to a registry as an OCI artifact"
+	cmdLong  = `Push the rendered manifests to a registry as an OCI artifact.
+
+	The files contained in the folder are packaged in a single compressed layer,
+	and the artifact is annotated with the commit of the configuration and its
+	creation date, so the same manifests can be promoted between environments
+	by tagging the artifact again instead of rendering them another time.
+
+	The credentials are read from the docker configuration file, like the ones
+	saved by 'docker login', or can be set with the --username and
+	--password-stdin flags.
+	`
+	cmdExamples = `# push the manifests rendered in the out folder
+	mlp push oci://registry.example.com/team/app:v1.2.0 -f ./out
+
+	# push the manifests attaching a report and reading the password from stdin
+	echo $REGISTRY_TOKEN | mlp push oci://registry.example.com/team/app:v1.2.0 -f ./out --report report.json --username ci --password-stdin
+	`
+
+	// ArtifactType is the type of the artifacts containing the rendered manifests
+	ArtifactType = "application/vnd.mia-platform.mlp.manifests.v1"
+	// ManifestsMediaType is the media type of the layer containing the rendered manifests
+	ManifestsMediaType = "application/vnd.mia-platform.mlp.manifests.v1.tar+gzip"
+	// ReportMediaType is the media type of the layer containing the report attached to the artifact
+	ReportMediaType = "application/vnd.mia-platform.mlp.report.v1+json"
+
+	manifestsLayerTitle = "manifests.tar.gz"
+
+	inputPathFlagName  = "filename"
+	inputPathShortName = "f"
+	inputPathFlagUsage = "folder containing the rendered manifests to push"
+
+	reportFlagName  = "report"
+	reportFlagUsage = "path to a json report attached to the artifact as a separate layer"
+
+	gitSHAFlagName  = "git-sha"
+	gitSHAFlagUsage = "commit of the configuration saved in the artifact annotations, default to the CI_COMMIT_SHA or GITHUB_SHA env"
+
+	annotationsFlagName  = "annotation"
+	annotationsFlagUsage = "additional annotation in the form key=value to add to the artifact, can be repeated"
+
+	plainHTTPFlagName     = "plain-http"
+	plainHTTPDefaultValue = false
+	plainHTTPFlagUsage    = "if true connect to the registry without TLS"
+
+	usernameFlagName  = "username"
+	usernameFlagUsage = "username for the registry, the credentials in the docker configuration are used if not set"
+
+	passwordStdinFlagName     = "password-stdin"
+	passwordStdinDefaultValue = false
+	passwordStdinFlagUsage    = "if true read the password for the registry from stdin"
+)
+
+// Flags contains all the flags for the `push` command. They will be converted to Options
+// that contains all runtime options for the command.
+type Flags struct {
+	inputPath     string
+	report        string
+	gitSHA        string
+	annotations   []string
+	plainHTTP     bool
+	username      string
+	passwordStdin bool
+}
+
+// Options have the data required to perform the push operation
+type Options struct {
+	reference     string
+	inputPath     string
+	report        string
+	gitSHA        string
+	annotations   map[string]string
+	plainHTTP     bool
+	username      string
+	passwordStdin bool
+
+	credentials oci.CredentialsFunc
+	clock       clock.PassiveClock
+	fSys        filesys.FileSystem
+	reader      io.Reader
+	writer      io.Writer
+}
+
+// NewCommand return the command for pushing the rendered manifests as an OCI artifact
+func NewCommand() *cobra.Command {
+	flags := &Flags{}
+
+	cmd := &cobra.Command{
+		Use:     cmdUsage,
+		Short:   heredoc.Doc(cmdShort),
+		Long:    heredoc.Doc(cmdLong),
+		Example: heredoc.Doc(cmdExamples),
+
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: cobra.NoFileCompletions,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			o, err := flags.ToOptions(args[0], cmd.InOrStdin(), cmd.OutOrStdout(), filesys.MakeFsOnDisk())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// AddFlags set the connection between Flags property to command line flags
+func (f *Flags) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&f.inputPath, inputPathFlagName, inputPathShortName, "", inputPathFlagUsage)
+	flags.StringVar(&f.report, reportFlagName, "", reportFlagUsage)
+	flags.StringVar(&f.gitSHA, gitSHAFlagName, cmp.Or(os.Getenv("CI_COMMIT_SHA"), os.Getenv("GITHUB_SHA")), gitSHAFlagUsage)
+	flags.StringArrayVar(&f.annotations, annotationsFlagName, nil, annotationsFlagUsage)
+	flags.BoolVar(&f.plainHTTP, plainHTTPFlagName, plainHTTPDefaultValue, plainHTTPFlagUsage)
+	flags.StringVar(&f.username, usernameFlagName, "", usernameFlagUsage)
+	flags.BoolVar(&f.passwordStdin, passwordStdinFlagName, passwordStdinDefaultValue, passwordStdinFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *Flags) ToOptions(reference string, reader io.Reader, writer io.Writer, fSys filesys.FileSystem) (*Options, error) {
+	annotations := make(map[string]string, len(f.annotations))
+	for _, annotation := range f.annotations {
+		key, value, found := strings.Cut(annotation, "=")
+		if !found || len(key) == 0 {
+			return nil, fmt.Errorf("invalid value %q for %q flag: must be in the form key=value", annotation, annotationsFlagName)
+		}
+		annotations[key] = value
+	}
+
+	return &Options{
+		reference:     reference,
+		inputPath:     f.inputPath,
+		report:        f.report,
+		gitSHA:        f.gitSHA,
+		annotations:   annotations,
+		plainHTTP:     f.plainHTTP,
+		username:      f.username,
+		passwordStdin: f.passwordStdin,
+
+		credentials: oci.DockerConfigCredentials(),
+		clock:       clock.RealClock{},
+		fSys:        fSys,
+		reader:      reader,
+		writer:      writer,
+	}, nil
+}
+
+// Validate check the options for errors
+func (o *Options) Validate() error {
+	if _, err := oci.ParseReference(o.reference); err != nil {
+		return err
+	}
+
+	if len(o.inputPath) == 0 {
+		return fmt.Errorf("the folder containing the manifests must be specified with the %q flag", inputPathFlagName)
+	}
+
+	if !o.fSys.IsDir(o.inputPath) {
+		return fmt.Errorf("%q is not a folder", o.inputPath)
+	}
+
+	if o.passwordStdin && len(o.username) == 0 {
+		return fmt.Errorf("the %q flag requires the %q flag", passwordStdinFlagName, usernameFlagName)
+	}
+
+	return nil
+}
+
+// Run execute the push command
+func (o *Options) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+	reference, err := oci.ParseReference(o.reference)
+	if err != nil {
+		return err
+	}
+
+	credentials := o.credentials
+	if len(o.username) > 0 {
+		password := ""
+		if o.passwordStdin {
+			data, err := io.ReadAll(o.reader)
+			if err != nil {
+				return fmt.Errorf("failed to read the password from stdin: %w", err)
+			}
+			password = strings.TrimRight(string(data), "\r\n")
+		}
+		credentials = oci.StaticCredentials(oci.Credentials{Username: o.username, Password: password})
+	}
+
+	manifests, files, err := archive(o.fSys, o.inputPath)
+	if err != nil {
+		return err
+	}
+	if files == 0 {
+		return fmt.Errorf("no files found in %q", o.inputPath)
+	}
+
+	artifact := oci.Artifact{
+		ArtifactType: ArtifactType,
+		Layers: []oci.Layer{
+			{MediaType: ManifestsMediaType, Data: manifests, Annotations: map[string]string{oci.AnnotationTitle: manifestsLayerTitle}},
+		},
+		Annotations: o.artifactAnnotations(),
+	}
+
+	if len(o.report) > 0 {
+		data, err := o.fSys.ReadFile(o.report)
+		if err != nil {
+			return fmt.Errorf("failed to read report: %w", err)
+		}
+		artifact.Layers = append(artifact.Layers, oci.Layer{
+			MediaType:   ReportMediaType,
+			Data:        data,
+			Annotations: map[string]string{oci.AnnotationTitle: filepath.Base(o.report)},
+		})
+	}
+
+	logger.V(3).Info("pushing artifact", "reference", reference.String(), "files", files, "size", len(manifests))
+	digest, err := oci.NewClient(o.plainHTTP, credentials).Push(ctx, reference, artifact)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(o.writer, "pushed %s@%s\n", reference, digest)
+	return nil
+}
+
+// artifactAnnotations return the annotations of the artifact, the ones set via flag override the default ones
+func (o *Options) artifactAnnotations() map[string]string {
+	annotations := map[string]string{
+		oci.AnnotationCreated: o.clock.Now().UTC().Format(time.RFC3339),
+	}
+	if len(o.gitSHA) > 0 {
+		annotations[oci.AnnotationRevision] = o.gitSHA
+	}
+
+	for key, value := range o.annotations {
+		annotations[key] = value
+	}
+	return annotations
+}
+
+// archive return a compressed tar containing the files found in root with their path relative to it, and the
+// number of files added; the archive doesn't contain timestamps and owners so it only depends on the files content
+func archive(fSys filesys.FileSystem, root string) ([]byte, int, error) {
+	paths := make([]string, 0)
+	err := fSys.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %q: %w", root, err)
+	}
+	slices.Sort(paths)
+
+	buffer := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, path := range paths {
+		data, err := fSys.ReadFile(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read %q: %w", path, err)
+		}
+
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		header := &tar.Header{
+			Name:     filepath.ToSlash(name),
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatPAX,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return nil, 0, err
+		}
+		if _, err := tarWriter.Write(data); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, 0, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, 0, err
+	}
+	return buffer.Bytes(), len(paths), nil
+}
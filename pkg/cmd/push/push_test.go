@@ -0,0 +1,237 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/mlp/v2/pkg/oci"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewCommand()
+	assert.NotNil(t, cmd)
+	assert.NotNil(t, cmd.Flags().Lookup(inputPathFlagName))
+	assert.NotNil(t, cmd.Flags().Lookup(gitSHAFlagName))
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.MkdirAll("out"))
+	require.NoError(t, fSys.WriteFile("file.yaml", []byte("{}")))
+
+	tests := map[string]struct {
+		reference     string
+		flags         *Flags
+		expectedError string
+	}{
+		"valid options": {
+			reference: "oci://registry.example.com/app:v1",
+			flags:     &Flags{inputPath: "out", annotations: []string{"team=platform", "empty="}},
+		},
+		"invalid reference": {
+			reference:     "registry.example.com/app:v1",
+			flags:         &Flags{inputPath: "out"},
+			expectedError: "must start with oci://",
+		},
+		"missing folder": {
+			reference:     "oci://registry.example.com/app:v1",
+			flags:         &Flags{},
+			expectedError: `the folder containing the manifests must be specified with the "filename" flag`,
+		},
+		"not a folder": {
+			reference:     "oci://registry.example.com/app:v1",
+			flags:         &Flags{inputPath: "file.yaml"},
+			expectedError: `"file.yaml" is not a folder`,
+		},
+		"password without username": {
+			reference:     "oci://registry.example.com/app:v1",
+			flags:         &Flags{inputPath: "out", passwordStdin: true},
+			expectedError: `the "password-stdin" flag requires the "username" flag`,
+		},
+		"invalid annotation": {
+			reference:     "oci://registry.example.com/app:v1",
+			flags:         &Flags{inputPath: "out", annotations: []string{"team"}},
+			expectedError: `invalid value "team" for "annotation" flag: must be in the form key=value`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			opts, err := test.flags.ToOptions(test.reference, nil, nil, fSys)
+			if err == nil {
+				err = opts.Validate()
+			}
+
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, map[string]string{"team": "platform", "empty": ""}, opts.annotations)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	lock := sync.Mutex{}
+	blobs := make(map[string][]byte)
+	manifests := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if username, password, ok := r.BasicAuth(); !ok || username != "ci" || password != "token" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPost:
+			w.Header().Set("Location", "/v2/team/app/blobs/uploads/id")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/blobs/uploads/"):
+			blobs[r.URL.Query().Get("digest")] = body
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/"):
+			manifests[r.URL.Path] = body
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	fSys := filesys.MakeFsOnDisk()
+	require.NoError(t, fSys.MkdirAll(filepath.Join(dir, "out", "nested")))
+	require.NoError(t, fSys.WriteFile(filepath.Join(dir, "out", "deployment.yaml"), []byte("kind: Deployment\n")))
+	require.NoError(t, fSys.WriteFile(filepath.Join(dir, "out", "nested", "service.yaml"), []byte("kind: Service\n")))
+	require.NoError(t, fSys.WriteFile(filepath.Join(dir, "report.json"), []byte(`{"resources":2}`)))
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	writer := new(bytes.Buffer)
+	flags := &Flags{
+		inputPath:     filepath.Join(dir, "out"),
+		report:        filepath.Join(dir, "report.json"),
+		gitSHA:        "0123456789abcdef",
+		annotations:   []string{"org.opencontainers.image.source=https://git.example.com/team/app"},
+		plainHTTP:     true,
+		username:      "ci",
+		passwordStdin: true,
+	}
+	opts, err := flags.ToOptions(fmt.Sprintf("oci://%s/team/app:v1.0.0", host), strings.NewReader("token\n"), writer, fSys)
+	require.NoError(t, err)
+	opts.clock = clocktesting.NewFakePassiveClock(time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC))
+	require.NoError(t, opts.Validate())
+	require.NoError(t, opts.Run(context.TODO()))
+
+	data, found := manifests["/v2/team/app/manifests/v1.0.0"]
+	require.True(t, found)
+	assert.Contains(t, writer.String(), fmt.Sprintf("pushed oci://%s/team/app:v1.0.0@sha256:", host))
+
+	manifest := oci.Manifest{}
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	assert.Equal(t, ArtifactType, manifest.ArtifactType)
+	assert.Equal(t, map[string]string{
+		oci.AnnotationCreated:             "2024-03-01T10:00:00Z",
+		oci.AnnotationRevision:            "0123456789abcdef",
+		"org.opencontainers.image.source": "https://git.example.com/team/app",
+	}, manifest.Annotations)
+
+	require.Len(t, manifest.Layers, 2)
+	assert.Equal(t, ManifestsMediaType, manifest.Layers[0].MediaType)
+	assert.Equal(t, map[string]string{
+		"deployment.yaml":     "kind: Deployment\n",
+		"nested/service.yaml": "kind: Service\n",
+	}, readArchive(t, blobs[manifest.Layers[0].Digest]))
+	assert.Equal(t, ReportMediaType, manifest.Layers[1].MediaType)
+	assert.Equal(t, "report.json", manifest.Layers[1].Annotations[oci.AnnotationTitle])
+	assert.Equal(t, `{"resources":2}`, string(blobs[manifest.Layers[1].Digest]))
+}
+
+func TestArchiveIsReproducible(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fSys := filesys.MakeFsOnDisk()
+	require.NoError(t, fSys.MkdirAll(filepath.Join(dir, "out")))
+	require.NoError(t, fSys.WriteFile(filepath.Join(dir, "out", "b.yaml"), []byte("b")))
+	require.NoError(t, fSys.WriteFile(filepath.Join(dir, "out", "a.yaml"), []byte("a")))
+
+	first, files, err := archive(fSys, filepath.Join(dir, "out"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, files)
+
+	second, _, err := archive(fSys, filepath.Join(dir, "out"))
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	require.NoError(t, fSys.MkdirAll(filepath.Join(dir, "empty")))
+	_, files, err = archive(fSys, filepath.Join(dir, "empty"))
+	require.NoError(t, err)
+	assert.Zero(t, files)
+}
+
+func readArchive(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tarReader := tar.NewReader(gzipReader)
+	files := make(map[string]string)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tarReader)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+	return files
+}
@@ -0,0 +1,349 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/deploy"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/interpolate"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	applyCmdUsage = "apply"
+	applyCmdShort = "Deploy the manifests described by a release file"
+	applyCmdLong  = `Deploy the manifests described by a release file.
+
+	The sources of the release are interpolated with the environment variables
+	matching its env prefixes, if any, and then deployed in its namespace with its
+	deploy type and wait policies. The pre apply hooks are run before the deploy,
+	and the post apply hooks after it completes successfully.
+	`
+	applyCmdExamples = `# deploy the release described in the release.yaml file of the current directory
+	mlp release apply
+
+	# check the changes of the release without applying them
+	mlp release apply -f deploy/release.yaml --dry-run
+	`
+
+	releaseFileFlagName     = "filename"
+	releaseFileShortName    = "f"
+	releaseFileDefaultValue = "release.yaml"
+	releaseFileFlagUsage    = "the release file describing the deploy"
+
+	dryRunFlagName     = "dry-run"
+	dryRunDefaultValue = false
+	dryRunFlagUsage    = "if true the resources are sent to the server in dry run mode and the hooks are not run"
+
+	// the names of the deploy and interpolate flags set from the release file
+	inputPathsFlagName         = "filename"
+	deployTypeFlagName         = "deploy-type"
+	ensureNamespaceFlagName    = "ensure-namespace"
+	releaseNameFlagName        = "release-name"
+	waitTimeoutFlagName        = "wait-timeout"
+	healthCheckTimeoutFlagName = "health-check-timeout"
+	dependsOnTimeoutFlagName   = "depends-on-timeout"
+	pruneWaitTimeoutFlagName   = "prune-wait-timeout"
+	envPrefixFlagName          = "env-prefix"
+	outputFlagName             = "out"
+)
+
+// deployFunc apply the resources in paths to the cluster configured in configFlags, using flagValues as the
+// values of the deploy command flags
+type deployFunc func(ctx context.Context, configFlags *genericclioptions.ConfigFlags, paths []string, flagValues map[string]string, writer io.Writer) error
+
+// interpolateFunc interpolate the environment variables matching prefixes in the files of path, saving the
+// results in outputPath
+type interpolateFunc func(ctx context.Context, path string, prefixes []string, outputPath string) error
+
+// hookRunner execute command in dir, writing its output to writer
+type hookRunner func(ctx context.Context, dir string, writer io.Writer, command []string) error
+
+// ApplyFlags contains all the flags for the `release apply` command. They will be converted to ApplyOptions
+// that contains all runtime options for the command.
+type ApplyFlags struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+	releaseFile string
+	dryRun      bool
+}
+
+// ApplyOptions have the data required to perform the release apply operation
+type ApplyOptions struct {
+	releaseFile string
+	dryRun      bool
+
+	configFlags *genericclioptions.ConfigFlags
+	deploy      deployFunc
+	interpolate interpolateFunc
+	runHook     hookRunner
+	fSys        filesys.FileSystem
+	writer      io.Writer
+}
+
+// newApplyCommand return the command for deploying a release file
+func newApplyCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	flags := &ApplyFlags{
+		ConfigFlags: configFlags,
+	}
+
+	cmd := &cobra.Command{
+		Use:     applyCmdUsage,
+		Short:   heredoc.Doc(applyCmdShort),
+		Long:    heredoc.Doc(applyCmdLong),
+		Example: heredoc.Doc(applyCmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(cmd.OutOrStderr(), filesys.MakeFsOnDisk())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// AddFlags set the connection between ApplyFlags property to command line flags
+func (f *ApplyFlags) AddFlags(flags *pflag.FlagSet) {
+	if f.ConfigFlags != nil {
+		f.ConfigFlags.AddFlags(flags)
+	}
+
+	flags.StringVarP(&f.releaseFile, releaseFileFlagName, releaseFileShortName, releaseFileDefaultValue, releaseFileFlagUsage)
+	flags.BoolVar(&f.dryRun, dryRunFlagName, dryRunDefaultValue, dryRunFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *ApplyFlags) ToOptions(writer io.Writer, fSys filesys.FileSystem) (*ApplyOptions, error) {
+	if f.ConfigFlags == nil {
+		return nil, fmt.Errorf("config flags are required")
+	}
+
+	return &ApplyOptions{
+		releaseFile: f.releaseFile,
+		dryRun:      f.dryRun,
+
+		configFlags: f.ConfigFlags,
+		deploy:      runDeploy,
+		interpolate: runInterpolate,
+		runHook:     execHook,
+		fSys:        fSys,
+		writer:      writer,
+	}, nil
+}
+
+// Validate will check that the options are consistent
+func (o *ApplyOptions) Validate() error {
+	if len(o.releaseFile) == 0 {
+		return fmt.Errorf("the %q flag cannot be empty", releaseFileFlagName)
+	}
+
+	if o.releaseFile == stdinToken {
+		return fmt.Errorf("the release file cannot be read from stdin")
+	}
+
+	return nil
+}
+
+// Run execute the release apply command
+func (o *ApplyOptions) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	release, err := Load(o.fSys, o.releaseFile)
+	if err != nil {
+		return err
+	}
+
+	if err := o.setNamespace(release.Namespace); err != nil {
+		return err
+	}
+
+	if err := o.runHooks(ctx, release, "pre apply", release.Hooks.PreApply); err != nil {
+		return err
+	}
+
+	paths := release.sourcePaths()
+	if len(release.EnvPrefixes) > 0 {
+		tmpDir, err := os.MkdirTemp("", "mlp-release-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		interpolatedPaths := make([]string, 0, len(paths))
+		for idx, path := range paths {
+			// every source is saved in its own folder for avoiding collisions between files with the same name
+			outputPath := filepath.Join(tmpDir, strconv.Itoa(idx))
+			logger.V(3).Info("interpolating source", "path", path, "output", outputPath)
+			if err := o.interpolate(ctx, path, release.EnvPrefixes, outputPath); err != nil {
+				return fmt.Errorf("interpolating source %q: %w", path, err)
+			}
+			interpolatedPaths = append(interpolatedPaths, outputPath)
+		}
+		paths = interpolatedPaths
+	}
+
+	logger.V(3).Info("deploying release", "paths", strings.Join(paths, ", "))
+	if err := o.deploy(ctx, o.configFlags, paths, release.deployFlagValues(o.dryRun), o.writer); err != nil {
+		return fmt.Errorf("deploying release: %w", err)
+	}
+
+	return o.runHooks(ctx, release, "post apply", release.Hooks.PostApply)
+}
+
+// setNamespace set the namespace of the release as the target namespace, failing if a different one is
+// passed via flag
+func (o *ApplyOptions) setNamespace(namespace string) error {
+	if len(namespace) == 0 {
+		return nil
+	}
+
+	if o.configFlags.Namespace != nil && len(*o.configFlags.Namespace) > 0 {
+		if *o.configFlags.Namespace != namespace {
+			return fmt.Errorf("the namespace %q set via flag is different from the release namespace %q", *o.configFlags.Namespace, namespace)
+		}
+		return nil
+	}
+
+	o.configFlags.Namespace = &namespace
+	return nil
+}
+
+// runHooks execute hooks in the directory of the release file, stopping at the first failure; the hooks are
+// skipped in dry run mode
+func (o *ApplyOptions) runHooks(ctx context.Context, release *Release, phase string, hooks []Hook) error {
+	for _, hook := range hooks {
+		if o.dryRun {
+			fmt.Fprintf(o.writer, "skipping %s hook %q in dry run mode\n", phase, hook.Name)
+			continue
+		}
+
+		fmt.Fprintf(o.writer, "running %s hook %q\n", phase, hook.Name)
+		if err := o.runHook(ctx, release.dir, o.writer, hook.Command); err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", phase, hook.Name, err)
+		}
+	}
+	return nil
+}
+
+// deployFlagValues return the values of the deploy command flags set by the release
+func (r *Release) deployFlagValues(dryRun bool) map[string]string {
+	values := make(map[string]string)
+	if len(r.Name) > 0 {
+		values[releaseNameFlagName] = r.Name
+	}
+	if len(r.DeployType) > 0 {
+		values[deployTypeFlagName] = r.DeployType
+	}
+	if r.EnsureNamespace != nil {
+		values[ensureNamespaceFlagName] = strconv.FormatBool(*r.EnsureNamespace)
+	}
+	if r.Wait.Timeout != nil {
+		values[waitTimeoutFlagName] = r.Wait.Timeout.Duration.String()
+	}
+	if r.Wait.HealthCheckTimeout != nil {
+		values[healthCheckTimeoutFlagName] = r.Wait.HealthCheckTimeout.Duration.String()
+	}
+	if r.Wait.DependsOnTimeout != nil {
+		values[dependsOnTimeoutFlagName] = r.Wait.DependsOnTimeout.Duration.String()
+	}
+	if r.Wait.PruneTimeout != nil {
+		values[pruneWaitTimeoutFlagName] = r.Wait.PruneTimeout.Duration.String()
+	}
+	if dryRun {
+		values[dryRunFlagName] = strconv.FormatBool(dryRun)
+	}
+	return values
+}
+
+// runDeploy is the deployFunc that use the deploy command, the flags not set in flagValues keep their
+// default values
+func runDeploy(ctx context.Context, configFlags *genericclioptions.ConfigFlags, paths []string, flagValues map[string]string, writer io.Writer) error {
+	o, err := deploy.NewOptions(ctx, configFlags, paths, flagValues, writer)
+	if err != nil {
+		return err
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	return o.Run(ctx)
+}
+
+// runInterpolate is the interpolateFunc that use the interpolate command with its default values
+func runInterpolate(ctx context.Context, path string, prefixes []string, outputPath string) error {
+	flags := &interpolate.Flags{}
+	flagSet := pflag.NewFlagSet(applyCmdUsage, pflag.ContinueOnError)
+	flags.AddFlags(flagSet)
+	flagValues := map[string]string{
+		envPrefixFlagName: strings.Join(prefixes, ","),
+		outputFlagName:    outputPath,
+	}
+	if err := setFlags(flagSet, []string{path}, flagValues); err != nil {
+		return err
+	}
+
+	o, err := flags.ToOptions(nil, filesys.MakeFsOnDisk())
+	if err != nil {
+		return err
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	return o.Run(ctx)
+}
+
+// setFlags set paths as the value of the filename flag, and the other flags to the values in flagValues
+func setFlags(flagSet *pflag.FlagSet, paths []string, flagValues map[string]string) error {
+	inputPaths, ok := flagSet.Lookup(inputPathsFlagName).Value.(pflag.SliceValue)
+	if !ok {
+		return fmt.Errorf("the %q flag does not accept multiple values", inputPathsFlagName)
+	}
+	if err := inputPaths.Replace(paths); err != nil {
+		return err
+	}
+
+	for name, value := range flagValues {
+		if err := flagSet.Set(name, value); err != nil {
+			return fmt.Errorf("setting %q flag: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// execHook is the hookRunner that execute the command found in the PATH, with the environment of mlp
+func execHook(ctx context.Context, dir string, writer io.Writer, command []string) error {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	return cmd.Run()
+}
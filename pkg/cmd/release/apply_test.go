@@ -0,0 +1,244 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/mia-platform/mlp/v2/pkg/cmd/deploy"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const testRelease = `apiVersion: mlp.mia-platform.eu/v1
+kind: Release
+name: api
+namespace: production
+sources:
+- manifests
+- shared
+deployType: deploy_all
+hooks:
+  preApply:
+  - name: migrate
+    command: [./migrate.sh]
+  postApply:
+  - name: notify
+    command: [./notify.sh, done]
+wait:
+  healthCheckTimeout: 5m
+`
+
+func TestApplyOptions(t *testing.T) {
+	t.Parallel()
+
+	configFlags := genericclioptions.NewConfigFlags(false)
+	flags := &ApplyFlags{ConfigFlags: configFlags}
+	flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.AddFlags(flagSet)
+	require.NoError(t, flagSet.Parse([]string{"-f", "deploy/release.yaml", "--dry-run"}))
+
+	opts, err := flags.ToOptions(io.Discard, filesys.MakeFsInMemory())
+	require.NoError(t, err)
+	assert.Equal(t, "deploy/release.yaml", opts.releaseFile)
+	assert.True(t, opts.dryRun)
+	assert.NoError(t, opts.Validate())
+
+	opts.releaseFile = stdinToken
+	assert.ErrorContains(t, opts.Validate(), "the release file cannot be read from stdin")
+
+	_, err = (&ApplyFlags{}).ToOptions(io.Discard, filesys.MakeFsInMemory())
+	assert.ErrorContains(t, err, "config flags are required")
+}
+
+func TestApplyRun(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		release              string
+		namespaceFlag        string
+		dryRun               bool
+		deployErr            error
+		hookErr              error
+		expectedPaths        []string
+		expectedFlags        map[string]string
+		expectedHooks        [][]string
+		expectedOutput       string
+		expectedInterpolated int
+		expectedError        string
+	}{
+		"deploy with hooks": {
+			release:       testRelease,
+			expectedPaths: []string{"/deploy/manifests", "/deploy/shared"},
+			expectedFlags: map[string]string{
+				"release-name":         "api",
+				"deploy-type":          "deploy_all",
+				"health-check-timeout": "5m0s",
+			},
+			expectedHooks:  [][]string{{"./migrate.sh"}, {"./notify.sh", "done"}},
+			expectedOutput: "running pre apply hook \"migrate\"\nrunning post apply hook \"notify\"\n",
+		},
+		"dry run skips hooks": {
+			release:       testRelease,
+			dryRun:        true,
+			expectedPaths: []string{"/deploy/manifests", "/deploy/shared"},
+			expectedFlags: map[string]string{
+				"release-name":         "api",
+				"deploy-type":          "deploy_all",
+				"health-check-timeout": "5m0s",
+				"dry-run":              "true",
+			},
+			expectedOutput: "skipping pre apply hook \"migrate\" in dry run mode\nskipping post apply hook \"notify\" in dry run mode\n",
+		},
+		"same namespace via flag": {
+			release:        "apiVersion: mlp.mia-platform.eu/v1\nkind: Release\nnamespace: production\nsources: [manifests]\n",
+			namespaceFlag:  "production",
+			expectedPaths:  []string{"/deploy/manifests"},
+			expectedFlags:  map[string]string{},
+			expectedOutput: "",
+		},
+		"interpolated sources": {
+			release:              "apiVersion: mlp.mia-platform.eu/v1\nkind: Release\nsources: [manifests, shared]\nenvPrefixes: [MLP_]\nensureNamespace: false\n",
+			expectedFlags:        map[string]string{"ensure-namespace": "false"},
+			expectedInterpolated: 2,
+		},
+		"different namespace via flag": {
+			release:       testRelease,
+			namespaceFlag: "staging",
+			expectedError: `the namespace "staging" set via flag is different from the release namespace "production"`,
+		},
+		"failing pre apply hook": {
+			release:       testRelease,
+			hookErr:       errors.New("exit status 1"),
+			expectedHooks: [][]string{{"./migrate.sh"}},
+			expectedError: `pre apply hook "migrate" failed: exit status 1`,
+		},
+		"failing deploy": {
+			release:       testRelease,
+			deployErr:     errors.New("timeout"),
+			expectedPaths: []string{"/deploy/manifests", "/deploy/shared"},
+			expectedFlags: map[string]string{
+				"release-name":         "api",
+				"deploy-type":          "deploy_all",
+				"health-check-timeout": "5m0s",
+			},
+			expectedHooks: [][]string{{"./migrate.sh"}},
+			expectedError: "deploying release: timeout",
+		},
+		"invalid release": {
+			release:       "apiVersion: v1\nkind: ConfigMap\n",
+			expectedError: `unsupported release type "v1" "ConfigMap"`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			fSys := filesys.MakeFsInMemory()
+			require.NoError(t, fSys.WriteFile("/deploy/release.yaml", []byte(test.release)))
+
+			configFlags := genericclioptions.NewConfigFlags(false)
+			*configFlags.Namespace = test.namespaceFlag
+
+			var deployedPaths []string
+			var deployedFlags map[string]string
+			var hooks [][]string
+			var interpolated []string
+			buffer := new(bytes.Buffer)
+			o := &ApplyOptions{
+				releaseFile: "/deploy/release.yaml",
+				dryRun:      test.dryRun,
+				configFlags: configFlags,
+				deploy: func(_ context.Context, flags *genericclioptions.ConfigFlags, paths []string, flagValues map[string]string, _ io.Writer) error {
+					assert.Equal(t, "production", *flags.Namespace)
+					deployedPaths = paths
+					deployedFlags = flagValues
+					return test.deployErr
+				},
+				interpolate: func(_ context.Context, path string, prefixes []string, outputPath string) error {
+					assert.Equal(t, []string{"MLP_"}, prefixes)
+					interpolated = append(interpolated, path)
+					deployedPaths = append(deployedPaths, outputPath)
+					return nil
+				},
+				runHook: func(_ context.Context, dir string, _ io.Writer, command []string) error {
+					assert.Equal(t, "/deploy", dir)
+					hooks = append(hooks, command)
+					return test.hookErr
+				},
+				fSys:   fSys,
+				writer: buffer,
+			}
+
+			if test.expectedInterpolated > 0 {
+				o.deploy = func(_ context.Context, _ *genericclioptions.ConfigFlags, paths []string, flagValues map[string]string, _ io.Writer) error {
+					assert.Equal(t, deployedPaths, paths)
+					assert.Equal(t, "0", filepath.Base(paths[0]))
+					assert.Equal(t, "1", filepath.Base(paths[1]))
+					deployedFlags = flagValues
+					return nil
+				}
+			}
+
+			err := o.Run(context.TODO())
+			assert.Equal(t, test.expectedHooks, hooks)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedFlags, deployedFlags)
+			assert.Equal(t, test.expectedOutput, buffer.String())
+			if test.expectedInterpolated > 0 {
+				assert.Equal(t, []string{"/deploy/manifests", "/deploy/shared"}, interpolated)
+				return
+			}
+			assert.Equal(t, test.expectedPaths, deployedPaths)
+		})
+	}
+}
+
+func TestSetFlags(t *testing.T) {
+	t.Parallel()
+
+	flags := &deploy.Flags{ConfigFlags: genericclioptions.NewConfigFlags(false)}
+	flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.AddFlags(flagSet)
+
+	release := &Release{Name: "api", Wait: Wait{}}
+	require.NoError(t, setFlags(flagSet, []string{"a,b", "c"}, release.deployFlagValues(true)))
+	filenames, err := flagSet.GetStringSlice(inputPathsFlagName)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a,b", "c"}, filenames)
+	releaseName, err := flagSet.GetString(releaseNameFlagName)
+	require.NoError(t, err)
+	assert.Equal(t, "api", releaseName)
+	dryRun, err := flagSet.GetBool(dryRunFlagName)
+	require.NoError(t, err)
+	assert.True(t, dryRun)
+
+	assert.ErrorContains(t, setFlags(flagSet, nil, map[string]string{"unknown": "value"}), `setting "unknown" flag`)
+}
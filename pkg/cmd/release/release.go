@@ -0,0 +1,211 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	cmdUsage = "release"
+	cmdShort = "Deploy the manifests described by a release file"
+	cmdLong  = `Deploy the manifests described by a release file.
+
+	A release file declares in a versionable document the sources of the manifests,
+	the env prefixes used for interpolating them, the target namespace, the deploy
+	type, the hooks to run around the deploy and the wait policies, replacing the
+	flags of the interpolate and deploy commands.
+	`
+
+	// APIVersion is the apiVersion of the release files supported by the command
+	APIVersion = "mlp.mia-platform.eu/v1"
+	// Kind is the kind of the release files
+	Kind = "Release"
+
+	stdinToken = "-"
+)
+
+var (
+	validDeployTypeValues = []string{extensions.DeployAll, extensions.DeploySmart}
+)
+
+// Release describes the deploy of a set of manifests
+type Release struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Name is the name of the release, the resources of releases with different names in the same namespace are
+	// saved in separate inventories
+	Name string `json:"name,omitempty"`
+	// Namespace is the namespace where the resources are deployed
+	Namespace string `json:"namespace,omitempty"`
+	// Sources contains the files and folders with the manifests, relative to the directory of the release file
+	Sources []string `json:"sources"`
+	// EnvPrefixes contains the prefixes of the environment variables interpolated in the sources, if empty the
+	// sources are deployed as they are
+	EnvPrefixes []string `json:"envPrefixes,omitempty"`
+	// DeployType is the deploy type used, deploy_all or smart_deploy
+	DeployType string `json:"deployType,omitempty"`
+	// EnsureNamespace create the namespace if it doesn't exist
+	EnsureNamespace *bool `json:"ensureNamespace,omitempty"`
+	Hooks           Hooks `json:"hooks,omitempty"`
+	Wait            Wait  `json:"wait,omitempty"`
+
+	// dir is the directory containing the release file
+	dir string
+}
+
+// Hooks contains the commands run before and after the deploy
+type Hooks struct {
+	PreApply  []Hook `json:"preApply,omitempty"`
+	PostApply []Hook `json:"postApply,omitempty"`
+}
+
+// Hook is a command run in the directory of the release file
+type Hook struct {
+	Name    string   `json:"name"`
+	Command []string `json:"command"`
+}
+
+// Wait contains the timeouts used while waiting for the deployed resources
+type Wait struct {
+	// Timeout is the maximum time of the whole deploy
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// HealthCheckTimeout is the maximum time waited for the resources to become ready
+	HealthCheckTimeout *metav1.Duration `json:"healthCheckTimeout,omitempty"`
+	// DependsOnTimeout is the maximum time waited for the dependencies of a resource
+	DependsOnTimeout *metav1.Duration `json:"dependsOnTimeout,omitempty"`
+	// PruneTimeout is the maximum time waited for the removal of the pruned resources
+	PruneTimeout *metav1.Duration `json:"pruneTimeout,omitempty"`
+}
+
+// NewCommand return the command grouping the operations on release files
+func NewCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   cmdUsage,
+		Short: heredoc.Doc(cmdShort),
+		Long:  heredoc.Doc(cmdLong),
+
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newApplyCommand(configFlags))
+	cmd.AddCommand(newValidateCommand())
+	return cmd
+}
+
+// Load read and validate the release file at path
+func Load(fSys filesys.FileSystem, path string) (*Release, error) {
+	data, err := fSys.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release file: %w", err)
+	}
+
+	release := new(Release)
+	if err := yaml.UnmarshalStrict(data, release); err != nil {
+		return nil, fmt.Errorf("failed to parse release file %q: %w", path, err)
+	}
+
+	if err := release.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid release file %q: %w", path, err)
+	}
+
+	release.dir = filepath.Dir(path)
+	return release, nil
+}
+
+// Validate check that the release contains all the required fields with valid values
+func (r *Release) Validate() error {
+	if r.APIVersion != APIVersion || r.Kind != Kind {
+		return fmt.Errorf("unsupported release type %q %q, must be %q %q", r.APIVersion, r.Kind, APIVersion, Kind)
+	}
+
+	if len(r.Name) > 0 {
+		if errs := validation.IsDNS1123Label(r.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid name %q: %s", r.Name, strings.Join(errs, ", "))
+		}
+	}
+
+	if len(r.Namespace) > 0 {
+		if errs := validation.IsDNS1123Label(r.Namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace %q: %s", r.Namespace, strings.Join(errs, ", "))
+		}
+	}
+
+	if len(r.Sources) == 0 {
+		return fmt.Errorf("at least one source must be specified")
+	}
+
+	for _, source := range r.Sources {
+		if len(source) == 0 || source == stdinToken {
+			return fmt.Errorf("invalid source %q, must be a file or folder path", source)
+		}
+	}
+
+	if len(r.DeployType) > 0 && !slices.Contains(validDeployTypeValues, r.DeployType) {
+		return fmt.Errorf("invalid deploy type value: %q", r.DeployType)
+	}
+
+	for _, hook := range slices.Concat(r.Hooks.PreApply, r.Hooks.PostApply) {
+		if len(hook.Name) == 0 {
+			return fmt.Errorf("every hook must have a name")
+		}
+		if len(hook.Command) == 0 {
+			return fmt.Errorf("hook %q must have a command", hook.Name)
+		}
+	}
+
+	timeouts := []struct {
+		name  string
+		value *metav1.Duration
+	}{
+		{name: "timeout", value: r.Wait.Timeout},
+		{name: "healthCheckTimeout", value: r.Wait.HealthCheckTimeout},
+		{name: "dependsOnTimeout", value: r.Wait.DependsOnTimeout},
+		{name: "pruneTimeout", value: r.Wait.PruneTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value != nil && timeout.value.Duration < 0 {
+			return fmt.Errorf("the wait %s cannot be negative", timeout.name)
+		}
+	}
+
+	return nil
+}
+
+// sourcePaths return the paths of the sources resolved from the directory of the release file
+func (r *Release) sourcePaths() []string {
+	paths := make([]string, 0, len(r.Sources))
+	for _, source := range r.Sources {
+		if filepath.IsAbs(source) {
+			paths = append(paths, source)
+			continue
+		}
+		paths = append(paths, filepath.Join(r.dir, source))
+	}
+	return paths
+}
@@ -0,0 +1,176 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	ensureNamespace := false
+	tests := map[string]struct {
+		content         string
+		expectedRelease *Release
+		expectedError   string
+	}{
+		"complete release": {
+			content: `apiVersion: mlp.mia-platform.eu/v1
+kind: Release
+name: api
+namespace: production
+sources:
+- manifests
+- /shared/manifests
+envPrefixes:
+- MLP_
+deployType: smart_deploy
+ensureNamespace: false
+hooks:
+  preApply:
+  - name: migrate
+    command: [./migrate.sh, up]
+wait:
+  timeout: 10m
+  pruneTimeout: 30s
+`,
+			expectedRelease: &Release{
+				TypeMeta:        metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+				Name:            "api",
+				Namespace:       "production",
+				Sources:         []string{"manifests", "/shared/manifests"},
+				EnvPrefixes:     []string{"MLP_"},
+				DeployType:      "smart_deploy",
+				EnsureNamespace: &ensureNamespace,
+				Hooks: Hooks{
+					PreApply: []Hook{{Name: "migrate", Command: []string{"./migrate.sh", "up"}}},
+				},
+				Wait: Wait{
+					Timeout:      &metav1.Duration{Duration: 10 * time.Minute},
+					PruneTimeout: &metav1.Duration{Duration: 30 * time.Second},
+				},
+				dir: "/deploy",
+			},
+		},
+		"unknown field": {
+			content: `apiVersion: mlp.mia-platform.eu/v1
+kind: Release
+sources: [manifests]
+source: other
+`,
+			expectedError: `failed to parse release file "/deploy/release.yaml"`,
+		},
+		"invalid release": {
+			content: `apiVersion: mlp.mia-platform.eu/v1
+kind: Release
+`,
+			expectedError: `invalid release file "/deploy/release.yaml": at least one source must be specified`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			fSys := filesys.MakeFsInMemory()
+			require.NoError(t, fSys.WriteFile("/deploy/release.yaml", []byte(test.content)))
+
+			release, err := Load(fSys, "/deploy/release.yaml")
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				assert.Nil(t, release)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedRelease, release)
+			assert.Equal(t, []string{"/deploy/manifests", "/shared/manifests"}, release.sourcePaths())
+		})
+	}
+
+	_, err := Load(filesys.MakeFsInMemory(), "missing.yaml")
+	assert.ErrorContains(t, err, "failed to read release file")
+}
+
+func TestValidateRelease(t *testing.T) {
+	t.Parallel()
+
+	typeMeta := metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind}
+	tests := map[string]struct {
+		release       *Release
+		expectedError string
+	}{
+		"valid release": {
+			release: &Release{TypeMeta: typeMeta, Name: "api", Namespace: "production", Sources: []string{"manifests"}, DeployType: "deploy_all"},
+		},
+		"wrong kind": {
+			release:       &Release{TypeMeta: metav1.TypeMeta{APIVersion: APIVersion, Kind: "Deploy"}, Sources: []string{"manifests"}},
+			expectedError: `unsupported release type "mlp.mia-platform.eu/v1" "Deploy"`,
+		},
+		"invalid name": {
+			release:       &Release{TypeMeta: typeMeta, Name: "API", Sources: []string{"manifests"}},
+			expectedError: `invalid name "API"`,
+		},
+		"invalid namespace": {
+			release:       &Release{TypeMeta: typeMeta, Namespace: "my_namespace", Sources: []string{"manifests"}},
+			expectedError: `invalid namespace "my_namespace"`,
+		},
+		"missing sources": {
+			release:       &Release{TypeMeta: typeMeta},
+			expectedError: "at least one source must be specified",
+		},
+		"stdin source": {
+			release:       &Release{TypeMeta: typeMeta, Sources: []string{stdinToken}},
+			expectedError: `invalid source "-", must be a file or folder path`,
+		},
+		"invalid deploy type": {
+			release:       &Release{TypeMeta: typeMeta, Sources: []string{"manifests"}, DeployType: "partial"},
+			expectedError: `invalid deploy type value: "partial"`,
+		},
+		"hook without name": {
+			release:       &Release{TypeMeta: typeMeta, Sources: []string{"manifests"}, Hooks: Hooks{PostApply: []Hook{{Command: []string{"true"}}}}},
+			expectedError: "every hook must have a name",
+		},
+		"hook without command": {
+			release:       &Release{TypeMeta: typeMeta, Sources: []string{"manifests"}, Hooks: Hooks{PreApply: []Hook{{Name: "migrate"}}}},
+			expectedError: `hook "migrate" must have a command`,
+		},
+		"negative timeout": {
+			release:       &Release{TypeMeta: typeMeta, Sources: []string{"manifests"}, Wait: Wait{HealthCheckTimeout: &metav1.Duration{Duration: -time.Second}}},
+			expectedError: "the wait healthCheckTimeout cannot be negative",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			err := test.release.Validate()
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	validateCmdUsage = "validate"
+	validateCmdShort = "Validate a release file without deploying it"
+	validateCmdLong  = `Validate a release file without deploying it.
+
+	The release file is parsed and checked for unknown fields, missing sources and
+	invalid values, without connecting to the cluster or reading the manifests.
+	`
+	validateCmdExamples = `# validate the release file in the deploy folder
+	mlp release validate -f deploy/release.yaml
+	`
+)
+
+// ValidateFlags contains all the flags for the `release validate` command. They will be converted to
+// ValidateOptions that contains all runtime options for the command.
+type ValidateFlags struct {
+	releaseFile string
+}
+
+// ValidateOptions have the data required to perform the release validate operation
+type ValidateOptions struct {
+	releaseFile string
+
+	fSys   filesys.FileSystem
+	writer io.Writer
+}
+
+// newValidateCommand return the command for validating a release file
+func newValidateCommand() *cobra.Command {
+	flags := &ValidateFlags{}
+
+	cmd := &cobra.Command{
+		Use:     validateCmdUsage,
+		Short:   heredoc.Doc(validateCmdShort),
+		Long:    heredoc.Doc(validateCmdLong),
+		Example: heredoc.Doc(validateCmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(cmd.OutOrStdout(), filesys.MakeFsOnDisk())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run())
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// AddFlags set the connection between ValidateFlags property to command line flags
+func (f *ValidateFlags) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&f.releaseFile, releaseFileFlagName, releaseFileShortName, releaseFileDefaultValue, releaseFileFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *ValidateFlags) ToOptions(writer io.Writer, fSys filesys.FileSystem) (*ValidateOptions, error) {
+	return &ValidateOptions{
+		releaseFile: f.releaseFile,
+		fSys:        fSys,
+		writer:      writer,
+	}, nil
+}
+
+// Validate will check that the options are consistent
+func (o *ValidateOptions) Validate() error {
+	if len(o.releaseFile) == 0 {
+		return fmt.Errorf("the %q flag cannot be empty", releaseFileFlagName)
+	}
+	return nil
+}
+
+// Run execute the release validate command
+func (o *ValidateOptions) Run() error {
+	if _, err := Load(o.fSys, o.releaseFile); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(o.writer, "release file %q is valid\n", o.releaseFile)
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestValidateCommand(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("release.yaml", []byte("apiVersion: mlp.mia-platform.eu/v1\nkind: Release\nsources: [manifests]\n")))
+	require.NoError(t, fSys.WriteFile("invalid.yaml", []byte("apiVersion: mlp.mia-platform.eu/v1\nkind: Release\n")))
+
+	buffer := new(bytes.Buffer)
+	o, err := (&ValidateFlags{releaseFile: "release.yaml"}).ToOptions(buffer, fSys)
+	require.NoError(t, err)
+	require.NoError(t, o.Validate())
+	require.NoError(t, o.Run())
+	assert.Equal(t, "release file \"release.yaml\" is valid\n", buffer.String())
+
+	o.releaseFile = "invalid.yaml"
+	assert.ErrorContains(t, o.Run(), "at least one source must be specified")
+
+	o.releaseFile = ""
+	assert.ErrorContains(t, o.Validate(), `the "filename" flag cannot be empty`)
+}
@@ -17,21 +17,44 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"os/signal"
 	"runtime"
+	"strings"
+	"syscall"
 
 	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/go-logr/logr"
 	"github.com/go-logr/stdr"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/certs"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/clustersnapshot"
 	"github.com/mia-platform/mlp/v2/pkg/cmd/deploy"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/env"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/export"
 	"github.com/mia-platform/mlp/v2/pkg/cmd/generate"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/graph"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/history"
 	"github.com/mia-platform/mlp/v2/pkg/cmd/hydrate"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/images"
 	"github.com/mia-platform/mlp/v2/pkg/cmd/interpolate"
 	"github.com/mia-platform/mlp/v2/pkg/cmd/kustomize"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/push"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/release"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/sanitize"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/secrets"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/selfupdate"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/snapshot"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/verify"
+	"github.com/mia-platform/mlp/v2/pkg/config"
+	"github.com/mia-platform/mlp/v2/pkg/update"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
 var (
@@ -39,6 +62,9 @@ var (
 	Version = "DEV"
 	// BuildDate is dynamically set at build time by the cli or overridden in the Makefile.
 	BuildDate = "" // YYYY-MM-DD
+	// SigningKey is the public key verifying the signature of the releases, dynamically set by the ci when
+	// building them; it is the base64 of the key in PKIX form.
+	SigningKey = ""
 )
 
 const (
@@ -55,13 +81,23 @@ const (
 	versionCmdShort = "Show mlp version"
 	versionCmdLong  = "Show mlp version"
 
+	checkUpdateFlagName     = "check-update"
+	checkUpdateDefaultValue = false
+	checkUpdateFlagUsage    = "if true check if a newer release of mlp is available and print its highlights"
+
+	updateHighlightsLimit = 5
+
 	verboseFlagName      = "verbose"
 	verboseFlagShortName = "v"
 	verboseUsage         = "setting logging verbosity; use number between 0 and 10"
+
+	projectConfigFlagName  = "project-config"
+	projectConfigFlagUsage = "path to the project configuration file, if not set a mlp.yaml file is searched from the current directory up to the repository root"
 )
 
 type Flags struct {
-	verbosity int
+	verbosity     int
+	projectConfig string
 }
 
 func NewRootCommand() *cobra.Command {
@@ -78,8 +114,9 @@ func NewRootCommand() *cobra.Command {
 
 		Args:              cobra.NoArgs,
 		ValidArgsFunction: cobra.NoFileCompletions,
-		PersistentPreRun: func(*cobra.Command, []string) {
+		PersistentPreRun: func(cmd *cobra.Command, _ []string) {
 			stdr.SetVerbosity(flags.verbosity)
+			cobra.CheckErr(flags.applyProjectConfig(cmd))
 		},
 	}
 
@@ -89,24 +126,85 @@ func NewRootCommand() *cobra.Command {
 	cmd.SetContext(logr.NewContext(context.Background(), logger))
 
 	cmd.AddCommand(
+		certs.NewCommand(genericclioptions.NewConfigFlags(true)),
+		clustersnapshot.NewCommand(genericclioptions.NewConfigFlags(true)),
 		deploy.NewCommand(genericclioptions.NewConfigFlags(true)),
+		env.NewCommand(genericclioptions.NewConfigFlags(true)),
+		export.NewCommand(genericclioptions.NewConfigFlags(true)),
 		generate.NewCommand(),
+		graph.NewCommand(),
+		history.NewCommand(genericclioptions.NewConfigFlags(true)),
 		hydrate.NewCommand(),
+		images.NewCommand(),
 		interpolate.NewCommand(),
 		kustomize.NewCommand(),
+		push.NewCommand(),
+		release.NewCommand(genericclioptions.NewConfigFlags(true)),
+		sanitize.NewCommand(),
+		secrets.NewCommand(genericclioptions.NewConfigFlags(true)),
+		selfupdate.NewCommand(Version, SigningKey),
+		snapshot.NewCommand(),
+		verify.NewCommand(genericclioptions.NewConfigFlags(true)),
 		versionCommand(),
 	)
 
 	return cmd
 }
 
+// NewSignalContext return a context that will be cancelled at the first SIGINT or SIGTERM received, allowing the
+// running command to stop gracefully; after the first signal the default behaviour is restored, so a second one will
+// terminate the process immediately
+func NewSignalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return ctx, stop
+}
+
 // AddFlags set the connection between Flags property to command line flags
 func (f *Flags) AddFlags(flags *pflag.FlagSet) {
 	flags.IntVarP(&f.verbosity, verboseFlagName, verboseFlagShortName, f.verbosity, verboseUsage)
+	flags.StringVar(&f.projectConfig, projectConfigFlagName, f.projectConfig, projectConfigFlagUsage)
+}
+
+// applyProjectConfig load the project configuration and set its defaults on the flags of cmd that are not set on
+// the command line, the path of the configuration is saved in the command context for the commands that read it
+func (f *Flags) applyProjectConfig(cmd *cobra.Command) error {
+	fSys := filesys.MakeFsOnDisk()
+	path := f.projectConfig
+	switch {
+	case len(path) == 0:
+		workingDir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		if path = config.Find(fSys, workingDir); len(path) == 0 {
+			return nil
+		}
+	case !fSys.Exists(path):
+		return fmt.Errorf("project configuration %q not found", path)
+	}
+
+	project, err := config.Load(fSys, path)
+	if err != nil {
+		return err
+	}
+
+	command := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	if err := project.ApplyDefaults(command, cmd.Flags()); err != nil {
+		return err
+	}
+
+	cmd.SetContext(config.NewContext(cmd.Context(), path))
+	return nil
 }
 
 // versionCommand return the command for printing the version string, like --version flag
 func versionCommand() *cobra.Command {
+	checkUpdate := checkUpdateDefaultValue
 	cmd := &cobra.Command{
 		Use: "version",
 
@@ -116,14 +214,49 @@ func versionCommand() *cobra.Command {
 		SilenceErrors:     true,
 		Args:              cobra.NoArgs,
 		ValidArgsFunction: cobra.NoFileCompletions,
-		Run: func(cobra *cobra.Command, _ []string) {
-			cobra.Println(versionString())
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmd.Println(versionString())
+			if checkUpdate {
+				client := update.NewClient(update.DefaultAPIURL, update.DefaultRepository)
+				cobra.CheckErr(printUpdateCheck(cmd.Context(), client, cmd.OutOrStdout(), Version))
+			}
 		},
 	}
 
+	cmd.Flags().BoolVar(&checkUpdate, checkUpdateFlagName, checkUpdateDefaultValue, checkUpdateFlagUsage)
 	return cmd
 }
 
+// printUpdateCheck write in writer if a release newer than current is available with its highlights
+func printUpdateCheck(ctx context.Context, client *update.Client, writer io.Writer, current string) error {
+	release, err := client.LatestRelease(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check for updates: %w", err)
+	}
+
+	comparison, err := update.Compare(current, release.Version)
+	switch {
+	case errors.Is(err, update.ErrDevelopmentVersion):
+		fmt.Fprintf(writer, "mlp is a development build, the latest release is %s\n", release.Version)
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to check for updates: %w", err)
+	case comparison <= 0:
+		fmt.Fprintln(writer, "mlp is up to date")
+		return nil
+	}
+
+	fmt.Fprintf(writer, "a new version of mlp is available: %s\n", release.Version)
+	for _, highlight := range update.Highlights(release.Notes, updateHighlightsLimit) {
+		fmt.Fprintf(writer, "  - %s\n", highlight)
+	}
+	if len(release.URL) > 0 {
+		fmt.Fprintf(writer, "release notes: %s\n", release.URL)
+	}
+	fmt.Fprintln(writer, "run `mlp self-update` for installing it")
+	return nil
+}
+
 // versionString format a complete version string to output to the user
 func versionString() string {
 	version := Version
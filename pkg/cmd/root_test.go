@@ -16,9 +16,21 @@
 package cmd
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/mia-platform/mlp/v2/pkg/config"
+	"github.com/mia-platform/mlp/v2/pkg/update"
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRootCommand(t *testing.T) {
@@ -35,3 +47,144 @@ func TestVersionCommand(t *testing.T) {
 	assert.NotNil(t, cmd)
 	assert.NoError(t, cmd.Execute())
 }
+
+func TestPrintUpdateCheck(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/mia-platform/mlp/releases/latest" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{
+	"tag_name": "v2.1.0",
+	"html_url": "https://github.com/mia-platform/mlp/releases/tag/v2.1.0",
+	"body": "## Added\n\n- first feature\n- second feature\n"
+}`))
+	}))
+	t.Cleanup(server.Close)
+
+	tests := map[string]struct {
+		current        string
+		repository     string
+		expectedOutput string
+		expectedError  string
+	}{
+		"new version available": {
+			current: "2.0.0",
+			expectedOutput: `a new version of mlp is available: v2.1.0
+  - first feature
+  - second feature
+release notes: https://github.com/mia-platform/mlp/releases/tag/v2.1.0
+run ` + "`mlp self-update`" + ` for installing it
+`,
+		},
+		"up to date": {
+			current:        "2.1.0",
+			expectedOutput: "mlp is up to date\n",
+		},
+		"development build": {
+			current:        "DEV",
+			expectedOutput: "mlp is a development build, the latest release is v2.1.0\n",
+		},
+		"release not found": {
+			current:       "2.0.0",
+			repository:    "mia-platform/missing",
+			expectedError: "failed to check for updates: failed to read release: unexpected status code 404",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			repository := update.DefaultRepository
+			if len(test.repository) > 0 {
+				repository = test.repository
+			}
+
+			writer := new(strings.Builder)
+			err := printUpdateCheck(context.TODO(), update.NewClient(server.URL, repository), writer, test.current)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedOutput, writer.String())
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestNewSignalContext(t *testing.T) {
+	ctx, stop := NewSignalContext(context.TODO())
+	defer stop()
+	require.NoError(t, ctx.Err())
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGTERM))
+
+	select {
+	case <-ctx.Done():
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "context not cancelled after receiving the signal")
+	}
+}
+
+func TestApplyProjectConfig(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "project.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`defaults:
+  child:
+    out: from-config
+`), 0600))
+
+	tests := map[string]struct {
+		projectConfig string
+		args          []string
+		expectedOut   string
+		expectedError string
+	}{
+		"defaults from project configuration": {
+			projectConfig: configPath,
+			expectedOut:   "from-config",
+		},
+		"command line flag take precedence": {
+			projectConfig: configPath,
+			args:          []string{"--out", "from-flag"},
+			expectedOut:   "from-flag",
+		},
+		"missing project configuration": {
+			projectConfig: filepath.Join(t.TempDir(), "missing.yaml"),
+			expectedError: "project configuration",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var out string
+			root := &cobra.Command{Use: "mlp"}
+			child := &cobra.Command{Use: "child"}
+			child.Flags().StringVar(&out, "out", "default", "")
+			root.AddCommand(child)
+			require.NoError(t, child.ParseFlags(test.args))
+			child.SetContext(context.TODO())
+
+			flags := &Flags{projectConfig: test.projectConfig}
+			err := flags.applyProjectConfig(child)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedOut, out)
+				assert.Equal(t, test.projectConfig, config.PathFromContext(child.Context()))
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
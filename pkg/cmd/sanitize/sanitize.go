@@ -0,0 +1,208 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sanitize
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/resourceutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	cmdUsage = "sanitize"
+	cmdShort = "Remove the fields populated by the server from the resources"
+	cmdLong  = `Remove the fields populated by the API server from resources exported from
+	a cluster, like the output of 'kubectl get -o yaml', so they can be used as
+	manifests for the deploy command.
+
+	The read-only fields are found using the OpenAPI schema of the Kubernetes
+	resources, and for all the resources the status, the managed fields, the
+	annotations added by kubectl and the controllers and the cluster IPs allocated
+	to the Services are removed. The resources contained in a List are written as
+	separate documents.
+	`
+	cmdExamples = `# sanitize the resources exported from a namespace
+	kubectl get deployments,services -o yaml > dump.yaml
+	mlp sanitize -f dump.yaml > manifests.yaml
+
+	# sanitize the resources read from stdin
+	kubectl get configmap example -o yaml | mlp sanitize -f -
+	`
+
+	inputPathsFlagName  = "filename"
+	inputPathsShortName = "f"
+	inputPathsFlagUsage = "file or folder paths containing the resources, use - for reading from stdin"
+
+	stdinToken        = "-"
+	documentSeparator = "---\n"
+)
+
+var yamlExtensions = []string{".yaml", ".yml", ".json"}
+
+// Flags contains all the flags for the `sanitize` command. They will be converted to Options
+// that contains all runtime options for the command.
+type Flags struct {
+	inputPaths []string
+}
+
+// Options have the data required to perform the sanitize operation
+type Options struct {
+	inputPaths []string
+
+	fSys   filesys.FileSystem
+	reader io.Reader
+	writer io.Writer
+}
+
+// NewCommand return the command for removing the fields populated by the server from a set of resources
+func NewCommand() *cobra.Command {
+	flags := &Flags{}
+
+	cmd := &cobra.Command{
+		Use:     cmdUsage,
+		Short:   heredoc.Doc(cmdShort),
+		Long:    heredoc.Doc(cmdLong),
+		Example: heredoc.Doc(cmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(cmd.InOrStdin(), cmd.OutOrStdout(), filesys.MakeFsOnDisk())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// AddFlags set the connection between Flags property to command line flags
+func (f *Flags) AddFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVarP(&f.inputPaths, inputPathsFlagName, inputPathsShortName, nil, inputPathsFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *Flags) ToOptions(reader io.Reader, writer io.Writer, fSys filesys.FileSystem) (*Options, error) {
+	return &Options{
+		inputPaths: f.inputPaths,
+		fSys:       fSys,
+		reader:     reader,
+		writer:     writer,
+	}, nil
+}
+
+// Validate check the options for errors
+func (o *Options) Validate() error {
+	if len(o.inputPaths) == 0 {
+		return fmt.Errorf("at least one path must be specified with the %q flag", inputPathsFlagName)
+	}
+
+	if len(o.inputPaths) > 1 && slices.Contains(o.inputPaths, stdinToken) {
+		return fmt.Errorf("cannot read from stdin and other paths together")
+	}
+
+	return nil
+}
+
+// Run execute the sanitize command
+func (o *Options) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	objects, err := o.readObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger.V(5).Info("sanitizing resources", "resources", len(objects))
+	for idx, obj := range objects {
+		Sanitize(obj)
+
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+
+		if idx > 0 {
+			if _, err := io.WriteString(o.writer, documentSeparator); err != nil {
+				return err
+			}
+		}
+		if _, err := o.writer.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readObjects return the objects to sanitize, read from stdin or from the input paths
+func (o *Options) readObjects(ctx context.Context) ([]*unstructured.Unstructured, error) {
+	if o.inputPaths[0] == stdinToken {
+		return decodeObjects(o.reader)
+	}
+
+	objects, err := resourceutil.DecodePaths(ctx, o.fSys, o.inputPaths, yamlExtensions)
+	if err != nil {
+		return nil, err
+	}
+
+	return expandLists(objects)
+}
+
+// decodeObjects return all the objects contained in the YAML or JSON stream in reader, the items of the
+// lists are returned as separate objects
+func decodeObjects(reader io.Reader) ([]*unstructured.Unstructured, error) {
+	objects, err := resourceutil.Decode(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return expandLists(objects)
+}
+
+// expandLists return objects replacing every list with its items
+func expandLists(objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	expanded := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, obj := range objects {
+		if !obj.IsList() {
+			expanded = append(expanded, obj)
+			continue
+		}
+
+		err := obj.EachListItem(func(item runtime.Object) error {
+			expanded = append(expanded, item.(*unstructured.Unstructured))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return expanded, nil
+}
@@ -0,0 +1,121 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sanitize
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewCommand()
+	assert.NotNil(t, cmd)
+	assert.NotNil(t, cmd.Flags().Lookup(inputPathsFlagName))
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	reader := new(bytes.Buffer)
+	writer := new(bytes.Buffer)
+	fSys := filesys.MakeFsInMemory()
+
+	flags := &Flags{
+		inputPaths: []string{"input"},
+	}
+	opts, err := flags.ToOptions(reader, writer, fSys)
+	require.NoError(t, err)
+	assert.Equal(t, &Options{
+		inputPaths: []string{"input"},
+		fSys:       fSys,
+		reader:     reader,
+		writer:     writer,
+	}, opts)
+	assert.NoError(t, opts.Validate())
+
+	opts.inputPaths = []string{"input", stdinToken}
+	assert.ErrorContains(t, opts.Validate(), "cannot read from stdin and other paths together")
+	opts.inputPaths = nil
+	assert.ErrorContains(t, opts.Validate(), `at least one path must be specified with the "filename" flag`)
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	testdata := "testdata"
+	stdinData, err := os.ReadFile(filepath.Join(testdata, "dump.yaml"))
+	require.NoError(t, err)
+	expectedDump, err := os.ReadFile(filepath.Join(testdata, "expected-dump.yaml"))
+	require.NoError(t, err)
+	expectedResources, err := os.ReadFile(filepath.Join(testdata, "expected-resources.yaml"))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		inputPaths     []string
+		expectedOutput string
+		expectedError  string
+	}{
+		"sanitize list": {
+			inputPaths:     []string{filepath.Join(testdata, "dump.yaml")},
+			expectedOutput: string(expectedDump),
+		},
+		"sanitize multiple documents": {
+			inputPaths:     []string{filepath.Join(testdata, "resources.yaml")},
+			expectedOutput: string(expectedResources),
+		},
+		"read from stdin": {
+			inputPaths:     []string{stdinToken},
+			expectedOutput: string(expectedDump),
+		},
+		"missing path": {
+			inputPaths:    []string{filepath.Join(testdata, "missing")},
+			expectedError: "no such file or directory",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			writer := new(strings.Builder)
+			opts := &Options{
+				inputPaths: test.inputPaths,
+				fSys:       filesys.MakeFsOnDisk(),
+				reader:     bytes.NewReader(stdinData),
+				writer:     writer,
+			}
+
+			err := opts.Run(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+				assert.Equal(t, test.expectedOutput, writer.String())
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sanitize
+
+import (
+	"regexp"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+var (
+	// readOnlyDescription match the sentences used by the Kubernetes OpenAPI for describing the fields
+	// that are populated by the API server and cannot be set by the users
+	readOnlyDescription = regexp.MustCompile(`(^|\s)Read-only\.|Populated by the system\.`)
+
+	// serverAnnotations contains the annotations added by the API server or by the controllers, and by kubectl
+	serverAnnotations = []string{
+		"kubectl.kubernetes.io/last-applied-configuration",
+		"deployment.kubernetes.io/revision",
+		"pv.kubernetes.io/bind-completed",
+		"pv.kubernetes.io/bound-by-controller",
+		"volume.beta.kubernetes.io/storage-provisioner",
+		"volume.kubernetes.io/storage-provisioner",
+		"volume.kubernetes.io/selected-node",
+	}
+
+	// jobControllerLabels contains the labels added by the Job controller on the pod template
+	jobControllerLabels = []string{"controller-uid", "batch.kubernetes.io/controller-uid"}
+
+	serviceGK = schema.GroupKind{Kind: "Service"}
+	jobGK     = schema.GroupKind{Group: "batch", Kind: "Job"}
+
+	// fallbackTypeMeta is used to find the schema of the metadata for the resources without a built-in schema
+	fallbackTypeMeta = yaml.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}
+)
+
+// Sanitize remove from obj the fields populated by the API server, so it can be applied again on a cluster.
+// The fields are found using the OpenAPI schema of the built-in resources, for the other resources only the
+// metadata is sanitized; the status, the managed fields, the annotations added by the controllers and the
+// allocated cluster IPs are always removed.
+func Sanitize(obj *unstructured.Unstructured) {
+	resourceSchema := openapi.SchemaForResourceType(yaml.TypeMeta{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind()})
+	switch {
+	case resourceSchema != nil:
+		removeReadOnlyFields(obj.Object, resourceSchema)
+	default:
+		if metadata, found := obj.Object["metadata"].(map[string]interface{}); found {
+			removeReadOnlyFields(metadata, openapi.SchemaForResourceType(fallbackTypeMeta).Field("metadata"))
+		}
+	}
+
+	unstructured.RemoveNestedField(obj.Object, "status")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+	removeKeys(obj.Object, serverAnnotations, "metadata", "annotations")
+
+	switch obj.GroupVersionKind().GroupKind() {
+	case serviceGK:
+		sanitizeService(obj)
+	case jobGK:
+		sanitizeJob(obj)
+	}
+}
+
+// removeReadOnlyFields walk value following its schema and remove every field described as read-only
+func removeReadOnlyFields(value interface{}, resourceSchema *openapi.ResourceSchema) {
+	if resourceSchema == nil || resourceSchema.Schema == nil {
+		return
+	}
+
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, fieldValue := range typedValue {
+			if property, found := resourceSchema.Schema.Properties[key]; found && readOnlyDescription.MatchString(property.Description) {
+				delete(typedValue, key)
+				continue
+			}
+			removeReadOnlyFields(fieldValue, resourceSchema.Field(key))
+		}
+	case []interface{}:
+		if !slices.Equal(resourceSchema.Schema.Type, []string{"array"}) {
+			return
+		}
+		elementsSchema := resourceSchema.Elements()
+		for _, element := range typedValue {
+			removeReadOnlyFields(element, elementsSchema)
+		}
+	}
+}
+
+// sanitizeService remove the cluster IPs allocated by the API server, keeping the headless services
+func sanitizeService(obj *unstructured.Unstructured) {
+	clusterIP, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP")
+	if clusterIP == "None" {
+		return
+	}
+
+	unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+	unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+}
+
+// sanitizeJob remove the selector and the labels generated by the Job controller, unless the selector has been
+// set manually
+func sanitizeJob(obj *unstructured.Unstructured) {
+	if manualSelector, _, _ := unstructured.NestedBool(obj.Object, "spec", "manualSelector"); manualSelector {
+		return
+	}
+
+	unstructured.RemoveNestedField(obj.Object, "spec", "selector")
+	removeKeys(obj.Object, jobControllerLabels, "spec", "template", "metadata", "labels")
+}
+
+// removeKeys delete keys from the map found at fields, removing the map if it remains empty
+func removeKeys(object map[string]interface{}, keys []string, fields ...string) {
+	values, found, err := unstructured.NestedMap(object, fields...)
+	if err != nil || !found {
+		return
+	}
+
+	for _, key := range keys {
+		delete(values, key)
+	}
+
+	if len(values) == 0 {
+		unstructured.RemoveNestedField(object, fields...)
+		return
+	}
+	_ = unstructured.SetNestedMap(object, values, fields...)
+}
@@ -0,0 +1,143 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sanitize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSanitize(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		object   map[string]interface{}
+		expected map[string]interface{}
+	}{
+		"read-only fields in nested metadata": {
+			object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "StatefulSet",
+				"metadata": map[string]interface{}{
+					"name":              "example",
+					"uid":               "uid",
+					"selfLink":          "/apis/apps/v1/namespaces/default/statefulsets/example",
+					"deletionTimestamp": "2024-05-10T08:00:00Z",
+				},
+				"spec": map[string]interface{}{
+					"volumeClaimTemplates": []interface{}{
+						map[string]interface{}{
+							"metadata": map[string]interface{}{"name": "data", "creationTimestamp": nil},
+							"spec":     map[string]interface{}{"accessModes": []interface{}{"ReadWriteOnce"}},
+							"status":   map[string]interface{}{"phase": "Pending"},
+						},
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "StatefulSet",
+				"metadata":   map[string]interface{}{"name": "example"},
+				"spec": map[string]interface{}{
+					"volumeClaimTemplates": []interface{}{
+						map[string]interface{}{
+							"metadata": map[string]interface{}{"name": "data"},
+							"spec":     map[string]interface{}{"accessModes": []interface{}{"ReadWriteOnce"}},
+						},
+					},
+				},
+			},
+		},
+		"controller annotations are removed with the empty map": {
+			object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "PersistentVolumeClaim",
+				"metadata": map[string]interface{}{
+					"name": "data",
+					"annotations": map[string]interface{}{
+						"pv.kubernetes.io/bind-completed":          "yes",
+						"volume.kubernetes.io/storage-provisioner": "ebs.csi.aws.com",
+					},
+				},
+				"spec": map[string]interface{}{"volumeName": "pvc-1234"},
+			},
+			expected: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "PersistentVolumeClaim",
+				"metadata":   map[string]interface{}{"name": "data"},
+				"spec":       map[string]interface{}{"volumeName": "pvc-1234"},
+			},
+		},
+		"job with manual selector": {
+			object: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"name": "job"},
+				"spec": map[string]interface{}{
+					"manualSelector": true,
+					"selector":       map[string]interface{}{"matchLabels": map[string]interface{}{"controller-uid": "custom"}},
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{"labels": map[string]interface{}{"controller-uid": "custom"}},
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"name": "job"},
+				"spec": map[string]interface{}{
+					"manualSelector": true,
+					"selector":       map[string]interface{}{"matchLabels": map[string]interface{}{"controller-uid": "custom"}},
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{"labels": map[string]interface{}{"controller-uid": "custom"}},
+					},
+				},
+			},
+		},
+		"custom resource without schema": {
+			object: map[string]interface{}{
+				"apiVersion": "example.com/v1",
+				"kind":       "Widget",
+				"metadata": map[string]interface{}{
+					"name":            "widget",
+					"generation":      int64(2),
+					"resourceVersion": "42",
+					"managedFields":   []interface{}{map[string]interface{}{"manager": "kubectl"}},
+				},
+				"spec":   map[string]interface{}{"resourceVersion": "custom"},
+				"status": map[string]interface{}{"ready": true},
+			},
+			expected: map[string]interface{}{
+				"apiVersion": "example.com/v1",
+				"kind":       "Widget",
+				"metadata":   map[string]interface{}{"name": "widget"},
+				"spec":       map[string]interface{}{"resourceVersion": "custom"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			obj := &unstructured.Unstructured{Object: test.object}
+			Sanitize(obj)
+			assert.Equal(t, test.expected, obj.Object)
+		})
+	}
+}
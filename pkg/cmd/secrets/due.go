@@ -0,0 +1,300 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/jpl/pkg/util"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/generate"
+	"github.com/mia-platform/mlp/v2/pkg/resourceutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	dueCmdUsage = "due"
+	dueCmdShort = "Report the generated secrets that must be rotated"
+	dueCmdLong  = `Report the rotation dates of every Secret generated with a rotation
+	configuration, and fail if any of them is past its rotation date or will be
+	within the configured window.
+
+	The resources are read from the files passed with the --filename flag, or from
+	the namespace of the target cluster if no file is passed.
+	`
+	dueCmdExamples = `# check the rotation dates of the generated manifests
+	mlp secrets due --filename interpolated-files
+
+	# check the secrets in the production namespace, failing if any must be rotated in the next 7 days
+	mlp secrets due --namespace production --within-days 7
+	`
+
+	inputPathsFlagName  = "filename"
+	inputPathsShortName = "f"
+	inputPathsFlagUsage = "file or folder paths containing the resources to check, if not set the resources are read from the target namespace"
+
+	withinDaysFlagName     = "within-days"
+	withinDaysDefaultValue = 0
+	withinDaysFlagUsage    = "fail also if a secret must be rotated in less than this number of days"
+
+	stdinToken = "-"
+)
+
+var yamlExtensions = []string{".yaml", ".yml"}
+
+// DueFlags contains all the flags for the `secrets due` command. They will be converted to DueOptions
+// that contains all runtime options for the command.
+type DueFlags struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+	inputPaths  []string
+	withinDays  int
+}
+
+// DueOptions have the data required to perform the secrets due operation
+type DueOptions struct {
+	inputPaths []string
+	withinDays int
+
+	clientFactory util.ClientFactory
+	clock         clock.PassiveClock
+	fSys          filesys.FileSystem
+	reader        io.Reader
+	writer        io.Writer
+}
+
+// rotationReport contains the rotation information of a single generated secret
+type rotationReport struct {
+	name      string
+	interval  string
+	rotatedAt string
+	due       time.Time
+	daysLeft  int
+}
+
+// newDueCommand return the command for checking the rotation dates of generated secrets
+func newDueCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	flags := &DueFlags{
+		ConfigFlags: configFlags,
+	}
+
+	cmd := &cobra.Command{
+		Use:     dueCmdUsage,
+		Short:   heredoc.Doc(dueCmdShort),
+		Long:    heredoc.Doc(dueCmdLong),
+		Example: heredoc.Doc(dueCmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(cmd.InOrStdin(), cmd.OutOrStdout(), filesys.MakeFsOnDisk())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// AddFlags set the connection between DueFlags property to command line flags
+func (f *DueFlags) AddFlags(flags *pflag.FlagSet) {
+	if f.ConfigFlags != nil {
+		f.ConfigFlags.AddFlags(flags)
+	}
+
+	flags.StringSliceVarP(&f.inputPaths, inputPathsFlagName, inputPathsShortName, nil, inputPathsFlagUsage)
+	flags.IntVar(&f.withinDays, withinDaysFlagName, withinDaysDefaultValue, withinDaysFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *DueFlags) ToOptions(reader io.Reader, writer io.Writer, fSys filesys.FileSystem) (*DueOptions, error) {
+	if f.ConfigFlags == nil {
+		return nil, fmt.Errorf("config flags are required")
+	}
+
+	return &DueOptions{
+		inputPaths:    f.inputPaths,
+		withinDays:    f.withinDays,
+		clientFactory: util.NewFactory(f.ConfigFlags),
+		clock:         clock.RealClock{},
+		fSys:          fSys,
+		reader:        reader,
+		writer:        writer,
+	}, nil
+}
+
+// Validate check the options for errors
+func (o *DueOptions) Validate() error {
+	if len(o.inputPaths) > 1 && slices.Contains(o.inputPaths, stdinToken) {
+		return fmt.Errorf("cannot read from stdin and other paths together")
+	}
+
+	if o.withinDays < 0 {
+		return fmt.Errorf("the %q flag cannot be negative", withinDaysFlagName)
+	}
+
+	return nil
+}
+
+// Run execute the secrets due command
+func (o *DueOptions) Run(ctx context.Context) error {
+	secrets, err := o.readSecrets(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := o.clock.Now()
+	reports := make([]rotationReport, 0, len(secrets))
+	for _, secret := range secrets {
+		report, found, err := rotationReportForSecret(secret, now)
+		if err != nil {
+			return err
+		}
+		if found {
+			reports = append(reports, report)
+		}
+	}
+
+	if len(reports) == 0 {
+		fmt.Fprintln(o.writer, "no secrets with a rotation schedule found")
+		return nil
+	}
+
+	slices.SortStableFunc(reports, func(a, b rotationReport) int { return a.due.Compare(b.due) })
+	o.printReports(reports)
+
+	due := 0
+	for _, report := range reports {
+		if report.daysLeft < o.withinDays || !now.Before(report.due) {
+			due++
+		}
+	}
+	switch {
+	case due > 0 && o.withinDays > 0:
+		return fmt.Errorf("%d secrets must be rotated in less than %d days", due, o.withinDays)
+	case due > 0:
+		return fmt.Errorf("%d secrets are past their rotation date", due)
+	}
+
+	return nil
+}
+
+// rotationReportForSecret return the rotation report for secret if it has been generated with a rotation schedule
+func rotationReportForSecret(secret metav1.Object, now time.Time) (rotationReport, bool, error) {
+	annotations := secret.GetAnnotations()
+	dueValue, found := annotations[generate.RotationDueAnnotation]
+	if !found {
+		return rotationReport{}, false, nil
+	}
+
+	due, err := time.Parse(time.RFC3339, dueValue)
+	if err != nil {
+		return rotationReport{}, false, fmt.Errorf("invalid %s annotation on secret %q: %w", generate.RotationDueAnnotation, secret.GetName(), err)
+	}
+
+	return rotationReport{
+		name:      secret.GetName(),
+		interval:  annotations[generate.RotationIntervalAnnotation],
+		rotatedAt: annotations[generate.RotatedAtAnnotation],
+		due:       due,
+		daysLeft:  int(math.Floor(due.Sub(now).Hours() / 24)),
+	}, true, nil
+}
+
+// readSecrets return the Secrets found in the input paths, or in the target namespace if no path is set
+func (o *DueOptions) readSecrets(ctx context.Context) ([]metav1.Object, error) {
+	if len(o.inputPaths) == 0 {
+		return o.readRemoteSecrets(ctx)
+	}
+
+	objects, err := o.readObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]metav1.Object, 0, len(objects))
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		if gvk.Group != corev1.GroupName || gvk.Kind != "Secret" {
+			continue
+		}
+		secrets = append(secrets, obj)
+	}
+
+	return secrets, nil
+}
+
+// readRemoteSecrets return the Secrets found in the target namespace
+func (o *DueOptions) readRemoteSecrets(ctx context.Context) ([]metav1.Object, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	namespace, _, err := o.clientFactory.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	clientSet, err := o.clientFactory.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+
+	logger.V(5).Info("reading secrets from cluster", "namespace", namespace)
+	list, err := clientSet.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	secrets := make([]metav1.Object, 0, len(list.Items))
+	for idx := range list.Items {
+		secrets = append(secrets, &list.Items[idx])
+	}
+	return secrets, nil
+}
+
+// readObjects return the resources whose secrets are checked, read from stdin or from the input paths
+func (o *DueOptions) readObjects(ctx context.Context) ([]*unstructured.Unstructured, error) {
+	if o.inputPaths[0] == stdinToken {
+		return resourceutil.Decode(o.reader)
+	}
+
+	return resourceutil.DecodePaths(ctx, o.fSys, o.inputPaths, yamlExtensions)
+}
+
+// printReports write a table with a row for every report
+func (o *DueOptions) printReports(reports []rotationReport) {
+	tabWriter := tabwriter.NewWriter(o.writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, "SECRET\tINTERVAL\tROTATED AT\tROTATION DUE\tDAYS LEFT")
+	for _, report := range reports {
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%d\n", report.name, report.interval, report.rotatedAt,
+			report.due.UTC().Format(time.RFC3339), report.daysLeft)
+	}
+	_ = tabWriter.Flush()
+}
@@ -0,0 +1,241 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/mia-platform/jpl/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+	restfake "k8s.io/client-go/rest/fake"
+	"k8s.io/utils/clock"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	expectedReport = `SECRET                INTERVAL  ROTATED AT            ROTATION DUE          DAYS LEFT
+database-credentials  90d       2024-10-01T00:00:00Z  2024-12-30T00:00:00Z  -2
+api-token             4w        2024-12-20T00:00:00Z  2025-01-17T00:00:00Z  16
+`
+	expectedRemoteReport = `SECRET     INTERVAL  ROTATED AT            ROTATION DUE          DAYS LEFT
+api-token  4w        2024-12-20T00:00:00Z  2025-01-17T00:00:00Z  16
+`
+)
+
+func TestDueOptions(t *testing.T) {
+	t.Parallel()
+
+	reader := new(bytes.Buffer)
+	writer := new(bytes.Buffer)
+	fSys := filesys.MakeFsInMemory()
+	configFlags := genericclioptions.NewConfigFlags(false)
+
+	flags := &DueFlags{
+		inputPaths: []string{"input"},
+		withinDays: 10,
+	}
+	_, err := flags.ToOptions(reader, writer, fSys)
+	assert.ErrorContains(t, err, "config flags are required")
+
+	flags.ConfigFlags = configFlags
+	opts, err := flags.ToOptions(reader, writer, fSys)
+	require.NoError(t, err)
+	assert.Equal(t, &DueOptions{
+		inputPaths:    []string{"input"},
+		withinDays:    10,
+		clientFactory: util.NewFactory(configFlags),
+		clock:         clock.RealClock{},
+		fSys:          fSys,
+		reader:        reader,
+		writer:        writer,
+	}, opts)
+	assert.NoError(t, opts.Validate())
+
+	opts.inputPaths = []string{"input", stdinToken}
+	assert.ErrorContains(t, opts.Validate(), "cannot read from stdin and other paths together")
+	opts.inputPaths = nil
+	assert.NoError(t, opts.Validate())
+
+	opts.withinDays = -1
+	assert.ErrorContains(t, opts.Validate(), `the "within-days" flag cannot be negative`)
+}
+
+func TestDueRunFromFiles(t *testing.T) {
+	t.Parallel()
+
+	testdata := "testdata"
+	fakeClock := clocktesting.NewFakePassiveClock(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC))
+	stdinData, err := os.ReadFile(filepath.Join(testdata, "resources", "secrets.yaml"))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		inputPaths     []string
+		withinDays     int
+		expectedOutput string
+		expectedError  string
+	}{
+		"secrets past their rotation date": {
+			inputPaths:     []string{filepath.Join(testdata, "resources")},
+			expectedOutput: expectedReport,
+			expectedError:  "1 secrets are past their rotation date",
+		},
+		"secrets due in the window": {
+			inputPaths:     []string{filepath.Join(testdata, "resources")},
+			withinDays:     30,
+			expectedOutput: expectedReport,
+			expectedError:  "2 secrets must be rotated in less than 30 days",
+		},
+		"read from stdin": {
+			inputPaths:     []string{stdinToken},
+			expectedOutput: expectedReport,
+			expectedError:  "1 secrets are past their rotation date",
+		},
+		"no secrets with rotation": {
+			inputPaths:     []string{filepath.Join(testdata, "no-rotation")},
+			expectedOutput: "no secrets with a rotation schedule found\n",
+		},
+		"invalid rotation due annotation": {
+			inputPaths:    []string{filepath.Join(testdata, "invalid.yaml")},
+			expectedError: `invalid mia-platform.eu/rotation-due annotation on secret "broken"`,
+		},
+		"missing path": {
+			inputPaths:    []string{filepath.Join(testdata, "missing")},
+			expectedError: "no such file or directory",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			writer := new(strings.Builder)
+			opts := &DueOptions{
+				inputPaths: test.inputPaths,
+				withinDays: test.withinDays,
+				clock:      fakeClock,
+				fSys:       filesys.MakeFsOnDisk(),
+				reader:     bytes.NewReader(stdinData),
+				writer:     writer,
+			}
+
+			err := opts.Run(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+			if len(test.expectedOutput) > 0 {
+				assert.Equal(t, test.expectedOutput, writer.String())
+			}
+		})
+	}
+}
+
+func TestDueRunFromCluster(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-secrets-test"
+	codec := jpltesting.Codecs.LegacyCodec(jpltesting.Scheme.PrioritizedVersionsAllGroups()...)
+	secrets := []corev1.Secret{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "api-token",
+				Namespace: namespace,
+				Annotations: map[string]string{
+					"mia-platform.eu/rotation-interval": "4w",
+					"mia-platform.eu/rotated-at":        "2024-12-20T00:00:00Z",
+					"mia-platform.eu/rotation-due":      "2025-01-17T00:00:00Z",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "plain-secret", Namespace: namespace},
+		},
+	}
+
+	tests := map[string]struct {
+		secretsStatus  int
+		expectedOutput string
+		expectedError  string
+	}{
+		"read secrets from namespace": {
+			secretsStatus:  http.StatusOK,
+			expectedOutput: expectedRemoteReport,
+		},
+		"error listing secrets": {
+			secretsStatus: http.StatusForbidden,
+			expectedError: "failed to list secrets",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tf := jpltesting.NewTestClientFactory().
+				WithNamespace(namespace)
+			tf.Client = &restfake.RESTClient{
+				NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+				Client: restfake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+					if r.URL.Path != fmt.Sprintf("/api/v1/namespaces/%s/secrets", namespace) {
+						return nil, fmt.Errorf("unexpected call: %q, method %s", r.URL.Path, r.Method)
+					}
+
+					body := []byte(runtime.EncodeOrDie(codec, &corev1.SecretList{Items: secrets}))
+					return &http.Response{
+						StatusCode: test.secretsStatus,
+						Header:     jpltesting.DefaultHeaders(),
+						Body:       io.NopCloser(bytes.NewReader(body)),
+					}, nil
+				}),
+			}
+
+			writer := new(strings.Builder)
+			opts := &DueOptions{
+				clientFactory: tf,
+				clock:         clocktesting.NewFakePassiveClock(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)),
+				writer:        writer,
+			}
+
+			err := opts.Run(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedOutput, writer.String())
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
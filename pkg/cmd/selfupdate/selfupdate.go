@@ -0,0 +1,213 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/update"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	cmdUsage = "self-update"
+	cmdShort = "Update mlp to the latest release"
+	cmdLong  = `Update mlp to the latest release.
+
+	The binary for the current operating system and architecture is downloaded
+	from the GitHub releases, verified against the checksums published with the
+	release, and then replaces the running executable. The checksums file is
+	signed when the release is built, and its signature is verified with the
+	public key embedded in the official mlp binaries, so the binary is installed
+	only if it has been published by the mlp maintainers; a local build without
+	the key verifies only the integrity of the download. A specific version can
+	be installed, also for going back to a previous release, if it has been
+	published with a signature.
+	`
+	cmdExamples = `# update mlp to the latest release
+	mlp self-update
+
+	# install a specific version
+	mlp self-update --version v2.0.0
+	`
+
+	versionFlagName  = "version"
+	versionFlagUsage = "the release to install instead of the latest one"
+
+	forceFlagName     = "force"
+	forceDefaultValue = false
+	forceFlagUsage    = "if true install the release also if it is the current version or mlp is a development build"
+
+	defaultGoARM = "7"
+)
+
+// Flags contains all the flags for the `self-update` command. They will be converted to Options
+// that contains all runtime options for the command.
+type Flags struct {
+	version string
+	force   bool
+}
+
+// Options have the data required to perform the self-update operation
+type Options struct {
+	version        string
+	force          bool
+	currentVersion string
+	executable     string
+	binaryName     string
+	// verified is true when the signature of the release checksums is verified
+	verified bool
+
+	client *update.Client
+	writer io.Writer
+}
+
+// NewCommand return the command for replacing the running binary with a release, currentVersion is the version
+// of the running binary and signingKey the public key for verifying the releases, empty in local builds
+func NewCommand(currentVersion, signingKey string) *cobra.Command {
+	flags := &Flags{}
+
+	cmd := &cobra.Command{
+		Use:     cmdUsage,
+		Short:   heredoc.Doc(cmdShort),
+		Long:    heredoc.Doc(cmdLong),
+		Example: heredoc.Doc(cmdExamples),
+
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cobra.NoFileCompletions,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(currentVersion, signingKey, cmd.OutOrStdout())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// AddFlags set the connection between Flags property to command line flags
+func (f *Flags) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&f.version, versionFlagName, "", versionFlagUsage)
+	flags.BoolVar(&f.force, forceFlagName, forceDefaultValue, forceFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *Flags) ToOptions(currentVersion, signingKey string, writer io.Writer) (*Options, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the mlp executable: %w", err)
+	}
+
+	client := update.NewClient(update.DefaultAPIURL, update.DefaultRepository)
+	if len(signingKey) > 0 {
+		key, err := update.ParseSigningKey(signingKey)
+		if err != nil {
+			return nil, err
+		}
+		client = client.WithSigningKey(key)
+	}
+
+	return &Options{
+		version:        f.version,
+		force:          f.force,
+		currentVersion: currentVersion,
+		executable:     executable,
+		binaryName:     update.BinaryName(runtime.GOOS, runtime.GOARCH, goARM()),
+		verified:       len(signingKey) > 0,
+		client:         client,
+		writer:         writer,
+	}, nil
+}
+
+// Validate check the options for errors
+func (o *Options) Validate() error {
+	return nil
+}
+
+// Run execute the self-update command
+func (o *Options) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	var release *update.Release
+	var err error
+	if len(o.version) > 0 {
+		release, err = o.client.Release(ctx, o.version)
+	} else {
+		release, err = o.client.LatestRelease(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !o.force {
+		comparison, err := update.Compare(o.currentVersion, release.Version)
+		switch {
+		case errors.Is(err, update.ErrDevelopmentVersion):
+			return fmt.Errorf("mlp %s is a development build, use --%s for replacing it with %s", o.currentVersion, forceFlagName, release.Version)
+		case err != nil:
+			return err
+		case len(o.version) == 0 && comparison <= 0:
+			fmt.Fprintf(o.writer, "mlp %s is already the latest version\n", o.currentVersion)
+			return nil
+		case comparison == 0:
+			fmt.Fprintf(o.writer, "mlp %s is already installed\n", o.currentVersion)
+			return nil
+		}
+	}
+
+	if !o.verified {
+		fmt.Fprintf(o.writer, "warning: mlp %s has been built without the signing key of the releases, only the integrity of %s will be verified\n", o.currentVersion, release.Version)
+	}
+
+	logger.V(3).Info("downloading release", "version", release.Version, "binary", o.binaryName)
+	data, err := o.client.DownloadBinary(ctx, release, o.binaryName)
+	if err != nil {
+		return err
+	}
+
+	logger.V(5).Info("replacing executable", "path", o.executable)
+	if err := update.ReplaceExecutable(o.executable, data); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(o.writer, "mlp updated from %s to %s\n", o.currentVersion, release.Version)
+	return nil
+}
+
+// goARM return the arm version used for building the running binary
+func goARM() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "GOARM" && len(setting.Value) > 0 {
+				return setting.Value
+			}
+		}
+	}
+
+	return defaultGoARM
+}
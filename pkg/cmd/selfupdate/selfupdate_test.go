@@ -0,0 +1,239 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selfupdate
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mia-platform/mlp/v2/pkg/update"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBinaryName = "mlp-linux-amd64"
+	oldBinary      = "old binary"
+	newBinary      = "new binary"
+)
+
+// newReleaseServer return a server exposing the releases in versions, all with the same binary and checksums;
+// the checksums are signed with signingKey if it is not nil
+func newReleaseServer(t *testing.T, signingKey *ecdsa.PrivateKey, latest string, versions ...string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	sum := sha256.Sum256([]byte(newBinary))
+	checksums := hex.EncodeToString(sum[:]) + "  " + testBinaryName + "\n"
+	mux.HandleFunc("/download/"+testBinaryName, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(newBinary))
+	})
+	mux.HandleFunc("/download/checksums.txt", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(checksums))
+	})
+
+	assets := []update.Asset{
+		{Name: testBinaryName, URL: server.URL + "/download/" + testBinaryName},
+		{Name: "checksums.txt", URL: server.URL + "/download/checksums.txt"},
+	}
+	if signingKey != nil {
+		digest := sha256.Sum256([]byte(checksums))
+		signature, err := ecdsa.SignASN1(rand.Reader, signingKey, digest[:])
+		require.NoError(t, err)
+		mux.HandleFunc("/download/checksums.txt.sig", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString(signature)))
+		})
+		assets = append(assets, update.Asset{Name: "checksums.txt.sig", URL: server.URL + "/download/checksums.txt.sig"})
+	}
+
+	for _, version := range append(versions, latest) {
+		release := update.Release{
+			Version: version,
+			Assets:  assets,
+		}
+		writeRelease := func(w http.ResponseWriter, _ *http.Request) {
+			require.NoError(t, json.NewEncoder(w).Encode(release))
+		}
+		mux.HandleFunc("/repos/mia-platform/mlp/releases/tags/"+version, writeRelease)
+		if version == latest {
+			mux.HandleFunc("/repos/mia-platform/mlp/releases/latest", writeRelease)
+		}
+	}
+
+	return server
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&signingKey.PublicKey)
+	require.NoError(t, err)
+
+	writer := new(strings.Builder)
+	flags := &Flags{version: "v2.0.0", force: true}
+	opts, err := flags.ToOptions("2.1.0", "", writer)
+	require.NoError(t, err)
+	assert.False(t, opts.verified)
+
+	_, err = flags.ToOptions("2.1.0", "invalid", writer)
+	assert.ErrorContains(t, err, "invalid signing key")
+
+	opts, err = flags.ToOptions("2.1.0", base64.StdEncoding.EncodeToString(publicKey), writer)
+	require.NoError(t, err)
+	assert.True(t, opts.verified)
+
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	assert.Equal(t, "v2.0.0", opts.version)
+	assert.True(t, opts.force)
+	assert.Equal(t, "2.1.0", opts.currentVersion)
+	assert.Equal(t, executable, opts.executable)
+	assert.True(t, strings.HasPrefix(opts.binaryName, "mlp-"))
+	assert.NotNil(t, opts.client)
+	assert.NoError(t, opts.Validate())
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		currentVersion  string
+		version         string
+		force           bool
+		withoutKey      bool
+		unsignedRelease bool
+		expectedOutput  string
+		expectedError   string
+		expectedBinary  string
+	}{
+		"update to latest": {
+			currentVersion: "2.0.0",
+			expectedOutput: "mlp updated from 2.0.0 to v2.1.0\n",
+			expectedBinary: newBinary,
+		},
+		"already the latest": {
+			currentVersion: "2.1.0",
+			expectedOutput: "mlp 2.1.0 is already the latest version\n",
+			expectedBinary: oldBinary,
+		},
+		"install specific older version": {
+			currentVersion: "2.1.0",
+			version:        "2.0.0",
+			expectedOutput: "mlp updated from 2.1.0 to v2.0.0\n",
+			expectedBinary: newBinary,
+		},
+		"specific version already installed": {
+			currentVersion: "2.0.0",
+			version:        "v2.0.0",
+			expectedOutput: "mlp 2.0.0 is already installed\n",
+			expectedBinary: oldBinary,
+		},
+		"development build": {
+			currentVersion: "DEV",
+			expectedError:  "mlp DEV is a development build, use --force for replacing it with v2.1.0",
+			expectedBinary: oldBinary,
+		},
+		"force development build": {
+			currentVersion: "DEV",
+			force:          true,
+			expectedOutput: "mlp updated from DEV to v2.1.0\n",
+			expectedBinary: newBinary,
+		},
+		"release without signature": {
+			currentVersion:  "2.0.0",
+			unsignedRelease: true,
+			expectedError:   "release v2.1.0 has no checksums.txt.sig file for verifying the checksums",
+			expectedBinary:  oldBinary,
+		},
+		"local build without signing key": {
+			currentVersion:  "2.0.0",
+			withoutKey:      true,
+			unsignedRelease: true,
+			expectedOutput:  "warning: mlp 2.0.0 has been built without the signing key of the releases, only the integrity of v2.1.0 will be verified\nmlp updated from 2.0.0 to v2.1.0\n",
+			expectedBinary:  newBinary,
+		},
+		"missing version": {
+			currentVersion: "2.0.0",
+			version:        "v1.0.0",
+			expectedError:  "failed to read release: unexpected status code 404",
+			expectedBinary: oldBinary,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			serverKey := signingKey
+			if test.unsignedRelease {
+				serverKey = nil
+			}
+			server := newReleaseServer(t, serverKey, "v2.1.0", "v2.0.0")
+			client := update.NewClient(server.URL, update.DefaultRepository)
+			if !test.withoutKey {
+				client = client.WithSigningKey(&signingKey.PublicKey)
+			}
+			executable := filepath.Join(t.TempDir(), "mlp")
+			require.NoError(t, os.WriteFile(executable, []byte(oldBinary), 0o755))
+
+			writer := new(strings.Builder)
+			opts := &Options{
+				version:        test.version,
+				force:          test.force,
+				currentVersion: test.currentVersion,
+				executable:     executable,
+				binaryName:     testBinaryName,
+				verified:       !test.withoutKey,
+				client:         client,
+				writer:         writer,
+			}
+
+			err := opts.Run(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedOutput, writer.String())
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+
+			data, err := os.ReadFile(executable)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedBinary, string(data))
+		})
+	}
+}
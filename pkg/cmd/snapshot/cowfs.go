@@ -0,0 +1,136 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// errReadOnly is returned by the operations of copyOnWriteFileSystem that are not supported
+var errReadOnly = errors.New("operation not supported while rendering a snapshot")
+
+// copyOnWriteFileSystem read the files from the underlying filesystem keeping in memory the files written on it,
+// so the hydration of the kustomization files can be rendered without modifying the original ones
+type copyOnWriteFileSystem struct {
+	filesys.FileSystem
+	written map[string][]byte
+}
+
+func newCopyOnWriteFileSystem(fSys filesys.FileSystem) *copyOnWriteFileSystem {
+	return &copyOnWriteFileSystem{
+		FileSystem: fSys,
+		written:    make(map[string][]byte),
+	}
+}
+
+// keep it to always check if copyOnWriteFileSystem implement correctly the filesys.FileSystem interface
+var _ filesys.FileSystem = &copyOnWriteFileSystem{}
+
+// Create implement filesys.FileSystem interface
+func (fs *copyOnWriteFileSystem) Create(string) (filesys.File, error) {
+	return nil, errReadOnly
+}
+
+// Mkdir implement filesys.FileSystem interface
+func (fs *copyOnWriteFileSystem) Mkdir(string) error {
+	return errReadOnly
+}
+
+// MkdirAll implement filesys.FileSystem interface
+func (fs *copyOnWriteFileSystem) MkdirAll(string) error {
+	return errReadOnly
+}
+
+// RemoveAll implement filesys.FileSystem interface
+func (fs *copyOnWriteFileSystem) RemoveAll(string) error {
+	return errReadOnly
+}
+
+// Open implement filesys.FileSystem interface
+func (fs *copyOnWriteFileSystem) Open(path string) (filesys.File, error) {
+	key := fs.key(path)
+	data, found := fs.written[key]
+	if !found {
+		return fs.FileSystem.Open(path)
+	}
+
+	memFs := filesys.MakeFsInMemory()
+	if err := memFs.WriteFile(key, data); err != nil {
+		return nil, err
+	}
+	return memFs.Open(key)
+}
+
+// Exists implement filesys.FileSystem interface
+func (fs *copyOnWriteFileSystem) Exists(path string) bool {
+	if _, found := fs.written[fs.key(path)]; found {
+		return true
+	}
+
+	return fs.FileSystem.Exists(path)
+}
+
+// ReadDir implement filesys.FileSystem interface
+func (fs *copyOnWriteFileSystem) ReadDir(path string) ([]string, error) {
+	names, err := fs.FileSystem.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := fs.key(path)
+	for writtenPath := range fs.written {
+		if name := filepath.Base(writtenPath); filepath.Dir(writtenPath) == dir && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	return names, nil
+}
+
+// ReadFile implement filesys.FileSystem interface
+func (fs *copyOnWriteFileSystem) ReadFile(path string) ([]byte, error) {
+	if data, found := fs.written[fs.key(path)]; found {
+		return bytes.Clone(data), nil
+	}
+
+	return fs.FileSystem.ReadFile(path)
+}
+
+// WriteFile implement filesys.FileSystem interface
+func (fs *copyOnWriteFileSystem) WriteFile(path string, data []byte) error {
+	if fs.FileSystem.IsDir(path) {
+		return fmt.Errorf("%q is a directory", path)
+	}
+
+	fs.written[fs.key(path)] = bytes.Clone(data)
+	return nil
+}
+
+// key return the absolute path used for keeping track of the written files
+func (fs *copyOnWriteFileSystem) key(path string) string {
+	if absPath, err := filepath.Abs(path); err == nil {
+		return absPath
+	}
+
+	return filepath.Clean(path)
+}
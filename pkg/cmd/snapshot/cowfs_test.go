@@ -0,0 +1,66 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestCopyOnWriteFileSystem(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	existingPath := filepath.Join(dir, "existing.yaml")
+	require.NoError(t, os.WriteFile(existingPath, []byte("original"), os.ModePerm))
+
+	fSys := newCopyOnWriteFileSystem(filesys.MakeFsOnDisk())
+	newPath := filepath.Join(dir, "new.yaml")
+	require.NoError(t, fSys.WriteFile(existingPath, []byte("changed")))
+	require.NoError(t, fSys.WriteFile(newPath, []byte("new")))
+	assert.Error(t, fSys.WriteFile(dir, []byte("folder")))
+
+	data, err := fSys.ReadFile(existingPath)
+	require.NoError(t, err)
+	assert.Equal(t, "changed", string(data))
+	assert.True(t, fSys.Exists(newPath))
+
+	file, err := fSys.Open(newPath)
+	require.NoError(t, err)
+	data, err = io.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	require.NoError(t, file.Close())
+
+	names, err := fSys.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"existing.yaml", "new.yaml"}, names)
+
+	assert.ErrorIs(t, fSys.MkdirAll(filepath.Join(dir, "folder")), errReadOnly)
+	assert.ErrorIs(t, fSys.RemoveAll(existingPath), errReadOnly)
+
+	// the files on disk are never modified
+	data, err = os.ReadFile(existingPath)
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(data))
+	assert.NoFileExists(t, newPath)
+}
@@ -0,0 +1,314 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/hydrate"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	cmdUsage = "snapshot DIR"
+	cmdShort = "Compare the rendered resources of a folder with a snapshot"
+	cmdLong  = `Compare the rendered resources of a folder with a snapshot.
+
+	The command will hydrate the kustomization file of DIR, and of the additional
+	folders passed with --hydrate, build it and save every resulting resource in
+	its own file. The files are compared with the ones inside the snapshot folder,
+	printing the differences and failing if any is found.
+
+	The original kustomization files are never modified; running the command with
+	--update will write the rendered resources in the snapshot folder instead.
+	`
+	cmdExamples = `# compare the production overlay with its snapshot
+	mlp snapshot overlays/production --snapshot-dir snapshots/production
+
+	# hydrate also the base folder and update the snapshot
+	mlp snapshot overlays/production --hydrate base --snapshot-dir snapshots/production --update
+	`
+
+	snapshotDirFlagName     = "snapshot-dir"
+	snapshotDirDefaultValue = "snapshot"
+	snapshotDirFlagUsage    = "folder containing the snapshot of the rendered resources"
+
+	hydrateFlagName  = "hydrate"
+	hydrateFlagUsage = "additional folders whose kustomization files are hydrated before building DIR"
+
+	updateFlagName     = "update"
+	updateDefaultValue = false
+	updateFlagUsage    = "if true write the rendered resources in the snapshot folder instead of comparing them"
+
+	snapshotFileExtension = ".yaml"
+)
+
+// Flags contains all the flags for the `snapshot` command. They will be converted to Options
+// that contains all runtime options for the command.
+type Flags struct {
+	snapshotDir  string
+	hydratePaths []string
+	update       bool
+}
+
+// Options have the data required to perform the snapshot operation
+type Options struct {
+	inputPath    string
+	snapshotDir  string
+	hydratePaths []string
+	update       bool
+
+	fSys   filesys.FileSystem
+	writer io.Writer
+}
+
+// NewCommand return the command for comparing the rendered resources of a folder with a snapshot
+func NewCommand() *cobra.Command {
+	flags := &Flags{}
+	cmd := &cobra.Command{
+		Use:     cmdUsage,
+		Short:   heredoc.Doc(cmdShort),
+		Long:    heredoc.Doc(cmdLong),
+		Example: heredoc.Doc(cmdExamples),
+
+		Args: cobra.ExactArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			o, err := flags.ToOptions(args, filesys.MakeFsOnDisk(), cmd.OutOrStdout())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// AddFlags set the connection between Flags property to command line flags
+func (f *Flags) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&f.snapshotDir, snapshotDirFlagName, snapshotDirDefaultValue, snapshotDirFlagUsage)
+	flags.StringSliceVar(&f.hydratePaths, hydrateFlagName, nil, hydrateFlagUsage)
+	flags.BoolVar(&f.update, updateFlagName, updateDefaultValue, updateFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *Flags) ToOptions(args []string, fSys filesys.FileSystem, writer io.Writer) (*Options, error) {
+	return &Options{
+		inputPath:    args[0],
+		snapshotDir:  f.snapshotDir,
+		hydratePaths: f.hydratePaths,
+		update:       f.update,
+		fSys:         fSys,
+		writer:       writer,
+	}, nil
+}
+
+// Validate will check that the options are consistent
+func (o *Options) Validate() error {
+	if len(o.snapshotDir) == 0 {
+		return fmt.Errorf("the %q flag cannot be empty", snapshotDirFlagName)
+	}
+
+	if !o.fSys.IsDir(o.inputPath) {
+		return fmt.Errorf("%q is not a folder", o.inputPath)
+	}
+
+	if o.fSys.Exists(o.snapshotDir) && !o.fSys.IsDir(o.snapshotDir) {
+		return fmt.Errorf("snapshot path %q is not a folder", o.snapshotDir)
+	}
+
+	return nil
+}
+
+// Run execute the snapshot command
+func (o *Options) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	rendered, err := o.render(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger.V(5).Info("reading snapshot", "path", o.snapshotDir)
+	snapshot, err := o.readSnapshot()
+	if err != nil {
+		return err
+	}
+
+	if o.update {
+		return o.updateSnapshot(rendered, snapshot)
+	}
+
+	changes := 0
+	names := append(slices.Collect(maps.Keys(rendered)), slices.Collect(maps.Keys(snapshot))...)
+	slices.Sort(names)
+	for _, name := range slices.Compact(names) {
+		diff, err := unifiedDiff(filepath.Join(o.snapshotDir, name), snapshot[name], rendered[name])
+		if err != nil {
+			return err
+		}
+		if len(diff) == 0 {
+			continue
+		}
+
+		changes++
+		fmt.Fprint(o.writer, diff)
+	}
+
+	if changes > 0 {
+		return fmt.Errorf("%d file(s) differ from the snapshot in %q, run the command with --%s for accepting the changes", changes, o.snapshotDir, updateFlagName)
+	}
+
+	fmt.Fprintf(o.writer, "%d resource(s) match the snapshot in %q\n", len(rendered), o.snapshotDir)
+	return nil
+}
+
+// render hydrate and build the input folder without modifying the kustomization files, and return the resulting
+// resources keyed by their snapshot file name
+func (o *Options) render(ctx context.Context) (map[string][]byte, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	fSys := newCopyOnWriteFileSystem(o.fSys)
+	paths := append(slices.Clone(o.hydratePaths), o.inputPath)
+	logger.V(5).Info("hydrating folders", "paths", strings.Join(paths, ", "))
+	if err := hydrate.NewOptions(paths, fSys).Run(ctx); err != nil {
+		return nil, err
+	}
+
+	logger.V(5).Info("building resources", "path", o.inputPath)
+	resourceMap, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, o.inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := make(map[string][]byte, resourceMap.Size())
+	for _, res := range resourceMap.Resources() {
+		name := snapshotFileName(res.GetNamespace(), res.GetKind(), res.GetName())
+		if _, found := rendered[name]; found {
+			return nil, fmt.Errorf("multiple resources are saved in the snapshot file %q", name)
+		}
+
+		data, err := res.AsYAML()
+		if err != nil {
+			return nil, err
+		}
+		rendered[name] = data
+	}
+
+	return rendered, nil
+}
+
+// readSnapshot return the content of the files found in the snapshot folder, a missing folder is an empty snapshot
+func (o *Options) readSnapshot() (map[string][]byte, error) {
+	snapshot := make(map[string][]byte)
+	if !o.fSys.Exists(o.snapshotDir) {
+		return snapshot, nil
+	}
+
+	names, err := o.fSys.ReadDir(o.snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		path := filepath.Join(o.snapshotDir, name)
+		if filepath.Ext(name) != snapshotFileExtension || o.fSys.IsDir(path) {
+			continue
+		}
+
+		if snapshot[name], err = o.fSys.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return snapshot, nil
+}
+
+// updateSnapshot write the rendered resources in the snapshot folder, removing the files of the resources that
+// are not rendered anymore
+func (o *Options) updateSnapshot(rendered, snapshot map[string][]byte) error {
+	if err := o.fSys.MkdirAll(o.snapshotDir); err != nil {
+		return err
+	}
+
+	for name := range snapshot {
+		if _, found := rendered[name]; found {
+			continue
+		}
+
+		if err := o.fSys.RemoveAll(filepath.Join(o.snapshotDir, name)); err != nil {
+			return err
+		}
+	}
+
+	for name, data := range rendered {
+		if err := o.fSys.WriteFile(filepath.Join(o.snapshotDir, name), data); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(o.writer, "snapshot in %q updated with %d resource(s)\n", o.snapshotDir, len(rendered))
+	return nil
+}
+
+// snapshotFileName return the name of the file containing a resource in the snapshot
+func snapshotFileName(namespace, kind, name string) string {
+	parts := []string{strings.ToLower(kind), name}
+	if len(namespace) > 0 {
+		parts = append([]string{namespace}, parts...)
+	}
+
+	return strings.Join(parts, "_") + snapshotFileExtension
+}
+
+// unifiedDiff return the differences between the snapshot file at path and the rendered resource, a nil content
+// means that the file is missing from the snapshot or the resource is not rendered anymore
+func unifiedDiff(path string, snapshot, rendered []byte) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(snapshot),
+		B:        splitLines(rendered),
+		FromFile: path,
+		ToFile:   path + " (rendered)",
+		Context:  3,
+	})
+}
+
+// splitLines return the lines of data keeping their line endings
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
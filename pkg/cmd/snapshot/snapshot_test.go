@@ -0,0 +1,199 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewCommand()
+	assert.NotNil(t, cmd)
+
+	cmd.SetArgs([]string{
+		filepath.Join("testdata", "app"),
+		"--snapshot-dir=" + filepath.Join("testdata", "snapshot"),
+	})
+	cmd.SetOut(new(strings.Builder))
+	assert.NoError(t, cmd.Execute())
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsOnDisk()
+	flags := &Flags{
+		snapshotDir: "snapshot",
+		update:      true,
+	}
+
+	writer := new(strings.Builder)
+	opts, err := flags.ToOptions([]string{filepath.Join("testdata", "app")}, fSys, writer)
+	require.NoError(t, err)
+	assert.Equal(t, &Options{
+		inputPath:   filepath.Join("testdata", "app"),
+		snapshotDir: "snapshot",
+		update:      true,
+		fSys:        fSys,
+		writer:      writer,
+	}, opts)
+	assert.NoError(t, opts.Validate())
+
+	opts.snapshotDir = ""
+	assert.ErrorContains(t, opts.Validate(), `the "snapshot-dir" flag cannot be empty`)
+
+	opts.snapshotDir = filepath.Join("testdata", "app", "kustomization.yaml")
+	assert.ErrorContains(t, opts.Validate(), "is not a folder")
+
+	opts.snapshotDir = "snapshot"
+	opts.inputPath = filepath.Join("testdata", "missing")
+	assert.ErrorContains(t, opts.Validate(), `"testdata/missing" is not a folder`)
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	inputPath := filepath.Join("testdata", "app")
+	kustomization, err := os.ReadFile(filepath.Join(inputPath, "kustomization.yaml"))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		snapshotFiles  map[string]string
+		update         bool
+		expectedOutput []string
+		expectedFiles  []string
+		expectedError  string
+	}{
+		"matching snapshot": {
+			expectedOutput: []string{`2 resource(s) match the snapshot in`},
+		},
+		"changed resource": {
+			snapshotFiles: map[string]string{
+				"default_configmap_example.yaml": "",
+				"deployment_example.yaml":        strings.Replace(readSnapshotFile(t, "deployment_example.yaml"), "replicas: 2", "replicas: 1", 1),
+			},
+			expectedOutput: []string{
+				"deployment_example.yaml (rendered)",
+				"-  replicas: 1\n+  replicas: 2\n",
+			},
+			expectedError: "1 file(s) differ from the snapshot",
+		},
+		"added and removed resources": {
+			snapshotFiles: map[string]string{
+				"default_configmap_example.yaml": "",
+				"service_example.yaml":           "kind: Service\n",
+			},
+			expectedOutput: []string{
+				"+kind: Deployment\n",
+				"-kind: Service\n",
+			},
+			expectedError: "2 file(s) differ from the snapshot",
+		},
+		"update snapshot": {
+			snapshotFiles: map[string]string{
+				"default_configmap_example.yaml": "",
+				"service_example.yaml":           "kind: Service\n",
+				"README.md":                      "# snapshot",
+			},
+			update:         true,
+			expectedOutput: []string{"updated with 2 resource(s)"},
+			expectedFiles:  []string{"README.md", "default_configmap_example.yaml", "deployment_example.yaml"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			snapshotDir := filepath.Join("testdata", "snapshot")
+			if test.snapshotFiles != nil {
+				snapshotDir = filepath.Join(t.TempDir(), "snapshot")
+				require.NoError(t, os.MkdirAll(snapshotDir, os.ModePerm))
+				for name, data := range test.snapshotFiles {
+					if len(data) == 0 {
+						data = readSnapshotFile(t, name)
+					}
+					require.NoError(t, os.WriteFile(filepath.Join(snapshotDir, name), []byte(data), os.ModePerm))
+				}
+			}
+
+			writer := new(strings.Builder)
+			opts := &Options{
+				inputPath:   inputPath,
+				snapshotDir: snapshotDir,
+				update:      test.update,
+				fSys:        filesys.MakeFsOnDisk(),
+				writer:      writer,
+			}
+
+			err := opts.Run(context.TODO())
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+
+			for _, expected := range test.expectedOutput {
+				assert.Contains(t, writer.String(), expected)
+			}
+
+			if test.expectedFiles != nil {
+				entries, err := os.ReadDir(snapshotDir)
+				require.NoError(t, err)
+				files := make([]string, 0, len(entries))
+				for _, entry := range entries {
+					files = append(files, entry.Name())
+				}
+				assert.Equal(t, test.expectedFiles, files)
+				assert.Equal(t, readSnapshotFile(t, "deployment_example.yaml"), readFile(t, filepath.Join(snapshotDir, "deployment_example.yaml")))
+			}
+
+			// the kustomization file is hydrated only in memory
+			assert.Equal(t, string(kustomization), readFile(t, filepath.Join(inputPath, "kustomization.yaml")))
+		})
+	}
+}
+
+func TestSnapshotFileName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "default_configmap_example.yaml", snapshotFileName("default", "ConfigMap", "example"))
+	assert.Equal(t, "clusterrole_example.yaml", snapshotFileName("", "ClusterRole", "example"))
+}
+
+func readSnapshotFile(t *testing.T, name string) string {
+	t.Helper()
+	return readFile(t, filepath.Join("testdata", "snapshot", name))
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,99 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+const (
+	providerKind     = "kind"
+	providerK3d      = "k3d"
+	providerExisting = "existing"
+)
+
+// commandRunner execute the program name with args, writing its output to writer
+type commandRunner func(ctx context.Context, writer io.Writer, name string, args ...string) error
+
+// execRunner is the commandRunner that execute the programs found in the PATH
+func execRunner(ctx context.Context, writer io.Writer, name string, args ...string) error {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("%s is required for creating the cluster: %w", name, err)
+	}
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s: %w", name, err)
+	}
+	return nil
+}
+
+// clusterProvider create and delete the throwaway clusters used for verifying the manifests
+type clusterProvider interface {
+	// Create start a new cluster called name, and save the configuration for connecting to it in kubeconfigPath
+	Create(ctx context.Context, name, kubeconfigPath string) error
+	// Delete remove the cluster called name
+	Delete(ctx context.Context, name string) error
+}
+
+// newClusterProvider return the clusterProvider for provider, or nil when an existing cluster is used
+func newClusterProvider(provider string, runner commandRunner, writer io.Writer) clusterProvider {
+	switch provider {
+	case providerKind:
+		return &kindProvider{runner: runner, writer: writer}
+	case providerK3d:
+		return &k3dProvider{runner: runner, writer: writer}
+	default:
+		return nil
+	}
+}
+
+// kindProvider create the clusters using kind
+type kindProvider struct {
+	runner commandRunner
+	writer io.Writer
+}
+
+func (p *kindProvider) Create(ctx context.Context, name, kubeconfigPath string) error {
+	return p.runner(ctx, p.writer, providerKind, "create", "cluster", "--name", name, "--kubeconfig", kubeconfigPath, "--wait", "5m")
+}
+
+func (p *kindProvider) Delete(ctx context.Context, name string) error {
+	return p.runner(ctx, p.writer, providerKind, "delete", "cluster", "--name", name)
+}
+
+// k3dProvider create the clusters using k3d
+type k3dProvider struct {
+	runner commandRunner
+	writer io.Writer
+}
+
+func (p *k3dProvider) Create(ctx context.Context, name, kubeconfigPath string) error {
+	if err := p.runner(ctx, p.writer, providerK3d, "cluster", "create", name, "--wait", "--kubeconfig-update-default=false", "--kubeconfig-switch-context=false"); err != nil {
+		return err
+	}
+	return p.runner(ctx, p.writer, providerK3d, "kubeconfig", "write", name, "--output", kubeconfigPath)
+}
+
+func (p *k3dProvider) Delete(ctx context.Context, name string) error {
+	return p.runner(ctx, p.writer, providerK3d, "cluster", "delete", name)
+}
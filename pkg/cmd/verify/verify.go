@@ -0,0 +1,289 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/cmd/deploy"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+const (
+	cmdUsage = "verify"
+	cmdShort = "Verify the manifests deploying them on a throwaway cluster"
+	cmdLong  = `Verify the manifests deploying them on a throwaway cluster.
+
+	A new local cluster is created with kind or k3d, the manifests are applied
+	waiting for all the resources to become ready, and the optional smoke test
+	Jobs are run after them waiting for their completion. At the end the cluster
+	is deleted, also when the verification fails, unless --keep-cluster is set.
+
+	With the existing provider no cluster is created and the manifests are
+	applied to the cluster configured in the kubeconfig, that is left untouched
+	at the end.
+	`
+	cmdExamples = `# verify the manifests rendered in the out folder on a kind cluster
+	mlp verify -f ./out
+
+	# verify the manifests on a k3d cluster running the smoke tests and keeping the cluster for inspection
+	mlp verify -f ./out --provider k3d --smoke-tests ./smoke-tests --keep-cluster
+	`
+
+	inputPathsFlagName  = "filename"
+	inputPathsShortName = "f"
+	inputPathsFlagUsage = "the files and/or folders that contain the manifests to verify"
+
+	providerFlagName     = "provider"
+	providerDefaultValue = providerKind
+	providerFlagUsage    = "how to get the cluster used for the verification, one of: kind, k3d, existing"
+
+	clusterNameFlagName     = "cluster-name"
+	clusterNameDefaultValue = "mlp-verify"
+	clusterNameFlagUsage    = "name of the cluster created for the verification"
+
+	keepClusterFlagName     = "keep-cluster"
+	keepClusterDefaultValue = false
+	keepClusterFlagUsage    = "if true the cluster created for the verification is not deleted at the end"
+
+	smokeTestsFlagName  = "smoke-tests"
+	smokeTestsFlagUsage = "the files and/or folders that contain the smoke test Jobs to run after the manifests are ready"
+
+	smokeTestsReleaseName     = "smoke-tests"
+	deployReleaseNameFlagName = "release-name"
+	stdinToken                = "-"
+)
+
+var (
+	validProviderValues = []string{providerKind, providerK3d, providerExisting}
+)
+
+// deployFunc apply the resources in paths to the cluster configured in configFlags, waiting for them to become ready
+type deployFunc func(ctx context.Context, configFlags *genericclioptions.ConfigFlags, paths []string, releaseName string, writer io.Writer) error
+
+// Flags contains all the flags for the `verify` command. They will be converted to Options
+// that contains all runtime options for the command.
+type Flags struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+	inputPaths  []string
+	provider    string
+	clusterName string
+	keepCluster bool
+	smokeTests  []string
+}
+
+// Options have the data required to perform the verify operation
+type Options struct {
+	inputPaths  []string
+	provider    string
+	clusterName string
+	keepCluster bool
+	smokeTests  []string
+
+	configFlags *genericclioptions.ConfigFlags
+	runner      commandRunner
+	deploy      deployFunc
+	writer      io.Writer
+}
+
+// NewCommand return the command for verifying the manifests on a throwaway cluster
+func NewCommand(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	flags := &Flags{
+		ConfigFlags: configFlags,
+	}
+
+	cmd := &cobra.Command{
+		Use:     cmdUsage,
+		Short:   heredoc.Doc(cmdShort),
+		Long:    heredoc.Doc(cmdLong),
+		Example: heredoc.Doc(cmdExamples),
+
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			o, err := flags.ToOptions(cmd.OutOrStderr())
+			cobra.CheckErr(err)
+			cobra.CheckErr(o.Validate())
+			cobra.CheckErr(o.Run(cmd.Context()))
+		},
+	}
+
+	flags.AddFlags(cmd.Flags())
+	if err := cmd.RegisterFlagCompletionFunc(providerFlagName, providerFlagCompletionfunc); err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+// AddFlags set the connection between Flags property to command line flags
+func (f *Flags) AddFlags(flags *pflag.FlagSet) {
+	if f.ConfigFlags != nil {
+		f.ConfigFlags.AddFlags(flags)
+	}
+
+	flags.StringSliceVarP(&f.inputPaths, inputPathsFlagName, inputPathsShortName, nil, inputPathsFlagUsage)
+	flags.StringVar(&f.provider, providerFlagName, providerDefaultValue, providerFlagUsage)
+	flags.StringVar(&f.clusterName, clusterNameFlagName, clusterNameDefaultValue, clusterNameFlagUsage)
+	flags.BoolVar(&f.keepCluster, keepClusterFlagName, keepClusterDefaultValue, keepClusterFlagUsage)
+	flags.StringSliceVar(&f.smokeTests, smokeTestsFlagName, nil, smokeTestsFlagUsage)
+}
+
+// ToOptions transform the command flags in command runtime arguments
+func (f *Flags) ToOptions(writer io.Writer) (*Options, error) {
+	if f.ConfigFlags == nil {
+		return nil, fmt.Errorf("config flags are required")
+	}
+
+	return &Options{
+		inputPaths:  f.inputPaths,
+		provider:    f.provider,
+		clusterName: f.clusterName,
+		keepCluster: f.keepCluster,
+		smokeTests:  f.smokeTests,
+
+		configFlags: f.ConfigFlags,
+		runner:      execRunner,
+		deploy:      runDeploy,
+		writer:      writer,
+	}, nil
+}
+
+// Validate will check that the options are consistent
+func (o *Options) Validate() error {
+	if len(o.inputPaths) == 0 {
+		return fmt.Errorf("at least one path must be specified with %q flag", inputPathsFlagName)
+	}
+
+	if slices.Contains(o.inputPaths, stdinToken) || slices.Contains(o.smokeTests, stdinToken) {
+		return fmt.Errorf("the manifests to verify cannot be read from stdin")
+	}
+
+	if !slices.Contains(validProviderValues, o.provider) {
+		return fmt.Errorf("invalid provider value: %q", o.provider)
+	}
+
+	if o.provider != providerExisting {
+		if errs := validation.IsDNS1123Label(o.clusterName); len(errs) > 0 {
+			return fmt.Errorf("invalid cluster name %q: %s", o.clusterName, strings.Join(errs, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Run execute the verify command
+func (o *Options) Run(ctx context.Context) (err error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	configFlags := o.configFlags
+	if provider := newClusterProvider(o.provider, o.runner, o.writer); provider != nil {
+		tmpDir, tmpErr := os.MkdirTemp("", "mlp-verify-")
+		if tmpErr != nil {
+			return tmpErr
+		}
+		defer os.RemoveAll(tmpDir)
+
+		kubeconfigPath := filepath.Join(tmpDir, "kubeconfig")
+		fmt.Fprintf(o.writer, "creating %s cluster %q\n", o.provider, o.clusterName)
+		defer func() {
+			err = errors.Join(err, o.teardown(ctx, provider))
+		}()
+		if err := provider.Create(ctx, o.clusterName, kubeconfigPath); err != nil {
+			return fmt.Errorf("creating cluster %q: %w", o.clusterName, err)
+		}
+
+		configFlags = clusterConfigFlags(o.configFlags, kubeconfigPath)
+	}
+
+	logger.V(3).Info("deploying manifests", "paths", strings.Join(o.inputPaths, ", "))
+	fmt.Fprintln(o.writer, "deploying manifests")
+	if err := o.deploy(ctx, configFlags, o.inputPaths, "", o.writer); err != nil {
+		return fmt.Errorf("deploying manifests: %w", err)
+	}
+
+	if len(o.smokeTests) > 0 {
+		logger.V(3).Info("running smoke tests", "paths", strings.Join(o.smokeTests, ", "))
+		fmt.Fprintln(o.writer, "running smoke tests")
+		if err := o.deploy(ctx, configFlags, o.smokeTests, smokeTestsReleaseName, o.writer); err != nil {
+			return fmt.Errorf("running smoke tests: %w", err)
+		}
+	}
+
+	fmt.Fprintln(o.writer, "manifests verified successfully")
+	return nil
+}
+
+// teardown delete the cluster created for the verification, unless it has to be kept; the deletion is done also
+// when the context is cancelled for not leaving the cluster running after an interrupt
+func (o *Options) teardown(ctx context.Context, provider clusterProvider) error {
+	if o.keepCluster {
+		fmt.Fprintf(o.writer, "keeping %s cluster %q\n", o.provider, o.clusterName)
+		return nil
+	}
+
+	fmt.Fprintf(o.writer, "deleting %s cluster %q\n", o.provider, o.clusterName)
+	if err := provider.Delete(context.WithoutCancel(ctx), o.clusterName); err != nil {
+		return fmt.Errorf("deleting cluster %q: %w", o.clusterName, err)
+	}
+	return nil
+}
+
+// clusterConfigFlags return the config flags for connecting to the cluster saved in kubeconfigPath, keeping the
+// namespace set via flag
+func clusterConfigFlags(configFlags *genericclioptions.ConfigFlags, kubeconfigPath string) *genericclioptions.ConfigFlags {
+	clusterFlags := genericclioptions.NewConfigFlags(true)
+	clusterFlags.KubeConfig = &kubeconfigPath
+	if configFlags.Namespace != nil {
+		namespace := *configFlags.Namespace
+		clusterFlags.Namespace = &namespace
+	}
+	return clusterFlags
+}
+
+// runDeploy is the deployFunc that use the deploy command with its default values, saving the resources in the
+// inventory of releaseName if set
+func runDeploy(ctx context.Context, configFlags *genericclioptions.ConfigFlags, paths []string, releaseName string, writer io.Writer) error {
+	flagValues := make(map[string]string)
+	if len(releaseName) > 0 {
+		flagValues[deployReleaseNameFlagName] = releaseName
+	}
+
+	o, err := deploy.NewOptions(ctx, configFlags, paths, flagValues, writer)
+	if err != nil {
+		return err
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	return o.Run(ctx)
+}
+
+func providerFlagCompletionfunc(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return validProviderValues, cobra.ShellCompDirectiveDefault
+}
@@ -0,0 +1,272 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	configFlags := genericclioptions.NewConfigFlags(false)
+	flags := &Flags{ConfigFlags: configFlags}
+	flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.AddFlags(flagSet)
+	require.NoError(t, flagSet.Parse([]string{"-f", "out", "--smoke-tests", "tests", "--provider", "k3d", "--keep-cluster"}))
+
+	opts, err := flags.ToOptions(io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"out"}, opts.inputPaths)
+	assert.Equal(t, []string{"tests"}, opts.smokeTests)
+	assert.Equal(t, providerK3d, opts.provider)
+	assert.Equal(t, clusterNameDefaultValue, opts.clusterName)
+	assert.True(t, opts.keepCluster)
+	assert.NoError(t, opts.Validate())
+
+	_, err = (&Flags{}).ToOptions(io.Discard)
+	assert.ErrorContains(t, err, "config flags are required")
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		options       *Options
+		expectedError string
+	}{
+		"valid options": {
+			options: &Options{inputPaths: []string{"out"}, provider: providerKind, clusterName: "mlp-verify"},
+		},
+		"missing input paths": {
+			options:       &Options{provider: providerKind, clusterName: "mlp-verify"},
+			expectedError: `at least one path must be specified with "filename" flag`,
+		},
+		"reading from stdin": {
+			options:       &Options{inputPaths: []string{stdinToken}, provider: providerKind, clusterName: "mlp-verify"},
+			expectedError: "the manifests to verify cannot be read from stdin",
+		},
+		"smoke tests from stdin": {
+			options:       &Options{inputPaths: []string{"out"}, smokeTests: []string{stdinToken}, provider: providerKind, clusterName: "mlp-verify"},
+			expectedError: "the manifests to verify cannot be read from stdin",
+		},
+		"invalid provider": {
+			options:       &Options{inputPaths: []string{"out"}, provider: "minikube", clusterName: "mlp-verify"},
+			expectedError: `invalid provider value: "minikube"`,
+		},
+		"invalid cluster name": {
+			options:       &Options{inputPaths: []string{"out"}, provider: providerK3d, clusterName: "Invalid_Name"},
+			expectedError: `invalid cluster name "Invalid_Name"`,
+		},
+		"cluster name ignored for existing cluster": {
+			options: &Options{inputPaths: []string{"out"}, provider: providerExisting},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := test.options.Validate()
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+type recorder struct {
+	calls       []string
+	failOn      string
+	kubeconfigs []string
+}
+
+func (r *recorder) runner(_ context.Context, _ io.Writer, name string, args ...string) error {
+	call := strings.Join(append([]string{name}, args...), " ")
+	if idx := strings.Index(call, "--kubeconfig "); idx >= 0 {
+		path := strings.Fields(call[idx:])[1]
+		r.kubeconfigs = append(r.kubeconfigs, path)
+		call = strings.Replace(call, path, "KUBECONFIG", 1)
+	}
+	if idx := strings.Index(call, "--output "); idx >= 0 {
+		path := strings.Fields(call[idx:])[1]
+		r.kubeconfigs = append(r.kubeconfigs, path)
+		call = strings.Replace(call, path, "KUBECONFIG", 1)
+	}
+	r.calls = append(r.calls, call)
+	if len(r.failOn) > 0 && strings.HasPrefix(call, r.failOn) {
+		return errors.New("command failed")
+	}
+	return nil
+}
+
+func (r *recorder) deploy(_ context.Context, configFlags *genericclioptions.ConfigFlags, paths []string, releaseName string, _ io.Writer) error {
+	call := "deploy " + strings.Join(paths, ",")
+	if len(releaseName) > 0 {
+		call += " release=" + releaseName
+	}
+	if configFlags.KubeConfig != nil && len(*configFlags.KubeConfig) > 0 {
+		call += " kubeconfig=" + filepath.Base(*configFlags.KubeConfig)
+	}
+	if configFlags.Namespace != nil && len(*configFlags.Namespace) > 0 {
+		call += " namespace=" + *configFlags.Namespace
+	}
+	r.calls = append(r.calls, call)
+	if len(r.failOn) > 0 && strings.HasPrefix(call, r.failOn) {
+		return errors.New("deploy failed")
+	}
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		provider      string
+		keepCluster   bool
+		smokeTests    []string
+		failOn        string
+		expectedCalls []string
+		expectedError string
+	}{
+		"kind cluster": {
+			provider: providerKind,
+			expectedCalls: []string{
+				"kind create cluster --name mlp-verify --kubeconfig KUBECONFIG --wait 5m",
+				"deploy out kubeconfig=kubeconfig namespace=app",
+				"kind delete cluster --name mlp-verify",
+			},
+		},
+		"k3d cluster with smoke tests": {
+			provider:   providerK3d,
+			smokeTests: []string{"tests"},
+			expectedCalls: []string{
+				"k3d cluster create mlp-verify --wait --kubeconfig-update-default=false --kubeconfig-switch-context=false",
+				"k3d kubeconfig write mlp-verify --output KUBECONFIG",
+				"deploy out kubeconfig=kubeconfig namespace=app",
+				"deploy tests release=smoke-tests kubeconfig=kubeconfig namespace=app",
+				"k3d cluster delete mlp-verify",
+			},
+		},
+		"keep cluster": {
+			provider:    providerKind,
+			keepCluster: true,
+			expectedCalls: []string{
+				"kind create cluster --name mlp-verify --kubeconfig KUBECONFIG --wait 5m",
+				"deploy out kubeconfig=kubeconfig namespace=app",
+			},
+		},
+		"existing cluster": {
+			provider:   providerExisting,
+			smokeTests: []string{"tests"},
+			expectedCalls: []string{
+				"deploy out namespace=app",
+				"deploy tests release=smoke-tests namespace=app",
+			},
+		},
+		"cluster deleted when deploy fails": {
+			provider:   providerKind,
+			smokeTests: []string{"tests"},
+			failOn:     "deploy out",
+			expectedCalls: []string{
+				"kind create cluster --name mlp-verify --kubeconfig KUBECONFIG --wait 5m",
+				"deploy out kubeconfig=kubeconfig namespace=app",
+				"kind delete cluster --name mlp-verify",
+			},
+			expectedError: "deploying manifests: deploy failed",
+		},
+		"cluster deleted when smoke tests fail": {
+			provider:   providerKind,
+			smokeTests: []string{"tests"},
+			failOn:     "deploy tests",
+			expectedCalls: []string{
+				"kind create cluster --name mlp-verify --kubeconfig KUBECONFIG --wait 5m",
+				"deploy out kubeconfig=kubeconfig namespace=app",
+				"deploy tests release=smoke-tests kubeconfig=kubeconfig namespace=app",
+				"kind delete cluster --name mlp-verify",
+			},
+			expectedError: "running smoke tests: deploy failed",
+		},
+		"cluster creation fails": {
+			provider: providerKind,
+			failOn:   "kind create",
+			expectedCalls: []string{
+				"kind create cluster --name mlp-verify --kubeconfig KUBECONFIG --wait 5m",
+				"kind delete cluster --name mlp-verify",
+			},
+			expectedError: `creating cluster "mlp-verify": command failed`,
+		},
+		"cluster deletion fails": {
+			provider: providerKind,
+			failOn:   "kind delete",
+			expectedCalls: []string{
+				"kind create cluster --name mlp-verify --kubeconfig KUBECONFIG --wait 5m",
+				"deploy out kubeconfig=kubeconfig namespace=app",
+				"kind delete cluster --name mlp-verify",
+			},
+			expectedError: `deleting cluster "mlp-verify": command failed`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			configFlags := genericclioptions.NewConfigFlags(false)
+			namespace := "app"
+			configFlags.Namespace = &namespace
+
+			recorder := &recorder{failOn: test.failOn}
+			buffer := new(bytes.Buffer)
+			opts := &Options{
+				inputPaths:  []string{"out"},
+				provider:    test.provider,
+				clusterName: clusterNameDefaultValue,
+				keepCluster: test.keepCluster,
+				smokeTests:  test.smokeTests,
+
+				configFlags: configFlags,
+				runner:      recorder.runner,
+				deploy:      recorder.deploy,
+				writer:      buffer,
+			}
+
+			err := opts.Run(context.TODO())
+			assert.Equal(t, test.expectedCalls, recorder.calls)
+			for _, path := range recorder.kubeconfigs {
+				assert.Equal(t, "kubeconfig", filepath.Base(path))
+			}
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, buffer.String(), "manifests verified successfully")
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config contains the project configuration that can be saved in a mlp.yaml file for sharing
+// settings between all the invocations of the commands
+package config
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultFileName is the name of the project configuration file searched in the working directory
+	DefaultFileName = "mlp.yaml"
+)
+
+// Project contains the configuration of a project
+type Project struct {
+	// Defaults contains for every command the default values of its flags, keyed by the flag name; the values set
+	// on the command line take precedence
+	Defaults map[string]map[string]interface{} `json:"defaults,omitempty"`
+	Deploy   Deploy                            `json:"deploy,omitempty"`
+	Hydrate  Hydrate                           `json:"hydrate,omitempty"`
+}
+
+// contextKey is used for saving the project configuration path inside a context
+type contextKey struct{}
+
+// Deploy contains the configuration for the deploy command
+type Deploy struct {
+	Readiness []extensions.ReadinessDefinition `json:"readiness,omitempty"`
+	Workloads []extensions.WorkloadDefinition  `json:"workloads,omitempty"`
+	// WorkloadDefaults contains the values set on every workload resource when they are missing from its manifest
+	WorkloadDefaults *extensions.WorkloadDefaults `json:"workloadDefaults,omitempty"`
+	// Env contains the environment variables added to every container, together with the standard ones, when the
+	// injection of the standard environment variables is enabled
+	Env map[string]string `json:"env,omitempty"`
+	// Profiles contains named sets of flag values, keyed by the flag name, that can be selected with a single flag;
+	// the values take precedence over the defaults, but not over the flags set on the command line
+	Profiles map[string]map[string]interface{} `json:"profiles,omitempty"`
+}
+
+// Hydrate contains the configuration for the hydrate command
+type Hydrate struct {
+	// Components contains the kustomize components to add to the hydrated kustomization files, keyed by the
+	// env prefix of the environment that enables them
+	Components map[string][]string `json:"components,omitempty"`
+}
+
+// ComponentsFor return the components enabled for envPrefixes, in order and without duplicates
+func (h Hydrate) ComponentsFor(envPrefixes []string) []string {
+	var components []string
+	for _, prefix := range envPrefixes {
+		for _, component := range h.Components[prefix] {
+			if !slices.Contains(components, component) {
+				components = append(components, component)
+			}
+		}
+	}
+
+	return components
+}
+
+// Load read the project configuration at path, if the file doesn't exist an empty configuration is returned
+func Load(fSys filesys.FileSystem, path string) (*Project, error) {
+	project := new(Project)
+	if !fSys.Exists(path) {
+		return project, nil
+	}
+
+	data, err := fSys.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project configuration: %w", err)
+	}
+
+	if err := yaml.UnmarshalStrict(data, project); err != nil {
+		return nil, fmt.Errorf("failed to parse project configuration %q: %w", path, err)
+	}
+
+	return project, nil
+}
+
+// Find search the project configuration file starting from dir and going up to the root of the repository, the
+// first directory containing a .git entry, or of the file system. An empty string is returned if no file is found.
+func Find(fSys filesys.FileSystem, dir string) string {
+	for {
+		path := filepath.Join(dir, DefaultFileName)
+		if fSys.Exists(path) && !fSys.IsDir(path) {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if fSys.Exists(filepath.Join(dir, ".git")) || parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ApplyDefaults set the default values found in the project configuration for command on flags, skipping the
+// ones already set on the command line
+func (p *Project) ApplyDefaults(command string, flags *pflag.FlagSet) error {
+	for name, value := range p.Defaults[command] {
+		flag := flags.Lookup(name)
+		if flag == nil {
+			return fmt.Errorf("unknown flag %q in the project configuration defaults for %q", name, command)
+		}
+
+		if flag.Changed {
+			continue
+		}
+
+		if err := setFlagValue(flag, value); err != nil {
+			return fmt.Errorf("invalid default value for flag %q of %q: %w", name, command, err)
+		}
+	}
+
+	return nil
+}
+
+// ApplyProfile set the flag values of the profile name on flags, skipping the ones already set on the command line
+// and the ignored ones
+func (d Deploy) ApplyProfile(name string, flags *pflag.FlagSet, ignored ...string) error {
+	profile, found := d.Profiles[name]
+	if !found {
+		available := slices.Sorted(maps.Keys(d.Profiles))
+		if len(available) == 0 {
+			return fmt.Errorf("unknown profile %q, no profiles are defined in the project configuration", name)
+		}
+		return fmt.Errorf("unknown profile %q, available profiles are: %s", name, strings.Join(available, ", "))
+	}
+
+	for flagName, value := range profile {
+		if slices.Contains(ignored, flagName) {
+			return fmt.Errorf("flag %q cannot be set in the profile %q", flagName, name)
+		}
+
+		flag := flags.Lookup(flagName)
+		if flag == nil {
+			return fmt.Errorf("unknown flag %q in the profile %q", flagName, name)
+		}
+
+		if flag.Changed {
+			continue
+		}
+
+		if err := setFlagValue(flag, value); err != nil {
+			return fmt.Errorf("invalid value for flag %q in the profile %q: %w", flagName, name, err)
+		}
+	}
+
+	return nil
+}
+
+// setFlagValue set value on flag, lists replace the values of slice flags or are joined with a comma for the others
+func setFlagValue(flag *pflag.Flag, value interface{}) error {
+	list, isList := value.([]interface{})
+	if !isList {
+		if value == nil {
+			return fmt.Errorf("missing value")
+		}
+		return flag.Value.Set(fmt.Sprint(value))
+	}
+
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		values = append(values, fmt.Sprint(item))
+	}
+
+	if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+		return sliceValue.Replace(values)
+	}
+	return flag.Value.Set(strings.Join(values, ","))
+}
+
+// NewContext return a copy of ctx containing the path of the project configuration
+func NewContext(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, contextKey{}, path)
+}
+
+// PathFromContext return the project configuration path saved in ctx, or DefaultFileName if not present
+func PathFromContext(ctx context.Context) string {
+	if path, ok := ctx.Value(contextKey{}).(string); ok {
+		return path
+	}
+
+	return DefaultFileName
+}
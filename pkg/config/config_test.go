@@ -0,0 +1,367 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("mlp.yaml", []byte(`defaults:
+  deploy:
+    ensure-namespace: true
+deploy:
+  readiness:
+  - group: example.com
+    kind: Custom
+    ready: object.status.ready
+  profiles:
+    production:
+      deploy-type: smart_deploy
+hydrate:
+  components:
+    DEV_:
+    - components/debug
+`)))
+	require.NoError(t, fSys.WriteFile("invalid.yaml", []byte(`unknown: value`)))
+
+	tests := map[string]struct {
+		path            string
+		expectedProject *Project
+		expectedError   string
+	}{
+		"load project configuration": {
+			path: DefaultFileName,
+			expectedProject: &Project{
+				Defaults: map[string]map[string]interface{}{
+					"deploy": {"ensure-namespace": true},
+				},
+				Deploy: Deploy{
+					Readiness: []extensions.ReadinessDefinition{
+						{Group: "example.com", Kind: "Custom", Ready: "object.status.ready"},
+					},
+					Profiles: map[string]map[string]interface{}{
+						"production": {"deploy-type": "smart_deploy"},
+					},
+				},
+				Hydrate: Hydrate{
+					Components: map[string][]string{
+						"DEV_": {"components/debug"},
+					},
+				},
+			},
+		},
+		"missing file return an empty configuration": {
+			path:            "missing.yaml",
+			expectedProject: &Project{},
+		},
+		"unknown keys": {
+			path:          "invalid.yaml",
+			expectedError: `failed to parse project configuration "invalid.yaml"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			project, err := Load(fSys, test.path)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedProject, project)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile(filepath.Join("/", "repo", DefaultFileName), []byte{}))
+	require.NoError(t, fSys.MkdirAll(filepath.Join("/", "repo", ".git")))
+	require.NoError(t, fSys.MkdirAll(filepath.Join("/", "repo", "nested", "dir")))
+	require.NoError(t, fSys.MkdirAll(filepath.Join("/", "other", ".git")))
+	require.NoError(t, fSys.MkdirAll(filepath.Join("/", "other", "nested")))
+	require.NoError(t, fSys.WriteFile(filepath.Join("/", DefaultFileName), []byte{}))
+
+	tests := map[string]struct {
+		dir          string
+		expectedPath string
+	}{
+		"file in the directory": {
+			dir:          filepath.Join("/", "repo"),
+			expectedPath: filepath.Join("/", "repo", DefaultFileName),
+		},
+		"file in the repository root": {
+			dir:          filepath.Join("/", "repo", "nested", "dir"),
+			expectedPath: filepath.Join("/", "repo", DefaultFileName),
+		},
+		"search stops at the repository root": {
+			dir: filepath.Join("/", "other", "nested"),
+		},
+		"search stops at the file system root": {
+			dir:          filepath.Join("/", "outside"),
+			expectedPath: filepath.Join("/", DefaultFileName),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expectedPath, Find(fSys, test.dir))
+		})
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	t.Parallel()
+
+	project := &Project{
+		Defaults: map[string]map[string]interface{}{
+			"generate": {
+				"env-prefix": []interface{}{"MLP_", "CI_"},
+				"out":        "generated",
+				"parallel":   float64(4),
+				"verbose":    true,
+			},
+			"unknown": {
+				"missing": "value",
+			},
+			"invalid": {
+				"parallel": "many",
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		command          string
+		args             []string
+		expectedPrefixes []string
+		expectedOut      string
+		expectedParallel int
+		expectedVerbose  bool
+		expectedError    string
+	}{
+		"defaults are applied": {
+			command:          "generate",
+			expectedPrefixes: []string{"MLP_", "CI_"},
+			expectedOut:      "generated",
+			expectedParallel: 4,
+			expectedVerbose:  true,
+		},
+		"command line flags take precedence": {
+			command:          "generate",
+			args:             []string{"--env-prefix", "DEV_", "--out", "output", "--verbose=false"},
+			expectedPrefixes: []string{"DEV_"},
+			expectedOut:      "output",
+			expectedParallel: 4,
+		},
+		"command without defaults": {
+			command:          "deploy",
+			expectedPrefixes: []string{"DEFAULT_"},
+			expectedOut:      ".",
+			expectedParallel: 1,
+		},
+		"unknown flag": {
+			command:       "unknown",
+			expectedError: `unknown flag "missing" in the project configuration defaults for "unknown"`,
+		},
+		"invalid value": {
+			command:       "invalid",
+			expectedError: `invalid default value for flag "parallel" of "invalid"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var prefixes []string
+			var out string
+			var parallel int
+			var verbose bool
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			flags.StringSliceVar(&prefixes, "env-prefix", []string{"DEFAULT_"}, "")
+			flags.StringVar(&out, "out", ".", "")
+			flags.IntVar(&parallel, "parallel", 1, "")
+			flags.BoolVar(&verbose, "verbose", false, "")
+			require.NoError(t, flags.Parse(test.args))
+
+			err := project.ApplyDefaults(test.command, flags)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedPrefixes, prefixes)
+				assert.Equal(t, test.expectedOut, out)
+				assert.Equal(t, test.expectedParallel, parallel)
+				assert.Equal(t, test.expectedVerbose, verbose)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	t.Parallel()
+
+	deploy := Deploy{
+		Profiles: map[string]map[string]interface{}{
+			"production": {
+				"deploy-type":       "smart_deploy",
+				"ensure-namespace":  true,
+				"notify-url":        []interface{}{"https://example.com/a", "https://example.com/b"},
+				"health-check-wait": "10m",
+			},
+			"unknown": {
+				"missing": "value",
+			},
+			"invalid": {
+				"ensure-namespace": "maybe",
+			},
+			"nested": {
+				"profile": "production",
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		profile                 string
+		args                    []string
+		expectedDeployType      string
+		expectedEnsureNamespace bool
+		expectedNotifyURLs      []string
+		expectedError           string
+	}{
+		"profile values are applied": {
+			profile:                 "production",
+			expectedDeployType:      "smart_deploy",
+			expectedEnsureNamespace: true,
+			expectedNotifyURLs:      []string{"https://example.com/a", "https://example.com/b"},
+		},
+		"command line flags take precedence": {
+			profile:            "production",
+			args:               []string{"--deploy-type", "deploy_all", "--ensure-namespace=false", "--notify-url", "https://example.com/c"},
+			expectedDeployType: "deploy_all",
+			expectedNotifyURLs: []string{"https://example.com/c"},
+		},
+		"missing profile": {
+			profile:       "staging",
+			expectedError: `unknown profile "staging", available profiles are: invalid, nested, production, unknown`,
+		},
+		"unknown flag": {
+			profile:       "unknown",
+			expectedError: `unknown flag "missing" in the profile "unknown"`,
+		},
+		"invalid value": {
+			profile:       "invalid",
+			expectedError: `invalid value for flag "ensure-namespace" in the profile "invalid"`,
+		},
+		"ignored flag": {
+			profile:       "nested",
+			expectedError: `flag "profile" cannot be set in the profile "nested"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var deployType, profile, healthCheckWait string
+			var ensureNamespace bool
+			var notifyURLs []string
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			flags.StringVar(&deployType, "deploy-type", "deploy_all", "")
+			flags.BoolVar(&ensureNamespace, "ensure-namespace", false, "")
+			flags.StringSliceVar(&notifyURLs, "notify-url", nil, "")
+			flags.StringVar(&healthCheckWait, "health-check-wait", "5m", "")
+			flags.StringVar(&profile, "profile", "", "")
+			require.NoError(t, flags.Parse(test.args))
+
+			err := deploy.ApplyProfile(test.profile, flags, "profile")
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedDeployType, deployType)
+				assert.Equal(t, test.expectedEnsureNamespace, ensureNamespace)
+				assert.Equal(t, test.expectedNotifyURLs, notifyURLs)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+
+	err := Deploy{}.ApplyProfile("production", pflag.NewFlagSet("test", pflag.ContinueOnError))
+	assert.EqualError(t, err, `unknown profile "production", no profiles are defined in the project configuration`)
+}
+
+func TestComponentsFor(t *testing.T) {
+	t.Parallel()
+
+	hydrate := Hydrate{
+		Components: map[string][]string{
+			"DEV_":  {"components/debug", "components/mesh"},
+			"PROD_": {"components/mesh", "components/monitoring"},
+		},
+	}
+
+	tests := map[string]struct {
+		envPrefixes        []string
+		expectedComponents []string
+	}{
+		"no prefixes": {},
+		"unknown prefix": {
+			envPrefixes: []string{"TEST_"},
+		},
+		"single prefix": {
+			envPrefixes:        []string{"DEV_"},
+			expectedComponents: []string{"components/debug", "components/mesh"},
+		},
+		"multiple prefixes are merged without duplicates": {
+			envPrefixes:        []string{"PROD_", "DEV_"},
+			expectedComponents: []string{"components/mesh", "components/monitoring", "components/debug"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expectedComponents, hydrate.ComponentsFor(test.envPrefixes))
+		})
+	}
+}
+
+func TestPathFromContext(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, DefaultFileName, PathFromContext(context.TODO()))
+	assert.Equal(t, "custom.yaml", PathFromContext(NewContext(context.TODO(), "custom.yaml")))
+}
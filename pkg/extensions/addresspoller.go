@@ -0,0 +1,258 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/poller"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// AwaitCompletionAnnotation enable the wait for the address assignment of Ingress, Gateway and HTTPRoute resources,
+	// its value can be true or a duration that also limits the wait for the resource to become ready
+	AwaitCompletionAnnotation = miaPlatformPrefix + "await-completion"
+	// AwaitHealthPathAnnotation contains the path that must respond 200 via the assigned address before considering
+	// the resource ready, it is used only if AwaitCompletionAnnotation is enabled
+	AwaitHealthPathAnnotation = miaPlatformPrefix + "await-health-path"
+
+	currentMessage = "Resource is current"
+)
+
+// IngressGK, GatewayGK and HTTPRouteGK identify the resources that can wait for the assignment of an address
+var (
+	IngressGK   = networkingv1.SchemeGroupVersion.WithKind("Ingress").GroupKind()
+	GatewayGK   = schema.GroupKind{Group: "gateway.networking.k8s.io", Kind: "Gateway"}
+	HTTPRouteGK = schema.GroupKind{Group: "gateway.networking.k8s.io", Kind: "HTTPRoute"}
+)
+
+// AddressStatusCheckers return the status checkers that wait for the assignment of an address to the Ingress,
+// Gateway and HTTPRoute resources that have the await completion annotation
+func AddressStatusCheckers() poller.CustomStatusCheckers {
+	return poller.CustomStatusCheckers{
+		IngressGK:   awaitCompletionChecker(ingressStatusChecker),
+		GatewayGK:   awaitCompletionChecker(gatewayStatusChecker),
+		HTTPRouteGK: awaitCompletionChecker(httpRouteStatusChecker),
+	}
+}
+
+// AwaitCompletion return true if object has the await completion annotation enabled, set to true or to a timeout
+func AwaitCompletion(object *unstructured.Unstructured) bool {
+	value := object.GetAnnotations()[AwaitCompletionAnnotation]
+	if enabled, err := strconv.ParseBool(value); err == nil {
+		return enabled
+	}
+
+	_, found := AwaitCompletionTimeout(object)
+	return found
+}
+
+// AwaitCompletionTimeout return the timeout set in the await completion annotation of object and true, or false if
+// the annotation doesn't contain a positive duration like the 10m used by the previous versions of mlp
+func AwaitCompletionTimeout(object *unstructured.Unstructured) (time.Duration, bool) {
+	timeout, err := time.ParseDuration(object.GetAnnotations()[AwaitCompletionAnnotation])
+	if err != nil || timeout <= 0 {
+		return 0, false
+	}
+	return timeout, true
+}
+
+// awaitCompletionChecker wrap checker for calling it only on the resources with the await completion annotation,
+// the other ones are considered current as soon as they are applied
+func awaitCompletionChecker(checker poller.StatusCheckerFunc) poller.StatusCheckerFunc {
+	return func(object *unstructured.Unstructured) (*poller.Result, error) {
+		if !AwaitCompletion(object) {
+			return &poller.Result{Status: poller.StatusCurrent, Message: currentMessage}, nil
+		}
+
+		return checker(object)
+	}
+}
+
+// ingressStatusChecker contains the logic for checking if an Ingress has received an address from its controller
+func ingressStatusChecker(object *unstructured.Unstructured) (*poller.Result, error) {
+	ingress := new(networkingv1.Ingress)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, ingress); err != nil {
+		return nil, err
+	}
+
+	if address := IngressAddress(ingress); len(address) > 0 {
+		return &poller.Result{
+			Status:  poller.StatusCurrent,
+			Message: fmt.Sprintf("Ingress has address %s", address),
+		}, nil
+	}
+
+	return &poller.Result{
+		Status:  poller.StatusInProgress,
+		Message: "Ingress address assignment in progress",
+	}, nil
+}
+
+// IngressAddress return the first ip or hostname assigned to ingress, or an empty string if none is present
+func IngressAddress(ingress *networkingv1.Ingress) string {
+	for _, lbIngress := range ingress.Status.LoadBalancer.Ingress {
+		if len(lbIngress.IP) > 0 {
+			return lbIngress.IP
+		}
+		if len(lbIngress.Hostname) > 0 {
+			return lbIngress.Hostname
+		}
+	}
+
+	return ""
+}
+
+// gatewayStatusChecker contains the logic for checking if a Gateway has been programmed and has received an address
+func gatewayStatusChecker(object *unstructured.Unstructured) (*poller.Result, error) {
+	conditions, err := statusConditions(object.Object, "status", "conditions")
+	if err != nil {
+		return nil, err
+	}
+
+	if condition := findCondition(conditions, "Programmed"); condition != nil && condition.Status != metav1.ConditionTrue {
+		return &poller.Result{
+			Status:  poller.StatusInProgress,
+			Message: condition.Message,
+		}, nil
+	}
+
+	address, err := GatewayAddress(object)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(address) > 0 {
+		return &poller.Result{
+			Status:  poller.StatusCurrent,
+			Message: fmt.Sprintf("Gateway has address %s", address),
+		}, nil
+	}
+
+	return &poller.Result{
+		Status:  poller.StatusInProgress,
+		Message: "Gateway address assignment in progress",
+	}, nil
+}
+
+// GatewayAddress return the first address assigned to the gateway, or an empty string if none is present
+func GatewayAddress(gateway *unstructured.Unstructured) (string, error) {
+	addresses, _, err := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+	if err != nil {
+		return "", err
+	}
+
+	for _, address := range addresses {
+		addressMap, ok := address.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if value, ok := addressMap["value"].(string); ok && len(value) > 0 {
+			return value, nil
+		}
+	}
+
+	return "", nil
+}
+
+// httpRouteStatusChecker contains the logic for checking if an HTTPRoute has been accepted by all its parents
+func httpRouteStatusChecker(object *unstructured.Unstructured) (*poller.Result, error) {
+	parents, _, err := unstructured.NestedSlice(object.Object, "status", "parents")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parents) == 0 {
+		return &poller.Result{
+			Status:  poller.StatusInProgress,
+			Message: "HTTPRoute is waiting to be accepted by its parents",
+		}, nil
+	}
+
+	for _, parent := range parents {
+		parentMap, ok := parent.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		conditions, err := statusConditions(parentMap, "conditions")
+		if err != nil {
+			return nil, err
+		}
+
+		condition := findCondition(conditions, "Accepted")
+		switch {
+		case condition == nil:
+			return &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "HTTPRoute is waiting to be accepted by its parents",
+			}, nil
+		case condition.Status != metav1.ConditionTrue:
+			return &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: condition.Message,
+			}, nil
+		}
+	}
+
+	return &poller.Result{
+		Status:  poller.StatusCurrent,
+		Message: "HTTPRoute has been accepted by its parents",
+	}, nil
+}
+
+// statusConditions return the conditions found at fields inside object, skipping the ones that cannot be parsed
+func statusConditions(object map[string]interface{}, fields ...string) ([]metav1.Condition, error) {
+	conditionsData, _, err := unstructured.NestedSlice(object, fields...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", strings.Join(fields, "."), err)
+	}
+
+	conditions := make([]metav1.Condition, 0, len(conditionsData))
+	for _, conditionData := range conditionsData {
+		conditionMap, ok := conditionData.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		condition := metav1.Condition{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(conditionMap, &condition); err != nil {
+			continue
+		}
+		conditions = append(conditions, condition)
+	}
+
+	return conditions, nil
+}
+
+// findCondition return the condition with conditionType, or nil if it is not present
+func findCondition(conditions []metav1.Condition, conditionType string) *metav1.Condition {
+	for idx := range conditions {
+		if conditions[idx].Type == conditionType {
+			return &conditions[idx]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/jpl/pkg/poller"
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAddressStatusCheckers(t *testing.T) {
+	t.Parallel()
+
+	testdata := filepath.Join("testdata", "custom-pollers")
+	checkers := AddressStatusCheckers()
+	assert.Equal(t, 3, len(checkers))
+
+	tests := map[string]struct {
+		object         *unstructured.Unstructured
+		expectedResult *poller.Result
+	}{
+		"resource without annotation is current": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "ingress-no-annotation.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: "Resource is current",
+			},
+		},
+		"ingress without address is in progress": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "ingress-no-address.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "Ingress address assignment in progress",
+			},
+		},
+		"ingress with address is current": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "ingress-address.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: "Ingress has address lb.example.com",
+			},
+		},
+		"gateway not programmed is in progress": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "gateway-not-programmed.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "waiting for the load balancer",
+			},
+		},
+		"gateway without address is in progress": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "gateway-no-address.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "Gateway address assignment in progress",
+			},
+		},
+		"gateway with address is current": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "gateway-address.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: "Gateway has address 10.0.0.1",
+			},
+		},
+		"httproute without parents status is in progress": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "httproute-no-parents.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "HTTPRoute is waiting to be accepted by its parents",
+			},
+		},
+		"httproute not accepted is in progress": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "httproute-not-accepted.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "route not allowed by the listeners",
+			},
+		},
+		"httproute accepted is current": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "httproute-accepted.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: "HTTPRoute has been accepted by its parents",
+			},
+		},
+	}
+
+	for testName, testCase := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			checker, found := checkers[testCase.object.GroupVersionKind().GroupKind()]
+			assert.True(t, found)
+
+			result, err := checker(testCase.object)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedResult, result)
+		})
+	}
+}
+
+func TestAwaitCompletion(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		annotations map[string]string
+		expected    bool
+	}{
+		"no annotations": {},
+		"annotation enabled": {
+			annotations: map[string]string{AwaitCompletionAnnotation: "true"},
+			expected:    true,
+		},
+		"annotation disabled": {
+			annotations: map[string]string{AwaitCompletionAnnotation: "false"},
+		},
+		"annotation with timeout": {
+			annotations: map[string]string{AwaitCompletionAnnotation: "10m"},
+			expected:    true,
+		},
+		"annotation with zero timeout": {
+			annotations: map[string]string{AwaitCompletionAnnotation: "0s"},
+		},
+		"invalid annotation": {
+			annotations: map[string]string{AwaitCompletionAnnotation: "yes please"},
+		},
+	}
+
+	for testName, testCase := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			obj.SetAnnotations(testCase.annotations)
+			assert.Equal(t, testCase.expected, AwaitCompletion(obj))
+		})
+	}
+}
+
+func TestAwaitCompletionTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		annotations     map[string]string
+		expectedTimeout time.Duration
+		expectedFound   bool
+	}{
+		"no annotations": {},
+		"annotation enabled": {
+			annotations: map[string]string{AwaitCompletionAnnotation: "true"},
+		},
+		"annotation with timeout": {
+			annotations:     map[string]string{AwaitCompletionAnnotation: "10m"},
+			expectedTimeout: 10 * time.Minute,
+			expectedFound:   true,
+		},
+		"annotation with negative timeout": {
+			annotations: map[string]string{AwaitCompletionAnnotation: "-10m"},
+		},
+	}
+
+	for testName, testCase := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			obj.SetAnnotations(testCase.annotations)
+			timeout, found := AwaitCompletionTimeout(obj)
+			assert.Equal(t, testCase.expectedTimeout, timeout)
+			assert.Equal(t, testCase.expectedFound, found)
+		})
+	}
+}
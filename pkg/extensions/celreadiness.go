@@ -0,0 +1,241 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/google/cel-go/cel"
+	"github.com/mia-platform/jpl/pkg/poller"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// celObjectVariable is the name of the variable containing the resource inside the CEL expressions
+	celObjectVariable = "object"
+	// celGenerationObservedVariable is the name of the variable that is true when the controller of the resource
+	// has observed its latest generation, or doesn't report the generation it has observed
+	celGenerationObservedVariable = "generationObserved"
+
+	// conditionObserved is true when the condition c refers to the latest generation of the resource, or doesn't
+	// report the generation it refers to
+	conditionObserved = `(!has(c.observedGeneration) || !has(object.metadata.generation) ||
+		c.observedGeneration == object.metadata.generation)`
+
+	readyConditionExpression = `generationObserved && has(object.status) && has(object.status.conditions) &&
+		object.status.conditions.exists(c, c.type == "Ready" && c.status == "True" && ` + conditionObserved + `)`
+	readyConditionMessage = `generationObserved && has(object.status) && has(object.status.conditions) &&
+		object.status.conditions.exists(c, c.type == "Ready" && has(c.message) && ` + conditionObserved + `) ?
+		object.status.conditions.filter(c, c.type == "Ready")[0].message : ""`
+	certificateFailedExpression = `generationObserved && has(object.status) && has(object.status.conditions) &&
+		object.status.conditions.exists(c, c.type == "Issuing" && c.status == "False" && c.reason == "Failed" &&
+		` + conditionObserved + `)`
+)
+
+// ReadinessDefinition contains the CEL expressions used for computing the status of the resources matching
+// Group and Kind. The expressions can access the resource via the object variable, and the generationObserved
+// variable is true when status.observedGeneration is missing or is equal to metadata.generation.
+type ReadinessDefinition struct {
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+	// Ready must return true when the resource is ready
+	Ready string `json:"ready"`
+	// Failed is optional and must return true when the resource will not become ready without user intervention
+	Failed string `json:"failed,omitempty"`
+	// Message is optional and must return a string describing the current status of the resource
+	Message string `json:"message,omitempty"`
+}
+
+// builtinReadinessDefinitions contains the definitions for well known custom resources
+var builtinReadinessDefinitions = []ReadinessDefinition{
+	{
+		Group:   "cert-manager.io",
+		Kind:    "Certificate",
+		Ready:   readyConditionExpression,
+		Failed:  certificateFailedExpression,
+		Message: readyConditionMessage,
+	},
+	{Group: "cert-manager.io", Kind: "Issuer", Ready: readyConditionExpression, Message: readyConditionMessage},
+	{Group: "cert-manager.io", Kind: "ClusterIssuer", Ready: readyConditionExpression, Message: readyConditionMessage},
+	{Group: "kafka.strimzi.io", Kind: "Kafka", Ready: readyConditionExpression, Message: readyConditionMessage},
+	{Group: "kafka.strimzi.io", Kind: "KafkaTopic", Ready: readyConditionExpression, Message: readyConditionMessage},
+	{Group: "kafka.strimzi.io", Kind: "KafkaUser", Ready: readyConditionExpression, Message: readyConditionMessage},
+}
+
+// ReadinessStatusCheckers return the status checkers for the built-in readiness definitions and the ones passed
+// as argument, that will override the built-in ones with the same group and kind
+func ReadinessStatusCheckers(definitions []ReadinessDefinition) (poller.CustomStatusCheckers, error) {
+	env, err := cel.NewEnv(
+		cel.Variable(celObjectVariable, cel.DynType),
+		cel.Variable(celGenerationObservedVariable, cel.BoolType),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	checkers := make(poller.CustomStatusCheckers)
+	for _, definition := range slices.Concat(builtinReadinessDefinitions, definitions) {
+		checker, err := newReadinessChecker(env, definition)
+		if err != nil {
+			return nil, err
+		}
+		checkers[schema.GroupKind{Group: definition.Group, Kind: definition.Kind}] = checker.statusCheck
+	}
+
+	return checkers, nil
+}
+
+// readinessChecker contains the compiled programs of a ReadinessDefinition
+type readinessChecker struct {
+	kind    string
+	ready   cel.Program
+	failed  cel.Program
+	message cel.Program
+}
+
+func newReadinessChecker(env *cel.Env, definition ReadinessDefinition) (*readinessChecker, error) {
+	if len(definition.Kind) == 0 {
+		return nil, fmt.Errorf("readiness definition for group %q is missing the kind", definition.Group)
+	}
+
+	gk := schema.GroupKind{Group: definition.Group, Kind: definition.Kind}
+	if len(definition.Ready) == 0 {
+		return nil, fmt.Errorf("readiness definition for %s is missing the ready expression", gk)
+	}
+
+	checker := &readinessChecker{kind: definition.Kind}
+	var err error
+	if checker.ready, err = compileExpression(env, definition.Ready, cel.BoolType); err != nil {
+		return nil, fmt.Errorf("invalid ready expression for %s: %w", gk, err)
+	}
+
+	if len(definition.Failed) > 0 {
+		if checker.failed, err = compileExpression(env, definition.Failed, cel.BoolType); err != nil {
+			return nil, fmt.Errorf("invalid failed expression for %s: %w", gk, err)
+		}
+	}
+
+	if len(definition.Message) > 0 {
+		if checker.message, err = compileExpression(env, definition.Message, cel.StringType); err != nil {
+			return nil, fmt.Errorf("invalid message expression for %s: %w", gk, err)
+		}
+	}
+
+	return checker, nil
+}
+
+// compileExpression compile expression checking that it will return a value of outputType or a dynamic one
+func compileExpression(env *cel.Env, expression string, outputType *cel.Type) (cel.Program, error) {
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+
+	if !ast.OutputType().IsExactType(outputType) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression must return a %s, found %s", outputType, ast.OutputType())
+	}
+
+	return env.Program(ast)
+}
+
+// statusCheck implement poller.StatusCheckerFunc evaluating the compiled expressions against object, if the
+// evaluation fails the resource is considered in progress because the status can be not populated yet
+func (c *readinessChecker) statusCheck(object *unstructured.Unstructured) (*poller.Result, error) {
+	variables := celVariables(object)
+	message := c.evaluateMessage(variables)
+
+	if c.failed != nil {
+		failed, err := evaluateBool(c.failed, variables)
+		if err != nil {
+			return &poller.Result{Status: poller.StatusInProgress, Message: err.Error()}, nil
+		}
+		if failed {
+			return &poller.Result{Status: poller.StatusFailed, Message: defaultMessage(message, c.kind+" has failed")}, nil
+		}
+	}
+
+	ready, err := evaluateBool(c.ready, variables)
+	switch {
+	case err != nil:
+		return &poller.Result{Status: poller.StatusInProgress, Message: err.Error()}, nil
+	case ready:
+		return &poller.Result{Status: poller.StatusCurrent, Message: defaultMessage(message, c.kind+" is ready")}, nil
+	default:
+		return &poller.Result{Status: poller.StatusInProgress, Message: defaultMessage(message, c.kind+" is not ready yet")}, nil
+	}
+}
+
+// celVariables return the variables available to the expressions for object
+func celVariables(object *unstructured.Unstructured) map[string]interface{} {
+	return map[string]interface{}{
+		celObjectVariable:             object.Object,
+		celGenerationObservedVariable: generationObserved(object),
+	}
+}
+
+// generationObserved return false only if object report in status.observedGeneration a generation different
+// from its metadata.generation, so its status still describes a previous version of the resource
+func generationObserved(object *unstructured.Unstructured) bool {
+	observedGeneration, found, err := unstructured.NestedInt64(object.Object, "status", "observedGeneration")
+	if err != nil || !found {
+		return true
+	}
+
+	generation, found, err := unstructured.NestedInt64(object.Object, "metadata", "generation")
+	if err != nil || !found {
+		return true
+	}
+
+	return observedGeneration == generation
+}
+
+// evaluateMessage return the result of the message expression or an empty string if is not set or has failed
+func (c *readinessChecker) evaluateMessage(variables map[string]interface{}) string {
+	if c.message == nil {
+		return ""
+	}
+
+	value, _, err := c.message.Eval(variables)
+	if err != nil {
+		return ""
+	}
+
+	message, _ := value.Value().(string)
+	return message
+}
+
+func evaluateBool(program cel.Program, variables map[string]interface{}) (bool, error) {
+	value, _, err := program.Eval(variables)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate readiness: %w", err)
+	}
+
+	result, ok := value.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("readiness expression returned %T instead of a boolean", value.Value())
+	}
+
+	return result, nil
+}
+
+func defaultMessage(message, fallback string) string {
+	if len(message) > 0 {
+		return message
+	}
+	return fallback
+}
@@ -0,0 +1,190 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/poller"
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestReadinessStatusCheckers(t *testing.T) {
+	t.Parallel()
+
+	testdata := filepath.Join("testdata", "cel-readiness")
+	customDefinitions := []ReadinessDefinition{
+		{
+			Group:   "example.com",
+			Kind:    "Custom",
+			Ready:   `generationObserved && object.status.phase == "Ready"`,
+			Failed:  `object.status.phase == "Error"`,
+			Message: `"phase is " + object.status.phase`,
+		},
+		{
+			Group: "cert-manager.io",
+			Kind:  "Issuer",
+			Ready: `true`,
+		},
+	}
+
+	checkers, err := ReadinessStatusCheckers(customDefinitions)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		object         *unstructured.Unstructured
+		expectedResult *poller.Result
+	}{
+		"builtin ready certificate": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "certificate-ready.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: "Certificate is up to date and has not expired",
+			},
+		},
+		"builtin failed certificate": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "certificate-failed.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusFailed,
+				Message: "Issuing certificate as Secret does not exist",
+			},
+		},
+		"builtin certificate without status": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "certificate-no-status.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "Certificate is not ready yet",
+			},
+		},
+		"builtin certificate with condition of a previous generation": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "certificate-stale.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "Certificate is not ready yet",
+			},
+		},
+		"builtin ready kafka": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "kafka-ready.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: "Kafka cluster is ready",
+			},
+		},
+		"builtin kafka with status of a previous generation": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "kafka-stale.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "Kafka is not ready yet",
+			},
+		},
+		"custom resource with status of a previous generation": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "custom-stale.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "phase is Ready",
+			},
+		},
+		"custom resource in progress": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "custom-pending.yaml")),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "phase is Pending",
+			},
+		},
+		"custom resource with evaluation error": {
+			object: func() *unstructured.Unstructured {
+				obj := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "custom-pending.yaml"))
+				unstructured.RemoveNestedField(obj.Object, "status")
+				return obj
+			}(),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "failed to evaluate readiness: no such key: status",
+			},
+		},
+		"overridden builtin definition": {
+			object: func() *unstructured.Unstructured {
+				obj := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "certificate-no-status.yaml"))
+				obj.SetKind("Issuer")
+				return obj
+			}(),
+			expectedResult: &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: "Issuer is ready",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			checker, found := checkers[test.object.GroupVersionKind().GroupKind()]
+			require.True(t, found)
+			result, err := checker(test.object)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedResult, result)
+		})
+	}
+}
+
+func TestInvalidReadinessDefinitions(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		definition    ReadinessDefinition
+		expectedError string
+	}{
+		"missing kind": {
+			definition:    ReadinessDefinition{Group: "example.com", Ready: "true"},
+			expectedError: `readiness definition for group "example.com" is missing the kind`,
+		},
+		"missing ready expression": {
+			definition:    ReadinessDefinition{Group: "example.com", Kind: "Custom"},
+			expectedError: "readiness definition for Custom.example.com is missing the ready expression",
+		},
+		"syntax error": {
+			definition:    ReadinessDefinition{Group: "example.com", Kind: "Custom", Ready: "object.status =="},
+			expectedError: "invalid ready expression for Custom.example.com",
+		},
+		"wrong ready type": {
+			definition:    ReadinessDefinition{Group: "example.com", Kind: "Custom", Ready: `"ready"`},
+			expectedError: "expression must return a bool, found string",
+		},
+		"wrong message type": {
+			definition:    ReadinessDefinition{Group: "example.com", Kind: "Custom", Ready: "true", Message: "1"},
+			expectedError: "invalid message expression for Custom.example.com",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ReadinessStatusCheckers([]ReadinessDefinition{test.definition})
+			assert.ErrorContains(t, err, test.expectedError)
+		})
+	}
+
+	checkers, err := ReadinessStatusCheckers(nil)
+	require.NoError(t, err)
+	assert.Contains(t, checkers, schema.GroupKind{Group: "kafka.strimzi.io", Kind: "Kafka"})
+}
@@ -0,0 +1,122 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+
+	"github.com/mia-platform/jpl/pkg/client/cache"
+	"github.com/mia-platform/jpl/pkg/generator"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// CreatedByCronJobAnnotation is set on the Jobs generated from a CronJob and contains the CronJob name
+	CreatedByCronJobAnnotation = miaPlatformPrefix + "created-by-cronjob"
+
+	instantiateAnnotation = "cronjob.kubernetes.io/instantiate"
+	instantiateValue      = "manual"
+
+	// jobSuffixLength is the length of the random suffix added to the CronJob name, the name is truncated for
+	// keeping the Job name valid as a label value
+	jobSuffixLength  = 5
+	maxJobNameLength = 63
+)
+
+var (
+	cronJobGK = batchv1.SchemeGroupVersion.WithKind(reflect.TypeOf(batchv1.CronJob{}).Name()).GroupKind()
+	jobGK     = batchv1.SchemeGroupVersion.WithKind(reflect.TypeOf(batchv1.Job{}).Name()).GroupKind()
+)
+
+// NewCronJobGenerator return a new generator.Interface that will create a Job from the CronJobs that have value
+// in annotation, mimicking 'kubectl create job --from cronjob/'. The Job keeps track of the CronJob that has
+// created it, and inherit its await completion annotation for waiting the end of the run during the deploy.
+func NewCronJobGenerator(annotation string, value string) generator.Interface {
+	return &cronJobGenerator{
+		annotation: annotation,
+		value:      value,
+	}
+}
+
+// keep it to always check if cronJobGenerator implement correctly the generator.Interface interface
+var _ generator.Interface = &cronJobGenerator{}
+
+type cronJobGenerator struct {
+	annotation string
+	value      string
+}
+
+// CanHandleResource implement generator.Interface interface
+func (g *cronJobGenerator) CanHandleResource(objMeta *metav1.PartialObjectMetadata) bool {
+	return objMeta.GroupVersionKind().GroupKind() == cronJobGK && objMeta.Annotations[g.annotation] == g.value
+}
+
+// Generate implement generator.Interface interface
+func (g *cronJobGenerator) Generate(obj *unstructured.Unstructured, _ cache.RemoteResourceGetter) ([]*unstructured.Unstructured, error) {
+	cronJob := new(batchv1.CronJob)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructuredWithValidation(obj.Object, cronJob, true); err != nil {
+		return nil, err
+	}
+
+	awaitCompletion := ""
+	if AwaitCompletion(obj) {
+		awaitCompletion = obj.GetAnnotations()[AwaitCompletionAnnotation]
+	}
+
+	job := jobFromCronJob(cronJob, awaitCompletion)
+	unstructuredJob, err := runtime.DefaultUnstructuredConverter.ToUnstructured(job)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*unstructured.Unstructured{{Object: unstructuredJob}}, nil
+}
+
+// jobFromCronJob return a new Job with the template of cronJob and an unique name, awaitCompletion is copied in its
+// annotations if not empty
+func jobFromCronJob(cronJob *batchv1.CronJob, awaitCompletion string) *batchv1.Job {
+	annotations := map[string]string{
+		instantiateAnnotation:      instantiateValue,
+		CreatedByCronJobAnnotation: cronJob.Name,
+	}
+	for key, value := range cronJob.Spec.JobTemplate.Annotations {
+		annotations[key] = value
+	}
+	if len(awaitCompletion) > 0 {
+		annotations[AwaitCompletionAnnotation] = awaitCompletion
+	}
+
+	suffix := make([]byte, jobSuffixLength)
+	_, _ = rand.Read(suffix)
+	prefix := cronJob.Name[:min(len(cronJob.Name), maxJobNameLength-jobSuffixLength-1)]
+
+	return &batchv1.Job{
+		// this is ok because we know exactly how we want to be serialized
+		TypeMeta: metav1.TypeMeta{APIVersion: batchv1.SchemeGroupVersion.String(), Kind: jobGK.Kind},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        prefix + "-" + hex.EncodeToString(suffix)[:jobSuffixLength],
+			Namespace:   cronJob.Namespace,
+			Annotations: annotations,
+			Labels:      cronJob.Spec.JobTemplate.Labels,
+		},
+		Spec: cronJob.Spec.JobTemplate.Spec,
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCronJobGenerator(t *testing.T) {
+	t.Parallel()
+
+	testdata := filepath.Join("testdata", "cronjob-generator")
+	generator := NewCronJobGenerator("mia-platform.eu/autocreate", "true")
+
+	tests := map[string]struct {
+		path                string
+		canHandle           bool
+		expectedNamePattern string
+		expectedAnnotations map[string]string
+		expectedLabels      map[string]string
+	}{
+		"cronjob without autocreate": {
+			path: "cronjob-no-autocreate.yaml",
+		},
+		"cronjob with autocreate": {
+			path:                "cronjob.yaml",
+			canHandle:           true,
+			expectedNamePattern: `^example-[0-9a-f]{5}$`,
+			expectedAnnotations: map[string]string{
+				"cronjob.kubernetes.io/instantiate":  "manual",
+				"mia-platform.eu/created-by-cronjob": "example",
+				"template":                           "annotation",
+			},
+			expectedLabels: map[string]string{"app": "example"},
+		},
+		"cronjob with autocreate and await completion": {
+			path:                "cronjob-await.yaml",
+			canHandle:           true,
+			expectedNamePattern: `^a-very-long-cronjob-name-that-would-not-leave-space-for-t-[0-9a-f]{5}$`,
+			expectedAnnotations: map[string]string{
+				"cronjob.kubernetes.io/instantiate":  "manual",
+				"mia-platform.eu/created-by-cronjob": "a-very-long-cronjob-name-that-would-not-leave-space-for-the-random-suffix",
+				"mia-platform.eu/await-completion":   "true",
+			},
+		},
+		"cronjob with autocreate and await completion timeout": {
+			path:                "cronjob-await-timeout.yaml",
+			canHandle:           true,
+			expectedNamePattern: `^migrations-[0-9a-f]{5}$`,
+			expectedAnnotations: map[string]string{
+				"cronjob.kubernetes.io/instantiate":  "manual",
+				"mia-platform.eu/created-by-cronjob": "migrations",
+				"mia-platform.eu/await-completion":   "10m",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			obj := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, test.path))
+			objMeta := &metav1.PartialObjectMetadata{
+				TypeMeta:   metav1.TypeMeta{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind()},
+				ObjectMeta: metav1.ObjectMeta{Annotations: obj.GetAnnotations()},
+			}
+			require.Equal(t, test.canHandle, generator.CanHandleResource(objMeta))
+			if !test.canHandle {
+				return
+			}
+
+			generated, err := generator.Generate(obj, nil)
+			require.NoError(t, err)
+			require.Len(t, generated, 1)
+
+			job := generated[0]
+			assert.Equal(t, "batch/v1", job.GetAPIVersion())
+			assert.Equal(t, "Job", job.GetKind())
+			assert.Equal(t, "test", job.GetNamespace())
+			assert.Regexp(t, regexp.MustCompile(test.expectedNamePattern), job.GetName())
+			assert.LessOrEqual(t, len(job.GetName()), maxJobNameLength)
+			assert.Equal(t, test.expectedAnnotations, job.GetAnnotations())
+			assert.Equal(t, test.expectedLabels, job.GetLabels())
+
+			containers, found, err := unstructured.NestedSlice(job.Object, "spec", "template", "spec", "containers")
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Len(t, containers, 1)
+
+			other, err := generator.Generate(obj, nil)
+			require.NoError(t, err)
+			assert.NotEqual(t, job.GetName(), other[0].GetName())
+		})
+	}
+}
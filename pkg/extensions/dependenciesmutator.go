@@ -16,10 +16,16 @@
 package extensions
 
 import (
+	"context"
+	"fmt"
 	"maps"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/mia-platform/jpl/pkg/client/cache"
 	"github.com/mia-platform/jpl/pkg/mutator"
+	"github.com/mia-platform/jpl/pkg/resource"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -30,70 +36,96 @@ import (
 // updates and redeploy in case the content is changed
 type dependenciesMutator struct {
 	checksumsMap map[string]string
+	// pullSecrets contains the image pull secrets names of the ServiceAccounts found in the objects
+	pullSecrets map[string][]string
+	workloads   Workloads
 }
 
-// NewDependenciesMutator return a new mutator using ConfigMaps and Secrets found in objects
-func NewDependenciesMutator(objects []*unstructured.Unstructured) mutator.Interface {
+// NewDependenciesMutator return a new mutator using ConfigMaps, Secrets and ServiceAccounts found in objects, that
+// will handle the pods and the workloads. If secretsKey is not empty the checksums of the Secrets are calculated
+// with HMAC, so the annotation cannot be used for guessing their values.
+func NewDependenciesMutator(objects []*unstructured.Unstructured, workloads Workloads, secretsKey []byte) mutator.Interface {
 	checksumsMap := make(map[string]string)
+	pullSecrets := make(map[string][]string)
 
 	for _, obj := range objects {
 		switch obj.GroupVersionKind().GroupKind() {
 		case configMapGK:
 			maps.Copy(checksumsMap, checksumsFromConfigMap(obj))
 		case secretGK:
-			maps.Copy(checksumsMap, checksumsFromSecret(obj))
+			maps.Copy(checksumsMap, checksumsFromSecret(obj, secretsKey))
+		case serviceAccountGK:
+			key := checksumObjectKey(serviceAccountGK.Kind, obj.GetName(), obj.GetNamespace(), "")
+			checksumsMap[key] = checksumFromServiceAccount(obj)
+			pullSecrets[key] = pullSecretsFromServiceAccount(obj)
 		}
 	}
 
 	return &dependenciesMutator{
 		checksumsMap: checksumsMap,
+		pullSecrets:  pullSecrets,
+		workloads:    workloads,
 	}
 }
 
 // CanHandleResource implement mutator.Interface interface
 func (m *dependenciesMutator) CanHandleResource(obj *metav1.PartialObjectMetadata) bool {
-	if len(m.checksumsMap) == 0 {
+	if len(m.checksumsMap) == 0 && (obj == nil || !trackCredentials(obj.GetAnnotations())) {
 		return false
 	}
 
-	switch obj.GroupVersionKind().GroupKind() {
-	case deployGK:
-		return true
-	case dsGK:
-		return true
-	case stsGK:
-		return true
-	case podGK:
-		return true
-	}
-
-	return false
+	return m.workloads.handlePods(obj.GroupVersionKind().GroupKind())
 }
 
 // Mutate implement mutator.Interface interface
-func (m *dependenciesMutator) Mutate(obj *unstructured.Unstructured, _ cache.RemoteResourceGetter) error {
-	podSpecFields, podAnnotationsFields, err := podFieldsForGroupKind(obj.GroupVersionKind())
+func (m *dependenciesMutator) Mutate(obj *unstructured.Unstructured, getter cache.RemoteResourceGetter) error {
+	podSpecFields, podAnnotationsFields, err := m.workloads.podFields(obj.GroupVersionKind())
+	if err != nil || !hasPodSpec(obj, podSpecFields) {
+		return err
+	}
+
+	checksums, err := m.checksumsForWorkload(obj, podSpecFields, getter)
+	if err != nil || len(checksums) == 0 {
+		return err
+	}
+
+	annotations, err := annotationsFromUnstructuredFields(obj, podAnnotationsFields)
 	if err != nil {
 		return err
 	}
 
+	annotations[checksumAnnotation] = ChecksumFromData(checksums)
+	return unstructured.SetNestedStringMap(obj.Object, annotations, podAnnotationsFields...)
+}
+
+// checksumsForWorkload return the checksums of the dependencies used by the pod spec of obj found at podSpecFields,
+// without the ones ignored via annotation
+func (m *dependenciesMutator) checksumsForWorkload(obj *unstructured.Unstructured, podSpecFields []string, getter cache.RemoteResourceGetter) (map[string]string, error) {
 	podSpec, err := podSpecFromUnstructured(obj, podSpecFields)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	checksums := m.checksumsForPodSpec(podSpec, obj.GetNamespace())
-	if len(checksums) == 0 {
-		return nil
+	if trackCredentials(obj.GetAnnotations()) {
+		credentialsChecksums, err := m.checksumsForCredentials(podSpec, obj.GetNamespace(), getter)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(checksums, credentialsChecksums)
 	}
 
-	annotations, err := annotationsFromUnstructuredFields(obj, podAnnotationsFields)
+	ignored, err := ignoredDependencies(obj.GetAnnotations(), obj.GetNamespace())
 	if err != nil {
-		return err
+		return nil, err
 	}
+	maps.DeleteFunc(checksums, func(key string, _ string) bool {
+		return slices.ContainsFunc(ignored, func(prefix string) bool {
+			return key == prefix || strings.HasPrefix(key, prefix+":")
+		})
+	})
 
-	annotations[checksumAnnotation] = ChecksumFromData(checksums)
-	return unstructured.SetNestedStringMap(obj.Object, annotations, podAnnotationsFields...)
+	return checksums, nil
 }
 
 // keep it to always check if dependenciesMutator implement correctly the mutator.Interface interface
@@ -128,7 +160,8 @@ func checksumsFromConfigMap(obj *unstructured.Unstructured) map[string]string {
 // checksumsFromSecret return a map of checksums, containing the full value of the secret,
 // and single checksums for every data and stringData present in the configmap.
 // The keys are the secret kind, name, namespace and key name if necessary.
-func checksumsFromSecret(obj *unstructured.Unstructured) map[string]string {
+// The checksums are calculated with HMAC using secretsKey, when it is not empty.
+func checksumsFromSecret(obj *unstructured.Unstructured, secretsKey []byte) map[string]string {
 	checksums := make(map[string]string)
 
 	sec := new(corev1.Secret)
@@ -139,19 +172,131 @@ func checksumsFromSecret(obj *unstructured.Unstructured) map[string]string {
 	totalData := make(map[string][]byte)
 	maps.Copy(totalData, sec.Data)
 	for key, value := range sec.Data {
-		checksums[checksumObjectKey(secretGK.Kind, obj.GetName(), obj.GetNamespace(), key)] = ChecksumFromData(value)
+		checksums[checksumObjectKey(secretGK.Kind, obj.GetName(), obj.GetNamespace(), key)] = HMACChecksumFromData(secretsKey, value)
 	}
 
 	for key, value := range sec.StringData {
-		checksums[checksumObjectKey(secretGK.Kind, obj.GetName(), obj.GetNamespace(), key)] = ChecksumFromData(value)
+		checksums[checksumObjectKey(secretGK.Kind, obj.GetName(), obj.GetNamespace(), key)] = HMACChecksumFromData(secretsKey, value)
 		totalData[key] = []byte(value)
 	}
 
-	checksums[checksumObjectKey(secretGK.Kind, obj.GetName(), obj.GetNamespace(), "")] = ChecksumFromData(totalData)
+	checksums[checksumObjectKey(secretGK.Kind, obj.GetName(), obj.GetNamespace(), "")] = HMACChecksumFromData(secretsKey, totalData)
 
 	return checksums
 }
 
+// checksumFromServiceAccount return a checksum of the ServiceAccount fields that are used by the pods
+func checksumFromServiceAccount(obj *unstructured.Unstructured) string {
+	data := make(map[string]interface{})
+	for _, field := range []string{"imagePullSecrets", "secrets", "automountServiceAccountToken"} {
+		if value, found := obj.Object[field]; found {
+			data[field] = value
+		}
+	}
+
+	return ChecksumFromData(data)
+}
+
+// pullSecretsFromServiceAccount return the names of the image pull secrets referenced by the ServiceAccount
+func pullSecretsFromServiceAccount(obj *unstructured.Unstructured) []string {
+	serviceAccount := new(corev1.ServiceAccount)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, serviceAccount); err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(serviceAccount.ImagePullSecrets))
+	for _, reference := range serviceAccount.ImagePullSecrets {
+		names = append(names, reference.Name)
+	}
+	return names
+}
+
+// trackCredentials return true if annotations enable the tracking of ServiceAccount and image pull secrets
+func trackCredentials(annotations map[string]string) bool {
+	enabled, err := strconv.ParseBool(annotations[trackCredentialsAnnotation])
+	return err == nil && enabled
+}
+
+// ignoredDependencies return the checksum keys of the dependencies listed in the ignore-dependencies annotation, as
+// comma separated kind/name pairs, that must not trigger a rollout of the workload when they change
+func ignoredDependencies(annotations map[string]string, namespace string) ([]string, error) {
+	value := strings.TrimSpace(annotations[ignoreDependenciesAnnotation])
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	kinds := map[string]string{
+		strings.ToLower(configMapGK.Kind):      configMapGK.Kind,
+		strings.ToLower(secretGK.Kind):         secretGK.Kind,
+		strings.ToLower(serviceAccountGK.Kind): serviceAccountGK.Kind,
+	}
+
+	keys := make([]string, 0)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		kind, name, found := strings.Cut(entry, "/")
+		checksumKind, validKind := kinds[strings.ToLower(kind)]
+		if !found || !validKind || len(name) == 0 {
+			return nil, fmt.Errorf("invalid value %q in %s annotation: must be a comma separated list of configmap/name, secret/name or serviceaccount/name", entry, ignoreDependenciesAnnotation)
+		}
+		keys = append(keys, checksumObjectKey(checksumKind, name, namespace, ""))
+	}
+
+	return keys, nil
+}
+
+// checksumsForCredentials return the checksums of the ServiceAccount and the image pull secrets used by pod, if
+// the ServiceAccount is not found in the objects its remote uid is used, so a recreation will trigger a rollout
+func (m *dependenciesMutator) checksumsForCredentials(pod corev1.PodSpec, namespace string, getter cache.RemoteResourceGetter) (map[string]string, error) {
+	secKind := secretGK.Kind
+	dependencies := make([]string, 0)
+	for _, reference := range pod.ImagePullSecrets {
+		dependencies = append(dependencies, checksumObjectKey(secKind, reference.Name, namespace, ""))
+	}
+
+	checksums := make(map[string]string)
+	serviceAccountName := pod.ServiceAccountName
+	if len(serviceAccountName) == 0 {
+		serviceAccountName = pod.DeprecatedServiceAccount
+	}
+	if len(serviceAccountName) == 0 {
+		serviceAccountName = "default"
+	}
+
+	serviceAccountKey := checksumObjectKey(serviceAccountGK.Kind, serviceAccountName, namespace, "")
+	pullSecrets, found := m.pullSecrets[serviceAccountKey]
+	switch {
+	case found:
+		dependencies = append(dependencies, serviceAccountKey)
+	case getter != nil:
+		remoteObj, err := getter.Get(context.Background(), resource.ObjectMetadata{
+			Kind:      serviceAccountGK.Kind,
+			Name:      serviceAccountName,
+			Namespace: namespace,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if remoteObj != nil {
+			checksums[checksumObjectKey(serviceAccountGK.Kind, serviceAccountName, namespace, "uid")] = string(remoteObj.GetUID())
+			pullSecrets = pullSecretsFromServiceAccount(remoteObj)
+		}
+	}
+
+	for _, name := range pullSecrets {
+		dependencies = append(dependencies, checksumObjectKey(secKind, name, namespace, ""))
+	}
+
+	for _, key := range dependencies {
+		if shasum, found := m.checksumsMap[key]; found {
+			checksums[key] = shasum
+		}
+	}
+
+	return checksums, nil
+}
+
 // checksumsForPodSpec
 func (m *dependenciesMutator) checksumsForPodSpec(pod corev1.PodSpec, namespace string) map[string]string {
 	dependencies := make([]string, 0)
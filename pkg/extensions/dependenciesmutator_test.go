@@ -16,14 +16,18 @@
 package extensions
 
 import (
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/mia-platform/jpl/pkg/resource"
 	jpltesting "github.com/mia-platform/jpl/pkg/testing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func TestNewDependenciesMutator(t *testing.T) {
@@ -43,6 +47,16 @@ func TestNewDependenciesMutator(t *testing.T) {
 			},
 			expectedMap: make(map[string]string),
 		},
+		"immutable configmap": {
+			objects: []*unstructured.Unstructured{
+				jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "immutable-configmap.yaml")),
+			},
+			expectedMap: map[string]string{
+				"ConfigMap:example:test":         "474402695ca63dd67a8ee93690d46011d2e19181aeb10c616af3cb48ac36adad",
+				"ConfigMap:example:test:bconfig": "f2ad8ef38c3f6fac4d0dcfa67696710c04bc88f92a54f6758cb43c0d392b3eea",
+				"ConfigMap:example:test:config":  "3f564266de9477b004c53c67de5eb4ec7cedb6dcee5b3d6d77ca2ed6cdd323ca",
+			},
+		},
 		"mixed objects": {
 			objects: []*unstructured.Unstructured{
 				jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "deployment.yaml")),
@@ -65,7 +79,7 @@ func TestNewDependenciesMutator(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			m := NewDependenciesMutator(test.objects)
+			m := NewDependenciesMutator(test.objects, nil, nil)
 			dm, ok := m.(*dependenciesMutator)
 			require.True(t, ok)
 			assert.Equal(t, test.expectedMap, dm.checksumsMap)
@@ -73,6 +87,36 @@ func TestNewDependenciesMutator(t *testing.T) {
 	}
 }
 
+func TestDependenciesMutatorSecretsKey(t *testing.T) {
+	t.Parallel()
+	testdata := filepath.Join("testdata", "dependency-mutator")
+
+	objects := []*unstructured.Unstructured{
+		jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "configmap.yaml")),
+		jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "secret.yaml")),
+	}
+	checksumsMap := func(key []byte) map[string]string {
+		t.Helper()
+		dm, ok := NewDependenciesMutator(objects, nil, key).(*dependenciesMutator)
+		require.True(t, ok)
+		return dm.checksumsMap
+	}
+
+	plain := checksumsMap(nil)
+	keyed := checksumsMap([]byte("secret-key"))
+	assert.Equal(t, keyed, checksumsMap([]byte("secret-key")))
+	assert.Len(t, keyed, len(plain))
+	for key, value := range keyed {
+		switch strings.HasPrefix(key, secretGK.Kind+":") {
+		case true:
+			assert.NotEqual(t, plain[key], value, key)
+			assert.NotEqual(t, checksumsMap([]byte("other-key"))[key], value, key)
+		default:
+			assert.Equal(t, plain[key], value, key)
+		}
+	}
+}
+
 func TestDependenciesMutatorCanHandleResource(t *testing.T) {
 	t.Parallel()
 
@@ -185,6 +229,10 @@ func TestDependenciesMutatorMutate(t *testing.T) {
 			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "pod.yaml")),
 			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-pod.yaml")),
 		},
+		"rollout": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "rollout.yaml")),
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-rollout.yaml")),
+		},
 		"wrong resource": {
 			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "wrong-resource.yaml")),
 			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "wrong-resource.yaml")),
@@ -209,3 +257,156 @@ func TestDependenciesMutatorMutate(t *testing.T) {
 		})
 	}
 }
+
+func TestDependenciesMutatorIgnoreDependencies(t *testing.T) {
+	t.Parallel()
+
+	testdata := filepath.Join("testdata", "dependency-mutator")
+	checksumsMap := map[string]string{
+		"ConfigMap:example:test":        "474402695ca63dd67a8ee93690d46011d2e19181aeb10c616af3cb48ac36adad",
+		"ConfigMap:example:test:config": "3f564266de9477b004c53c67de5eb4ec7cedb6dcee5b3d6d77ca2ed6cdd323ca",
+		"Secret:example:test":           "f8ac3c753041ab4d641d61751aaf9a9422faf88b14b727789a0319fe872ee418",
+		"Secret:example:test:data":      "355c838b5878c899babc73dbde367b0f450f37c41e5fec9c4af0a86900086b72",
+	}
+
+	tests := map[string]struct {
+		annotation       string
+		expectedChecksum string
+		expectedError    string
+	}{
+		"ignore configmap": {
+			annotation:       "configmap/example",
+			expectedChecksum: ChecksumFromData(map[string]string{"Secret:example:test:data": checksumsMap["Secret:example:test:data"]}),
+		},
+		"ignore other resources": {
+			annotation: "ConfigMap/other, secret/other",
+			expectedChecksum: ChecksumFromData(map[string]string{
+				"ConfigMap:example:test":   checksumsMap["ConfigMap:example:test"],
+				"Secret:example:test:data": checksumsMap["Secret:example:test:data"],
+			}),
+		},
+		"ignore all dependencies": {
+			annotation: "configmap/example,secret/example",
+		},
+		"invalid kind": {
+			annotation:    "configmap/example,deployment/example",
+			expectedError: `invalid value "deployment/example" in mia-platform.eu/ignore-dependencies annotation`,
+		},
+		"missing name": {
+			annotation:    "secret",
+			expectedError: `invalid value "secret" in mia-platform.eu/ignore-dependencies annotation`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			obj := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "deployment.yaml"))
+			obj.SetAnnotations(map[string]string{ignoreDependenciesAnnotation: test.annotation})
+			mutator := &dependenciesMutator{checksumsMap: checksumsMap}
+
+			err := mutator.Mutate(obj, nil)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			checksum, found, err := unstructured.NestedString(obj.Object, "spec", "template", "metadata", "annotations", checksumAnnotation)
+			require.NoError(t, err)
+			assert.Equal(t, len(test.expectedChecksum) > 0, found)
+			assert.Equal(t, test.expectedChecksum, checksum)
+		})
+	}
+}
+
+func TestDependenciesMutatorCredentials(t *testing.T) {
+	t.Parallel()
+
+	testdata := filepath.Join("testdata", "dependency-mutator")
+	serviceAccountID := resource.ObjectMetadata{Kind: "ServiceAccount", Name: "example", Namespace: "test"}
+	remoteServiceAccount := func(uid string) *unstructured.Unstructured {
+		obj := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "serviceaccount.yaml"))
+		obj.SetUID(types.UID(uid))
+		return obj
+	}
+	checksum := func(t *testing.T, objects []*unstructured.Unstructured, getter *testGetter, tracking bool) string {
+		t.Helper()
+
+		obj := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "credentials-deployment.yaml"))
+		if !tracking {
+			obj.SetAnnotations(nil)
+		}
+
+		m := NewDependenciesMutator(objects, nil, nil)
+		if !m.CanHandleResource(&metav1.PartialObjectMetadata{TypeMeta: metav1.TypeMeta{Kind: obj.GetKind(), APIVersion: obj.GetAPIVersion()}, ObjectMeta: metav1.ObjectMeta{Annotations: obj.GetAnnotations()}}) {
+			return ""
+		}
+
+		require.NoError(t, m.Mutate(obj, getter))
+		value, _, err := unstructured.NestedString(obj.Object, "spec", "template", "metadata", "annotations", checksumAnnotation)
+		require.NoError(t, err)
+		return value
+	}
+	withData := func(path, data string) *unstructured.Unstructured {
+		obj := jpltesting.UnstructuredFromFile(t, path)
+		require.NoError(t, unstructured.SetNestedField(obj.Object, data, "data", ".dockerconfigjson"))
+		return obj
+	}
+
+	serviceAccount := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "serviceaccount.yaml"))
+	pullSecret := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "pull-secret.yaml"))
+	otherPullSecret := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "other-pull-secret.yaml"))
+	objects := []*unstructured.Unstructured{serviceAccount, pullSecret, otherPullSecret}
+	baseChecksum := checksum(t, objects, nil, true)
+	require.NotEmpty(t, baseChecksum)
+
+	t.Run("tracking disabled", func(t *testing.T) {
+		t.Parallel()
+		assert.Empty(t, checksum(t, objects, nil, false))
+		assert.Empty(t, checksum(t, []*unstructured.Unstructured{serviceAccount, withData(filepath.Join(testdata, "pull-secret.yaml"), "e30=")}, nil, false))
+	})
+
+	t.Run("rotating the pod image pull secret", func(t *testing.T) {
+		t.Parallel()
+		rotated := []*unstructured.Unstructured{serviceAccount, withData(filepath.Join(testdata, "pull-secret.yaml"), "e30="), otherPullSecret}
+		assert.NotEqual(t, baseChecksum, checksum(t, rotated, nil, true))
+	})
+
+	t.Run("rotating the service account image pull secret", func(t *testing.T) {
+		t.Parallel()
+		rotated := []*unstructured.Unstructured{serviceAccount, pullSecret, withData(filepath.Join(testdata, "other-pull-secret.yaml"), "e30=")}
+		assert.NotEqual(t, baseChecksum, checksum(t, rotated, nil, true))
+	})
+
+	t.Run("changing the service account", func(t *testing.T) {
+		t.Parallel()
+		changedServiceAccount := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "serviceaccount.yaml"))
+		changedServiceAccount.Object["automountServiceAccountToken"] = false
+		changed := []*unstructured.Unstructured{changedServiceAccount, pullSecret, otherPullSecret}
+		assert.NotEqual(t, baseChecksum, checksum(t, changed, nil, true))
+	})
+
+	t.Run("remote service account recreated", func(t *testing.T) {
+		t.Parallel()
+		secrets := []*unstructured.Unstructured{pullSecret, otherPullSecret}
+		checksums := make([]string, 0)
+		for _, uid := range []string{"first-uid", "second-uid"} {
+			getter := &testGetter{availableObjects: map[resource.ObjectMetadata]*unstructured.Unstructured{
+				serviceAccountID: remoteServiceAccount(uid),
+			}}
+			checksums = append(checksums, checksum(t, secrets, getter, true))
+		}
+		assert.NotEqual(t, checksums[0], checksums[1])
+		assert.NotEqual(t, checksum(t, secrets, &testGetter{}, true), checksums[0])
+	})
+
+	t.Run("error reading remote service account", func(t *testing.T) {
+		t.Parallel()
+		obj := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "credentials-deployment.yaml"))
+		getter := &testGetter{errors: map[resource.ObjectMetadata]error{serviceAccountID: fmt.Errorf("remote error")}}
+		m := NewDependenciesMutator([]*unstructured.Unstructured{pullSecret}, nil, nil)
+		assert.ErrorContains(t, m.Mutate(obj, getter), "remote error")
+	})
+}
@@ -0,0 +1,59 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"encoding/json"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DependentRestartPatch return the JSON merge patch that update the dependencies checksum annotation in the pod
+// template of the live workload obj, if it use one of the changed ConfigMaps and Secrets following the same rules
+// of the dependencies mutator; if obj doesn't use any of them a nil patch is returned.
+// The new checksum is calculated from the current one and the checksums of the changed dependencies, so the
+// annotation value always change and a new rollout is triggered.
+func DependentRestartPatch(obj *unstructured.Unstructured, changed []*unstructured.Unstructured, workloads Workloads, secretsKey []byte) ([]byte, error) {
+	if !workloads.handlePods(obj.GroupVersionKind().GroupKind()) || obj.GroupVersionKind().GroupKind() == podGK {
+		return nil, nil
+	}
+
+	podSpecFields, podAnnotationsFields, err := workloads.podFields(obj.GroupVersionKind())
+	if err != nil || !hasPodSpec(obj, podSpecFields) {
+		return nil, err
+	}
+
+	m := NewDependenciesMutator(changed, workloads, secretsKey).(*dependenciesMutator)
+	checksums, err := m.checksumsForWorkload(obj, podSpecFields, nil)
+	if err != nil || len(checksums) == 0 {
+		return nil, err
+	}
+
+	annotations, err := annotationsFromUnstructuredFields(obj, podAnnotationsFields)
+	if err != nil {
+		return nil, err
+	}
+	checksums["current"] = annotations[checksumAnnotation]
+
+	patch := make(map[string]interface{})
+	fields := slices.Concat(podAnnotationsFields, []string{checksumAnnotation})
+	if err := unstructured.SetNestedField(patch, ChecksumFromData(checksums), fields...); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(patch)
+}
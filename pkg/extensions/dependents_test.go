@@ -0,0 +1,131 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDependentRestartPatch(t *testing.T) {
+	t.Parallel()
+
+	workload := func(kind, apiVersion string, annotations map[string]string) *unstructured.Unstructured {
+		podSpec := map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "example", "image": "busybox"}},
+			"volumes": []interface{}{
+				map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": "config"}},
+			},
+		}
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": "example", "namespace": "test"},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{"annotations": map[string]interface{}{checksumAnnotation: "current"}},
+					"spec":     podSpec,
+				},
+			},
+		}}
+		if annotations != nil {
+			obj.SetAnnotations(annotations)
+		}
+		if kind == podGK.Kind {
+			obj.Object["spec"] = podSpec
+		}
+		return obj
+	}
+	configMap := func(name string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "test"},
+			"data":       map[string]interface{}{"key": "value"},
+		}}
+	}
+
+	tests := map[string]struct {
+		obj           *unstructured.Unstructured
+		changed       []*unstructured.Unstructured
+		expectedPatch bool
+	}{
+		"deployment using a changed configmap": {
+			obj:           workload("Deployment", "apps/v1", nil),
+			changed:       []*unstructured.Unstructured{configMap("config")},
+			expectedPatch: true,
+		},
+		"deployment not using the changed configmaps": {
+			obj:     workload("Deployment", "apps/v1", nil),
+			changed: []*unstructured.Unstructured{configMap("other")},
+		},
+		"deployment ignoring the changed configmap": {
+			obj:     workload("Deployment", "apps/v1", map[string]string{ignoreDependenciesAnnotation: "configmap/config"}),
+			changed: []*unstructured.Unstructured{configMap("config")},
+		},
+		"pod cannot be restarted": {
+			obj:     workload("Pod", "v1", nil),
+			changed: []*unstructured.Unstructured{configMap("config")},
+		},
+		"not a workload": {
+			obj:     configMap("config"),
+			changed: []*unstructured.Unstructured{configMap("config")},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := DependentRestartPatch(test.obj, test.changed, nil, nil)
+			require.NoError(t, err)
+			if !test.expectedPatch {
+				assert.Nil(t, patch)
+				return
+			}
+
+			patchMap := make(map[string]interface{})
+			require.NoError(t, json.Unmarshal(patch, &patchMap))
+			checksum, found, err := unstructured.NestedString(patchMap, "spec", "template", "metadata", "annotations", checksumAnnotation)
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.NotEqual(t, "current", checksum)
+		})
+	}
+}
+
+func TestWorkloadsGroupKinds(t *testing.T) {
+	t.Parallel()
+
+	workloads, err := NewWorkloads([]WorkloadDefinition{
+		{Group: "apps.example.com", Kind: "Workload", PodTemplatePath: "spec.podTemplate"},
+		{Group: "apps", Kind: "Deployment", PodTemplatePath: "spec.template"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []schema.GroupKind{
+		{Group: "apps", Kind: "DaemonSet"},
+		{Group: "apps", Kind: "Deployment"},
+		{Group: "argoproj.io", Kind: "Rollout"},
+		{Group: "apps", Kind: "StatefulSet"},
+		{Group: "apps.example.com", Kind: "Workload"},
+	}, workloads.GroupKinds())
+}
@@ -0,0 +1,147 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mia-platform/jpl/pkg/client/cache"
+	"github.com/mia-platform/jpl/pkg/mutator"
+	"github.com/mia-platform/jpl/pkg/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// DependsOnAnnotation contains a comma separated list of Kind/name or Kind.group/name references to the
+	// resources that must exist and be ready before applying the annotated one
+	DependsOnAnnotation = miaPlatformPrefix + "depends-on"
+)
+
+// DependsOnReference identify a resource referenced in the depends-on annotation, the group is empty if it has
+// not been specified
+type DependsOnReference struct {
+	Kind      string
+	Group     string
+	Name      string
+	Namespace string
+}
+
+// String implement fmt.Stringer interface
+func (r DependsOnReference) String() string {
+	if len(r.Group) > 0 {
+		return r.Kind + "." + r.Group + "/" + r.Name
+	}
+	return r.Kind + "/" + r.Name
+}
+
+// Matches return true if obj is the resource identified by the reference, a namespaced reference also matches the
+// cluster scoped resources with the same kind and name
+func (r DependsOnReference) Matches(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	if gvk.Kind != r.Kind || obj.GetName() != r.Name || (len(r.Group) > 0 && gvk.Group != r.Group) {
+		return false
+	}
+
+	return len(obj.GetNamespace()) == 0 || obj.GetNamespace() == r.Namespace
+}
+
+// DependsOnReferences return the references found in the depends-on annotation of obj, or an error if one of them
+// is malformed
+func DependsOnReferences(obj *unstructured.Unstructured) ([]DependsOnReference, error) {
+	value, found := obj.GetAnnotations()[DependsOnAnnotation]
+	if !found {
+		return nil, nil
+	}
+
+	references := make([]DependsOnReference, 0)
+	for _, reference := range strings.Split(value, ",") {
+		reference = strings.TrimSpace(reference)
+		if len(reference) == 0 {
+			continue
+		}
+
+		kind, name, found := strings.Cut(reference, "/")
+		if !found || len(kind) == 0 || len(name) == 0 || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid reference %q in %s annotation of %s %q: must be in the form Kind/name or Kind.group/name", reference, DependsOnAnnotation, obj.GetKind(), obj.GetName())
+		}
+
+		kind, group, _ := strings.Cut(kind, ".")
+		references = append(references, DependsOnReference{
+			Kind:      kind,
+			Group:     group,
+			Name:      name,
+			Namespace: obj.GetNamespace(),
+		})
+	}
+
+	return references, nil
+}
+
+// dependsOnMutator will implement a mutator that translate the depends-on annotation references to resources that
+// are part of the deploy in explicit dependencies, for applying them only after the referenced ones are ready
+type dependsOnMutator struct {
+	resources []*unstructured.Unstructured
+}
+
+// NewDependsOnMutator return a new mutator that will order the apply of the resources with the depends-on
+// annotation after the ones they reference found in resources; the references to resources that are not
+// deployed are ignored and must be checked before the apply
+func NewDependsOnMutator(resources []*unstructured.Unstructured) mutator.Interface {
+	return &dependsOnMutator{resources: resources}
+}
+
+// CanHandleResource implement mutator.Interface interface
+func (m *dependsOnMutator) CanHandleResource(obj *metav1.PartialObjectMetadata) bool {
+	if obj == nil {
+		return false
+	}
+
+	_, found := obj.GetAnnotations()[DependsOnAnnotation]
+	return found
+}
+
+// Mutate implement mutator.Interface interface
+func (m *dependsOnMutator) Mutate(obj *unstructured.Unstructured, _ cache.RemoteResourceGetter) error {
+	references, err := DependsOnReferences(obj)
+	if err != nil {
+		return err
+	}
+
+	dependencies, err := resource.ObjectExplicitDependencies(obj)
+	if err != nil {
+		return err
+	}
+
+	for _, reference := range references {
+		index := slices.IndexFunc(m.resources, reference.Matches)
+		if index == -1 {
+			continue
+		}
+
+		dependency := resource.ObjectMetadataFromUnstructured(m.resources[index])
+		if !slices.Contains(dependencies, dependency) {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+
+	return resource.SetObjectExplicitDependencies(obj, dependencies)
+}
+
+// keep it to always check if dependsOnMutator implement correctly the mutator.Interface interface
+var _ mutator.Interface = &dependsOnMutator{}
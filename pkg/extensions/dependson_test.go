@@ -0,0 +1,140 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func dependsOnObject(apiVersion, kind, name, namespace, dependsOn string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+	if len(dependsOn) > 0 {
+		obj.SetAnnotations(map[string]string{DependsOnAnnotation: dependsOn})
+	}
+	return obj
+}
+
+func TestDependsOnReferences(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		obj                *unstructured.Unstructured
+		expectedReferences []DependsOnReference
+		expectedError      string
+	}{
+		"without annotation": {
+			obj: dependsOnObject("apps/v1", "Deployment", "app", "test", ""),
+		},
+		"multiple references": {
+			obj: dependsOnObject("apps/v1", "Deployment", "app", "test", "Postgres/database, Secret/credentials,,Cluster.postgresql.cnpg.io/main"),
+			expectedReferences: []DependsOnReference{
+				{Kind: "Postgres", Name: "database", Namespace: "test"},
+				{Kind: "Secret", Name: "credentials", Namespace: "test"},
+				{Kind: "Cluster", Group: "postgresql.cnpg.io", Name: "main", Namespace: "test"},
+			},
+		},
+		"missing name": {
+			obj:           dependsOnObject("apps/v1", "Deployment", "app", "test", "Postgres"),
+			expectedError: `invalid reference "Postgres" in mia-platform.eu/depends-on annotation of Deployment "app": must be in the form Kind/name or Kind.group/name`,
+		},
+		"too many parts": {
+			obj:           dependsOnObject("apps/v1", "Deployment", "app", "test", "Secret/other/credentials"),
+			expectedError: `invalid reference "Secret/other/credentials" in mia-platform.eu/depends-on annotation of Deployment "app": must be in the form Kind/name or Kind.group/name`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			references, err := DependsOnReferences(test.obj)
+			if len(test.expectedError) > 0 {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedReferences, references)
+		})
+	}
+}
+
+func TestDependsOnMutator(t *testing.T) {
+	t.Parallel()
+
+	database := dependsOnObject("example.com/v1", "Postgres", "database", "test", "")
+	namespace := dependsOnObject("v1", "Namespace", "test", "", "")
+	otherNamespace := dependsOnObject("example.com/v1", "Postgres", "database", "other", "")
+
+	tests := map[string]struct {
+		obj                  *unstructured.Unstructured
+		expectedDependencies []resource.ObjectMetadata
+		expectedError        string
+	}{
+		"reference to deployed resources": {
+			obj: dependsOnObject("apps/v1", "Deployment", "app", "test", "Postgres.example.com/database, Namespace/test"),
+			expectedDependencies: []resource.ObjectMetadata{
+				{Group: "example.com", Kind: "Postgres", Name: "database", Namespace: "test"},
+				{Kind: "Namespace", Name: "test"},
+			},
+		},
+		"references to external resources are ignored": {
+			obj:                  dependsOnObject("apps/v1", "Deployment", "app", "test", "Postgres/missing, Postgres.other.io/database"),
+			expectedDependencies: []resource.ObjectMetadata{},
+		},
+		"resources in other namespaces are not matched": {
+			obj: dependsOnObject("apps/v1", "Deployment", "app", "other-app", "Postgres/database"),
+		},
+		"malformed reference": {
+			obj:           dependsOnObject("apps/v1", "Deployment", "app", "test", "database"),
+			expectedError: `invalid reference "database" in mia-platform.eu/depends-on annotation of Deployment "app": must be in the form Kind/name or Kind.group/name`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mutator := NewDependsOnMutator([]*unstructured.Unstructured{database, namespace, otherNamespace})
+			metadata := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Annotations: test.obj.GetAnnotations()}}
+			require.True(t, mutator.CanHandleResource(metadata))
+
+			err := mutator.Mutate(test.obj, nil)
+			if len(test.expectedError) > 0 {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			dependencies, err := resource.ObjectExplicitDependencies(test.obj)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, test.expectedDependencies, dependencies)
+		})
+	}
+
+	t.Run("resources without annotation are not handled", func(t *testing.T) {
+		t.Parallel()
+		assert.False(t, NewDependsOnMutator(nil).CanHandleResource(&metav1.PartialObjectMetadata{}))
+	})
+}
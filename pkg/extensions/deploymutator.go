@@ -17,10 +17,7 @@ package extensions
 
 import (
 	"context"
-	"fmt"
 
-	"github.com/blang/semver/v4"
-	dockerref "github.com/distribution/reference"
 	"github.com/mia-platform/jpl/pkg/client/cache"
 	"github.com/mia-platform/jpl/pkg/mutator"
 	"github.com/mia-platform/jpl/pkg/resource"
@@ -40,33 +37,24 @@ type deployMutator struct {
 	deployType    string
 	forceNoSemver bool
 	identifier    string
+	workloads     Workloads
 }
 
-// NewDeployMutator return a new deploy mutator with the given deployment configurations
-func NewDeployMutator(deployType string, forceNoSemver bool, deploymentIdentifier string) mutator.Interface {
+// NewDeployMutator return a new deploy mutator with the given deployment configurations, that will handle the
+// pods and the workloads
+func NewDeployMutator(deployType string, forceNoSemver bool, deploymentIdentifier string, workloads Workloads) mutator.Interface {
 	return &deployMutator{
 		deployType:    deployType,
 		forceNoSemver: forceNoSemver,
 		identifier:    deploymentIdentifier,
+		workloads:     workloads,
 	}
 }
 
 // CanHandleResource implement mutator.Interface interface
 func (m *deployMutator) CanHandleResource(obj *metav1.PartialObjectMetadata) bool {
-	switch obj.GroupVersionKind().GroupKind() {
-	case deployGK:
-		return true
-	case dsGK:
-		return true
-	case stsGK:
-		return true
-	case podGK:
-		return true
-	case extsecGK:
-		return true
-	}
-
-	return false
+	gk := obj.GroupVersionKind().GroupKind()
+	return gk == extsecGK || m.workloads.handlePods(gk)
 }
 
 // Mutate implement mutator.Interface interface
@@ -81,8 +69,8 @@ func (m *deployMutator) Mutate(obj *unstructured.Unstructured, getter cache.Remo
 		return unstructured.SetNestedStringMap(obj.Object, annotations, extSecAnnotationsFields...)
 	}
 
-	podSpecFields, podAnnotationsFields, err := podFieldsForGroupKind(obj.GroupVersionKind())
-	if err != nil {
+	podSpecFields, podAnnotationsFields, err := m.workloads.podFields(obj.GroupVersionKind())
+	if err != nil || !hasPodSpec(obj, podSpecFields) {
 		return err
 	}
 
@@ -149,17 +137,9 @@ func (m *deployMutator) checkNoSemverInPod(obj *unstructured.Unstructured, podSp
 
 	checkNoSemverTagInPod := func(containers []corev1.Container) (bool, error) {
 		for _, container := range containers {
-			tag, digest, err := parseImageTag(container.Image)
-			if err != nil {
-				return false, err
-			}
-
-			if len(digest) != 0 {
-				return false, nil
-			}
-
-			if _, err := semver.ParseTolerant(tag); err != nil {
-				return true, nil
+			notUsingSemver, err := IsNotUsingSemver(container.Image)
+			if notUsingSemver || err != nil {
+				return notUsingSemver, err
 			}
 		}
 
@@ -173,30 +153,5 @@ func (m *deployMutator) checkNoSemverInPod(obj *unstructured.Unstructured, podSp
 	return checkNoSemverTagInPod(podSpec.Containers)
 }
 
-// parseImageTag return tag and digest for the given image name string.
-// The function will also return "latest" as tag if the name string has no tag defined
-func parseImageTag(image string) (string, string, error) {
-	named, err := dockerref.ParseNormalizedNamed(image)
-	if err != nil {
-		return "", "", fmt.Errorf("couldn't parse image name %q: %w", image, err)
-	}
-
-	var tag, digest string
-	tagged, ok := named.(dockerref.Tagged)
-	if ok {
-		tag = tagged.Tag()
-	}
-
-	digested, ok := named.(dockerref.Digested)
-	if ok {
-		digest = digested.Digest().String()
-	}
-	// If no tag was specified, use the default "latest".
-	if len(tag) == 0 && len(digest) == 0 {
-		tag = "latest"
-	}
-	return tag, digest, nil
-}
-
 // keep it to always check if deployMutator implement correctly the mutator.Interface interface
 var _ mutator.Interface = &deployMutator{}
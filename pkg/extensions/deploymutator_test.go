@@ -33,7 +33,7 @@ import (
 func TestNewDeployMutator(t *testing.T) {
 	t.Parallel()
 
-	mutator := NewDeployMutator(DeployAll, true, "identifier")
+	mutator := NewDeployMutator(DeployAll, true, "identifier", nil)
 	assert.NotNil(t, mutator)
 }
 
@@ -89,6 +89,24 @@ func TestDeployMutatorCanHandleResource(t *testing.T) {
 			},
 			expectedResult: true,
 		},
+		"rollout return true": {
+			obj: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       rolloutGK.Kind,
+					APIVersion: "argoproj.io/v1alpha1",
+				},
+			},
+			expectedResult: true,
+		},
+		"unknown custom resource return false": {
+			obj: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Workload",
+					APIVersion: "example.com/v1",
+				},
+			},
+			expectedResult: false,
+		},
 	}
 
 	for name, test := range tests {
@@ -153,6 +171,22 @@ func TestDeployMutatorMutate(t *testing.T) {
 			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "error-remote.yaml")),
 			expectedError:  "error from remote",
 		},
+		"rollout": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "rollout.yaml")),
+			deployType:     DeploySmart,
+			forceNoSemver:  true,
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-rollout.yaml")),
+		},
+		"rollout referencing a deployment": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "rollout-workload-ref.yaml")),
+			deployType:     DeployAll,
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "rollout-workload-ref.yaml")),
+		},
+		"custom workload": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "custom-workload.yaml")),
+			deployType:     DeployAll,
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-custom-workload.yaml")),
+		},
 		"wrong resource": {
 			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "wrong-resource.yaml")),
 			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "wrong-resource.yaml")),
@@ -173,6 +207,7 @@ func TestDeployMutatorMutate(t *testing.T) {
 				deployType:    test.deployType,
 				forceNoSemver: test.forceNoSemver,
 				identifier:    "test-identifier",
+				workloads:     Workloads{{Group: "example.com", Kind: "Workload"}: {"spec", "podTemplate"}},
 			}
 
 			getter := &testGetter{
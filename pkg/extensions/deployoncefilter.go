@@ -36,19 +36,7 @@ func NewDeployOnceFilter() filter.Interface {
 
 // Filter implement filter.Interface interface
 func (f *deployOnceFilter) Filter(obj *unstructured.Unstructured, getter cache.RemoteResourceGetter) (bool, error) {
-	objGK := obj.GroupVersionKind().GroupKind()
-
-	switch objGK {
-	case configMapGK, secretGK:
-		annotations := obj.GetAnnotations()
-		if annotations == nil {
-			return false, nil
-		}
-
-		if value, found := annotations[deployFilterAnnotation]; !found || value != deployFilterValue {
-			return false, nil
-		}
-	default:
+	if !IsDeployOnce(obj) {
 		return false, nil
 	}
 
@@ -56,5 +44,15 @@ func (f *deployOnceFilter) Filter(obj *unstructured.Unstructured, getter cache.R
 	return remoteObj != nil, err
 }
 
+// IsDeployOnce return true if obj is a Secret or ConfigMap that must be applied only if it doesn't already exist
+func IsDeployOnce(obj *unstructured.Unstructured) bool {
+	switch obj.GroupVersionKind().GroupKind() {
+	case configMapGK, secretGK:
+		return obj.GetAnnotations()[deployFilterAnnotation] == deployFilterValue
+	default:
+		return false
+	}
+}
+
 // keep it to always check if deployOnceFilter implement correctly the filter.Interface interface
 var _ filter.Interface = &deployOnceFilter{}
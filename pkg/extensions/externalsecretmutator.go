@@ -33,9 +33,10 @@ import (
 type externalSecretsMutator struct {
 	externalSecretMap map[string]*unstructured.Unstructured
 	secretsStores     map[string]*unstructured.Unstructured
+	workloads         Workloads
 }
 
-func NewExternalSecretsMutator(objects []*unstructured.Unstructured) mutator.Interface {
+func NewExternalSecretsMutator(objects []*unstructured.Unstructured, workloads Workloads) mutator.Interface {
 	externalSecretMap := make(map[string]*unstructured.Unstructured)
 	secretsStores := make(map[string]*unstructured.Unstructured)
 
@@ -51,6 +52,7 @@ func NewExternalSecretsMutator(objects []*unstructured.Unstructured) mutator.Int
 	return &externalSecretsMutator{
 		externalSecretMap: externalSecretMap,
 		secretsStores:     secretsStores,
+		workloads:         workloads,
 	}
 }
 
@@ -60,20 +62,8 @@ func (m *externalSecretsMutator) CanHandleResource(obj *metav1.PartialObjectMeta
 		return false
 	}
 
-	switch obj.GroupVersionKind().GroupKind() {
-	case deployGK:
-		return true
-	case dsGK:
-		return true
-	case stsGK:
-		return true
-	case podGK:
-		return true
-	case extsecGK:
-		return true
-	}
-
-	return false
+	gk := obj.GroupVersionKind().GroupKind()
+	return gk == extsecGK || m.workloads.handlePods(gk)
 }
 
 // Mutate implement mutator.Interface interface
@@ -82,8 +72,8 @@ func (m *externalSecretsMutator) Mutate(obj *unstructured.Unstructured, _ cache.
 		return m.annotateExternalSecret(obj)
 	}
 
-	podSpecFields, _, err := podFieldsForGroupKind(obj.GroupVersionKind())
-	if err != nil {
+	podSpecFields, _, err := m.workloads.podFields(obj.GroupVersionKind())
+	if err != nil || !hasPodSpec(obj, podSpecFields) {
 		return err
 	}
 
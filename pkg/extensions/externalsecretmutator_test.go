@@ -75,7 +75,7 @@ func TestNewExternalSecretMutator(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			m := NewExternalSecretsMutator(test.objects)
+			m := NewExternalSecretsMutator(test.objects, nil)
 			esm, ok := m.(*externalSecretsMutator)
 			require.True(t, ok)
 			assert.Equal(t, test.expectedExternalSeceretMap, esm.externalSecretMap)
@@ -0,0 +1,85 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"github.com/mia-platform/jpl/pkg/resource"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// GeneratedInventoryName is the name of the ConfigMap that keep track of the resources created by the
+	// generate command
+	GeneratedInventoryName = "eu.mia-platform.mlp.generated"
+
+	generatedInventoryLabel = miaPlatformPrefix + "generated-inventory"
+)
+
+// NewGeneratedInventory return a ConfigMap that keep track of objects, it can be deployed alongside them for
+// allowing to prune the ones that will not be generated anymore
+func NewGeneratedInventory(objects []resource.ObjectMetadata) *corev1.ConfigMap {
+	data := make(map[string]string, len(objects))
+	for _, obj := range objects {
+		data[obj.ToString()] = ""
+	}
+
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       configMapGK.Kind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: GeneratedInventoryName,
+			Labels: map[string]string{
+				generatedInventoryLabel: "true",
+			},
+		},
+		Data: data,
+	}
+}
+
+// IsGeneratedInventory return true if obj is a ConfigMap created with NewGeneratedInventory
+func IsGeneratedInventory(obj *unstructured.Unstructured) bool {
+	return obj.GroupVersionKind().GroupKind() == configMapGK &&
+		obj.GetName() == GeneratedInventoryName &&
+		obj.GetLabels()[generatedInventoryLabel] == "true"
+}
+
+// RemovedGeneratedResources return the resources tracked in previousData that are not present in currentData,
+// the data are the ones of two ConfigMaps created with NewGeneratedInventory. The resources without a namespace
+// will be returned in namespace.
+func RemovedGeneratedResources(currentData, previousData map[string]string, namespace string) []resource.ObjectMetadata {
+	removed := make([]resource.ObjectMetadata, 0)
+	for key := range previousData {
+		if _, found := currentData[key]; found {
+			continue
+		}
+
+		valid, objMeta := resource.ObjectMetadataFromString(key)
+		if !valid {
+			continue
+		}
+
+		if len(objMeta.Namespace) == 0 {
+			objMeta.Namespace = namespace
+		}
+		removed = append(removed, objMeta)
+	}
+
+	return removed
+}
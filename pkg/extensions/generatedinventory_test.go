@@ -0,0 +1,125 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNewGeneratedInventory(t *testing.T) {
+	t.Parallel()
+
+	inventory := NewGeneratedInventory([]resource.ObjectMetadata{
+		{Name: "secret", Kind: "Secret"},
+		{Name: "config", Kind: "ConfigMap"},
+	})
+
+	assert.Equal(t, GeneratedInventoryName, inventory.Name)
+	assert.Equal(t, "ConfigMap", inventory.Kind)
+	assert.Equal(t, "v1", inventory.APIVersion)
+	assert.Equal(t, map[string]string{
+		"_secret__Secret":    "",
+		"_config__ConfigMap": "",
+	}, inventory.Data)
+
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(inventory)
+	require.NoError(t, err)
+	assert.True(t, IsGeneratedInventory(&unstructured.Unstructured{Object: data}))
+}
+
+func TestIsGeneratedInventory(t *testing.T) {
+	t.Parallel()
+
+	newObject := func(apiVersion, kind, name string, labels map[string]string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetLabels(labels)
+		return obj
+	}
+	labels := map[string]string{generatedInventoryLabel: "true"}
+
+	tests := map[string]struct {
+		obj            *unstructured.Unstructured
+		expectedResult bool
+	}{
+		"generated inventory": {
+			obj:            newObject("v1", "ConfigMap", GeneratedInventoryName, labels),
+			expectedResult: true,
+		},
+		"configmap without label": {
+			obj:            newObject("v1", "ConfigMap", GeneratedInventoryName, nil),
+			expectedResult: false,
+		},
+		"configmap with another name": {
+			obj:            newObject("v1", "ConfigMap", "other", labels),
+			expectedResult: false,
+		},
+		"secret with same name": {
+			obj:            newObject("v1", "Secret", GeneratedInventoryName, labels),
+			expectedResult: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expectedResult, IsGeneratedInventory(test.obj))
+		})
+	}
+}
+
+func TestRemovedGeneratedResources(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		currentData      map[string]string
+		previousData     map[string]string
+		expectedResource []resource.ObjectMetadata
+	}{
+		"no previous data": {
+			currentData:      map[string]string{"_secret__Secret": ""},
+			expectedResource: []resource.ObjectMetadata{},
+		},
+		"removed resources are returned in namespace": {
+			currentData: map[string]string{"_secret__Secret": ""},
+			previousData: map[string]string{
+				"_secret__Secret":         "",
+				"_config__ConfigMap":      "",
+				"other_config__ConfigMap": "",
+				"invalid key":             "",
+			},
+			expectedResource: []resource.ObjectMetadata{
+				{Name: "config", Namespace: "namespace", Kind: "ConfigMap"},
+				{Name: "config", Namespace: "other", Kind: "ConfigMap"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.ElementsMatch(t, test.expectedResource, RemovedGeneratedResources(test.currentData, test.previousData, "namespace"))
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	dockerref "github.com/distribution/reference"
+)
+
+// ParseImageTag return tag and digest for the given image name string.
+// The function will also return "latest" as tag if the name string has no tag defined
+func ParseImageTag(image string) (string, string, error) {
+	named, err := dockerref.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", "", fmt.Errorf("couldn't parse image name %q: %w", image, err)
+	}
+
+	var tag, digest string
+	tagged, ok := named.(dockerref.Tagged)
+	if ok {
+		tag = tagged.Tag()
+	}
+
+	digested, ok := named.(dockerref.Digested)
+	if ok {
+		digest = digested.Digest().String()
+	}
+	// If no tag was specified, use the default "latest".
+	if len(tag) == 0 && len(digest) == 0 {
+		tag = "latest"
+	}
+	return tag, digest, nil
+}
+
+// IsNotUsingSemver return true if image is not pinned to a digest and its tag is not a semantic version, the
+// workloads using such images are always deployed again with the smart_deploy type when forced
+func IsNotUsingSemver(image string) (bool, error) {
+	tag, digest, err := ParseImageTag(image)
+	if err != nil {
+		return false, err
+	}
+
+	if len(digest) != 0 {
+		return false, nil
+	}
+
+	_, err = semver.ParseTolerant(tag)
+	return err != nil, nil
+}
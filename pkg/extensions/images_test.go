@@ -0,0 +1,86 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsNotUsingSemver(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		image          string
+		expectedTag    string
+		expectedDigest string
+		expected       bool
+		expectedError  string
+	}{
+		"semver tag": {
+			image:       "nginx:1.27.0",
+			expectedTag: "1.27.0",
+		},
+		"tolerant semver tag": {
+			image:       "registry.example.com/team/api:v2.1",
+			expectedTag: "v2.1",
+		},
+		"not semver tag": {
+			image:       "nginx:stable",
+			expectedTag: "stable",
+			expected:    true,
+		},
+		"missing tag": {
+			image:       "nginx",
+			expectedTag: "latest",
+			expected:    true,
+		},
+		"digest": {
+			image:          "nginx@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			expectedDigest: "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		},
+		"tag and digest": {
+			image:          "nginx:stable@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			expectedTag:    "stable",
+			expectedDigest: "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		},
+		"invalid image": {
+			image:         "Invalid Image",
+			expectedError: `couldn't parse image name "Invalid Image"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			notUsingSemver, err := IsNotUsingSemver(test.image)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, notUsingSemver)
+
+			tag, digest, err := ParseImageTag(test.image)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedTag, tag)
+			assert.Equal(t, test.expectedDigest, digest)
+		})
+	}
+}
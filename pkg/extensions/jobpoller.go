@@ -0,0 +1,91 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"fmt"
+
+	"github.com/mia-platform/jpl/pkg/poller"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// JobStatusCheckers return the status checkers for the Jobs: the ones generated from a CronJob are awaited only if
+// the CronJob has the await completion annotation, the others are always awaited until their completion
+func JobStatusCheckers() poller.CustomStatusCheckers {
+	return poller.CustomStatusCheckers{
+		jobGK: jobStatusChecker,
+	}
+}
+
+// jobStatusChecker contains the logic for checking if a Job has completed, a failed Job will fail the deploy
+func jobStatusChecker(object *unstructured.Unstructured) (*poller.Result, error) {
+	cronJobName, generated := object.GetAnnotations()[CreatedByCronJobAnnotation]
+	if generated && !AwaitCompletion(object) {
+		return &poller.Result{
+			Status:  poller.StatusCurrent,
+			Message: fmt.Sprintf("Job created from CronJob %s, not awaiting its completion", cronJobName),
+		}, nil
+	}
+
+	job := new(batchv1.Job)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, job); err != nil {
+		return nil, err
+	}
+
+	origin := "Job"
+	if generated {
+		origin = fmt.Sprintf("Job created from CronJob %s", cronJobName)
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		switch condition.Type {
+		case batchv1.JobFailed:
+			return &poller.Result{
+				Status:  poller.StatusFailed,
+				Message: fmt.Sprintf("%s failed: %s", origin, condition.Message),
+			}, nil
+		case batchv1.JobComplete:
+			return &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: fmt.Sprintf("%s completed, succeeded: %d", origin, job.Status.Succeeded),
+			}, nil
+		case batchv1.JobSuspended:
+			return &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: fmt.Sprintf("%s is suspended", origin),
+			}, nil
+		}
+	}
+
+	if job.Status.StartTime.IsZero() {
+		return &poller.Result{
+			Status:  poller.StatusInProgress,
+			Message: fmt.Sprintf("%s is not started yet", origin),
+		}, nil
+	}
+
+	return &poller.Result{
+		Status:  poller.StatusInProgress,
+		Message: fmt.Sprintf("%s in progress, succeeded: %d, active: %d, failed: %d", origin, job.Status.Succeeded, job.Status.Active, job.Status.Failed),
+	}, nil
+}
@@ -0,0 +1,95 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/poller"
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobStatusCheckers(t *testing.T) {
+	t.Parallel()
+
+	testdata := filepath.Join("testdata", "custom-pollers")
+	checkers := JobStatusCheckers()
+	assert.Equal(t, 1, len(checkers))
+
+	tests := map[string]struct {
+		path           string
+		expectedResult *poller.Result
+	}{
+		"generated job without await completion is current": {
+			path: "job-generated-no-annotation.yaml",
+			expectedResult: &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: "Job created from CronJob example, not awaiting its completion",
+			},
+		},
+		"generated job running is in progress": {
+			path: "job-generated-running.yaml",
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "Job created from CronJob example in progress, succeeded: 0, active: 1, failed: 1",
+			},
+		},
+		"generated job completed is current": {
+			path: "job-generated-complete.yaml",
+			expectedResult: &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: "Job created from CronJob example completed, succeeded: 1",
+			},
+		},
+		"generated job failed is failed": {
+			path: "job-generated-failed.yaml",
+			expectedResult: &poller.Result{
+				Status:  poller.StatusFailed,
+				Message: "Job created from CronJob example failed: Job has reached the specified backoff limit",
+			},
+		},
+		"job not started is in progress": {
+			path: "job-not-started.yaml",
+			expectedResult: &poller.Result{
+				Status:  poller.StatusInProgress,
+				Message: "Job is not started yet",
+			},
+		},
+		"suspended job is current": {
+			path: "job-suspended.yaml",
+			expectedResult: &poller.Result{
+				Status:  poller.StatusCurrent,
+				Message: "Job is suspended",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			object := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, test.path))
+			checker, found := checkers[object.GroupVersionKind().GroupKind()]
+			require.True(t, found)
+			result, err := checker(object)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedResult, result)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/mia-platform/jpl/pkg/client/cache"
+	"github.com/mia-platform/jpl/pkg/mutator"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// metadataMutator will implement a mutator that will merge a set of labels and annotations in every resource,
+// overriding the values set in the manifests. The pod templates of the workloads are changed only if requested,
+// because a change in their metadata will trigger a new rollout.
+type metadataMutator struct {
+	labels       map[string]string
+	annotations  map[string]string
+	podTemplates bool
+	workloads    Workloads
+}
+
+// NewMetadataMutator return a new mutator that will add labels and annotations to every resource, and to the pod
+// templates of the workloads when podTemplates is true
+func NewMetadataMutator(labels, annotations map[string]string, podTemplates bool, workloads Workloads) mutator.Interface {
+	return &metadataMutator{
+		labels:       labels,
+		annotations:  annotations,
+		podTemplates: podTemplates,
+		workloads:    workloads,
+	}
+}
+
+// CanHandleResource implement mutator.Interface interface
+func (m *metadataMutator) CanHandleResource(obj *metav1.PartialObjectMetadata) bool {
+	return obj != nil
+}
+
+// Mutate implement mutator.Interface interface
+func (m *metadataMutator) Mutate(obj *unstructured.Unstructured, _ cache.RemoteResourceGetter) error {
+	if err := mergeStringMap(obj.Object, m.labels, "metadata", "labels"); err != nil {
+		return err
+	}
+	if err := mergeStringMap(obj.Object, m.annotations, "metadata", "annotations"); err != nil {
+		return err
+	}
+
+	gk := obj.GroupVersionKind().GroupKind()
+	if !m.podTemplates || gk == podGK || !m.workloads.handlePods(gk) {
+		return nil
+	}
+
+	podSpecFields, _, err := m.workloads.podFields(obj.GroupVersionKind())
+	if err != nil || !hasPodSpec(obj, podSpecFields) {
+		return err
+	}
+
+	podMetadataFields := slices.Concat(podSpecFields[:len(podSpecFields)-1], []string{"metadata"})
+	if err := mergeStringMap(obj.Object, m.labels, slices.Concat(podMetadataFields, []string{"labels"})...); err != nil {
+		return err
+	}
+	return mergeStringMap(obj.Object, m.annotations, slices.Concat(podMetadataFields, []string{"annotations"})...)
+}
+
+// mergeStringMap add values to the string map found at fields in object, creating it if missing
+func mergeStringMap(object map[string]interface{}, values map[string]string, fields ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	current, _, err := unstructured.NestedStringMap(object, fields...)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		current = make(map[string]string, len(values))
+	}
+	maps.Copy(current, values)
+	return unstructured.SetNestedStringMap(object, current, fields...)
+}
+
+// keep it to always check if metadataMutator implement correctly the mutator.Interface interface
+var _ mutator.Interface = &metadataMutator{}
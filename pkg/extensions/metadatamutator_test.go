@@ -0,0 +1,98 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"path/filepath"
+	"testing"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMetadataMutator(t *testing.T) {
+	t.Parallel()
+	testdata := filepath.Join("testdata", "deploy-mutator")
+
+	labels := map[string]string{"team": "payments", "app": "override"}
+	annotations := map[string]string{"ci.pipeline": "1234"}
+
+	tests := map[string]struct {
+		object                 *unstructured.Unstructured
+		podTemplates           bool
+		expectedLabels         map[string]string
+		expectedAnnotations    map[string]string
+		expectedPodLabels      map[string]string
+		expectedPodAnnotations map[string]string
+	}{
+		"merge with existing labels": {
+			object:              jpltesting.UnstructuredFromFile(t, filepath.Join("testdata", "filter", "configmap.yaml")),
+			expectedLabels:      map[string]string{"app": "override", "team": "payments", "mia-platform.eu/deploy": "once"},
+			expectedAnnotations: annotations,
+		},
+		"pod template is not changed by default": {
+			object:              jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "deployment.yaml")),
+			expectedLabels:      labels,
+			expectedAnnotations: annotations,
+			expectedPodLabels:   map[string]string{"app": "example"},
+		},
+		"pod template is changed when requested": {
+			object:                 jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "deployment.yaml")),
+			podTemplates:           true,
+			expectedLabels:         labels,
+			expectedAnnotations:    annotations,
+			expectedPodLabels:      map[string]string{"app": "override", "team": "payments"},
+			expectedPodAnnotations: annotations,
+		},
+		"pod metadata is changed only once": {
+			object:              jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "pod.yaml")),
+			podTemplates:        true,
+			expectedLabels:      map[string]string{"app": "override", "team": "payments", "name": "example"},
+			expectedAnnotations: annotations,
+		},
+		"rollout referencing a workload": {
+			object:              jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "rollout-workload-ref.yaml")),
+			podTemplates:        true,
+			expectedLabels:      labels,
+			expectedAnnotations: annotations,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mutator := NewMetadataMutator(labels, annotations, test.podTemplates, nil)
+			require.True(t, mutator.CanHandleResource(&metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{APIVersion: test.object.GetAPIVersion(), Kind: test.object.GetKind()},
+			}))
+			require.NoError(t, mutator.Mutate(test.object, nil))
+
+			assert.Equal(t, test.expectedLabels, test.object.GetLabels())
+			assert.Equal(t, test.expectedAnnotations, test.object.GetAnnotations())
+
+			podLabels, _, err := unstructured.NestedStringMap(test.object.Object, "spec", "template", "metadata", "labels")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPodLabels, podLabels)
+			podAnnotations, _, err := unstructured.NestedStringMap(test.object.Object, "spec", "template", "metadata", "annotations")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPodAnnotations, podAnnotations)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mia-platform/jpl/pkg/client/cache"
+	"github.com/mia-platform/jpl/pkg/mutator"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// normalizeMutator rewrite semantically identical manifests in the same form, for avoiding patches and rollouts
+// caused only by the way the resources have been rendered:
+//   - null values are removed
+//   - empty labels and annotations are removed
+//   - quantities of the containers compute resources are written in their canonical form
+//   - env lists of the containers are sorted by name, if no variable references another one
+type normalizeMutator struct{}
+
+// NewNormalizeMutator return a new mutator that will normalize the representation of every resource
+func NewNormalizeMutator() mutator.Interface {
+	return &normalizeMutator{}
+}
+
+// CanHandleResource implement mutator.Interface interface
+func (m *normalizeMutator) CanHandleResource(obj *metav1.PartialObjectMetadata) bool {
+	return obj != nil
+}
+
+// Mutate implement mutator.Interface interface
+func (m *normalizeMutator) Mutate(obj *unstructured.Unstructured, _ cache.RemoteResourceGetter) error {
+	removeNullValues(obj.Object)
+	removeEmptyMetadata(obj.Object, "metadata")
+
+	podSpecFields, podAnnotationsFields, err := podFieldsForGroupKind(obj.GroupVersionKind())
+	if err != nil {
+		// not a workload, nothing more to normalize
+		return nil
+	}
+
+	removeEmptyMetadata(obj.Object, podAnnotationsFields[:len(podAnnotationsFields)-1]...)
+	podSpec, found, err := unstructured.NestedMap(obj.Object, podSpecFields...)
+	if err != nil || !found {
+		return err
+	}
+
+	for _, containersField := range []string{"initContainers", "containers"} {
+		if err := normalizeContainers(podSpec, containersField); err != nil {
+			return err
+		}
+	}
+
+	return unstructured.SetNestedMap(obj.Object, podSpec, podSpecFields...)
+}
+
+// removeNullValues delete recursively all the keys with a null value from data
+func removeNullValues(data map[string]interface{}) {
+	for key, value := range data {
+		switch typedValue := value.(type) {
+		case nil:
+			delete(data, key)
+		case map[string]interface{}:
+			removeNullValues(typedValue)
+		case []interface{}:
+			for _, item := range typedValue {
+				if itemMap, ok := item.(map[string]interface{}); ok {
+					removeNullValues(itemMap)
+				}
+			}
+		}
+	}
+}
+
+// removeEmptyMetadata delete the labels and annotations keys without values from the metadata found at fields
+func removeEmptyMetadata(obj map[string]interface{}, fields ...string) {
+	metadata, found, err := unstructured.NestedMap(obj, fields...)
+	if err != nil || !found {
+		return
+	}
+
+	for _, key := range []string{"labels", "annotations"} {
+		if value, found := metadata[key]; found {
+			if valueMap, ok := value.(map[string]interface{}); ok && len(valueMap) == 0 {
+				unstructured.RemoveNestedField(obj, slices.Concat(fields, []string{key})...)
+			}
+		}
+	}
+}
+
+// normalizeContainers normalize the compute resources and the env of every container found at field in podSpec
+func normalizeContainers(podSpec map[string]interface{}, field string) error {
+	containers, found, err := unstructured.NestedSlice(podSpec, field)
+	if err != nil || !found {
+		return err
+	}
+
+	for _, container := range containers {
+		containerMap, ok := container.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, resourceType := range []string{"requests", "limits"} {
+			if quantities, ok := nestedMapNoCopy(containerMap, "resources", resourceType); ok {
+				canonicalizeQuantities(quantities)
+			}
+		}
+
+		if env, ok := containerMap["env"].([]interface{}); ok {
+			sortEnv(env)
+		}
+	}
+
+	return unstructured.SetNestedSlice(podSpec, containers, field)
+}
+
+// nestedMapNoCopy return the map found at fields in obj without copying it, so it can be modified in place
+func nestedMapNoCopy(obj map[string]interface{}, fields ...string) (map[string]interface{}, bool) {
+	value, found, err := unstructured.NestedFieldNoCopy(obj, fields...)
+	if err != nil || !found {
+		return nil, false
+	}
+
+	valueMap, ok := value.(map[string]interface{})
+	return valueMap, ok
+}
+
+// canonicalizeQuantities rewrite every valid quantity in quantities in its canonical form, invalid values are left
+// untouched and will be reported by the api server
+func canonicalizeQuantities(quantities map[string]interface{}) {
+	for name, value := range quantities {
+		switch value.(type) {
+		case string, int64, float64:
+		default:
+			continue
+		}
+
+		quantity, err := resource.ParseQuantity(fmt.Sprint(value))
+		if err != nil {
+			continue
+		}
+		quantities[name] = quantity.String()
+	}
+}
+
+// sortEnv sort in place the env variables by name, the order is kept if a variable reference another one with
+// the $(VAR_NAME) syntax, or if a name is repeated, because in these cases the order changes their values
+func sortEnv(env []interface{}) {
+	names := make(map[string]struct{}, len(env))
+	for _, item := range env {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		name, ok := itemMap["name"].(string)
+		if !ok {
+			return
+		}
+
+		if _, found := names[name]; found {
+			return
+		}
+		names[name] = struct{}{}
+
+		if value, ok := itemMap["value"].(string); ok && strings.Contains(value, "$(") {
+			return
+		}
+	}
+
+	slices.SortStableFunc(env, func(a, b interface{}) int {
+		return cmp.Compare(a.(map[string]interface{})["name"].(string), b.(map[string]interface{})["name"].(string))
+	})
+}
+
+var _ mutator.Interface = &normalizeMutator{}
@@ -0,0 +1,151 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"path/filepath"
+	"testing"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNewNormalizeMutator(t *testing.T) {
+	t.Parallel()
+
+	mutator := NewNormalizeMutator()
+	assert.NotNil(t, mutator)
+}
+
+func TestNormalizeMutatorCanHandleResource(t *testing.T) {
+	t.Parallel()
+
+	m := &normalizeMutator{}
+	assert.False(t, m.CanHandleResource(nil))
+	assert.True(t, m.CanHandleResource(&metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       configMapGK.Kind,
+			APIVersion: "v1",
+		},
+	}))
+	assert.True(t, m.CanHandleResource(&metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       deployGK.Kind,
+			APIVersion: "apps/v1",
+		},
+	}))
+}
+
+func TestNormalizeMutatorMutate(t *testing.T) {
+	t.Parallel()
+
+	testdata := filepath.Join("testdata", "normalize-mutator")
+	tests := map[string]struct {
+		resource       *unstructured.Unstructured
+		expectedResult *unstructured.Unstructured
+	}{
+		"deployment is normalized": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "deployment.yaml")),
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-deployment.yaml")),
+		},
+		"normalized deployment is not changed": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-deployment.yaml")),
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-deployment.yaml")),
+		},
+		"env with references and invalid quantities are kept": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "pod-with-references.yaml")),
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "pod-with-references.yaml")),
+		},
+		"other resources remove only empty values": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "service.yaml")),
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-service.yaml")),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mutator := &normalizeMutator{}
+			require.NoError(t, mutator.Mutate(test.resource, &testGetter{}))
+			assert.Equal(t, test.expectedResult, test.resource)
+		})
+	}
+}
+
+func TestSortEnv(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		env         []interface{}
+		expectedEnv []interface{}
+	}{
+		"sorted by name": {
+			env: []interface{}{
+				map[string]interface{}{"name": "B", "value": "b"},
+				map[string]interface{}{"name": "A", "value": "a"},
+			},
+			expectedEnv: []interface{}{
+				map[string]interface{}{"name": "A", "value": "a"},
+				map[string]interface{}{"name": "B", "value": "b"},
+			},
+		},
+		"reference to other variables keep the order": {
+			env: []interface{}{
+				map[string]interface{}{"name": "B", "value": "b"},
+				map[string]interface{}{"name": "A", "value": "$(B)"},
+			},
+			expectedEnv: []interface{}{
+				map[string]interface{}{"name": "B", "value": "b"},
+				map[string]interface{}{"name": "A", "value": "$(B)"},
+			},
+		},
+		"duplicated names keep the order": {
+			env: []interface{}{
+				map[string]interface{}{"name": "B", "value": "b"},
+				map[string]interface{}{"name": "A", "value": "a"},
+				map[string]interface{}{"name": "B", "value": "c"},
+			},
+			expectedEnv: []interface{}{
+				map[string]interface{}{"name": "B", "value": "b"},
+				map[string]interface{}{"name": "A", "value": "a"},
+				map[string]interface{}{"name": "B", "value": "c"},
+			},
+		},
+		"invalid entries keep the order": {
+			env: []interface{}{
+				map[string]interface{}{"name": "B", "value": "b"},
+				"A=a",
+			},
+			expectedEnv: []interface{}{
+				map[string]interface{}{"name": "B", "value": "b"},
+				"A=a",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			sortEnv(test.env)
+			assert.Equal(t, test.expectedEnv, test.env)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mia-platform/jpl/pkg/client/cache"
+	"github.com/mia-platform/jpl/pkg/mutator"
+	"github.com/mia-platform/jpl/pkg/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// preserveFieldsMutator copy from the live object the fields listed in the preserve-fields annotation, so the
+// values tuned by hand on the cluster, like the replicas or the resources of a container, are not reverted by
+// every deploy
+type preserveFieldsMutator struct{}
+
+// fieldPathElement is a single step of a field path, the field of a map optionally followed by an index in the
+// list it contains
+type fieldPathElement struct {
+	field string
+	index int
+}
+
+// NewPreserveFieldsMutator return a new mutator that will keep the live values of the fields listed in the
+// preserve-fields annotation of a resource
+func NewPreserveFieldsMutator() mutator.Interface {
+	return &preserveFieldsMutator{}
+}
+
+// CanHandleResource implement mutator.Interface interface
+func (m *preserveFieldsMutator) CanHandleResource(obj *metav1.PartialObjectMetadata) bool {
+	_, found := obj.GetAnnotations()[preserveFieldsAnnotation]
+	return found
+}
+
+// Mutate implement mutator.Interface interface
+func (m *preserveFieldsMutator) Mutate(obj *unstructured.Unstructured, getter cache.RemoteResourceGetter) error {
+	paths, err := parsePreserveFields(obj.GetAnnotations()[preserveFieldsAnnotation])
+	if err != nil {
+		return err
+	}
+
+	remoteObj, err := getter.Get(context.Background(), resource.ObjectMetadataFromUnstructured(obj))
+	if err != nil || remoteObj == nil {
+		return err
+	}
+
+	for _, path := range paths {
+		value, found := nestedFieldValue(remoteObj.Object, path)
+		if !found {
+			continue
+		}
+		setNestedFieldValue(obj.Object, path, runtime.DeepCopyJSONValue(value))
+	}
+
+	return nil
+}
+
+// parsePreserveFields return the field paths contained in the comma separated list value, every path is a dot
+// separated list of fields that can be followed by an index in square brackets
+func parsePreserveFields(value string) ([][]fieldPathElement, error) {
+	paths := make([][]fieldPathElement, 0)
+	for _, rawPath := range strings.Split(value, ",") {
+		rawPath = strings.TrimSpace(rawPath)
+		if len(rawPath) == 0 {
+			continue
+		}
+
+		path := make([]fieldPathElement, 0)
+		for _, rawElement := range strings.Split(rawPath, ".") {
+			element, err := parseFieldPathElement(rawElement)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s annotation path %q: %w", preserveFieldsAnnotation, rawPath, err)
+			}
+			path = append(path, element)
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// parseFieldPathElement parse a field optionally followed by an index, like containers[0]
+func parseFieldPathElement(value string) (fieldPathElement, error) {
+	element := fieldPathElement{field: value, index: -1}
+	if start := strings.Index(value, "["); start >= 0 {
+		if !strings.HasSuffix(value, "]") {
+			return element, fmt.Errorf("missing closing bracket in %q", value)
+		}
+
+		index, err := strconv.Atoi(value[start+1 : len(value)-1])
+		if err != nil || index < 0 {
+			return element, fmt.Errorf("invalid index in %q", value)
+		}
+		element.field, element.index = value[:start], index
+	}
+
+	if len(element.field) == 0 {
+		return element, fmt.Errorf("empty field name")
+	}
+	return element, nil
+}
+
+// nestedFieldValue return the value found at path in obj
+func nestedFieldValue(obj map[string]interface{}, path []fieldPathElement) (interface{}, bool) {
+	var current interface{} = obj
+	for _, element := range path {
+		fields, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		if current, ok = fields[element.field]; !ok {
+			return nil, false
+		}
+
+		if element.index < 0 {
+			continue
+		}
+
+		items, ok := current.([]interface{})
+		if !ok || element.index >= len(items) {
+			return nil, false
+		}
+		current = items[element.index]
+	}
+
+	return current, true
+}
+
+// setNestedFieldValue set value at path in obj creating the missing maps, the lists are never created or extended
+// so a path with an index missing in obj is left untouched
+func setNestedFieldValue(obj map[string]interface{}, path []fieldPathElement, value interface{}) {
+	fields := obj
+	for idx, element := range path {
+		last := idx == len(path)-1
+		if element.index < 0 {
+			if last {
+				fields[element.field] = value
+				return
+			}
+
+			next, ok := fields[element.field].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				fields[element.field] = next
+			}
+			fields = next
+			continue
+		}
+
+		items, ok := fields[element.field].([]interface{})
+		if !ok || element.index >= len(items) {
+			return
+		}
+
+		if last {
+			items[element.index] = value
+			return
+		}
+
+		next, ok := items[element.index].(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields = next
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPreserveFieldsMutatorCanHandleResource(t *testing.T) {
+	t.Parallel()
+
+	mutator := NewPreserveFieldsMutator()
+	obj := &metav1.PartialObjectMetadata{}
+	assert.False(t, mutator.CanHandleResource(obj))
+
+	obj.SetAnnotations(map[string]string{preserveFieldsAnnotation: "spec.replicas"})
+	assert.True(t, mutator.CanHandleResource(obj))
+}
+
+func TestPreserveFieldsMutatorMutate(t *testing.T) {
+	t.Parallel()
+
+	deployment := func(annotation string, replicas int64, resources map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":      "example",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"replicas": replicas,
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{"name": "app", "image": "app:1.0.0", "resources": resources},
+						},
+					},
+				},
+			},
+		}}
+		if len(annotation) > 0 {
+			obj.SetAnnotations(map[string]string{preserveFieldsAnnotation: annotation})
+		}
+		return obj
+	}
+
+	desiredResources := map[string]interface{}{"limits": map[string]interface{}{"memory": "100Mi"}}
+	liveResources := map[string]interface{}{"limits": map[string]interface{}{"memory": "1Gi"}}
+	live := deployment("", 5, liveResources)
+
+	tests := map[string]struct {
+		resource       *unstructured.Unstructured
+		remote         *unstructured.Unstructured
+		remoteError    error
+		expectedResult *unstructured.Unstructured
+		expectedError  string
+	}{
+		"replicas and container resources preserved": {
+			resource:       deployment("spec.replicas, spec.template.spec.containers[0].resources", 1, desiredResources),
+			remote:         live,
+			expectedResult: deployment("spec.replicas, spec.template.spec.containers[0].resources", 5, liveResources),
+		},
+		"only listed fields are preserved": {
+			resource:       deployment("spec.replicas", 1, desiredResources),
+			remote:         live,
+			expectedResult: deployment("spec.replicas", 5, desiredResources),
+		},
+		"fields missing from the live object are left untouched": {
+			resource:       deployment("spec.paused,spec.template.spec.containers[1].resources", 1, desiredResources),
+			remote:         live,
+			expectedResult: deployment("spec.paused,spec.template.spec.containers[1].resources", 1, desiredResources),
+		},
+		"object not in the cluster": {
+			resource:       deployment("spec.replicas", 1, desiredResources),
+			expectedResult: deployment("spec.replicas", 1, desiredResources),
+		},
+		"error reading live object": {
+			resource:       deployment("spec.replicas", 1, desiredResources),
+			remoteError:    fmt.Errorf("error from remote"),
+			expectedResult: deployment("spec.replicas", 1, desiredResources),
+			expectedError:  "error from remote",
+		},
+		"invalid path": {
+			resource:       deployment("spec.template.spec.containers[first].resources", 1, desiredResources),
+			remote:         live,
+			expectedResult: deployment("spec.template.spec.containers[first].resources", 1, desiredResources),
+			expectedError:  `invalid mia-platform.eu/preserve-fields annotation path "spec.template.spec.containers[first].resources": invalid index in "containers[first]"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			id := resource.ObjectMetadataFromUnstructured(test.resource)
+			getter := &testGetter{
+				availableObjects: map[resource.ObjectMetadata]*unstructured.Unstructured{},
+				errors:           map[resource.ObjectMetadata]error{},
+			}
+			if test.remote != nil {
+				getter.availableObjects[id] = test.remote
+			}
+			if test.remoteError != nil {
+				getter.errors[id] = test.remoteError
+			}
+
+			err := NewPreserveFieldsMutator().Mutate(test.resource, getter)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+
+			assert.Equal(t, test.expectedResult, test.resource)
+		})
+	}
+}
+
+func TestParsePreserveFields(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		value         string
+		expectedPaths [][]fieldPathElement
+		expectedError string
+	}{
+		"multiple paths": {
+			value: "spec.replicas, spec.template.spec.containers[0].resources,",
+			expectedPaths: [][]fieldPathElement{
+				{{field: "spec", index: -1}, {field: "replicas", index: -1}},
+				{{field: "spec", index: -1}, {field: "template", index: -1}, {field: "spec", index: -1}, {field: "containers", index: 0}, {field: "resources", index: -1}},
+			},
+		},
+		"empty field": {
+			value:         "spec..replicas",
+			expectedError: "empty field name",
+		},
+		"missing closing bracket": {
+			value:         "spec.containers[0",
+			expectedError: `missing closing bracket in "containers[0"`,
+		},
+		"negative index": {
+			value:         "spec.containers[-1]",
+			expectedError: `invalid index in "containers[-1]"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			paths, err := parsePreserveFields(test.value)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+			assert.Equal(t, test.expectedPaths, paths)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mia-platform/jpl/pkg/client/cache"
+	"github.com/mia-platform/jpl/pkg/filter"
+	"github.com/mia-platform/jpl/pkg/mutator"
+	"github.com/mia-platform/jpl/pkg/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// ReleaseNameAnnotation contains the name of the release that owns the resource
+	ReleaseNameAnnotation = miaPlatformPrefix + "release-name"
+)
+
+// releaseMutator will implement a mutator that will add the release name annotation to every resource
+type releaseMutator struct {
+	releaseName string
+}
+
+// NewReleaseMutator return a new mutator that will mark every resource as owned by releaseName
+func NewReleaseMutator(releaseName string) mutator.Interface {
+	return &releaseMutator{releaseName: releaseName}
+}
+
+// CanHandleResource implement mutator.Interface interface
+func (m *releaseMutator) CanHandleResource(obj *metav1.PartialObjectMetadata) bool {
+	return obj != nil
+}
+
+// Mutate implement mutator.Interface interface
+func (m *releaseMutator) Mutate(obj *unstructured.Unstructured, _ cache.RemoteResourceGetter) error {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	annotations[ReleaseNameAnnotation] = m.releaseName
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// keep it to always check if releaseMutator implement correctly the mutator.Interface interface
+var _ mutator.Interface = &releaseMutator{}
+
+// releaseOwnershipFilter will implement a filter that will return an error if the remote resource is owned
+// by a release different from the one being deployed. Resources without the release name annotation are adopted.
+type releaseOwnershipFilter struct {
+	releaseName string
+}
+
+// NewReleaseOwnershipFilter return a new filter for avoiding to apply resources owned by another release
+func NewReleaseOwnershipFilter(releaseName string) filter.Interface {
+	return &releaseOwnershipFilter{releaseName: releaseName}
+}
+
+// Filter implement filter.Interface interface
+func (f *releaseOwnershipFilter) Filter(obj *unstructured.Unstructured, getter cache.RemoteResourceGetter) (bool, error) {
+	remoteObj, err := getter.Get(context.Background(), resource.ObjectMetadataFromUnstructured(obj))
+	if err != nil || remoteObj == nil {
+		return false, err
+	}
+
+	if owner, found := remoteObj.GetAnnotations()[ReleaseNameAnnotation]; found && owner != f.releaseName {
+		return false, fmt.Errorf("resource is owned by the release %q and cannot be applied by the release %q", owner, f.releaseName)
+	}
+
+	return false, nil
+}
+
+// keep it to always check if releaseOwnershipFilter implement correctly the filter.Interface interface
+var _ filter.Interface = &releaseOwnershipFilter{}
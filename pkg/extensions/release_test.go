@@ -0,0 +1,122 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestReleaseMutator(t *testing.T) {
+	t.Parallel()
+	testdata := filepath.Join("testdata", "filter")
+
+	tests := map[string]struct {
+		object              *unstructured.Unstructured
+		expectedAnnotations map[string]string
+	}{
+		"add annotation to resource without annotations": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "configmap.yaml")),
+			expectedAnnotations: map[string]string{
+				ReleaseNameAnnotation: "frontend",
+			},
+		},
+		"keep existing annotations": {
+			object: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "filtered.yaml")),
+			expectedAnnotations: map[string]string{
+				deployFilterAnnotation: deployFilterValue,
+				ReleaseNameAnnotation:  "frontend",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mutator := NewReleaseMutator("frontend")
+			require.True(t, mutator.CanHandleResource(&metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{APIVersion: test.object.GetAPIVersion(), Kind: test.object.GetKind()},
+			}))
+			require.NoError(t, mutator.Mutate(test.object, nil))
+			assert.Equal(t, test.expectedAnnotations, test.object.GetAnnotations())
+		})
+	}
+}
+
+func TestReleaseOwnershipFilter(t *testing.T) {
+	t.Parallel()
+	testdata := filepath.Join("testdata", "filter")
+	object := jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "deployment.yaml"))
+	id := resource.ObjectMetadataFromUnstructured(object)
+
+	ownedBy := func(release string) *unstructured.Unstructured {
+		remote := object.DeepCopy()
+		remote.SetAnnotations(map[string]string{ReleaseNameAnnotation: release})
+		return remote
+	}
+
+	tests := map[string]struct {
+		getter        *testGetter
+		expectedError string
+	}{
+		"remote resource not found": {
+			getter: &testGetter{},
+		},
+		"remote resource without release is adopted": {
+			getter: &testGetter{
+				availableObjects: map[resource.ObjectMetadata]*unstructured.Unstructured{id: object.DeepCopy()},
+			},
+		},
+		"remote resource owned by the same release": {
+			getter: &testGetter{
+				availableObjects: map[resource.ObjectMetadata]*unstructured.Unstructured{id: ownedBy("frontend")},
+			},
+		},
+		"remote resource owned by another release": {
+			getter: &testGetter{
+				availableObjects: map[resource.ObjectMetadata]*unstructured.Unstructured{id: ownedBy("backend")},
+			},
+			expectedError: `resource is owned by the release "backend" and cannot be applied by the release "frontend"`,
+		},
+		"error getting remote object": {
+			getter: &testGetter{
+				errors: map[resource.ObjectMetadata]error{id: fmt.Errorf("error on load")},
+			},
+			expectedError: "error on load",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			filter := NewReleaseOwnershipFilter("frontend")
+			filtered, err := filter.Filter(object, test.getter)
+			switch len(test.expectedError) {
+			case 0:
+				assert.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+			assert.False(t, filtered)
+		})
+	}
+}
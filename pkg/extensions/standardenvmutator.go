@@ -0,0 +1,139 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/mia-platform/jpl/pkg/client/cache"
+	"github.com/mia-platform/jpl/pkg/mutator"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// standardEnvFields contains the standard environment variables injected in the containers, with the path of the
+// pod field that provide their value
+var standardEnvFields = []struct {
+	name      string
+	fieldPath string
+}{
+	{name: "NAMESPACE", fieldPath: "metadata.namespace"},
+	{name: "POD_NAME", fieldPath: "metadata.name"},
+	{name: "POD_IP", fieldPath: "status.podIP"},
+	{name: "NODE_NAME", fieldPath: "spec.nodeName"},
+}
+
+// standardEnvMutator will add the standard environment variables and the custom ones to every container of pods
+// and workloads, without overriding the variables already defined by a container
+type standardEnvMutator struct {
+	env       []map[string]interface{}
+	workloads Workloads
+}
+
+// NewStandardEnvMutator return a new mutator that will inject in every container the standard environment variables
+// read from the pod fields and the custom variables in env, that take precedence over the standard ones
+func NewStandardEnvMutator(env map[string]string, workloads Workloads) mutator.Interface {
+	vars := make([]map[string]interface{}, 0, len(standardEnvFields)+len(env))
+	for _, field := range standardEnvFields {
+		if _, found := env[field.name]; found {
+			continue
+		}
+		vars = append(vars, map[string]interface{}{
+			"name": field.name,
+			"valueFrom": map[string]interface{}{
+				"fieldRef": map[string]interface{}{"fieldPath": field.fieldPath},
+			},
+		})
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		vars = append(vars, map[string]interface{}{"name": name, "value": env[name]})
+	}
+
+	return &standardEnvMutator{
+		env:       vars,
+		workloads: workloads,
+	}
+}
+
+// CanHandleResource implement mutator.Interface interface
+func (m *standardEnvMutator) CanHandleResource(obj *metav1.PartialObjectMetadata) bool {
+	return m.workloads.handlePods(obj.GroupVersionKind().GroupKind())
+}
+
+// Mutate implement mutator.Interface interface
+func (m *standardEnvMutator) Mutate(obj *unstructured.Unstructured, _ cache.RemoteResourceGetter) error {
+	podSpecFields, _, err := m.workloads.podFields(obj.GroupVersionKind())
+	if err != nil || !hasPodSpec(obj, podSpecFields) {
+		return err
+	}
+
+	podSpec, _, err := unstructured.NestedMap(obj.Object, podSpecFields...)
+	if err != nil {
+		return err
+	}
+
+	for _, containersField := range []string{"initContainers", "containers"} {
+		if err := m.mutateContainers(podSpec, containersField); err != nil {
+			return err
+		}
+	}
+
+	return unstructured.SetNestedMap(obj.Object, podSpec, podSpecFields...)
+}
+
+// mutateContainers append the missing variables to the env of every container found at field in podSpec
+func (m *standardEnvMutator) mutateContainers(podSpec map[string]interface{}, field string) error {
+	containers, found, err := unstructured.NestedSlice(podSpec, field)
+	if err != nil || !found {
+		return err
+	}
+
+	for _, container := range containers {
+		containerMap, ok := container.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		env, _, err := unstructured.NestedSlice(containerMap, "env")
+		if err != nil {
+			return err
+		}
+
+		defined := make(map[string]bool, len(env))
+		for _, envVar := range env {
+			if envVarMap, ok := envVar.(map[string]interface{}); ok {
+				name, _, _ := unstructured.NestedString(envVarMap, "name")
+				defined[name] = true
+			}
+		}
+
+		for _, envVar := range m.env {
+			if !defined[envVar["name"].(string)] {
+				env = append(env, envVar)
+			}
+		}
+
+		if len(env) > 0 {
+			containerMap["env"] = env
+		}
+	}
+
+	return unstructured.SetNestedSlice(podSpec, containers, field)
+}
+
+var _ mutator.Interface = &standardEnvMutator{}
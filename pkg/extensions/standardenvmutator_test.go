@@ -0,0 +1,108 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"path/filepath"
+	"testing"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestStandardEnvMutatorCanHandleResource(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		obj            *metav1.PartialObjectMetadata
+		expectedResult bool
+	}{
+		"config map is not handled": {
+			obj: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{Kind: configMapGK.Kind, APIVersion: "v1"},
+			},
+			expectedResult: false,
+		},
+		"deployment is handled": {
+			obj: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{Kind: deployGK.Kind, APIVersion: "apps/v1"},
+			},
+			expectedResult: true,
+		},
+		"pod is handled": {
+			obj: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{Kind: podGK.Kind, APIVersion: "v1"},
+			},
+			expectedResult: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := NewStandardEnvMutator(nil, nil)
+			assert.Equal(t, test.expectedResult, m.CanHandleResource(test.obj))
+		})
+	}
+}
+
+func TestStandardEnvMutatorMutate(t *testing.T) {
+	t.Parallel()
+
+	testdata := filepath.Join("testdata", "standard-env-mutator")
+	tests := map[string]struct {
+		resource       *unstructured.Unstructured
+		env            map[string]string
+		expectedResult *unstructured.Unstructured
+		expectedError  string
+	}{
+		"custom values override the standard ones but not the container ones": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "deployment.yaml")),
+			env:            map[string]string{"PLATFORM": "mia-platform", "POD_IP": "127.0.0.1"},
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-deployment.yaml")),
+		},
+		"pod receive the standard values": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "pod.yaml")),
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-pod.yaml")),
+		},
+		"wrong resource": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "wrong-resource.yaml")),
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "wrong-resource.yaml")),
+			expectedError:  `unsupported object type for dependencies mutator: "v1, Service"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mutator := NewStandardEnvMutator(test.env, nil)
+			err := mutator.Mutate(test.resource, &testGetter{})
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+
+			assert.Equal(t, test.expectedResult, test.resource)
+		})
+	}
+}
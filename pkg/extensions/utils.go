@@ -16,9 +16,9 @@
 package extensions
 
 import (
+	"crypto/hmac"
 	"crypto/sha512"
 	"encoding/hex"
-	"fmt"
 	"reflect"
 
 	extsecv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
@@ -40,6 +40,12 @@ const (
 
 	checksumAnnotation = miaPlatformPrefix + "dependencies-checksum"
 
+	trackCredentialsAnnotation = miaPlatformPrefix + "track-credentials"
+
+	ignoreDependenciesAnnotation = miaPlatformPrefix + "ignore-dependencies"
+
+	preserveFieldsAnnotation = miaPlatformPrefix + "preserve-fields"
+
 	DeployAll   = "deploy_all"
 	DeploySmart = "smart_deploy"
 )
@@ -48,40 +54,22 @@ var (
 	configMapGK = corev1.SchemeGroupVersion.WithKind(reflect.TypeOf(corev1.ConfigMap{}).Name()).GroupKind()
 	secretGK    = corev1.SchemeGroupVersion.WithKind(reflect.TypeOf(corev1.Secret{}).Name()).GroupKind()
 
+	serviceAccountGK = corev1.SchemeGroupVersion.WithKind(reflect.TypeOf(corev1.ServiceAccount{}).Name()).GroupKind()
+
 	extsecGK      = extsecv1beta1.SchemeGroupVersion.WithKind(extsecv1beta1.ExtSecretKind).GroupKind()
 	extSecStoreGK = extsecv1beta1.SchemeGroupVersion.WithKind(extsecv1beta1.SecretStoreKind).GroupKind()
 
 	deployGK = appsv1.SchemeGroupVersion.WithKind(reflect.TypeOf(appsv1.Deployment{}).Name()).GroupKind()
 	dsGK     = appsv1.SchemeGroupVersion.WithKind(reflect.TypeOf(appsv1.DaemonSet{}).Name()).GroupKind()
 	stsGK    = appsv1.SchemeGroupVersion.WithKind(reflect.TypeOf(appsv1.StatefulSet{}).Name()).GroupKind()
+	rsGK     = appsv1.SchemeGroupVersion.WithKind(reflect.TypeOf(appsv1.ReplicaSet{}).Name()).GroupKind()
 	podGK    = corev1.SchemeGroupVersion.WithKind(reflect.TypeOf(corev1.Pod{}).Name()).GroupKind()
 )
 
 // podFieldsForGroupKind return the pieces of the path for the pod spec and pod annotations for an unstructured
-// object described by gvk. This arrays can be used for retrieving information wihout casting the resource.
+// object described by gvk, using only the built-in workloads
 func podFieldsForGroupKind(gvk schema.GroupVersionKind) ([]string, []string, error) {
-	podSpecFields := []string{}
-	annotationsFields := []string{}
-	var err error
-	switch gvk.GroupKind() {
-	case deployGK:
-		podSpecFields = []string{"spec", "template", "spec"}
-		annotationsFields = []string{"spec", "template", "metadata", "annotations"}
-	case dsGK:
-		podSpecFields = []string{"spec", "template", "spec"}
-		annotationsFields = []string{"spec", "template", "metadata", "annotations"}
-	case stsGK:
-		podSpecFields = []string{"spec", "template", "spec"}
-		annotationsFields = []string{"spec", "template", "metadata", "annotations"}
-	case podGK:
-		podSpecFields = []string{"spec"}
-		annotationsFields = []string{"metadata", "annotations"}
-	default:
-		apiVersion, kind := gvk.ToAPIVersionAndKind()
-		err = fmt.Errorf("unsupported object type for dependencies mutator: \"%s, %s\"", apiVersion, kind)
-	}
-
-	return podSpecFields, annotationsFields, err
+	return Workloads(nil).podFields(gvk)
 }
 
 // podSpecFromUnstructured try to extract a podSpec from obj at fields path
@@ -123,3 +111,20 @@ func ChecksumFromData(data interface{}) string {
 	shasum := sha512.Sum512_256(encoded)
 	return hex.EncodeToString(shasum[:])
 }
+
+// HMACChecksumFromData create a HMAC Sum512_256 checksum for arbitrary data using key, if key is empty
+// it will fallback to ChecksumFromData
+func HMACChecksumFromData(key []byte, data interface{}) string {
+	if len(key) == 0 {
+		return ChecksumFromData(data)
+	}
+
+	encoded, err := yaml.Marshal(data)
+	if err != nil {
+		return ""
+	}
+
+	mac := hmac.New(sha512.New512_256, key)
+	mac.Write(encoded)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,249 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/mia-platform/jpl/pkg/client/cache"
+	"github.com/mia-platform/jpl/pkg/mutator"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// WorkloadDefaults contains the values that will be set on every workload when they are missing
+type WorkloadDefaults struct {
+	// ImagePullPolicy is set on every container that don't have one
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Resources contains requests and limits that are set on every container that don't specify them
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// ContainerSecurityContext is set on every container without a securityContext
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+	// SecurityContext is set on every pod without a securityContext
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+	// TopologySpreadConstraints are set on every pod without constraints, if a constraint don't have a
+	// labelSelector the labels of the pod will be used
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+}
+
+// workloadDefaultsMutator will set default values on workload resources, without overriding values already
+// present in the manifests
+type workloadDefaultsMutator struct {
+	defaults  WorkloadDefaults
+	workloads Workloads
+}
+
+// NewWorkloadDefaultsMutator return a new mutator that will enforce defaults on every pod and workload resource
+func NewWorkloadDefaultsMutator(defaults WorkloadDefaults, workloads Workloads) mutator.Interface {
+	return &workloadDefaultsMutator{
+		defaults:  defaults,
+		workloads: workloads,
+	}
+}
+
+// CanHandleResource implement mutator.Interface interface
+func (m *workloadDefaultsMutator) CanHandleResource(obj *metav1.PartialObjectMetadata) bool {
+	return m.workloads.handlePods(obj.GroupVersionKind().GroupKind())
+}
+
+// Mutate implement mutator.Interface interface
+func (m *workloadDefaultsMutator) Mutate(obj *unstructured.Unstructured, _ cache.RemoteResourceGetter) error {
+	podSpecFields, podAnnotationsFields, err := m.workloads.podFields(obj.GroupVersionKind())
+	if err != nil || !hasPodSpec(obj, podSpecFields) {
+		return err
+	}
+
+	podSpec, _, err := unstructured.NestedMap(obj.Object, podSpecFields...)
+	if err != nil {
+		return err
+	}
+
+	for _, containersField := range []string{"initContainers", "containers"} {
+		if err := m.mutateContainers(podSpec, containersField); err != nil {
+			return err
+		}
+	}
+
+	if _, found := podSpec["securityContext"]; !found && m.defaults.SecurityContext != nil {
+		securityContext, err := runtime.DefaultUnstructuredConverter.ToUnstructured(m.defaults.SecurityContext)
+		if err != nil {
+			return err
+		}
+		podSpec["securityContext"] = securityContext
+	}
+
+	if _, found := podSpec["topologySpreadConstraints"]; !found && len(m.defaults.TopologySpreadConstraints) > 0 {
+		podLabelsFields := slices.Clone(podAnnotationsFields)
+		podLabelsFields[len(podLabelsFields)-1] = "labels"
+		podLabels, _, err := unstructured.NestedStringMap(obj.Object, podLabelsFields...)
+		if err != nil {
+			return err
+		}
+
+		constraints, err := m.topologySpreadConstraints(podLabels)
+		if err != nil {
+			return err
+		}
+		podSpec["topologySpreadConstraints"] = constraints
+	}
+
+	return unstructured.SetNestedMap(obj.Object, podSpec, podSpecFields...)
+}
+
+// mutateContainers set the container defaults on every container found at field in podSpec
+func (m *workloadDefaultsMutator) mutateContainers(podSpec map[string]interface{}, field string) error {
+	containers, found, err := unstructured.NestedSlice(podSpec, field)
+	if err != nil || !found {
+		return err
+	}
+
+	for _, container := range containers {
+		containerMap, ok := container.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if _, found := containerMap["imagePullPolicy"]; !found && len(m.defaults.ImagePullPolicy) > 0 {
+			containerMap["imagePullPolicy"] = string(m.defaults.ImagePullPolicy)
+		}
+
+		if _, found := containerMap["securityContext"]; !found && m.defaults.ContainerSecurityContext != nil {
+			securityContext, err := runtime.DefaultUnstructuredConverter.ToUnstructured(m.defaults.ContainerSecurityContext)
+			if err != nil {
+				return err
+			}
+			containerMap["securityContext"] = securityContext
+		}
+
+		if err := setMissingResources(containerMap, m.defaults.Resources); err != nil {
+			return err
+		}
+	}
+
+	return unstructured.SetNestedSlice(podSpec, containers, field)
+}
+
+// topologySpreadConstraints return the default constraints in unstructured form, using podLabels as labelSelector
+// for the ones that don't have one
+func (m *workloadDefaultsMutator) topologySpreadConstraints(podLabels map[string]string) ([]interface{}, error) {
+	constraints := make([]interface{}, 0, len(m.defaults.TopologySpreadConstraints))
+	for _, constraint := range m.defaults.TopologySpreadConstraints {
+		constraint := *constraint.DeepCopy()
+		if constraint.LabelSelector == nil && len(podLabels) > 0 {
+			constraint.LabelSelector = &metav1.LabelSelector{MatchLabels: podLabels}
+		}
+
+		unstrConstraint, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&constraint)
+		if err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, unstrConstraint)
+	}
+
+	return constraints, nil
+}
+
+// setMissingResources add to the container the requests and limits of defaults for the resource names that it
+// doesn't specify; because the API server reject a request greater than its limit, a default request is lowered
+// to the limit set in the container, and a default limit is raised to the request of the container
+func setMissingResources(container map[string]interface{}, defaults corev1.ResourceRequirements) error {
+	if len(defaults.Requests) == 0 && len(defaults.Limits) == 0 {
+		return nil
+	}
+
+	requests, _, err := unstructured.NestedMap(container, "resources", "requests")
+	if err != nil {
+		return err
+	}
+	limits, _, err := unstructured.NestedMap(container, "resources", "limits")
+	if err != nil {
+		return err
+	}
+
+	if requests == nil {
+		requests = make(map[string]interface{})
+	}
+	if limits == nil {
+		limits = make(map[string]interface{})
+	}
+
+	for name, quantity := range defaults.Requests {
+		if _, found := requests[string(name)]; found {
+			continue
+		}
+
+		if limit, found := limits[string(name)]; found {
+			limitQuantity, err := unstructuredQuantity(limit)
+			if err != nil {
+				return fmt.Errorf("invalid %s limit: %w", name, err)
+			}
+			if quantity.Cmp(limitQuantity) > 0 {
+				quantity = limitQuantity
+			}
+		}
+		requests[string(name)] = quantity.String()
+	}
+
+	for name, quantity := range defaults.Limits {
+		if _, found := limits[string(name)]; found {
+			continue
+		}
+
+		if request, found := requests[string(name)]; found {
+			requestQuantity, err := unstructuredQuantity(request)
+			if err != nil {
+				return fmt.Errorf("invalid %s request: %w", name, err)
+			}
+			if quantity.Cmp(requestQuantity) < 0 {
+				quantity = requestQuantity
+			}
+		}
+		limits[string(name)] = quantity.String()
+	}
+
+	for resourceType, resources := range map[string]map[string]interface{}{"requests": requests, "limits": limits} {
+		if len(resources) == 0 {
+			continue
+		}
+		if err := unstructured.SetNestedMap(container, resources, "resources", resourceType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unstructuredQuantity return the quantity saved in value, that can be a string or a number depending on how the
+// manifest has been written
+func unstructuredQuantity(value interface{}) (resource.Quantity, error) {
+	switch typedValue := value.(type) {
+	case string:
+		return resource.ParseQuantity(typedValue)
+	case int64:
+		return *resource.NewQuantity(typedValue, resource.DecimalSI), nil
+	case float64:
+		return resource.ParseQuantity(strconv.FormatFloat(typedValue, 'f', -1, 64))
+	default:
+		return resource.Quantity{}, fmt.Errorf("unsupported quantity %v", value)
+	}
+}
+
+var _ mutator.Interface = &workloadDefaultsMutator{}
@@ -0,0 +1,190 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"path/filepath"
+	"testing"
+
+	jpltesting "github.com/mia-platform/jpl/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+)
+
+func TestNewWorkloadDefaultsMutator(t *testing.T) {
+	t.Parallel()
+
+	mutator := NewWorkloadDefaultsMutator(WorkloadDefaults{}, nil)
+	assert.NotNil(t, mutator)
+}
+
+func TestWorkloadDefaultsMutatorCanHandleResource(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		obj            *metav1.PartialObjectMetadata
+		expectedResult bool
+	}{
+		"config map is not handled": {
+			obj: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       configMapGK.Kind,
+					APIVersion: "v1alpha1", // version is ignored put impossible one
+				},
+			},
+			expectedResult: false,
+		},
+		"external secret is not handled": {
+			obj: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       extsecGK.Kind,
+					APIVersion: extsecGK.Group + "/v1alpha1", // version is ignored put impossible one
+				},
+			},
+			expectedResult: false,
+		},
+		"deployment return true": {
+			obj: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       deployGK.Kind,
+					APIVersion: "apps/v1alpha1", // version is ignored put impossible one
+				},
+			},
+			expectedResult: true,
+		},
+		"daemonset return true": {
+			obj: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       dsGK.Kind,
+					APIVersion: "apps/v1alpha1", // version is ignored put impossible one
+				},
+			},
+			expectedResult: true,
+		},
+		"statefulset return true": {
+			obj: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       stsGK.Kind,
+					APIVersion: "apps/v1alpha1", // version is ignored put impossible one
+				},
+			},
+			expectedResult: true,
+		},
+		"pod return true": {
+			obj: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       podGK.Kind,
+					APIVersion: "v1alpha1", // version is ignored put impossible one
+				},
+			},
+			expectedResult: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := workloadDefaultsMutator{}
+			assert.Equal(t, test.expectedResult, m.CanHandleResource(test.obj))
+		})
+	}
+}
+
+func TestWorkloadDefaultsMutatorMutate(t *testing.T) {
+	t.Parallel()
+
+	testdata := filepath.Join("testdata", "workload-defaults-mutator")
+	defaults := WorkloadDefaults{
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("64Mi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("256Mi"),
+			},
+		},
+		ContainerSecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: ptr.To(false),
+		},
+		SecurityContext: &corev1.PodSecurityContext{
+			RunAsNonRoot: ptr.To(true),
+		},
+		TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+			{
+				MaxSkew:           1,
+				TopologyKey:       "kubernetes.io/hostname",
+				WhenUnsatisfiable: corev1.ScheduleAnyway,
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		resource       *unstructured.Unstructured
+		defaults       WorkloadDefaults
+		expectedResult *unstructured.Unstructured
+		expectedError  string
+	}{
+		"deployment receive all defaults": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "deployment.yaml")),
+			defaults:       defaults,
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-deployment.yaml")),
+		},
+		"pod values are not overridden": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "pod.yaml")),
+			defaults:       defaults,
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-pod.yaml")),
+		},
+		"default resources don't exceed the container limits and requests": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "limited-pod.yaml")),
+			defaults:       WorkloadDefaults{Resources: defaults.Resources},
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "expected-limited-pod.yaml")),
+		},
+		"empty defaults don't change the resource": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "deployment.yaml")),
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "deployment.yaml")),
+		},
+		"wrong resource": {
+			resource:       jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "wrong-resource.yaml")),
+			defaults:       defaults,
+			expectedResult: jpltesting.UnstructuredFromFile(t, filepath.Join(testdata, "wrong-resource.yaml")),
+			expectedError:  `unsupported object type for dependencies mutator: "v1, Service"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mutator := &workloadDefaultsMutator{
+				defaults: test.defaults,
+			}
+
+			err := mutator.Mutate(test.resource, &testGetter{})
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+
+			assert.Equal(t, test.expectedResult, test.resource)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// WorkloadDefinition describe where the resources matching Group and Kind keep the template of their pods
+type WorkloadDefinition struct {
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+	// PodTemplatePath is the dot separated path of the pod template inside the resource, like spec.template
+	PodTemplatePath string `json:"podTemplatePath"`
+}
+
+// Workloads contains the path of the pod template for the workload resources, keyed by their group and kind.
+// The built-in workloads are always available, also when the map is nil.
+type Workloads map[schema.GroupKind][]string
+
+var (
+	rolloutGK = schema.GroupKind{Group: "argoproj.io", Kind: "Rollout"}
+
+	// builtinWorkloads contains the pod template paths of the kubernetes workloads and of well known custom
+	// resources that have the same structure
+	builtinWorkloads = Workloads{
+		deployGK:  {"spec", "template"},
+		dsGK:      {"spec", "template"},
+		stsGK:     {"spec", "template"},
+		rolloutGK: {"spec", "template"},
+	}
+
+	// podOwners contains the pod template paths of the resources that run pods but are never modified by the
+	// deploy, because their pods are created from another resource or their template cannot be changed
+	podOwners = Workloads{
+		rsGK:      {"spec", "template"},
+		jobGK:     {"spec", "template"},
+		cronJobGK: {"spec", "jobTemplate", "spec", "template"},
+	}
+)
+
+// NewWorkloads return the Workloads described by definitions in addition to the built-in ones, a definition with
+// the same group and kind of a built-in one will override it
+func NewWorkloads(definitions []WorkloadDefinition) (Workloads, error) {
+	workloads := make(Workloads, len(definitions))
+	for _, definition := range definitions {
+		if len(definition.Kind) == 0 {
+			return nil, fmt.Errorf("workload definition for group %q is missing the kind", definition.Group)
+		}
+
+		path := strings.Split(definition.PodTemplatePath, ".")
+		if slices.Contains(path, "") {
+			return nil, fmt.Errorf("invalid pod template path %q for workload %s", definition.PodTemplatePath, schema.GroupKind{Group: definition.Group, Kind: definition.Kind})
+		}
+		workloads[schema.GroupKind{Group: definition.Group, Kind: definition.Kind}] = path
+	}
+
+	return workloads, nil
+}
+
+// podTemplatePath return the path of the pod template for the resources of gk and if gk is a workload
+func (w Workloads) podTemplatePath(gk schema.GroupKind) ([]string, bool) {
+	if path, found := w[gk]; found {
+		return path, true
+	}
+
+	path, found := builtinWorkloads[gk]
+	return path, found
+}
+
+// GroupKinds return the group and kind of all the workloads with a pod template, including the built-in ones,
+// sorted by their string representation
+func (w Workloads) GroupKinds() []schema.GroupKind {
+	groupKinds := slices.Collect(maps.Keys(builtinWorkloads))
+	for gk := range w {
+		if _, found := builtinWorkloads[gk]; !found {
+			groupKinds = append(groupKinds, gk)
+		}
+	}
+
+	slices.SortFunc(groupKinds, func(a, b schema.GroupKind) int { return strings.Compare(a.String(), b.String()) })
+	return groupKinds
+}
+
+// handlePods return true if the resources of gk are pods or workloads with a pod template
+func (w Workloads) handlePods(gk schema.GroupKind) bool {
+	if gk == podGK {
+		return true
+	}
+
+	_, found := w.podTemplatePath(gk)
+	return found
+}
+
+// podFields return the pieces of the path for the pod spec and pod annotations for an unstructured
+// object described by gvk. This arrays can be used for retrieving information wihout casting the resource.
+func (w Workloads) podFields(gvk schema.GroupVersionKind) ([]string, []string, error) {
+	if gvk.GroupKind() == podGK {
+		return []string{"spec"}, []string{"metadata", "annotations"}, nil
+	}
+
+	path, found := w.podTemplatePath(gvk.GroupKind())
+	if !found {
+		apiVersion, kind := gvk.ToAPIVersionAndKind()
+		return nil, nil, fmt.Errorf("unsupported object type for dependencies mutator: \"%s, %s\"", apiVersion, kind)
+	}
+
+	return slices.Concat(path, []string{"spec"}), slices.Concat(path, []string{"metadata", "annotations"}), nil
+}
+
+// PodSpecFields return the path of the pod spec inside the resources of gk and true if they contain one, like
+// the pods, the workloads and the resources that own pods without being modified by the deploy, like the Jobs
+func (w Workloads) PodSpecFields(gk schema.GroupKind) ([]string, bool) {
+	if gk == podGK {
+		return []string{"spec"}, true
+	}
+
+	path, found := w.podTemplatePath(gk)
+	if !found {
+		path, found = podOwners[gk]
+	}
+	if !found {
+		return nil, false
+	}
+
+	return slices.Concat(path, []string{"spec"}), true
+}
+
+// hasPodSpec return true if obj contains a pod spec at podSpecFields, workloads like a Rollout referencing a
+// Deployment can omit it
+func hasPodSpec(obj *unstructured.Unstructured, podSpecFields []string) bool {
+	_, found, err := unstructured.NestedMap(obj.Object, podSpecFields...)
+	return found && err == nil
+}
@@ -0,0 +1,184 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestNewWorkloads(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		definitions       []WorkloadDefinition
+		expectedWorkloads Workloads
+		expectedError     string
+	}{
+		"no definitions": {
+			expectedWorkloads: Workloads{},
+		},
+		"custom definitions": {
+			definitions: []WorkloadDefinition{
+				{Group: "example.com", Kind: "Workload", PodTemplatePath: "spec.podTemplate"},
+				{Group: "argoproj.io", Kind: "Rollout", PodTemplatePath: "spec.template"},
+			},
+			expectedWorkloads: Workloads{
+				{Group: "example.com", Kind: "Workload"}: {"spec", "podTemplate"},
+				rolloutGK:                                {"spec", "template"},
+			},
+		},
+		"missing kind": {
+			definitions:   []WorkloadDefinition{{Group: "example.com", PodTemplatePath: "spec.template"}},
+			expectedError: `workload definition for group "example.com" is missing the kind`,
+		},
+		"missing path": {
+			definitions:   []WorkloadDefinition{{Group: "example.com", Kind: "Workload"}},
+			expectedError: `invalid pod template path "" for workload Workload.example.com`,
+		},
+		"invalid path": {
+			definitions:   []WorkloadDefinition{{Group: "example.com", Kind: "Workload", PodTemplatePath: "spec..template"}},
+			expectedError: `invalid pod template path "spec..template" for workload Workload.example.com`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			workloads, err := NewWorkloads(test.definitions)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedWorkloads, workloads)
+			default:
+				assert.EqualError(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestWorkloadsPodFields(t *testing.T) {
+	t.Parallel()
+
+	customGK := schema.GroupKind{Group: "example.com", Kind: "Workload"}
+	workloads := Workloads{
+		customGK: {"spec", "podTemplate"},
+		deployGK: {"spec", "custom", "template"},
+	}
+
+	tests := map[string]struct {
+		workloads           Workloads
+		gvk                 schema.GroupVersionKind
+		expectedSpec        []string
+		expectedAnnotations []string
+		expectedError       string
+	}{
+		"pod": {
+			gvk:                 podGK.WithVersion("v1"),
+			expectedSpec:        []string{"spec"},
+			expectedAnnotations: []string{"metadata", "annotations"},
+		},
+		"built-in rollout": {
+			gvk:                 rolloutGK.WithVersion("v1alpha1"),
+			expectedSpec:        []string{"spec", "template", "spec"},
+			expectedAnnotations: []string{"spec", "template", "metadata", "annotations"},
+		},
+		"custom workload": {
+			workloads:           workloads,
+			gvk:                 customGK.WithVersion("v1"),
+			expectedSpec:        []string{"spec", "podTemplate", "spec"},
+			expectedAnnotations: []string{"spec", "podTemplate", "metadata", "annotations"},
+		},
+		"overridden built-in workload": {
+			workloads:           workloads,
+			gvk:                 deployGK.WithVersion("v1"),
+			expectedSpec:        []string{"spec", "custom", "template", "spec"},
+			expectedAnnotations: []string{"spec", "custom", "template", "metadata", "annotations"},
+		},
+		"unknown workload": {
+			gvk:           customGK.WithVersion("v1"),
+			expectedError: `unsupported object type for dependencies mutator: "example.com/v1, Workload"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, len(test.expectedError) == 0, test.workloads.handlePods(test.gvk.GroupKind()))
+			spec, annotations, err := test.workloads.podFields(test.gvk)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedSpec, spec)
+				assert.Equal(t, test.expectedAnnotations, annotations)
+			default:
+				assert.EqualError(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestWorkloadsPodSpecFields(t *testing.T) {
+	t.Parallel()
+
+	customGK := schema.GroupKind{Group: "example.com", Kind: "Workload"}
+	workloads := Workloads{customGK: {"spec", "podTemplate"}}
+
+	tests := map[string]struct {
+		gk            schema.GroupKind
+		expectedPath  []string
+		expectedFound bool
+	}{
+		"pod": {
+			gk:            podGK,
+			expectedPath:  []string{"spec"},
+			expectedFound: true,
+		},
+		"built-in rollout": {
+			gk:            rolloutGK,
+			expectedPath:  []string{"spec", "template", "spec"},
+			expectedFound: true,
+		},
+		"custom workload": {
+			gk:            customGK,
+			expectedPath:  []string{"spec", "podTemplate", "spec"},
+			expectedFound: true,
+		},
+		"cronjob not modified by the deploy": {
+			gk:            cronJobGK,
+			expectedPath:  []string{"spec", "jobTemplate", "spec", "template", "spec"},
+			expectedFound: true,
+		},
+		"resource without pods": {
+			gk: configMapGK,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path, found := workloads.PodSpecFields(test.gk)
+			assert.Equal(t, test.expectedFound, found)
+			assert.Equal(t, test.expectedPath, path)
+		})
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graph contains the logic for building the graph of the dependencies between a set of resources
+package graph
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	extsecv1beta1 "github.com/external-secrets/external-secrets/apis/externalsecrets/v1beta1"
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// RelationVolume link a workload to a ConfigMap or Secret mounted as a volume
+	RelationVolume = "volume"
+	// RelationEnv link a workload to a ConfigMap or Secret used for its environment variables
+	RelationEnv = "env"
+	// RelationImagePullSecret link a workload or a ServiceAccount to a Secret used for pulling images
+	RelationImagePullSecret = "image-pull-secret"
+	// RelationServiceAccount link a workload to its ServiceAccount
+	RelationServiceAccount = "service-account"
+	// RelationSecretStore link an ExternalSecret to the SecretStore or ClusterSecretStore it reads from
+	RelationSecretStore = "secret-store"
+	// RelationTarget link an ExternalSecret to the Secret it generates
+	RelationTarget = "target"
+	// RelationDefinition link a custom resource to the CustomResourceDefinition of its kind
+	RelationDefinition = "definition"
+)
+
+var (
+	configMapGK      = schema.GroupKind{Kind: "ConfigMap"}
+	secretGK         = schema.GroupKind{Kind: "Secret"}
+	serviceAccountGK = schema.GroupKind{Kind: "ServiceAccount"}
+	crdGK            = apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinition").GroupKind()
+	externalSecretGK = extsecv1beta1.SchemeGroupVersion.WithKind(extsecv1beta1.ExtSecretKind).GroupKind()
+)
+
+// Node is a resource of the graph
+type Node struct {
+	ID        string `json:"id"`
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// External is true if the resource is referenced but it is not part of the manifests
+	External bool `json:"external,omitempty"`
+}
+
+// Edge is a dependency of the resource From on the resource To
+type Edge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+}
+
+// Graph contains the resources and their dependencies
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// builder accumulate the nodes and edges found in the resources
+type builder struct {
+	nodes map[string]Node
+	edges map[Edge]bool
+}
+
+// Build return the graph of the dependencies between objects, reading the pod specs of the pods and of workloads;
+// the referenced resources that are not found in objects are added as external nodes
+func Build(objects []*unstructured.Unstructured, workloads extensions.Workloads) (*Graph, error) {
+	b := &builder{
+		nodes: make(map[string]Node, len(objects)),
+		edges: make(map[Edge]bool),
+	}
+
+	crds := make(map[schema.GroupKind]string)
+	for _, obj := range objects {
+		node := b.addNode(obj.GroupVersionKind().GroupKind(), obj.GetName(), obj.GetNamespace(), false)
+		if obj.GroupVersionKind().GroupKind() != crdGK {
+			continue
+		}
+
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		crds[schema.GroupKind{Group: group, Kind: kind}] = node.ID
+	}
+
+	for _, obj := range objects {
+		from := nodeID(obj.GroupVersionKind().GroupKind(), obj.GetName(), obj.GetNamespace())
+		if crdID, found := crds[obj.GroupVersionKind().GroupKind()]; found {
+			b.addEdge(from, crdID, RelationDefinition)
+		}
+
+		gk := obj.GroupVersionKind().GroupKind()
+		podSpecFields, isWorkload := workloads.PodSpecFields(gk)
+		switch {
+		case isWorkload:
+			if err := b.addPodSpecDependencies(obj, podSpecFields); err != nil {
+				return nil, err
+			}
+		case gk == serviceAccountGK:
+			secrets, _, err := unstructured.NestedSlice(obj.Object, "imagePullSecrets")
+			if err != nil {
+				return nil, fmt.Errorf("failed to read ServiceAccount %q: %w", obj.GetName(), err)
+			}
+			for _, secret := range secrets {
+				if secretMap, ok := secret.(map[string]interface{}); ok {
+					name, _ := secretMap["name"].(string)
+					b.addDependency(from, secretGK, name, obj.GetNamespace(), RelationImagePullSecret)
+				}
+			}
+		case gk == externalSecretGK:
+			if err := b.addExternalSecretDependencies(obj); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// start from empty slices for always encoding the nodes and edges as json arrays
+	nodes := slices.AppendSeq(make([]Node, 0, len(b.nodes)), maps.Values(b.nodes))
+	slices.SortFunc(nodes, func(a, b Node) int { return cmp.Compare(a.ID, b.ID) })
+	edges := slices.AppendSeq(make([]Edge, 0, len(b.edges)), maps.Keys(b.edges))
+	slices.SortFunc(edges, func(a, b Edge) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To), cmp.Compare(a.Relation, b.Relation))
+	})
+
+	return &Graph{Nodes: nodes, Edges: edges}, nil
+}
+
+// addPodSpecDependencies add the edges for the ConfigMaps, Secrets and ServiceAccount used by the pod spec of obj
+func (b *builder) addPodSpecDependencies(obj *unstructured.Unstructured, fields []string) error {
+	unstructuredPodSpec, _, err := unstructured.NestedMap(obj.Object, fields...)
+	if err != nil {
+		return fmt.Errorf("failed to read %s %q: %w", obj.GetKind(), obj.GetName(), err)
+	}
+
+	podSpec := corev1.PodSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredPodSpec, &podSpec); err != nil {
+		return fmt.Errorf("failed to read %s %q: %w", obj.GetKind(), obj.GetName(), err)
+	}
+
+	from := nodeID(obj.GroupVersionKind().GroupKind(), obj.GetName(), obj.GetNamespace())
+	namespace := obj.GetNamespace()
+	for _, volume := range podSpec.Volumes {
+		switch {
+		case volume.ConfigMap != nil:
+			b.addDependency(from, configMapGK, volume.ConfigMap.Name, namespace, RelationVolume)
+		case volume.Secret != nil:
+			b.addDependency(from, secretGK, volume.Secret.SecretName, namespace, RelationVolume)
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					b.addDependency(from, configMapGK, source.ConfigMap.Name, namespace, RelationVolume)
+				}
+				if source.Secret != nil {
+					b.addDependency(from, secretGK, source.Secret.Name, namespace, RelationVolume)
+				}
+			}
+		}
+	}
+
+	for _, container := range slices.Concat(podSpec.InitContainers, podSpec.Containers) {
+		for _, env := range container.Env {
+			switch {
+			case env.ValueFrom == nil:
+			case env.ValueFrom.ConfigMapKeyRef != nil:
+				b.addDependency(from, configMapGK, env.ValueFrom.ConfigMapKeyRef.Name, namespace, RelationEnv)
+			case env.ValueFrom.SecretKeyRef != nil:
+				b.addDependency(from, secretGK, env.ValueFrom.SecretKeyRef.Name, namespace, RelationEnv)
+			}
+		}
+
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				b.addDependency(from, configMapGK, envFrom.ConfigMapRef.Name, namespace, RelationEnv)
+			}
+			if envFrom.SecretRef != nil {
+				b.addDependency(from, secretGK, envFrom.SecretRef.Name, namespace, RelationEnv)
+			}
+		}
+	}
+
+	for _, pullSecret := range podSpec.ImagePullSecrets {
+		b.addDependency(from, secretGK, pullSecret.Name, namespace, RelationImagePullSecret)
+	}
+	b.addDependency(from, serviceAccountGK, podSpec.ServiceAccountName, namespace, RelationServiceAccount)
+	return nil
+}
+
+// addExternalSecretDependencies add the edges for the stores used by the ExternalSecret obj and the Secret it
+// generates
+func (b *builder) addExternalSecretDependencies(obj *unstructured.Unstructured) error {
+	externalSecret := new(extsecv1beta1.ExternalSecret)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, externalSecret); err != nil {
+		return fmt.Errorf("failed to read ExternalSecret %q: %w", obj.GetName(), err)
+	}
+
+	from := nodeID(externalSecretGK, obj.GetName(), obj.GetNamespace())
+	storeRefs := []*extsecv1beta1.SecretStoreRef{&externalSecret.Spec.SecretStoreRef}
+	for _, data := range externalSecret.Spec.Data {
+		if data.SourceRef != nil {
+			storeRefs = append(storeRefs, &data.SourceRef.SecretStoreRef)
+		}
+	}
+	for _, dataFrom := range externalSecret.Spec.DataFrom {
+		if dataFrom.SourceRef != nil {
+			storeRefs = append(storeRefs, dataFrom.SourceRef.SecretStoreRef)
+		}
+	}
+
+	for _, storeRef := range storeRefs {
+		if storeRef == nil {
+			continue
+		}
+
+		kind := cmp.Or(storeRef.Kind, extsecv1beta1.SecretStoreKind)
+		namespace := obj.GetNamespace()
+		if kind == extsecv1beta1.ClusterSecretStoreKind {
+			namespace = ""
+		}
+		storeGK := extsecv1beta1.SchemeGroupVersion.WithKind(kind).GroupKind()
+		b.addDependency(from, storeGK, storeRef.Name, namespace, RelationSecretStore)
+	}
+
+	targetName := cmp.Or(externalSecret.Spec.Target.Name, obj.GetName())
+	b.addDependency(from, secretGK, targetName, obj.GetNamespace(), RelationTarget)
+	return nil
+}
+
+// addDependency add an edge from the node with id from to the resource identified by gk, name and namespace,
+// adding it as an external node if not already present
+func (b *builder) addDependency(from string, gk schema.GroupKind, name, namespace, relation string) {
+	if len(name) == 0 {
+		return
+	}
+
+	node := b.addNode(gk, name, namespace, true)
+	b.addEdge(from, node.ID, relation)
+}
+
+// addNode add a node for the resource if not already present and return it
+func (b *builder) addNode(gk schema.GroupKind, name, namespace string, external bool) Node {
+	id := nodeID(gk, name, namespace)
+	if node, found := b.nodes[id]; found && (external || !node.External) {
+		return node
+	}
+
+	node := Node{
+		ID:        id,
+		Group:     gk.Group,
+		Kind:      gk.Kind,
+		Name:      name,
+		Namespace: namespace,
+		External:  external,
+	}
+	b.nodes[id] = node
+	return node
+}
+
+// addEdge add an edge between two nodes
+func (b *builder) addEdge(from, to, relation string) {
+	b.edges[Edge{From: from, To: to, Relation: relation}] = true
+}
+
+// nodeID return the identifier of the resource in the graph
+func nodeID(gk schema.GroupKind, name, namespace string) string {
+	if len(namespace) == 0 {
+		return gk.String() + "/" + name
+	}
+
+	return gk.String() + "/" + namespace + "/" + name
+}
+
+// WriteDOT write the graph in the DOT language to writer, the external nodes are drawn with a dashed border
+func (g *Graph) WriteDOT(writer io.Writer) error {
+	builder := new(strings.Builder)
+	builder.WriteString("digraph mlp {\n")
+	builder.WriteString("\trankdir=LR;\n")
+	builder.WriteString("\tnode [shape=box];\n")
+	for _, node := range g.Nodes {
+		label := node.Kind + `\n` + node.Name
+		style := ""
+		if node.External {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(builder, "\t%s [label=%s%s];\n", dotQuote(node.ID), dotQuote(label), style)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(builder, "\t%s -> %s [label=%s];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(edge.Relation))
+	}
+	builder.WriteString("}\n")
+
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+// dotQuote return value as a DOT quoted string, leaving untouched the escape sequences used for the labels
+func dotQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
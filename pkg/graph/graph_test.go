@@ -0,0 +1,134 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mia-platform/mlp/v2/pkg/extensions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	objects := objectsFromFile(t, filepath.Join("testdata", "resources.yaml"))
+	graph, err := Build(objects, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Node{
+		{ID: "ClusterSecretStore.external-secrets.io/global", Group: "external-secrets.io", Kind: "ClusterSecretStore", Name: "global", External: true},
+		{ID: "ConfigMap/test/config", Kind: "ConfigMap", Name: "config", Namespace: "test"},
+		{ID: "ConfigMap/test/shared", Kind: "ConfigMap", Name: "shared", Namespace: "test", External: true},
+		{ID: "CronJob.batch/test/backup", Group: "batch", Kind: "CronJob", Name: "backup", Namespace: "test"},
+		{ID: "CustomResourceDefinition.apiextensions.k8s.io/databases.example.com", Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition", Name: "databases.example.com"},
+		{ID: "Database.example.com/test/db", Group: "example.com", Kind: "Database", Name: "db", Namespace: "test"},
+		{ID: "Deployment.apps/test/api", Group: "apps", Kind: "Deployment", Name: "api", Namespace: "test"},
+		{ID: "ExternalSecret.external-secrets.io/test/credentials", Group: "external-secrets.io", Kind: "ExternalSecret", Name: "credentials", Namespace: "test"},
+		{ID: "Secret/test/credentials", Kind: "Secret", Name: "credentials", Namespace: "test", External: true},
+		{ID: "Secret/test/registry", Kind: "Secret", Name: "registry", Namespace: "test", External: true},
+		{ID: "SecretStore.external-secrets.io/test/vault", Group: "external-secrets.io", Kind: "SecretStore", Name: "vault", Namespace: "test", External: true},
+		{ID: "ServiceAccount/test/api", Kind: "ServiceAccount", Name: "api", Namespace: "test"},
+	}, graph.Nodes)
+
+	assert.Equal(t, []Edge{
+		{From: "CronJob.batch/test/backup", To: "Secret/test/credentials", Relation: RelationEnv},
+		{From: "Database.example.com/test/db", To: "CustomResourceDefinition.apiextensions.k8s.io/databases.example.com", Relation: RelationDefinition},
+		{From: "Deployment.apps/test/api", To: "ConfigMap/test/config", Relation: RelationVolume},
+		{From: "Deployment.apps/test/api", To: "ConfigMap/test/shared", Relation: RelationEnv},
+		{From: "Deployment.apps/test/api", To: "Secret/test/credentials", Relation: RelationEnv},
+		{From: "Deployment.apps/test/api", To: "Secret/test/credentials", Relation: RelationVolume},
+		{From: "Deployment.apps/test/api", To: "Secret/test/registry", Relation: RelationImagePullSecret},
+		{From: "Deployment.apps/test/api", To: "ServiceAccount/test/api", Relation: RelationServiceAccount},
+		{From: "ExternalSecret.external-secrets.io/test/credentials", To: "ClusterSecretStore.external-secrets.io/global", Relation: RelationSecretStore},
+		{From: "ExternalSecret.external-secrets.io/test/credentials", To: "Secret/test/credentials", Relation: RelationTarget},
+		{From: "ExternalSecret.external-secrets.io/test/credentials", To: "SecretStore.external-secrets.io/test/vault", Relation: RelationSecretStore},
+		{From: "ServiceAccount/test/api", To: "Secret/test/registry", Relation: RelationImagePullSecret},
+	}, graph.Edges)
+}
+
+func TestBuildWorkloads(t *testing.T) {
+	t.Parallel()
+
+	objects := objectsFromFile(t, filepath.Join("testdata", "workloads.yaml"))
+	workloads := extensions.Workloads{{Group: "example.com", Kind: "Workload"}: {"spec", "podTemplate"}}
+	graph, err := Build(objects, workloads)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Edge{
+		{From: "Rollout.argoproj.io/test/api", To: "ConfigMap/test/config", Relation: RelationEnv},
+		{From: "Workload.example.com/test/worker", To: "ServiceAccount/test/worker", Relation: RelationServiceAccount},
+	}, graph.Edges)
+
+	graph, err = Build(objects, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []Edge{
+		{From: "Rollout.argoproj.io/test/api", To: "ConfigMap/test/config", Relation: RelationEnv},
+	}, graph.Edges, "the custom workloads must be read only when configured")
+}
+
+func TestWriteDOT(t *testing.T) {
+	t.Parallel()
+
+	graph := &Graph{
+		Nodes: []Node{
+			{ID: "Deployment.apps/test/api", Group: "apps", Kind: "Deployment", Name: "api", Namespace: "test"},
+			{ID: "ConfigMap/test/config", Kind: "ConfigMap", Name: "config", Namespace: "test", External: true},
+		},
+		Edges: []Edge{
+			{From: "Deployment.apps/test/api", To: "ConfigMap/test/config", Relation: RelationVolume},
+		},
+	}
+
+	output := new(strings.Builder)
+	require.NoError(t, graph.WriteDOT(output))
+	assert.Equal(t, `digraph mlp {
+	rankdir=LR;
+	node [shape=box];
+	"Deployment.apps/test/api" [label="Deployment\napi"];
+	"ConfigMap/test/config" [label="ConfigMap\nconfig", style=dashed];
+	"Deployment.apps/test/api" -> "ConfigMap/test/config" [label="volume"];
+}
+`, output.String())
+}
+
+func objectsFromFile(t *testing.T, path string) []*unstructured.Unstructured {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(file, 4096)
+	var objects []*unstructured.Unstructured
+	for {
+		obj := &unstructured.Unstructured{}
+		err := decoder.Decode(&obj.Object)
+		if errors.Is(err, io.EOF) {
+			return objects
+		}
+		require.NoError(t, err)
+		objects = append(objects, obj)
+	}
+}
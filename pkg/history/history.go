@@ -0,0 +1,150 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package history contains the records of the deploys saved in a ConfigMap inside the target namespace, used as
+// a ring buffer keeping only the most recent ones
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// ConfigMapName is the name of the ConfigMap containing the deploy history
+	ConfigMapName = "eu.mia-platform.mlp.history"
+	// DefaultLimit is the default number of records kept in the history
+	DefaultLimit = 10
+
+	recordsKey = "records"
+
+	ResultSucceeded = "succeeded"
+	ResultFailed    = "failed"
+)
+
+// Record contains the data of a single deploy
+type Record struct {
+	Revision     int64     `json:"revision"`
+	Timestamp    time.Time `json:"timestamp"`
+	Actor        string    `json:"actor,omitempty"`
+	Commit       string    `json:"commit,omitempty"`
+	FieldManager string    `json:"fieldManager,omitempty"`
+	Applied      int       `json:"applied"`
+	Pruned       int       `json:"pruned"`
+	Failed       int       `json:"failed"`
+	Duration     string    `json:"duration"`
+	Result       string    `json:"result"`
+}
+
+// Store read and write the deploy history of a namespace
+type Store struct {
+	clientset kubernetes.Interface
+	namespace string
+}
+
+// NewStore return a new Store for the history saved in namespace
+func NewStore(clientset kubernetes.Interface, namespace string) *Store {
+	return &Store{
+		clientset: clientset,
+		namespace: namespace,
+	}
+}
+
+// List return the records saved in the history, from the oldest to the most recent
+func (s *Store) List(ctx context.Context) ([]Record, error) {
+	configMap, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Get(ctx, ConfigMapName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return []Record{}, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read deploy history: %w", err)
+	}
+
+	return recordsFromConfigMap(configMap)
+}
+
+// Append save record in the history with the next revision number, removing the oldest records exceeding limit
+func (s *Store) Append(ctx context.Context, record Record, limit int) error {
+	client := s.clientset.CoreV1().ConfigMaps(s.namespace)
+	// retry also if another deploy has created the ConfigMap in the meantime
+	retriable := func(err error) bool { return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) }
+	err := retry.OnError(retry.DefaultRetry, retriable, func() error {
+		configMap, err := client.Get(ctx, ConfigMapName, metav1.GetOptions{})
+		notFound := apierrors.IsNotFound(err)
+		switch {
+		case notFound:
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: s.namespace},
+			}
+		case err != nil:
+			return err
+		}
+
+		records, err := recordsFromConfigMap(configMap)
+		if err != nil {
+			return err
+		}
+
+		record.Revision = 1
+		if len(records) > 0 {
+			record.Revision = records[len(records)-1].Revision + 1
+		}
+		records = append(records, record)
+		if len(records) > limit {
+			records = records[len(records)-limit:]
+		}
+
+		data, err := json.Marshal(records)
+		if err != nil {
+			return err
+		}
+		configMap.Data = map[string]string{recordsKey: string(data)}
+
+		if notFound {
+			_, err = client.Create(ctx, configMap, metav1.CreateOptions{})
+			return err
+		}
+		_, err = client.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save deploy history: %w", err)
+	}
+
+	return nil
+}
+
+// recordsFromConfigMap decode the records saved in configMap
+func recordsFromConfigMap(configMap *corev1.ConfigMap) ([]Record, error) {
+	records := make([]Record, 0)
+	data, found := configMap.Data[recordsKey]
+	if !found || len(data) == 0 {
+		return records, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &records); err != nil {
+		return nil, fmt.Errorf("failed to decode deploy history: %w", err)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,137 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-history-test"
+	tests := map[string]struct {
+		objects         []runtime.Object
+		expectedRecords []Record
+		expectedError   string
+	}{
+		"missing history": {
+			expectedRecords: []Record{},
+		},
+		"saved records": {
+			objects: []runtime.Object{
+				testConfigMap(namespace, `[{"revision":1,"timestamp":"2025-01-01T10:00:00Z","actor":"user","applied":2,"pruned":0,"failed":0,"duration":"5s","result":"succeeded"}]`),
+			},
+			expectedRecords: []Record{{
+				Revision:  1,
+				Timestamp: time.Date(2025, time.January, 1, 10, 0, 0, 0, time.UTC),
+				Actor:     "user",
+				Applied:   2,
+				Duration:  "5s",
+				Result:    ResultSucceeded,
+			}},
+		},
+		"broken data": {
+			objects:       []runtime.Object{testConfigMap(namespace, `{`)},
+			expectedError: "failed to decode deploy history",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			store := NewStore(fake.NewSimpleClientset(test.objects...), namespace)
+			records, err := store.List(context.TODO())
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedRecords, records)
+		})
+	}
+}
+
+func TestAppend(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-history-test"
+	clientset := fake.NewSimpleClientset()
+	store := NewStore(clientset, namespace)
+
+	for idx := range 5 {
+		record := Record{
+			Timestamp: time.Date(2025, time.January, 1, idx, 0, 0, 0, time.UTC),
+			Commit:    fmt.Sprintf("commit-%d", idx),
+			Result:    ResultSucceeded,
+		}
+		require.NoError(t, store.Append(context.TODO(), record, 3))
+	}
+
+	records, err := store.List(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	for idx, record := range records {
+		assert.Equal(t, int64(idx+3), record.Revision)
+		assert.Equal(t, fmt.Sprintf("commit-%d", idx+2), record.Commit)
+	}
+}
+
+func TestAppendRetryOnConflict(t *testing.T) {
+	t.Parallel()
+
+	namespace := "mlp-history-test"
+	clientset := fake.NewSimpleClientset(testConfigMap(namespace, `[{"revision":7,"result":"succeeded"}]`))
+	conflicts := 0
+	clientset.PrependReactor("update", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			return false, nil, nil
+		}
+		conflicts++
+		return true, nil, apierrors.NewConflict(corev1.Resource("configmaps"), ConfigMapName, fmt.Errorf("conflict"))
+	})
+
+	store := NewStore(clientset, namespace)
+	require.NoError(t, store.Append(context.TODO(), Record{Result: ResultFailed}, DefaultLimit))
+	assert.Equal(t, 1, conflicts)
+
+	records, err := store.List(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, int64(8), records[1].Revision)
+	assert.Equal(t, ResultFailed, records[1].Result)
+}
+
+func testConfigMap(namespace, data string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: namespace},
+		Data:       map[string]string{recordsKey: data},
+	}
+}
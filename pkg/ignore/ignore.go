@@ -0,0 +1,76 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ignore contains the support for the .mlpignore files that can be placed at the root of an input folder
+// to exclude some of its files and folders from the directory walks, using the gitignore syntax
+package ignore
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	gitignore "github.com/monochromegane/go-gitignore"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	// FileName is the name of the file containing the patterns of the paths to ignore inside a folder
+	FileName = ".mlpignore"
+)
+
+// Walk walks the file tree rooted at root calling walkFn for each file or folder in the tree, including root,
+// like fSys.Walk does, but skipping the paths matching the patterns found in the .mlpignore file at the root of
+// the tree, if any
+func Walk(fSys filesys.FileSystem, root string, walkFn filepath.WalkFunc) error {
+	var matcher gitignore.IgnoreMatcher
+	return fSys.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return walkFn(path, info, err)
+		}
+
+		// the root is always visited first, use the path returned by the filesystem as the base for the patterns
+		if matcher == nil {
+			if matcher, err = readMatcher(fSys, path); err != nil {
+				return err
+			}
+			return walkFn(path, info, nil)
+		}
+
+		if filepath.Base(path) == FileName || matcher.Match(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		return walkFn(path, info, nil)
+	})
+}
+
+func readMatcher(fSys filesys.FileSystem, root string) (gitignore.IgnoreMatcher, error) {
+	ignoreFilePath := filepath.Join(root, FileName)
+	if !fSys.IsDir(root) || !fSys.Exists(ignoreFilePath) {
+		return gitignore.DummyIgnoreMatcher(false), nil
+	}
+
+	data, err := fSys.ReadFile(ignoreFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s file: %w", FileName, err)
+	}
+
+	return gitignore.NewGitIgnoreFromReader(root, bytes.NewReader(data)), nil
+}
@@ -0,0 +1,104 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ignore
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		ignoreFile    string
+		expectedFiles []string
+	}{
+		"without ignore file": {
+			expectedFiles: []string{
+				"README.md",
+				"deployment.yaml",
+				"partials/container.yaml",
+				"secret.example.yaml",
+				"service.yaml",
+			},
+		},
+		"ignore files and folders": {
+			ignoreFile: "# documentation and partial templates\nREADME.md\n*.example.yaml\npartials/\n",
+			expectedFiles: []string{
+				"deployment.yaml",
+				"service.yaml",
+			},
+		},
+		"negated patterns": {
+			ignoreFile: "*.yaml\n!service.yaml\n",
+			expectedFiles: []string{
+				"README.md",
+				"service.yaml",
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			fSys := filesys.MakeFsInMemory()
+			for _, path := range []string{"README.md", "deployment.yaml", "partials/container.yaml", "secret.example.yaml", "service.yaml"} {
+				require.NoError(t, fSys.WriteFile(filepath.Join("input", path), []byte("content")))
+			}
+			if len(test.ignoreFile) > 0 {
+				require.NoError(t, fSys.WriteFile(filepath.Join("input", FileName), []byte(test.ignoreFile)))
+			}
+
+			var files []string
+			err := Walk(fSys, "input", func(path string, info fs.FileInfo, err error) error {
+				require.NoError(t, err)
+				if info.IsDir() {
+					return nil
+				}
+				relPath, err := filepath.Rel("/input", path)
+				require.NoError(t, err)
+				files = append(files, relPath)
+				return nil
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedFiles, files)
+		})
+	}
+}
+
+func TestWalkOnDisk(t *testing.T) {
+	t.Parallel()
+
+	var files []string
+	err := Walk(filesys.MakeFsOnDisk(), "testdata", func(path string, info fs.FileInfo, err error) error {
+		require.NoError(t, err)
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join("testdata", "deployment.yaml")}, files)
+}
@@ -0,0 +1,111 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	dockerConfigEnv      = "DOCKER_CONFIG"
+	dockerConfigFileName = "config.json"
+	dockerHubRegistry    = "https://index.docker.io/v1/"
+)
+
+// StaticCredentials return a CredentialsFunc that always return credentials
+func StaticCredentials(credentials Credentials) CredentialsFunc {
+	return func(string) (Credentials, error) {
+		return credentials, nil
+	}
+}
+
+// DockerConfigCredentials return a CredentialsFunc that read the credentials saved by docker login in the
+// config.json file of the DOCKER_CONFIG folder, or of the .docker folder in the user home; a missing file
+// means anonymous access
+func DockerConfigCredentials() CredentialsFunc {
+	return func(registry string) (Credentials, error) {
+		dir := os.Getenv(dockerConfigEnv)
+		if len(dir) == 0 {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return Credentials{}, nil
+			}
+			dir = filepath.Join(home, ".docker")
+		}
+
+		return credentialsFromFile(filepath.Join(dir, dockerConfigFileName), registry)
+	}
+}
+
+// credentialsFromFile return the credentials for registry saved in the docker config file at path
+func credentialsFromFile(path, registry string) (Credentials, error) {
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return Credentials{}, nil
+	case err != nil:
+		return Credentials{}, fmt.Errorf("failed to read docker config: %w", err)
+	}
+
+	config := struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Credentials{}, fmt.Errorf("failed to parse docker config %s: %w", path, err)
+	}
+
+	for key, auth := range config.Auths {
+		if !matchRegistry(key, registry) {
+			continue
+		}
+
+		if len(auth.Auth) == 0 {
+			return Credentials{Username: auth.Username, Password: auth.Password}, nil
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("invalid auth for %s in docker config: %w", key, err)
+		}
+		username, password, _ := strings.Cut(string(decoded), ":")
+		return Credentials{Username: username, Password: password}, nil
+	}
+
+	return Credentials{}, nil
+}
+
+// matchRegistry return true if the key of the docker config auths refers to registry, the keys can contain the
+// scheme and a path like the one used for Docker Hub
+func matchRegistry(key, registry string) bool {
+	if key == dockerHubRegistry {
+		return registry == "docker.io" || registry == "index.docker.io" || registry == "registry-1.docker.io"
+	}
+
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	host, _, _ := strings.Cut(key, "/")
+	return host == registry
+}
@@ -0,0 +1,74 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerConfigCredentials(t *testing.T) {
+	dir := t.TempDir()
+	config := `{
+	"auths": {
+		"registry.example.com": {"auth": "dXNlcjpzZWNyZXQ="},
+		"https://other.example.com/v2/": {"username": "other", "password": "password"},
+		"https://index.docker.io/v1/": {"auth": "aHViOmh1Yi1zZWNyZXQ="}
+	}
+}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600))
+	t.Setenv("DOCKER_CONFIG", dir)
+
+	tests := map[string]struct {
+		registry            string
+		expectedCredentials Credentials
+	}{
+		"encoded auth": {
+			registry:            "registry.example.com",
+			expectedCredentials: Credentials{Username: "user", Password: "secret"},
+		},
+		"key with scheme and path": {
+			registry:            "other.example.com",
+			expectedCredentials: Credentials{Username: "other", Password: "password"},
+		},
+		"docker hub": {
+			registry:            "docker.io",
+			expectedCredentials: Credentials{Username: "hub", Password: "hub-secret"},
+		},
+		"unknown registry": {
+			registry: "unknown.example.com",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			credentials, err := DockerConfigCredentials()(test.registry)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCredentials, credentials)
+		})
+	}
+
+	t.Run("missing config", func(t *testing.T) {
+		t.Setenv("DOCKER_CONFIG", t.TempDir())
+		credentials, err := DockerConfigCredentials()("registry.example.com")
+		require.NoError(t, err)
+		assert.Equal(t, Credentials{}, credentials)
+	})
+}
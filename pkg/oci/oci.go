@@ -0,0 +1,401 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Scheme is the prefix of the references to OCI artifacts
+	Scheme = "oci://"
+
+	// ManifestMediaType is the media type of the OCI image manifests
+	ManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	// EmptyMediaType is the media type of the empty config used by the artifacts
+	EmptyMediaType = "application/vnd.oci.empty.v1+json"
+
+	// AnnotationCreated contains the date and time when the artifact was created, in RFC 3339 format
+	AnnotationCreated = "org.opencontainers.image.created"
+	// AnnotationRevision contains the source control revision of the artifact content
+	AnnotationRevision = "org.opencontainers.image.revision"
+	// AnnotationTitle contains the file name of a layer
+	AnnotationTitle = "org.opencontainers.image.title"
+
+	requestTimeout = 5 * time.Minute
+)
+
+var emptyConfig = []byte("{}")
+
+// Reference identify a tagged artifact in a repository of a registry
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+}
+
+// ParseReference return the Reference contained in value, that must be in the form oci://registry/repository:tag
+func ParseReference(value string) (Reference, error) {
+	reference := Reference{}
+	trimmed, found := strings.CutPrefix(value, Scheme)
+	if !found {
+		return reference, fmt.Errorf("invalid reference %q: must start with %s", value, Scheme)
+	}
+
+	registry, repository, found := strings.Cut(trimmed, "/")
+	if !found || len(registry) == 0 || len(repository) == 0 {
+		return reference, fmt.Errorf("invalid reference %q: must be in the form %sregistry/repository:tag", value, Scheme)
+	}
+
+	index := strings.LastIndex(repository, ":")
+	if index == -1 || index == len(repository)-1 || strings.Contains(repository[index:], "/") {
+		return reference, fmt.Errorf("invalid reference %q: the tag is missing", value)
+	}
+
+	reference.Registry = registry
+	reference.Repository = repository[:index]
+	reference.Tag = repository[index+1:]
+	if strings.ToLower(reference.Repository) != reference.Repository {
+		return reference, fmt.Errorf("invalid reference %q: the repository must be lowercase", value)
+	}
+	return reference, nil
+}
+
+// String implement fmt.Stringer interface
+func (r Reference) String() string {
+	return Scheme + r.Registry + "/" + r.Repository + ":" + r.Tag
+}
+
+// Layer is a file contained in an Artifact
+type Layer struct {
+	MediaType   string
+	Data        []byte
+	Annotations map[string]string
+}
+
+// Artifact contains the layers and the annotations of an OCI artifact
+type Artifact struct {
+	ArtifactType string
+	Layers       []Layer
+	Annotations  map[string]string
+}
+
+// Descriptor describe a content addressable blob
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest is the OCI image manifest of an Artifact
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Credentials used for authenticating to a registry, they are empty for anonymous access
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialsFunc return the Credentials to use for registry
+type CredentialsFunc func(registry string) (Credentials, error)
+
+// Client push artifacts to the registries implementing the OCI distribution specification
+type Client struct {
+	client      *http.Client
+	plainHTTP   bool
+	credentials CredentialsFunc
+
+	tokens map[string]string
+}
+
+// NewClient return a new Client using credentials for authenticating to the registries, if plainHTTP is true the
+// registries are contacted without TLS
+func NewClient(plainHTTP bool, credentials CredentialsFunc) *Client {
+	return &Client{
+		client:      &http.Client{Timeout: requestTimeout},
+		plainHTTP:   plainHTTP,
+		credentials: credentials,
+		tokens:      make(map[string]string),
+	}
+}
+
+// Push upload artifact to the repository of reference and tag it, returning the digest of its manifest; the blobs
+// already present in the repository are not uploaded again
+func (c *Client) Push(ctx context.Context, reference Reference, artifact Artifact) (string, error) {
+	manifest := Manifest{
+		SchemaVersion: 2,
+		MediaType:     ManifestMediaType,
+		ArtifactType:  artifact.ArtifactType,
+		Config:        descriptorFor(EmptyMediaType, emptyConfig, nil),
+		Layers:        make([]Descriptor, 0, len(artifact.Layers)),
+		Annotations:   artifact.Annotations,
+	}
+
+	if err := c.pushBlob(ctx, reference, manifest.Config, emptyConfig); err != nil {
+		return "", err
+	}
+
+	for _, layer := range artifact.Layers {
+		descriptor := descriptorFor(layer.MediaType, layer.Data, layer.Annotations)
+		if err := c.pushBlob(ctx, reference, descriptor, layer.Data); err != nil {
+			return "", err
+		}
+		manifest.Layers = append(manifest.Layers, descriptor)
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	manifestURL := c.url(reference, "manifests", reference.Tag)
+	response, err := c.do(ctx, reference, http.MethodPut, manifestURL, ManifestMediaType, data)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("failed to push the manifest to %s: %s", reference, responseError(response))
+	}
+
+	return digestOf(data), nil
+}
+
+// pushBlob upload data described by descriptor in the repository of reference if it is not already present
+func (c *Client) pushBlob(ctx context.Context, reference Reference, descriptor Descriptor, data []byte) error {
+	response, err := c.do(ctx, reference, http.MethodHead, c.url(reference, "blobs", descriptor.Digest), "", nil)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	response, err = c.do(ctx, reference, http.MethodPost, c.url(reference, "blobs", "uploads")+"/", "", nil)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("failed to start the upload of %s: %s", descriptor.Digest, responseError(response))
+	}
+
+	location, err := response.Request.URL.Parse(response.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location for %s: %w", descriptor.Digest, err)
+	}
+	query := location.Query()
+	query.Set("digest", descriptor.Digest)
+	location.RawQuery = query.Encode()
+
+	response, err = c.do(ctx, reference, http.MethodPut, location.String(), "application/octet-stream", data)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusCreated {
+		return fmt.Errorf("failed to upload %s: %s", descriptor.Digest, responseError(response))
+	}
+
+	return nil
+}
+
+// do send a request to the registry of reference, authenticating it if the registry asks for credentials
+func (c *Client) do(ctx context.Context, reference Reference, method, requestURL, contentType string, body []byte) (*http.Response, error) {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, requestURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if len(contentType) > 0 {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if token, found := c.tokens[reference.Registry]; found {
+			req.Header.Set("Authorization", token)
+		}
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.client.Do(req)
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+
+	_, _ = io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+	if err := c.authenticate(ctx, reference, response.Header.Get("WWW-Authenticate")); err != nil {
+		return nil, err
+	}
+
+	if req, err = newRequest(); err != nil {
+		return nil, err
+	}
+	return c.client.Do(req)
+}
+
+// authenticate save the authorization header to use with the registry of reference following challenge, requesting
+// a bearer token if needed
+func (c *Client) authenticate(ctx context.Context, reference Reference, challenge string) error {
+	credentials, err := c.credentials(reference.Registry)
+	if err != nil {
+		return err
+	}
+
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if len(credentials.Username) == 0 {
+			return fmt.Errorf("registry %s requires credentials", reference.Registry)
+		}
+		auth := base64.StdEncoding.EncodeToString([]byte(credentials.Username + ":" + credentials.Password))
+		c.tokens[reference.Registry] = "Basic " + auth
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("registry %s requires an unsupported authentication scheme %q", reference.Registry, scheme)
+	}
+
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil || len(params["realm"]) == 0 {
+		return fmt.Errorf("registry %s returned an invalid token realm %q", reference.Registry, params["realm"])
+	}
+	query := tokenURL.Query()
+	if service, found := params["service"]; found {
+		query.Set("service", service)
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull,push", reference.Repository))
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	if len(credentials.Username) > 0 {
+		req.SetBasicAuth(credentials.Username, credentials.Password)
+	}
+
+	response, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to authenticate to %s: %s", reference.Registry, responseError(response))
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return fmt.Errorf("failed to read the token of %s: %w", reference.Registry, err)
+	}
+
+	value := token.Token
+	if len(value) == 0 {
+		value = token.AccessToken
+	}
+	c.tokens[reference.Registry] = "Bearer " + value
+	return nil
+}
+
+// url return the url of the API endpoint kind for the repository of reference
+func (c *Client) url(reference Reference, kind, name string) string {
+	scheme := "https"
+	if c.plainHTTP {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s/%s", scheme, reference.Registry, reference.Repository, kind, name)
+}
+
+// parseChallenge return the scheme and the parameters of a WWW-Authenticate header value
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := make(map[string]string)
+	for len(rest) > 0 {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		if len(key) > 0 {
+			params[strings.ToLower(strings.TrimSpace(key))] = value
+		}
+	}
+	return scheme, params
+}
+
+// responseError return a description of the error contained in response
+func responseError(response *http.Response) string {
+	errorsBody := struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<16)).Decode(&errorsBody); err != nil || len(errorsBody.Errors) == 0 {
+		return response.Status
+	}
+
+	messages := make([]string, 0, len(errorsBody.Errors))
+	for _, registryError := range errorsBody.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", registryError.Code, registryError.Message))
+	}
+	return fmt.Sprintf("%s (%s)", response.Status, strings.Join(messages, ", "))
+}
+
+// descriptorFor return the descriptor of data with mediaType and annotations
+func descriptorFor(mediaType string, data []byte, annotations map[string]string) Descriptor {
+	return Descriptor{
+		MediaType:   mediaType,
+		Digest:      digestOf(data),
+		Size:        int64(len(data)),
+		Annotations: annotations,
+	}
+}
+
+// digestOf return the sha256 digest of data in the OCI format
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
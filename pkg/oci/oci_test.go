@@ -0,0 +1,259 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry is an in memory registry implementing the push endpoints of the distribution specification
+type testRegistry struct {
+	*httptest.Server
+
+	lock      sync.Mutex
+	auth      string
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   int
+}
+
+// newTestRegistry return a running registry, auth can be empty, basic or bearer
+func newTestRegistry(t *testing.T, auth string) *testRegistry {
+	t.Helper()
+
+	registry := &testRegistry{
+		auth:      auth,
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+	}
+	registry.Server = httptest.NewServer(http.HandlerFunc(registry.handle))
+	t.Cleanup(registry.Close)
+	return registry
+}
+
+func (r *testRegistry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func (r *testRegistry) handle(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if req.URL.Path == "/token" {
+		username, password, ok := req.BasicAuth()
+		if !ok || username != "user" || password != "secret" || req.URL.Query().Get("scope") != "repository:team/app:pull,push" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"token":"test-token"}`))
+		return
+	}
+
+	switch r.auth {
+	case "basic":
+		if username, password, ok := req.BasicAuth(); !ok || username != "user" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	case "bearer":
+		if req.Header.Get("Authorization") != "Bearer test-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry"`, r.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"UNAUTHORIZED","message":"authentication required"}]}`))
+			return
+		}
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	path := strings.TrimPrefix(req.URL.Path, "/v2/team/app/")
+	switch {
+	case req.Method == http.MethodHead && strings.HasPrefix(path, "blobs/"):
+		if _, found := r.blobs[strings.TrimPrefix(path, "blobs/")]; !found {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case req.Method == http.MethodPost && path == "blobs/uploads/":
+		r.uploads++
+		w.Header().Set("Location", fmt.Sprintf("/v2/team/app/blobs/uploads/%d?state=test", r.uploads))
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodPut && strings.HasPrefix(path, "blobs/uploads/"):
+		digest := req.URL.Query().Get("digest")
+		if digestOf(body) != digest || req.URL.Query().Get("state") != "test" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"DIGEST_INVALID","message":"provided digest did not match uploaded content"}]}`))
+			return
+		}
+		r.blobs[digest] = body
+		w.WriteHeader(http.StatusCreated)
+	case req.Method == http.MethodPut && strings.HasPrefix(path, "manifests/"):
+		if req.Header.Get("Content-Type") != ManifestMediaType {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.manifests[strings.TrimPrefix(path, "manifests/")] = body
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		value             string
+		expectedReference Reference
+		expectedError     string
+	}{
+		"valid reference": {
+			value:             "oci://registry.example.com/team/app:v1.0.0",
+			expectedReference: Reference{Registry: "registry.example.com", Repository: "team/app", Tag: "v1.0.0"},
+		},
+		"registry with port": {
+			value:             "oci://localhost:5000/app:latest",
+			expectedReference: Reference{Registry: "localhost:5000", Repository: "app", Tag: "latest"},
+		},
+		"missing scheme": {
+			value:         "registry.example.com/app:v1",
+			expectedError: `invalid reference "registry.example.com/app:v1": must start with oci://`,
+		},
+		"missing repository": {
+			value:         "oci://registry.example.com",
+			expectedError: `invalid reference "oci://registry.example.com": must be in the form oci://registry/repository:tag`,
+		},
+		"missing tag": {
+			value:         "oci://localhost:5000/app",
+			expectedError: `invalid reference "oci://localhost:5000/app": the tag is missing`,
+		},
+		"uppercase repository": {
+			value:         "oci://registry.example.com/App:v1",
+			expectedError: `invalid reference "oci://registry.example.com/App:v1": the repository must be lowercase`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			reference, err := ParseReference(test.value)
+			if len(test.expectedError) > 0 {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedReference, reference)
+			assert.Equal(t, test.value, reference.String())
+		})
+	}
+}
+
+func TestPush(t *testing.T) {
+	t.Parallel()
+
+	artifact := Artifact{
+		ArtifactType: "application/vnd.example.test.v1",
+		Layers: []Layer{
+			{MediaType: "application/vnd.example.layer.v1", Data: []byte("content"), Annotations: map[string]string{AnnotationTitle: "content.txt"}},
+		},
+		Annotations: map[string]string{AnnotationRevision: "abc123"},
+	}
+
+	tests := map[string]struct {
+		auth          string
+		credentials   Credentials
+		expectedError string
+	}{
+		"anonymous": {},
+		"basic authentication": {
+			auth:        "basic",
+			credentials: Credentials{Username: "user", Password: "secret"},
+		},
+		"bearer token": {
+			auth:        "bearer",
+			credentials: Credentials{Username: "user", Password: "secret"},
+		},
+		"basic authentication without credentials": {
+			auth:          "basic",
+			expectedError: "requires credentials",
+		},
+		"bearer token with wrong credentials": {
+			auth:          "bearer",
+			credentials:   Credentials{Username: "user", Password: "wrong"},
+			expectedError: "failed to authenticate to",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			registry := newTestRegistry(t, test.auth)
+			reference := Reference{Registry: registry.host(), Repository: "team/app", Tag: "v1"}
+			client := NewClient(true, StaticCredentials(test.credentials))
+
+			digest, err := client.Push(context.TODO(), reference, artifact)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			data, found := registry.manifests["v1"]
+			require.True(t, found)
+			assert.Equal(t, digestOf(data), digest)
+
+			manifest := Manifest{}
+			require.NoError(t, json.Unmarshal(data, &manifest))
+			assert.Equal(t, ManifestMediaType, manifest.MediaType)
+			assert.Equal(t, "application/vnd.example.test.v1", manifest.ArtifactType)
+			assert.Equal(t, map[string]string{AnnotationRevision: "abc123"}, manifest.Annotations)
+			assert.Equal(t, EmptyMediaType, manifest.Config.MediaType)
+			assert.Equal(t, []byte("{}"), registry.blobs[manifest.Config.Digest])
+			require.Len(t, manifest.Layers, 1)
+			assert.Equal(t, "content.txt", manifest.Layers[0].Annotations[AnnotationTitle])
+			assert.Equal(t, int64(7), manifest.Layers[0].Size)
+			assert.Equal(t, []byte("content"), registry.blobs[manifest.Layers[0].Digest])
+		})
+	}
+
+	t.Run("existing blobs are not uploaded again", func(t *testing.T) {
+		t.Parallel()
+
+		registry := newTestRegistry(t, "")
+		reference := Reference{Registry: registry.host(), Repository: "team/app", Tag: "v1"}
+		client := NewClient(true, StaticCredentials(Credentials{}))
+
+		_, err := client.Push(context.TODO(), reference, artifact)
+		require.NoError(t, err)
+		reference.Tag = "v2"
+		_, err = client.Push(context.TODO(), reference, artifact)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, registry.uploads)
+		assert.Equal(t, registry.manifests["v1"], registry.manifests["v2"])
+	})
+}
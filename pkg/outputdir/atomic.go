@@ -0,0 +1,95 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package outputdir contains the helpers for writing the files read by the next stages of a pipeline, like the
+// interpolated manifests or the generated resources, without exposing them half-written to the other pipelines
+// sharing the same workspace.
+package outputdir
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// defaultFileMode is the permission used for the new files
+const defaultFileMode os.FileMode = 0o644
+
+// atomicFsOnDisk is a filesys.FileSystem on disk that write the files atomically, so they are never read
+// half-written by another process
+type atomicFsOnDisk struct {
+	filesys.FileSystem
+}
+
+// MakeFsOnDisk return a filesys.FileSystem on disk that write every file in a temporary file in the same folder and
+// rename it to its final path when it is complete
+func MakeFsOnDisk() filesys.FileSystem {
+	return &atomicFsOnDisk{FileSystem: filesys.MakeFsOnDisk()}
+}
+
+// keep it to always check if atomicFsOnDisk implement correctly the filesys.FileSystem interface
+var _ filesys.FileSystem = &atomicFsOnDisk{}
+
+// WriteFile implement filesys.FileSystem interface
+func (fs *atomicFsOnDisk) WriteFile(path string, data []byte) error {
+	return WriteFileAtomically(path, data)
+}
+
+// WriteFileAtomically write data in a temporary file in the folder of path and rename it to path, the permissions
+// of an existing file are kept
+func WriteFileAtomically(path string, data []byte) error {
+	mode := defaultFileMode
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%q is a directory", path)
+		}
+		mode = info.Mode().Perm()
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := file.Name()
+	if err := writeAndClose(file, data, mode); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// writeAndClose write data in file flushing it on disk, set its permissions to mode and close it
+func writeAndClose(file *os.File, data []byte, mode os.FileMode) error {
+	_, err := file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err == nil {
+		err = file.Chmod(mode)
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputdir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomically(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	existingFile := filepath.Join(tmpDir, "existing.yaml")
+	require.NoError(t, os.WriteFile(existingFile, []byte("old"), 0o600))
+	require.NoError(t, os.Chmod(existingFile, 0o600))
+
+	tests := map[string]struct {
+		path          string
+		expectedMode  os.FileMode
+		expectedError string
+	}{
+		"new file": {
+			path:         filepath.Join(tmpDir, "new.yaml"),
+			expectedMode: defaultFileMode,
+		},
+		"existing file keep its permissions": {
+			path:         existingFile,
+			expectedMode: 0o600,
+		},
+		"directory": {
+			path:          tmpDir,
+			expectedError: "is a directory",
+		},
+		"missing folder": {
+			path:          filepath.Join(tmpDir, "missing", "file.yaml"),
+			expectedError: "no such file or directory",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := WriteFileAtomically(test.path, []byte("data"))
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			data, err := os.ReadFile(test.path)
+			require.NoError(t, err)
+			assert.Equal(t, "data", string(data))
+			info, err := os.Stat(test.path)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedMode, info.Mode().Perm())
+		})
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), ".tmp-", "temporary files must be removed")
+	}
+}
+
+func TestMakeFsOnDisk(t *testing.T) {
+	t.Parallel()
+
+	fSys := MakeFsOnDisk()
+	path := filepath.Join(t.TempDir(), "file.yaml")
+	require.NoError(t, fSys.WriteFile(path, []byte("data")))
+
+	data, err := fSys.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
@@ -0,0 +1,67 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputdir
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// IndexFileName is the name of the file listing the files produced in a run scoped output directory, it is not a
+// yaml file so it will not be read as a manifest by the next stages
+const IndexFileName = "index.json"
+
+// Index contains the list of the files produced by a command in a run scoped output directory
+type Index struct {
+	RunID   string   `json:"runID"`
+	Command string   `json:"command"`
+	Files   []string `json:"files"`
+}
+
+// RunPath return the output directory for the run identified by runID inside outputPath, runID must be usable
+// as the name of a single directory
+func RunPath(outputPath, runID string) (string, error) {
+	if runID == "." || runID == ".." || strings.ContainsAny(runID, `/\`) || len(strings.TrimSpace(runID)) == 0 {
+		return "", fmt.Errorf("invalid run id %q: it must be a valid directory name", runID)
+	}
+
+	return filepath.Join(outputPath, runID), nil
+}
+
+// WriteIndex save in dir the index of the files produced by command in the run identified by runID, paths inside
+// dir are listed relative to it
+func WriteIndex(fSys filesys.FileSystem, dir, runID, command string, files []string) error {
+	relativeFiles := make([]string, 0, len(files))
+	for _, file := range files {
+		if rel, err := filepath.Rel(dir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+		relativeFiles = append(relativeFiles, filepath.ToSlash(file))
+	}
+	slices.Sort(relativeFiles)
+
+	data, err := json.MarshalIndent(Index{RunID: runID, Command: command, Files: slices.Compact(relativeFiles)}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return fSys.WriteFile(filepath.Join(dir, IndexFileName), append(data, '\n'))
+}
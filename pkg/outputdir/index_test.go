@@ -0,0 +1,94 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputdir
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestRunPath(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		runID         string
+		expectedPath  string
+		expectedError string
+	}{
+		"valid run id": {
+			runID:        "1234",
+			expectedPath: filepath.Join("output", "1234"),
+		},
+		"blank run id": {
+			runID:         " ",
+			expectedError: `invalid run id " "`,
+		},
+		"parent folder": {
+			runID:         "..",
+			expectedError: `invalid run id ".."`,
+		},
+		"current folder": {
+			runID:         ".",
+			expectedError: `invalid run id "."`,
+		},
+		"nested folder": {
+			runID:         "run/1234",
+			expectedError: `invalid run id "run/1234"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path, err := RunPath("output", test.runID)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPath, path)
+		})
+	}
+}
+
+func TestWriteIndex(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.MkdirAll("/output/run"))
+
+	files := []string{"second.yaml", "/output/run/first.yaml", "second.yaml"}
+	require.NoError(t, WriteIndex(fSys, "/output/run", "run", "generate", files))
+
+	data, err := fSys.ReadFile("/output/run/" + IndexFileName)
+	require.NoError(t, err)
+	expectedIndex := `{
+  "runID": "run",
+  "command": "generate",
+  "files": [
+    "first.yaml",
+    "second.yaml"
+  ]
+}
+`
+	assert.Equal(t, expectedIndex, string(data))
+}
@@ -0,0 +1,104 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputdir
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// LayoutFlat save all the files directly inside the output directory
+	LayoutFlat = "flat"
+	// LayoutConsole save the files in a folder for every namespace, separating configurations, secrets and workloads,
+	// with a kustomization file listing all of them like the repositories handled by Mia-Platform Console
+	LayoutConsole = "console"
+
+	// ConsoleIndexFileName is the name of the kustomization file listing the resources saved with the console layout
+	ConsoleIndexFileName = "kustomization.yaml"
+
+	consoleClusterFolder       = "cluster"
+	consoleConfigurationFolder = "configuration"
+	consoleSecretsFolder       = "secrets"
+	consoleWorkloadsFolder     = "workloads"
+)
+
+// ValidLayouts are the layouts that can be used for saving the files in an output directory
+var ValidLayouts = []string{LayoutFlat, LayoutConsole}
+
+// consoleWorkloads are the kinds saved in the workloads folder of the console layout
+var consoleWorkloads = []schema.GroupKind{
+	{Group: "", Kind: "Pod"},
+	{Group: "apps", Kind: "Deployment"},
+	{Group: "apps", Kind: "StatefulSet"},
+	{Group: "apps", Kind: "DaemonSet"},
+	{Group: "apps", Kind: "ReplicaSet"},
+	{Group: "batch", Kind: "Job"},
+	{Group: "batch", Kind: "CronJob"},
+}
+
+// ConsolePath return the path relative to the output directory of the file named fileName containing a resource of
+// type gk in namespace, following the console layout; cluster scoped resources are saved in the cluster folder
+func ConsolePath(namespace string, gk schema.GroupKind, fileName string) string {
+	folder := consoleConfigurationFolder
+	switch {
+	case gk == schema.GroupKind{Group: "", Kind: "Secret"}:
+		folder = consoleSecretsFolder
+	case slices.Contains(consoleWorkloads, gk):
+		folder = consoleWorkloadsFolder
+	}
+
+	if len(namespace) == 0 {
+		namespace = consoleClusterFolder
+	}
+
+	return filepath.Join(namespace, folder, fileName)
+}
+
+// consoleIndex is the kustomization file written as index of the console layout
+type consoleIndex struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Resources  []string `json:"resources"`
+}
+
+// WriteConsoleIndex save in dir the kustomization file listing files, paths inside dir are listed relative to it
+func WriteConsoleIndex(fSys filesys.FileSystem, dir string, files []string) error {
+	resources := make([]string, 0, len(files))
+	for _, file := range files {
+		if rel, err := filepath.Rel(dir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+		resources = append(resources, filepath.ToSlash(file))
+	}
+	slices.Sort(resources)
+
+	data, err := yaml.Marshal(consoleIndex{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Resources:  slices.Compact(resources),
+	})
+	if err != nil {
+		return err
+	}
+
+	return fSys.WriteFile(filepath.Join(dir, ConsoleIndexFileName), data)
+}
@@ -0,0 +1,98 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputdir
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestConsolePath(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace    string
+		gk           schema.GroupKind
+		expectedPath string
+	}{
+		"configmap": {
+			namespace:    "production",
+			gk:           schema.GroupKind{Kind: "ConfigMap"},
+			expectedPath: filepath.Join("production", "configuration", "file.yaml"),
+		},
+		"secret": {
+			namespace:    "production",
+			gk:           schema.GroupKind{Kind: "Secret"},
+			expectedPath: filepath.Join("production", "secrets", "file.yaml"),
+		},
+		"deployment": {
+			namespace:    "production",
+			gk:           schema.GroupKind{Group: "apps", Kind: "Deployment"},
+			expectedPath: filepath.Join("production", "workloads", "file.yaml"),
+		},
+		"cronjob": {
+			namespace:    "staging",
+			gk:           schema.GroupKind{Group: "batch", Kind: "CronJob"},
+			expectedPath: filepath.Join("staging", "workloads", "file.yaml"),
+		},
+		"custom resource with a workload kind": {
+			namespace:    "production",
+			gk:           schema.GroupKind{Group: "example.com", Kind: "Deployment"},
+			expectedPath: filepath.Join("production", "configuration", "file.yaml"),
+		},
+		"cluster scoped resource": {
+			gk:           schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+			expectedPath: filepath.Join("cluster", "configuration", "file.yaml"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expectedPath, ConsolePath(test.namespace, test.gk, "file.yaml"))
+		})
+	}
+}
+
+func TestWriteConsoleIndex(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.MkdirAll("/output"))
+
+	files := []string{
+		"/output/production/workloads/deployment.apps_api.yaml",
+		"production/configuration/configmap_api.yaml",
+		"/output/production/workloads/deployment.apps_api.yaml",
+	}
+	require.NoError(t, WriteConsoleIndex(fSys, "/output", files))
+
+	data, err := fSys.ReadFile("/output/" + ConsoleIndexFileName)
+	require.NoError(t, err)
+	expectedIndex := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- production/configuration/configmap_api.yaml
+- production/workloads/deployment.apps_api.yaml
+`
+	assert.Equal(t, expectedIndex, string(data))
+}
@@ -0,0 +1,239 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resourceutil
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/mia-platform/mlp/v2/pkg/ignore"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// jsonSniffSize is the number of bytes read ahead for understanding if the stream contains JSON objects
+	jsonSniffSize = 1024
+)
+
+// DocumentError is returned when a document of a stream cannot be read or decoded, it reports the position of the
+// document in the stream and, when they can be found in its text, its kind and name for identifying it also when
+// the stream is read from stdin
+type DocumentError struct {
+	// Operation is the step that has failed, reading or decoding
+	Operation string
+	// Index is the position of the document in the stream, starting from 0
+	Index int
+	// Hint contains the kind and name of the document, or of the one preceding it if it cannot be read
+	Hint string
+	Err  error
+}
+
+func (e *DocumentError) Error() string {
+	if len(e.Hint) == 0 {
+		return fmt.Sprintf("%s document %d: %s", e.Operation, e.Index, e.Err)
+	}
+	return fmt.Sprintf("%s document %d (%s): %s", e.Operation, e.Index, e.Hint, e.Err)
+}
+
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeEach decode one at a time the objects contained in the YAML or JSON stream in reader and call fn for each
+// of them. Only a single document is kept in memory, so large streams can be read without loading them whole, and
+// the documents are split only on the separators at the start of a line, leaving untouched the ones indented inside
+// block scalars. Empty documents are skipped.
+func DecodeEach(reader io.Reader, fn func(*unstructured.Unstructured) error) error {
+	stream, _, isJSON := utilyaml.GuessJSONStream(reader, jsonSniffSize)
+	if isJSON {
+		return decodeJSONStream(stream, fn)
+	}
+
+	yamlReader := utilyaml.NewYAMLReader(bufio.NewReader(stream))
+	lastHint := ""
+	for index := 0; ; index++ {
+		document, err := yamlReader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			hint := ""
+			if len(lastHint) > 0 {
+				hint = "after " + lastHint
+			}
+			return &DocumentError{Operation: "reading", Index: index, Hint: hint, Err: err}
+		}
+
+		hint := documentHint(document)
+		object := make(map[string]interface{})
+		if err := yaml.Unmarshal(document, &object); err != nil {
+			return &DocumentError{Operation: "decoding", Index: index, Hint: hint, Err: err}
+		}
+		if len(hint) > 0 {
+			lastHint = hint
+		}
+		if len(object) == 0 {
+			continue
+		}
+
+		if err := fn(&unstructured.Unstructured{Object: object}); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeJSONStream call fn for every JSON object found in reader
+func decodeJSONStream(reader io.Reader, fn func(*unstructured.Unstructured) error) error {
+	decoder := json.NewDecoder(reader)
+	for index := 0; ; index++ {
+		object := make(map[string]interface{})
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return &DocumentError{Operation: "decoding", Index: index, Err: err}
+		}
+		if len(object) == 0 {
+			continue
+		}
+
+		if err := fn(&unstructured.Unstructured{Object: object}); err != nil {
+			return err
+		}
+	}
+}
+
+// Decode return all the objects contained in the YAML or JSON stream in reader
+func Decode(reader io.Reader) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	err := DecodeEach(reader, func(obj *unstructured.Unstructured) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// DecodeFile return all the objects contained in the file at path, reading it as a stream instead of loading it
+// whole in memory
+func DecodeFile(fSys filesys.FileSystem, path string) ([]*unstructured.Unstructured, error) {
+	file, err := fSys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Decode(file)
+}
+
+// DecodePaths return all the objects contained in the files found at paths, walking the folders recursively for
+// reading only the files with one of extensions and skipping the ones ignored by their .mlpignore file
+func DecodePaths(ctx context.Context, fSys filesys.FileSystem, paths []string, extensions []string) ([]*unstructured.Unstructured, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	var objects []*unstructured.Unstructured
+	for _, inputPath := range paths {
+		if !fSys.Exists(inputPath) {
+			return nil, fmt.Errorf("no such file or directory: %s", inputPath)
+		}
+
+		err := ignore.Walk(fSys, inputPath, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !slices.Contains(extensions, filepath.Ext(path)) {
+				return nil
+			}
+
+			logger.V(5).Info("reading resources", "path", path)
+			fileObjects, err := DecodeFile(fSys, path)
+			if err != nil {
+				return fmt.Errorf("failed to read %q: %w", path, err)
+			}
+			objects = append(objects, fileObjects...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return objects, nil
+}
+
+// documentHint return the kind and name found in the text of document as Kind/name, without decoding it so they
+// are found also in documents that are not valid YAML; an empty string is returned if neither of them is found
+func documentHint(document []byte) string {
+	var kind, name string
+	inMetadata := false
+	metadataIndent := 0
+	for _, line := range strings.Split(string(document), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		switch {
+		case indent == 0:
+			inMetadata = strings.HasPrefix(trimmed, "metadata:")
+			metadataIndent = 0
+			if strings.HasPrefix(trimmed, "kind:") {
+				kind = scalarValue(strings.TrimPrefix(trimmed, "kind:"))
+			}
+		case inMetadata:
+			// only the direct children of metadata are considered, skipping the labels called name
+			if metadataIndent == 0 {
+				metadataIndent = indent
+			}
+			if indent == metadataIndent && len(name) == 0 && strings.HasPrefix(trimmed, "name:") {
+				name = scalarValue(strings.TrimPrefix(trimmed, "name:"))
+			}
+		}
+	}
+
+	switch {
+	case len(kind) > 0 && len(name) > 0:
+		return kind + "/" + name
+	case len(kind) > 0:
+		return kind
+	default:
+		return name
+	}
+}
+
+// scalarValue return value without the spaces, the trailing comment and the surrounding quotes
+func scalarValue(value string) string {
+	if idx := strings.Index(value, " #"); idx >= 0 {
+		value = value[:idx]
+	}
+	return strings.Trim(strings.TrimSpace(value), `"'`)
+}
@@ -0,0 +1,298 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resourceutil
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data          string
+		expectedNames []string
+		expectedError string
+	}{
+		"multiple documents": {
+			data: `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+--- # comment after the separator
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: second
+`,
+			expectedNames: []string{"first", "second"},
+		},
+		"separator inside a block scalar": {
+			data: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+data:
+  file.yaml: |
+    key: value
+    ---
+    other: value
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: second
+`,
+			expectedNames: []string{"first", "second"},
+		},
+		"empty and comment only documents": {
+			data: `---
+# only a comment
+---
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+`,
+			expectedNames: []string{"first"},
+		},
+		"json stream": {
+			data: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "first"}}
+{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "second"}}`,
+			expectedNames: []string{"first", "second"},
+		},
+		"empty stream": {
+			data:          "",
+			expectedNames: []string{},
+		},
+		"invalid document": {
+			data: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+- not an object
+`,
+			expectedError: "decoding document 1: ",
+		},
+		"invalid document with kind and name": {
+			data: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    name: label
+  name: "api" # the api server
+spec:
+  replicas: [1
+`,
+			expectedError: "decoding document 1 (Deployment/api)",
+		},
+		"invalid separator after a document": {
+			data: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+kind: Secret
+--- invalid
+`,
+			expectedError: "reading document 1 (after ConfigMap/first): invalid Yaml document separator: invalid",
+		},
+		"invalid separator": {
+			data: `apiVersion: v1
+kind: ConfigMap
+--- invalid
+`,
+			expectedError: "reading document 0: invalid Yaml document separator: invalid",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			objects, err := Decode(strings.NewReader(test.data))
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			names := make([]string, 0, len(objects))
+			for _, obj := range objects {
+				names = append(names, obj.GetName())
+			}
+			assert.Equal(t, test.expectedNames, names)
+		})
+	}
+}
+
+func TestDecodePaths(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	files := map[string]string{
+		"input/configmap.yaml":          "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: configmap\n",
+		"input/nested/secret.yml":       "apiVersion: v1\nkind: Secret\nmetadata:\n  name: secret\n",
+		"input/service.json":            `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "service"}}`,
+		"input/README.md":               "not a resource",
+		"input/partials/container.yaml": "invalid: [",
+		"input/.mlpignore":              "partials/\n",
+		"single.yaml":                   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: single\n",
+	}
+	for path, content := range files {
+		require.NoError(t, fSys.WriteFile(path, []byte(content)))
+	}
+
+	tests := map[string]struct {
+		paths         []string
+		extensions    []string
+		expectedNames []string
+		expectedError string
+	}{
+		"folders are walked skipping ignored files": {
+			paths:         []string{"input", "single.yaml"},
+			extensions:    []string{".yaml", ".yml"},
+			expectedNames: []string{"configmap", "secret", "single"},
+		},
+		"only the files with the extensions are read": {
+			paths:         []string{"input"},
+			extensions:    []string{".json"},
+			expectedNames: []string{"service"},
+		},
+		"missing path": {
+			paths:         []string{"missing"},
+			extensions:    []string{".yaml"},
+			expectedError: "no such file or directory: missing",
+		},
+		"invalid file": {
+			paths:         []string{filepath.Join("input", "partials")},
+			extensions:    []string{".yaml"},
+			expectedError: `partials/container.yaml": decoding document 0`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			objects, err := DecodePaths(context.TODO(), fSys, test.paths, test.extensions)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			names := make([]string, 0, len(objects))
+			for _, obj := range objects {
+				names = append(names, obj.GetName())
+			}
+			assert.ElementsMatch(t, test.expectedNames, names)
+		})
+	}
+}
+
+func TestDocumentHint(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		document     string
+		expectedHint string
+	}{
+		"kind and name": {
+			document:     "apiVersion: v1\nkind: Service\nmetadata:\n  namespace: default\n  name: 'api'\n",
+			expectedHint: "Service/api",
+		},
+		"only kind": {
+			document:     "apiVersion: v1\nkind: Service\n",
+			expectedHint: "Service",
+		},
+		"nested names are ignored": {
+			document:     "kind: Pod\nmetadata:\n  labels:\n    name: label\nspec:\n  name: spec\n",
+			expectedHint: "Pod",
+		},
+		"no hint": {
+			document:     "- first\n- second\n",
+			expectedHint: "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expectedHint, documentHint([]byte(test.document)))
+		})
+	}
+}
+
+func TestDecodeEachStopsOnError(t *testing.T) {
+	t.Parallel()
+
+	data := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: second
+`
+	stopErr := errors.New("stop")
+	var names []string
+	err := DecodeEach(strings.NewReader(data), func(obj *unstructured.Unstructured) error {
+		names = append(names, obj.GetName())
+		return stopErr
+	})
+	assert.ErrorIs(t, err, stopErr)
+	assert.Equal(t, []string{"first"}, names)
+}
+
+func TestDecodeFile(t *testing.T) {
+	t.Parallel()
+
+	fSys := filesys.MakeFsInMemory()
+	require.NoError(t, fSys.WriteFile("resources.yaml", []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+`)))
+
+	objects, err := DecodeFile(fSys, "resources.yaml")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "first", objects[0].GetName())
+
+	_, err = DecodeFile(fSys, "missing.yaml")
+	assert.Error(t, err)
+}
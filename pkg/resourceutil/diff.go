@@ -0,0 +1,147 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resourceutil
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	// ignoredFields contains the fields populated by the API server that are not compared between resources
+	ignoredFields = [][]string{
+		{"status"},
+		{"metadata", "creationTimestamp"},
+		{"metadata", "generation"},
+		{"metadata", "managedFields"},
+		{"metadata", "resourceVersion"},
+		{"metadata", "selfLink"},
+		{"metadata", "uid"},
+	}
+)
+
+// Change contains the two versions of a resource present in both the sets compared
+type Change struct {
+	Old *unstructured.Unstructured
+	New *unstructured.Unstructured
+}
+
+// DiffResult contains the differences between two sets of resources, every list is sorted with OrderName
+type DiffResult struct {
+	// Added contains the resources found only in the new set
+	Added []*unstructured.Unstructured
+	// Removed contains the resources found only in the old set
+	Removed []*unstructured.Unstructured
+	// Changed contains the resources found in both the sets with a different content
+	Changed []Change
+}
+
+// Empty return true if the two sets compared contain the same resources
+func (r DiffResult) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+// Diff compare oldSet and newSet matching the resources by their Identifier, the resources present in both the sets
+// are changed if their API version or content differ, ignoring the status and the metadata populated by the API
+// server; an error is returned if a set contains the same resource more than once
+func Diff(oldSet, newSet []*unstructured.Unstructured) (DiffResult, error) {
+	oldByID, err := indexByIdentifier(oldSet)
+	if err != nil {
+		return DiffResult{}, fmt.Errorf("old set: %w", err)
+	}
+
+	newByID, err := indexByIdentifier(newSet)
+	if err != nil {
+		return DiffResult{}, fmt.Errorf("new set: %w", err)
+	}
+
+	result := DiffResult{
+		Added:   make([]*unstructured.Unstructured, 0),
+		Removed: make([]*unstructured.Unstructured, 0),
+		Changed: make([]Change, 0),
+	}
+	for id, newObj := range newByID {
+		oldObj, found := oldByID[id]
+		switch {
+		case !found:
+			result.Added = append(result.Added, newObj)
+		case !Equal(oldObj, newObj):
+			result.Changed = append(result.Changed, Change{Old: oldObj, New: newObj})
+		}
+	}
+
+	for id, oldObj := range oldByID {
+		if _, found := newByID[id]; !found {
+			result.Removed = append(result.Removed, oldObj)
+		}
+	}
+
+	compareObjects := func(a, b *unstructured.Unstructured) int { return CompareIdentifiers(Identifier(a), Identifier(b)) }
+	slices.SortFunc(result.Added, compareObjects)
+	slices.SortFunc(result.Removed, compareObjects)
+	slices.SortFunc(result.Changed, func(a, b Change) int { return compareObjects(a.New, b.New) })
+	return result, nil
+}
+
+// Equal return true if a and b have the same API version and content, ignoring the status and the metadata
+// populated by the API server
+func Equal(a, b *unstructured.Unstructured) bool {
+	return equality.Semantic.DeepEqual(withoutIgnoredFields(a).Object, withoutIgnoredFields(b).Object)
+}
+
+// withoutIgnoredFields return a copy of obj without the fields ignored in the comparisons, and without the empty
+// labels and annotations
+func withoutIgnoredFields(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	copied := obj.DeepCopy()
+	for _, field := range ignoredFields {
+		unstructured.RemoveNestedField(copied.Object, field...)
+	}
+
+	if len(copied.GetAnnotations()) == 0 {
+		unstructured.RemoveNestedField(copied.Object, "metadata", "annotations")
+	}
+	if len(copied.GetLabels()) == 0 {
+		unstructured.RemoveNestedField(copied.Object, "metadata", "labels")
+	}
+	return copied
+}
+
+// indexByIdentifier return the resources keyed by their identifier, or an error if one is repeated
+func indexByIdentifier(resources []*unstructured.Unstructured) (map[resource.ObjectMetadata]*unstructured.Unstructured, error) {
+	index := make(map[resource.ObjectMetadata]*unstructured.Unstructured, len(resources))
+	for _, obj := range resources {
+		id := Identifier(obj)
+		if _, found := index[id]; found {
+			return nil, fmt.Errorf("resource %s is present more than once", formatIdentifier(id))
+		}
+		index[id] = obj
+	}
+	return index, nil
+}
+
+// formatIdentifier return a human readable representation of id
+func formatIdentifier(id resource.ObjectMetadata) string {
+	name := id.Name
+	if len(id.Namespace) > 0 {
+		name = id.Namespace + "/" + id.Name
+	}
+	return schema.GroupKind{Group: id.Group, Kind: id.Kind}.String() + " " + name
+}
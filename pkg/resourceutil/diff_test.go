@@ -0,0 +1,90 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resourceutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	withData := func(obj *unstructured.Unstructured, data map[string]interface{}) *unstructured.Unstructured {
+		obj.Object["data"] = data
+		return obj
+	}
+	withServerFields := func(obj *unstructured.Unstructured) *unstructured.Unstructured {
+		obj.SetResourceVersion("42")
+		obj.SetUID("0d6a3c1e-1111-2222-3333-444455556666")
+		obj.SetGeneration(3)
+		obj.SetAnnotations(map[string]string{})
+		obj.Object["status"] = map[string]interface{}{"replicas": int64(1)}
+		return obj
+	}
+
+	oldSet := []*unstructured.Unstructured{
+		withData(newResource("v1", "ConfigMap", "default", "unchanged", nil), map[string]interface{}{"key": "value"}),
+		withData(newResource("v1", "ConfigMap", "default", "changed", nil), map[string]interface{}{"key": "old"}),
+		newResource("v1", "Secret", "default", "removed", nil),
+		newResource("autoscaling/v1", "HorizontalPodAutoscaler", "default", "api", nil),
+		newResource("apps/v1", "Deployment", "default", "api", nil),
+	}
+	newSet := []*unstructured.Unstructured{
+		withServerFields(withData(newResource("v1", "ConfigMap", "default", "unchanged", nil), map[string]interface{}{"key": "value"})),
+		withData(newResource("v1", "ConfigMap", "default", "changed", nil), map[string]interface{}{"key": "new"}),
+		newResource("v1", "Service", "default", "added", nil),
+		newResource("autoscaling/v2", "HorizontalPodAutoscaler", "default", "api", nil),
+		withServerFields(newResource("apps/v1", "Deployment", "default", "api", nil)),
+	}
+
+	result, err := Diff(oldSet, newSet)
+	require.NoError(t, err)
+	assert.False(t, result.Empty())
+	assert.Equal(t, []string{"Service/added"}, names(result.Added))
+	assert.Equal(t, []string{"Secret/removed"}, names(result.Removed))
+	require.Len(t, result.Changed, 2)
+	assert.Equal(t, "changed", result.Changed[0].New.GetName())
+	assert.Equal(t, "old", result.Changed[0].Old.Object["data"].(map[string]interface{})["key"])
+	assert.Equal(t, "autoscaling/v1", result.Changed[1].Old.GetAPIVersion())
+	assert.Equal(t, "autoscaling/v2", result.Changed[1].New.GetAPIVersion())
+
+	result, err = Diff(oldSet, oldSet)
+	require.NoError(t, err)
+	assert.True(t, result.Empty())
+
+	result, err = Diff(nil, nil)
+	require.NoError(t, err)
+	assert.True(t, result.Empty())
+}
+
+func TestDiffDuplicatedResources(t *testing.T) {
+	t.Parallel()
+
+	duplicated := []*unstructured.Unstructured{
+		newResource("autoscaling/v1", "HorizontalPodAutoscaler", "default", "api", nil),
+		newResource("autoscaling/v2", "HorizontalPodAutoscaler", "default", "api", nil),
+	}
+
+	_, err := Diff(duplicated, nil)
+	assert.EqualError(t, err, "old set: resource HorizontalPodAutoscaler.autoscaling default/api is present more than once")
+
+	_, err = Diff(nil, duplicated)
+	assert.EqualError(t, err, "new set: resource HorizontalPodAutoscaler.autoscaling default/api is present more than once")
+}
@@ -0,0 +1,113 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resourceutil contains the semantics used by mlp for working on sets of kubernetes resources, like sorting,
+// filtering and comparing them, decoupled from the files they are read from and from the cluster
+package resourceutil
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/mia-platform/jpl/pkg/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Order is the order used for sorting a set of resources
+type Order string
+
+const (
+	// OrderApply sort the resources in the order they are applied, with the namespaces, the definitions and the
+	// resources used by the workloads before the workloads themselves
+	OrderApply Order = "apply"
+	// OrderPrune sort the resources in the order they are pruned, the reverse of OrderApply
+	OrderPrune Order = "prune"
+	// OrderName sort the resources by their group, kind, namespace and name
+	OrderName Order = "name"
+)
+
+// Sort return a copy of resources sorted following order; the resources with the same position keep their
+// relative order
+func Sort(resources []*unstructured.Unstructured, order Order) ([]*unstructured.Unstructured, error) {
+	sorted := slices.Clone(resources)
+	switch order {
+	case OrderApply:
+		sort.Stable(resource.SortableObjects(sorted))
+	case OrderPrune:
+		sort.Stable(sort.Reverse(resource.SortableObjects(sorted)))
+	case OrderName:
+		slices.SortStableFunc(sorted, func(a, b *unstructured.Unstructured) int {
+			return CompareIdentifiers(Identifier(a), Identifier(b))
+		})
+	default:
+		return nil, fmt.Errorf("unknown sort order %q", order)
+	}
+
+	return sorted, nil
+}
+
+// Identifier return the identifier of obj, that doesn't include the version of its API, so the same resource
+// served by different versions is considered the same
+func Identifier(obj *unstructured.Unstructured) resource.ObjectMetadata {
+	return resource.ObjectMetadataFromUnstructured(obj)
+}
+
+// CompareIdentifiers compare two identifiers by their group, kind, namespace and name, returning -1, 0 or +1 like
+// the cmp.Compare function
+func CompareIdentifiers(a, b resource.ObjectMetadata) int {
+	return cmp.Or(
+		cmp.Compare(a.Group, b.Group),
+		cmp.Compare(a.Kind, b.Kind),
+		cmp.Compare(a.Namespace, b.Namespace),
+		cmp.Compare(a.Name, b.Name),
+	)
+}
+
+// Filter return the resources for which keep return true, in their original order
+func Filter(resources []*unstructured.Unstructured, keep func(*unstructured.Unstructured) bool) []*unstructured.Unstructured {
+	filtered := make([]*unstructured.Unstructured, 0, len(resources))
+	for _, obj := range resources {
+		if keep(obj) {
+			filtered = append(filtered, obj)
+		}
+	}
+	return filtered
+}
+
+// FilterByGVK return the resources matching one of gvks; an empty version matches all the versions of the kind
+func FilterByGVK(resources []*unstructured.Unstructured, gvks ...schema.GroupVersionKind) []*unstructured.Unstructured {
+	return Filter(resources, func(obj *unstructured.Unstructured) bool {
+		objGVK := obj.GroupVersionKind()
+		return slices.ContainsFunc(gvks, func(gvk schema.GroupVersionKind) bool {
+			return gvk.GroupKind() == objGVK.GroupKind() && (len(gvk.Version) == 0 || gvk.Version == objGVK.Version)
+		})
+	})
+}
+
+// FilterByLabel return the resources whose labels match selector, written with the same syntax used by kubectl
+func FilterByLabel(resources []*unstructured.Unstructured, selector string) ([]*unstructured.Unstructured, error) {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector %q: %w", selector, err)
+	}
+
+	return Filter(resources, func(obj *unstructured.Unstructured) bool {
+		return parsed.Matches(labels.Set(obj.GetLabels()))
+	}), nil
+}
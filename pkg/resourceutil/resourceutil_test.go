@@ -0,0 +1,193 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resourceutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newResource(apiVersion, kind, namespace, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	if labels != nil {
+		obj.SetLabels(labels)
+	}
+	return obj
+}
+
+func names(resources []*unstructured.Unstructured) []string {
+	result := make([]string, 0, len(resources))
+	for _, obj := range resources {
+		result = append(result, obj.GetKind()+"/"+obj.GetName())
+	}
+	return result
+}
+
+func TestSort(t *testing.T) {
+	t.Parallel()
+
+	resources := []*unstructured.Unstructured{
+		newResource("apps/v1", "Deployment", "default", "api", nil),
+		newResource("v1", "ConfigMap", "default", "config", nil),
+		newResource("v1", "Namespace", "", "default", nil),
+		newResource("example.com/v1", "Widget", "default", "widget", nil),
+		newResource("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "widgets.example.com", nil),
+		newResource("v1", "ConfigMap", "default", "another", nil),
+	}
+
+	tests := map[string]struct {
+		order         Order
+		expected      []string
+		expectedError string
+	}{
+		"apply order": {
+			order: OrderApply,
+			expected: []string{
+				"Namespace/default",
+				"CustomResourceDefinition/widgets.example.com",
+				"ConfigMap/another",
+				"ConfigMap/config",
+				"Deployment/api",
+				"Widget/widget",
+			},
+		},
+		"prune order": {
+			order: OrderPrune,
+			expected: []string{
+				"Widget/widget",
+				"Deployment/api",
+				"ConfigMap/config",
+				"ConfigMap/another",
+				"CustomResourceDefinition/widgets.example.com",
+				"Namespace/default",
+			},
+		},
+		"name order": {
+			order: OrderName,
+			expected: []string{
+				"ConfigMap/another",
+				"ConfigMap/config",
+				"Namespace/default",
+				"CustomResourceDefinition/widgets.example.com",
+				"Deployment/api",
+				"Widget/widget",
+			},
+		},
+		"unknown order": {
+			order:         "random",
+			expectedError: `unknown sort order "random"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			sorted, err := Sort(resources, test.order)
+			if len(test.expectedError) > 0 {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, names(sorted))
+			assert.Equal(t, "Deployment/api", names(resources)[0], "the input slice must not be modified")
+		})
+	}
+}
+
+func TestFilterByGVK(t *testing.T) {
+	t.Parallel()
+
+	resources := []*unstructured.Unstructured{
+		newResource("apps/v1", "Deployment", "default", "api", nil),
+		newResource("v1", "ConfigMap", "default", "config", nil),
+		newResource("autoscaling/v2", "HorizontalPodAutoscaler", "default", "api", nil),
+		newResource("autoscaling/v1", "HorizontalPodAutoscaler", "default", "legacy", nil),
+	}
+
+	assert.Equal(t, []string{"Deployment/api", "ConfigMap/config"}, names(FilterByGVK(resources,
+		schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		schema.GroupVersionKind{Kind: "ConfigMap"},
+	)))
+	assert.Equal(t, []string{"HorizontalPodAutoscaler/api", "HorizontalPodAutoscaler/legacy"}, names(FilterByGVK(resources,
+		schema.GroupVersionKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"},
+	)))
+	assert.Equal(t, []string{"HorizontalPodAutoscaler/api"}, names(FilterByGVK(resources,
+		schema.GroupVersionKind{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"},
+	)))
+	assert.Empty(t, FilterByGVK(resources, schema.GroupVersionKind{Kind: "Deployment"}))
+}
+
+func TestFilterByLabel(t *testing.T) {
+	t.Parallel()
+
+	resources := []*unstructured.Unstructured{
+		newResource("apps/v1", "Deployment", "default", "api", map[string]string{"app": "api", "tier": "backend"}),
+		newResource("apps/v1", "Deployment", "default", "web", map[string]string{"app": "web", "tier": "frontend"}),
+		newResource("v1", "ConfigMap", "default", "config", nil),
+	}
+
+	tests := map[string]struct {
+		selector      string
+		expected      []string
+		expectedError string
+	}{
+		"equality": {
+			selector: "tier=backend",
+			expected: []string{"Deployment/api"},
+		},
+		"set based": {
+			selector: "app in (api, web)",
+			expected: []string{"Deployment/api", "Deployment/web"},
+		},
+		"not existing label": {
+			selector: "!tier",
+			expected: []string{"ConfigMap/config"},
+		},
+		"empty selector": {
+			selector: "",
+			expected: []string{"Deployment/api", "Deployment/web", "ConfigMap/config"},
+		},
+		"invalid selector": {
+			selector:      "app in api",
+			expectedError: `invalid label selector "app in api"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			filtered, err := FilterByLabel(resources, test.selector)
+			if len(test.expectedError) > 0 {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, names(filtered))
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package restmapping contains the utilities used for resolving the resources served by a cluster from the kinds
+// of the manifests, tolerating the discovery failures of the aggregated APIs that are not needed by them
+package restmapping
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+)
+
+// FromGVKtoGVR return the resource of the cluster serving the objects of type gvk
+func FromGVKtoGVR(mapper meta.RESTMapper, gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return schema.GroupVersionResource{}, err
+	}
+
+	return mapping.Resource, nil
+}
+
+// MappingCache store the mappings resolved by a RESTMapper, so they are not resolved again during the same run
+type MappingCache interface {
+	// Get return the mapping saved for key if present
+	Get(key string) (*meta.RESTMapping, bool)
+	// Set save mapping for key
+	Set(key string, mapping *meta.RESTMapping)
+}
+
+// memoryCache is a MappingCache that keep the mappings in memory
+type memoryCache struct {
+	lock     sync.RWMutex
+	mappings map[string]*meta.RESTMapping
+}
+
+// NewMemoryCache return a MappingCache that keep the mappings in memory for the lifetime of the process
+func NewMemoryCache() MappingCache {
+	return &memoryCache{mappings: make(map[string]*meta.RESTMapping)}
+}
+
+// Get implement MappingCache interface
+func (c *memoryCache) Get(key string) (*meta.RESTMapping, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	mapping, found := c.mappings[key]
+	return mapping, found
+}
+
+// Set implement MappingCache interface
+func (c *memoryCache) Set(key string, mapping *meta.RESTMapping) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.mappings[key] = mapping
+}
+
+// tolerantMapper wrap a RESTMapper caching the mappings it resolves and falling back to the partial discovery
+// of the cluster when the discovery of an API group not involved in the mapping has failed
+type tolerantMapper struct {
+	meta.RESTMapper
+
+	discovery discovery.DiscoveryInterface
+	cache     MappingCache
+
+	lock     sync.Mutex
+	fallback meta.RESTMapper
+	failed   map[schema.GroupVersion]error
+}
+
+// NewTolerantRESTMapper return a ResettableRESTMapper that resolve the mappings with delegate saving them in cache; if the
+// discovery of an API group fails, like it can happen for the aggregated APIs, the mappings of the other groups
+// are resolved using the groups served by client that are still reachable
+func NewTolerantRESTMapper(delegate meta.RESTMapper, client discovery.DiscoveryInterface, cache MappingCache) meta.ResettableRESTMapper {
+	return &tolerantMapper{RESTMapper: delegate, discovery: client, cache: cache}
+}
+
+// RESTMapping override the RESTMapper method
+func (m *tolerantMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	key := cacheKey(gk, versions)
+	if mapping, found := m.cache.Get(key); found {
+		return mapping, nil
+	}
+
+	mapping, err := m.RESTMapper.RESTMapping(gk, versions...)
+	var discoveryErr *discovery.ErrGroupDiscoveryFailed
+	if errors.As(err, &discoveryErr) {
+		mapping, err = m.fallbackMapping(gk, versions...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.cache.Set(key, mapping)
+	return mapping, nil
+}
+
+// Reset implement meta.ResettableRESTMapper interface, the cached mappings are kept because they are still valid
+// but the discovery is done again for finding the API groups added to the cluster, like new custom resources
+func (m *tolerantMapper) Reset() {
+	meta.MaybeResetRESTMapper(m.RESTMapper)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if cached, ok := m.discovery.(discovery.CachedDiscoveryInterface); ok {
+		cached.Invalidate()
+	}
+	m.fallback = nil
+	m.failed = nil
+}
+
+// fallbackMapping resolve the mapping of gk using only the API groups that have been discovered successfully,
+// it return an error if the discovery of the group of gk has failed
+func (m *tolerantMapper) fallbackMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.fallback == nil {
+		// the partial discovery hide the groups that have failed, so they are read from the error beforehand
+		_, _, err := m.discovery.ServerGroupsAndResources()
+		var discoveryErr *discovery.ErrGroupDiscoveryFailed
+		switch {
+		case errors.As(err, &discoveryErr):
+			m.failed = discoveryErr.Groups
+		case err != nil:
+			return nil, err
+		}
+
+		groupResources, err := restmapper.GetAPIGroupResources(m.discovery)
+		if err != nil {
+			return nil, err
+		}
+		m.fallback = restmapper.NewDiscoveryRESTMapper(groupResources)
+	}
+
+	for groupVersion, err := range m.failed {
+		if groupVersion.Group == gk.Group {
+			return nil, fmt.Errorf("failed to discover API group %q: %w", groupVersion.String(), err)
+		}
+	}
+
+	return m.fallback.RESTMapping(gk, versions...)
+}
+
+// cacheKey return the key used for saving the mapping of gk for versions
+func cacheKey(gk schema.GroupKind, versions []string) string {
+	return gk.String() + "/" + strings.Join(versions, ",")
+}
+
+// keep it to always check if tolerantMapper implement correctly the meta.ResettableRESTMapper interface
+var _ meta.ResettableRESTMapper = &tolerantMapper{}
@@ -0,0 +1,150 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restmapping
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var metricsGroupVersion = schema.GroupVersion{Group: "metrics.k8s.io", Version: "v1beta1"}
+
+// partialDiscovery is a discovery client that fail the discovery of the metrics API group
+type partialDiscovery struct {
+	*fakediscovery.FakeDiscovery
+	calls int
+}
+
+func (d *partialDiscovery) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
+	d.calls++
+	groups, resources, err := d.FakeDiscovery.ServerGroupsAndResources()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return groups, resources, &discovery.ErrGroupDiscoveryFailed{
+		Groups: map[schema.GroupVersion]error{metricsGroupVersion: errors.New("the server is currently unable to handle the request")},
+	}
+}
+
+// countingMapper is a RESTMapper that always return mapping and err, counting the mappings requested
+type countingMapper struct {
+	meta.RESTMapper
+	mapping *meta.RESTMapping
+	err     error
+	calls   int
+}
+
+func (m *countingMapper) RESTMapping(schema.GroupKind, ...string) (*meta.RESTMapping, error) {
+	m.calls++
+	return m.mapping, m.err
+}
+
+func newPartialDiscovery() *partialDiscovery {
+	return &partialDiscovery{FakeDiscovery: &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "configmaps", SingularName: "configmap", Kind: "ConfigMap", Namespaced: true},
+					},
+				},
+				{
+					GroupVersion: "apps/v1",
+					APIResources: []metav1.APIResource{
+						{Name: "deployments", SingularName: "deployment", Kind: "Deployment", Namespaced: true},
+					},
+				},
+			},
+		},
+	}}
+}
+
+func TestFromGVKtoGVR(t *testing.T) {
+	t.Parallel()
+
+	delegate := &countingMapper{mapping: &meta.RESTMapping{Resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}}}
+	gvr, err := FromGVKtoGVR(delegate, schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	require.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, gvr)
+
+	delegate = &countingMapper{err: &meta.NoKindMatchError{GroupKind: schema.GroupKind{Kind: "Unknown"}}}
+	_, err = FromGVKtoGVR(delegate, schema.GroupVersionKind{Version: "v1", Kind: "Unknown"})
+	assert.True(t, meta.IsNoMatchError(err))
+}
+
+func TestTolerantRESTMapperCache(t *testing.T) {
+	t.Parallel()
+
+	expectedMapping := &meta.RESTMapping{Resource: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}}
+	delegate := &countingMapper{mapping: expectedMapping}
+	mapper := NewTolerantRESTMapper(delegate, newPartialDiscovery(), NewMemoryCache())
+
+	for range 3 {
+		mapping, err := mapper.RESTMapping(schema.GroupKind{Kind: "ConfigMap"}, "v1")
+		require.NoError(t, err)
+		assert.Equal(t, expectedMapping, mapping)
+	}
+	assert.Equal(t, 1, delegate.calls, "the successful mappings must be cached")
+
+	delegate.err = errors.New("failure")
+	_, err := mapper.RESTMapping(schema.GroupKind{Group: "apps", Kind: "Deployment"})
+	assert.EqualError(t, err, "failure")
+	_, err = mapper.RESTMapping(schema.GroupKind{Group: "apps", Kind: "Deployment"})
+	assert.EqualError(t, err, "failure")
+	assert.Equal(t, 3, delegate.calls, "the failed mappings must not be cached")
+}
+
+func TestTolerantRESTMapperFallback(t *testing.T) {
+	t.Parallel()
+
+	client := newPartialDiscovery()
+	delegate := &countingMapper{err: &discovery.ErrGroupDiscoveryFailed{
+		Groups: map[schema.GroupVersion]error{metricsGroupVersion: errors.New("the server is currently unable to handle the request")},
+	}}
+	mapper := NewTolerantRESTMapper(delegate, client, NewMemoryCache())
+
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: "apps", Kind: "Deployment"})
+	require.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, mapping.Resource)
+
+	mapping, err = mapper.RESTMapping(schema.GroupKind{Kind: "ConfigMap"}, "v1")
+	require.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, mapping.Resource)
+
+	_, err = mapper.RESTMapping(schema.GroupKind{Group: "metrics.k8s.io", Kind: "PodMetrics"})
+	assert.ErrorContains(t, err, `failed to discover API group "metrics.k8s.io/v1beta1": the server is currently unable to handle the request`)
+
+	_, err = mapper.RESTMapping(schema.GroupKind{Group: "example.com", Kind: "Unknown"})
+	assert.True(t, meta.IsNoMatchError(err))
+
+	assert.Equal(t, 2, client.calls, "the fallback discovery must be done only once")
+
+	mapper.Reset()
+	_, err = mapper.RESTMapping(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "v1")
+	require.NoError(t, err)
+	assert.Equal(t, 4, client.calls, "the fallback discovery must be done again after a reset")
+}
@@ -0,0 +1,332 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+)
+
+const (
+	// DefaultAPIURL is the url of the GitHub API used for reading the releases
+	DefaultAPIURL = "https://api.github.com"
+	// DefaultRepository is the GitHub repository where mlp is released
+	DefaultRepository = "mia-platform/mlp"
+
+	binaryName         = "mlp"
+	checksumsAssetName = "checksums.txt"
+	// signatureAssetName is the cosign signature of the checksums file, encoded in base64
+	signatureAssetName = checksumsAssetName + ".sig"
+	requestTimeout     = 5 * time.Minute
+)
+
+// ErrDevelopmentVersion is returned when comparing a version that is not a release, like the ones of local builds
+var ErrDevelopmentVersion = errors.New("development versions cannot be compared with releases")
+
+// Release contains the data of a GitHub release used for the update
+type Release struct {
+	Version string  `json:"tag_name"`
+	Notes   string  `json:"body"`
+	URL     string  `json:"html_url"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a Release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Client read the releases of a GitHub repository and download their binaries
+type Client struct {
+	apiURL     string
+	repository string
+	client     *http.Client
+	signingKey *ecdsa.PublicKey
+}
+
+// NewClient return a new Client for the releases of repository, using the GitHub API at apiURL
+func NewClient(apiURL, repository string) *Client {
+	return &Client{
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		repository: repository,
+		client:     &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// WithSigningKey set the public key used for verifying the signature of the checksums published with the
+// releases, without a key the binaries are verified only against the checksums
+func (c *Client) WithSigningKey(key *ecdsa.PublicKey) *Client {
+	c.signingKey = key
+	return c
+}
+
+// LatestRelease return the most recent release of the repository, excluding drafts and prereleases
+func (c *Client) LatestRelease(ctx context.Context) (*Release, error) {
+	return c.release(ctx, c.apiURL+"/repos/"+c.repository+"/releases/latest")
+}
+
+// Release return the release of the repository for version, the leading v of the tag can be omitted
+func (c *Client) Release(ctx context.Context, version string) (*Release, error) {
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return c.release(ctx, c.apiURL+"/repos/"+c.repository+"/releases/tags/"+version)
+}
+
+func (c *Client) release(ctx context.Context, url string) (*Release, error) {
+	data, err := c.get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release: %w", err)
+	}
+
+	release := new(Release)
+	if err := json.Unmarshal(data, release); err != nil {
+		return nil, fmt.Errorf("failed to read release: %w", err)
+	}
+
+	return release, nil
+}
+
+// DownloadBinary download the asset called name from release, and verify its content against the checksums
+// published with the release; if the client has a signing key the signature of the checksums is verified first
+func (c *Client) DownloadBinary(ctx context.Context, release *Release, name string) ([]byte, error) {
+	binaryAsset, found := release.asset(name)
+	if !found {
+		return nil, fmt.Errorf("release %s has no binary %q", release.Version, name)
+	}
+	checksumsAsset, found := release.asset(checksumsAssetName)
+	if !found {
+		return nil, fmt.Errorf("release %s has no %s file for verifying the binary", release.Version, checksumsAssetName)
+	}
+
+	checksums, err := c.get(ctx, checksumsAsset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", checksumsAssetName, err)
+	}
+	if err := c.verifySignature(ctx, release, checksums); err != nil {
+		return nil, err
+	}
+	expected, found := findChecksum(checksums, name)
+	if !found {
+		return nil, fmt.Errorf("no checksum found for %q in %s", name, checksumsAssetName)
+	}
+
+	data, err := c.get(ctx, binaryAsset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %q: %w", name, err)
+	}
+
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return nil, fmt.Errorf("checksum mismatch for %q: expected %s, got %s", name, expected, actual)
+	}
+
+	return data, nil
+}
+
+// verifySignature check that checksums has been signed with the signing key of the client, the check is skipped
+// if the client has no key
+func (c *Client) verifySignature(ctx context.Context, release *Release, checksums []byte) error {
+	if c.signingKey == nil {
+		return nil
+	}
+
+	signatureAsset, found := release.asset(signatureAssetName)
+	if !found {
+		return fmt.Errorf("release %s has no %s file for verifying the checksums", release.Version, signatureAssetName)
+	}
+
+	data, err := c.get(ctx, signatureAsset.URL)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", signatureAssetName, err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", signatureAssetName, err)
+	}
+
+	digest := sha256.Sum256(checksums)
+	if !ecdsa.VerifyASN1(c.signingKey, digest[:], signature) {
+		return fmt.Errorf("invalid signature for the %s file of release %s", checksumsAssetName, release.Version)
+	}
+
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, url string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", response.StatusCode, url)
+	}
+
+	return io.ReadAll(response.Body)
+}
+
+func (r *Release) asset(name string) (Asset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+
+	return Asset{}, false
+}
+
+// findChecksum return the sha256 checksum for name in the content of a checksums file, where every line
+// contains an hex encoded checksum followed by the file name
+func findChecksum(checksums []byte, name string) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), true
+		}
+	}
+
+	return "", false
+}
+
+// ParseSigningKey return the ECDSA public key encoded in key as the base64 of its PKIX form, that is the content
+// of the PEM file generated by cosign without its header and footer
+func ParseSigningKey(key string) (*ecdsa.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+
+	ecdsaKey, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid signing key: unsupported key type %T", publicKey)
+	}
+
+	return ecdsaKey, nil
+}
+
+// Compare return 1 if release is newer than current, 0 if they are the same version and -1 if release is older;
+// ErrDevelopmentVersion is returned if current is not a valid semantic version
+func Compare(current, release string) (int, error) {
+	currentVersion, err := semver.ParseTolerant(current)
+	if err != nil {
+		return 0, ErrDevelopmentVersion
+	}
+
+	releaseVersion, err := semver.ParseTolerant(release)
+	if err != nil {
+		return 0, fmt.Errorf("invalid release version %q: %w", release, err)
+	}
+
+	return releaseVersion.Compare(currentVersion), nil
+}
+
+// Highlights return at most limit entries of the lists found in the release notes
+func Highlights(notes string, limit int) []string {
+	highlights := make([]string, 0, limit)
+	for _, line := range strings.Split(notes, "\n") {
+		if len(highlights) == limit {
+			break
+		}
+
+		line = strings.TrimSpace(line)
+		for _, bullet := range []string{"- ", "* "} {
+			if entry, found := strings.CutPrefix(line, bullet); found && len(strings.TrimSpace(entry)) > 0 {
+				highlights = append(highlights, strings.TrimSpace(entry))
+				break
+			}
+		}
+	}
+
+	return highlights
+}
+
+// BinaryName return the name of the release binary built for goos and goarch, goarm is used only for the arm
+// architecture and contains the arm version without the v prefix
+func BinaryName(goos, goarch, goarm string) string {
+	name := binaryName + "-" + goos + "-" + goarch
+	if goarch == "arm" && len(goarm) > 0 {
+		name += "v" + goarm
+	}
+
+	return name
+}
+
+// ReplaceExecutable overwrite the file at path with data, keeping its permissions; the new content is written
+// in a temporary file in the same folder and then renamed, so the executable is never left half written
+func ReplaceExecutable(path string, data []byte) error {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to replace %q: %w", path, err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to replace %q: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to replace %q: %w", path, err)
+	}
+	if err := os.Chmod(file.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to replace %q: %w", path, err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %q: %w", path, err)
+	}
+	return nil
+}
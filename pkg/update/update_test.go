@@ -0,0 +1,348 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBinaryName  = "mlp-linux-amd64"
+	testBinaryValue = "new binary"
+)
+
+// newReleaseServer return a server that expose a single release with version and the files in assets
+func newReleaseServer(t *testing.T, version string, assets map[string]string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	release := Release{
+		Version: version,
+		Notes:   "## Added\n\n- first feature\n* second feature\n",
+		URL:     "https://github.com/mia-platform/mlp/releases/tag/" + version,
+	}
+	for name, content := range assets {
+		release.Assets = append(release.Assets, Asset{Name: name, URL: server.URL + "/download/" + name})
+		mux.HandleFunc("/download/"+name, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(content))
+		})
+	}
+
+	writeRelease := func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(release))
+	}
+	mux.HandleFunc("/repos/mia-platform/mlp/releases/latest", writeRelease)
+	mux.HandleFunc("/repos/mia-platform/mlp/releases/tags/"+version, writeRelease)
+	return server
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// sign return the base64 encoded signature of content, in the same format produced by cosign
+func sign(t *testing.T, key *ecdsa.PrivateKey, content string) string {
+	t.Helper()
+
+	digest := sha256.Sum256([]byte(content))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func TestReleases(t *testing.T) {
+	t.Parallel()
+
+	server := newReleaseServer(t, "v2.1.0", nil)
+	client := NewClient(server.URL+"/", DefaultRepository)
+
+	release, err := client.LatestRelease(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, "v2.1.0", release.Version)
+	assert.Equal(t, "https://github.com/mia-platform/mlp/releases/tag/v2.1.0", release.URL)
+
+	release, err = client.Release(context.TODO(), "2.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "v2.1.0", release.Version)
+
+	_, err = client.Release(context.TODO(), "v2.0.0")
+	assert.ErrorContains(t, err, "failed to read release: unexpected status code 404")
+}
+
+func TestDownloadBinary(t *testing.T) {
+	t.Parallel()
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	validChecksums := checksum("other") + "  mlp-darwin-arm64\n" + checksum(testBinaryValue) + "  " + testBinaryName + "\n"
+	tests := map[string]struct {
+		assets        map[string]string
+		signed        bool
+		expectedError string
+	}{
+		"verified binary": {
+			assets: map[string]string{
+				testBinaryName:     testBinaryValue,
+				checksumsAssetName: validChecksums,
+			},
+		},
+		"verified signature": {
+			assets: map[string]string{
+				testBinaryName:     testBinaryValue,
+				checksumsAssetName: validChecksums,
+				signatureAssetName: sign(t, signingKey, validChecksums) + "\n",
+			},
+			signed: true,
+		},
+		"missing signature": {
+			assets: map[string]string{
+				testBinaryName:     testBinaryValue,
+				checksumsAssetName: validChecksums,
+			},
+			signed:        true,
+			expectedError: "release v2.1.0 has no checksums.txt.sig file for verifying the checksums",
+		},
+		"signature of another key": {
+			assets: map[string]string{
+				testBinaryName:     testBinaryValue,
+				checksumsAssetName: validChecksums,
+				signatureAssetName: sign(t, otherKey, validChecksums),
+			},
+			signed:        true,
+			expectedError: "invalid signature for the checksums.txt file of release v2.1.0",
+		},
+		"signature of other checksums": {
+			assets: map[string]string{
+				testBinaryName:     testBinaryValue,
+				checksumsAssetName: validChecksums,
+				signatureAssetName: sign(t, signingKey, checksum(testBinaryValue)+"  "+testBinaryName+"\n"),
+			},
+			signed:        true,
+			expectedError: "invalid signature for the checksums.txt file of release v2.1.0",
+		},
+		"malformed signature": {
+			assets: map[string]string{
+				testBinaryName:     testBinaryValue,
+				checksumsAssetName: validChecksums,
+				signatureAssetName: "not base64!",
+			},
+			signed:        true,
+			expectedError: "failed to read checksums.txt.sig",
+		},
+		"missing binary": {
+			assets: map[string]string{
+				checksumsAssetName: checksum(testBinaryValue) + "  " + testBinaryName + "\n",
+			},
+			expectedError: `release v2.1.0 has no binary "mlp-linux-amd64"`,
+		},
+		"missing checksums file": {
+			assets: map[string]string{
+				testBinaryName: testBinaryValue,
+			},
+			expectedError: "release v2.1.0 has no checksums.txt file for verifying the binary",
+		},
+		"missing checksum": {
+			assets: map[string]string{
+				testBinaryName:     testBinaryValue,
+				checksumsAssetName: checksum("other") + "  mlp-darwin-arm64\n",
+			},
+			expectedError: `no checksum found for "mlp-linux-amd64" in checksums.txt`,
+		},
+		"checksum mismatch": {
+			assets: map[string]string{
+				testBinaryName:     testBinaryValue,
+				checksumsAssetName: checksum("other") + "  " + testBinaryName + "\n",
+			},
+			expectedError: `checksum mismatch for "mlp-linux-amd64"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newReleaseServer(t, "v2.1.0", test.assets)
+			client := NewClient(server.URL, DefaultRepository)
+			if test.signed {
+				client = client.WithSigningKey(&signingKey.PublicKey)
+			}
+			release, err := client.LatestRelease(context.TODO())
+			require.NoError(t, err)
+
+			data, err := client.DownloadBinary(context.TODO(), release, testBinaryName)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, testBinaryValue, string(data))
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestParseSigningKey(t *testing.T) {
+	t.Parallel()
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecdsaDER, err := x509.MarshalPKIXPublicKey(&signingKey.PublicKey)
+	require.NoError(t, err)
+	ed25519Key, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ed25519DER, err := x509.MarshalPKIXPublicKey(ed25519Key)
+	require.NoError(t, err)
+
+	key, err := ParseSigningKey(base64.StdEncoding.EncodeToString(ecdsaDER))
+	require.NoError(t, err)
+	assert.True(t, signingKey.PublicKey.Equal(key))
+
+	_, err = ParseSigningKey("not base64!")
+	assert.ErrorContains(t, err, "invalid signing key")
+
+	_, err = ParseSigningKey(base64.StdEncoding.EncodeToString([]byte("not a key")))
+	assert.ErrorContains(t, err, "invalid signing key")
+
+	_, err = ParseSigningKey(base64.StdEncoding.EncodeToString(ed25519DER))
+	assert.ErrorContains(t, err, "invalid signing key: unsupported key type ed25519.PublicKey")
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		current       string
+		release       string
+		expected      int
+		expectedError string
+	}{
+		"newer release": {
+			current:  "2.0.0",
+			release:  "v2.1.0",
+			expected: 1,
+		},
+		"same release": {
+			current:  "v2.1.0",
+			release:  "v2.1.0",
+			expected: 0,
+		},
+		"older release": {
+			current:  "2.1.0",
+			release:  "v2.0.0-rc",
+			expected: -1,
+		},
+		"release after prerelease": {
+			current:  "2.0.0-rc",
+			release:  "v2.0.0",
+			expected: 1,
+		},
+		"development version": {
+			current:       "DEV",
+			release:       "v2.1.0",
+			expectedError: ErrDevelopmentVersion.Error(),
+		},
+		"invalid release": {
+			current:       "2.0.0",
+			release:       "latest",
+			expectedError: `invalid release version "latest"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			comparison, err := Compare(test.current, test.release)
+			switch len(test.expectedError) {
+			case 0:
+				require.NoError(t, err)
+				assert.Equal(t, test.expected, comparison)
+			default:
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestHighlights(t *testing.T) {
+	t.Parallel()
+
+	notes := "## Added\n\n- first\n  * nested second\n-\n\ntext\n- third\n- fourth\n"
+	assert.Equal(t, []string{"first", "nested second", "third"}, Highlights(notes, 3))
+	assert.Equal(t, []string{"first", "nested second", "third", "fourth"}, Highlights(notes, 10))
+	assert.Empty(t, Highlights("no lists", 5))
+}
+
+func TestBinaryName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "mlp-linux-amd64", BinaryName("linux", "amd64", "7"))
+	assert.Equal(t, "mlp-darwin-arm64", BinaryName("darwin", "arm64", ""))
+	assert.Equal(t, "mlp-linux-armv6", BinaryName("linux", "arm", "6"))
+}
+
+func TestReplaceExecutable(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mlp")
+	require.NoError(t, os.WriteFile(path, []byte("old binary"), 0o750))
+	link := filepath.Join(dir, "mlp-link")
+	require.NoError(t, os.Symlink(path, link))
+
+	require.NoError(t, ReplaceExecutable(link, []byte(testBinaryValue)))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, testBinaryValue, string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o750), info.Mode().Perm())
+	linkInfo, err := os.Lstat(link)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, linkInfo.Mode().Type())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	assert.Error(t, ReplaceExecutable(filepath.Join(dir, "missing"), nil))
+}